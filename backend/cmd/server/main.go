@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -18,6 +19,7 @@ import (
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/metrics"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/registry"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
 )
 
 func loadSavedAIConfig(store data.Store, aiSvc ai.Service) {
@@ -37,6 +39,20 @@ func loadSavedAIConfig(store data.Store, aiSvc ai.Service) {
 	}
 }
 
+func loadSavedPricing(store data.Store, server *api.Server) {
+	saved, err := store.GetSetting(context.Background(), api.PricingSetting)
+	if err != nil || saved == "" {
+		return
+	}
+	var pricing models.PricingConfig
+	if err := json.Unmarshal([]byte(saved), &pricing); err != nil {
+		slog.Warn("failed to decode saved pricing", "error", err)
+		return
+	}
+	server.SetPricing(pricing)
+	slog.Info("restored pricing from database", "profiles", len(pricing.Profiles))
+}
+
 func loadSavedStackDeploys(store data.Store, server *api.Server) {
 	ctx := context.Background()
 	records, err := store.ListStackDeploys(ctx, 100)
@@ -68,6 +84,37 @@ func loadSavedStackDeploys(store data.Store, server *api.Server) {
 	slog.Info("stack deploy recovery complete", "total", len(records), "restored_to_memory", restored)
 }
 
+func loadSavedDeploys(store data.Store, server *api.Server) {
+	ctx := context.Background()
+	records, err := store.ListSingleDeploys(ctx, 100)
+	if err != nil {
+		slog.Warn("failed to load saved deploys", "error", err)
+		return
+	}
+	restored := 0
+	for i := range records {
+		rec := &records[i]
+		switch {
+		case rec.Status == "deploying" || rec.Status == "awaiting_promotion" || (rec.Status == "pending" && rec.ParentID != ""):
+			// Interrupted by restart (including clusters of a fan-out still waiting their wave) — mark as failed
+			now := time.Now()
+			rec.Status = "failed"
+			rec.CompletedAt = &now
+			if err := store.SaveSingleDeploy(ctx, rec); err != nil {
+				slog.Warn("failed to mark interrupted deploy as failed", "deploy_id", rec.DeployID, "error", err)
+			}
+			slog.Info("marked interrupted deploy as failed", "deploy_id", rec.DeployID)
+		case rec.Status == "pending":
+			// Was waiting for user approval — restore to in-memory
+			server.RestoreDeploy(rec)
+			restored++
+			slog.Info("restored pending deploy", "deploy_id", rec.DeployID, "container", rec.ContainerID)
+		}
+		// completed, failed, cancelled, aborted, partial — remain in DB only, queryable via API
+	}
+	slog.Info("deploy recovery complete", "total", len(records), "restored_to_memory", restored)
+}
+
 func loadSavedClusters(store data.Store, k8sSvc kubernetes.Service) {
 	ctx := context.Background()
 	clusters, err := store.GetRegisteredClusters(ctx)
@@ -82,6 +129,8 @@ func loadSavedClusters(store data.Store, k8sSvc kubernetes.Service) {
 			Kubeconfig: c.Kubeconfig,
 			Context:    c.Context,
 			Registry:   c.Registry,
+			Delivery:   c.Delivery,
+			Git:        c.Git,
 		}
 		if err := k8sSvc.AddCluster(ctx, clusterCfg); err != nil {
 			slog.Warn("failed to restore cluster", "name", c.Name, "error", err)
@@ -113,12 +162,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	aiSvc, err := ai.NewService(cfg.AI)
-	if err != nil {
-		slog.Error("failed to initialize ai service", "error", err)
-		os.Exit(1)
-	}
-
 	dataStore, err := data.NewStore(cfg.Database)
 	if err != nil {
 		slog.Error("failed to initialize data store", "error", err)
@@ -130,6 +173,13 @@ func main() {
 	}
 	defer dataStore.Close()
 
+	// The data store also holds the AI response cache and usage records
+	aiSvc, err := ai.NewService(cfg.AI, dataStore)
+	if err != nil {
+		slog.Error("failed to initialize ai service", "error", err)
+		os.Exit(1)
+	}
+
 	// Restore persisted AI configuration
 	loadSavedAIConfig(dataStore, aiSvc)
 
@@ -149,12 +199,35 @@ func main() {
 		defer metricsColl.Stop()
 	}
 
+	// Sample container usage for resource right-sizing
+	sampler := metrics.NewSampler(cfg.Docker.Sampling, dockerSvc)
+	if cfg.Docker.Sampling.Enabled {
+		sampler.Start()
+		defer sampler.Stop()
+	}
+
 	// Create and start HTTP server
-	server := api.NewServer(cfg, dockerSvc, k8sSvc, aiSvc, dataStore, registrySvc, metricsColl)
+	server := api.NewServer(cfg, dockerSvc, k8sSvc, aiSvc, dataStore, registrySvc, metricsColl, sampler)
 
-	// Restore persisted stack deployments
+	// Pricing changed through the API replaces the configured one
+	loadSavedPricing(dataStore, server)
+
+	// Restore persisted single and stack deployments
+	loadSavedDeploys(dataStore, server)
 	loadSavedStackDeploys(dataStore, server)
 
+	// Start periodic drift detection
+	if cfg.Drift.Enabled {
+		server.StartDriftDetection(time.Duration(cfg.Drift.Interval) * time.Second)
+		defer server.StopDriftDetection()
+	}
+
+	// Start the post-deploy feedback job
+	if cfg.Feedback.Enabled {
+		server.StartFeedback(time.Duration(cfg.Feedback.Window)*time.Second, time.Duration(cfg.Feedback.Interval)*time.Second)
+		defer server.StopFeedback()
+	}
+
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	httpServer := &http.Server{
 		Addr:         addr,
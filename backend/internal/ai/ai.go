@@ -1,16 +1,16 @@
 package ai
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -24,6 +24,7 @@ type ContainerInfo struct {
 	Name        string
 	Image       string
 	ImageTag    string
+	ImageID     string
 	EnvVars     map[string]string
 	Ports       []int
 	Volumes     []string
@@ -32,6 +33,9 @@ type ContainerInfo struct {
 	CPUUsage    string
 	MemoryUsage string
 	NetworkMode string
+	// Usage is the sampled usage of the container; CPUUsage and MemoryUsage are
+	// only a snapshot and reach the prompt when it is nil.
+	Usage *models.UsageProfile
 }
 
 // StackContainerInfo holds information about multiple containers for stack deployment.
@@ -50,6 +54,12 @@ type StackManifestResult struct {
 	Manifests  map[string]map[string]string `json:"manifests"`
 	Reasoning  string                      `json:"reasoning"`
 	Confidence float64                     `json:"confidence"`
+	Cached     bool                        `json:"cached,omitempty"` // served from the response cache
+
+	// The model and prompt templates that produced the manifests; empty for fallback templates
+	Provider      string `json:"provider,omitempty"`
+	Model         string `json:"model,omitempty"`
+	PromptVersion int    `json:"prompt_version,omitempty"`
 }
 
 // Service defines the interface for AI-based manifest generation.
@@ -61,61 +71,82 @@ type Service interface {
 	UpdateConfig(provider, apiKey, model string)
 	GetConfig() map[string]interface{}
 	ListModels(ctx context.Context, provider, apiKey string) ([]string, error)
+	CacheStats(ctx context.Context) models.AICacheStats
+	PurgeCache(ctx context.Context) (int64, error)
+	BudgetStatus(ctx context.Context) models.AIBudgetStatus
 }
 
-// NewService creates a new AI service with the given configuration.
-func NewService(cfg config.AIConfig) (Service, error) {
+// NewService creates a new AI service with the given configuration. store records
+// the usage of every request and caches generated manifests when cfg.Cache is
+// enabled; it may be nil.
+func NewService(cfg config.AIConfig, store Store) (Service, error) {
 	maxTokens := cfg.MaxTokens
 	if maxTokens < 4096 {
 		maxTokens = 4096
 	}
-	svc := &aiService{
-		provider:    cfg.Provider,
-		model:       cfg.Model,
-		apiKey:      cfg.APIKey,
-		temperature: cfg.Temperature,
-		maxTokens:   maxTokens,
-		httpClient:  &http.Client{Timeout: 120 * time.Second},
-	}
-
-	switch cfg.Provider {
-	case "openai":
-		svc.endpoint = "https://api.openai.com/v1/chat/completions"
-	case "claude":
-		svc.endpoint = "https://api.anthropic.com/v1/messages"
-	case "gemini":
-		svc.endpoint = "https://generativelanguage.googleapis.com/v1beta"
-	case "azure-openai":
-		svc.endpoint = cfg.APIKey // Azure uses a custom endpoint
-	default:
-		svc.endpoint = "https://api.openai.com/v1/chat/completions"
-	}
-
-	return svc, nil
+	provider := cfg.Provider
+	if provider == "" {
+		provider = "openai"
+	}
+	if !HasProvider(provider) {
+		return nil, fmt.Errorf("unsupported AI provider %q (available: %s)", provider, strings.Join(Providers(), ", "))
+	}
+
+	cacheTTL := cfg.Cache.TTL
+	if cacheTTL <= 0 {
+		cacheTTL = defaultCacheTTL
+	}
+
+	return &aiService{
+		provider:     provider,
+		model:        cfg.Model,
+		apiKey:       cfg.APIKey,
+		temperature:  cfg.Temperature,
+		maxTokens:    maxTokens,
+		providers:    cfg.Providers,
+		httpClient:   &http.Client{Timeout: 120 * time.Second},
+		pricing:      cfg.Pricing,
+		budget:       cfg.Budget,
+		fewShot:      cfg.FewShot,
+		store:        store,
+		cacheEnabled: cfg.Cache.Enabled,
+		cacheTTL:     cacheTTL,
+	}, nil
 }
 
 type aiService struct {
+	mu          sync.RWMutex // guards the fields below; UpdateConfig runs beside in-flight requests
 	provider    string
 	model       string
 	apiKey      string
 	temperature float64
 	maxTokens   int
-	endpoint    string
+	providers   map[string]config.ProviderConfig // per-provider overrides
 	httpClient  *http.Client
+
+	pricing map[string]config.ModelPrice
+	budget  config.BudgetConfig
+	fewShot config.FewShotConfig
+
+	store         Store
+	cacheEnabled  bool
+	cacheTTL      time.Duration
+	cacheHits     atomic.Int64
+	cacheMisses   atomic.Int64
+	cacheBypassed atomic.Int64
+}
+
+// hasAPIKey reports whether a real key (not the example placeholder) is set.
+func hasAPIKey(apiKey string) bool {
+	return apiKey != "" && apiKey != "your-api-key-here"
 }
 
 // UpdateConfig updates the AI service configuration at runtime.
 func (s *aiService) UpdateConfig(provider, apiKey, model string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if provider != "" {
 		s.provider = provider
-		switch provider {
-		case "claude":
-			s.endpoint = "https://api.anthropic.com/v1/messages"
-		case "openai":
-			s.endpoint = "https://api.openai.com/v1/chat/completions"
-		case "gemini":
-			s.endpoint = "https://generativelanguage.googleapis.com/v1beta"
-		}
 	}
 	if apiKey != "" {
 		s.apiKey = apiKey
@@ -127,52 +158,120 @@ func (s *aiService) UpdateConfig(provider, apiKey, model string) {
 
 // GetConfig returns the current AI service configuration (api key is masked).
 func (s *aiService) GetConfig() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	maskedKey := ""
-	if s.apiKey != "" && s.apiKey != "your-api-key-here" {
+	if hasAPIKey(s.apiKey) {
 		if len(s.apiKey) > 8 {
 			maskedKey = s.apiKey[:4] + "..." + s.apiKey[len(s.apiKey)-4:]
 		} else {
 			maskedKey = "****"
 		}
 	}
+	_, settings, keyOptional, err := resolveProvider(s.provider, s.providers, s.httpClient)
 	return map[string]interface{}{
 		"provider":    s.provider,
 		"model":       s.model,
 		"api_key":     maskedKey,
 		"temperature": s.temperature,
-		"configured":  s.apiKey != "" && s.apiKey != "your-api-key-here",
+		"base_url":    settings.BaseURL,
+		"providers":   Providers(),
+		"configured":  err == nil && (keyOptional || hasAPIKey(s.apiKey)),
 	}
 }
 
-func (s *aiService) callProvider(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	switch s.provider {
-	case "claude":
-		return s.callClaudeAPI(ctx, systemPrompt, userPrompt)
-	case "gemini":
-		return s.callGeminiAPI(ctx, systemPrompt, userPrompt)
-	default:
-		return s.callOpenAIAPI(ctx, systemPrompt, userPrompt)
+// configured reports whether requests can go to the provider: it has a key or runs
+// without one. Unconfigured services generate fallback templates.
+func (s *aiService) configured() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if hasAPIKey(s.apiKey) {
+		return true
 	}
+	_, _, keyOptional, err := resolveProvider(s.provider, s.providers, s.httpClient)
+	return err == nil && keyOptional
+}
+
+// providerName returns the name of the current provider.
+func (s *aiService) providerName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.provider
+}
+
+// callProvider sends one prompt to the current provider. Requests get at least
+// minTokens of output budget. The call, when given, receives the provider, model
+// and tokens used.
+func (s *aiService) callProvider(ctx context.Context, call *aiCall, systemPrompt, userPrompt string, minTokens int) (string, error) {
+	s.mu.RLock()
+	p, _, _, err := resolveProvider(s.provider, s.providers, s.httpClient)
+	req := CompletionRequest{
+		Model:        s.model,
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+		Temperature:  s.temperature,
+		MaxTokens:    s.maxTokens,
+	}
+	if hasAPIKey(s.apiKey) {
+		req.APIKey = s.apiKey
+	}
+	if call != nil {
+		call.provider, call.model = s.provider, s.model
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = 4096
+	}
+	if req.MaxTokens < minTokens {
+		req.MaxTokens = minTokens
+	}
+	var completion *Completion
+	if sp, ok := p.(StreamingProvider); ok && progressFunc(ctx) != nil {
+		attempt := 1
+		if call != nil {
+			attempt = call.retries + 1
+		}
+		progress := &streamProgress{ctx: ctx, call: call, attempt: attempt}
+		completion, err = sp.Stream(ctx, req, progress.onText)
+		progress.flush()
+	} else {
+		completion, err = p.Complete(ctx, req)
+	}
+	if err != nil {
+		return "", err
+	}
+	if call != nil {
+		call.promptTokens += completion.PromptTokens
+		call.completionTokens += completion.CompletionTokens
+	}
+	return completion.Text, nil
 }
 
 // callWithRetry calls the AI provider with up to maxRetries retries using exponential backoff.
-func (s *aiService) callWithRetry(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+func (s *aiService) callWithRetry(ctx context.Context, call *aiCall, systemPrompt, userPrompt string, minTokens int) (string, error) {
 	const maxAttempts = 3
 	var lastErr error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
 		if attempt > 0 {
+			call.retries = attempt
 			backoff := time.Duration(attempt) * 3 * time.Second // 3s, 6s
-			slog.Warn("AI API call failed, retrying", "provider", s.provider, "attempt", attempt+1, "backoff", backoff, "error", lastErr)
+			slog.Warn("AI API call failed, retrying", "provider", s.providerName(), "attempt", attempt+1, "backoff", backoff, "error", lastErr)
+			emitProgress(ctx, call, models.GenerationEvent{Type: models.GenerationRetry, Attempt: attempt + 1, Message: truncate(lastErr.Error(), 500)})
 			select {
 			case <-time.After(backoff):
 			case <-ctx.Done():
 				return "", fmt.Errorf("context cancelled during retry backoff: %w", ctx.Err())
 			}
 		}
-		response, err := s.callProvider(ctx, systemPrompt, userPrompt)
+		emitProgress(ctx, call, models.GenerationEvent{Type: models.GenerationStarted, Attempt: attempt + 1})
+		response, err := s.callProvider(ctx, call, systemPrompt, userPrompt, minTokens)
 		if err == nil {
 			if attempt > 0 {
-				slog.Info("AI API call succeeded after retry", "provider", s.provider, "attempt", attempt+1)
+				slog.Info("AI API call succeeded after retry", "provider", s.providerName(), "attempt", attempt+1)
 			}
 			return response, nil
 		}
@@ -182,18 +281,38 @@ func (s *aiService) callWithRetry(ctx context.Context, systemPrompt, userPrompt
 }
 
 func (s *aiService) GenerateManifest(ctx context.Context, info ContainerInfo, history []models.DeploymentHistory) (*models.ManifestResult, error) {
-	if s.apiKey == "" || s.apiKey == "your-api-key-here" {
+	if !s.configured() {
 		fb := s.generateFallbackManifest(info)
 		fb.Reasoning = "[Fallback] AI API 키가 설정되지 않아 기본 템플릿으로 생성되었습니다. Settings에서 AI 설정을 구성하면 더 정확한 매니페스트를 생성할 수 있습니다."
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "generate", Type: models.GenerationFallback, Message: "AI API key not configured"})
+		return fb, nil
+	}
+
+	examples := s.selectExamples([]ContainerInfo{info}, history)
+	key, provider, model := s.cacheKey("manifest", manifestCacheInputs(info, exampleHistory(examples)))
+	var cached models.ManifestResult
+	if s.lookupCache(ctx, key, &cached) {
+		cached.Cached = true
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "generate", Type: models.GenerationCompleted, Message: "served from cache"})
+		return &cached, nil
+	}
+
+	if budget, exceeded := s.budgetExceeded(ctx); exceeded {
+		fb := s.generateFallbackManifest(info)
+		fb.Reasoning = fmt.Sprintf("[Fallback] 이번 달 AI 예산($%.2f)을 초과하여($%.2f 사용) 기본 템플릿으로 생성되었습니다.", budget.MonthlyUSD, budget.SpentUSD)
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "generate", Type: models.GenerationFallback, Message: "monthly AI budget exceeded"})
 		return fb, nil
 	}
 
 	systemPrompt := buildSystemPrompt()
-	userPrompt := buildUserPrompt(info, history)
+	userPrompt := buildUserPrompt(info, examples)
+	logExamples(ctx, "generate", len(history), examples)
 
-	response, err := s.callWithRetry(ctx, systemPrompt, userPrompt)
+	call := s.newCall("generate")
+	response, err := s.callWithRetry(ctx, call, systemPrompt, userPrompt, 0)
 	if err != nil {
-		slog.Error("AI API call failed after retry", "provider", s.provider, "error", err)
+		slog.Error("AI API call failed after retry", "provider", s.providerName(), "error", err)
+		s.finishCall(ctx, call, models.AIOutcomeFallback, err)
 		fb := s.generateFallbackManifest(info)
 		fb.Reasoning = fmt.Sprintf("[Fallback] AI API 호출 실패: %v. 기본 템플릿으로 생성되었습니다. API 키와 네트워크 연결을 확인해주세요.", err)
 		return fb, nil
@@ -201,32 +320,50 @@ func (s *aiService) GenerateManifest(ctx context.Context, info ContainerInfo, hi
 
 	result, err := parseManifestResponse(response)
 	if err != nil {
-		slog.Error("failed to parse AI response", "provider", s.provider, "error", err, "response_len", len(response))
+		slog.Error("failed to parse AI response", "provider", s.providerName(), "error", err, "response_len", len(response))
+		s.finishCall(ctx, call, models.AIOutcomeParseError, err)
 		fb := s.generateFallbackManifest(info)
 		fb.Reasoning = fmt.Sprintf("[Fallback] AI 응답 파싱 실패: %v. 기본 템플릿으로 생성되었습니다. 다시 시도해보세요.", err)
 		return fb, nil
 	}
+	s.finishCall(ctx, call, models.AIOutcomeOK, nil)
+	result.Provider, result.Model, result.PromptVersion = call.provider, call.model, promptVersion
+	for _, ex := range examples {
+		result.Examples = append(result.Examples, ex.History.ID)
+	}
 
+	s.storeCache(ctx, "manifest", key, provider, model, result)
 	return result, nil
 }
 
 func (s *aiService) RefineManifest(ctx context.Context, currentManifest *models.ManifestResult, feedback string) (*models.ManifestResult, error) {
-	if s.apiKey == "" || s.apiKey == "your-api-key-here" {
+	if !s.configured() {
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "refine", Type: models.GenerationFailed, Message: "AI API key not configured"})
 		return nil, fmt.Errorf("AI API key not configured. Settings에서 API 키를 설정해주세요.")
 	}
 
+	if budget, exceeded := s.budgetExceeded(ctx); exceeded {
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "refine", Type: models.GenerationFailed, Message: "monthly AI budget exceeded"})
+		return nil, fmt.Errorf("이번 달 AI 예산($%.2f)을 초과했습니다($%.2f 사용)", budget.MonthlyUSD, budget.SpentUSD)
+	}
+
 	systemPrompt := buildSystemPrompt()
 	userPrompt := buildRefinePrompt(currentManifest, feedback)
 
-	response, err := s.callWithRetry(ctx, systemPrompt, userPrompt)
+	call := s.newCall("refine")
+	response, err := s.callWithRetry(ctx, call, systemPrompt, userPrompt, 0)
 	if err != nil {
+		s.finishCall(ctx, call, models.AIOutcomeError, err)
 		return nil, fmt.Errorf("AI API 호출 실패: %w", err)
 	}
 
 	result, err := parseManifestResponse(response)
 	if err != nil {
+		s.finishCall(ctx, call, models.AIOutcomeParseError, err)
 		return nil, fmt.Errorf("AI 응답 파싱 실패: %w", err)
 	}
+	s.finishCall(ctx, call, models.AIOutcomeOK, nil)
+	result.Provider, result.Model, result.PromptVersion = call.provider, call.model, promptVersion
 
 	return result, nil
 }
@@ -281,22 +418,10 @@ manifest 생성 요구사항:
 중요: reasoning 필드는 반드시 한국어로 작성하세요.`
 }
 
-func buildUserPrompt(info ContainerInfo, history []models.DeploymentHistory) string {
+func buildUserPrompt(info ContainerInfo, examples []fewShotExample) string {
 	var b strings.Builder
 
-	if len(history) > 0 {
-		b.WriteString("## Similar Deployment History (for reference)\n")
-		for i, h := range history {
-			if i >= 3 {
-				break
-			}
-			fmt.Fprintf(&b, "- Service: %s, Image: %s:%s, CPU: %s/%s, Memory: %s/%s, Replicas: %d, Success: %v\n",
-				h.ServiceName, h.ImageName, h.ImageTag,
-				h.CPURequest, h.CPULimit, h.MemoryRequest, h.MemoryLimit,
-				h.Replicas, h.Success)
-		}
-		b.WriteString("\n")
-	}
+	writeExamples(&b, examples)
 
 	b.WriteString("## Current Container Information\n")
 	fmt.Fprintf(&b, "- Name: %s\n", info.Name)
@@ -319,11 +444,15 @@ func buildUserPrompt(info ContainerInfo, history []models.DeploymentHistory) str
 		fmt.Fprintf(&b, "%s\n", strings.Join(envPairs, ", "))
 	}
 
-	if info.CPUUsage != "" {
-		fmt.Fprintf(&b, "- Current CPU Usage: %s\n", info.CPUUsage)
-	}
-	if info.MemoryUsage != "" {
-		fmt.Fprintf(&b, "- Current Memory Usage: %s\n", info.MemoryUsage)
+	if info.Usage != nil {
+		writeUsage(&b, info.Usage)
+	} else {
+		if info.CPUUsage != "" {
+			fmt.Fprintf(&b, "- Current CPU Usage: %s\n", info.CPUUsage)
+		}
+		if info.MemoryUsage != "" {
+			fmt.Fprintf(&b, "- Current Memory Usage: %s\n", info.MemoryUsage)
+		}
 	}
 
 	if len(info.Volumes) > 0 {
@@ -338,430 +467,28 @@ func buildUserPrompt(info ContainerInfo, history []models.DeploymentHistory) str
 	return b.String()
 }
 
-type openAIRequest struct {
-	Model       string          `json:"model"`
-	Messages    []openAIMessage `json:"messages"`
-	Temperature float64         `json:"temperature"`
-	MaxTokens   int             `json:"max_tokens"`
-}
-
-type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type openAIResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
-}
-
-func (s *aiService) callOpenAIAPI(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	maxTokens := s.maxTokens
-	if maxTokens <= 0 {
-		maxTokens = 4096
-	}
-	reqBody := openAIRequest{
-		Model: s.model,
-		Messages: []openAIMessage{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: userPrompt},
-		},
-		Temperature: s.temperature,
-		MaxTokens:   maxTokens,
-	}
-
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("marshaling request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", s.endpoint, bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("calling API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("reading response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var openAIResp openAIResponse
-	if err := json.Unmarshal(respBody, &openAIResp); err != nil {
-		return "", fmt.Errorf("parsing response: %w", err)
-	}
-
-	if len(openAIResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
-	}
-
-	return openAIResp.Choices[0].Message.Content, nil
-}
-
-type claudeRequest struct {
-	Model       string          `json:"model"`
-	MaxTokens   int             `json:"max_tokens"`
-	System      string          `json:"system"`
-	Messages    []claudeMessage `json:"messages"`
-	Temperature float64         `json:"temperature"`
-}
-
-type claudeMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type claudeResponse struct {
-	Content []struct {
-		Text string `json:"text"`
-	} `json:"content"`
-}
-
-func (s *aiService) callClaudeAPI(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	maxTokens := s.maxTokens
-	if maxTokens <= 0 {
-		maxTokens = 4096
-	}
-	reqBody := claudeRequest{
-		Model:     s.model,
-		MaxTokens: maxTokens,
-		System:    systemPrompt,
-		Messages: []claudeMessage{
-			{Role: "user", Content: userPrompt},
-		},
-		Temperature: s.temperature,
-	}
-
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("marshaling request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", s.endpoint, bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", s.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("calling API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("reading response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var claudeResp claudeResponse
-	if err := json.Unmarshal(respBody, &claudeResp); err != nil {
-		return "", fmt.Errorf("parsing response: %w", err)
-	}
-
-	if len(claudeResp.Content) == 0 {
-		return "", fmt.Errorf("no content in response")
-	}
-
-	return claudeResp.Content[0].Text, nil
-}
-
-// --- Gemini API ---
-
-type geminiRequest struct {
-	Contents         []geminiContent        `json:"contents"`
-	SystemInstruction *geminiContent        `json:"systemInstruction,omitempty"`
-	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
-}
-
-type geminiContent struct {
-	Parts []geminiPart `json:"parts"`
-	Role  string       `json:"role,omitempty"`
-}
-
-type geminiPart struct {
-	Text string `json:"text"`
-}
-
-type geminiGenerationConfig struct {
-	Temperature     float64 `json:"temperature"`
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
-	ResponseMimeType string `json:"responseMimeType,omitempty"`
-}
-
-type geminiResponse struct {
-	Candidates []struct {
-		Content struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		} `json:"content"`
-		FinishReason string `json:"finishReason,omitempty"`
-	} `json:"candidates"`
-	Error *struct {
-		Message string `json:"message"`
-		Code    int    `json:"code"`
-	} `json:"error,omitempty"`
-}
-
-func (s *aiService) callGeminiAPI(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	model := s.model
-	if model == "" {
-		model = "gemini-2.0-flash"
-	}
-
-	endpoint := fmt.Sprintf("%s/models/%s:generateContent?key=%s", s.endpoint, model, s.apiKey)
-
-	maxTokens := s.maxTokens
-	if maxTokens <= 0 {
-		maxTokens = 4096
-	}
-	// Gemini 2.5 "thinking" models need higher token budget since
-	// thinking tokens count towards maxOutputTokens
-	if strings.Contains(model, "2.5") && maxTokens < 8192 {
-		maxTokens = 8192
-	}
-
-	reqBody := geminiRequest{
-		SystemInstruction: &geminiContent{
-			Parts: []geminiPart{{Text: systemPrompt}},
-		},
-		Contents: []geminiContent{
-			{
-				Role: "user",
-				Parts: []geminiPart{{Text: userPrompt}},
-			},
-		},
-		GenerationConfig: &geminiGenerationConfig{
-			Temperature:      s.temperature,
-			MaxOutputTokens:  maxTokens,
-			ResponseMimeType: "application/json",
-		},
-	}
-
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("marshaling request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("calling Gemini API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("reading response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Gemini API returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var geminiResp geminiResponse
-	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
-		return "", fmt.Errorf("parsing response: %w", err)
-	}
-
-	if geminiResp.Error != nil {
-		return "", fmt.Errorf("Gemini API error: %s", geminiResp.Error.Message)
-	}
-
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content in Gemini response")
-	}
-
-	// Concatenate all parts (Gemini may split response across multiple parts)
-	var fullText strings.Builder
-	for _, part := range geminiResp.Candidates[0].Content.Parts {
-		fullText.WriteString(part.Text)
-	}
-
-	text := fullText.String()
-	finishReason := geminiResp.Candidates[0].FinishReason
-	slog.Info("Gemini API response received",
-		"text_len", len(text),
-		"parts_count", len(geminiResp.Candidates[0].Content.Parts),
-		"finish_reason", finishReason)
-
-	if finishReason == "MAX_TOKENS" {
-		slog.Warn("Gemini response was truncated (MAX_TOKENS). Consider increasing maxOutputTokens", "text_len", len(text), "max_tokens", maxTokens)
-	}
-
-	return text, nil
-}
-
 // --- List Models ---
 
 func (s *aiService) ListModels(ctx context.Context, provider, apiKey string) ([]string, error) {
+	s.mu.RLock()
 	if provider == "" {
 		provider = s.provider
 	}
 	if apiKey == "" {
 		apiKey = s.apiKey
 	}
-	if apiKey == "" || apiKey == "your-api-key-here" {
-		return nil, fmt.Errorf("API key is required to list models")
-	}
-
-	switch provider {
-	case "openai":
-		return s.listOpenAIModels(ctx, apiKey)
-	case "claude":
-		return s.listClaudeModels(ctx, apiKey)
-	case "gemini":
-		return s.listGeminiModels(ctx, apiKey)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", provider)
-	}
-}
-
-func (s *aiService) listOpenAIModels(ctx context.Context, apiKey string) ([]string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.openai.com/v1/models", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("calling OpenAI models API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenAI API returned %d: %s", resp.StatusCode, string(body))
-	}
-
-	var result struct {
-		Data []struct {
-			ID      string `json:"id"`
-			OwnedBy string `json:"owned_by"`
-		} `json:"data"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
-	}
-
-	// Filter to chat models only
-	var models []string
-	for _, m := range result.Data {
-		if strings.HasPrefix(m.ID, "gpt-") {
-			models = append(models, m.ID)
-		}
-	}
-	sort.Strings(models)
-	return models, nil
-}
-
-func (s *aiService) listClaudeModels(ctx context.Context, apiKey string) ([]string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.anthropic.com/v1/models", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("x-api-key", apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("calling Anthropic models API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Anthropic API returned %d: %s", resp.StatusCode, string(body))
-	}
-
-	var result struct {
-		Data []struct {
-			ID string `json:"id"`
-		} `json:"data"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
-	}
-
-	var models []string
-	for _, m := range result.Data {
-		models = append(models, m.ID)
-	}
-	sort.Strings(models)
-	return models, nil
-}
-
-func (s *aiService) listGeminiModels(ctx context.Context, apiKey string) ([]string, error) {
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models?key=%s", apiKey)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := s.httpClient.Do(req)
+	p, _, keyOptional, err := resolveProvider(provider, s.providers, s.httpClient)
+	s.mu.RUnlock()
 	if err != nil {
-		return nil, fmt.Errorf("calling Gemini models API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Gemini API returned %d: %s", resp.StatusCode, string(body))
-	}
-
-	var result struct {
-		Models []struct {
-			Name                       string   `json:"name"`
-			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
-		} `json:"models"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
-
-	var models []string
-	for _, m := range result.Models {
-		// Filter to models that support content generation
-		for _, method := range m.SupportedGenerationMethods {
-			if method == "generateContent" {
-				// "models/gemini-2.0-flash" → "gemini-2.0-flash"
-				name := strings.TrimPrefix(m.Name, "models/")
-				models = append(models, name)
-				break
-			}
+	if !hasAPIKey(apiKey) {
+		if !keyOptional {
+			return nil, fmt.Errorf("API key is required to list models")
 		}
+		apiKey = ""
 	}
-	sort.Strings(models)
-	return models, nil
+	return p.ListModels(ctx, apiKey)
 }
 
 func parseManifestResponse(response string) (*models.ManifestResult, error) {
@@ -865,6 +592,8 @@ func (s *aiService) generateFallbackManifest(info ContainerInfo) *models.Manifes
 		containerPort = info.Ports[0]
 	}
 
+	sizing := fallbackSizing(info)
+
 	deployment := fmt.Sprintf(`apiVersion: apps/v1
 kind: Deployment
 metadata:
@@ -888,11 +617,11 @@ spec:
         - containerPort: %d
         resources:
           requests:
-            cpu: "100m"
-            memory: "128Mi"
+            cpu: "%s"
+            memory: "%s"
           limits:
-            cpu: "500m"
-            memory: "512Mi"
+            cpu: "%s"
+            memory: "%s"
         livenessProbe:
           httpGet:
             path: /
@@ -907,7 +636,8 @@ spec:
           periodSeconds: 10
         securityContext:
           runAsNonRoot: false
-          readOnlyRootFilesystem: false`, name, name, name, name, name, image, containerPort, containerPort, containerPort)
+          readOnlyRootFilesystem: false`, name, name, name, name, name, image, containerPort,
+		sizing.CPURequest, sizing.MemoryRequest, sizing.CPULimit, sizing.MemoryLimit, containerPort, containerPort)
 
 	service := fmt.Sprintf(`apiVersion: v1
 kind: Service
@@ -930,29 +660,55 @@ spec:
 	}
 }
 
+// fallbackSizing returns the resources of fallback templates: the ones suggested from
+// sampled usage, or fixed defaults without samples.
+func fallbackSizing(info ContainerInfo) models.ResourceSizing {
+	if info.Usage != nil {
+		return info.Usage.Suggested
+	}
+	return models.ResourceSizing{CPURequest: "100m", CPULimit: "500m", MemoryRequest: "128Mi", MemoryLimit: "512Mi"}
+}
+
 // --- Stack Manifest Generation ---
 
+// stackMinTokens is the output budget of stack requests, which return many manifests.
+const stackMinTokens = 16384
+
 func (s *aiService) GenerateStackManifest(ctx context.Context, info StackContainerInfo, history []models.DeploymentHistory) (*StackManifestResult, error) {
-	if s.apiKey == "" || s.apiKey == "your-api-key-here" {
+	if !s.configured() {
 		fb := s.generateFallbackStackManifest(info)
 		fb.Reasoning = "[Fallback] AI API 키가 설정되지 않아 기본 템플릿으로 생성되었습니다. Settings에서 AI 설정을 구성하면 서비스 간 연결 자동 감지 등 더 정확한 매니페스트를 생성할 수 있습니다."
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "generate_stack", Type: models.GenerationFallback, Message: "AI API key not configured"})
 		return fb, nil
 	}
 
-	// Stack manifests are much larger — temporarily increase token limit.
-	// Multiple services with Deployment+Service+ConfigMap+Secret YAML requires a large budget.
-	origMaxTokens := s.maxTokens
-	if s.maxTokens < 16384 {
-		s.maxTokens = 16384
+	examples := s.selectExamples(info.Containers, history)
+	key, provider, model := s.cacheKey("stack", stackCacheInputs(info, exampleHistory(examples)))
+	var cached StackManifestResult
+	if s.lookupCache(ctx, key, &cached) {
+		cached.Cached = true
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "generate_stack", Type: models.GenerationCompleted, Message: "served from cache"})
+		return &cached, nil
 	}
-	defer func() { s.maxTokens = origMaxTokens }()
 
+	if budget, exceeded := s.budgetExceeded(ctx); exceeded {
+		fb := s.generateFallbackStackManifest(info)
+		fb.Reasoning = fmt.Sprintf("[Fallback] 이번 달 AI 예산($%.2f)을 초과하여($%.2f 사용) 기본 템플릿으로 생성되었습니다.", budget.MonthlyUSD, budget.SpentUSD)
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "generate_stack", Type: models.GenerationFallback, Message: "monthly AI budget exceeded"})
+		return fb, nil
+	}
+
+	// Stack manifests are much larger — raise the token limit for this request.
+	// Multiple services with Deployment+Service+ConfigMap+Secret YAML requires a large budget.
 	systemPrompt := buildStackSystemPrompt()
-	userPrompt := buildStackUserPrompt(info, history)
+	userPrompt := buildStackUserPrompt(info, examples)
+	logExamples(ctx, "generate_stack", len(history), examples)
 
-	response, err := s.callWithRetry(ctx, systemPrompt, userPrompt)
+	call := s.newCall("generate_stack")
+	response, err := s.callWithRetry(ctx, call, systemPrompt, userPrompt, stackMinTokens)
 	if err != nil {
-		slog.Error("AI API call failed for stack after retry", "provider", s.provider, "error", err)
+		slog.Error("AI API call failed for stack after retry", "provider", s.providerName(), "error", err)
+		s.finishCall(ctx, call, models.AIOutcomeFallback, err)
 		fb := s.generateFallbackStackManifest(info)
 		fb.Reasoning = fmt.Sprintf("[Fallback] AI API 호출 실패: %v. 기본 템플릿으로 생성되었습니다. API 키와 네트워크 연결을 확인해주세요.", err)
 		return fb, nil
@@ -960,38 +716,47 @@ func (s *aiService) GenerateStackManifest(ctx context.Context, info StackContain
 
 	result, err := parseStackManifestResponse(response)
 	if err != nil {
-		slog.Error("failed to parse stack AI response", "provider", s.provider, "error", err, "response_len", len(response))
+		slog.Error("failed to parse stack AI response", "provider", s.providerName(), "error", err, "response_len", len(response))
+		s.finishCall(ctx, call, models.AIOutcomeParseError, err)
 		fb := s.generateFallbackStackManifest(info)
 		fb.Reasoning = fmt.Sprintf("[Fallback] AI 응답 파싱 실패: %v. 기본 템플릿으로 생성되었습니다. 다시 시도해보세요.", err)
 		return fb, nil
 	}
+	s.finishCall(ctx, call, models.AIOutcomeOK, nil)
+	result.Provider, result.Model, result.PromptVersion = call.provider, call.model, promptVersion
 
+	s.storeCache(ctx, "stack", key, provider, model, result)
 	return result, nil
 }
 
 func (s *aiService) RefineStackManifest(ctx context.Context, current *StackManifestResult, feedback string) (*StackManifestResult, error) {
-	if s.apiKey == "" || s.apiKey == "your-api-key-here" {
+	if !s.configured() {
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "refine_stack", Type: models.GenerationFailed, Message: "AI API key not configured"})
 		return nil, fmt.Errorf("AI API key not configured. Settings에서 API 키를 설정해주세요.")
 	}
 
-	origMaxTokens := s.maxTokens
-	if s.maxTokens < 16384 {
-		s.maxTokens = 16384
+	if budget, exceeded := s.budgetExceeded(ctx); exceeded {
+		emitProgress(ctx, nil, models.GenerationEvent{Operation: "refine_stack", Type: models.GenerationFailed, Message: "monthly AI budget exceeded"})
+		return nil, fmt.Errorf("이번 달 AI 예산($%.2f)을 초과했습니다($%.2f 사용)", budget.MonthlyUSD, budget.SpentUSD)
 	}
-	defer func() { s.maxTokens = origMaxTokens }()
 
 	systemPrompt := buildStackSystemPrompt()
 	userPrompt := buildStackRefinePrompt(current, feedback)
 
-	response, err := s.callWithRetry(ctx, systemPrompt, userPrompt)
+	call := s.newCall("refine_stack")
+	response, err := s.callWithRetry(ctx, call, systemPrompt, userPrompt, stackMinTokens)
 	if err != nil {
+		s.finishCall(ctx, call, models.AIOutcomeError, err)
 		return nil, fmt.Errorf("AI API 호출 실패: %w", err)
 	}
 
 	result, err := parseStackManifestResponse(response)
 	if err != nil {
+		s.finishCall(ctx, call, models.AIOutcomeParseError, err)
 		return nil, fmt.Errorf("AI 응답 파싱 실패: %w", err)
 	}
+	s.finishCall(ctx, call, models.AIOutcomeOK, nil)
+	result.Provider, result.Model, result.PromptVersion = call.provider, call.model, promptVersion
 
 	return result, nil
 }
@@ -1039,7 +804,7 @@ manifests 규칙:
 중요: reasoning 필드는 반드시 한국어로 작성하세요.`
 }
 
-func buildStackUserPrompt(info StackContainerInfo, history []models.DeploymentHistory) string {
+func buildStackUserPrompt(info StackContainerInfo, examples []fewShotExample) string {
 	var b strings.Builder
 
 	fmt.Fprintf(&b, "## Stack: %s (namespace: %s)\n\n", info.StackName, info.Namespace)
@@ -1048,18 +813,7 @@ func buildStackUserPrompt(info StackContainerInfo, history []models.DeploymentHi
 		fmt.Fprintf(&b, "## 사용자 요구사항\n%s\n\n", info.UserPrompt)
 	}
 
-	if len(history) > 0 {
-		b.WriteString("## 유사 배포 이력\n")
-		for i, h := range history {
-			if i >= 3 {
-				break
-			}
-			fmt.Fprintf(&b, "- %s (%s:%s), CPU: %s/%s, Memory: %s/%s, Replicas: %d\n",
-				h.ServiceName, h.ImageName, h.ImageTag,
-				h.CPURequest, h.CPULimit, h.MemoryRequest, h.MemoryLimit, h.Replicas)
-		}
-		b.WriteString("\n")
-	}
+	writeExamples(&b, examples)
 
 	for i, c := range info.Containers {
 		fmt.Fprintf(&b, "## Container %d: %s\n", i+1, c.Name)
@@ -1085,11 +839,15 @@ func buildStackUserPrompt(info StackContainerInfo, history []models.DeploymentHi
 			}
 		}
 
-		if c.CPUUsage != "" {
-			fmt.Fprintf(&b, "- CPU Usage: %s\n", c.CPUUsage)
-		}
-		if c.MemoryUsage != "" {
-			fmt.Fprintf(&b, "- Memory Usage: %s\n", c.MemoryUsage)
+		if c.Usage != nil {
+			writeUsage(&b, c.Usage)
+		} else {
+			if c.CPUUsage != "" {
+				fmt.Fprintf(&b, "- CPU Usage: %s\n", c.CPUUsage)
+			}
+			if c.MemoryUsage != "" {
+				fmt.Fprintf(&b, "- Memory Usage: %s\n", c.MemoryUsage)
+			}
 		}
 		if len(c.Volumes) > 0 {
 			fmt.Fprintf(&b, "- Volumes: %s\n", strings.Join(c.Volumes, ", "))
@@ -1347,6 +1105,7 @@ data:
             name: %s`, configMapName)
 		}
 
+		sizing := fallbackSizing(c)
 		manifests["Deployment"][name] = fmt.Sprintf(`apiVersion: apps/v1
 kind: Deployment
 metadata:
@@ -1372,11 +1131,12 @@ spec:
         - containerPort: %d%s
         resources:
           requests:
-            cpu: "100m"
-            memory: "128Mi"
+            cpu: "%s"
+            memory: "%s"
           limits:
-            cpu: "500m"
-            memory: "512Mi"`, name, name, info.StackName, name, name, info.StackName, name, image, port, envFromBlock)
+            cpu: "%s"
+            memory: "%s"`, name, name, info.StackName, name, name, info.StackName, name, image, port, envFromBlock,
+			sizing.CPURequest, sizing.MemoryRequest, sizing.CPULimit, sizing.MemoryLimit)
 
 		k8sSvcType := "ClusterIP"
 		if svcType == "web-server" || svcType == "web-application" {
@@ -3,9 +3,13 @@ package ai
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/config"
 	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
@@ -18,7 +22,7 @@ func TestNewService(t *testing.T) {
 		APIKey:      "test-key",
 		Temperature: 0.3,
 		MaxTokens:   2000,
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("NewService failed: %v", err)
 	}
@@ -77,7 +81,8 @@ func TestBuildUserPrompt_WithHistory(t *testing.T) {
 		{ServiceName: "old-app", ImageName: "app", ImageTag: "v0.9", CPURequest: "200m", CPULimit: "1", MemoryRequest: "256Mi", MemoryLimit: "1Gi", Replicas: 3, Success: true},
 	}
 
-	prompt := buildUserPrompt(info, history)
+	prompt := buildUserPrompt(info, []fewShotExample{{History: history[0], Score: 0.9, Healthy: true,
+		Snippet: "apiVersion: apps/v1\nkind: Deployment"}})
 
 	if !containsStr(prompt, "Similar Deployment History") {
 		t.Error("user prompt should include history section")
@@ -85,6 +90,9 @@ func TestBuildUserPrompt_WithHistory(t *testing.T) {
 	if !containsStr(prompt, "old-app") {
 		t.Error("user prompt should include historical service name")
 	}
+	if !containsStr(prompt, "kind: Deployment") {
+		t.Error("user prompt should include the example manifest")
+	}
 }
 
 func TestBuildUserPrompt_WithResourceUsage(t *testing.T) {
@@ -113,6 +121,51 @@ func TestBuildUserPrompt_WithResourceUsage(t *testing.T) {
 	}
 }
 
+func TestBuildUserPrompt_WithSampledUsage(t *testing.T) {
+	from := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	info := ContainerInfo{
+		Name:        "app",
+		Image:       "app",
+		CPUUsage:    "25.3%",
+		MemoryUsage: "128Mi",
+		Usage: &models.UsageProfile{
+			Samples:  120,
+			From:     from,
+			To:       from.Add(time.Hour),
+			CPU:      models.UsagePercentiles{P50: 40, P95: 90, Max: 300},
+			Memory:   models.UsagePercentiles{P50: 100 << 20, P95: 150 << 20, Max: 200 << 20},
+			Headroom: 0.2,
+			Suggested: models.ResourceSizing{
+				CPURequest: "110m", CPULimit: "360m", MemoryRequest: "180Mi", MemoryLimit: "270Mi",
+			},
+		},
+	}
+
+	prompt := buildUserPrompt(info, nil)
+	for _, want := range []string{
+		"over 1h0m0s (120 samples): CPU p50 40m / p95 90m / max 300m, Memory p50 100Mi / p95 150Mi / max 200Mi",
+		"(p95 + 20% headroom): requests cpu=110m memory=180Mi, limits cpu=360m memory=270Mi",
+	} {
+		if !containsStr(prompt, want) {
+			t.Errorf("prompt should contain %q:\n%s", want, prompt)
+		}
+	}
+	if containsStr(prompt, "25.3%") {
+		t.Error("prompt should not contain the snapshot when usage was sampled")
+	}
+
+	stack := buildStackUserPrompt(StackContainerInfo{StackName: "s", Containers: []ContainerInfo{info}}, nil)
+	if !containsStr(stack, "requests cpu=110m memory=180Mi") || containsStr(stack, "25.3%") {
+		t.Errorf("stack prompt should contain the sampled usage instead of the snapshot:\n%s", stack)
+	}
+
+	// Fallback templates use the suggested resources
+	svc := &aiService{}
+	if d := svc.generateFallbackManifest(info).Deployment; !containsStr(d, `cpu: "110m"`) || !containsStr(d, `memory: "270Mi"`) {
+		t.Errorf("expected the suggested resources in the fallback Deployment:\n%s", d)
+	}
+}
+
 func TestParseManifestResponse_ValidJSON(t *testing.T) {
 	response := `{
 		"deployment": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: test",
@@ -268,7 +321,7 @@ func TestGenerateManifest_FallbackWhenNoAPIKey(t *testing.T) {
 		Provider: "openai",
 		APIKey:   "",
 		Model:    "gpt-4",
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("NewService failed: %v", err)
 	}
@@ -296,7 +349,7 @@ func TestGenerateManifest_FallbackWhenPlaceholderKey(t *testing.T) {
 		Provider: "openai",
 		APIKey:   "your-api-key-here",
 		Model:    "gpt-4",
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("NewService failed: %v", err)
 	}
@@ -341,13 +394,13 @@ func TestCallOpenAIAPI_Success(t *testing.T) {
 		apiKey:      "test-key",
 		temperature: 0.3,
 		maxTokens:   2000,
-		endpoint:    server.URL,
+		providers:   map[string]config.ProviderConfig{"openai": {BaseURL: server.URL}},
 		httpClient:  server.Client(),
 	}
 
-	response, err := svc.callOpenAIAPI(context.Background(), "system", "user")
+	response, err := svc.callProvider(context.Background(), nil, "system", "user", 0)
 	if err != nil {
-		t.Fatalf("callOpenAIAPI failed: %v", err)
+		t.Fatalf("callProvider failed: %v", err)
 	}
 	if response == "" {
 		t.Error("expected non-empty response")
@@ -364,11 +417,11 @@ func TestCallOpenAIAPI_ServerError(t *testing.T) {
 	svc := &aiService{
 		provider:   "openai",
 		apiKey:     "test-key",
-		endpoint:   server.URL,
+		providers:  map[string]config.ProviderConfig{"openai": {BaseURL: server.URL}},
 		httpClient: server.Client(),
 	}
 
-	_, err := svc.callOpenAIAPI(context.Background(), "system", "user")
+	_, err := svc.callProvider(context.Background(), nil, "system", "user", 0)
 	if err == nil {
 		t.Fatal("expected error for server error response")
 	}
@@ -398,13 +451,13 @@ func TestCallClaudeAPI_Success(t *testing.T) {
 		provider:   "claude",
 		model:      "claude-3-sonnet",
 		apiKey:     "test-key",
-		endpoint:   server.URL,
+		providers:  map[string]config.ProviderConfig{"claude": {BaseURL: server.URL}},
 		httpClient: server.Client(),
 	}
 
-	response, err := svc.callClaudeAPI(context.Background(), "system", "user")
+	response, err := svc.callProvider(context.Background(), nil, "system", "user", 0)
 	if err != nil {
-		t.Fatalf("callClaudeAPI failed: %v", err)
+		t.Fatalf("callProvider failed: %v", err)
 	}
 	if response == "" {
 		t.Error("expected non-empty response")
@@ -434,7 +487,7 @@ func TestGenerateManifest_WithMockOpenAI(t *testing.T) {
 		apiKey:      "real-key",
 		temperature: 0.3,
 		maxTokens:   2000,
-		endpoint:    server.URL,
+		providers:   map[string]config.ProviderConfig{"openai": {BaseURL: server.URL}},
 		httpClient:  server.Client(),
 	}
 
@@ -468,3 +521,616 @@ func containsSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestNewService_UnknownProvider(t *testing.T) {
+	if _, err := NewService(config.AIConfig{Provider: "bard"}, nil); err == nil {
+		t.Fatal("expected an error for an unregistered provider")
+	}
+	svc, err := NewService(config.AIConfig{}, nil)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	if got := svc.GetConfig()["provider"]; got != "openai" {
+		t.Errorf("expected openai as the default provider, got %v", got)
+	}
+}
+
+func TestProvider_OpenAICompatibleWithoutKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("expected no Authorization header without a key, got %q", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("X-Team") != "platform" {
+			t.Errorf("expected the configured header, got %q", r.Header.Get("X-Team"))
+		}
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			var req openAIRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Model != "llama3.1" || req.MaxTokens != 16384 {
+				t.Errorf("unexpected request %+v", req)
+			}
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"{\"deployment\":\"apiVersion: apps/v1\\nkind: Deployment\\nmetadata:\\n  name: web\",\"reasoning\":\"local\",\"confidence\":0.7}"}}]}`)
+		case "/v1/models":
+			fmt.Fprint(w, `{"data":[{"id":"qwen2.5"},{"id":"llama3.1"}]}`)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	svc, err := NewService(config.AIConfig{
+		Provider:  "ollama",
+		Model:     "llama3.1",
+		MaxTokens: 16384,
+		Providers: map[string]config.ProviderConfig{
+			"ollama": {BaseURL: server.URL + "/v1/", Headers: map[string]string{"X-Team": "platform"}},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	if cfg := svc.GetConfig(); cfg["configured"] != true || cfg["base_url"] != server.URL+"/v1" {
+		t.Errorf("expected a configured local provider, got %v", cfg)
+	}
+
+	result, err := svc.GenerateManifest(context.Background(), ContainerInfo{Name: "web", Image: "nginx"}, nil)
+	if err != nil {
+		t.Fatalf("GenerateManifest failed: %v", err)
+	}
+	if result.Reasoning != "local" {
+		t.Errorf("expected the local model's manifest, got reasoning %q", result.Reasoning)
+	}
+
+	// Compatible servers list every model, not only gpt-*
+	list, err := svc.ListModels(context.Background(), "", "")
+	if err != nil || len(list) != 2 || list[0] != "llama3.1" {
+		t.Errorf("unexpected models %v (err %v)", list, err)
+	}
+}
+
+func TestProvider_AzureDeploymentURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/prod-gpt4o/chat/completions" || r.URL.Query().Get("api-version") != "2024-10-21" {
+			t.Errorf("unexpected URL %s", r.URL)
+		}
+		if r.Header.Get("api-key") != "azure-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("expected api-key auth, got headers %v", r.Header)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["model"]; ok {
+			t.Error("Azure requests name the deployment in the URL, not the body")
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	svc := &aiService{
+		provider: "azure-openai",
+		model:    "gpt-4o",
+		apiKey:   "azure-key",
+		providers: map[string]config.ProviderConfig{
+			"azure-openai": {BaseURL: server.URL, Deployment: "prod-gpt4o", APIVersion: "2024-10-21"},
+		},
+		httpClient: server.Client(),
+	}
+	response, err := svc.callProvider(context.Background(), nil, "system", "user", 0)
+	if err != nil || response != "ok" {
+		t.Fatalf("expected ok, got %q (err %v)", response, err)
+	}
+
+	// Without a resource endpoint the provider cannot be built
+	svc.providers = nil
+	if _, err := svc.callProvider(context.Background(), nil, "system", "user", 0); err == nil {
+		t.Error("expected an error without an Azure base_url")
+	}
+}
+
+func TestProvider_Registry(t *testing.T) {
+	RegisterProvider("test-echo", config.ProviderConfig{AuthScheme: "none"}, false,
+		func(settings config.ProviderConfig, client *http.Client) (Provider, error) {
+			return echoProvider{}, nil
+		})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "test-echo")
+		registryMu.Unlock()
+	}()
+
+	if !HasProvider("test-echo") {
+		t.Fatal("expected the registered provider")
+	}
+	svc, err := NewService(config.AIConfig{Provider: "test-echo", Model: "m"}, nil)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	// auth_scheme none needs no key, so the provider is called instead of the fallback
+	result, err := svc.RefineManifest(context.Background(), &models.ManifestResult{Deployment: "d"}, "feedback")
+	if err != nil || result.Reasoning != "echo m" {
+		t.Errorf("expected the registered provider to answer, got %+v (err %v)", result, err)
+	}
+
+	if _, _, _, err := resolveProvider("openai", map[string]config.ProviderConfig{"openai": {AuthScheme: "basic"}}, nil); err == nil {
+		t.Error("expected an error for an unknown auth scheme")
+	}
+}
+
+type echoProvider struct{}
+
+func (echoProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	return &Completion{Text: fmt.Sprintf(`{"deployment":"apiVersion: apps/v1\nkind: Deployment","reasoning":"echo %s","confidence":1}`, req.Model)}, nil
+}
+func (echoProvider) ListModels(ctx context.Context, apiKey string) ([]string, error) {
+	return []string{"m"}, nil
+}
+
+func TestUpdateConfig_Concurrent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	svc := &aiService{
+		provider:   "openai",
+		apiKey:     "key",
+		providers:  map[string]config.ProviderConfig{"openai": {BaseURL: server.URL}, "vllm": {BaseURL: server.URL}},
+		httpClient: server.Client(),
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			provider := "openai"
+			if i%2 == 0 {
+				provider = "vllm"
+			}
+			svc.UpdateConfig(provider, fmt.Sprintf("key-%d", i), fmt.Sprintf("model-%d", i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			svc.GetConfig()
+			if _, err := svc.callProvider(context.Background(), nil, "system", "user", 0); err != nil {
+				t.Errorf("callProvider failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCacheKey_Normalization(t *testing.T) {
+	svc := &aiService{provider: "openai", model: "gpt-4o"}
+	info := ContainerInfo{Name: "web", Image: "nginx", ImageTag: "1.25", Ports: []int{443, 80},
+		EnvVars: map[string]string{"A": "1", "B": "2"}, CPUUsage: "3.0%"}
+
+	key, _, _ := svc.cacheKey("manifest", manifestCacheInputs(info, nil))
+
+	reordered := info
+	reordered.Ports = []int{80, 443}
+	reordered.EnvVars = map[string]string{"B": "2", "A": "1"}
+	reordered.CPUUsage = "57.2%"
+	if k, _, _ := svc.cacheKey("manifest", manifestCacheInputs(reordered, nil)); k != key {
+		t.Error("expected port order and live usage not to change the key")
+	}
+
+	changed := info
+	changed.ImageTag = "1.26"
+	if k, _, _ := svc.cacheKey("manifest", manifestCacheInputs(changed, nil)); k == key {
+		t.Error("expected a new image tag to change the key")
+	}
+
+	svc.UpdateConfig("", "", "gpt-4.1")
+	if k, _, model := svc.cacheKey("manifest", manifestCacheInputs(info, nil)); k == key || model != "gpt-4.1" {
+		t.Error("expected a model change to change the key")
+	}
+}
+
+// fewShotHistory returns a successful deploy whose stored Deployment exposes the
+// ports and reads the env keys.
+func fewShotHistory(id, image, tag string, ports []int, env ...string) models.DeploymentHistory {
+	var d strings.Builder
+	d.WriteString("apiVersion: apps/v1\nkind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n      - name: app\n")
+	if len(ports) > 0 {
+		d.WriteString("        ports:\n")
+		for _, p := range ports {
+			fmt.Fprintf(&d, "        - containerPort: %d\n", p)
+		}
+	}
+	if len(env) > 0 {
+		d.WriteString("        env:\n")
+		for _, k := range env {
+			fmt.Fprintf(&d, "        - name: %s\n", k)
+		}
+	}
+	manifests, _ := json.Marshal(models.ManifestResult{Deployment: d.String()})
+	return models.DeploymentHistory{
+		ID: id, ServiceName: id, ImageName: image, ImageTag: tag, Success: true, Status: "deployed",
+		ManifestJSON: string(manifests), DeployedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestFewShot_RanksBySimilarity(t *testing.T) {
+	svc := &aiService{fewShot: config.FewShotConfig{Enabled: true, MaxExamples: 2}}
+	info := ContainerInfo{Name: "web", Image: "nginx", ImageTag: "1.25.3-alpine", Ports: []int{80},
+		EnvVars: map[string]string{"SERVER_NAME": "x"}}
+
+	history := []models.DeploymentHistory{
+		fewShotHistory("redis", "redis", "7.2", []int{6379}),
+		fewShotHistory("nginx-other-minor", "library/nginx", "1.24-alpine", []int{80}, "SERVER_NAME"),
+		fewShotHistory("nginx-same-minor", "docker.io/nginx", "1.25.1-alpine", []int{80}, "SERVER_NAME"),
+		fewShotHistory("failed", "nginx", "1.25.3-alpine", []int{80}, "SERVER_NAME"),
+	}
+	history[3].Success = false
+
+	examples := svc.selectExamples([]ContainerInfo{info}, history)
+	if len(examples) != 2 {
+		t.Fatalf("expected MaxExamples to cap the examples at 2, got %d", len(examples))
+	}
+	if examples[0].History.ID != "nginx-same-minor" || examples[1].History.ID != "nginx-other-minor" {
+		t.Errorf("expected the same tag family first, got %s, %s", examples[0].History.ID, examples[1].History.ID)
+	}
+	if !strings.Contains(examples[0].Snippet, "containerPort: 80") {
+		t.Errorf("expected the stored Deployment as snippet, got %q", examples[0].Snippet)
+	}
+
+	svc.fewShot.SimilarityThreshold = 0.99
+	if got := svc.selectExamples([]ContainerInfo{info}, history); len(got) != 0 {
+		t.Errorf("expected the threshold to drop all examples, got %d", len(got))
+	}
+	svc.fewShot.Enabled = false
+	if got := svc.selectExamples([]ContainerInfo{info}, history); got != nil {
+		t.Error("expected no examples with few-shot disabled")
+	}
+}
+
+func TestFewShot_PrefersHealthyDeploys(t *testing.T) {
+	svc := &aiService{fewShot: config.FewShotConfig{Enabled: true}}
+	info := ContainerInfo{Name: "api", Image: "myorg/api", ImageTag: "2.1.0", Ports: []int{8080}}
+
+	oom := fewShotHistory("oom", "myorg/api", "2.1.0", []int{8080})
+	oom.OOMEvents = 3
+	oom.DeployedAt = oom.DeployedAt.Add(time.Hour)
+	healthy := fewShotHistory("healthy", "myorg/api", "2.0.0", []int{8080})
+	healthy.Usage = &models.DeploymentUsage{Samples: 60, From: healthy.DeployedAt, To: healthy.DeployedAt.Add(time.Hour),
+		CPU: models.UsagePercentiles{P50: 80, P95: 150, Max: 210}, Memory: models.UsagePercentiles{P50: 90 << 20, P95: 120 << 20, Max: 130 << 20}}
+
+	examples := svc.selectExamples([]ContainerInfo{info}, []models.DeploymentHistory{oom, healthy})
+	if len(examples) != 2 || examples[0].History.ID != "healthy" || examples[1].Healthy {
+		t.Fatalf("expected the healthy deploy ranked above the OOM-killed one, got %+v", examples)
+	}
+
+	prompt := buildUserPrompt(info, examples)
+	if !strings.Contains(prompt, "3 OOM kills") {
+		t.Error("expected the prompt to flag the unhealthy example")
+	}
+	if !strings.Contains(prompt, "CPU p50 80m / p95 150m / max 210m, Memory p50 90Mi / p95 120Mi / max 130Mi") {
+		t.Errorf("expected the measured percentiles in the prompt, got:\n%s", prompt)
+	}
+}
+
+func TestFewShot_TagFamily(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"1.25.3-alpine", "1.25.1-alpine", 0.9},
+		{"1.25.3-alpine", "1.24-alpine", 0.7},
+		{"1.25.3-alpine", "1.24", 0.5},
+		{"alpine", "alpine", 1},
+		{"v2-slim", "3-slim", 0.3},
+		{"1.25", "2.0", 0},
+	}
+	for _, tt := range tests {
+		if got, ok := tagSimilarity(tt.a, tt.b); !ok || got != tt.want {
+			t.Errorf("tagSimilarity(%q, %q) = %v, %v; want %v", tt.a, tt.b, got, ok, tt.want)
+		}
+	}
+	if _, ok := tagSimilarity("latest", "1.25"); ok {
+		t.Error("expected latest not to be compared")
+	}
+}
+
+// memoryStore is an in-memory Store for usage and cache tests.
+type memoryStore struct {
+	mu    sync.Mutex
+	usage []models.AIUsageRecord
+	cache map[string]models.AICacheEntry
+}
+
+func (m *memoryStore) SaveAICacheEntry(ctx context.Context, entry *models.AICacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cache == nil {
+		m.cache = map[string]models.AICacheEntry{}
+	}
+	m.cache[entry.Key] = *entry
+	return nil
+}
+func (m *memoryStore) GetAICacheEntry(ctx context.Context, key string) (*models.AICacheEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.cache[key]; ok {
+		return &e, nil
+	}
+	return nil, fmt.Errorf("not found")
+}
+func (m *memoryStore) CountAICacheEntries(ctx context.Context) (int, error) { return len(m.cache), nil }
+func (m *memoryStore) PurgeAICache(ctx context.Context) (int64, error)      { return 0, nil }
+func (m *memoryStore) SaveAIUsage(ctx context.Context, rec *models.AIUsageRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage = append(m.usage, *rec)
+	return nil
+}
+func (m *memoryStore) SumAIUsageCost(ctx context.Context, since time.Time) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total float64
+	for _, r := range m.usage {
+		if !r.CreatedAt.Before(since) {
+			total += r.CostUSD
+		}
+	}
+	return total, nil
+}
+
+func TestUsage_TokensFromEachProvider(t *testing.T) {
+	manifest := `{\"deployment\":\"apiVersion: apps/v1\\nkind: Deployment\",\"reasoning\":\"ok\",\"confidence\":0.9}`
+	tests := []struct {
+		provider   string
+		reply      string
+		prompt     int
+		completion int
+	}{
+		{"openai", `{"choices":[{"message":{"content":"` + manifest + `"}}],"usage":{"prompt_tokens":1200,"completion_tokens":300}}`, 1200, 300},
+		{"claude", `{"content":[{"text":"` + manifest + `"}],"usage":{"input_tokens":900,"output_tokens":250}}`, 900, 250},
+		{"gemini", `{"candidates":[{"content":{"parts":[{"text":"` + manifest + `"}]}}],"usageMetadata":{"promptTokenCount":800,"candidatesTokenCount":200,"thoughtsTokenCount":100}}`, 800, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.reply)
+			}))
+			defer server.Close()
+
+			store := &memoryStore{}
+			svc, err := NewService(config.AIConfig{
+				Provider:  tt.provider,
+				APIKey:    "key",
+				Model:     "model-x-2025",
+				Providers: map[string]config.ProviderConfig{tt.provider: {BaseURL: server.URL}},
+				Pricing:   map[string]config.ModelPrice{"model": {Input: 1, Output: 100}, "model-x": {Input: 2, Output: 10}},
+			}, store)
+			if err != nil {
+				t.Fatalf("NewService failed: %v", err)
+			}
+
+			ctx := WithDeploy(context.Background(), "d1", "alice")
+			if _, err := svc.GenerateManifest(ctx, ContainerInfo{Name: "web", Image: "nginx"}, nil); err != nil {
+				t.Fatalf("GenerateManifest failed: %v", err)
+			}
+			if len(store.usage) != 1 {
+				t.Fatalf("expected one usage record, got %d", len(store.usage))
+			}
+			rec := store.usage[0]
+			if rec.Operation != "generate" || rec.Provider != tt.provider || rec.Model != "model-x-2025" || rec.Outcome != models.AIOutcomeOK ||
+				rec.PromptTokens != tt.prompt || rec.CompletionTokens != tt.completion || rec.DeployID != "d1" || rec.User != "alice" {
+				t.Errorf("unexpected record %+v", rec)
+			}
+			// The longest matching prefix ("model-x") prices the call
+			if want := float64(tt.prompt*2+tt.completion*10) / 1e6; rec.CostUSD != want {
+				t.Errorf("expected cost %v, got %v", want, rec.CostUSD)
+			}
+		})
+	}
+}
+
+func TestUsage_OutcomesAndBudget(t *testing.T) {
+	var calls int
+	var reply string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, reply)
+	}))
+	defer server.Close()
+
+	store := &memoryStore{}
+	svc, err := NewService(config.AIConfig{
+		Provider:  "openai",
+		APIKey:    "key",
+		Model:     "gpt-4o",
+		Providers: map[string]config.ProviderConfig{"openai": {BaseURL: server.URL}},
+		Pricing:   map[string]config.ModelPrice{"gpt-4o": {Input: 1000, Output: 1000}},
+		Budget:    config.BudgetConfig{MonthlyUSD: 1},
+	}, store)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	ctx := context.Background()
+
+	// An unparsable reply falls back and is recorded as a parse error
+	reply = `{"choices":[{"message":{"content":"not json"}}],"usage":{"prompt_tokens":100,"completion_tokens":100}}`
+	result, _ := svc.GenerateManifest(ctx, ContainerInfo{Name: "web", Image: "nginx"}, nil)
+	if !strings.HasPrefix(result.Reasoning, "[Fallback]") || store.usage[0].Outcome != models.AIOutcomeParseError {
+		t.Fatalf("expected a recorded parse error, got %+v", store.usage)
+	}
+	if status := svc.BudgetStatus(ctx); status.SpentUSD != 0.2 || status.Exceeded {
+		t.Errorf("unexpected budget status %+v", status)
+	}
+
+	// Spending past the budget switches generation to templates without calling the LLM
+	reply = `{"choices":[{"message":{"content":"{\"deployment\":\"apiVersion: apps/v1\\nkind: Deployment\"}"}}],"usage":{"prompt_tokens":500,"completion_tokens":400}}`
+	if _, err := svc.RefineManifest(ctx, &models.ManifestResult{Deployment: "d"}, "more"); err != nil {
+		t.Fatalf("RefineManifest failed: %v", err)
+	}
+	if status := svc.BudgetStatus(ctx); !status.Exceeded {
+		t.Fatalf("expected the budget to be exceeded, got %+v", status)
+	}
+	before := calls
+	result, _ = svc.GenerateManifest(ctx, ContainerInfo{Name: "api", Image: "node"}, nil)
+	if calls != before || !strings.Contains(result.Reasoning, "예산") {
+		t.Errorf("expected a budget fallback without an LLM call, got %q (calls %d -> %d)", result.Reasoning, before, calls)
+	}
+	if _, err := svc.RefineManifest(ctx, &models.ManifestResult{Deployment: "d"}, "more"); err == nil {
+		t.Error("expected refine to fail once the budget is exceeded")
+	}
+	if len(store.usage) != 2 {
+		t.Errorf("expected only the two LLM calls to be recorded, got %d", len(store.usage))
+	}
+}
+
+func TestStream_EachProvider(t *testing.T) {
+	content := `{"deployment":"apiVersion: apps/v1\nkind: Deployment","reasoning":"2 replicas for \"web\"","confidence":0.9}`
+	var pieces []string
+	for rest := content; len(rest) > 0; {
+		n := min(len(rest), 7)
+		pieces = append(pieces, rest[:n])
+		rest = rest[n:]
+	}
+	event := func(name string, v interface{}) string {
+		b, _ := json.Marshal(v)
+		if name == "" {
+			return fmt.Sprintf("data: %s\n\n", b)
+		}
+		return fmt.Sprintf("event: %s\ndata: %s\n\n", name, b)
+	}
+
+	tests := []struct {
+		provider   string
+		path       string
+		body       func() string
+		prompt     int
+		completion int
+	}{
+		{"openai", "/chat/completions", func() string {
+			var b strings.Builder
+			for _, p := range pieces {
+				b.WriteString(event("", map[string]interface{}{"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"content": p}}}}))
+			}
+			b.WriteString(event("", map[string]interface{}{"choices": []interface{}{}, "usage": map[string]int{"prompt_tokens": 120, "completion_tokens": 30}}))
+			b.WriteString("data: [DONE]\n\n")
+			return b.String()
+		}, 120, 30},
+		{"claude", "/messages", func() string {
+			var b strings.Builder
+			b.WriteString(event("message_start", map[string]interface{}{"type": "message_start", "message": map[string]interface{}{"usage": map[string]int{"input_tokens": 90, "output_tokens": 1}}}))
+			b.WriteString(": ping\n\n")
+			for _, p := range pieces {
+				b.WriteString(event("content_block_delta", map[string]interface{}{"type": "content_block_delta", "delta": map[string]string{"type": "text_delta", "text": p}}))
+			}
+			b.WriteString(event("message_delta", map[string]interface{}{"type": "message_delta", "usage": map[string]int{"output_tokens": 25}}))
+			b.WriteString(event("message_stop", map[string]string{"type": "message_stop"}))
+			return b.String()
+		}, 90, 25},
+		{"gemini", "/models/model-x:streamGenerateContent", func() string {
+			var b strings.Builder
+			for i, p := range pieces {
+				chunk := map[string]interface{}{"candidates": []interface{}{map[string]interface{}{"content": map[string]interface{}{"parts": []interface{}{map[string]string{"text": p}}}}}}
+				if i == len(pieces)-1 {
+					chunk["usageMetadata"] = map[string]int{"promptTokenCount": 80, "candidatesTokenCount": 20, "thoughtsTokenCount": 5}
+				}
+				b.WriteString(event("", chunk))
+			}
+			return b.String()
+		}, 80, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				var req map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&req)
+				if tt.provider == "gemini" {
+					if r.URL.Query().Get("alt") != "sse" {
+						t.Errorf("expected alt=sse, got %q", r.URL.RawQuery)
+					}
+				} else if req["stream"] != true {
+					t.Errorf("expected a streaming request, got %v", req)
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, tt.body())
+			}))
+			defer server.Close()
+
+			store := &memoryStore{}
+			svc, err := NewService(config.AIConfig{
+				Provider:  tt.provider,
+				APIKey:    "key",
+				Model:     "model-x",
+				Providers: map[string]config.ProviderConfig{tt.provider: {BaseURL: server.URL}},
+			}, store)
+			if err != nil {
+				t.Fatalf("NewService failed: %v", err)
+			}
+
+			var events []models.GenerationEvent
+			ctx := WithProgress(WithDeploy(context.Background(), "d1", ""), func(ev models.GenerationEvent) {
+				events = append(events, ev)
+			})
+			result, err := svc.GenerateManifest(ctx, ContainerInfo{Name: "web", Image: "nginx"}, nil)
+			if err != nil {
+				t.Fatalf("GenerateManifest failed: %v", err)
+			}
+			if result.Reasoning != `2 replicas for "web"` || !strings.Contains(result.Deployment, "kind: Deployment") {
+				t.Errorf("unexpected result %+v", result)
+			}
+			if rec := store.usage[0]; rec.PromptTokens != tt.prompt || rec.CompletionTokens != tt.completion {
+				t.Errorf("expected %d/%d tokens, got %+v", tt.prompt, tt.completion, rec)
+			}
+
+			if len(events) < 3 {
+				t.Fatalf("expected started, progress and completed events, got %+v", events)
+			}
+			first, progress, last := events[0], events[len(events)-2], events[len(events)-1]
+			if first.Type != models.GenerationStarted || first.Attempt != 1 || first.Operation != "generate" || first.DeployID != "d1" {
+				t.Errorf("unexpected first event %+v", first)
+			}
+			if progress.Type != models.GenerationProgress || progress.Reasoning != `2 replicas for "web"` || progress.TokensReceived == 0 {
+				t.Errorf("unexpected progress event %+v", progress)
+			}
+			if last.Type != models.GenerationCompleted || last.TokensReceived != tt.completion {
+				t.Errorf("unexpected final event %+v", last)
+			}
+		})
+	}
+}
+
+func TestStream_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"message":"bad model"}}`)
+	}))
+	defer server.Close()
+
+	p, _, _, err := resolveProvider("claude", map[string]config.ProviderConfig{"claude": {BaseURL: server.URL}}, server.Client())
+	if err != nil {
+		t.Fatalf("resolveProvider failed: %v", err)
+	}
+	_, err = p.(StreamingProvider).Stream(context.Background(), CompletionRequest{Model: "m", APIKey: "k"}, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "bad model") {
+		t.Errorf("expected the status and body in the error, got %v", err)
+	}
+}
+
+func TestPartialJSONString(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`{"deployment":"x"`, ""},
+		{`{"reasoning": "Uses 2 rep`, "Uses 2 rep"},
+		{`{"reasoning":"a \"quoted\" word","confidence":0.9}`, `a "quoted" word`},
+		{`{"reasoning":"line\nbreak and \`, "line\nbreak and "},
+		{`{"reasoning":"unicode é and \u00`, "unicode é and "},
+		{"{\"reasoning\":\"raw\nline", "raw\nline"},
+		{`{"reasoning": 5}`, ""},
+	}
+	for _, tt := range tests {
+		if got := partialJSONString(tt.text, "reasoning"); got != tt.want {
+			t.Errorf("partialJSONString(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
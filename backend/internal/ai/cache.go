@@ -0,0 +1,225 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// promptVersion identifies the prompt templates. Bump it when a prompt changes so
+// responses generated by the old prompt are no longer served from the cache.
+const promptVersion = 4
+
+// defaultCacheTTL applies when the cache is enabled without a TTL.
+const defaultCacheTTL = time.Hour
+
+// ResponseCache persists generated manifests so identical requests skip the LLM
+// across restarts. data.Store implements it.
+type ResponseCache interface {
+	SaveAICacheEntry(ctx context.Context, entry *models.AICacheEntry) error
+	GetAICacheEntry(ctx context.Context, key string) (*models.AICacheEntry, error)
+	CountAICacheEntries(ctx context.Context) (int, error)
+	PurgeAICache(ctx context.Context) (int64, error)
+}
+
+type bypassCacheKey struct{}
+
+// WithoutCache returns a context whose generation requests skip the response cache:
+// they always call the LLM and replace the cached entry with the new result.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+func bypassesCache(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// cacheContainer is the part of ContainerInfo that reaches the prompt, in a stable
+// order. Live CPU and memory usage is left out: it changes with every sample and
+// would make every request a miss. The resources suggested from sampled usage are
+// rounded and change far less often, so they are kept.
+type cacheContainer struct {
+	Name     string                 `json:"name"`
+	Image    string                 `json:"image"`
+	ImageTag string                 `json:"image_tag"`
+	EnvVars  map[string]string      `json:"env_vars,omitempty"` // encoding/json sorts the keys
+	Ports    []int                  `json:"ports,omitempty"`
+	Volumes  []string               `json:"volumes,omitempty"`
+	Command  []string               `json:"command,omitempty"`
+	Sizing   *models.ResourceSizing `json:"sizing,omitempty"`
+}
+
+type cacheHistory struct {
+	ID            string `json:"id"`
+	ServiceName   string `json:"service_name"`
+	Image         string `json:"image"`
+	CPURequest    string `json:"cpu_request"`
+	CPULimit      string `json:"cpu_limit"`
+	MemoryRequest string `json:"memory_request"`
+	MemoryLimit   string `json:"memory_limit"`
+	Replicas      int    `json:"replicas"`
+	Success       bool   `json:"success"`
+}
+
+func normalizeContainer(info ContainerInfo) cacheContainer {
+	c := cacheContainer{
+		Name:     strings.TrimSpace(info.Name),
+		Image:    strings.TrimSpace(info.Image),
+		ImageTag: strings.TrimSpace(info.ImageTag),
+		EnvVars:  info.EnvVars,
+		Command:  info.Command,
+	}
+	if info.Usage != nil {
+		c.Sizing = &info.Usage.Suggested
+	}
+	if len(info.Ports) > 0 {
+		c.Ports = append([]int(nil), info.Ports...)
+		sort.Ints(c.Ports)
+	}
+	if len(info.Volumes) > 0 {
+		c.Volumes = append([]string(nil), info.Volumes...)
+		sort.Strings(c.Volumes)
+	}
+	return c
+}
+
+// normalizeHistory keeps the few-shot examples the prompts include.
+func normalizeHistory(history []models.DeploymentHistory) []cacheHistory {
+	var out []cacheHistory
+	for _, h := range history {
+		out = append(out, cacheHistory{
+			ID:            h.ID,
+			ServiceName:   h.ServiceName,
+			Image:         h.ImageName + ":" + h.ImageTag,
+			CPURequest:    h.CPURequest,
+			CPULimit:      h.CPULimit,
+			MemoryRequest: h.MemoryRequest,
+			MemoryLimit:   h.MemoryLimit,
+			Replicas:      h.Replicas,
+			Success:       h.Success,
+		})
+	}
+	return out
+}
+
+// cacheKey hashes the provider, model, prompt version and normalized inputs of a
+// generation request.
+func (s *aiService) cacheKey(kind string, inputs interface{}) (key, provider, model string) {
+	s.mu.RLock()
+	provider, model = s.provider, s.model
+	s.mu.RUnlock()
+
+	b, _ := json.Marshal(struct {
+		Kind          string      `json:"kind"`
+		Provider      string      `json:"provider"`
+		Model         string      `json:"model"`
+		PromptVersion int         `json:"prompt_version"`
+		Inputs        interface{} `json:"inputs"`
+	}{kind, provider, model, promptVersion, inputs})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), provider, model
+}
+
+func manifestCacheInputs(info ContainerInfo, history []models.DeploymentHistory) interface{} {
+	return struct {
+		Container cacheContainer `json:"container"`
+		History   []cacheHistory `json:"history,omitempty"`
+	}{normalizeContainer(info), normalizeHistory(history)}
+}
+
+func stackCacheInputs(info StackContainerInfo, history []models.DeploymentHistory) interface{} {
+	containers := make([]cacheContainer, len(info.Containers))
+	for i, c := range info.Containers {
+		containers[i] = normalizeContainer(c)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	return struct {
+		StackName  string           `json:"stack_name"`
+		Namespace  string           `json:"namespace"`
+		UserPrompt string           `json:"user_prompt,omitempty"`
+		Containers []cacheContainer `json:"containers"`
+		History    []cacheHistory   `json:"history,omitempty"`
+	}{strings.TrimSpace(info.StackName), info.Namespace, strings.TrimSpace(info.UserPrompt), containers, normalizeHistory(history)}
+}
+
+// lookupCache decodes the cached result for key into out. It reports false when the
+// cache is off, the request bypasses it, or there is no unexpired entry.
+func (s *aiService) lookupCache(ctx context.Context, key string, out interface{}) bool {
+	if s.store == nil || !s.cacheEnabled {
+		return false
+	}
+	if bypassesCache(ctx) {
+		s.cacheBypassed.Add(1)
+		return false
+	}
+	entry, err := s.store.GetAICacheEntry(ctx, key)
+	if err == nil {
+		if err = json.Unmarshal([]byte(entry.ResultJSON), out); err == nil {
+			s.cacheHits.Add(1)
+			return true
+		}
+		slog.Warn("failed to decode cached AI response", "key", key, "error", err)
+	}
+	s.cacheMisses.Add(1)
+	return false
+}
+
+// storeCache saves a generated result under key. Fallback templates are never stored.
+func (s *aiService) storeCache(ctx context.Context, kind, key, provider, model string, result interface{}) {
+	if s.store == nil || !s.cacheEnabled {
+		return
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	entry := &models.AICacheEntry{
+		Key:        key,
+		Kind:       kind,
+		Provider:   provider,
+		Model:      model,
+		ResultJSON: string(b),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(s.cacheTTL),
+	}
+	if err := s.store.SaveAICacheEntry(ctx, entry); err != nil {
+		slog.Warn("failed to save AI response to cache", "kind", kind, "error", err)
+	}
+}
+
+// CacheStats reports the cache counters since startup and the number of live entries.
+func (s *aiService) CacheStats(ctx context.Context) models.AICacheStats {
+	stats := models.AICacheStats{
+		Enabled:  s.store != nil && s.cacheEnabled,
+		TTL:      s.cacheTTL.String(),
+		Hits:     s.cacheHits.Load(),
+		Misses:   s.cacheMisses.Load(),
+		Bypassed: s.cacheBypassed.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	if s.store != nil {
+		if n, err := s.store.CountAICacheEntries(ctx); err == nil {
+			stats.Entries = n
+		}
+	}
+	return stats
+}
+
+// PurgeCache deletes every cached response and returns how many were removed.
+func (s *aiService) PurgeCache(ctx context.Context) (int64, error) {
+	if s.store == nil {
+		return 0, nil
+	}
+	return s.store.PurgeAICache(ctx)
+}
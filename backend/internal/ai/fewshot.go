@@ -0,0 +1,499 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// defaultMaxExamples applies when few_shot.max_examples is not set.
+	defaultMaxExamples = 3
+	// unhealthyPenalty scales the similarity of deploys that had OOM kills or CPU
+	// throttling, so healthy deploys of the same kind are preferred.
+	unhealthyPenalty = 0.8
+	// fewShotSnippetLines bounds the Deployment YAML shown for one example.
+	fewShotSnippetLines = 40
+)
+
+// Weights of the similarity signals. Signals one side has no data for are left out
+// and the rest are renormalized.
+var similarityWeights = []struct {
+	signal string
+	weight float64
+}{
+	{"image", 0.30},
+	{"tag", 0.10},
+	{"service_type", 0.15},
+	{"language", 0.10},
+	{"ports", 0.15},
+	{"env", 0.10},
+	{"resources", 0.10},
+}
+
+// fewShotExample is a past deploy chosen as an example for a generation.
+type fewShotExample struct {
+	History models.DeploymentHistory
+	Score   float64 // similarity, 0-1, after the health penalty
+	Healthy bool    // no OOM or throttle events
+	Snippet string  // Deployment YAML of the stored manifests, possibly cut
+}
+
+// storedWorkload is what the scorer reads from the stored manifests of a deploy.
+type storedWorkload struct {
+	deployment string
+	ports      []int
+	envKeys    []string
+}
+
+// selectExamples ranks past deploys by their similarity to the containers and returns
+// the best ones at or above the threshold, up to MaxExamples. A candidate's score is
+// its best match among the containers. Nothing is returned when few-shot is off.
+func (s *aiService) selectExamples(containers []ContainerInfo, history []models.DeploymentHistory) []fewShotExample {
+	s.mu.RLock()
+	cfg := s.fewShot
+	s.mu.RUnlock()
+	if !cfg.Enabled || len(containers) == 0 {
+		return nil
+	}
+	limit := cfg.MaxExamples
+	if limit <= 0 {
+		limit = defaultMaxExamples
+	}
+
+	var ranked []fewShotExample
+	seen := map[string]bool{}
+	for _, h := range history {
+		// Multi-cluster parents repeat the manifests of their children
+		if !h.Success || h.Status == "failed" || h.FanOut != nil {
+			continue
+		}
+		w := parseStoredWorkload(h.ManifestJSON)
+		best := 0.0
+		for _, c := range containers {
+			best = math.Max(best, similarity(c, h, w))
+		}
+		ex := fewShotExample{
+			History: h,
+			Score:   best,
+			Healthy: h.OOMEvents == 0 && h.ThrottleEvents == 0,
+			Snippet: snippet(w.deployment, fewShotSnippetLines),
+		}
+		if !ex.Healthy {
+			ex.Score *= unhealthyPenalty
+		}
+		ex.Score = math.Round(ex.Score*100) / 100
+		if ex.Score < cfg.SimilarityThreshold || ex.Score == 0 {
+			continue
+		}
+		ranked = append(ranked, ex)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Healthy != b.Healthy {
+			return a.Healthy
+		}
+		return a.History.DeployedAt.After(b.History.DeployedAt)
+	})
+
+	// Redeploys of one service add nothing over its best example
+	var examples []fewShotExample
+	for _, ex := range ranked {
+		key := ex.History.ServiceName + "|" + ex.History.ImageName + ":" + ex.History.ImageTag
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		examples = append(examples, ex)
+		if len(examples) == limit {
+			break
+		}
+	}
+	return examples
+}
+
+// logExamples records which deploys a generation was given as examples.
+func logExamples(ctx context.Context, operation string, candidates int, examples []fewShotExample) {
+	if len(examples) == 0 {
+		return
+	}
+	used := make([]string, len(examples))
+	for i, ex := range examples {
+		used[i] = fmt.Sprintf("%s(%s:%s, %.2f)", ex.History.ID, ex.History.ImageName, ex.History.ImageTag, ex.Score)
+	}
+	var deployID string
+	if tag, ok := ctx.Value(usageTagKey{}).(usageTag); ok {
+		deployID = tag.deployID
+	}
+	slog.Info("few-shot examples selected", "operation", operation, "deploy_id", deployID,
+		"candidates", candidates, "examples", strings.Join(used, ", "))
+}
+
+// exampleHistory returns the deploys behind the examples.
+func exampleHistory(examples []fewShotExample) []models.DeploymentHistory {
+	out := make([]models.DeploymentHistory, len(examples))
+	for i, ex := range examples {
+		out[i] = ex.History
+	}
+	return out
+}
+
+// similarity scores how alike a container and a past deploy are, from 0 to 1.
+func similarity(c ContainerInfo, h models.DeploymentHistory, w storedWorkload) float64 {
+	signals := map[string]float64{}
+	signals["image"] = imageSimilarity(c.Image, h.ImageName)
+	if v, ok := tagSimilarity(c.ImageTag, h.ImageTag); ok {
+		signals["tag"] = v
+	}
+	if h.ServiceType != "" {
+		signals["service_type"] = boolScore(detectServiceType(c) == h.ServiceType)
+	}
+	if lang := detectLanguage(c); lang != "unknown" && h.Language != "" && h.Language != "unknown" {
+		signals["language"] = boolScore(lang == h.Language)
+	}
+	if len(c.Ports) > 0 && len(w.ports) > 0 {
+		a := make([]string, len(c.Ports))
+		for i, p := range c.Ports {
+			a[i] = strconv.Itoa(p)
+		}
+		b := make([]string, len(w.ports))
+		for i, p := range w.ports {
+			b[i] = strconv.Itoa(p)
+		}
+		signals["ports"] = jaccard(a, b)
+	}
+	if len(c.EnvVars) > 0 && len(w.envKeys) > 0 {
+		keys := make([]string, 0, len(c.EnvVars))
+		for k := range c.EnvVars {
+			keys = append(keys, k)
+		}
+		signals["env"] = jaccard(keys, w.envKeys)
+	}
+	if v, ok := usageSimilarity(c, h); ok {
+		signals["resources"] = v
+	}
+
+	var sum, weights float64
+	for _, w := range similarityWeights {
+		if v, ok := signals[w.signal]; ok {
+			sum += w.weight * v
+			weights += w.weight
+		}
+	}
+	if weights == 0 {
+		return 0
+	}
+	return sum / weights
+}
+
+func boolScore(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// imageRepo returns the repository of an image without its registry host and the
+// Docker Hub "library/" prefix, lowercased.
+func imageRepo(image string) string {
+	image = strings.ToLower(strings.TrimSpace(image))
+	if i := strings.Index(image, "/"); i > 0 {
+		host := image[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			image = image[i+1:]
+		}
+	}
+	return strings.TrimPrefix(image, "library/")
+}
+
+// imageSimilarity compares two image names: the same repository, the same name under
+// another namespace, a name containing the other (nginx, nginx-proxy) or a shared word.
+func imageSimilarity(a, b string) float64 {
+	a, b = imageRepo(a), imageRepo(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	baseA, baseB := a[strings.LastIndex(a, "/")+1:], b[strings.LastIndex(b, "/")+1:]
+	switch {
+	case baseA == baseB:
+		return 0.8
+	case strings.Contains(baseA, baseB) || strings.Contains(baseB, baseA):
+		return 0.5
+	}
+	split := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	}
+	if jaccard(split(baseA), split(baseB)) > 0 {
+		return 0.3
+	}
+	return 0
+}
+
+var tagVersion = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?`)
+
+// tagFamily splits a tag like "1.25.3-alpine" into its major ("1") and minor ("1.25")
+// version and its variant ("alpine"). Tags without a version only have a variant.
+func tagFamily(tag string) (major, minor, variant string) {
+	tag = strings.ToLower(tag)
+	if m := tagVersion.FindStringSubmatch(tag); m != nil {
+		major = m[1]
+		if m[2] != "" {
+			minor = m[1] + "." + m[2]
+		}
+		tag = tag[len(m[0]):]
+		// Drop the patch version and the separator of the variant
+		tag = strings.TrimLeft(strings.TrimLeft(tag, ".0123456789"), "-_")
+	}
+	return major, minor, tag
+}
+
+// tagSimilarity compares image tags by version family and variant. Tags that say
+// nothing about the version (none, latest) are not compared.
+func tagSimilarity(a, b string) (float64, bool) {
+	if a == "" || b == "" || a == "latest" || b == "latest" {
+		return 0, false
+	}
+	if a == b {
+		return 1, true
+	}
+	majorA, minorA, variantA := tagFamily(a)
+	majorB, minorB, variantB := tagFamily(b)
+	sameVariant := variantA == variantB
+	switch {
+	case minorA != "" && minorA == minorB && sameVariant:
+		return 0.9, true
+	case majorA != "" && majorA == majorB && sameVariant:
+		return 0.7, true
+	case majorA != "" && majorA == majorB:
+		return 0.5, true
+	case sameVariant && variantA != "":
+		return 0.3, true
+	}
+	return 0, true
+}
+
+// usageSimilarity compares the container's CPU and memory usage (the sampled p95, or
+// the current snapshot without samples) with the usage measured for the past deploy,
+// as the ratio of the smaller to the larger.
+func usageSimilarity(c ContainerInfo, h models.DeploymentHistory) (float64, bool) {
+	cpu, cpuOK := dockerCPUMillis(c.CPUUsage)
+	var mem float64
+	memQ, err := resource.ParseQuantity(c.MemoryUsage)
+	memOK := err == nil
+	if memOK {
+		mem = float64(memQ.Value())
+	}
+	if c.Usage != nil {
+		cpu, cpuOK = float64(c.Usage.CPU.P95), true
+		mem, memOK = float64(c.Usage.Memory.P95), true
+	}
+
+	var ratios []float64
+	if cpuOK {
+		if q, err := resource.ParseQuantity(h.ActualCPU); err == nil {
+			ratios = append(ratios, ratio(cpu, float64(q.MilliValue())))
+		}
+	}
+	if memOK {
+		if q, err := resource.ParseQuantity(h.ActualMemory); err == nil {
+			ratios = append(ratios, ratio(mem, float64(q.Value())))
+		}
+	}
+	if len(ratios) == 0 {
+		return 0, false
+	}
+	sum := 0.0
+	for _, r := range ratios {
+		sum += r
+	}
+	return sum / float64(len(ratios)), true
+}
+
+// dockerCPUMillis converts Docker's CPU percentage (100% = one core) to millicores.
+func dockerCPUMillis(usage string) (float64, bool) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(usage), "%"), 64)
+	if err != nil || !strings.HasSuffix(usage, "%") {
+		return 0, false
+	}
+	return pct * 10, true
+}
+
+func ratio(a, b float64) float64 {
+	if a <= 0 && b <= 0 {
+		return 1
+	}
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+	return math.Min(a, b) / math.Max(a, b)
+}
+
+// jaccard is the size of the intersection of two sets over the size of their union.
+func jaccard(a, b []string) float64 {
+	setA := make(map[string]bool, len(a))
+	for _, v := range a {
+		setA[v] = true
+	}
+	setB := make(map[string]bool, len(b))
+	for _, v := range b {
+		setB[v] = true
+	}
+	inter := 0
+	for v := range setA {
+		if setB[v] {
+			inter++
+		}
+	}
+	union := len(setA) + len(setB) - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
+
+// parseStoredWorkload reads the Deployment of a deploy's stored manifests
+// (models.ManifestResult), with the ports and environment keys of its containers
+// and the keys of its ConfigMap.
+func parseStoredWorkload(manifestJSON string) storedWorkload {
+	var w storedWorkload
+	var m models.ManifestResult
+	if manifestJSON == "" || json.Unmarshal([]byte(manifestJSON), &m) != nil {
+		return w
+	}
+	w.deployment = strings.TrimSpace(m.Deployment)
+
+	var deployment struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					Containers []struct {
+						Ports []struct {
+							ContainerPort int `yaml:"containerPort"`
+						} `yaml:"ports"`
+						Env []struct {
+							Name string `yaml:"name"`
+						} `yaml:"env"`
+					} `yaml:"containers"`
+				} `yaml:"spec"`
+			} `yaml:"template"`
+		} `yaml:"spec"`
+	}
+	if yaml.Unmarshal([]byte(m.Deployment), &deployment) == nil {
+		for _, c := range deployment.Spec.Template.Spec.Containers {
+			for _, p := range c.Ports {
+				w.ports = append(w.ports, p.ContainerPort)
+			}
+			for _, e := range c.Env {
+				w.envKeys = append(w.envKeys, e.Name)
+			}
+		}
+	}
+	var configMap struct {
+		Data map[string]string `yaml:"data"`
+	}
+	if yaml.Unmarshal([]byte(m.ConfigMap), &configMap) == nil {
+		for k := range configMap.Data {
+			w.envKeys = append(w.envKeys, k)
+		}
+	}
+	return w
+}
+
+// snippet returns the first lines of text, marking the cut.
+func snippet(text string, lines int) string {
+	parts := strings.SplitN(text, "\n", lines+1)
+	if len(parts) <= lines {
+		return text
+	}
+	return strings.Join(parts[:lines], "\n") + "\n# ..."
+}
+
+// writeExamples writes the few-shot examples section of a prompt.
+func writeExamples(b *strings.Builder, examples []fewShotExample) {
+	if len(examples) == 0 {
+		return
+	}
+	b.WriteString("## Similar Deployment History (few-shot examples of successful deploys)\n")
+	b.WriteString("Size resources from the usage measured after these deploys; give more headroom than examples that were OOM-killed or throttled.\n")
+	for i, ex := range examples {
+		h := ex.History
+		fmt.Fprintf(b, "### Example %d: %s (%s:%s), similarity %.2f\n", i+1, h.ServiceName, h.ImageName, h.ImageTag, ex.Score)
+		fmt.Fprintf(b, "- Type: %s, Language: %s, CPU: %s/%s, Memory: %s/%s, Replicas: %d\n",
+			h.ServiceType, h.Language, h.CPURequest, h.CPULimit, h.MemoryRequest, h.MemoryLimit, h.Replicas)
+		if u := h.Usage; u != nil && u.Samples > 0 {
+			fmt.Fprintf(b, "- Measured usage per pod over %s (%d samples): CPU p50 %dm / p95 %dm / max %dm, Memory p50 %s / p95 %s / max %s\n",
+				u.To.Sub(u.From).Round(time.Minute), u.Samples, u.CPU.P50, u.CPU.P95, u.CPU.Max,
+				mebibytes(u.Memory.P50), mebibytes(u.Memory.P95), mebibytes(u.Memory.Max))
+		} else if h.ActualCPU != "" || h.ActualMemory != "" {
+			fmt.Fprintf(b, "- Measured usage: CPU %s, Memory %s\n", h.ActualCPU, h.ActualMemory)
+		}
+		switch {
+		case ex.Healthy && h.Usage == nil:
+			b.WriteString("- Health: not measured after deploy\n")
+		case ex.Healthy:
+			b.WriteString("- Health: no OOM kills or CPU throttling\n")
+		default:
+			fmt.Fprintf(b, "- Health: %d OOM kills, %d throttle events (size resources above this example)\n", h.OOMEvents, h.ThrottleEvents)
+		}
+		if ex.Snippet != "" {
+			fmt.Fprintf(b, "```yaml\n%s\n```\n", ex.Snippet)
+		}
+	}
+	b.WriteString("\n")
+}
+
+// writeUsage writes the sampled usage of a container and the requests and limits
+// derived from it.
+func writeUsage(b *strings.Builder, u *models.UsageProfile) {
+	fmt.Fprintf(b, "- Measured Usage over %s (%d samples): CPU p50 %dm / p95 %dm / max %dm, Memory p50 %s / p95 %s / max %s\n",
+		u.To.Sub(u.From).Round(time.Minute), u.Samples, u.CPU.P50, u.CPU.P95, u.CPU.Max,
+		mebibytes(u.Memory.P50), mebibytes(u.Memory.P95), mebibytes(u.Memory.Max))
+	s := u.Suggested
+	fmt.Fprintf(b, "- Suggested Resources (p95 + %.0f%% headroom): requests cpu=%s memory=%s, limits cpu=%s memory=%s\n",
+		u.Headroom*100, s.CPURequest, s.MemoryRequest, s.CPULimit, s.MemoryLimit)
+}
+
+// mebibytes formats a byte count in whole MiB, rounded up.
+func mebibytes(bytes int64) string {
+	return fmt.Sprintf("%dMi", int64(math.Ceil(float64(bytes)/(1<<20))))
+}
+
+func detectLanguage(info ContainerInfo) string {
+	image := strings.ToLower(info.Image)
+	switch {
+	case strings.Contains(image, "node"):
+		return "javascript"
+	case strings.Contains(image, "python"):
+		return "python"
+	case strings.Contains(image, "golang") || strings.Contains(image, "go"):
+		return "go"
+	case strings.Contains(image, "java") || strings.Contains(image, "openjdk"):
+		return "java"
+	case strings.Contains(image, "ruby"):
+		return "ruby"
+	case strings.Contains(image, "php"):
+		return "php"
+	case strings.Contains(image, "dotnet") || strings.Contains(image, "aspnet"):
+		return "csharp"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// ProgressFunc receives the progress events of a generation. It is called from the
+// generating goroutine and must not block.
+type ProgressFunc func(models.GenerationEvent)
+
+type progressKey struct{}
+
+// WithProgress returns a context whose generation requests report their progress to
+// fn. Replies are streamed from providers that support it.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func progressFunc(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// progressInterval limits how often streamed text is reported.
+const progressInterval = 250 * time.Millisecond
+
+// emitProgress reports an event of the call, if anyone listens.
+func emitProgress(ctx context.Context, call *aiCall, ev models.GenerationEvent) {
+	fn := progressFunc(ctx)
+	if fn == nil {
+		return
+	}
+	if call != nil {
+		ev.Operation = call.operation
+	}
+	if tag, ok := ctx.Value(usageTagKey{}).(usageTag); ok {
+		ev.DeployID = tag.deployID
+	}
+	ev.Timestamp = time.Now()
+	fn(ev)
+}
+
+// finishEvent is the final event of a call with the outcome recorded for it.
+// Generation falls back on failure while refinement returns the error.
+func finishEvent(call *aiCall, outcome string, callErr error) models.GenerationEvent {
+	ev := models.GenerationEvent{Type: models.GenerationCompleted, TokensReceived: call.completionTokens}
+	switch {
+	case outcome == models.AIOutcomeFallback:
+		ev.Type = models.GenerationFallback
+	case outcome == models.AIOutcomeParseError && strings.HasPrefix(call.operation, "generate"):
+		ev.Type = models.GenerationFallback
+	case outcome != models.AIOutcomeOK:
+		ev.Type = models.GenerationFailed
+	}
+	if callErr != nil {
+		ev.Message = truncate(callErr.Error(), 500)
+	}
+	return ev
+}
+
+// streamProgress accumulates the streamed reply of one attempt and reports it at
+// most every progressInterval.
+type streamProgress struct {
+	ctx     context.Context
+	call    *aiCall
+	attempt int
+
+	mu       sync.Mutex
+	text     strings.Builder
+	lastSent time.Time
+}
+
+func (p *streamProgress) onText(piece string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.text.WriteString(piece)
+	if time.Since(p.lastSent) < progressInterval {
+		return
+	}
+	p.lastSent = time.Now()
+	p.emitLocked()
+}
+
+// flush reports the text not yet reported.
+func (p *streamProgress) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.emitLocked()
+}
+
+func (p *streamProgress) emitLocked() {
+	text := p.text.String()
+	emitProgress(p.ctx, p.call, models.GenerationEvent{
+		Type:           models.GenerationProgress,
+		Attempt:        p.attempt,
+		TokensReceived: estimateTokens(text),
+		Reasoning:      partialJSONString(text, "reasoning"),
+	})
+}
+
+// estimateTokens approximates the token count of streamed text (about four bytes per
+// token) until the provider reports the real count.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// partialJSONString returns the value of a top-level string field from JSON that may
+// still be incomplete, decoding as much of the value as has arrived.
+func partialJSONString(text, key string) string {
+	i := strings.Index(text, `"`+key+`"`)
+	if i < 0 {
+		return ""
+	}
+	rest := strings.TrimLeft(text[i+len(key)+2:], " \t\r\n")
+	if !strings.HasPrefix(rest, ":") {
+		return ""
+	}
+	rest = strings.TrimLeft(rest[1:], " \t\r\n")
+	if !strings.HasPrefix(rest, `"`) {
+		return ""
+	}
+	rest = rest[1:]
+
+	// Find the closing quote, or cut an escape sequence that is still incomplete
+	end := len(rest)
+	for j := 0; j < len(rest); j++ {
+		if rest[j] == '\\' {
+			n := 2
+			if j+1 < len(rest) && rest[j+1] == 'u' {
+				n = 6
+			}
+			if j+n > len(rest) {
+				end = j
+				break
+			}
+			j += n - 1
+			continue
+		}
+		if rest[j] == '"' {
+			end = j
+			break
+		}
+	}
+
+	// Models sometimes leave raw line breaks in strings; tryRepairJSON fixes the full
+	// reply, this only the part shown
+	raw := strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(rest[:end])
+	var value string
+	if err := json.Unmarshal([]byte(`"`+raw+`"`), &value); err != nil {
+		return ""
+	}
+	return value
+}
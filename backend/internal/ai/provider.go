@@ -0,0 +1,667 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/config"
+)
+
+// CompletionRequest is one system + user prompt sent to a provider.
+type CompletionRequest struct {
+	Model        string
+	APIKey       string // empty for providers that run without a key
+	SystemPrompt string
+	UserPrompt   string
+	Temperature  float64
+	MaxTokens    int
+}
+
+// Completion is a provider's reply and the tokens its usage block reported (zero
+// when the provider reports none).
+type Completion struct {
+	Text             string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Provider is one LLM API the service generates manifests with.
+type Provider interface {
+	// Complete sends the prompt and returns the reply.
+	Complete(ctx context.Context, req CompletionRequest) (*Completion, error)
+	// ListModels returns the chat models the provider offers.
+	ListModels(ctx context.Context, apiKey string) ([]string, error)
+}
+
+// ProviderFactory builds a provider from its resolved settings. The client already
+// carries the configured timeout.
+type ProviderFactory func(settings config.ProviderConfig, client *http.Client) (Provider, error)
+
+type providerRegistration struct {
+	defaults    config.ProviderConfig
+	keyOptional bool
+	factory     ProviderFactory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]providerRegistration{}
+)
+
+// RegisterProvider adds or replaces a provider. defaults fill the settings the
+// configuration leaves empty; keyOptional providers run without an API key.
+func RegisterProvider(name string, defaults config.ProviderConfig, keyOptional bool, factory ProviderFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = providerRegistration{defaults: defaults, keyOptional: keyOptional, factory: factory}
+}
+
+// Providers returns the names of the registered providers, sorted.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasProvider reports whether a provider is registered under name.
+func HasProvider(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[name]
+	return ok
+}
+
+func init() {
+	compatible := func(settings config.ProviderConfig, client *http.Client) (Provider, error) {
+		return newOpenAIProvider(settings, client, "")
+	}
+
+	RegisterProvider("openai", config.ProviderConfig{BaseURL: "https://api.openai.com/v1", AuthScheme: "bearer"}, false,
+		func(settings config.ProviderConfig, client *http.Client) (Provider, error) {
+			return newOpenAIProvider(settings, client, "gpt-")
+		})
+	RegisterProvider("claude", config.ProviderConfig{
+		BaseURL:    "https://api.anthropic.com/v1",
+		AuthScheme: "x-api-key",
+		Headers:    map[string]string{"anthropic-version": "2023-06-01"},
+	}, false, newClaudeProvider)
+	RegisterProvider("gemini", config.ProviderConfig{BaseURL: "https://generativelanguage.googleapis.com/v1beta", AuthScheme: "query"}, false, newGeminiProvider)
+	RegisterProvider("azure-openai", config.ProviderConfig{AuthScheme: "api-key", APIVersion: "2024-06-01"}, false, newAzureOpenAIProvider)
+
+	// Self-hosted servers speaking the OpenAI chat completions API
+	RegisterProvider("openai-compatible", config.ProviderConfig{AuthScheme: "bearer"}, true, compatible)
+	RegisterProvider("ollama", config.ProviderConfig{BaseURL: "http://localhost:11434/v1", AuthScheme: "bearer"}, true, compatible)
+	RegisterProvider("vllm", config.ProviderConfig{BaseURL: "http://localhost:8000/v1", AuthScheme: "bearer"}, true, compatible)
+	RegisterProvider("lmstudio", config.ProviderConfig{BaseURL: "http://localhost:1234/v1", AuthScheme: "bearer"}, true, compatible)
+}
+
+// resolveProvider builds the named provider from its defaults overlaid with the
+// configured overrides. It also reports whether the provider runs without a key.
+func resolveProvider(name string, overrides map[string]config.ProviderConfig, base *http.Client) (Provider, config.ProviderConfig, bool, error) {
+	registryMu.RLock()
+	reg, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, config.ProviderConfig{}, false, fmt.Errorf("unsupported provider: %s", name)
+	}
+
+	settings := mergeProviderConfig(reg.defaults, overrides[name])
+	switch settings.AuthScheme {
+	case "bearer", "api-key", "x-api-key", "query", "none":
+	default:
+		return nil, settings, false, fmt.Errorf("provider %s: unsupported auth scheme %q", name, settings.AuthScheme)
+	}
+
+	client := &http.Client{}
+	if base != nil {
+		*client = *base
+	}
+	if settings.Timeout > 0 {
+		client.Timeout = settings.Timeout
+	}
+	p, err := reg.factory(settings, client)
+	if err != nil {
+		return nil, settings, false, fmt.Errorf("provider %s: %w", name, err)
+	}
+	return p, settings, reg.keyOptional || settings.AuthScheme == "none", nil
+}
+
+// mergeProviderConfig overlays the non-empty fields of o on defaults. Headers merge.
+func mergeProviderConfig(defaults, o config.ProviderConfig) config.ProviderConfig {
+	merged := defaults
+	if o.BaseURL != "" {
+		merged.BaseURL = o.BaseURL
+	}
+	if o.Timeout > 0 {
+		merged.Timeout = o.Timeout
+	}
+	if o.AuthScheme != "" {
+		merged.AuthScheme = o.AuthScheme
+	}
+	if o.Deployment != "" {
+		merged.Deployment = o.Deployment
+	}
+	if o.APIVersion != "" {
+		merged.APIVersion = o.APIVersion
+	}
+	merged.Headers = make(map[string]string, len(defaults.Headers)+len(o.Headers))
+	for k, v := range defaults.Headers {
+		merged.Headers[k] = v
+	}
+	for k, v := range o.Headers {
+		merged.Headers[k] = v
+	}
+	merged.BaseURL = strings.TrimSuffix(merged.BaseURL, "/")
+	return merged
+}
+
+// httpProvider sends the JSON requests of a provider with its headers and auth scheme.
+type httpProvider struct {
+	settings config.ProviderConfig
+	client   *http.Client
+}
+
+// newRequest builds a request with the provider's headers and auth scheme.
+func (p *httpProvider) newRequest(ctx context.Context, method, rawURL, apiKey string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling request: %w", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	if p.settings.AuthScheme == "query" && apiKey != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing URL: %w", err)
+		}
+		q := u.Query()
+		q.Set("key", apiKey)
+		u.RawQuery = q.Encode()
+		rawURL = u.String()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reader)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range p.settings.Headers {
+		req.Header.Set(k, v)
+	}
+	if apiKey != "" {
+		switch p.settings.AuthScheme {
+		case "bearer":
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		case "api-key":
+			req.Header.Set("api-key", apiKey)
+		case "x-api-key":
+			req.Header.Set("x-api-key", apiKey)
+		}
+	}
+	return req, nil
+}
+
+// do sends a request and returns the response body and status code.
+func (p *httpProvider) do(ctx context.Context, method, rawURL, apiKey string, body interface{}) ([]byte, int, error) {
+	req, err := p.newRequest(ctx, method, rawURL, apiKey, body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("calling API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("reading response: %w", err)
+	}
+	return respBody, resp.StatusCode, nil
+}
+
+// --- OpenAI and OpenAI-compatible servers ---
+
+type openAIRequest struct {
+	Model         string               `json:"model,omitempty"`
+	Messages      []openAIMessage      `json:"messages"`
+	Temperature   float64              `json:"temperature"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// openAIProvider talks to the OpenAI chat completions API and servers compatible with
+// it (Ollama, vLLM, LM Studio). Azure OpenAI uses it with a deployment URL.
+type openAIProvider struct {
+	httpProvider
+	chatURL     string
+	modelsURL   string
+	modelPrefix string // ListModels keeps only models with this prefix
+	deployment  string // Azure: the model is part of the URL
+}
+
+func newOpenAIProvider(settings config.ProviderConfig, client *http.Client, modelPrefix string) (Provider, error) {
+	if settings.BaseURL == "" {
+		return nil, fmt.Errorf("base_url is required")
+	}
+	return &openAIProvider{
+		httpProvider: httpProvider{settings: settings, client: client},
+		chatURL:      settings.BaseURL + "/chat/completions",
+		modelsURL:    settings.BaseURL + "/models",
+		modelPrefix:  modelPrefix,
+	}, nil
+}
+
+// newAzureOpenAIProvider targets a deployment of an Azure OpenAI resource:
+// <base_url>/openai/deployments/<deployment>/chat/completions?api-version=<version>.
+// Without a configured deployment the model name is used.
+func newAzureOpenAIProvider(settings config.ProviderConfig, client *http.Client) (Provider, error) {
+	if settings.BaseURL == "" {
+		return nil, fmt.Errorf("base_url is required (https://<resource>.openai.azure.com)")
+	}
+	return &openAIProvider{
+		httpProvider: httpProvider{settings: settings, client: client},
+		deployment:   settings.Deployment,
+	}, nil
+}
+
+func (p *openAIProvider) azure() bool {
+	return p.chatURL == ""
+}
+
+// request returns the chat completions URL and body of a prompt.
+func (p *openAIProvider) request(r CompletionRequest) (string, openAIRequest, error) {
+	chatURL := p.chatURL
+	reqBody := openAIRequest{
+		Model: r.Model,
+		Messages: []openAIMessage{
+			{Role: "system", Content: r.SystemPrompt},
+			{Role: "user", Content: r.UserPrompt},
+		},
+		Temperature: r.Temperature,
+		MaxTokens:   r.MaxTokens,
+	}
+	if p.azure() {
+		deployment := p.deployment
+		if deployment == "" {
+			deployment = r.Model
+		}
+		if deployment == "" {
+			return "", reqBody, fmt.Errorf("an Azure OpenAI deployment or model is required")
+		}
+		chatURL = fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+			p.settings.BaseURL, url.PathEscape(deployment), url.QueryEscape(p.settings.APIVersion))
+		reqBody.Model = ""
+	}
+	return chatURL, reqBody, nil
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, r CompletionRequest) (*Completion, error) {
+	chatURL, reqBody, err := p.request(r)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, status, err := p.do(ctx, "POST", chatURL, r.APIKey, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", status, string(respBody))
+	}
+
+	var openAIResp openAIResponse
+	if err := json.Unmarshal(respBody, &openAIResp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	if len(openAIResp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+	return &Completion{
+		Text:             openAIResp.Choices[0].Message.Content,
+		PromptTokens:     openAIResp.Usage.PromptTokens,
+		CompletionTokens: openAIResp.Usage.CompletionTokens,
+	}, nil
+}
+
+func (p *openAIProvider) ListModels(ctx context.Context, apiKey string) ([]string, error) {
+	// Azure deployments are managed outside the data plane API
+	if p.azure() {
+		if p.deployment == "" {
+			return nil, fmt.Errorf("listing models is not supported for Azure OpenAI; configure a deployment")
+		}
+		return []string{p.deployment}, nil
+	}
+
+	body, status, err := p.do(ctx, "GET", p.modelsURL, apiKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("calling models API: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("models API returned %d: %s", status, string(body))
+	}
+
+	var result struct {
+		Data []struct {
+			ID      string `json:"id"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	// Filter to chat models only
+	var models []string
+	for _, m := range result.Data {
+		if strings.HasPrefix(m.ID, p.modelPrefix) {
+			models = append(models, m.ID)
+		}
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// --- Claude API ---
+
+type claudeRequest struct {
+	Model       string          `json:"model"`
+	MaxTokens   int             `json:"max_tokens"`
+	System      string          `json:"system"`
+	Messages    []claudeMessage `json:"messages"`
+	Temperature float64         `json:"temperature"`
+	Stream      bool            `json:"stream,omitempty"`
+}
+
+type claudeMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type claudeResponse struct {
+	Content []struct {
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type claudeProvider struct {
+	httpProvider
+}
+
+func newClaudeProvider(settings config.ProviderConfig, client *http.Client) (Provider, error) {
+	return &claudeProvider{httpProvider{settings: settings, client: client}}, nil
+}
+
+func (p *claudeProvider) request(r CompletionRequest) claudeRequest {
+	return claudeRequest{
+		Model:     r.Model,
+		MaxTokens: r.MaxTokens,
+		System:    r.SystemPrompt,
+		Messages: []claudeMessage{
+			{Role: "user", Content: r.UserPrompt},
+		},
+		Temperature: r.Temperature,
+	}
+}
+
+func (p *claudeProvider) Complete(ctx context.Context, r CompletionRequest) (*Completion, error) {
+	respBody, status, err := p.do(ctx, "POST", p.settings.BaseURL+"/messages", r.APIKey, p.request(r))
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", status, string(respBody))
+	}
+
+	var claudeResp claudeResponse
+	if err := json.Unmarshal(respBody, &claudeResp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	if len(claudeResp.Content) == 0 {
+		return nil, fmt.Errorf("no content in response")
+	}
+	return &Completion{
+		Text:             claudeResp.Content[0].Text,
+		PromptTokens:     claudeResp.Usage.InputTokens,
+		CompletionTokens: claudeResp.Usage.OutputTokens,
+	}, nil
+}
+
+func (p *claudeProvider) ListModels(ctx context.Context, apiKey string) ([]string, error) {
+	body, status, err := p.do(ctx, "GET", p.settings.BaseURL+"/models", apiKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("calling Anthropic models API: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("Anthropic API returned %d: %s", status, string(body))
+	}
+
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	var models []string
+	for _, m := range result.Data {
+		models = append(models, m.ID)
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// --- Gemini API ---
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Parts []geminiPart `json:"parts"`
+	Role  string       `json:"role,omitempty"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature      float64 `json:"temperature"`
+	MaxOutputTokens  int     `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string  `json:"responseMimeType,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason,omitempty"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int `json:"thoughtsTokenCount"` // billed as output
+	} `json:"usageMetadata"`
+	Error *struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error,omitempty"`
+}
+
+type geminiProvider struct {
+	httpProvider
+}
+
+func newGeminiProvider(settings config.ProviderConfig, client *http.Client) (Provider, error) {
+	return &geminiProvider{httpProvider{settings: settings, client: client}}, nil
+}
+
+// request returns the model and body of a prompt, and the output token limit it sets.
+func (p *geminiProvider) request(r CompletionRequest) (string, geminiRequest, int) {
+	model := r.Model
+	if model == "" {
+		model = "gemini-2.0-flash"
+	}
+
+	maxTokens := r.MaxTokens
+	// Gemini 2.5 "thinking" models need higher token budget since
+	// thinking tokens count towards maxOutputTokens
+	if strings.Contains(model, "2.5") && maxTokens < 8192 {
+		maxTokens = 8192
+	}
+
+	reqBody := geminiRequest{
+		SystemInstruction: &geminiContent{
+			Parts: []geminiPart{{Text: r.SystemPrompt}},
+		},
+		Contents: []geminiContent{
+			{
+				Role:  "user",
+				Parts: []geminiPart{{Text: r.UserPrompt}},
+			},
+		},
+		GenerationConfig: &geminiGenerationConfig{
+			Temperature:      r.Temperature,
+			MaxOutputTokens:  maxTokens,
+			ResponseMimeType: "application/json",
+		},
+	}
+	return model, reqBody, maxTokens
+}
+
+func (p *geminiProvider) Complete(ctx context.Context, r CompletionRequest) (*Completion, error) {
+	model, reqBody, maxTokens := p.request(r)
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", p.settings.BaseURL, model)
+	respBody, status, err := p.do(ctx, "POST", endpoint, r.APIKey, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("calling Gemini API: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("Gemini API returned status %d: %s", status, string(respBody))
+	}
+
+	var geminiResp geminiResponse
+	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+
+	if geminiResp.Error != nil {
+		return nil, fmt.Errorf("Gemini API error: %s", geminiResp.Error.Message)
+	}
+
+	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
+		return nil, fmt.Errorf("no content in Gemini response")
+	}
+
+	// Concatenate all parts (Gemini may split response across multiple parts)
+	var fullText strings.Builder
+	for _, part := range geminiResp.Candidates[0].Content.Parts {
+		fullText.WriteString(part.Text)
+	}
+
+	text := fullText.String()
+	finishReason := geminiResp.Candidates[0].FinishReason
+	slog.Info("Gemini API response received",
+		"text_len", len(text),
+		"parts_count", len(geminiResp.Candidates[0].Content.Parts),
+		"finish_reason", finishReason)
+
+	if finishReason == "MAX_TOKENS" {
+		slog.Warn("Gemini response was truncated (MAX_TOKENS). Consider increasing maxOutputTokens", "text_len", len(text), "max_tokens", maxTokens)
+	}
+
+	return &Completion{
+		Text:             text,
+		PromptTokens:     geminiResp.UsageMetadata.PromptTokenCount,
+		CompletionTokens: geminiResp.UsageMetadata.CandidatesTokenCount + geminiResp.UsageMetadata.ThoughtsTokenCount,
+	}, nil
+}
+
+func (p *geminiProvider) ListModels(ctx context.Context, apiKey string) ([]string, error) {
+	body, status, err := p.do(ctx, "GET", p.settings.BaseURL+"/models", apiKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("calling Gemini models API: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("Gemini API returned %d: %s", status, string(body))
+	}
+
+	var result struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	var models []string
+	for _, m := range result.Models {
+		// Filter to models that support content generation
+		for _, method := range m.SupportedGenerationMethods {
+			if method == "generateContent" {
+				// "models/gemini-2.0-flash" → "gemini-2.0-flash"
+				name := strings.TrimPrefix(m.Name, "models/")
+				models = append(models, name)
+				break
+			}
+		}
+	}
+	sort.Strings(models)
+	return models, nil
+}
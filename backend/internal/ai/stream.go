@@ -0,0 +1,256 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// StreamingProvider is a Provider that can deliver its reply as it is generated.
+type StreamingProvider interface {
+	Provider
+	// Stream sends the prompt like Complete and calls onText with each piece of the
+	// reply as it arrives. It returns the full reply.
+	Stream(ctx context.Context, req CompletionRequest, onText func(string)) (*Completion, error)
+}
+
+// maxSSELine bounds one server-sent event line. Gemini sends whole candidates per
+// event, which can hold large parts of a stack manifest.
+const maxSSELine = 4 << 20
+
+// stream sends a request and calls fn with the event name and data of every
+// server-sent event in the response.
+func (p *httpProvider) stream(ctx context.Context, rawURL, apiKey string, body interface{}, fn func(event, data string) error) error {
+	req, err := p.newRequest(ctx, "POST", rawURL, apiKey, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return readSSE(resp.Body, fn)
+}
+
+// readSSE parses a text/event-stream body. Multi-line data is joined with newlines.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELine)
+
+	var event string
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := fn(event, strings.Join(data, "\n"))
+		event, data = "", nil
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading stream: %w", err)
+	}
+	return dispatch()
+}
+
+// --- OpenAI and OpenAI-compatible servers ---
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, r CompletionRequest, onText func(string)) (*Completion, error) {
+	chatURL, reqBody, err := p.request(r)
+	if err != nil {
+		return nil, err
+	}
+	reqBody.Stream = true
+	// Older Azure API versions reject stream_options
+	if !p.azure() {
+		reqBody.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	var text strings.Builder
+	completion := &Completion{}
+	err = p.stream(ctx, chatURL, r.APIKey, reqBody, func(_, data string) error {
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("parsing stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("API error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			completion.PromptTokens = chunk.Usage.PromptTokens
+			completion.CompletionTokens = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			text.WriteString(chunk.Choices[0].Delta.Content)
+			onText(chunk.Choices[0].Delta.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no content in response")
+	}
+	completion.Text = text.String()
+	return completion, nil
+}
+
+// --- Claude API ---
+
+type claudeStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *claudeProvider) Stream(ctx context.Context, r CompletionRequest, onText func(string)) (*Completion, error) {
+	reqBody := p.request(r)
+	reqBody.Stream = true
+
+	var text strings.Builder
+	completion := &Completion{}
+	err := p.stream(ctx, p.settings.BaseURL+"/messages", r.APIKey, reqBody, func(_, data string) error {
+		var ev claudeStreamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return fmt.Errorf("parsing stream event: %w", err)
+		}
+		switch ev.Type {
+		case "message_start":
+			completion.PromptTokens = ev.Message.Usage.InputTokens
+			completion.CompletionTokens = ev.Message.Usage.OutputTokens
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+				text.WriteString(ev.Delta.Text)
+				onText(ev.Delta.Text)
+			}
+		case "message_delta":
+			// The output count is cumulative
+			completion.CompletionTokens = ev.Usage.OutputTokens
+		case "error":
+			return fmt.Errorf("API error: %s", ev.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no content in response")
+	}
+	completion.Text = text.String()
+	return completion, nil
+}
+
+// --- Gemini API ---
+
+func (p *geminiProvider) Stream(ctx context.Context, r CompletionRequest, onText func(string)) (*Completion, error) {
+	model, reqBody, maxTokens := p.request(r)
+	endpoint := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", p.settings.BaseURL, model)
+
+	var text strings.Builder
+	var finishReason string
+	completion := &Completion{}
+	err := p.stream(ctx, endpoint, r.APIKey, reqBody, func(_, data string) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("parsing stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("Gemini API error: %s", chunk.Error.Message)
+		}
+		// Every chunk carries the usage so far
+		if u := chunk.UsageMetadata; u.PromptTokenCount > 0 || u.CandidatesTokenCount > 0 {
+			completion.PromptTokens = u.PromptTokenCount
+			completion.CompletionTokens = u.CandidatesTokenCount + u.ThoughtsTokenCount
+		}
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+		if chunk.Candidates[0].FinishReason != "" {
+			finishReason = chunk.Candidates[0].FinishReason
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text != "" {
+				text.WriteString(part.Text)
+				onText(part.Text)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("calling Gemini API: %w", err)
+	}
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no content in Gemini response")
+	}
+
+	completion.Text = text.String()
+	slog.Info("Gemini API stream received", "text_len", text.Len(), "finish_reason", finishReason)
+	if finishReason == "MAX_TOKENS" {
+		slog.Warn("Gemini response was truncated (MAX_TOKENS). Consider increasing maxOutputTokens", "text_len", text.Len(), "max_tokens", maxTokens)
+	}
+	return completion, nil
+}
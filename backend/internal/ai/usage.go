@@ -0,0 +1,144 @@
+package ai
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/config"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// UsageStore records every AI request and sums their cost for the monthly budget.
+// data.Store implements it.
+type UsageStore interface {
+	SaveAIUsage(ctx context.Context, rec *models.AIUsageRecord) error
+	SumAIUsageCost(ctx context.Context, since time.Time) (float64, error)
+}
+
+// Store is what the service persists: cached responses and usage records.
+type Store interface {
+	ResponseCache
+	UsageStore
+}
+
+type usageTagKey struct{}
+
+type usageTag struct {
+	deployID string
+	user     string
+}
+
+// WithDeploy returns a context whose AI requests are recorded against the deploy and
+// the user who requested it.
+func WithDeploy(ctx context.Context, deployID, user string) context.Context {
+	return context.WithValue(ctx, usageTagKey{}, usageTag{deployID: deployID, user: user})
+}
+
+// aiCall accumulates the usage of one request across its retries.
+type aiCall struct {
+	operation        string
+	provider         string
+	model            string
+	promptTokens     int
+	completionTokens int
+	retries          int
+	start            time.Time
+}
+
+func (s *aiService) newCall(operation string) *aiCall {
+	return &aiCall{operation: operation, start: time.Now()}
+}
+
+// modelPrice returns the price of a model: an exact entry, or else the longest
+// entry the model name starts with ("gpt-4o" prices "gpt-4o-2024-08-06").
+func modelPrice(pricing map[string]config.ModelPrice, model string) (config.ModelPrice, bool) {
+	if p, ok := pricing[model]; ok {
+		return p, true
+	}
+	var best string
+	for prefix := range pricing {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return config.ModelPrice{}, false
+	}
+	return pricing[best], true
+}
+
+// recordUsage stores the usage of a finished request. Costs are zero for models
+// without a price.
+func (s *aiService) recordUsage(ctx context.Context, call *aiCall, outcome string, callErr error) {
+	rec := &models.AIUsageRecord{
+		Operation:        call.operation,
+		Provider:         call.provider,
+		Model:            call.model,
+		PromptTokens:     call.promptTokens,
+		CompletionTokens: call.completionTokens,
+		LatencyMS:        time.Since(call.start).Milliseconds(),
+		Retries:          call.retries,
+		Outcome:          outcome,
+		CreatedAt:        time.Now(),
+	}
+	if price, ok := modelPrice(s.pricing, call.model); ok {
+		rec.CostUSD = (float64(call.promptTokens)*price.Input + float64(call.completionTokens)*price.Output) / 1e6
+	}
+	if callErr != nil {
+		rec.Error = truncate(callErr.Error(), 500)
+	}
+	if tag, ok := ctx.Value(usageTagKey{}).(usageTag); ok {
+		rec.DeployID = tag.deployID
+		rec.User = tag.user
+	}
+
+	slog.Info("AI call finished", "operation", rec.Operation, "provider", rec.Provider, "model", rec.Model,
+		"prompt_tokens", rec.PromptTokens, "completion_tokens", rec.CompletionTokens, "latency_ms", rec.LatencyMS,
+		"retries", rec.Retries, "outcome", rec.Outcome, "deploy_id", rec.DeployID)
+	if s.store == nil {
+		return
+	}
+	// Record even when the request itself was cancelled
+	if err := s.store.SaveAIUsage(context.WithoutCancel(ctx), rec); err != nil {
+		slog.Warn("failed to record AI usage", "operation", rec.Operation, "error", err)
+	}
+}
+
+// finishCall records the usage of a finished request and reports its final event.
+func (s *aiService) finishCall(ctx context.Context, call *aiCall, outcome string, callErr error) {
+	s.recordUsage(ctx, call, outcome, callErr)
+	emitProgress(ctx, call, finishEvent(call, outcome, callErr))
+}
+
+// monthStart is the first instant of the month containing t, in UTC.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// BudgetStatus reports this month's recorded cost against the monthly budget.
+func (s *aiService) BudgetStatus(ctx context.Context) models.AIBudgetStatus {
+	status := models.AIBudgetStatus{MonthlyUSD: s.budget.MonthlyUSD}
+	if s.store == nil {
+		return status
+	}
+	spent, err := s.store.SumAIUsageCost(ctx, monthStart(time.Now()))
+	if err != nil {
+		slog.Warn("failed to sum AI usage cost", "error", err)
+		return status
+	}
+	status.SpentUSD = spent
+	status.Exceeded = status.MonthlyUSD > 0 && spent >= status.MonthlyUSD
+	return status
+}
+
+// budgetExceeded reports whether requests must stop for the rest of the month.
+func (s *aiService) budgetExceeded(ctx context.Context) (models.AIBudgetStatus, bool) {
+	if s.budget.MonthlyUSD <= 0 {
+		return models.AIBudgetStatus{}, false
+	}
+	status := s.BudgetStatus(ctx)
+	return status, status.Exceeded
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// parseUsageTime parses a from/to query value: a date (YYYY-MM-DD, UTC) or an
+// RFC3339 time. A date used as the end of a range covers the whole day.
+func parseUsageTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use YYYY-MM-DD or RFC3339", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// usageRange reads the from and to query parameters. The range defaults to the
+// current month.
+func usageRange(c *gin.Context) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	from, to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), now
+	for _, q := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(q.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseUsageTime(value, q.name == "to")
+		if err != nil {
+			return from, to, err
+		}
+		*q.dst = t
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+// handleGetAIUsage aggregates the recorded AI requests by day, model and user. The
+// range defaults to the current month.
+func (s *Server) handleGetAIUsage(c *gin.Context) {
+	from, to, err := usageRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return
+	}
+	filter := models.AIUsageFilter{From: from, To: to, DeployID: c.Query("deploy_id")}
+
+	ctx := c.Request.Context()
+	report := models.AIUsageReport{From: filter.From, To: filter.To}
+	for _, group := range []struct {
+		by  string
+		dst *[]models.AIUsageSummary
+	}{{"day", &report.ByDay}, {"model", &report.ByModel}, {"user", &report.ByUser}} {
+		summary, err := s.data.SummarizeAIUsage(ctx, filter, group.by)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "USAGE_ERROR", Message: fmt.Sprintf("failed to summarize AI usage: %v", err)},
+			})
+			return
+		}
+		*group.dst = summary
+	}
+	total, err := s.data.SummarizeAIUsage(ctx, filter, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "USAGE_ERROR", Message: fmt.Sprintf("failed to summarize AI usage: %v", err)},
+		})
+		return
+	}
+	if len(total) > 0 {
+		report.Total = total[0]
+	}
+	report.Budget = s.ai.BudgetStatus(ctx)
+
+	c.JSON(http.StatusOK, report)
+}
+
+// handleGetAIQuality compares the quality scores of the manifests each model produced
+// with each prompt version, over AI-generated and refined revisions. The range
+// defaults to the current month.
+func (s *Server) handleGetAIQuality(c *gin.Context) {
+	from, to, err := usageRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return
+	}
+	source := c.Query("source")
+	if source != "" && source != models.RevisionGenerate && source != models.RevisionRefine {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "source must be generate or refine"},
+		})
+		return
+	}
+
+	summary, err := s.data.SummarizeManifestQuality(c.Request.Context(), models.AIQualityFilter{From: from, To: to, Source: source})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "QUALITY_ERROR", Message: fmt.Sprintf("failed to summarize manifest quality: %v", err)},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.AIQualityReport{From: from, To: to, Source: source, ByModel: summary})
+}
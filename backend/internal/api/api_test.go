@@ -1,18 +1,27 @@
 package api
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/config"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/gitops"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
 	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
 )
 
@@ -41,6 +50,16 @@ func (m *mockDockerService) GetContainer(ctx context.Context, id string) (*model
 	return nil, fmt.Errorf("container %s not found", id)
 }
 
+func (m *mockDockerService) ContainerStats(ctx context.Context, id string) (*models.ContainerStats, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.detail != nil && m.detail.Stats != nil {
+		return m.detail.Stats, nil
+	}
+	return nil, fmt.Errorf("container %s not found", id)
+}
+
 func (m *mockDockerService) RestartContainer(ctx context.Context, id string) error { return m.err }
 func (m *mockDockerService) StopContainer(ctx context.Context, id string) error    { return m.err }
 func (m *mockDockerService) DeleteContainer(ctx context.Context, id string, force bool) error {
@@ -52,7 +71,25 @@ type mockK8sService struct {
 	pods        []models.Pod
 	deployments []models.Deployment
 	services    []models.Service
+	diffs       []models.ResourceDiff
+	rollout     *models.RolloutStatus
+	rolloutErr  error
+	applyErr    error
+	applyErrs   map[string]error // per cluster
+	snapshotErr error
+	restored    []models.ResourceSnapshot
+	applied     []string
+	owned       []models.OwnedResource
+	deleted     []models.Ownership
+	drifts      []models.ResourceDrift
+	adopted     string
+	scaled      []string // "name=replicas"
+	samples     []*models.UsageSample // returned by SampleUsage in order, the last one repeats
+	selectors   []string              // label selectors passed to SampleUsage
+	removed     []string // deleted Deployments
+	byName      []string // "Kind/name" deleted by DeleteResource
 	err         error
+	mu          sync.Mutex // guards applied for concurrent multi-cluster deploys
 }
 
 func (m *mockK8sService) ListClusters(ctx context.Context) ([]models.Cluster, error) {
@@ -71,12 +108,18 @@ func (m *mockK8sService) ListServices(ctx context.Context, cluster, namespace st
 	return m.services, m.err
 }
 func (m *mockK8sService) ScaleDeployment(ctx context.Context, cluster, namespace, name string, replicas int) error {
+	m.mu.Lock()
+	m.scaled = append(m.scaled, fmt.Sprintf("%s=%d", name, replicas))
+	m.mu.Unlock()
 	return m.err
 }
 func (m *mockK8sService) RestartPod(ctx context.Context, cluster, namespace, name string) error {
 	return m.err
 }
 func (m *mockK8sService) DeleteDeployment(ctx context.Context, cluster, namespace, name string) error {
+	m.mu.Lock()
+	m.removed = append(m.removed, name)
+	m.mu.Unlock()
 	return m.err
 }
 func (m *mockK8sService) DeleteService(ctx context.Context, cluster, namespace, name string) error {
@@ -91,12 +134,151 @@ func (m *mockK8sService) AddCluster(ctx context.Context, cfg config.ClusterConfi
 func (m *mockK8sService) RemoveCluster(name string) error {
 	return m.err
 }
-func (m *mockK8sService) ApplyManifest(ctx context.Context, cluster string, yamlContent string) error {
-	return m.err
+func (m *mockK8sService) ApplyManifest(ctx context.Context, cluster string, yamlContent string) ([]models.AppliedResource, error) {
+	err := m.applyErr
+	if err == nil {
+		err = m.applyErrs[cluster]
+	}
+	if err != nil || m.err != nil {
+		if err == nil {
+			err = m.err
+		}
+		return []models.AppliedResource{{Kind: "Deployment", Name: "test", Status: "failed", Error: err.Error()}}, err
+	}
+	m.mu.Lock()
+	m.applied = append(m.applied, yamlContent)
+	m.mu.Unlock()
+	return []models.AppliedResource{{Kind: "Deployment", Name: "test", Status: "applied"}}, nil
+}
+func (m *mockK8sService) ListOwned(ctx context.Context, cluster string, kinds []string) ([]models.OwnedResource, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var owned []models.OwnedResource
+	for _, o := range m.owned {
+		if o.Cluster == cluster {
+			owned = append(owned, o)
+		}
+	}
+	return owned, nil
+}
+func (m *mockK8sService) DeleteOwned(ctx context.Context, cluster string, owner models.Ownership, kinds []string) ([]models.AppliedResource, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.deleted = append(m.deleted, owner)
+	var deleted []models.AppliedResource
+	for _, o := range m.owned {
+		if o.Cluster == cluster && ((owner.DeployID != "" && o.Owner.DeployID == owner.DeployID) || (owner.StackID != "" && o.Owner.StackID == owner.StackID)) {
+			deleted = append(deleted, models.AppliedResource{Kind: o.Kind, Name: o.Name, Namespace: o.Namespace, Status: "deleted"})
+		}
+	}
+	return deleted, nil
 }
 func (m *mockK8sService) DeleteResource(ctx context.Context, cluster, kind, namespace, name string) error {
+	if m.err == nil {
+		m.byName = append(m.byName, kind+"/"+name)
+	}
+	return m.err
+}
+func (m *mockK8sService) DiffManifest(ctx context.Context, cluster string, yamlContent string) ([]models.ResourceDiff, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.diffs != nil {
+		return m.diffs, nil
+	}
+	return []models.ResourceDiff{{Kind: "Deployment", Name: "test", Action: "create"}}, nil
+}
+func (m *mockK8sService) DiffManifestSet(ctx context.Context, cluster string, manifests []string) ([][]models.ResourceDiff, error) {
+	results := make([][]models.ResourceDiff, 0, len(manifests))
+	for _, yamlContent := range manifests {
+		diffs, err := m.DiffManifest(ctx, cluster, yamlContent)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, diffs)
+	}
+	return results, nil
+}
+func (m *mockK8sService) DetectDrift(ctx context.Context, cluster string, yamlContent string) ([]models.ResourceDrift, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.drifts != nil {
+		return m.drifts, nil
+	}
+	return []models.ResourceDrift{{Kind: "Deployment", Name: "test", Status: "in_sync"}}, nil
+}
+func (m *mockK8sService) AdoptLive(ctx context.Context, cluster string, yamlContent string) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	return m.adopted, nil
+}
+func (m *mockK8sService) SnapshotManifest(ctx context.Context, cluster string, yamlContent string) ([]models.ResourceSnapshot, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.snapshotErr != nil {
+		return nil, m.snapshotErr
+	}
+	// the YAML content doubles as the object name so tests can tell snapshots apart
+	return []models.ResourceSnapshot{{APIVersion: "v1", Kind: "Object", Name: yamlContent, Namespace: "default"}}, nil
+}
+func (m *mockK8sService) SampleUsage(ctx context.Context, cluster, namespace, labelSelector string) (*models.UsageSample, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.selectors = append(m.selectors, labelSelector)
+	if len(m.samples) == 0 {
+		return &models.UsageSample{Time: time.Now()}, nil
+	}
+	sample := m.samples[0]
+	if len(m.samples) > 1 {
+		m.samples = m.samples[1:]
+	}
+	return sample, nil
+}
+func (m *mockK8sService) RestoreSnapshot(ctx context.Context, cluster string, snap models.ResourceSnapshot) error {
+	m.restored = append(m.restored, snap)
+	return m.err
+}
+func (m *mockK8sService) RolloutStatus(ctx context.Context, cluster, namespace, kind, name string) (*models.RolloutStatus, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &models.RolloutStatus{Kind: kind, Name: name, Namespace: namespace, Revision: 2, Replicas: 2, ReadyReplicas: 2, Complete: true}, nil
+}
+func (m *mockK8sService) RolloutHistory(ctx context.Context, cluster, namespace, kind, name string) ([]models.RolloutRevision, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []models.RolloutRevision{{Revision: 1}, {Revision: 2, Current: true}}, nil
+}
+func (m *mockK8sService) UndoRollout(ctx context.Context, cluster, namespace, kind, name string, revision int64) error {
+	return m.err
+}
+func (m *mockK8sService) PauseRollout(ctx context.Context, cluster, namespace, kind, name string) error {
+	return m.err
+}
+func (m *mockK8sService) ResumeRollout(ctx context.Context, cluster, namespace, kind, name string) error {
+	return m.err
+}
+func (m *mockK8sService) RestartRollout(ctx context.Context, cluster, namespace, kind, name string) error {
 	return m.err
 }
+func (m *mockK8sService) WaitForRollout(ctx context.Context, cluster, namespace, kind, name string) (*models.RolloutStatus, error) {
+	if m.rollout != nil {
+		return m.rollout, m.rolloutErr
+	}
+	return &models.RolloutStatus{Kind: kind, Name: name, Namespace: namespace, Replicas: 2, ReadyReplicas: 2, Complete: true}, m.rolloutErr
+}
+func (m *mockK8sService) ServiceEndpoint(ctx context.Context, cluster, namespace, name string) (string, error) {
+	return "http://" + name + "." + namespace + ".svc.cluster.local", m.err
+}
 
 type mockAIService struct {
 	result *models.ManifestResult
@@ -119,6 +301,15 @@ func (m *mockAIService) RefineManifest(ctx context.Context, currentManifest *mod
 func (m *mockAIService) ListModels(ctx context.Context, provider, apiKey string) ([]string, error) {
 	return []string{"model-1", "model-2"}, nil
 }
+func (m *mockAIService) CacheStats(ctx context.Context) models.AICacheStats {
+	return models.AICacheStats{}
+}
+func (m *mockAIService) PurgeCache(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+func (m *mockAIService) BudgetStatus(ctx context.Context) models.AIBudgetStatus {
+	return models.AIBudgetStatus{}
+}
 func (m *mockAIService) GenerateStackManifest(ctx context.Context, info ai.StackContainerInfo, history []models.DeploymentHistory) (*ai.StackManifestResult, error) {
 	return &ai.StackManifestResult{
 		Topology: models.StackTopology{DeployOrder: []string{"svc1"}},
@@ -135,14 +326,39 @@ func (m *mockAIService) RefineStackManifest(ctx context.Context, current *ai.Sta
 
 type mockDataStore struct {
 	history []models.DeploymentHistory
+	stacks  []models.StackDeployRecord
+	drift   map[string]string
+	saved   []models.DeploymentHistory
 	err     error
+	mu      sync.Mutex // guards saved, promotions, revisions, deploys, settings and the AI cache and usage for concurrent deploys
+
+	promotions  []models.Promotion
+	revisions   []models.ManifestRevision
+	deploys     map[string]models.DeployRecord
+	deploySaves []models.DeployRecord // every saved deploy record, in order
+	aiCache     map[string]models.AICacheEntry
+	aiUsage     []models.AIUsageRecord
+	settings    map[string]string
+	registered  []models.RegisteredCluster
 }
 
 func (m *mockDataStore) Init() error  { return nil }
 func (m *mockDataStore) Close() error { return nil }
 func (m *mockDataStore) SaveDeployment(ctx context.Context, d *models.DeploymentHistory) error {
+	if m.err == nil {
+		m.mu.Lock()
+		m.saved = append(m.saved, *d)
+		m.mu.Unlock()
+	}
 	return m.err
 }
+
+// savedDeployments returns the history entries saved so far.
+func (m *mockDataStore) savedDeployments() []models.DeploymentHistory {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.DeploymentHistory(nil), m.saved...)
+}
 func (m *mockDataStore) GetDeployHistory(ctx context.Context, limit int) ([]models.DeploymentHistory, error) {
 	if m.err != nil {
 		return nil, m.err
@@ -153,10 +369,21 @@ func (m *mockDataStore) FindSimilar(ctx context.Context, imageName, serviceType
 	return m.history, m.err
 }
 func (m *mockDataStore) SaveSetting(ctx context.Context, key, value string) error {
-	return m.err
+	if m.err != nil {
+		return m.err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.settings == nil {
+		m.settings = map[string]string{}
+	}
+	m.settings[key] = value
+	return nil
 }
 func (m *mockDataStore) GetSetting(ctx context.Context, key string) (string, error) {
-	return "", m.err
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settings[key], m.err
 }
 func (m *mockDataStore) GetAllSettings(ctx context.Context, prefix string) (map[string]string, error) {
 	return map[string]string{}, m.err
@@ -171,14 +398,53 @@ func (m *mockDataStore) DeleteRegisteredCluster(ctx context.Context, name string
 	return m.err
 }
 func (m *mockDataStore) GetRegisteredClusters(ctx context.Context) ([]models.RegisteredCluster, error) {
-	return []models.RegisteredCluster{}, m.err
+	if m.registered == nil {
+		return []models.RegisteredCluster{}, m.err
+	}
+	return m.registered, m.err
 }
 func (m *mockDataStore) GetDeployment(ctx context.Context, id string) (*models.DeploymentHistory, error) {
-	return nil, m.err
+	if m.err != nil {
+		return nil, m.err
+	}
+	for i := range m.history {
+		if m.history[i].ID == id {
+			return &m.history[i], nil
+		}
+	}
+	return nil, fmt.Errorf("deployment %s not found", id)
 }
 func (m *mockDataStore) UpdateDeploymentStatus(ctx context.Context, id string, status string, deletedAt *time.Time) error {
 	return m.err
 }
+func (m *mockDataStore) UpdateDeploymentManifest(ctx context.Context, id string, manifestJSON string) error {
+	for i := range m.history {
+		if m.history[i].ID == id {
+			m.history[i].ManifestJSON = manifestJSON
+		}
+	}
+	return m.err
+}
+func (m *mockDataStore) UpdateDriftStatus(ctx context.Context, id string, driftStatus string, checkedAt time.Time) error {
+	if m.drift == nil {
+		m.drift = map[string]string{}
+	}
+	m.drift[id] = driftStatus
+	return m.err
+}
+func (m *mockDataStore) UpdateDeploymentUsage(ctx context.Context, d *models.DeploymentHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.history {
+		if m.history[i].ID == d.ID {
+			m.history[i].ActualCPU, m.history[i].ActualMemory = d.ActualCPU, d.ActualMemory
+			m.history[i].OOMEvents, m.history[i].ThrottleEvents = d.OOMEvents, d.ThrottleEvents
+			usage := *d.Usage
+			m.history[i].Usage = &usage
+		}
+	}
+	return m.err
+}
 func (m *mockDataStore) DeleteDeploymentRecord(ctx context.Context, id string) error {
 	return m.err
 }
@@ -186,13 +452,25 @@ func (m *mockDataStore) SaveStackDeploy(ctx context.Context, record *models.Stac
 	return m.err
 }
 func (m *mockDataStore) GetStackDeploy(ctx context.Context, deployID string) (*models.StackDeployRecord, error) {
+	for i := range m.stacks {
+		if m.stacks[i].DeployID == deployID {
+			return &m.stacks[i], m.err
+		}
+	}
 	return nil, m.err
 }
 func (m *mockDataStore) UpdateStackDeploy(ctx context.Context, record *models.StackDeployRecord) error {
 	return m.err
 }
 func (m *mockDataStore) ListStackDeploys(ctx context.Context, limit int) ([]models.StackDeployRecord, error) {
-	return nil, m.err
+	return m.stacks, m.err
+}
+func (m *mockDataStore) UpdateStackDriftStatus(ctx context.Context, deployID string, driftStatus string, checkedAt time.Time) error {
+	if m.drift == nil {
+		m.drift = map[string]string{}
+	}
+	m.drift[deployID] = driftStatus
+	return m.err
 }
 func (m *mockDataStore) DeleteStackDeploy(ctx context.Context, deployID string) error {
 	return m.err
@@ -200,6 +478,233 @@ func (m *mockDataStore) DeleteStackDeploy(ctx context.Context, deployID string)
 func (m *mockDataStore) ListUnifiedHistory(ctx context.Context, offset, limit int) ([]models.UnifiedDeployItem, int, error) {
 	return []models.UnifiedDeployItem{}, 0, m.err
 }
+func (m *mockDataStore) SavePromotion(ctx context.Context, p *models.Promotion) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.promotions {
+		if m.promotions[i].ID == p.ID {
+			m.promotions[i] = *p
+			return nil
+		}
+	}
+	m.promotions = append(m.promotions, *p)
+	return nil
+}
+func (m *mockDataStore) GetPromotion(ctx context.Context, id string) (*models.Promotion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.promotions {
+		if m.promotions[i].ID == id {
+			p := m.promotions[i]
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("promotion %s not found", id)
+}
+func (m *mockDataStore) ListPromotions(ctx context.Context, filter models.PromotionFilter) ([]models.Promotion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := []models.Promotion{}
+	for i := len(m.promotions) - 1; i >= 0; i-- {
+		p := m.promotions[i]
+		if (filter.Status != "" && p.Status != filter.Status) || (filter.ToEnv != "" && p.ToEnv != filter.ToEnv) ||
+			(filter.SourceID != "" && p.SourceID != filter.SourceID) || (filter.DeployID != "" && p.DeployID != filter.DeployID) {
+			continue
+		}
+		result = append(result, p)
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			break
+		}
+	}
+	return result, m.err
+}
+func (m *mockDataStore) SaveManifestRevision(ctx context.Context, rev *models.ManifestRevision) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rev.Version = 1
+	for _, r := range m.revisions {
+		if r.DeployID == rev.DeployID && r.Version >= rev.Version {
+			rev.Version = r.Version + 1
+		}
+	}
+	m.revisions = append(m.revisions, *rev)
+	return m.err
+}
+func (m *mockDataStore) GetManifestRevision(ctx context.Context, deployID string, version int) (*models.ManifestRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.revisions {
+		if r.DeployID == deployID && r.Version == version {
+			return &r, nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d not found", version)
+}
+func (m *mockDataStore) ListManifestRevisions(ctx context.Context, deployID string) ([]models.ManifestRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := []models.ManifestRevision{}
+	for _, r := range m.revisions {
+		if r.DeployID == deployID {
+			result = append(result, r)
+		}
+	}
+	return result, m.err
+}
+func (m *mockDataStore) SaveSingleDeploy(ctx context.Context, record *models.DeployRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.deploys == nil {
+		m.deploys = map[string]models.DeployRecord{}
+	}
+	m.deploys[record.DeployID] = *record
+	m.deploySaves = append(m.deploySaves, *record)
+	return m.err
+}
+func (m *mockDataStore) GetSingleDeploy(ctx context.Context, deployID string) (*models.DeployRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.deploys[deployID]; ok {
+		return &r, nil
+	}
+	return nil, fmt.Errorf("deploy %s not found", deployID)
+}
+func (m *mockDataStore) ListSingleDeploys(ctx context.Context, limit int) ([]models.DeployRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := []models.DeployRecord{}
+	for _, r := range m.deploys {
+		result = append(result, r)
+	}
+	return result, m.err
+}
+func (m *mockDataStore) SaveAICacheEntry(ctx context.Context, entry *models.AICacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.aiCache == nil {
+		m.aiCache = map[string]models.AICacheEntry{}
+	}
+	m.aiCache[entry.Key] = *entry
+	return m.err
+}
+func (m *mockDataStore) GetAICacheEntry(ctx context.Context, key string) (*models.AICacheEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.aiCache[key]; ok && e.ExpiresAt.After(time.Now()) {
+		return &e, nil
+	}
+	return nil, fmt.Errorf("cache entry %s not found", key)
+}
+func (m *mockDataStore) CountAICacheEntries(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.aiCache), m.err
+}
+func (m *mockDataStore) PurgeAICache(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := int64(len(m.aiCache))
+	m.aiCache = nil
+	return n, m.err
+}
+func (m *mockDataStore) SaveAIUsage(ctx context.Context, rec *models.AIUsageRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec.ID = int64(len(m.aiUsage) + 1)
+	m.aiUsage = append(m.aiUsage, *rec)
+	return m.err
+}
+
+// usageRecords returns the AI usage recorded so far.
+func (m *mockDataStore) usageRecords() []models.AIUsageRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.AIUsageRecord(nil), m.aiUsage...)
+}
+func (m *mockDataStore) SumAIUsageCost(ctx context.Context, since time.Time) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total float64
+	for _, r := range m.aiUsage {
+		if !r.CreatedAt.Before(since) {
+			total += r.CostUSD
+		}
+	}
+	return total, m.err
+}
+func (m *mockDataStore) SummarizeAIUsage(ctx context.Context, filter models.AIUsageFilter, groupBy string) ([]models.AIUsageSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byKey := map[string]*models.AIUsageSummary{}
+	var keys []string
+	for _, r := range m.aiUsage {
+		if r.CreatedAt.Before(filter.From) || (!filter.To.IsZero() && !r.CreatedAt.Before(filter.To)) ||
+			(filter.DeployID != "" && r.DeployID != filter.DeployID) {
+			continue
+		}
+		key := map[string]string{"day": r.CreatedAt.UTC().Format("2006-01-02"), "model": r.Provider + "/" + r.Model, "user": r.User}[groupBy]
+		sum, ok := byKey[key]
+		if !ok {
+			sum = &models.AIUsageSummary{Key: key}
+			byKey[key] = sum
+			keys = append(keys, key)
+		}
+		sum.Calls++
+		sum.PromptTokens += r.PromptTokens
+		sum.CompletionTokens += r.CompletionTokens
+		sum.CostUSD += r.CostUSD
+		sum.Retries += r.Retries
+		if r.LatencyMS > sum.MaxLatencyMS {
+			sum.MaxLatencyMS = r.LatencyMS
+		}
+		if r.Outcome != models.AIOutcomeOK {
+			sum.Failures++
+		}
+	}
+	sort.Strings(keys)
+	result := []models.AIUsageSummary{}
+	for _, k := range keys {
+		result = append(result, *byKey[k])
+	}
+	return result, m.err
+}
+func (m *mockDataStore) SummarizeManifestQuality(ctx context.Context, filter models.AIQualityFilter) ([]models.AIQualitySummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byKey := map[string]*models.AIQualitySummary{}
+	var keys []string
+	for _, r := range m.revisions {
+		if r.Model == "" || r.Quality == nil || (r.Source != models.RevisionGenerate && r.Source != models.RevisionRefine) ||
+			(filter.Source != "" && r.Source != filter.Source) {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s/%d/%d", r.Provider, r.Model, r.PromptVersion, r.Quality.RulesVersion)
+		sum, ok := byKey[key]
+		if !ok {
+			sum = &models.AIQualitySummary{Provider: r.Provider, Model: r.Model, PromptVersion: r.PromptVersion,
+				RulesVersion: r.Quality.RulesVersion, MinScore: r.Quality.Score, MaxScore: r.Quality.Score}
+			byKey[key] = sum
+			keys = append(keys, key)
+		}
+		sum.AvgScore = (sum.AvgScore*float64(sum.Revisions) + float64(r.Quality.Score)) / float64(sum.Revisions+1)
+		sum.Revisions++
+		if r.Quality.Score < sum.MinScore {
+			sum.MinScore = r.Quality.Score
+		}
+		if r.Quality.Score > sum.MaxScore {
+			sum.MaxScore = r.Quality.Score
+		}
+	}
+	sort.Strings(keys)
+	result := []models.AIQualitySummary{}
+	for _, k := range keys {
+		result = append(result, *byKey[k])
+	}
+	return result, m.err
+}
 func (m *mockDataStore) CleanupOldRecords(ctx context.Context, retentionDays int) (int64, error) {
 	return 0, m.err
 }
@@ -421,6 +926,86 @@ func TestListClusters(t *testing.T) {
 	}
 }
 
+func TestRolloutStatus(t *testing.T) {
+	s := setupTestServer(t)
+
+	r := gin.New()
+	r.GET("/api/k8s/:cluster/rollouts/:kind/:ns/:name/status", s.handleRolloutStatus)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/k8s/test-cluster/rollouts/deploy/default/web/status", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp models.RolloutStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Kind != "Deployment" {
+		t.Errorf("expected normalized kind 'Deployment', got %q", resp.Kind)
+	}
+	if !resp.Complete {
+		t.Error("expected rollout to be complete")
+	}
+}
+
+func TestRolloutStatus_InvalidKind(t *testing.T) {
+	s := setupTestServer(t)
+
+	r := gin.New()
+	r.GET("/api/k8s/:cluster/rollouts/:kind/:ns/:name/status", s.handleRolloutStatus)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/k8s/test-cluster/rollouts/cronjob/default/web/status", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRolloutUndo(t *testing.T) {
+	s := setupTestServer(t)
+
+	r := gin.New()
+	r.POST("/api/k8s/:cluster/rollouts/:kind/:ns/:name/undo", s.handleRolloutUndo)
+
+	// empty body rolls back to the previous revision
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/k8s/test-cluster/rollouts/statefulset/default/db/undo", nil)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for empty body, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/k8s/test-cluster/rollouts/statefulset/default/db/undo", bytes.NewBufferString(`{"revision": -1}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for negative revision, got %d", w.Code)
+	}
+}
+
+func TestRolloutRestart_Error(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{err: fmt.Errorf("cannot restart a paused deployment")}
+
+	r := gin.New()
+	r.POST("/api/k8s/:cluster/rollouts/:kind/:ns/:name/restart", s.handleRolloutRestart)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/k8s/test-cluster/rollouts/deployment/default/web/restart", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+}
+
 // --- Deploy Handler Tests ---
 
 func TestGetDeployHistory(t *testing.T) {
@@ -597,6 +1182,26 @@ func TestGetAIConfig(t *testing.T) {
 	}
 }
 
+func TestUpdateAIConfig_UnknownProvider(t *testing.T) {
+	s := setupTestServer(t)
+
+	r := gin.New()
+	r.PUT("/api/config/ai", s.handleUpdateAIConfig)
+
+	for body, want := range map[string]int{
+		`{"provider":"bard","model":"x"}`:        http.StatusBadRequest,
+		`{"provider":"ollama","model":"llama3"}`: http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/config/ai", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d: %s", body, want, w.Code, w.Body.String())
+		}
+	}
+}
+
 // --- Stack Deploy Handler Tests ---
 
 func TestDeployStack(t *testing.T) {
@@ -776,29 +1381,3396 @@ func TestStackDeployFlow_DeployThenStatus(t *testing.T) {
 	}
 }
 
-// --- Detect Functions Tests ---
+func TestExecuteDeployAsync_ReportsAppliedResources(t *testing.T) {
+	s := setupTestServer(t)
+	s.deployStates["d1"] = &deployState{
+		Status: &models.DeployStatus{DeployID: "d1", Status: "deploying", Steps: []models.DeployStep{
+			{Step: "push_image", Status: "pending"},
+			{Step: "create_deployment", Status: "pending"},
+			{Step: "create_service", Status: "pending"},
+			{Step: "wait_ready", Status: "pending"},
+		}},
+		Response:  &models.DeployResponse{DeployID: "d1"},
+		Request:   &models.DeployRequest{ContainerID: "abc123", ClusterName: "test-cluster"},
+		Manifests: &models.ManifestResult{Deployment: "deploy-yaml", Service: "svc-yaml"},
+	}
 
-func TestDetectServiceType(t *testing.T) {
-	tests := []struct {
-		image    string
-		expected string
-	}{
-		{"nginx:latest", "web-server"},
-		{"node:18-alpine", "web-application"},
-		{"postgres:15", "database"},
-		{"mysql:8.0", "database"},
-		{"redis:7", "database"},
-		{"rabbitmq:3-management", "message-queue"},
-		{"my-custom-app:v1", "application"},
+	s.executeDeployAsync("d1")
+
+	status := s.deployStates["d1"].Status
+	if status.Status != "completed" {
+		t.Fatalf("expected completed, got %q", status.Status)
+	}
+	step := status.Steps[1]
+	if len(step.Resources) != 1 || step.Resources[0].Status != "applied" {
+		t.Errorf("expected one applied resource on create_deployment, got %+v", step.Resources)
+	}
+	if step.Message != "Applied Deployment/test" {
+		t.Errorf("unexpected step message %q", step.Message)
+	}
+	if status.Steps[3].Step != "wait_ready" || status.Steps[3].Status != "completed" {
+		t.Errorf("expected wait_ready to complete, got %+v", status.Steps[3])
 	}
+	if status.Result == nil || status.Result.PodsReady != "2/2" || status.Result.Replicas != 2 {
+		t.Errorf("expected real ready count in result, got %+v", status.Result)
+	}
+}
 
-	for _, tt := range tests {
-		t.Run(tt.image, func(t *testing.T) {
-			info := struct {
-				Image string
-			}{Image: tt.image}
-			// Use the internal function via the package
-			_ = info // detectServiceType is tested via deploy handler
-		})
+func TestExecuteDeployAsync_WaitReadyFailure(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{
+		rollout: &models.RolloutStatus{Kind: "Deployment", Name: "test", Replicas: 2, ReadyReplicas: 0, Failed: true,
+			Failures: []models.PodFailure{{Pod: "test-abc", Reason: "CrashLoopBackOff", Message: "last exit code 1", Fatal: true}}},
+		rolloutErr: fmt.Errorf("pod test-abc: CrashLoopBackOff: last exit code 1"),
+	}
+	s.deployStates["d1"] = &deployState{
+		Status: &models.DeployStatus{DeployID: "d1", Status: "deploying", Steps: []models.DeployStep{
+			{Step: "push_image", Status: "pending"},
+			{Step: "create_deployment", Status: "pending"},
+			{Step: "create_service", Status: "pending"},
+			{Step: "wait_ready", Status: "pending"},
+		}},
+		Response:  &models.DeployResponse{DeployID: "d1"},
+		Request:   &models.DeployRequest{ContainerID: "abc123", ClusterName: "test-cluster"},
+		Manifests: &models.ManifestResult{Deployment: "deploy-yaml", Service: "svc-yaml"},
+	}
+
+	s.executeDeployAsync("d1")
+
+	status := s.deployStates["d1"].Status
+	if status.Status != "failed" {
+		t.Fatalf("expected failed, got %q", status.Status)
+	}
+	if status.Steps[3].Status != "failed" || !strings.Contains(status.Steps[3].Message, "CrashLoopBackOff") {
+		t.Errorf("expected wait_ready failure with classification, got %+v", status.Steps[3])
+	}
+	if status.Result == nil || status.Result.PodsReady != "0/2" || len(status.Result.Failures) != 1 {
+		t.Errorf("expected diagnostics in result, got %+v", status.Result)
+	}
+}
+
+func TestExecuteDeployAsync_RollbackOnFailure(t *testing.T) {
+	k8s := &mockK8sService{
+		rollout:    &models.RolloutStatus{Kind: "Deployment", Name: "test", Replicas: 2, Failed: true},
+		rolloutErr: fmt.Errorf("timed out"),
+	}
+	s := setupTestServer(t)
+	s.kubernetes = k8s
+	s.deployStates["d1"] = newTestDeployState("d1")
+
+	s.executeDeployAsync("d1")
+
+	// deployment and service were applied; both are reverted, last applied first
+	if len(k8s.restored) != 2 || k8s.restored[0].Name != "svc-yaml" || k8s.restored[1].Name != "deploy-yaml" {
+		t.Fatalf("expected service then deployment to be restored, got %+v", k8s.restored)
+	}
+	steps := s.deployStates["d1"].Status.Steps
+	if len(steps) != 6 {
+		t.Fatalf("expected 4 deploy steps + 2 rollback steps, got %d", len(steps))
+	}
+	if steps[4].Step != "rollback:Object/svc-yaml" || steps[4].Status != "completed" {
+		t.Errorf("unexpected rollback step %+v", steps[4])
+	}
+	if s.deployStates["d1"].Status.Status != "failed" {
+		t.Errorf("expected failed, got %q", s.deployStates["d1"].Status.Status)
+	}
+}
+
+func TestExecuteDeployAsync_RollbackOnApplyFailure(t *testing.T) {
+	k8s := &mockK8sService{applyErr: fmt.Errorf("admission webhook denied")}
+	s := setupTestServer(t)
+	s.kubernetes = k8s
+	s.deployStates["d1"] = newTestDeployState("d1")
+
+	s.executeDeployAsync("d1")
+
+	// only the deployment manifest was touched before the failure
+	if len(k8s.restored) != 1 || k8s.restored[0].Name != "deploy-yaml" {
+		t.Fatalf("expected deployment snapshot to be restored, got %+v", k8s.restored)
+	}
+	steps := s.deployStates["d1"].Status.Steps
+	if last := steps[len(steps)-1]; last.Step != "rollback:Object/deploy-yaml" {
+		t.Errorf("expected trailing rollback step, got %+v", last)
+	}
+}
+
+func TestExecuteDeployAsync_SnapshotFailureAbortsApply(t *testing.T) {
+	k8s := &mockK8sService{snapshotErr: fmt.Errorf("deployments.apps is forbidden")}
+	s := setupTestServer(t)
+	s.kubernetes = k8s
+	s.deployStates["d1"] = newTestDeployState("d1")
+
+	s.executeDeployAsync("d1")
+
+	// nothing is applied that a rollback could not restore
+	if len(k8s.applied) != 0 {
+		t.Fatalf("expected no apply without a snapshot, got %v", k8s.applied)
+	}
+	status := s.deployStates["d1"].Status
+	if status.Status != "failed" || status.Steps[1].Status != "failed" || !strings.Contains(status.Steps[1].Message, "snapshotting live state") {
+		t.Errorf("expected create_deployment to fail on the snapshot, got %+v", status.Steps[1])
+	}
+}
+
+func TestRedeploy_RunsUnderNewDeployID(t *testing.T) {
+	stamped, err := kubernetes.StampOwnership("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n", models.Ownership{DeployID: "old-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestJSON, _ := json.Marshal(models.ManifestResult{Deployment: stamped, Service: "svc-yaml"})
+
+	tests := []struct {
+		name       string
+		k8s        *mockK8sService
+		wantStatus string
+	}{
+		{"ready", &mockK8sService{}, "completed"},
+		{"rolled back", &mockK8sService{rollout: &models.RolloutStatus{Kind: "Deployment", Name: "test", Replicas: 2, Failed: true}, rolloutErr: fmt.Errorf("timed out")}, "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := setupTestServer(t)
+			s.kubernetes = tt.k8s
+			store := &mockDataStore{history: []models.DeploymentHistory{{
+				ID: "old-1", ServiceName: "web", TargetCluster: "test-cluster", Namespace: "default", Status: "deleted", ManifestJSON: string(manifestJSON),
+			}}}
+			s.data = store
+			r := gin.New()
+			r.POST("/api/deploy/:deploy_id/redeploy", s.handleRedeployToK8s)
+
+			w := postJSON(r, "/api/deploy/old-1/redeploy", `{}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				DeployID string `json:"deploy_id"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.DeployID == "" || resp.DeployID == "old-1" {
+				t.Fatalf("expected a new deploy ID, got %q", resp.DeployID)
+			}
+
+			history := waitForHistory(t, store)
+			s.mu.RLock()
+			state := s.deployStates[resp.DeployID]
+			s.mu.RUnlock()
+			if state == nil {
+				t.Fatal("expected the redeploy to be tracked under its new ID")
+			}
+			waitForDeploy(t, s, state)
+			if got := s.deployStatusSnapshot(state).Status; got != tt.wantStatus {
+				t.Errorf("expected %s, got %q", tt.wantStatus, got)
+			}
+
+			tt.k8s.mu.Lock()
+			applied := append([]string{}, tt.k8s.applied...)
+			tt.k8s.mu.Unlock()
+			if len(applied) == 0 || !strings.Contains(applied[0], kubernetes.LabelDeployID+": "+resp.DeployID) || strings.Contains(applied[0], "old-1") {
+				t.Errorf("expected the manifests to be re-stamped with the new deploy ID, got %q", applied)
+			}
+			if history.ID != resp.DeployID || !strings.Contains(history.ManifestJSON, resp.DeployID) || strings.Contains(history.ManifestJSON, "old-1") {
+				t.Errorf("expected history under the new ID with re-stamped manifests, got %s %s", history.ID, history.ManifestJSON)
+			}
+			if tt.wantStatus == "failed" && len(tt.k8s.restored) != 2 {
+				t.Errorf("expected both applied manifests to be rolled back, got %+v", tt.k8s.restored)
+			}
+		})
+	}
+}
+
+func TestExecuteDeployAsync_SkipRollback(t *testing.T) {
+	k8s := &mockK8sService{applyErr: fmt.Errorf("admission webhook denied")}
+	s := setupTestServer(t)
+	s.kubernetes = k8s
+	s.deployStates["d1"] = newTestDeployState("d1")
+	s.deployStates["d1"].SkipRollback = true
+
+	s.executeDeployAsync("d1")
+
+	if len(k8s.restored) != 0 {
+		t.Fatalf("expected no restore with skip_rollback, got %+v", k8s.restored)
+	}
+	steps := s.deployStates["d1"].Status.Steps
+	if last := steps[len(steps)-1]; last.Step != "rollback" || last.Status != "skipped" {
+		t.Errorf("expected skipped rollback step, got %+v", last)
+	}
+}
+
+func TestExecuteDeployAsync_AppliesConfigBeforeWorkloads(t *testing.T) {
+	k8s := &mockK8sService{}
+	s := setupTestServer(t)
+	s.kubernetes = k8s
+	state := newTestDeployState("d1")
+	state.Manifests = &models.ManifestResult{Deployment: strategyManifests, Service: strategyService, HPA: costHPA, ConfigMap: configMapManifest}
+	s.deployStates["d1"] = state
+
+	s.executeDeployAsync("d1")
+
+	var kinds []string
+	for _, m := range k8s.applied {
+		kinds = append(kinds, kubernetes.ManifestKinds(m)...)
+	}
+	if got := strings.Join(kinds, ","); got != "ConfigMap,Deployment,Service,HorizontalPodAutoscaler" {
+		t.Errorf("expected config before workloads, got %s", got)
+	}
+	if state.Status.Status != "completed" || len(state.Status.Steps[1].Resources) != 2 {
+		t.Errorf("expected the ConfigMap on the create_deployment step, got %+v", state.Status)
+	}
+}
+
+func TestExecuteDeployAsync_ConfigMapFailureFailsStep(t *testing.T) {
+	k8s := &mockK8sService{applyErr: fmt.Errorf("configmaps is forbidden")}
+	s := setupTestServer(t)
+	s.kubernetes = k8s
+	state := newTestDeployState("d1")
+	state.Manifests.ConfigMap = configMapManifest
+	s.deployStates["d1"] = state
+
+	s.executeDeployAsync("d1")
+
+	step := state.Status.Steps[1]
+	if state.Status.Status != "failed" || step.Status != "failed" || !strings.HasPrefix(step.Message, "Failed: ConfigMap:") {
+		t.Fatalf("expected create_deployment to fail on the ConfigMap, got %+v", state.Status)
+	}
+	if len(k8s.applied) != 0 || state.Status.Steps[2].Status != "pending" {
+		t.Errorf("expected nothing applied after the ConfigMap failed, got %v", k8s.applied)
+	}
+}
+
+const configMapManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+  namespace: default
+data:
+  LOG_LEVEL: info
+`
+
+func TestExecuteStackDeployAsync_Rollback(t *testing.T) {
+	k8s := &mockK8sService{applyErr: fmt.Errorf("quota exceeded")}
+	s := setupTestServer(t)
+	s.kubernetes = k8s
+	manifests := map[string]map[string]string{
+		"Deployment": {"api": "api-deploy"},
+		"Service":    {"api": "api-svc"},
+	}
+	s.stackDeployStates["s1"] = &stackDeployState{
+		Status: &models.StackDeployStatus{DeployID: "s1", Status: "deploying", DeployOrder: []string{"api"},
+			Services: map[string]*models.ServiceDeployStatus{
+				"api": {ServiceName: "api", Status: "pending", Steps: buildServiceSteps("api", manifests)},
+			}},
+		Response:  &models.StackDeployResponse{DeployID: "s1"},
+		Request:   &models.StackDeployRequest{ClusterName: "test-cluster"},
+		Manifests: &ai.StackManifestResult{Manifests: manifests},
+	}
+
+	s.executeStackDeployAsync("s1")
+
+	if len(k8s.restored) != 1 || k8s.restored[0].Name != "api-deploy" {
+		t.Fatalf("expected the applied deployment to be reverted, got %+v", k8s.restored)
+	}
+	svc := s.stackDeployStates["s1"].Status.Services["api"]
+	if svc.Status != "rolled_back" {
+		t.Errorf("expected service status rolled_back, got %q", svc.Status)
+	}
+	if last := svc.Steps[len(svc.Steps)-1]; last.Step != "rollback:Object/api-deploy" {
+		t.Errorf("expected trailing rollback step, got %+v", last)
+	}
+}
+
+func TestExecuteStackDeployAsync_RollbackOnReadinessFailure(t *testing.T) {
+	k8s := &mockK8sService{
+		rollout:    &models.RolloutStatus{Kind: "Deployment", Name: "test", Replicas: 2, Failed: true},
+		rolloutErr: fmt.Errorf("pod api-abc: CrashLoopBackOff"),
+	}
+	s := setupTestServer(t)
+	s.kubernetes = k8s
+	manifests := map[string]map[string]string{
+		"Deployment": {"api": "api-deploy", "web": "web-deploy"},
+		"Service":    {"api": "api-svc"},
+	}
+	s.stackDeployStates["s1"] = &stackDeployState{
+		Status: &models.StackDeployStatus{DeployID: "s1", Status: "deploying", DeployOrder: []string{"api", "web"},
+			Services: map[string]*models.ServiceDeployStatus{
+				"api": {ServiceName: "api", Status: "pending", Steps: buildServiceSteps("api", manifests)},
+				"web": {ServiceName: "web", Status: "pending", Steps: buildServiceSteps("web", manifests)},
+			}},
+		Response:  &models.StackDeployResponse{DeployID: "s1"},
+		Request:   &models.StackDeployRequest{ClusterName: "test-cluster"},
+		Manifests: &ai.StackManifestResult{Manifests: manifests},
+	}
+
+	s.executeStackDeployAsync("s1")
+
+	state := s.stackDeployStates["s1"]
+	if state.Status.Status != "failed" || state.Status.Services["web"].Status != "skipped" {
+		t.Fatalf("expected the stack to stop at api, got %+v", state.Status)
+	}
+	if len(k8s.restored) != 2 || k8s.restored[0].Name != "api-svc" || k8s.restored[1].Name != "api-deploy" {
+		t.Fatalf("expected the applied objects of api to be reverted, got %+v", k8s.restored)
+	}
+	api := state.Status.Services["api"]
+	if api.Status != "rolled_back" || api.Steps[2].Step != "wait_ready" || api.Steps[2].Status != "failed" ||
+		!strings.Contains(api.Steps[2].Message, "CrashLoopBackOff") {
+		t.Errorf("expected a failed wait_ready step before the rollback, got %+v", api.Steps)
+	}
+}
+
+// newTestDeployState returns a single deploy ready for executeDeployAsync.
+func newTestDeployState(deployID string) *deployState {
+	return &deployState{
+		Status: &models.DeployStatus{DeployID: deployID, Status: "deploying", Steps: []models.DeployStep{
+			{Step: "push_image", Status: "pending"},
+			{Step: "create_deployment", Status: "pending"},
+			{Step: "create_service", Status: "pending"},
+			{Step: "wait_ready", Status: "pending"},
+		}},
+		Response:  &models.DeployResponse{DeployID: deployID},
+		Request:   &models.DeployRequest{ContainerID: "abc123", ClusterName: "test-cluster"},
+		Manifests: &models.ManifestResult{Deployment: "deploy-yaml", Service: "svc-yaml"},
+	}
+}
+
+// --- Deploy Preview Tests ---
+
+func TestPreviewDeploy(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{diffs: []models.ResourceDiff{
+		{Kind: "Deployment", Name: "web", Action: "change", Changes: []models.FieldChange{{Path: "spec.replicas", Before: 1, After: 2}}},
+	}}
+	s.deployStates["d1"] = &deployState{
+		Status:    &models.DeployStatus{DeployID: "d1", Status: "pending"},
+		Request:   &models.DeployRequest{ClusterName: "test-cluster"},
+		Manifests: &models.ManifestResult{Deployment: "deploy-yaml", Service: "svc-yaml"},
+	}
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/preview", s.handlePreviewDeploy)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/d1/preview", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.DeployPreview
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	// one diff per non-empty manifest (Deployment + Service)
+	if len(resp.Resources) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(resp.Resources))
+	}
+	if resp.Summary.Change != 2 || resp.ClusterName != "test-cluster" {
+		t.Errorf("unexpected preview: %+v", resp)
+	}
+}
+
+func TestPreviewDeploy_DecodeErrorReported(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{err: fmt.Errorf("decoding YAML: bad")}
+	s.deployStates["d1"] = &deployState{
+		Status:    &models.DeployStatus{DeployID: "d1", Status: "pending"},
+		Request:   &models.DeployRequest{ClusterName: "test-cluster"},
+		Manifests: &models.ManifestResult{Deployment: "deploy-yaml"},
+	}
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/preview", s.handlePreviewDeploy)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/d1/preview", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp models.DeployPreview
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Summary.Error != 1 || resp.Resources[0].Action != "error" {
+		t.Errorf("expected one error entry, got %+v", resp)
+	}
+}
+
+func TestPreviewDeploy_ListsManifestsInApplyOrder(t *testing.T) {
+	manifests := models.ManifestResult{Deployment: strategyManifests, Service: strategyService, HPA: costHPA, ConfigMap: configMapManifest}
+
+	// Decode errors carry the kind of each manifest, in the order preview lists them
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{err: fmt.Errorf("dry-run unavailable")}
+	preview := newTestDeployState("d1")
+	preview.Manifests = &manifests
+	s.deployStates["d1"] = preview
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/preview", s.handlePreviewDeploy)
+	w := postJSON(r, "/api/deploy/d1/preview", `{}`)
+	var resp models.DeployPreview
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	var previewed []string
+	for _, res := range resp.Resources {
+		previewed = append(previewed, res.Kind)
+	}
+
+	k8s := &mockK8sService{}
+	executed := setupTestServer(t)
+	executed.kubernetes = k8s
+	execute := newTestDeployState("d1")
+	execute.Manifests = &manifests
+	executed.deployStates["d1"] = execute
+	executed.executeDeployAsync("d1")
+	var applied []string
+	for _, m := range k8s.applied {
+		applied = append(applied, kubernetes.ManifestKinds(m)...)
+	}
+
+	if strings.Join(previewed, ",") != strings.Join(applied, ",") || len(applied) != 4 {
+		t.Errorf("expected preview order %v to match apply order %v", previewed, applied)
+	}
+}
+
+func TestPreviewDeploy_NotFound(t *testing.T) {
+	s := setupTestServer(t)
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/preview", s.handlePreviewDeploy)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/nonexistent/preview", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestPreviewStackDeploy(t *testing.T) {
+	s := setupTestServer(t)
+	s.stackDeployStates["s1"] = &stackDeployState{
+		Status:  &models.StackDeployStatus{DeployID: "s1"},
+		Request: &models.StackDeployRequest{ClusterName: "test-cluster"},
+		Manifests: &ai.StackManifestResult{Manifests: map[string]map[string]string{
+			"Service":    {"api": "svc-yaml"},
+			"Deployment": {"api": "deploy-yaml"},
+			"Namespace":  {"shop": "ns-yaml"},
+		}},
+	}
+
+	r := gin.New()
+	r.POST("/api/deploy/stack/:deploy_id/preview", s.handlePreviewStackDeploy)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/stack/s1/preview", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.DeployPreview
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Resources) != 3 {
+		t.Fatalf("expected 3 resources, got %d", len(resp.Resources))
+	}
+	// Namespace is previewed first, then workloads, then services
+	wantServices := []string{"shop", "api", "api"}
+	for i, want := range wantServices {
+		if resp.Resources[i].Service != want {
+			t.Errorf("resource %d: expected service %q, got %q", i, want, resp.Resources[i].Service)
+		}
+	}
+	if resp.Summary.Create != 3 {
+		t.Errorf("expected 3 creates, got %+v", resp.Summary)
+	}
+}
+
+func TestPreviewStackDeploy_NoCluster(t *testing.T) {
+	s := setupTestServer(t)
+	s.stackDeployStates["s1"] = &stackDeployState{
+		Status:  &models.StackDeployStatus{DeployID: "s1"},
+		Request: &models.StackDeployRequest{},
+	}
+
+	r := gin.New()
+	r.POST("/api/deploy/stack/:deploy_id/preview", s.handlePreviewStackDeploy)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/stack/s1/preview", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+// --- Ownership Tests ---
+
+func TestExecuteDeployAsync_StampsOwnership(t *testing.T) {
+	s := setupTestServer(t)
+	s.docker.(*mockDockerService).detail.ImageID = "sha256:0123abcd"
+	k8s := &mockK8sService{}
+	s.kubernetes = k8s
+	state := newTestDeployState("d1")
+	state.Manifests.Deployment = "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"
+	s.deployStates["d1"] = state
+
+	s.executeDeployAsync("d1")
+
+	if len(k8s.applied) == 0 {
+		t.Fatal("expected manifests to be applied")
+	}
+	for _, want := range []string{
+		"app.kubernetes.io/managed-by: hybrid-cloud-dashboard",
+		"hybrid-cloud-dashboard/deploy-id: d1",
+		"hybrid-cloud-dashboard/source-container: test-container",
+		"hybrid-cloud-dashboard/source-digest: sha256:0123abcd",
+	} {
+		if !strings.Contains(k8s.applied[0], want) {
+			t.Errorf("applied deployment missing %q:\n%s", want, k8s.applied[0])
+		}
+	}
+}
+
+func TestUndeployFromK8s_DeletesByLabel(t *testing.T) {
+	s := setupTestServer(t)
+	k8s := &mockK8sService{owned: []models.OwnedResource{
+		{Cluster: "test-cluster", Kind: "Deployment", Name: "web-v2", Namespace: "default", Owner: models.Ownership{DeployID: "d1"}},
+		{Cluster: "test-cluster", Kind: "Service", Name: "web-svc", Namespace: "default", Owner: models.Ownership{DeployID: "d1"}},
+		{Cluster: "test-cluster", Kind: "Deployment", Name: "other", Namespace: "default", Owner: models.Ownership{DeployID: "d2"}},
+	}}
+	s.kubernetes = k8s
+	s.data = &mockDataStore{history: []models.DeploymentHistory{
+		{ID: "d1", ServiceName: "abc123", TargetCluster: "test-cluster", Namespace: "default", Status: "deployed"},
+	}}
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/undeploy", s.handleUndeployFromK8s)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/d1/undeploy", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(k8s.deleted) != 1 || k8s.deleted[0].DeployID != "d1" {
+		t.Fatalf("expected delete by deploy-id d1, got %+v", k8s.deleted)
+	}
+
+	var resp struct {
+		Resources []models.AppliedResource `json:"resources"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Resources) != 2 {
+		t.Errorf("expected 2 deleted resources, got %+v", resp.Resources)
+	}
+}
+
+func TestScanOwnership(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{
+		clusters: []models.Cluster{{Name: "test-cluster", Status: "connected"}},
+		owned: []models.OwnedResource{
+			{Cluster: "test-cluster", Kind: "Deployment", Name: "live", Owner: models.Ownership{DeployID: "d-live"}},
+			{Cluster: "test-cluster", Kind: "Deployment", Name: "gone", Owner: models.Ownership{DeployID: "d-gone"}},
+			{Cluster: "test-cluster", Kind: "Service", Name: "undeployed", Owner: models.Ownership{DeployID: "d-deleted"}},
+			{Cluster: "test-cluster", Kind: "Deployment", Name: "api", Owner: models.Ownership{StackID: "s-live", Service: "api"}},
+			{Cluster: "test-cluster", Kind: "Deployment", Name: "running", Owner: models.Ownership{DeployID: "d-running"}},
+		},
+	}
+	s.data = &mockDataStore{
+		history: []models.DeploymentHistory{
+			{ID: "d-live", TargetCluster: "test-cluster", Status: "deployed"},
+			{ID: "d-deleted", TargetCluster: "test-cluster", Status: "deleted"},
+			{ID: "d-lost", ServiceName: "lost", TargetCluster: "test-cluster", Status: "deployed", ManifestJSON: labelledManifestJSON},
+			{ID: "d-legacy", ServiceName: "legacy", TargetCluster: "test-cluster", Status: "deployed", ManifestJSON: `{"deployment":"kind: Deployment"}`},
+			{ID: "s-live_api", TargetCluster: "test-cluster", Status: "deployed"},
+			{ID: "s-lost_db", TargetCluster: "test-cluster", Status: "deployed"},
+		},
+		stacks: []models.StackDeployRecord{
+			{DeployID: "s-live", ClusterName: "test-cluster", Status: "deployed"},
+			{DeployID: "s-lost", StackName: "lost-stack", ClusterName: "test-cluster", Status: "deployed", ManifestsJSON: labelledManifestJSON},
+			{DeployID: "s-legacy", StackName: "legacy-stack", ClusterName: "test-cluster", Status: "deployed"},
+		},
+	}
+	s.deployStates["d-running"] = &deployState{Status: &models.DeployStatus{DeployID: "d-running", Status: "deploying"}}
+
+	r := gin.New()
+	r.GET("/api/deploy/orphans", s.handleScanOwnership)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/deploy/orphans", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var scan models.OwnershipScan
+	if err := json.Unmarshal(w.Body.Bytes(), &scan); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	orphans := map[string]string{}
+	for _, o := range scan.Orphans {
+		orphans[o.Name] = o.Reason
+	}
+	if len(orphans) != 2 || orphans["gone"] != "record_missing" || orphans["undeployed"] != "record_deleted" {
+		t.Errorf("unexpected orphans: %+v", scan.Orphans)
+	}
+
+	if len(scan.Missing) != 2 {
+		t.Fatalf("expected 2 missing deploys, got %+v", scan.Missing)
+	}
+	for _, m := range scan.Missing {
+		if m.DeployID != "d-lost" && m.StackID != "s-lost" {
+			t.Errorf("unexpected missing entry %+v", m)
+		}
+	}
+
+	// Deploys from before the ownership labels have nothing to match on
+	if len(scan.Unlabelled) != 2 || scan.Unlabelled[0].StackID != "s-legacy" || scan.Unlabelled[1].DeployID != "d-legacy" {
+		t.Errorf("expected the legacy deploys to be unlabelled, got %+v", scan.Unlabelled)
+	}
+}
+
+// labelledManifestJSON is a stored manifest stamped with the ownership labels.
+const labelledManifestJSON = `{"deployment":"metadata:\n  labels:\n    hybrid-cloud-dashboard/deploy-id: d1\n"}`
+
+func TestUndeployFromK8s_UnlabelledNeedsConfirmation(t *testing.T) {
+	s := setupTestServer(t)
+	k8s := &mockK8sService{}
+	s.kubernetes = k8s
+	s.data = &mockDataStore{history: []models.DeploymentHistory{
+		{ID: "d1", ServiceName: "web", TargetCluster: "test-cluster", Namespace: "default", Status: "deployed"},
+		{ID: "d2", ServiceName: "api", TargetCluster: "test-cluster", Namespace: "default", Status: "deployed", ManifestJSON: labelledManifestJSON},
+	}}
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/undeploy", s.handleUndeployFromK8s)
+
+	w := postJSON(r, "/api/deploy/d1/undeploy", `{}`)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "UNLABELLED_RESOURCES") ||
+		!strings.Contains(w.Body.String(), "Deployment default/web") {
+		t.Fatalf("expected 409 listing the objects to delete by name, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(k8s.byName) != 0 {
+		t.Fatalf("expected nothing deleted without confirmation, got %v", k8s.byName)
+	}
+
+	w = postJSON(r, "/api/deploy/d1/undeploy?delete_by_name=true", `{}`)
+	if w.Code != http.StatusOK || strings.Join(k8s.byName, ",") != "Service/web,Deployment/web" {
+		t.Fatalf("expected the confirmed delete by name, got %d %v: %s", w.Code, k8s.byName, w.Body.String())
+	}
+
+	// A labelled deploy with nothing left on the cluster is not searched by name
+	k8s.byName = nil
+	if w := postJSON(r, "/api/deploy/d2/undeploy", `{}`); w.Code != http.StatusOK || len(k8s.byName) != 0 {
+		t.Errorf("expected no delete by name for a labelled deploy, got %d %v", w.Code, k8s.byName)
+	}
+}
+
+func TestUndeployStack_UnlabelledNeedsConfirmation(t *testing.T) {
+	s := setupTestServer(t)
+	k8s := &mockK8sService{}
+	s.kubernetes = k8s
+	s.stackDeployStates["s1"] = &stackDeployState{
+		Status:   &models.StackDeployStatus{DeployID: "s1", Status: "deployed", DeployOrder: []string{"db", "api"}},
+		Response: &models.StackDeployResponse{DeployID: "s1"},
+		Request:  &models.StackDeployRequest{ClusterName: "test-cluster", Namespace: "shop"},
+		Manifests: &ai.StackManifestResult{Manifests: map[string]map[string]string{
+			"Deployment": {"api": "api-deploy", "db": "db-deploy"},
+			"Service":    {"api": "api-svc"},
+			"ConfigMap":  {"api-config": "api-config"},
+		}},
+	}
+	r := gin.New()
+	r.POST("/api/deploy/stack/:deploy_id/undeploy", s.handleUndeployStack)
+
+	if w := postJSON(r, "/api/deploy/stack/s1/undeploy", `{}`); w.Code != http.StatusConflict || len(k8s.byName) != 0 {
+		t.Fatalf("expected 409 without deleting, got %d %v: %s", w.Code, k8s.byName, w.Body.String())
+	}
+	if s.stackDeployStates["s1"] == nil {
+		t.Fatal("expected the stack to stay deployed until confirmed")
+	}
+
+	w := postJSON(r, "/api/deploy/stack/s1/undeploy?delete_by_name=true", `{}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := strings.Join(k8s.byName, ","); got != "Service/api,Deployment/api,ConfigMap/api-config,Deployment/db" {
+		t.Errorf("expected services in reverse deploy order, dependents first, got %s", got)
+	}
+}
+
+// --- Detect Functions Tests ---
+
+func TestDetectServiceType(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"nginx:latest", "web-server"},
+		{"node:18-alpine", "web-application"},
+		{"postgres:15", "database"},
+		{"mysql:8.0", "database"},
+		{"redis:7", "database"},
+		{"rabbitmq:3-management", "message-queue"},
+		{"my-custom-app:v1", "application"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			info := struct {
+				Image string
+			}{Image: tt.image}
+			// Use the internal function via the package
+			_ = info // detectServiceType is tested via deploy handler
+		})
+	}
+}
+
+func TestCheckDeployDrift_ReportsDrifted(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{drifts: []models.ResourceDrift{
+		{Kind: "Deployment", Name: "web", Status: "drifted", Changes: []models.FieldChange{{Path: "spec.replicas", Before: "2", After: "5"}}},
+	}}
+	store := &mockDataStore{history: []models.DeploymentHistory{
+		{ID: "d1", ServiceName: "web", TargetCluster: "test-cluster", Status: "deployed", ManifestJSON: `{"deployment":"deploy-yaml"}`},
+	}}
+	s.data = store
+
+	r := gin.New()
+	r.GET("/api/deploy/:deploy_id/drift", s.handleCheckDeployDrift)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/deploy/d1/drift", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report models.DriftReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.Status != "drifted" || len(report.Resources) != 1 || report.Resources[0].Service != "web" {
+		t.Errorf("expected drifted report for web, got %+v", report)
+	}
+	if store.drift["d1"] != "drifted" {
+		t.Errorf("expected drift status persisted, got %q", store.drift["d1"])
+	}
+	if s.driftReport("d1") == nil {
+		t.Error("expected report kept for the drift list")
+	}
+}
+
+func TestReapplyDeployDrift(t *testing.T) {
+	s := setupTestServer(t)
+	k8s := &mockK8sService{}
+	s.kubernetes = k8s
+	s.data = &mockDataStore{history: []models.DeploymentHistory{
+		{ID: "d1", TargetCluster: "test-cluster", Status: "deployed", ManifestJSON: `{"deployment":"deploy-yaml","service":"svc-yaml"}`},
+	}}
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/drift/reapply", s.handleReapplyDeployDrift)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/d1/drift/reapply", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(k8s.applied) != 2 || k8s.applied[0] != "deploy-yaml" || k8s.applied[1] != "svc-yaml" {
+		t.Errorf("expected stored manifests re-applied in order, got %v", k8s.applied)
+	}
+}
+
+func TestAdoptDeployDrift_StoresLiveManifest(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{adopted: "live-yaml"}
+	store := &mockDataStore{history: []models.DeploymentHistory{
+		{ID: "d1", TargetCluster: "test-cluster", Status: "deployed", ManifestJSON: `{"deployment":"deploy-yaml"}`},
+	}}
+	s.data = store
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/drift/adopt", s.handleAdoptDeployDrift)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/d1/drift/adopt", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stored models.ManifestResult
+	json.Unmarshal([]byte(store.history[0].ManifestJSON), &stored)
+	if stored.Deployment != "live-yaml" {
+		t.Errorf("expected adopted manifest stored, got %+v", stored)
+	}
+}
+
+func TestDeployDrift_SkipsSupersededDeploys(t *testing.T) {
+	s := setupTestServer(t)
+	k8s := &mockK8sService{drifts: []models.ResourceDrift{{Kind: "Deployment", Name: "web", Status: "drifted"}}}
+	s.kubernetes = k8s
+	now := time.Now()
+	store := &mockDataStore{history: []models.DeploymentHistory{
+		{ID: "d2", ServiceName: "web", TargetCluster: "test-cluster", Status: "deployed", DeployedAt: now, ManifestJSON: `{"deployment":"deploy-v2"}`},
+		{ID: "d1", ServiceName: "web", TargetCluster: "test-cluster", Namespace: "default", Status: "deployed", DeployedAt: now.Add(-time.Hour), ManifestJSON: `{"deployment":"deploy-v1"}`},
+		{ID: "d3", ServiceName: "web", TargetCluster: "other-cluster", Status: "deployed", DeployedAt: now.Add(-time.Hour), ManifestJSON: `{"deployment":"deploy-v1"}`},
+	}}
+	s.data = store
+	s.driftReports = map[string]*models.DriftReport{"d1": {DeployID: "d1", Status: "drifted"}}
+
+	s.runDriftChecks(context.Background())
+	if _, ok := store.drift["d1"]; ok || store.drift["d2"] != "drifted" || store.drift["d3"] != "drifted" {
+		t.Errorf("expected only the latest deploy per cluster to be checked, got %v", store.drift)
+	}
+	if s.driftReport("d1") != nil {
+		t.Error("expected the report of the superseded deploy to be dropped")
+	}
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/drift/reapply", s.handleReapplyDeployDrift)
+	r.POST("/api/deploy/:deploy_id/drift/adopt", s.handleAdoptDeployDrift)
+	for _, action := range []string{"reapply", "adopt"} {
+		w := postJSON(r, "/api/deploy/d1/drift/"+action, `{}`)
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "SUPERSEDED") {
+			t.Errorf("%s: expected 409 SUPERSEDED, got %d: %s", action, w.Code, w.Body.String())
+		}
+	}
+	if len(k8s.applied) != 0 {
+		t.Errorf("expected nothing re-applied, got %v", k8s.applied)
+	}
+}
+
+func TestCheckDeployDrift_NotDeployed(t *testing.T) {
+	s := setupTestServer(t)
+	s.data = &mockDataStore{history: []models.DeploymentHistory{
+		{ID: "d1", Status: "deleted", ManifestJSON: `{"deployment":"deploy-yaml"}`},
+	}}
+
+	r := gin.New()
+	r.GET("/api/deploy/:deploy_id/drift", s.handleCheckDeployDrift)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/deploy/d1/drift", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+const exportTestDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+    app.kubernetes.io/managed-by: hybrid-cloud-dashboard
+    hybrid-cloud-dashboard/deploy-id: d1
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.25
+`
+
+func TestExportDeploy_HelmChart(t *testing.T) {
+	s := setupTestServer(t)
+	manifestJSON, _ := json.Marshal(models.ManifestResult{Deployment: exportTestDeployment})
+	s.data = &mockDataStore{history: []models.DeploymentHistory{
+		{ID: "d1", ServiceName: "web", Namespace: "prod", Status: "deployed", ManifestJSON: string(manifestJSON),
+			CPURequest: "250m", MemoryLimit: "256Mi", Replicas: 3},
+	}}
+
+	r := gin.New()
+	r.GET("/api/deploy/:deploy_id/export", s.handleExportDeploy)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/deploy/d1/export?output=json", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Name  string            `json:"name"`
+		Files map[string]string `json:"files"`
+		Lint  []interface{}     `json:"lint"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Name != "web" || len(resp.Lint) != 0 {
+		t.Fatalf("expected lint-clean chart named web, got %q %v", resp.Name, resp.Lint)
+	}
+	tpl := resp.Files["web/templates/web-deployment.yaml"]
+	if !strings.Contains(tpl, "{{ .Values.web.image | quote }}") || !strings.Contains(tpl, "{{ .Values.web.replicas }}") {
+		t.Errorf("expected parameterized image and replicas, got:\n%s", tpl)
+	}
+	if strings.Contains(tpl, "hybrid-cloud-dashboard") {
+		t.Errorf("expected ownership labels stripped, got:\n%s", tpl)
+	}
+	values := resp.Files["web/values.yaml"]
+	if !strings.Contains(values, "replicas: 3") || !strings.Contains(values, "cpu: 250m") || !strings.Contains(values, "namespace: prod") {
+		t.Errorf("expected values from recommendations, got:\n%s", values)
+	}
+}
+
+func TestExportDeploy_KustomizeArchive(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{clusters: []models.Cluster{{Name: "local"}, {Name: "aws-eks"}}}
+	manifestJSON, _ := json.Marshal(models.ManifestResult{Deployment: exportTestDeployment})
+	s.data = &mockDataStore{history: []models.DeploymentHistory{
+		{ID: "d1", ServiceName: "web", Namespace: "prod", Status: "deployed", ManifestJSON: string(manifestJSON)},
+	}}
+
+	r := gin.New()
+	r.GET("/api/deploy/:deploy_id/export", s.handleExportDeploy)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/deploy/d1/export?format=kustomize", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/gzip" {
+		t.Errorf("expected gzip archive, got %q", ct)
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("invalid gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	paths := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		paths[hdr.Name] = true
+	}
+	for _, p := range []string{"web/base/kustomization.yaml", "web/base/web-deployment.yaml", "web/overlays/local/kustomization.yaml", "web/overlays/aws-eks/kustomization.yaml"} {
+		if !paths[p] {
+			t.Errorf("expected %s in archive, got %v", p, paths)
+		}
+	}
+}
+
+func TestExportDeploy_InvalidFormat(t *testing.T) {
+	s := setupTestServer(t)
+	manifestJSON, _ := json.Marshal(models.ManifestResult{Deployment: exportTestDeployment})
+	s.data = &mockDataStore{history: []models.DeploymentHistory{{ID: "d1", ManifestJSON: string(manifestJSON)}}}
+
+	r := gin.New()
+	r.GET("/api/deploy/:deploy_id/export", s.handleExportDeploy)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/deploy/d1/export?format=zip", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+// --- GitOps Delivery Tests ---
+
+type mockGitopsService struct {
+	commits []gitops.CommitRequest
+	targets []models.GitDelivery
+	mrURL   string
+	err     error
+}
+
+func (m *mockGitopsService) Commit(ctx context.Context, target models.GitDelivery, req gitops.CommitRequest) (*models.GitCommit, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.commits = append(m.commits, req)
+	m.targets = append(m.targets, target)
+	branch := target.Branch
+	if target.MergeRequest != nil {
+		branch = gitops.DeployBranch(req.DeployID)
+	}
+	return &models.GitCommit{Repository: target.URL, Branch: branch, SHA: "0123456789abcdef0123456789abcdef01234567", Path: target.Path + "/" + req.Dir}, nil
+}
+func (m *mockGitopsService) OpenMergeRequest(ctx context.Context, target models.GitDelivery, commit *models.GitCommit, title, description string) (string, error) {
+	return m.mrURL, nil
+}
+
+// setupGitopsServer returns a test server whose "gitops-cluster" uses git delivery.
+func setupGitopsServer(t *testing.T, mr *models.MergeRequestSettings) (*Server, *mockGitopsService, *mockK8sService) {
+	t.Helper()
+	s := setupTestServer(t)
+	s.cfg.Clusters = append(s.cfg.Clusters, config.ClusterConfig{
+		Name: "gitops-cluster", Type: "kubernetes", Delivery: models.DeliveryGit,
+		Git: &models.GitDelivery{URL: "https://git.example.com/acme/manifests.git", MergeRequest: mr},
+	})
+	git := &mockGitopsService{mrURL: "https://git.example.com/acme/manifests/merge_requests/1"}
+	k8s := &mockK8sService{}
+	s.gitops = git
+	s.kubernetes = k8s
+	return s, git, k8s
+}
+
+func TestExecuteDeploy_GitDeliverySteps(t *testing.T) {
+	s, _, _ := setupGitopsServer(t, nil)
+	state := newTestDeployState("d1")
+	state.Status.Status = "pending"
+	state.Status.Steps = nil
+	state.Request.ClusterName = "gitops-cluster"
+	s.deployStates["d1"] = state
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/execute", s.handleExecuteDeploy)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/d1/execute", strings.NewReader(`{"approved": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.DeployStatus
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Steps) != 2 || resp.Steps[1].Step != "commit_manifests" {
+		t.Errorf("expected push_image and commit_manifests steps, got %+v", resp.Steps)
+	}
+
+	// let the async delivery finish before the test server goes away
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		done := state.Status.CompletedAt != nil
+		s.mu.RUnlock()
+		if done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExecuteGitDeployAsync_CommitsManifests(t *testing.T) {
+	s, git, k8s := setupGitopsServer(t, nil)
+	target := s.clusterDelivery(context.Background(), "gitops-cluster")
+	if target == nil {
+		t.Fatal("expected git delivery target")
+	}
+	state := newTestDeployState("d1")
+	state.Request.ClusterName = "gitops-cluster"
+	state.Status.Steps = gitDeploySteps(*target)
+	s.deployStates["d1"] = state
+
+	s.executeGitDeployAsync("d1", *target)
+
+	if state.Status.Status != "completed" {
+		t.Fatalf("expected completed, got %q (steps %+v)", state.Status.Status, state.Status.Steps)
+	}
+	if state.Status.Steps[1].Status != "completed" || !strings.HasPrefix(state.Status.Steps[1].Message, "Committed 0123456789ab to main") {
+		t.Errorf("unexpected commit step %+v", state.Status.Steps[1])
+	}
+	if len(k8s.applied) != 0 {
+		t.Errorf("git delivery must not apply to the cluster, applied %v", k8s.applied)
+	}
+	if len(git.commits) != 1 {
+		t.Fatalf("expected one commit, got %d", len(git.commits))
+	}
+	commit := git.commits[0]
+	if commit.DeployID != "d1" || commit.Dir != "default/abc123" {
+		t.Errorf("unexpected commit request %+v", commit)
+	}
+	if _, ok := commit.Files["deployment.yaml"]; !ok || len(commit.Files) != 2 {
+		t.Errorf("expected deployment.yaml and service.yaml, got %v", commit.Files)
+	}
+	if git.targets[0].Branch != "main" || git.targets[0].Path != "clusters/gitops-cluster" {
+		t.Errorf("expected defaulted target, got %+v", git.targets[0])
+	}
+	if state.Status.Commit == nil || state.Status.Commit.SHA == "" {
+		t.Fatalf("expected commit in status, got %+v", state.Status.Commit)
+	}
+	saved := s.data.(*mockDataStore).saved
+	if len(saved) != 1 || saved[0].CommitSHA != state.Status.Commit.SHA || !saved[0].Success {
+		t.Errorf("expected history with commit SHA, got %+v", saved)
+	}
+}
+
+func TestExecuteGitDeployAsync_OpensMergeRequest(t *testing.T) {
+	s, _, _ := setupGitopsServer(t, &models.MergeRequestSettings{Provider: "gitlab", Project: "acme/manifests"})
+	target := s.clusterDelivery(context.Background(), "gitops-cluster")
+	if target == nil {
+		t.Fatal("expected git delivery target")
+	}
+	state := newTestDeployState("d1")
+	state.Request.ClusterName = "gitops-cluster"
+	state.Status.Steps = gitDeploySteps(*target)
+	s.deployStates["d1"] = state
+
+	s.executeGitDeployAsync("d1", *target)
+
+	if len(state.Status.Steps) != 3 || state.Status.Steps[2].Step != "open_merge_request" || state.Status.Steps[2].Status != "completed" {
+		t.Fatalf("unexpected steps %+v", state.Status.Steps)
+	}
+	if state.Status.Commit.MergeRequestURL != "https://git.example.com/acme/manifests/merge_requests/1" {
+		t.Errorf("expected merge request URL, got %+v", state.Status.Commit)
+	}
+	if state.Status.Commit.Branch != "hybrid-cloud-dashboard/d1" {
+		t.Errorf("expected deploy branch, got %q", state.Status.Commit.Branch)
+	}
+}
+
+func TestExecuteGitDeployAsync_CommitFailure(t *testing.T) {
+	s, git, _ := setupGitopsServer(t, nil)
+	git.err = fmt.Errorf("git push: rejected")
+	state := newTestDeployState("d1")
+	state.Request.ClusterName = "gitops-cluster"
+	state.Status.Steps = gitDeploySteps(models.GitDelivery{})
+	s.deployStates["d1"] = state
+
+	s.executeGitDeployAsync("d1", *s.clusterDelivery(context.Background(), "gitops-cluster"))
+
+	if state.Status.Status != "failed" || state.Status.Steps[1].Status != "failed" {
+		t.Errorf("expected failed commit step, got %+v", state.Status)
+	}
+	if len(s.data.(*mockDataStore).saved) != 0 {
+		t.Error("a failed commit must not be recorded as a deploy")
+	}
+}
+
+func TestExecuteStackDeployAsync_GitDelivery(t *testing.T) {
+	s, git, k8s := setupGitopsServer(t, nil)
+	manifests := map[string]map[string]string{
+		"Deployment": {"api": "api-deploy", "db": "db-deploy"},
+		"Service":    {"api": "api-svc"},
+	}
+	s.stackDeployStates["s1"] = &stackDeployState{
+		Status: &models.StackDeployStatus{DeployID: "s1", Status: "deploying", StackName: "shop", DeployOrder: []string{"db", "api"},
+			Services: map[string]*models.ServiceDeployStatus{
+				"api": {ServiceName: "api", Status: "pending", Steps: buildServiceSteps("api", manifests)},
+				"db":  {ServiceName: "db", Status: "pending", Steps: buildServiceSteps("db", manifests)},
+			}},
+		Response:  &models.StackDeployResponse{DeployID: "s1"},
+		Request:   &models.StackDeployRequest{ClusterName: "gitops-cluster", Namespace: "shop"},
+		Manifests: &ai.StackManifestResult{Manifests: manifests},
+	}
+
+	s.executeStackDeployAsync("s1")
+
+	state := s.stackDeployStates["s1"]
+	if state.Status.Status != "deployed" || state.Status.Commit == nil {
+		t.Fatalf("expected deployed with commit, got %+v", state.Status)
+	}
+	if len(k8s.applied) != 0 {
+		t.Errorf("git delivery must not apply to the cluster, applied %v", k8s.applied)
+	}
+	if len(git.commits) != 1 || git.commits[0].Dir != "shop/shop" || len(git.commits[0].Files) != 3 {
+		t.Fatalf("expected one commit of three files, got %+v", git.commits)
+	}
+	if _, ok := git.commits[0].Files["api-deployment.yaml"]; !ok {
+		t.Errorf("expected api-deployment.yaml, got %v", git.commits[0].Files)
+	}
+	for _, name := range []string{"api", "db"} {
+		svc := state.Status.Services[name]
+		if svc.Status != "deployed" || !strings.HasPrefix(svc.Steps[0].Message, "Committed 0123456789ab") {
+			t.Errorf("unexpected %s status %+v", name, svc)
+		}
+	}
+}
+
+func TestRegisterCluster_GitDeliveryRequiresURL(t *testing.T) {
+	s := setupTestServer(t)
+	r := gin.New()
+	r.POST("/api/config/clusters", s.handleRegisterCluster)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/config/clusters", strings.NewReader(`{"name":"prod","context":"prod","delivery":"git"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRegisterCluster_RejectsOptionURL(t *testing.T) {
+	s := setupTestServer(t)
+	r := gin.New()
+	r.POST("/api/config/clusters", s.handleRegisterCluster)
+
+	w := postJSON(r, "/api/config/clusters", `{"name":"prod","context":"prod","delivery":"git","git":{"url":"--upload-pack=touch /tmp/x"}}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRegisterCluster_RejectsMergeRequestCredentials(t *testing.T) {
+	s := setupTestServer(t)
+	r := gin.New()
+	r.POST("/api/config/clusters", s.handleRegisterCluster)
+
+	for _, mr := range []string{`{"provider":"github","project":"a/b","token_env":"OPENAI_API_KEY"}`, `{"provider":"gitlab","project":"a/b","api_url":"https://evil.example.com"}`} {
+		w := postJSON(r, "/api/config/clusters", `{"name":"prod","context":"prod","delivery":"git","git":{"url":"https://git.example.com/m.git","merge_request":`+mr+`}}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for merge_request %s, got %d: %s", mr, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestClusterDelivery_StripsRegisteredMergeRequestCredentials(t *testing.T) {
+	s := setupTestServer(t)
+	s.data = &mockDataStore{registered: []models.RegisteredCluster{{
+		Name: "prod", Delivery: models.DeliveryGit,
+		Git: &models.GitDelivery{URL: "https://git.example.com/m.git", MergeRequest: &models.MergeRequestSettings{
+			Provider: "github", Project: "a/b", TokenEnv: "OPENAI_API_KEY", APIURL: "https://evil.example.com",
+		}},
+	}}}
+
+	target := s.clusterDelivery(context.Background(), "prod")
+	if target == nil || target.MergeRequest == nil {
+		t.Fatalf("expected a git target with merge requests, got %+v", target)
+	}
+	if target.MergeRequest.TokenEnv != "" || target.MergeRequest.APIURL != "" {
+		t.Errorf("expected the credentials of a registered cluster to be dropped, got %+v", target.MergeRequest)
+	}
+}
+
+// --- Multi-Cluster Fan-out Tests ---
+
+const fanOutDeploymentYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: web
+        image: registry.local:5000/web:1.0
+`
+
+// newFanOutDeployState returns an analyzed deploy targeting the given clusters.
+func newFanOutDeployState(deployID, strategy string, targets ...models.ClusterTarget) *deployState {
+	state := newTestDeployState(deployID)
+	state.Status.Status = "pending"
+	state.Status.Steps = nil
+	state.Request.ClusterName = ""
+	state.Request.Targets = targets
+	state.Request.Strategy = strategy
+	state.Manifests = &models.ManifestResult{Deployment: fanOutDeploymentYAML}
+	return state
+}
+
+func TestExecuteDeploy_FanOutChildResponses(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{}
+	s.data = &mockDataStore{}
+	s.cfg.Pricing = models.PricingConfig{
+		Default:  "on-prem",
+		Clusters: map[string]string{"cloud": "eks"},
+		Profiles: map[string]models.PricingProfile{"eks": {VCPUHour: 0.04}, "on-prem": {VCPUHour: 0.01}},
+	}
+	state := newFanOutDeployState("d1", "",
+		models.ClusterTarget{ClusterName: "onprem", ManifestOverrides: models.ManifestOverrides{Replicas: 3}},
+		models.ClusterTarget{ClusterName: "cloud"},
+	)
+	s.deployStates["d1"] = state
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/execute", s.handleExecuteDeploy)
+	if w := postJSON(r, "/api/deploy/d1/execute", `{"approved": true}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	waitForDeploy(t, s, state)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if state.Response.DeployID != "d1" {
+		t.Errorf("parent response must keep its ID, got %q", state.Response.DeployID)
+	}
+	for _, want := range []struct {
+		id, cluster, profile string
+		replicas             int
+	}{{"d1-1", "onprem", "on-prem", 3}, {"d1-2", "cloud", "eks", 2}} {
+		child := s.deployStates[want.id]
+		if child == nil || child.Response == state.Response {
+			t.Fatalf("%s: expected a response of its own", want.id)
+		}
+		resp := child.Response
+		if resp.DeployID != want.id {
+			t.Errorf("%s: response deploy ID %q", want.id, resp.DeployID)
+		}
+		if resp.EstimatedCost == nil || resp.EstimatedCost.Cluster != want.cluster || resp.EstimatedCost.Profile != want.profile {
+			t.Errorf("%s: expected the cost of %s under %s, got %+v", want.id, want.cluster, want.profile, resp.EstimatedCost)
+		}
+		if resp.Recommendations == nil || resp.Recommendations.Replicas != want.replicas {
+			t.Errorf("%s: expected %d replicas in the recommendations, got %+v", want.id, want.replicas, resp.Recommendations)
+		}
+	}
+}
+
+// waitForDeploy waits until the deploy has completed.
+func waitForDeploy(t *testing.T, s *Server, state *deployState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		done := state.Status.CompletedAt != nil
+		s.mu.RUnlock()
+		if done {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("deploy did not complete")
+}
+
+func TestExecuteDeploy_FanOutParallel(t *testing.T) {
+	s := setupTestServer(t)
+	k8s := &mockK8sService{}
+	s.kubernetes = k8s
+	store := &mockDataStore{}
+	s.data = store
+	state := newFanOutDeployState("d1", "",
+		models.ClusterTarget{ClusterName: "onprem", ManifestOverrides: models.ManifestOverrides{Replicas: 3}},
+		models.ClusterTarget{ClusterName: "cloud", ManifestOverrides: models.ManifestOverrides{Namespace: "edge", Registry: "harbor.cloud"}},
+	)
+	s.deployStates["d1"] = state
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/execute", s.handleExecuteDeploy)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/d1/execute", strings.NewReader(`{"approved": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.DeployStatus
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Clusters) != 2 || len(resp.Steps) != 1 || resp.Steps[0].Step != "wave_1" {
+		t.Fatalf("expected two nested clusters in one wave, got %+v", resp)
+	}
+
+	waitForDeploy(t, s, state)
+	if state.Status.Status != "completed" {
+		t.Fatalf("expected completed, got %q (steps %+v)", state.Status.Status, state.Status.Steps)
+	}
+	for _, cluster := range []string{"onprem", "cloud"} {
+		child := state.Status.Clusters[cluster]
+		if child == nil || child.Status != "completed" || child.ClusterName != cluster {
+			t.Errorf("unexpected %s status %+v", cluster, child)
+		}
+	}
+
+	k8s.mu.Lock()
+	applied := strings.Join(k8s.applied, "\n---\n")
+	k8s.mu.Unlock()
+	if !strings.Contains(applied, "replicas: 3") || !strings.Contains(applied, "namespace: edge") ||
+		!strings.Contains(applied, "image: harbor.cloud/web:1.0") {
+		t.Errorf("expected overridden manifests, got:\n%s", applied)
+	}
+
+	var parent *models.DeploymentHistory
+	children := 0
+	for _, h := range store.savedDeployments() {
+		h := h
+		switch {
+		case h.ID == "d1":
+			parent = &h
+		case h.ParentID == "d1":
+			children++
+			if h.ManifestJSON == "" {
+				t.Errorf("per-cluster entry %s must keep its manifests", h.ID)
+			}
+		}
+	}
+	if children != 2 {
+		t.Errorf("expected two per-cluster history entries, got %d", children)
+	}
+	if parent == nil || parent.FanOut == nil || parent.ManifestJSON != "" {
+		t.Fatalf("expected a parent entry linking the clusters, got %+v", parent)
+	}
+	if parent.FanOut.Strategy != models.FanOutParallel || len(parent.FanOut.Targets) != 2 ||
+		parent.FanOut.Targets[1].DeployID != "d1-2" || parent.FanOut.Targets[1].Status != "completed" {
+		t.Errorf("unexpected fan-out %+v", parent.FanOut)
+	}
+}
+
+func TestExecuteDeploy_FanOutParallelPartial(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{applyErrs: map[string]error{"cloud": fmt.Errorf("forbidden")}}
+	store := &mockDataStore{}
+	s.data = store
+	state := newFanOutDeployState("d1", models.FanOutParallel,
+		models.ClusterTarget{ClusterName: "onprem"},
+		models.ClusterTarget{ClusterName: "cloud"},
+	)
+	s.deployStates["d1"] = state
+
+	if err := s.startDeployFanOut(context.Background(), "d1", state, false); err != nil {
+		t.Fatalf("startDeployFanOut: %v", err)
+	}
+	waitForDeploy(t, s, state)
+
+	if state.Status.Status != "partial" {
+		t.Fatalf("expected partial, got %q", state.Status.Status)
+	}
+	if state.Status.Clusters["onprem"].Status != "completed" || state.Status.Clusters["cloud"].Status != "failed" {
+		t.Errorf("unexpected cluster statuses %+v / %+v", state.Status.Clusters["onprem"], state.Status.Clusters["cloud"])
+	}
+	if state.Status.Steps[0].Status != "failed" || !strings.Contains(state.Status.Steps[0].Message, "cloud") {
+		t.Errorf("unexpected wave step %+v", state.Status.Steps[0])
+	}
+}
+
+func TestExecuteDeploy_FanOutWavesStopOnFailure(t *testing.T) {
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{applyErrs: map[string]error{"staging": fmt.Errorf("quota exceeded")}}
+	store := &mockDataStore{}
+	s.data = store
+	state := newFanOutDeployState("d1", models.FanOutWaves,
+		models.ClusterTarget{ClusterName: "prod-a", Wave: 2},
+		models.ClusterTarget{ClusterName: "staging", Wave: 1},
+		models.ClusterTarget{ClusterName: "prod-b", Wave: 2},
+	)
+	s.deployStates["d1"] = state
+
+	if err := s.startDeployFanOut(context.Background(), "d1", state, false); err != nil {
+		t.Fatalf("startDeployFanOut: %v", err)
+	}
+	waitForDeploy(t, s, state)
+
+	if state.Status.Status != "failed" {
+		t.Fatalf("expected failed, got %q", state.Status.Status)
+	}
+	if len(state.Status.Steps) != 2 || state.Status.Steps[0].Step != "wave_1" || state.Status.Steps[0].Status != "failed" ||
+		state.Status.Steps[1].Status != "skipped" {
+		t.Errorf("unexpected wave steps %+v", state.Status.Steps)
+	}
+	for _, cluster := range []string{"prod-a", "prod-b"} {
+		if child := state.Status.Clusters[cluster]; child.Status != "skipped" || child.Wave != 2 {
+			t.Errorf("expected %s skipped in wave 2, got %+v", cluster, child)
+		}
+	}
+
+	var parent *models.DeploymentHistory
+	for _, h := range store.savedDeployments() {
+		if h.ID == "d1" {
+			h := h
+			parent = &h
+		}
+	}
+	if parent == nil || parent.Status != "failed" || parent.FanOut == nil || parent.FanOut.Targets[0].Status != "skipped" {
+		t.Errorf("unexpected parent entry %+v", parent)
+	}
+}
+
+func TestExecuteDeploy_FanOutRejectsBadOverride(t *testing.T) {
+	s := setupTestServer(t)
+	state := newFanOutDeployState("d1", "", models.ClusterTarget{ClusterName: "onprem", ManifestOverrides: models.ManifestOverrides{Replicas: 3}})
+	state.Manifests = &models.ManifestResult{Deployment: "not: [valid"}
+	s.deployStates["d1"] = state
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/execute", s.handleExecuteDeploy)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/d1/execute", strings.NewReader(`{"approved": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(s.deployStates) != 1 {
+		t.Errorf("a rejected fan-out must not create per-cluster deploys")
+	}
+}
+
+func TestDeployDockerToK8s_FanOutValidation(t *testing.T) {
+	s := setupTestServer(t)
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+
+	for name, body := range map[string]string{
+		"no cluster":        `{"container_id":"abc123"}`,
+		"duplicate cluster": `{"container_id":"abc123","targets":[{"cluster_name":"a"},{"cluster_name":"a"}]}`,
+		"unknown strategy":  `{"container_id":"abc123","targets":[{"cluster_name":"a"}],"strategy":"canary"}`,
+		"target w/o name":   `{"container_id":"abc123","targets":[{"namespace":"x"}]}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/deploy/docker-to-k8s", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestFanOutWaves(t *testing.T) {
+	targets := []models.ClusterTarget{{ClusterName: "a"}, {ClusterName: "b", Wave: 5}, {ClusterName: "c"}}
+	if waves := fanOutWaves(targets, models.FanOutParallel); len(waves) != 1 || len(waves[0].Targets) != 3 {
+		t.Errorf("parallel: expected one wave of three, got %+v", waves)
+	}
+	waves := fanOutWaves(targets, models.FanOutWaves)
+	if len(waves) != 3 || waves[0].Number != 1 || waves[1].Number != 3 || waves[2].Number != 5 || waves[2].Targets[0] != 1 {
+		t.Errorf("waves: unexpected grouping %+v", waves)
+	}
+}
+
+func TestExecuteStackDeploy_FanOut(t *testing.T) {
+	s := setupTestServer(t)
+	k8s := &mockK8sService{}
+	s.kubernetes = k8s
+	manifests := map[string]map[string]string{
+		"Deployment": {"api": fanOutDeploymentYAML},
+		"Namespace":  {"shop": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: shop\n"},
+	}
+	state := &stackDeployState{
+		Status: &models.StackDeployStatus{DeployID: "s1", Status: "pending", StackName: "shop", DeployOrder: []string{"_namespace", "api"},
+			Services: map[string]*models.ServiceDeployStatus{
+				"_namespace": {ServiceName: "_namespace", Status: "pending"},
+				"api":        {ServiceName: "api", Status: "pending"},
+			}},
+		Response: &models.StackDeployResponse{DeployID: "s1"},
+		Request: &models.StackDeployRequest{Namespace: "shop", Strategy: models.FanOutWaves, Targets: []models.ClusterTarget{
+			{ClusterName: "onprem"},
+			{ClusterName: "cloud", ManifestOverrides: models.ManifestOverrides{Namespace: "shop-cloud"}},
+		}},
+		Manifests: &ai.StackManifestResult{Manifests: manifests},
+	}
+	s.stackDeployStates["s1"] = state
+
+	r := gin.New()
+	r.POST("/api/deploy/stack/:deploy_id/execute", s.handleExecuteStackDeploy)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/deploy/stack/s1/execute", strings.NewReader(`{"approved": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 without cluster_name, got %d: %s", w.Code, w.Body.String())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		done := state.Status.CompletedAt != nil
+		s.mu.RUnlock()
+		if done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if state.Status.Status != "deployed" {
+		t.Fatalf("expected deployed, got %q", state.Status.Status)
+	}
+	cloud := s.stackDeployStates["s1-2"]
+	if cloud == nil || cloud.ParentID != "s1" || cloud.Request.ClusterName != "cloud" || cloud.Request.Namespace != "shop-cloud" {
+		t.Fatalf("unexpected cloud stack deploy %+v", cloud)
+	}
+	if _, ok := cloud.Manifests.Manifests["Namespace"]["shop-cloud"]; !ok {
+		t.Errorf("expected the Namespace object renamed, got %v", cloud.Manifests.Manifests["Namespace"])
+	}
+	if !strings.Contains(cloud.Manifests.Manifests["Deployment"]["api"], "namespace: shop-cloud") {
+		t.Errorf("expected the overridden namespace, got:\n%s", cloud.Manifests.Manifests["Deployment"]["api"])
+	}
+	if state.Status.Clusters["onprem"].Wave != 1 || state.Status.Clusters["cloud"].Wave != 2 {
+		t.Errorf("unexpected waves %+v", state.Status.Clusters)
+	}
+	// The source manifests stay untouched for the other clusters
+	if strings.Contains(manifests["Deployment"]["api"], "shop-cloud") {
+		t.Error("overrides must not modify the approved manifests")
+	}
+
+	record := s.stateToRecord(state)
+	if record.FanOut == nil || len(record.FanOut.Targets) != 2 || record.FanOut.Targets[1].DeployID != "s1-2" ||
+		record.FanOut.Targets[1].Status != "deployed" {
+		t.Errorf("unexpected fan-out record %+v", record.FanOut)
+	}
+}
+
+// --- Environment Promotion Tests ---
+
+var promotionEnvironments = []config.EnvironmentConfig{
+	{Name: "dev", Cluster: "test-cluster", Namespace: "dev"},
+	{Name: "staging", Cluster: "test-cluster", Namespace: "staging", Patch: models.ManifestOverrides{Replicas: 3}},
+	{Name: "prod", Cluster: "prod-cluster", Namespace: "prod", Patch: models.ManifestOverrides{Registry: "harbor.prod"}},
+}
+
+// setupPromotionServer returns a server with the dev → staging → prod pipeline, a
+// deployed deploy "d1" in dev and the promotion routes registered.
+func setupPromotionServer(t *testing.T) (*Server, *mockDataStore, *mockK8sService, *gin.Engine) {
+	t.Helper()
+	s := setupTestServer(t)
+	s.cfg.Environments = promotionEnvironments
+	k8s := &mockK8sService{}
+	s.kubernetes = k8s
+	manifestJSON, _ := json.Marshal(models.ManifestResult{Deployment: fanOutDeploymentYAML})
+	store := &mockDataStore{history: []models.DeploymentHistory{{
+		ID: "d1", ServiceName: "web", TargetCluster: "test-cluster", Namespace: "dev",
+		Status: "deployed", ManifestJSON: string(manifestJSON),
+	}}}
+	s.data = store
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/promote", s.handlePromoteDeploy)
+	r.GET("/api/promotions", s.handleListPromotions)
+	r.GET("/api/promotions/environments", s.handleListEnvironments)
+	r.GET("/api/promotions/:promotion_id", s.handleGetPromotion)
+	r.POST("/api/promotions/:promotion_id/approve", s.handleApprovePromotion)
+	r.POST("/api/promotions/:promotion_id/reject", s.handleRejectPromotion)
+	return s, store, k8s, r
+}
+
+func postJSON(r *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// waitForPromotion waits until the promotion has finished deploying.
+func waitForPromotion(t *testing.T, store *mockDataStore, id string) models.Promotion {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		p, err := store.GetPromotion(context.Background(), id)
+		if err == nil && p.Status != models.PromotionDeploying {
+			return *p
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("promotion did not finish")
+	return models.Promotion{}
+}
+
+func TestPromoteDeploy_ApprovalFlow(t *testing.T) {
+	_, store, k8s, r := setupPromotionServer(t)
+
+	w := postJSON(r, "/api/deploy/d1/promote", `{"requested_by": "alice", "comment": "ready for QA"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var p models.Promotion
+	json.Unmarshal(w.Body.Bytes(), &p)
+	if p.FromEnv != "dev" || p.ToEnv != "staging" || p.Status != models.PromotionPending ||
+		p.Namespace != "staging" || !strings.HasPrefix(p.ArtifactVersion, "sha256:") ||
+		len(p.Images) != 1 || p.Images[0] != "registry.local:5000/web:1.0" {
+		t.Fatalf("unexpected promotion %+v", p)
+	}
+	if len(k8s.applied) != 0 {
+		t.Fatal("expected nothing to be deployed before approval")
+	}
+
+	// The requester cannot approve their own promotion
+	if w := postJSON(r, "/api/promotions/"+p.ID+"/approve", `{"approver": "Alice"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for self-approval, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = postJSON(r, "/api/promotions/"+p.ID+"/approve", `{"approver": "bob", "comment": "lgtm"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &p)
+	if p.Status != models.PromotionDeploying || p.DeployID == "" || p.DecidedBy != "bob" {
+		t.Fatalf("unexpected approved promotion %+v", p)
+	}
+
+	done := waitForPromotion(t, store, p.ID)
+	if done.Status != models.PromotionCompleted || done.CompletedAt == nil {
+		t.Fatalf("expected completed promotion, got %+v", done)
+	}
+	k8s.mu.Lock()
+	applied := strings.Join(k8s.applied, "\n---\n")
+	k8s.mu.Unlock()
+	if !strings.Contains(applied, "namespace: staging") || !strings.Contains(applied, "replicas: 3") {
+		t.Errorf("expected staging patch applied, got:\n%s", applied)
+	}
+
+	var history *models.DeploymentHistory
+	for _, h := range store.savedDeployments() {
+		if h.ID == p.DeployID {
+			h := h
+			history = &h
+		}
+	}
+	if history == nil || history.Status == "failed" || history.Namespace != "staging" || history.ServiceName != "web" {
+		t.Fatalf("expected a history entry for the promoted deploy, got %+v", history)
+	}
+
+	// The promoted deploy moves on to prod with the version first promoted
+	history.Status = "deployed"
+	store.history = append(store.history, *history)
+	w = postJSON(r, "/api/deploy/"+p.DeployID+"/promote", `{"requested_by": "bob"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var next models.Promotion
+	json.Unmarshal(w.Body.Bytes(), &next)
+	if next.FromEnv != "staging" || next.ToEnv != "prod" || next.ClusterName != "prod-cluster" ||
+		next.ArtifactVersion != p.ArtifactVersion {
+		t.Fatalf("unexpected second-stage promotion %+v (first %+v)", next, p)
+	}
+
+	// The environment listing shows what runs in staging
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/promotions/environments", nil)
+	r.ServeHTTP(w, req)
+	var envs struct {
+		Environments []models.Environment `json:"environments"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &envs)
+	if len(envs.Environments) != 3 || envs.Environments[1].Current == nil ||
+		envs.Environments[1].Current.ArtifactVersion != p.ArtifactVersion || envs.Environments[2].Current != nil {
+		t.Errorf("unexpected environments %+v", envs.Environments)
+	}
+}
+
+func TestPromoteDeploy_Rejected(t *testing.T) {
+	_, store, k8s, r := setupPromotionServer(t)
+
+	w := postJSON(r, "/api/deploy/d1/promote", `{"requested_by": "alice"}`)
+	var p models.Promotion
+	json.Unmarshal(w.Body.Bytes(), &p)
+
+	// A pending promotion blocks a second request for the same deploy
+	if w := postJSON(r, "/api/deploy/d1/promote", `{"requested_by": "alice"}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a second promotion, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, body := range []string{`{}`, `{"approver": "  "}`} {
+		if w := postJSON(r, "/api/promotions/"+p.ID+"/reject", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 without approver for %s, got %d", body, w.Code)
+		}
+	}
+	if w := postJSON(r, "/api/promotions/"+p.ID+"/approve", `{"approver": " Alice "}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for the requester approving, got %d", w.Code)
+	}
+	if w := postJSON(r, "/api/promotions/"+p.ID+"/reject", `{"approver": "bob", "comment": "not yet"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := postJSON(r, "/api/promotions/"+p.ID+"/approve", `{"approver": "bob"}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 approving a rejected promotion, got %d", w.Code)
+	}
+
+	got, _ := store.GetPromotion(context.Background(), p.ID)
+	if got.Status != models.PromotionRejected || got.DecisionComment != "not yet" || len(k8s.applied) != 0 {
+		t.Errorf("unexpected rejected promotion %+v (applied %d)", got, len(k8s.applied))
+	}
+}
+
+func TestPromoteDeploy_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(s *Server, store *mockDataStore)
+		code    int
+		errCode string
+	}{
+		{"no environments", func(s *Server, _ *mockDataStore) { s.cfg.Environments = nil }, http.StatusBadRequest, "NO_ENVIRONMENTS"},
+		{"outside pipeline", func(_ *Server, store *mockDataStore) { store.history[0].TargetCluster = "other" }, http.StatusBadRequest, "UNKNOWN_ENVIRONMENT"},
+		{"last environment", func(_ *Server, store *mockDataStore) {
+			store.history[0].TargetCluster, store.history[0].Namespace = "prod-cluster", "prod"
+		}, http.StatusBadRequest, "FINAL_ENVIRONMENT"},
+		{"not deployed", func(_ *Server, store *mockDataStore) { store.history[0].Status = "undeployed" }, http.StatusBadRequest, "INVALID_STATE"},
+		{"fan-out parent", func(_ *Server, store *mockDataStore) { store.history[0].FanOut = &models.FanOut{} }, http.StatusBadRequest, "FANOUT_PARENT"},
+		{"no requester", nil, http.StatusBadRequest, "INVALID_REQUEST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store, _, r := setupPromotionServer(t)
+			body := `{}`
+			if tt.mutate != nil {
+				tt.mutate(s, store)
+				body = `{"requested_by": "alice"}`
+			}
+			w := postJSON(r, "/api/deploy/d1/promote", body)
+			var resp models.ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if w.Code != tt.code || resp.Error.Code != tt.errCode {
+				t.Errorf("expected %d %s, got %d: %s", tt.code, tt.errCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPromoteStack_FanOutParent(t *testing.T) {
+	s, store, _, r := setupPromotionServer(t)
+	store.stacks = []models.StackDeployRecord{{DeployID: "s1", ClusterName: "test-cluster", Status: "deployed", ManifestsJSON: `{}`, FanOut: &models.FanOut{}}}
+	r.POST("/api/deploy/stack/:deploy_id/promote", s.handlePromoteStack)
+
+	w := postJSON(r, "/api/deploy/stack/s1/promote", `{"requested_by": "alice"}`)
+	var resp models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusBadRequest || resp.Error.Code != "FANOUT_PARENT" {
+		t.Errorf("expected 400 FANOUT_PARENT like a single deploy, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestApprovePromotion_RequiresRequester(t *testing.T) {
+	_, store, k8s, r := setupPromotionServer(t)
+	store.promotions = append(store.promotions, models.Promotion{ID: "p1", Kind: models.PromotionDeploy, SourceID: "d1", Status: models.PromotionPending})
+
+	if w := postJSON(r, "/api/promotions/p1/approve", `{"approver": "bob"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a promotion without a requester, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(k8s.applied) != 0 {
+		t.Errorf("expected nothing deployed, got %v", k8s.applied)
+	}
+}
+
+func TestApprovePromotion_FailedDeploy(t *testing.T) {
+	_, store, k8s, r := setupPromotionServer(t)
+	k8s.applyErr = fmt.Errorf("admission denied")
+
+	w := postJSON(r, "/api/deploy/d1/promote", `{"requested_by": "alice"}`)
+	var p models.Promotion
+	json.Unmarshal(w.Body.Bytes(), &p)
+	if w := postJSON(r, "/api/promotions/"+p.ID+"/approve", `{"approver": "bob"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	done := waitForPromotion(t, store, p.ID)
+	if done.Status != models.PromotionFailed || done.Error == "" {
+		t.Errorf("expected failed promotion, got %+v", done)
+	}
+}
+
+// --- Deploy Strategy Tests ---
+
+const strategyManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 4
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: registry.local:5000/web:2.0
+`
+
+const strategyService = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: default
+spec:
+  selector:
+    app: web
+  ports:
+  - port: 80
+`
+
+func setupStrategyServer(t *testing.T) (*Server, *mockK8sService, *mockDataStore, *deployState, *gin.Engine) {
+	t.Helper()
+	s := setupTestServer(t)
+	k8s := &mockK8sService{}
+	s.kubernetes = k8s
+	store := &mockDataStore{}
+	s.data = store
+	state := newTestDeployState("d1")
+	state.Status.Status = "pending"
+	state.Status.Steps = nil
+	state.Manifests = &models.ManifestResult{Deployment: strategyManifests, Service: strategyService}
+	s.deployStates["d1"] = state
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/execute", s.handleExecuteDeploy)
+	r.POST("/api/deploy/:deploy_id/rollout/promote", s.handlePromoteRollout)
+	r.POST("/api/deploy/:deploy_id/rollout/abort", s.handleAbortRollout)
+	return s, k8s, store, state, r
+}
+
+// waitForRolloutPhase waits until the rollout of the deploy reaches the phase.
+func waitForRolloutPhase(t *testing.T, s *Server, state *deployState, phase string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		current := state.Status.Rollout.Phase
+		s.mu.RUnlock()
+		if current == phase {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("rollout did not reach %s", phase)
+}
+
+// waitForHistory waits until the deploy history has been saved.
+func waitForHistory(t *testing.T, store *mockDataStore) models.DeploymentHistory {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if saved := store.savedDeployments(); len(saved) > 0 {
+			if len(saved) != 1 {
+				t.Fatalf("expected one history record, got %d", len(saved))
+			}
+			return saved[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("deploy history was not saved")
+	return models.DeploymentHistory{}
+}
+
+func TestExecuteDeploy_BlueGreenPromote(t *testing.T) {
+	s, k8s, store, state, r := setupStrategyServer(t)
+
+	w := postJSON(r, "/api/deploy/d1/execute", `{"approved": true, "strategy": {"type": "blue_green"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	waitForRolloutPhase(t, s, state, models.RolloutAwaitingPromotion)
+
+	s.mu.RLock()
+	status, slot := state.Status.Status, state.Status.Rollout.ActiveSlot
+	s.mu.RUnlock()
+	if status != "awaiting_promotion" || slot != "blue" {
+		t.Fatalf("expected blue slot awaiting promotion, got %q on %q", status, slot)
+	}
+	k8s.mu.Lock()
+	applied := strings.Join(k8s.applied, "\n---\n")
+	k8s.mu.Unlock()
+	if !strings.Contains(applied, "name: web-blue") || !strings.Contains(applied, "hybrid-cloud-dashboard/slot: blue") {
+		t.Errorf("expected blue slot and selector to be applied, got:\n%s", applied)
+	}
+
+	w = postJSON(r, "/api/deploy/d1/rollout/promote", `{}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if history := waitForHistory(t, store); !history.Success {
+		t.Errorf("expected successful history, got %+v", history)
+	}
+	if state.Status.Status != "completed" || state.Status.Rollout.Phase != models.RolloutPromoted {
+		t.Fatalf("expected promoted rollout, got %q (%+v)", state.Status.Status, state.Status.Rollout)
+	}
+	if !strings.Contains(state.Manifests.Deployment, "name: web-blue") {
+		t.Errorf("expected live manifests to hold the blue slot, got:\n%s", state.Manifests.Deployment)
+	}
+
+	w = postJSON(r, "/api/deploy/d1/rollout/abort", `{}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 after promotion, got %d", w.Code)
+	}
+}
+
+func TestExecuteDeploy_BlueGreenAbort(t *testing.T) {
+	s, k8s, _, state, r := setupStrategyServer(t)
+	// The Service routes to blue, so the new version goes to green
+	k8s.services = []models.Service{{Name: "web", Selector: map[string]string{"app": "web", "hybrid-cloud-dashboard/slot": "blue"}}}
+
+	postJSON(r, "/api/deploy/d1/execute", `{"approved": true, "strategy": {"type": "blue_green"}}`)
+	waitForRolloutPhase(t, s, state, models.RolloutAwaitingPromotion)
+
+	w := postJSON(r, "/api/deploy/d1/rollout/abort", `{}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	waitForDeploy(t, s, state)
+	if state.Status.Status != "aborted" || state.Status.Rollout.ActiveSlot != "blue" {
+		t.Fatalf("expected rollout aborted back to blue, got %q (%+v)", state.Status.Status, state.Status.Rollout)
+	}
+
+	k8s.mu.Lock()
+	defer k8s.mu.Unlock()
+	if len(k8s.removed) != 1 || k8s.removed[0] != "web-green" {
+		t.Errorf("expected green slot to be removed, got %v", k8s.removed)
+	}
+	last := k8s.applied[len(k8s.applied)-1]
+	if !strings.Contains(last, "hybrid-cloud-dashboard/slot: blue") {
+		t.Errorf("expected Service switched back to blue, got:\n%s", last)
+	}
+}
+
+func TestExecuteDeploy_CanaryPromote(t *testing.T) {
+	defer func(d time.Duration) { canaryStepInterval = d }(canaryStepInterval)
+	canaryStepInterval = 10 * time.Millisecond
+
+	s, k8s, store, state, r := setupStrategyServer(t)
+	k8s.deployments = []models.Deployment{{Name: "web", Replicas: 4}}
+
+	postJSON(r, "/api/deploy/d1/execute", `{"approved": true, "strategy": {"type": "canary", "canary_steps": [25, 50]}}`)
+	waitForRolloutPhase(t, s, state, models.RolloutAwaitingPromotion)
+
+	s.mu.RLock()
+	rollout := *state.Status.Rollout
+	s.mu.RUnlock()
+	if rollout.CanaryWeight != 50 || rollout.CanaryReplicas != 2 || rollout.StableReplicas != 2 {
+		t.Errorf("expected 2/2 replicas at 50%%, got %+v", rollout)
+	}
+
+	postJSON(r, "/api/deploy/d1/rollout/promote", `{}`)
+	if history := waitForHistory(t, store); !history.Success {
+		t.Errorf("expected successful history, got %+v", history)
+	}
+	if state.Status.Status != "completed" {
+		t.Fatalf("expected completed, got %q (steps %+v)", state.Status.Status, state.Status.Steps)
+	}
+
+	k8s.mu.Lock()
+	defer k8s.mu.Unlock()
+	if strings.Join(k8s.scaled, ",") != "web-canary=1,web=3,web-canary=2,web=2" {
+		t.Errorf("unexpected scaling %v", k8s.scaled)
+	}
+	if len(k8s.removed) != 1 || k8s.removed[0] != "web-canary" {
+		t.Errorf("expected canary to be removed, got %v", k8s.removed)
+	}
+	if last := k8s.applied[len(k8s.applied)-1]; !strings.Contains(last, "name: web\n") {
+		t.Errorf("expected new template applied to the stable Deployment, got:\n%s", last)
+	}
+}
+
+func TestExecuteDeploy_CanaryHealthGate(t *testing.T) {
+	defer func(d time.Duration) { canaryStepInterval = d }(canaryStepInterval)
+	canaryStepInterval = 10 * time.Millisecond
+
+	_, k8s, store, state, r := setupStrategyServer(t)
+	k8s.deployments = []models.Deployment{{Name: "web", Replicas: 4}}
+	k8s.pods = []models.Pod{{Name: "web-canary-1", Containers: []models.PodContainer{{Name: "web", Ready: true, RestartCount: 3}}}}
+
+	postJSON(r, "/api/deploy/d1/execute", `{"approved": true, "strategy": {"type": "canary", "canary_steps": [25], "max_restarts": 1}}`)
+	if history := waitForHistory(t, store); history.Success {
+		t.Errorf("expected failed history, got %+v", history)
+	}
+	if state.Status.Status != "failed" || state.Status.Rollout.Phase != models.RolloutFailed {
+		t.Fatalf("expected failed rollout, got %q (%+v)", state.Status.Status, state.Status.Rollout)
+	}
+	for _, step := range state.Status.Steps {
+		if step.Step == "canary_25" && (step.Status != "failed" || !strings.Contains(step.Message, "restarted 3 times")) {
+			t.Errorf("expected failed health gate, got %+v", step)
+		}
+	}
+
+	k8s.mu.Lock()
+	defer k8s.mu.Unlock()
+	if k8s.scaled[len(k8s.scaled)-1] != "web=4" || len(k8s.removed) != 1 {
+		t.Errorf("expected stable replicas restored and canary removed, got %v / %v", k8s.scaled, k8s.removed)
+	}
+}
+
+func TestExecuteDeploy_InvalidStrategy(t *testing.T) {
+	_, _, _, state, r := setupStrategyServer(t)
+
+	for _, body := range []string{
+		`{"approved": true, "strategy": {"type": "shadow"}}`,
+		`{"approved": true, "strategy": {"type": "canary", "canary_steps": [50, 20]}}`,
+	} {
+		w := postJSON(r, "/api/deploy/d1/execute", body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_STRATEGY") {
+			t.Errorf("%s: expected 400 INVALID_STRATEGY, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+
+	state.Request.Targets = []models.ClusterTarget{{ClusterName: "onprem"}}
+	w := postJSON(r, "/api/deploy/d1/execute", `{"approved": true, "strategy": {"type": "blue_green"}}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for multi-cluster deploy, got %d", w.Code)
+	}
+
+	w = postJSON(r, "/api/deploy/d1/rollout/promote", `{}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "NO_ROLLOUT") {
+		t.Errorf("expected 400 NO_ROLLOUT, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// --- Manifest Edit Tests ---
+
+func setupEditServer(t *testing.T) (*Server, *deployState, *gin.Engine) {
+	t.Helper()
+	s := setupTestServer(t)
+	s.kubernetes = &mockK8sService{}
+	s.data = &mockDataStore{}
+	state := newTestDeployState("d1")
+	state.Status.Status = "pending"
+	state.Status.Steps = nil
+	state.Manifests = &models.ManifestResult{Deployment: strategyManifests, Service: strategyService}
+	state.Response.ManifestVersion = s.recordRevision(context.Background(), "d1", "single", models.RevisionGenerate, "", 0, state.Manifests)
+	s.deployStates["d1"] = state
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/edit", s.handleEditDeploy)
+	r.POST("/api/deploy/:deploy_id/execute", s.handleExecuteDeploy)
+	return s, state, r
+}
+
+func TestEditDeploy_Patches(t *testing.T) {
+	_, state, r := setupEditServer(t)
+
+	body, _ := json.Marshal(models.EditManifestRequest{Edits: []models.ManifestEdit{
+		{Resource: "deployment", Patch: `{"spec":{"template":{"spec":{"containers":[{"name":"web","resources":{"limits":{"memory":"1Gi"}}}]}}}}`},
+		{Resource: "deployment", Type: models.EditJSONPatch, Patch: `[{"op":"replace","path":"/spec/replicas","value":2}]`},
+		{Resource: "service", Type: models.EditMergePatch, Patch: "spec:\n  type: NodePort\n"},
+	}})
+	w := postJSON(r, "/api/deploy/d1/edit", string(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.ManifestVersion != 2 {
+		t.Errorf("expected manifest version 2, got %d", resp.ManifestVersion)
+	}
+	d := state.Manifests.Deployment
+	// The strategic merge keeps the container's image while adding the limit
+	for _, want := range []string{"memory: 1Gi", "image: registry.local:5000/web:2.0", "replicas: 2"} {
+		if !strings.Contains(d, want) {
+			t.Errorf("expected %q in edited Deployment:\n%s", want, d)
+		}
+	}
+	if !strings.Contains(state.Manifests.Service, "type: NodePort") || !strings.Contains(resp.Manifests.Service, "type: NodePort") {
+		t.Errorf("expected merged Service, got:\n%s", state.Manifests.Service)
+	}
+	if rec := resp.Recommendations; rec == nil || rec.Replicas != 2 || rec.MemoryLimit != "1Gi" {
+		t.Errorf("expected the recommendations of the edited Deployment, got %+v", rec)
+	}
+}
+
+func TestEditDeploy_Invalid(t *testing.T) {
+	_, state, r := setupEditServer(t)
+
+	for _, edit := range []models.ManifestEdit{
+		{Resource: "deployment", YAML: strings.Replace(strategyManifests, "replicas: 4", "replica: 4", 1)},
+		{Resource: "deployment", YAML: strings.Replace(strategyManifests, "replicas: 4", "replicas: four", 1)},
+		{Resource: "deployment", YAML: strategyService},
+		{Resource: "service", YAML: ""},
+		{Resource: "hpa", Type: models.EditMergePatch, Patch: `{"spec":{}}`},
+		{Resource: "deployment", Type: models.EditJSONPatch, Patch: `[{"op":"remove","path":"/spec/missing"}]`},
+		{Resource: "ingress", YAML: strategyService},
+	} {
+		body, _ := json.Marshal(models.EditManifestRequest{Edits: []models.ManifestEdit{edit}})
+		w := postJSON(r, "/api/deploy/d1/edit", string(body))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_MODIFICATION") {
+			t.Errorf("%+v: expected 400 INVALID_MODIFICATION, got %d: %s", edit, w.Code, w.Body.String())
+		}
+	}
+	if state.Manifests.Deployment != strategyManifests || state.Response.ManifestVersion != 1 {
+		t.Error("expected failed edits to leave the manifests unchanged")
+	}
+
+	state.Status.Status = "deploying"
+	w := postJSON(r, "/api/deploy/d1/edit", `{"modifications": {"service": "x"}}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while deploying, got %d", w.Code)
+	}
+}
+
+func TestExecuteDeploy_Modifications(t *testing.T) {
+	s, state, r := setupEditServer(t)
+	k8s := s.kubernetes.(*mockK8sService)
+	nodePort := strings.Replace(strategyService, "spec:\n", "spec:\n  type: NodePort\n", 1)
+
+	w := postJSON(r, "/api/deploy/d1/execute", `{"approved": true, "modifications": {"service": "nope: ["}}`)
+	if w.Code != http.StatusBadRequest || state.Status.Status != "pending" {
+		t.Fatalf("expected 400 without starting the deploy, got %d (%s)", w.Code, state.Status.Status)
+	}
+
+	body, _ := json.Marshal(models.ExecuteRequest{Approved: true, Modifications: map[string]string{"service": nodePort}})
+	w = postJSON(r, "/api/deploy/d1/execute", string(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	waitForDeploy(t, s, state)
+
+	k8s.mu.Lock()
+	defer k8s.mu.Unlock()
+	if !strings.Contains(strings.Join(k8s.applied, "\n---\n"), "type: NodePort") {
+		t.Errorf("expected the modified Service to be applied, got %v", k8s.applied)
+	}
+}
+
+// --- Manifest Revision Tests ---
+
+func getJSON(r *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestDeployRevisions_DiffAndRevert(t *testing.T) {
+	s, state, r := setupEditServer(t)
+	r.GET("/api/deploy/:deploy_id/revisions", s.handleListDeployRevisions)
+	r.GET("/api/deploy/:deploy_id/revisions/diff", s.handleDiffDeployRevisions)
+	r.GET("/api/deploy/:deploy_id/revisions/:version", s.handleGetDeployRevision)
+	r.POST("/api/deploy/:deploy_id/revisions/:version/revert", s.handleRevertDeployRevision)
+
+	w := postJSON(r, "/api/deploy/d1/edit", `{"edits": [{"resource": "deployment", "type": "json", "patch": "[{\"op\": \"replace\", \"path\": \"/spec/replicas\", \"value\": 2}]"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("edit: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = getJSON(r, "/api/deploy/d1/revisions")
+	var list struct {
+		Current   int                       `json:"current"`
+		Revisions []models.ManifestRevision `json:"revisions"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Current != 2 || len(list.Revisions) != 2 ||
+		list.Revisions[0].Source != models.RevisionGenerate || list.Revisions[1].Source != models.RevisionEdit {
+		t.Fatalf("unexpected revisions %s", w.Body.String())
+	}
+
+	// Without from/to the latest revision is compared with the one before
+	w = getJSON(r, "/api/deploy/d1/revisions/diff")
+	var diff models.ManifestRevisionDiff
+	json.Unmarshal(w.Body.Bytes(), &diff)
+	if diff.From != 1 || diff.To != 2 || len(diff.Resources) != 2 {
+		t.Fatalf("unexpected diff %s", w.Body.String())
+	}
+	for _, res := range diff.Resources {
+		switch res.Kind {
+		case "Deployment":
+			if res.Action != "change" || len(res.Changes) != 1 || res.Changes[0].Path != "spec.replicas" {
+				t.Errorf("expected only spec.replicas to change, got %+v", res)
+			}
+		case "Service":
+			if res.Action != "unchanged" {
+				t.Errorf("expected unchanged Service, got %+v", res)
+			}
+		}
+	}
+
+	w = getJSON(r, "/api/deploy/d1/revisions/1")
+	var rev models.ManifestRevision
+	json.Unmarshal(w.Body.Bytes(), &rev)
+	if rev.Documents["deployment"] != strategyManifests || rev.Documents["service"] != strategyService {
+		t.Errorf("unexpected revision documents %+v", rev.Documents)
+	}
+
+	w = postJSON(r, "/api/deploy/d1/revisions/1/revert", `{}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("revert: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.ManifestVersion != 3 || state.Manifests.Deployment != strategyManifests {
+		t.Errorf("expected revision 1 restored as version 3, got %d:\n%s", resp.ManifestVersion, state.Manifests.Deployment)
+	}
+	revs, _ := s.data.ListManifestRevisions(context.Background(), "d1")
+	if last := revs[len(revs)-1]; last.Source != models.RevisionRevert || last.RevertedFrom != 1 {
+		t.Errorf("expected a revert revision, got %+v", last)
+	}
+
+	if w := getJSON(r, "/api/deploy/d1/revisions/9"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing revision, got %d", w.Code)
+	}
+	if w := getJSON(r, "/api/deploy/d1/revisions/diff?from=x"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid version, got %d", w.Code)
+	}
+	if w := getJSON(r, "/api/deploy/d2/revisions"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deploy without revisions, got %d", w.Code)
+	}
+	state.Status.Status = "completed"
+	if w := postJSON(r, "/api/deploy/d1/revisions/2/revert", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 after execute, got %d", w.Code)
+	}
+}
+
+func TestStackRevisions_RefineAndRevert(t *testing.T) {
+	s := setupTestServer(t)
+	s.data = &mockDataStore{}
+	original := &ai.StackManifestResult{
+		Topology: models.StackTopology{DeployOrder: []string{"api"}},
+		Manifests: map[string]map[string]string{
+			"Deployment": {"api": strategyManifests},
+			"Service":    {"api": strategyService},
+		},
+		Reasoning: "generated",
+	}
+	state := &stackDeployState{
+		Status:    &models.StackDeployStatus{DeployID: "s1", Status: "pending"},
+		Response:  &models.StackDeployResponse{DeployID: "s1", Status: "analyzing"},
+		Request:   &models.StackDeployRequest{},
+		Manifests: original,
+	}
+	state.Response.ManifestVersion = s.recordRevision(context.Background(), "s1", "stack", generatedSource(original.Reasoning), "", 0, original)
+	s.stackDeployStates["s1"] = state
+	s.ai = &stackRefineAI{}
+
+	r := gin.New()
+	r.POST("/api/deploy/stack/:deploy_id/refine", s.handleRefineStackDeploy)
+	r.GET("/api/deploy/stack/:deploy_id/revisions/diff", s.handleDiffStackRevisions)
+	r.POST("/api/deploy/stack/:deploy_id/revisions/:version/revert", s.handleRevertStackRevision)
+
+	w := postJSON(r, "/api/deploy/stack/s1/refine", `{"feedback": "drop the service"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refine: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	revs, _ := s.data.ListManifestRevisions(context.Background(), "s1")
+	if len(revs) != 2 || revs[1].Source != models.RevisionRefine || revs[1].Feedback != "drop the service" {
+		t.Fatalf("expected a refine revision with the feedback, got %+v", revs)
+	}
+
+	w = getJSON(r, "/api/deploy/stack/s1/revisions/diff?from=1&to=2")
+	var diff models.ManifestRevisionDiff
+	json.Unmarshal(w.Body.Bytes(), &diff)
+	var deleted bool
+	for _, res := range diff.Resources {
+		if res.Kind == "Service" && res.Action == "delete" && res.Service == "api" {
+			deleted = true
+		}
+	}
+	if !deleted {
+		t.Errorf("expected the Service of api to be deleted, got %s", w.Body.String())
+	}
+
+	w = postJSON(r, "/api/deploy/stack/s1/revisions/1/revert", `{}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("revert: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if state.Response.ManifestVersion != 3 || state.Response.Manifests["Service"]["api"] != strategyService || state.Response.Reasoning != "generated" {
+		t.Errorf("expected revision 1 restored as version 3, got %+v", state.Response)
+	}
+}
+
+// stackRefineAI drops every Service on refine.
+type stackRefineAI struct{ mockAIService }
+
+func (m *stackRefineAI) RefineStackManifest(ctx context.Context, current *ai.StackManifestResult, feedback string) (*ai.StackManifestResult, error) {
+	refined := *current
+	refined.Manifests = map[string]map[string]string{"Deployment": current.Manifests["Deployment"]}
+	refined.Reasoning = "refined"
+	return &refined, nil
+}
+
+// --- Single Deploy Persistence Tests ---
+
+func TestDeployPersistence_RestoreAndStatusFallback(t *testing.T) {
+	store := &mockDataStore{}
+	s := setupTestServer(t)
+	s.data = store
+	s.ai = &mockAIService{result: &models.ManifestResult{Deployment: strategyManifests, Service: strategyService, Reasoning: "generated"}}
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	w := postJSON(r, "/api/deploy/docker-to-k8s", `{"container_id": "abc123", "cluster_name": "test-cluster"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	rec, err := store.GetSingleDeploy(context.Background(), created.DeployID)
+	if err != nil || rec.Status != "pending" || rec.ContainerID != "abc123" || rec.ManifestsJSON == "" {
+		t.Fatalf("expected a pending deploy record, got %+v (err %v)", rec, err)
+	}
+
+	// A restarted server restores the pending deploy and can still cancel it
+	restarted := setupTestServer(t)
+	restarted.data = store
+	restarted.RestoreDeploy(rec)
+	state := restarted.deployStates[created.DeployID]
+	if state == nil || state.Manifests == nil || state.Manifests.Deployment != s.deployStates[created.DeployID].Manifests.Deployment ||
+		state.Response.ManifestVersion != created.ManifestVersion || state.Request.ClusterName != "test-cluster" {
+		t.Fatalf("unexpected restored state %+v", state)
+	}
+	r = gin.New()
+	r.POST("/api/deploy/:deploy_id/execute", restarted.handleExecuteDeploy)
+	r.GET("/api/deploy/:deploy_id/status", restarted.handleGetDeployStatus)
+	if w := postJSON(r, "/api/deploy/"+created.DeployID+"/execute", `{"approved": false}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if rec, _ := store.GetSingleDeploy(context.Background(), created.DeployID); rec.Status != "cancelled" || rec.CompletedAt == nil {
+		t.Errorf("expected the cancellation to be persisted, got %+v", rec)
+	}
+
+	// A deploy interrupted mid-execute is only in the DB, marked failed at startup
+	interrupted := newTestDeployState("d-interrupted")
+	interrupted.Status.Status = "deploying"
+	interrupted.Status.Steps = directDeploySteps()
+	interrupted.Status.Steps[0].Status = "completed"
+	interrupted.Status.Steps[1].Status = "in_progress"
+	failed := deployStateToRecord(interrupted)
+	now := time.Now()
+	failed.Status = "failed"
+	failed.CompletedAt = &now
+	store.SaveSingleDeploy(context.Background(), failed)
+
+	w = getJSON(r, "/api/deploy/d-interrupted/status")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 from the DB fallback, got %d: %s", w.Code, w.Body.String())
+	}
+	var status models.DeployStatus
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if status.Status != "failed" || len(status.Steps) != 4 || status.Steps[0].Status != "completed" ||
+		status.Steps[1].Status != "failed" || status.Steps[2].Status != "pending" {
+		t.Errorf("unexpected persisted status %+v", status)
+	}
+	if w := getJSON(r, "/api/deploy/unknown/status"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestDeployPersistence_ExecuteSavesFinalStatus(t *testing.T) {
+	s := setupTestServer(t)
+	store := &mockDataStore{}
+	s.data = store
+	s.deployStates["d1"] = newTestDeployState("d1")
+
+	r := gin.New()
+	r.POST("/api/deploy/:deploy_id/execute", s.handleExecuteDeploy)
+	if w := postJSON(r, "/api/deploy/d1/execute", `{"approved": true}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	waitForHistory(t, store)
+
+	rec, err := store.GetSingleDeploy(context.Background(), "d1")
+	if err != nil || rec.Status != "completed" || rec.CompletedAt == nil {
+		t.Fatalf("expected the completed deploy to be persisted, got %+v (err %v)", rec, err)
+	}
+	if status := recordToDeployStatus(rec); len(status.Steps) != 4 || status.Steps[3].Status != "completed" || status.Result == nil {
+		t.Errorf("expected persisted steps and result, got %+v", status)
+	}
+
+	// Each step transition is saved, so a restart sees the step that was running
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var sawRunning bool
+	for _, saved := range store.deploySaves {
+		status := recordToDeployStatus(&saved)
+		if len(status.Steps) == 4 && status.Steps[1].Status == "in_progress" && status.Steps[0].Status == "completed" {
+			sawRunning = true
+		}
+	}
+	if !sawRunning {
+		t.Errorf("expected create_deployment in progress to be saved, got %d saves", len(store.deploySaves))
+	}
+}
+
+// writeOpenAIReply answers a chat completions request, as server-sent chunks when the
+// request asks for a stream.
+func writeOpenAIReply(w http.ResponseWriter, r *http.Request, content string, usage map[string]int) {
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if !req.Stream {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": content}}},
+			"usage":   usage,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	for len(content) > 0 {
+		n := min(len(content), 40)
+		chunk, _ := json.Marshal(map[string]interface{}{
+			"choices": []map[string]interface{}{{"delta": map[string]string{"content": content[:n]}}},
+		})
+		fmt.Fprintf(w, "data: %s\n\n", chunk)
+		content = content[n:]
+	}
+	if usage != nil {
+		chunk, _ := json.Marshal(map[string]interface{}{"choices": []interface{}{}, "usage": usage})
+		fmt.Fprintf(w, "data: %s\n\n", chunk)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func TestAICache_DeployReusesGeneratedManifests(t *testing.T) {
+	var calls int
+	var callsMu sync.Mutex
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callsMu.Lock()
+		calls++
+		callsMu.Unlock()
+		content, _ := json.Marshal(map[string]interface{}{
+			"deployment": strategyManifests, "service": strategyService, "reasoning": "generated", "confidence": 0.9,
+		})
+		writeOpenAIReply(w, r, string(content), nil)
+	}))
+	defer llm.Close()
+	llmCalls := func() int {
+		callsMu.Lock()
+		defer callsMu.Unlock()
+		return calls
+	}
+
+	store := &mockDataStore{}
+	aiSvc, err := ai.NewService(config.AIConfig{
+		Provider:  "ollama",
+		Model:     "llama3.1",
+		Cache:     config.CacheConfig{Enabled: true, TTL: time.Hour},
+		Providers: map[string]config.ProviderConfig{"ollama": {BaseURL: llm.URL}},
+	}, store)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	s := setupTestServer(t)
+	s.data = store
+	s.ai = aiSvc
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	r.POST("/api/deploy/:deploy_id/refine", s.handleRefineDeploy)
+	r.GET("/api/config/ai/cache", s.handleGetAICacheStats)
+	r.DELETE("/api/config/ai/cache", s.handlePurgeAICache)
+
+	deploy := func(body string) models.DeployResponse {
+		t.Helper()
+		w := postJSON(r, "/api/deploy/docker-to-k8s", body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp models.DeployResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	first := deploy(`{"container_id": "abc123", "cluster_name": "test-cluster"}`)
+	second := deploy(`{"container_id": "abc123", "cluster_name": "test-cluster"}`)
+	if first.Cached || !second.Cached || llmCalls() != 1 {
+		t.Fatalf("expected the second deploy to be served from the cache (cached %v/%v, calls %d)", first.Cached, second.Cached, llmCalls())
+	}
+	if second.Manifests.Deployment != first.Manifests.Deployment {
+		t.Errorf("expected the cached manifests, got %q", second.Manifests.Deployment)
+	}
+
+	// no_cache regenerates, and refine never uses the cache
+	if bypassed := deploy(`{"container_id": "abc123", "cluster_name": "test-cluster", "no_cache": true}`); bypassed.Cached || llmCalls() != 2 {
+		t.Errorf("expected no_cache to call the LLM (cached %v, calls %d)", bypassed.Cached, llmCalls())
+	}
+	w := postJSON(r, "/api/deploy/"+second.DeployID+"/refine", `{"feedback": "more replicas"}`)
+	var refined models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &refined)
+	if w.Code != http.StatusOK || refined.Cached || llmCalls() != 3 {
+		t.Errorf("expected refine to call the LLM (status %d, cached %v, calls %d)", w.Code, refined.Cached, llmCalls())
+	}
+
+	var stats models.AICacheStats
+	json.Unmarshal(getJSON(r, "/api/config/ai/cache").Body.Bytes(), &stats)
+	if !stats.Enabled || stats.Hits != 1 || stats.Misses != 1 || stats.Bypassed != 1 || stats.HitRate != 0.5 || stats.Entries != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	req, _ := http.NewRequest("DELETE", "/api/config/ai/cache", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"purged":1`) {
+		t.Fatalf("expected one purged entry, got %d: %s", w.Code, w.Body.String())
+	}
+	if again := deploy(`{"container_id": "abc123", "cluster_name": "test-cluster"}`); again.Cached || llmCalls() != 4 {
+		t.Errorf("expected a miss after purge (cached %v, calls %d)", again.Cached, llmCalls())
+	}
+}
+
+func TestAIUsage_RecordsDeployCallsAndReports(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := json.Marshal(map[string]interface{}{
+			"deployment": strategyManifests, "service": strategyService, "reasoning": "generated", "confidence": 0.9,
+		})
+		writeOpenAIReply(w, r, string(content), map[string]int{"prompt_tokens": 1000, "completion_tokens": 500})
+	}))
+	defer llm.Close()
+
+	store := &mockDataStore{}
+	aiSvc, err := ai.NewService(config.AIConfig{
+		Provider:  "vllm",
+		Model:     "qwen2.5",
+		Providers: map[string]config.ProviderConfig{"vllm": {BaseURL: llm.URL}},
+		Pricing:   map[string]config.ModelPrice{"qwen": {Input: 1, Output: 2}},
+		Budget:    config.BudgetConfig{MonthlyUSD: 10},
+	}, store)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	s := setupTestServer(t)
+	s.data = store
+	s.ai = aiSvc
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	r.POST("/api/deploy/:deploy_id/refine", s.handleRefineDeploy)
+	r.GET("/api/ai/usage", s.handleGetAIUsage)
+
+	w := postJSON(r, "/api/deploy/docker-to-k8s", `{"container_id": "abc123", "cluster_name": "test-cluster", "requested_by": "alice"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if w := postJSON(r, "/api/deploy/"+created.DeployID+"/refine", `{"feedback": "more memory"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	records := store.usageRecords()
+	if len(records) != 2 {
+		t.Fatalf("expected generate and refine to be recorded, got %+v", records)
+	}
+	for i, op := range []string{"generate", "refine"} {
+		if rec := records[i]; rec.Operation != op || rec.DeployID != created.DeployID || rec.User != "alice" ||
+			rec.Provider != "vllm" || rec.PromptTokens != 1000 || rec.CostUSD != 0.002 {
+			t.Errorf("unexpected %s record %+v", op, rec)
+		}
+	}
+
+	var report models.AIUsageReport
+	w = getJSON(r, "/api/ai/usage?deploy_id="+created.DeployID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.Total.Calls != 2 || report.Total.CompletionTokens != 1000 || report.Total.CostUSD != 0.004 {
+		t.Errorf("unexpected total %+v", report.Total)
+	}
+	if len(report.ByModel) != 1 || report.ByModel[0].Key != "vllm/qwen2.5" || len(report.ByUser) != 1 || report.ByUser[0].Key != "alice" || len(report.ByDay) != 1 {
+		t.Errorf("unexpected groupings %+v / %+v / %+v", report.ByDay, report.ByModel, report.ByUser)
+	}
+	if report.Budget.MonthlyUSD != 10 || report.Budget.SpentUSD != 0.004 || report.Budget.Exceeded {
+		t.Errorf("unexpected budget %+v", report.Budget)
+	}
+
+	// A range before the calls is empty; a bad date is rejected
+	json.Unmarshal(getJSON(r, "/api/ai/usage?from=2020-01-01&to=2020-01-31").Body.Bytes(), &report)
+	if report.Total.Calls != 0 || len(report.ByDay) != 0 {
+		t.Errorf("expected no usage in 2020, got %+v", report.Total)
+	}
+	if w := getJSON(r, "/api/ai/usage?from=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid date, got %d", w.Code)
+	}
+}
+
+func TestGeneration_DeployStreamsProgress(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := json.Marshal(map[string]interface{}{
+			"deployment": strategyManifests, "service": strategyService, "reasoning": "streamed reasoning", "confidence": 0.9,
+		})
+		writeOpenAIReply(w, r, string(content), map[string]int{"prompt_tokens": 100, "completion_tokens": 50})
+	}))
+	defer llm.Close()
+
+	aiSvc, err := ai.NewService(config.AIConfig{
+		Provider:  "ollama",
+		Model:     "llama3.1",
+		Providers: map[string]config.ProviderConfig{"ollama": {BaseURL: llm.URL}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	s := setupTestServer(t)
+	s.ai = aiSvc
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	body, _ := json.Marshal(models.DeployRequest{ContainerID: "abc123", ClusterName: "test-cluster"})
+	req := httptest.NewRequest(http.MethodPost, "/api/deploy/docker-to-k8s", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("expected an event stream, got %q: %s", ct, w.Body.String())
+	}
+	type sse struct{ name, data string }
+	var events []sse
+	for _, block := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+		var ev sse
+		for _, line := range strings.Split(block, "\n") {
+			if v, ok := strings.CutPrefix(line, "event:"); ok {
+				ev.name = v
+			} else if v, ok := strings.CutPrefix(line, "data:"); ok {
+				ev.data = v
+			}
+		}
+		events = append(events, ev)
+	}
+	if len(events) < 4 || events[0].name != "deploy" || events[len(events)-1].name != "result" {
+		t.Fatalf("expected deploy, generation and result events, got %+v", events)
+	}
+
+	var resp models.DeployResponse
+	json.Unmarshal([]byte(events[len(events)-1].data), &resp)
+	if !strings.Contains(events[0].data, resp.DeployID) || resp.Recommendations.Reasoning != "streamed reasoning" {
+		t.Errorf("unexpected result %+v (deploy event %s)", resp, events[0].data)
+	}
+	var types []string
+	for _, ev := range events[1 : len(events)-1] {
+		var gen models.GenerationEvent
+		json.Unmarshal([]byte(ev.data), &gen)
+		if ev.name != "generation" || gen.DeployID != resp.DeployID {
+			t.Errorf("unexpected event %+v", ev)
+		}
+		types = append(types, gen.Type)
+		if gen.Type == models.GenerationProgress && gen.Reasoning != "streamed reasoning" {
+			t.Errorf("expected the streamed reasoning, got %+v", gen)
+		}
+	}
+	if types[0] != models.GenerationStarted || types[len(types)-1] != models.GenerationCompleted {
+		t.Errorf("unexpected generation events %v", types)
+	}
+
+	s.mu.RLock()
+	_, stored := s.deployStates[resp.DeployID]
+	s.mu.RUnlock()
+	if !stored {
+		t.Error("expected the deploy to be stored")
+	}
+}
+
+func TestGeneration_WebSocketReplaysAndCloses(t *testing.T) {
+	s := setupTestServer(t)
+	run := s.beginGeneration("d1")
+	run.report(models.GenerationEvent{Type: models.GenerationStarted, Attempt: 1})
+	run.report(models.GenerationEvent{Type: models.GenerationProgress, Attempt: 1, Reasoning: "Uses"})
+	run.report(models.GenerationEvent{Type: models.GenerationProgress, Attempt: 1, Reasoning: "Uses two replicas"})
+
+	r := gin.New()
+	r.GET("/ws/deploy/:deploy_id/generation", s.handleGenerationWS)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/deploy/%s/generation"
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf(wsURL, "d1"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	type message struct {
+		Type     string                 `json:"type"`
+		DeployID string                 `json:"deploy_id"`
+		Data     models.GenerationEvent `json:"data"`
+	}
+	read := func() message {
+		var m message
+		if err := conn.ReadJSON(&m); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		return m
+	}
+
+	// Only the latest progress event is kept
+	if m := read(); m.Type != "generation" || m.DeployID != "d1" || m.Data.Type != models.GenerationStarted || m.Data.Seq != 1 {
+		t.Errorf("unexpected first message %+v", m)
+	}
+	if m := read(); m.Data.Type != models.GenerationProgress || m.Data.Reasoning != "Uses two replicas" {
+		t.Errorf("unexpected progress message %+v", m)
+	}
+	run.report(models.GenerationEvent{Type: models.GenerationCompleted, TokensReceived: 40})
+	if m := read(); m.Data.Type != models.GenerationCompleted || m.Data.TokensReceived != 40 {
+		t.Errorf("unexpected final message %+v", m)
+	}
+	run.finish()
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("expected the connection to close when the generation finished")
+	}
+
+	// Events after the end are ignored; unknown deploys are reported
+	run.report(models.GenerationEvent{Type: models.GenerationProgress})
+	if events, done, _ := s.generationEventsAfter("d1", 0); len(events) != 3 || !done {
+		t.Errorf("expected three events of a finished generation, got %+v", events)
+	}
+	other, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf(wsURL, "unknown"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer other.Close()
+	var notFound map[string]string
+	if err := other.ReadJSON(&notFound); err != nil || notFound["status"] != "not_found" {
+		t.Errorf("expected not_found, got %v (%v)", notFound, err)
+	}
+}
+
+const policyDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.27
+        ports:
+        - name: http
+          containerPort: 8080
+        resources:
+          requests: {cpu: 100m, memory: 128Mi}
+          limits: {cpu: 500m, memory: 256Mi}
+        readinessProbe:
+          httpGet: {path: /, port: 8080}
+        livenessProbe:
+          httpGet: {path: /, port: 8080}
+        securityContext:
+          runAsNonRoot: true
+`
+
+const policyService = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+  ports:
+  - port: 80
+    targetPort: http
+`
+
+// policyAIService generates manifests breaking every policy rule and returns the
+// scripted refinements in order, keeping the feedback it received.
+type policyAIService struct {
+	mockAIService
+	refinements []*models.ManifestResult
+	feedback    []string
+}
+
+func (m *policyAIService) GenerateManifest(ctx context.Context, info ai.ContainerInfo, history []models.DeploymentHistory) (*models.ManifestResult, error) {
+	deployment := strings.NewReplacer("nginx:1.27", "nginx", "matchLabels:\n      app: web", "matchLabels:\n      app: other").Replace(policyDeployment)
+	deployment = deployment[:strings.Index(deployment, "        resources:")]
+	return &models.ManifestResult{
+		Deployment: deployment,
+		Service:    strings.Replace(policyService, "targetPort: http", "targetPort: 9090", 1),
+		HPA:        "kind: [broken",
+		Reasoning:  "generated",
+	}, nil
+}
+
+func (m *policyAIService) RefineManifest(ctx context.Context, current *models.ManifestResult, feedback string) (*models.ManifestResult, error) {
+	m.feedback = append(m.feedback, feedback)
+	if len(m.refinements) == 0 {
+		return nil, fmt.Errorf("no refinement left")
+	}
+	next := m.refinements[0]
+	m.refinements = m.refinements[1:]
+	return next, nil
+}
+
+func violationRules(violations []models.PolicyViolation) []string {
+	var rules []string
+	for _, v := range violations {
+		rules = append(rules, v.Rule)
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+func TestPolicy_GenerationIsRepairedWithinRounds(t *testing.T) {
+	rootDeployment := strings.Replace(policyDeployment, "        securityContext:\n          runAsNonRoot: true\n", "", 1)
+	aiSvc := &policyAIService{refinements: []*models.ManifestResult{
+		{Deployment: rootDeployment, Service: policyService, Reasoning: "round 1"},
+		{Deployment: rootDeployment, Service: policyService, Reasoning: "round 2"},
+	}}
+	s := setupTestServer(t)
+	s.ai = aiSvc
+	s.cfg.AI.Policy = config.PolicyConfig{Enabled: true, MaxRepairRounds: 2}
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	r.POST("/api/deploy/:deploy_id/edit", s.handleEditDeploy)
+
+	w := postJSON(r, "/api/deploy/docker-to-k8s", `{"container_id":"abc123","cluster_name":"test-cluster"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	// Every violation of the generated manifests reaches the model
+	if len(aiSvc.feedback) != 2 {
+		t.Fatalf("expected two repair rounds, got %d", len(aiSvc.feedback))
+	}
+	for _, want := range []string{
+		"[schema]", "[resources] Deployment/web", "[probes] Deployment/web", "[no_latest_tag] Deployment/web",
+		"[non_root] Deployment/web", "[selector_labels] Deployment/web", "[service_target_port] Service/web: port 80 targets 9090",
+	} {
+		if !strings.Contains(aiSvc.feedback[0], want) {
+			t.Errorf("expected %q in the first feedback:\n%s", want, aiSvc.feedback[0])
+		}
+	}
+
+	// The model never fixed non_root: it is left on the result after the last round
+	if resp.PolicyRepairRounds != 2 || resp.Recommendations.Reasoning != "round 2" {
+		t.Errorf("expected the second refinement after 2 rounds, got %d rounds (%q)", resp.PolicyRepairRounds, resp.Recommendations.Reasoning)
+	}
+	if rules := violationRules(resp.PolicyViolations); len(rules) != 1 || rules[0] != models.PolicyNonRoot {
+		t.Errorf("expected only the non_root violation to remain, got %+v", resp.PolicyViolations)
+	}
+
+	// Manual edits are checked again without calling the model
+	w = postJSON(r, "/api/deploy/"+resp.DeployID+"/edit", `{"edits":[{"resource":"deployment","type":"strategic","patch":"spec:\n  template:\n    spec:\n      securityContext:\n        runAsUser: 1000"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var edited models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &edited)
+	if len(edited.PolicyViolations) != 0 || edited.PolicyRepairRounds != 0 || len(aiSvc.feedback) != 2 {
+		t.Errorf("expected the edit to clear the violations, got %+v", edited.PolicyViolations)
+	}
+}
+
+func TestPolicy_DisabledRulesAndFailedRepair(t *testing.T) {
+	aiSvc := &policyAIService{}
+	s := setupTestServer(t)
+	s.ai = aiSvc
+	s.cfg.AI.Policy = config.PolicyConfig{
+		Enabled:         true,
+		MaxRepairRounds: 3,
+		DisabledRules:   []string{models.PolicySchema, models.PolicyProbes, models.PolicyNonRoot},
+	}
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	w := postJSON(r, "/api/deploy/docker-to-k8s", `{"container_id":"abc123","cluster_name":"test-cluster"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	// A failed refinement ends the loop and keeps the generated manifests
+	if len(aiSvc.feedback) != 1 || resp.PolicyRepairRounds != 1 || resp.Recommendations.Reasoning != "generated" {
+		t.Errorf("expected one failed round, got %d feedbacks, %d rounds", len(aiSvc.feedback), resp.PolicyRepairRounds)
+	}
+	want := []string{models.PolicyResources, models.PolicyPinnedImage, models.PolicySelector, models.PolicyTargetPort}
+	sort.Strings(want)
+	if got := violationRules(resp.PolicyViolations); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Without the policy check nothing is reported or repaired
+	s.cfg.AI.Policy.Enabled = false
+	w = postJSON(r, "/api/deploy/docker-to-k8s", `{"container_id":"abc123","cluster_name":"test-cluster"}`)
+	var unchecked models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &unchecked)
+	if len(unchecked.PolicyViolations) != 0 || len(aiSvc.feedback) != 1 {
+		t.Errorf("expected no policy check, got %+v", unchecked.PolicyViolations)
+	}
+}
+
+// qualityScores returns the category scores of a report by category.
+func qualityScores(q *models.QualityReport) map[string]int {
+	scores := map[string]int{}
+	for _, c := range q.Categories {
+		scores[c.Category] = c.Score
+	}
+	return scores
+}
+
+func TestQuality_ScoresManifestsAndComparesModels(t *testing.T) {
+	s := setupTestServer(t)
+	s.ai = &mockAIService{result: &models.ManifestResult{
+		Deployment: policyDeployment, Service: policyService, Reasoning: "generated",
+		Provider: "openai", Model: "gpt-4", PromptVersion: 1,
+	}}
+	store := s.data.(*mockDataStore)
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	r.POST("/api/deploy/:deploy_id/edit", s.handleEditDeploy)
+	r.GET("/api/ai/quality", s.handleGetAIQuality)
+
+	w := postJSON(r, "/api/deploy/docker-to-k8s", `{"container_id":"abc123","cluster_name":"test-cluster"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	// security: privilege_escalation, read_only_root_fs, drop_capabilities
+	// reliability: single_replica; resources: cpu_limit_ratio (500m / 100m)
+	want := map[string]int{
+		models.QualitySecurity: 84, models.QualityReliability: 90, models.QualityResources: 97, models.QualityNetworking: 100,
+	}
+	if resp.Quality == nil {
+		t.Fatal("expected a quality report")
+	}
+	if got := qualityScores(resp.Quality); !reflect.DeepEqual(got, want) || resp.Quality.Score != 93 {
+		t.Errorf("expected score 93 with %v, got %d with %v: %+v", want, resp.Quality.Score, got, resp.Quality.Findings)
+	}
+	if resp.Quality.RulesVersion != kubernetes.QualityRulesVersion || resp.Quality.Counts[models.SeverityWarning] != 2 || resp.Quality.Counts[models.SeverityInfo] != 3 {
+		t.Errorf("unexpected counts %v", resp.Quality.Counts)
+	}
+
+	// The report and the model are stored with the revision
+	if len(store.revisions) != 1 || store.revisions[0].Quality == nil || store.revisions[0].Quality.Score != 93 ||
+		store.revisions[0].Model != "gpt-4" || store.revisions[0].PromptVersion != 1 {
+		t.Fatalf("expected the scored revision, got %+v", store.revisions)
+	}
+
+	// Edits are scored again
+	w = postJSON(r, "/api/deploy/"+resp.DeployID+"/edit", `{"edits":[{"resource":"deployment","type":"strategic","patch":"spec:\n  replicas: 3"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var edited models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &edited)
+	var rules []string
+	for _, f := range edited.Quality.Findings {
+		if f.Category == models.QualityReliability {
+			rules = append(rules, f.Rule)
+		}
+	}
+	if strings.Join(rules, ",") != "anti_affinity,pod_disruption_budget" {
+		t.Errorf("expected the replicas to need a PDB and anti-affinity, got %v", rules)
+	}
+
+	// Only AI output is compared, so the edit is left out
+	w = getJSON(r, "/api/ai/quality")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report models.AIQualityReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if len(report.ByModel) != 1 || report.ByModel[0].Model != "gpt-4" || report.ByModel[0].Revisions != 1 || report.ByModel[0].AvgScore != 93 {
+		t.Errorf("expected one gpt-4 revision scoring 93, got %+v", report.ByModel)
+	}
+
+	if w = getJSON(r, "/api/ai/quality?source=edit"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an edit source, got %d", w.Code)
+	}
+}
+
+func TestQuality_FindingsAreOrderedBySeverity(t *testing.T) {
+	s := setupTestServer(t)
+	s.ai = &policyAIService{}
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	w := postJSON(r, "/api/deploy/docker-to-k8s", `{"container_id":"abc123","cluster_name":"test-cluster"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	rank := map[string]int{models.SeverityError: 0, models.SeverityWarning: 1, models.SeverityInfo: 2}
+	findings := resp.Quality.Findings
+	for i := 1; i < len(findings); i++ {
+		a, b := findings[i-1], findings[i]
+		if rank[a.Severity] > rank[b.Severity] || (a.Severity == b.Severity && a.Resource > b.Resource) {
+			t.Errorf("finding %d (%s %s) is out of order after %s %s", i, b.Severity, b.Resource, a.Severity, a.Resource)
+		}
+	}
+
+	// The broken HPA, the selector and the Service port are errors
+	errors := map[string]string{}
+	for _, f := range findings {
+		if f.Severity == models.SeverityError {
+			errors[f.Rule] = f.Category
+		}
+	}
+	for rule, category := range map[string]string{
+		"schema": models.QualityReliability, "selector_labels": models.QualityReliability, models.PolicyTargetPort: models.QualityNetworking,
+	} {
+		if errors[rule] != category {
+			t.Errorf("expected a %s error in %s, got %v", rule, category, errors)
+		}
+	}
+	if scores := qualityScores(resp.Quality); scores[models.QualityNetworking] != 75 || resp.Quality.Score >= 75 {
+		t.Errorf("expected a low score, got %d with %v", resp.Quality.Score, scores)
+	}
+}
+
+// usageSample returns a sample of pods with CPU in millicores, memory in MiB and the
+// container state of "app".
+func usageSample(pods ...models.PodUsage) *models.UsageSample {
+	return &models.UsageSample{Time: time.Now(), Pods: pods, MetricsAvailable: true, ThrottleAvailable: true}
+}
+
+func podUsage(name string, cpu, memMiB int64, restarts int, last string, periods, throttled int64) models.PodUsage {
+	return models.PodUsage{
+		Name: name, CPUMillis: cpu, MemoryBytes: memMiB << 20, HasMetrics: true,
+		Containers: []models.ContainerUsage{{Name: "app", RestartCount: restarts, LastTermination: last,
+			CFSPeriods: periods, ThrottledPeriods: throttled}},
+	}
+}
+
+func TestFeedback_RecordsUsageAndEvents(t *testing.T) {
+	s := setupTestServer(t)
+	k8s := s.kubernetes.(*mockK8sService)
+	store := s.data.(*mockDataStore)
+
+	manifests, _ := json.Marshal(models.ManifestResult{Deployment: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      tier: fe
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+        tier: fe
+    spec:
+      containers:
+      - name: app
+        image: nginx
+`})
+	store.history = []models.DeploymentHistory{
+		{ID: "d1", ServiceName: "web", Status: "deployed", Success: true, TargetCluster: "test-cluster", Namespace: "default",
+			ManifestJSON: string(manifests), DeployedAt: time.Now().Add(-10 * time.Minute)},
+		{ID: "deleted", ServiceName: "old", Status: "deleted", Success: true, TargetCluster: "test-cluster",
+			ManifestJSON: string(manifests), DeployedAt: time.Now().Add(-10 * time.Minute)},
+	}
+	k8s.samples = []*models.UsageSample{
+		usageSample(podUsage("web-1", 100, 100, 0, "", 1000, 0), podUsage("web-2", 200, 200, 0, "", 1000, 0)),
+		// web-1 was OOM-killed once and throttled in half of its periods since the last sample
+		usageSample(podUsage("web-1", 300, 120, 1, "OOMKilled", 2000, 500), podUsage("web-2", 400, 210, 0, "", 2000, 100)),
+	}
+
+	ctx := context.Background()
+	s.runFeedback(ctx, time.Hour)
+	s.runFeedback(ctx, time.Hour)
+
+	if len(k8s.selectors) != 2 || k8s.selectors[0] != "app=web,tier=fe" {
+		t.Fatalf("expected the deployed deploy to be sampled by its selector twice, got %v", k8s.selectors)
+	}
+	d := store.history[0]
+	if d.Usage == nil || d.Usage.Samples != 4 || d.Usage.Completed {
+		t.Fatalf("expected 4 samples of an ongoing window, got %+v", d.Usage)
+	}
+	if want := (models.UsagePercentiles{P50: 200, P95: 400, Max: 400}); d.Usage.CPU != want {
+		t.Errorf("expected CPU %+v, got %+v", want, d.Usage.CPU)
+	}
+	if d.ActualCPU != "400m" || d.ActualMemory != "210Mi" {
+		t.Errorf("expected the p95 usage as actual usage, got %s / %s", d.ActualCPU, d.ActualMemory)
+	}
+	if d.OOMEvents != 1 || d.ThrottleEvents != 1 || !d.Usage.ThrottleMeasured {
+		t.Errorf("expected 1 OOM kill and 1 throttle event, got %d / %d", d.OOMEvents, d.ThrottleEvents)
+	}
+
+	// Once the window has ended the entry is completed and no longer sampled
+	s.runFeedback(ctx, 5*time.Minute)
+	s.runFeedback(ctx, 5*time.Minute)
+	if len(k8s.selectors) != 2 {
+		t.Errorf("expected no samples after the window, got %d", len(k8s.selectors))
+	}
+	if d := store.history[0]; !d.Usage.Completed || d.Usage.Samples != 4 || d.ActualCPU != "400m" {
+		t.Errorf("expected the completed usage to keep the measurements, got %+v", d.Usage)
+	}
+	if len(s.feedbackTrackers) != 0 {
+		t.Errorf("expected the tracker to be dropped, got %d", len(s.feedbackTrackers))
+	}
+}
+
+// --- Resource Right-sizing Tests ---
+
+// sizingAIService returns fixed manifests and keeps the container info it received.
+type sizingAIService struct {
+	mockAIService
+	info ai.ContainerInfo
+}
+
+func (m *sizingAIService) GenerateManifest(ctx context.Context, info ai.ContainerInfo, history []models.DeploymentHistory) (*models.ManifestResult, error) {
+	m.info = info
+	return m.mockAIService.GenerateManifest(ctx, info, history)
+}
+
+func TestDeployDockerToK8s_Recommendations(t *testing.T) {
+	s := setupTestServer(t)
+	s.data = &mockDataStore{}
+	s.docker.(*mockDockerService).detail.Stats = &models.ContainerStats{CPUPercent: 5, MemoryUsage: 100 << 20}
+	aiSvc := &sizingAIService{mockAIService: mockAIService{result: &models.ManifestResult{
+		Deployment: policyDeployment, Service: policyService, HPA: "kind: HorizontalPodAutoscaler", Reasoning: "generated",
+	}}}
+	s.ai = aiSvc
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	r.POST("/api/deploy/:deploy_id/edit", s.handleEditDeploy)
+	w := postJSON(r, "/api/deploy/docker-to-k8s", `{"container_id": "abc123", "cluster_name": "test-cluster"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Without a sampler the current stats are the only sample: p95 5% = 50m and 100Mi,
+	// plus 20% headroom
+	usage := aiSvc.info.Usage
+	if usage == nil || usage.Samples != 1 || usage.CPU.P95 != 50 || usage.Memory.P95 != 100<<20 {
+		t.Fatalf("expected the current stats as usage, got %+v", usage)
+	}
+	if want := (models.ResourceSizing{CPURequest: "60m", CPULimit: "120m", MemoryRequest: "120Mi", MemoryLimit: "180Mi"}); usage.Suggested != want {
+		t.Errorf("expected suggested resources %+v, got %+v", want, usage.Suggested)
+	}
+
+	var resp models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	rec := resp.Recommendations
+	if rec == nil || rec.CPURequest != "100m" || rec.CPULimit != "500m" || rec.MemoryRequest != "128Mi" || rec.MemoryLimit != "256Mi" {
+		t.Fatalf("expected the resources of the generated Deployment, got %+v", rec)
+	}
+	if rec.Replicas != 1 || !rec.EnableHPA || rec.Reasoning != "generated" || rec.Usage == nil || rec.Usage.Suggested != usage.Suggested {
+		t.Errorf("unexpected recommendations %+v", rec)
+	}
+
+	// Edits update the recommendations and keep the sampled usage
+	body, _ := json.Marshal(models.EditManifestRequest{Edits: []models.ManifestEdit{
+		{Resource: "deployment", Type: models.EditJSONPatch, Patch: `[{"op":"add","path":"/spec/replicas","value":3}]`},
+		{Resource: "deployment", Patch: `{"spec":{"template":{"spec":{"containers":[{"name":"web","resources":{"requests":{"cpu":"60m"}}}]}}}}`},
+	}})
+	w = postJSON(r, "/api/deploy/"+resp.DeployID+"/edit", string(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if rec := resp.Recommendations; rec.Replicas != 3 || rec.CPURequest != "60m" || rec.MemoryLimit != "256Mi" || rec.Usage == nil {
+		t.Errorf("expected the recommendations of the edited Deployment, got %+v", rec)
+	}
+}
+
+// --- Cost Estimate Tests ---
+
+const costHPA = `apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  scaleTargetRef: {apiVersion: apps/v1, kind: Deployment, name: web}
+  minReplicas: 2
+  maxReplicas: 4
+`
+
+func TestDeployDockerToK8s_EstimatedCost(t *testing.T) {
+	s := setupTestServer(t)
+	s.data = &mockDataStore{}
+	s.cfg.Pricing = models.PricingConfig{
+		Default:  "on-prem",
+		Clusters: map[string]string{"test-cluster": "eks"},
+		Profiles: map[string]models.PricingProfile{
+			"eks":     {VCPUHour: 0.04, GiBHour: 0.005, LoadBalancerHour: 0.02, PVCGiBMonth: 0.1, NodeOverhead: 0.1},
+			"on-prem": {VCPUHour: 0.01},
+		},
+	}
+	s.ai = &mockAIService{result: &models.ManifestResult{
+		Deployment: policyDeployment,
+		Service:    strings.Replace(policyService, "spec:\n", "spec:\n  type: LoadBalancer\n", 1),
+		HPA:        costHPA,
+		Reasoning:  "generated",
+	}}
+
+	r := gin.New()
+	r.POST("/api/deploy/docker-to-k8s", s.handleDeployDockerToK8s)
+	r.POST("/api/deploy/:deploy_id/edit", s.handleEditDeploy)
+	w := postJSON(r, "/api/deploy/docker-to-k8s", `{"container_id": "abc123", "cluster_name": "test-cluster"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 2 replicas (HPA minReplicas) × 100m and 128Mi, one LoadBalancer, 10% node overhead
+	var resp models.DeployResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	cost := resp.EstimatedCost
+	if cost == nil || cost.Profile != "eks" || cost.Cluster != "test-cluster" {
+		t.Fatalf("expected an estimate with the mapped eks profile, got %+v", cost)
+	}
+	if cost.MonthlyUSD != 22.03 {
+		t.Errorf("expected $22.03 a month, got %v (%s)", cost.MonthlyUSD, cost.Breakdown)
+	}
+	if want := "CPU: $5.84, Memory: $0.91, LoadBalancer: $14.60, Node overhead: $0.68"; !strings.HasPrefix(cost.Breakdown, want) {
+		t.Errorf("expected breakdown %q, got %q", want, cost.Breakdown)
+	}
+	if len(cost.Items) != 4 || cost.Items[0].Resource != models.CostCPU || cost.Items[0].Quantity != 0.2 || cost.Items[0].MaxQuantity != 0.4 {
+		t.Errorf("expected CPU of 2 and up to 4 replicas, got %+v", cost.Items)
+	}
+	if cost.MaxMonthlyUSD <= cost.MonthlyUSD {
+		t.Errorf("expected a higher cost at maxReplicas, got %v", cost.MaxMonthlyUSD)
+	}
+
+	// Edits are priced again
+	body, _ := json.Marshal(models.EditManifestRequest{Edits: []models.ManifestEdit{
+		{Resource: "deployment", Type: models.EditJSONPatch, Patch: `[{"op":"add","path":"/spec/replicas","value":3}]`},
+		{Resource: "service", Type: models.EditMergePatch, Patch: "spec:\n  type: ClusterIP\n"},
+	}})
+	w = postJSON(r, "/api/deploy/"+resp.DeployID+"/edit", string(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if cost := resp.EstimatedCost; cost == nil || cost.Items[0].Quantity != 0.3 || strings.Contains(cost.Breakdown, "LoadBalancer") {
+		t.Errorf("expected the edited manifests to be priced, got %+v", cost)
+	}
+
+	// Clusters without a mapping fall back to the default profile
+	if name, _, ok := s.pricingFor("other"); !ok || name != "on-prem" {
+		t.Errorf("expected the default profile, got %q", name)
+	}
+}
+
+func TestUpdatePricing(t *testing.T) {
+	s := setupTestServer(t)
+	store := &mockDataStore{}
+	s.data = store
+
+	r := gin.New()
+	r.GET("/api/config/pricing", s.handleGetPricing)
+	r.PUT("/api/config/pricing", s.handleUpdatePricing)
+	put := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/config/pricing", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"default": "gke", "profiles": {"eks": {"vcpu_hour": 0.04}}}`,
+		`{"clusters": {"test-cluster": "aks"}, "profiles": {"eks": {"vcpu_hour": 0.04}}}`,
+		`{"profiles": {"eks": {"vcpu_hour": -1}}}`,
+		`{"profiles": {"eks": {"node_overhead": 1.5}}}`,
+	} {
+		if w := put(body); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_PRICING") {
+			t.Errorf("%s: expected 400 INVALID_PRICING, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+
+	w := put(`{"default": "eks", "profiles": {"eks": {"vcpu_hour": 0.04, "gib_hour": 0.005, "load_balancer_hour": 0.02, "pvc_gib_month": 0.1}}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(store.settings[PricingSetting], `"vcpu_hour":0.04`) {
+		t.Errorf("expected the pricing to be persisted, got %q", store.settings[PricingSetting])
+	}
+	if name, profile, ok := s.pricingFor("test-cluster"); !ok || name != "eks" || profile.GiBHour != 0.005 {
+		t.Errorf("expected the updated default profile, got %q %+v", name, profile)
+	}
+
+	w = getJSON(r, "/api/config/pricing")
+	var got models.PricingConfig
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Default != "eks" || got.Profiles["eks"].LoadBalancerHour != 0.02 {
+		t.Errorf("unexpected pricing %+v", got)
 	}
 }
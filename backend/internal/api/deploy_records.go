@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// saveDeployToDB persists the current in-memory state of a single deploy so that a
+// pending deploy survives a restart and a finished one stays readable afterwards.
+func (s *Server) saveDeployToDB(ctx context.Context, state *deployState) {
+	s.mu.RLock()
+	record := deployStateToRecord(state)
+	s.mu.RUnlock()
+	if err := s.data.SaveSingleDeploy(ctx, record); err != nil {
+		slog.Error("failed to save deploy to DB", "deploy_id", record.DeployID, "error", err)
+	}
+}
+
+// deployStatusSnapshot returns a copy of the status of a deploy, safe to serialize
+// while the deploy keeps running.
+func (s *Server) deployStatusSnapshot(state *deployState) *models.DeployStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyDeployStatus(state.Status)
+}
+
+// copyDeployStatus copies the parts of a deploy status that executors mutate. The
+// caller holds s.mu.
+func copyDeployStatus(status *models.DeployStatus) *models.DeployStatus {
+	c := *status
+	c.Steps = append([]models.DeployStep{}, status.Steps...)
+	if status.Result != nil {
+		result := *status.Result
+		c.Result = &result
+	}
+	if status.Rollout != nil {
+		rollout := *status.Rollout
+		c.Rollout = &rollout
+	}
+	if status.Clusters != nil {
+		c.Clusters = make(map[string]*models.DeployStatus, len(status.Clusters))
+		for name, child := range status.Clusters {
+			c.Clusters[name] = copyDeployStatus(child)
+		}
+	}
+	return &c
+}
+
+// deployStateToRecord converts a deploy state to its DB record. The caller holds s.mu.
+func deployStateToRecord(state *deployState) *models.DeployRecord {
+	record := &models.DeployRecord{
+		DeployID:    state.Status.DeployID,
+		Status:      state.Status.Status,
+		StartedAt:   state.Status.StartedAt,
+		CompletedAt: state.Status.CompletedAt,
+		ParentID:    state.ParentID,
+	}
+	if state.Request != nil {
+		record.ContainerID = state.Request.ContainerID
+		record.ClusterName = state.Request.ClusterName
+		record.Namespace = state.Request.Namespace
+		if b, err := json.Marshal(state.Request); err == nil {
+			record.RequestJSON = string(b)
+		}
+	}
+	if record.Namespace == "" {
+		record.Namespace = "default"
+	}
+	if state.Response != nil {
+		if b, err := json.Marshal(state.Response); err == nil {
+			record.ResponseJSON = string(b)
+		}
+	}
+	if state.Manifests != nil {
+		if b, err := json.Marshal(state.Manifests); err == nil {
+			record.ManifestsJSON = string(b)
+		}
+	}
+	if b, err := json.Marshal(state.Status); err == nil {
+		record.StatusJSON = string(b)
+	}
+	return record
+}
+
+// recordToDeployStatus converts a DB record to the deploy status. Steps still in
+// progress when a deploy was marked failed were interrupted by a restart.
+func recordToDeployStatus(record *models.DeployRecord) *models.DeployStatus {
+	status := &models.DeployStatus{}
+	if record.StatusJSON != "" {
+		if err := json.Unmarshal([]byte(record.StatusJSON), status); err != nil {
+			slog.Warn("failed to decode persisted deploy status", "deploy_id", record.DeployID, "error", err)
+		}
+	}
+	status.DeployID = record.DeployID
+	status.Status = record.Status
+	status.StartedAt = record.StartedAt
+	status.CompletedAt = record.CompletedAt
+	if status.Steps == nil {
+		status.Steps = []models.DeployStep{}
+	}
+	if record.Status == "failed" {
+		for i, step := range status.Steps {
+			if step.Status == "in_progress" {
+				status.Steps[i].Status = "failed"
+				status.Steps[i].Message = "Interrupted by server restart"
+				status.Steps[i].CompletedAt = record.CompletedAt
+			}
+		}
+	}
+	return status
+}
+
+// RestoreDeploy restores an in-memory state from a DB record (used at startup).
+func (s *Server) RestoreDeploy(record *models.DeployRecord) {
+	state := &deployState{
+		Status:   recordToDeployStatus(record),
+		Request:  &models.DeployRequest{ContainerID: record.ContainerID, ClusterName: record.ClusterName, Namespace: record.Namespace},
+		ParentID: record.ParentID,
+	}
+	if record.RequestJSON != "" {
+		var req models.DeployRequest
+		if err := json.Unmarshal([]byte(record.RequestJSON), &req); err == nil {
+			state.Request = &req
+		}
+	}
+	if record.ResponseJSON != "" {
+		var resp models.DeployResponse
+		if err := json.Unmarshal([]byte(record.ResponseJSON), &resp); err == nil {
+			state.Response = &resp
+		}
+	}
+	if record.ManifestsJSON != "" {
+		var manifests models.ManifestResult
+		if err := json.Unmarshal([]byte(record.ManifestsJSON), &manifests); err == nil {
+			state.Manifests = &manifests
+		}
+	}
+	if state.Response == nil {
+		state.Response = &models.DeployResponse{DeployID: record.DeployID, Status: "analyzing"}
+	}
+	if state.Response.Recommendations == nil {
+		state.Response.Recommendations = &models.Recommendations{}
+	}
+
+	s.mu.Lock()
+	s.deployStates[record.DeployID] = state
+	s.mu.Unlock()
+}
+
+// persistedDeployStatus returns the status of a deploy that is no longer in memory.
+func (s *Server) persistedDeployStatus(ctx context.Context, deployID string) (*models.DeployStatus, bool) {
+	record, err := s.data.GetSingleDeploy(ctx, deployID)
+	if err != nil || record == nil {
+		return nil, false
+	}
+	return recordToDeployStatus(record), true
+}
@@ -0,0 +1,597 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// driftCheckTimeout bounds one drift check of a single deploy or stack.
+const driftCheckTimeout = 60 * time.Second
+
+// StartDriftDetection starts the periodic drift check of every deployed single and
+// stack deploy. Results are kept in memory for the drift endpoints and WebSocket and
+// persisted on the deploy records.
+func (s *Server) StartDriftDetection(interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.driftCancel = cancel
+
+	s.driftWG.Add(1)
+	go func() {
+		defer s.driftWG.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.runDriftChecks(ctx)
+			}
+		}
+	}()
+
+	slog.Info("drift detection started", "interval", interval.String())
+}
+
+// StopDriftDetection stops the periodic drift check and waits for a running pass.
+func (s *Server) StopDriftDetection() {
+	if s.driftCancel != nil {
+		s.driftCancel()
+	}
+	s.driftWG.Wait()
+	slog.Info("drift detection stopped")
+}
+
+// runDriftChecks checks every deployed single deploy and stack once.
+func (s *Server) runDriftChecks(ctx context.Context) {
+	stacks, err := s.data.ListStackDeploys(ctx, ownershipScanLimit)
+	if err != nil {
+		slog.Warn("drift check: failed to list stack deploys", "error", err)
+		return
+	}
+	history, err := s.data.GetDeployHistory(ctx, ownershipScanLimit)
+	if err != nil {
+		slog.Warn("drift check: failed to list deploy history", "error", err)
+		return
+	}
+
+	stackRecords := make(map[string]models.StackDeployRecord, len(stacks))
+	for _, st := range stacks {
+		stackRecords[st.DeployID] = st
+	}
+
+	// Older deploys of a service were replaced on the cluster by the latest one; checking
+	// them would report drift forever
+	latest := latestSingleDeploys(history, stackRecords)
+	var superseded []string
+	for i := range history {
+		d := &history[i]
+		if d.Status != "deployed" || d.ManifestJSON == "" || d.FanOut != nil || stackOfHistoryID(d.ID, stackRecords) != "" {
+			continue
+		}
+		if latest[deployServiceKey(d)] != d.ID {
+			superseded = append(superseded, d.ID)
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		s.checkDeployDrift(ctx, d)
+	}
+	s.driftMu.Lock()
+	for _, id := range superseded {
+		delete(s.driftReports, id)
+	}
+	s.driftMu.Unlock()
+	for i := range stacks {
+		st := &stacks[i]
+		if (st.Status != "deployed" && st.Status != "completed") || st.ManifestsJSON == "" || st.FanOut != nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		s.checkStackDrift(ctx, st)
+	}
+}
+
+// deployServiceKey identifies the objects a single deploy manages: a later deploy of
+// the same service to the same cluster and namespace replaces them.
+func deployServiceKey(d *models.DeploymentHistory) string {
+	ns := d.Namespace
+	if ns == "" {
+		ns = "default"
+	}
+	return d.TargetCluster + "/" + ns + "/" + d.ServiceName
+}
+
+// latestSingleDeploys maps each deployServiceKey to the ID of its most recent deployed
+// single deploy. Stack services and fan-out parents are left out.
+func latestSingleDeploys(history []models.DeploymentHistory, stackRecords map[string]models.StackDeployRecord) map[string]string {
+	latest := make(map[string]string)
+	deployedAt := make(map[string]time.Time)
+	for i := range history {
+		d := &history[i]
+		if d.Status != "deployed" || d.FanOut != nil || stackOfHistoryID(d.ID, stackRecords) != "" {
+			continue
+		}
+		key := deployServiceKey(d)
+		if at, ok := deployedAt[key]; !ok || d.DeployedAt.After(at) {
+			latest[key] = d.ID
+			deployedAt[key] = d.DeployedAt
+		}
+	}
+	return latest
+}
+
+// checkDeployDrift compares the stored manifests of a single deploy with the cluster
+// and records the result.
+func (s *Server) checkDeployDrift(ctx context.Context, d *models.DeploymentHistory) *models.DriftReport {
+	ctx, cancel := context.WithTimeout(ctx, driftCheckTimeout)
+	defer cancel()
+
+	report := &models.DriftReport{
+		DeployID:  d.ID,
+		Type:      "single",
+		Cluster:   d.TargetCluster,
+		Resources: []models.ResourceDrift{},
+	}
+	var manifests models.ManifestResult
+	if err := json.Unmarshal([]byte(d.ManifestJSON), &manifests); err != nil {
+		report.Resources = append(report.Resources, models.ResourceDrift{Status: "error", Error: "invalid stored manifest: " + err.Error()})
+	} else {
+		for _, yamlContent := range deployManifestsInOrder(&manifests) {
+			report.Resources = append(report.Resources, s.detectDrift(ctx, d.TargetCluster, d.ServiceName, yamlContent)...)
+		}
+	}
+
+	s.recordDrift(report)
+	return report
+}
+
+// checkStackDrift compares the stored manifests of a stack deploy with the cluster
+// and records the result.
+func (s *Server) checkStackDrift(ctx context.Context, st *models.StackDeployRecord) *models.DriftReport {
+	ctx, cancel := context.WithTimeout(ctx, driftCheckTimeout)
+	defer cancel()
+
+	report := &models.DriftReport{
+		DeployID:  st.DeployID,
+		Type:      "stack",
+		Cluster:   st.ClusterName,
+		Resources: []models.ResourceDrift{},
+	}
+	var manifests map[string]map[string]string
+	if err := json.Unmarshal([]byte(st.ManifestsJSON), &manifests); err != nil {
+		report.Resources = append(report.Resources, models.ResourceDrift{Status: "error", Error: "invalid stored manifests: " + err.Error()})
+	} else {
+		forEachStackManifest(manifests, func(kind, name, yamlContent string) {
+			report.Resources = append(report.Resources, s.detectDrift(ctx, st.ClusterName, name, yamlContent)...)
+		})
+	}
+
+	s.recordDrift(report)
+	return report
+}
+
+// detectDrift wraps kubernetes.DetectDrift so that manifest-level failures show up
+// as an "error" entry instead of aborting the whole check.
+func (s *Server) detectDrift(ctx context.Context, cluster, service, yamlContent string) []models.ResourceDrift {
+	drifts, err := s.kubernetes.DetectDrift(ctx, cluster, yamlContent)
+	if err != nil {
+		return []models.ResourceDrift{{Service: service, Status: "error", Error: err.Error()}}
+	}
+	for i := range drifts {
+		drifts[i].Service = service
+	}
+	return drifts
+}
+
+// recordDrift stores the report for the drift endpoints and persists its status.
+func (s *Server) recordDrift(report *models.DriftReport) {
+	report.Status = driftStatusOf(report.Resources)
+	report.CheckedAt = time.Now()
+
+	s.driftMu.Lock()
+	if s.driftReports == nil {
+		s.driftReports = make(map[string]*models.DriftReport)
+	}
+	s.driftReports[report.DeployID] = report
+	s.driftMu.Unlock()
+
+	ctx := context.Background()
+	var err error
+	if report.Type == "stack" {
+		err = s.data.UpdateStackDriftStatus(ctx, report.DeployID, report.Status, report.CheckedAt)
+	} else {
+		err = s.data.UpdateDriftStatus(ctx, report.DeployID, report.Status, report.CheckedAt)
+	}
+	if err != nil {
+		slog.Warn("failed to persist drift status", "deploy_id", report.DeployID, "error", err)
+	}
+	if report.Status != "in_sync" {
+		slog.Info("drift detected", "deploy_id", report.DeployID, "type", report.Type, "status", report.Status)
+	}
+}
+
+// driftReport returns the latest in-memory report of a deploy, if any.
+func (s *Server) driftReport(deployID string) *models.DriftReport {
+	s.driftMu.RLock()
+	defer s.driftMu.RUnlock()
+	return s.driftReports[deployID]
+}
+
+// driftStatusOf summarizes per-object results: drift wins over check errors.
+func driftStatusOf(resources []models.ResourceDrift) string {
+	status := "in_sync"
+	for _, r := range resources {
+		switch r.Status {
+		case "drifted", "missing":
+			return "drifted"
+		case "error":
+			status = "error"
+		}
+	}
+	return status
+}
+
+// deployManifestsInOrder returns the non-empty manifests of a single deploy in apply order.
+func deployManifestsInOrder(m *models.ManifestResult) []string {
+	var docs []string
+	for _, yamlContent := range []string{m.ConfigMap, m.Deployment, m.Service, m.HPA} {
+		if yamlContent != "" {
+			docs = append(docs, yamlContent)
+		}
+	}
+	return docs
+}
+
+// forEachStackManifest visits the manifests of a stack in apply order, names sorted within a kind.
+func forEachStackManifest(manifests map[string]map[string]string, fn func(kind, name, yamlContent string)) {
+	for _, kind := range orderedManifestKinds(manifests) {
+		names := make([]string, 0, len(manifests[kind]))
+		for name := range manifests[kind] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fn(kind, name, manifests[kind][name])
+		}
+	}
+}
+
+// handleListDriftReports returns the latest drift report of every checked deploy.
+func (s *Server) handleListDriftReports(c *gin.Context) {
+	s.driftMu.RLock()
+	reports := make([]*models.DriftReport, 0, len(s.driftReports))
+	for _, r := range s.driftReports {
+		if status := c.Query("status"); status == "" || r.Status == status {
+			reports = append(reports, r)
+		}
+	}
+	s.driftMu.RUnlock()
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].CheckedAt.After(reports[j].CheckedAt) })
+	c.JSON(http.StatusOK, gin.H{"reports": reports, "total": len(reports)})
+}
+
+// loadDeployedDeployment fetches a single deploy that drift actions can run on.
+func (s *Server) loadDeployedDeployment(c *gin.Context) (*models.DeploymentHistory, bool) {
+	deployment, err := s.data.GetDeployment(c.Request.Context(), c.Param("deploy_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "deployment not found"},
+		})
+		return nil, false
+	}
+	if deployment.Status != "deployed" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_STATE", Message: "deployment is not in 'deployed' state"},
+		})
+		return nil, false
+	}
+	if deployment.ManifestJSON == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "NO_MANIFEST", Message: "no stored manifest to compare with"},
+		})
+		return nil, false
+	}
+	return deployment, true
+}
+
+// rejectSuperseded fails the request with 409 SUPERSEDED when a later deploy of the same
+// service has replaced the objects of deployment: re-applying or adopting it would roll
+// the live service back or overwrite the record with another deploy's objects.
+func (s *Server) rejectSuperseded(c *gin.Context, deployment *models.DeploymentHistory) bool {
+	ctx := c.Request.Context()
+	history, err := s.data.GetDeployHistory(ctx, ownershipScanLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return true
+	}
+	var stackRecords map[string]models.StackDeployRecord
+	if stacks, err := s.data.ListStackDeploys(ctx, ownershipScanLimit); err == nil {
+		stackRecords = make(map[string]models.StackDeployRecord, len(stacks))
+		for _, st := range stacks {
+			stackRecords[st.DeployID] = st
+		}
+	}
+	latest, ok := latestSingleDeploys(history, stackRecords)[deployServiceKey(deployment)]
+	if !ok || latest == deployment.ID {
+		return false
+	}
+	c.JSON(http.StatusConflict, models.ErrorResponse{
+		Error: models.ErrorDetail{Code: "SUPERSEDED", Message: "deployment was superseded by deploy " + latest + " of the same service"},
+	})
+	return true
+}
+
+// handleCheckDeployDrift runs a drift check of a single deploy now.
+func (s *Server) handleCheckDeployDrift(c *gin.Context) {
+	deployment, ok := s.loadDeployedDeployment(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, s.checkDeployDrift(c.Request.Context(), deployment))
+}
+
+// handleReapplyDeployDrift re-applies the stored manifests of a single deploy,
+// overwriting live changes, and checks drift again. Superseded deploys are refused.
+func (s *Server) handleReapplyDeployDrift(c *gin.Context) {
+	deployment, ok := s.loadDeployedDeployment(c)
+	if !ok {
+		return
+	}
+	if s.rejectSuperseded(c, deployment) {
+		return
+	}
+	var manifests models.ManifestResult
+	if err := json.Unmarshal([]byte(deployment.ManifestJSON), &manifests); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: "invalid stored manifest: " + err.Error()},
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	applied := []models.AppliedResource{}
+	for _, yamlContent := range deployManifestsInOrder(&manifests) {
+		resources, err := s.kubernetes.ApplyManifest(ctx, deployment.TargetCluster, yamlContent)
+		applied = append(applied, resources...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":     models.ErrorDetail{Code: "K8S_ERROR", Message: err.Error()},
+				"resources": applied,
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"resources": applied,
+		"drift":     s.checkDeployDrift(ctx, deployment),
+	})
+}
+
+// handleAdoptDeployDrift rewrites the stored manifests of a single deploy from the
+// live objects, so the live state becomes the desired state. Superseded deploys are
+// refused.
+func (s *Server) handleAdoptDeployDrift(c *gin.Context) {
+	deployment, ok := s.loadDeployedDeployment(c)
+	if !ok {
+		return
+	}
+	if s.rejectSuperseded(c, deployment) {
+		return
+	}
+	var manifests models.ManifestResult
+	if err := json.Unmarshal([]byte(deployment.ManifestJSON), &manifests); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: "invalid stored manifest: " + err.Error()},
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	for _, m := range []*string{&manifests.ConfigMap, &manifests.Deployment, &manifests.Service, &manifests.HPA} {
+		if *m == "" {
+			continue
+		}
+		adopted, err := s.kubernetes.AdoptLive(ctx, deployment.TargetCluster, *m)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "K8S_ERROR", Message: err.Error()},
+			})
+			return
+		}
+		*m = adopted
+	}
+
+	manifestJSON, err := json.Marshal(manifests)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	if err := s.data.UpdateDeploymentManifest(ctx, deployment.ID, string(manifestJSON)); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	deployment.ManifestJSON = string(manifestJSON)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"manifests": manifests,
+		"drift":     s.checkDeployDrift(ctx, deployment),
+	})
+}
+
+// loadDeployedStack fetches a stack deploy that drift actions can run on.
+func (s *Server) loadDeployedStack(c *gin.Context) (*models.StackDeployRecord, bool) {
+	record, err := s.data.GetStackDeploy(c.Request.Context(), c.Param("deploy_id"))
+	if err != nil || record == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "stack deploy not found"},
+		})
+		return nil, false
+	}
+	if record.Status != "deployed" && record.Status != "completed" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_STATE", Message: fmt.Sprintf("stack is not deployed (current: %s)", record.Status)},
+		})
+		return nil, false
+	}
+	if record.ManifestsJSON == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "NO_MANIFEST", Message: "no stored manifests to compare with"},
+		})
+		return nil, false
+	}
+	return record, true
+}
+
+// handleCheckStackDrift runs a drift check of a stack deploy now.
+func (s *Server) handleCheckStackDrift(c *gin.Context) {
+	record, ok := s.loadDeployedStack(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, s.checkStackDrift(c.Request.Context(), record))
+}
+
+// handleReapplyStackDrift re-applies the stored manifests of a stack deploy in apply
+// order, overwriting live changes, and checks drift again.
+func (s *Server) handleReapplyStackDrift(c *gin.Context) {
+	record, ok := s.loadDeployedStack(c)
+	if !ok {
+		return
+	}
+	var manifests map[string]map[string]string
+	if err := json.Unmarshal([]byte(record.ManifestsJSON), &manifests); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: "invalid stored manifests: " + err.Error()},
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	applied := []models.AppliedResource{}
+	var applyErr error
+	forEachStackManifest(manifests, func(kind, name, yamlContent string) {
+		if applyErr != nil {
+			return
+		}
+		resources, err := s.kubernetes.ApplyManifest(ctx, record.ClusterName, yamlContent)
+		applied = append(applied, resources...)
+		if err != nil {
+			applyErr = fmt.Errorf("%s/%s: %w", kind, name, err)
+		}
+	})
+	if applyErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":     models.ErrorDetail{Code: "K8S_ERROR", Message: applyErr.Error()},
+			"resources": applied,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"resources": applied,
+		"drift":     s.checkStackDrift(ctx, record),
+	})
+}
+
+// handleAdoptStackDrift rewrites the stored manifests of a stack deploy from the live
+// objects, so the live state becomes the desired state.
+func (s *Server) handleAdoptStackDrift(c *gin.Context) {
+	record, ok := s.loadDeployedStack(c)
+	if !ok {
+		return
+	}
+	var manifests map[string]map[string]string
+	if err := json.Unmarshal([]byte(record.ManifestsJSON), &manifests); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: "invalid stored manifests: " + err.Error()},
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	adopted := make(map[string]map[string]string, len(manifests))
+	var adoptErr error
+	forEachStackManifest(manifests, func(kind, name, yamlContent string) {
+		if adoptErr != nil {
+			return
+		}
+		live, err := s.kubernetes.AdoptLive(ctx, record.ClusterName, yamlContent)
+		if err != nil {
+			adoptErr = fmt.Errorf("%s/%s: %w", kind, name, err)
+			return
+		}
+		if live == "" {
+			return // deleted from the cluster
+		}
+		if adopted[kind] == nil {
+			adopted[kind] = map[string]string{}
+		}
+		adopted[kind][name] = live
+	})
+	if adoptErr != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "K8S_ERROR", Message: adoptErr.Error()},
+		})
+		return
+	}
+
+	manifestsJSON, err := json.Marshal(adopted)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	record.ManifestsJSON = string(manifestsJSON)
+	record.UpdatedAt = time.Now()
+	if err := s.data.UpdateStackDeploy(ctx, record); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+
+	// Keep a restored in-memory state in step so a later redeploy applies the adopted manifests
+	s.mu.Lock()
+	if state, ok := s.stackDeployStates[record.DeployID]; ok {
+		if state.Manifests != nil {
+			state.Manifests.Manifests = adopted
+		}
+		if state.Response != nil {
+			state.Response.Manifests = models.StackManifests(adopted)
+		}
+	}
+	s.mu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"manifests": adopted,
+		"drift":     s.checkStackDrift(ctx, record),
+	})
+}
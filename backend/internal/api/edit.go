@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// manifestResources maps the resource names of manifest edits to the kind each holds.
+var manifestResources = map[string]string{
+	"deployment": "Deployment",
+	"service":    "Service",
+	"hpa":        "HorizontalPodAutoscaler",
+	"configmap":  "ConfigMap",
+}
+
+// manifestField returns the manifest of a resource name, or nil for an unknown name.
+func manifestField(m *models.ManifestResult, resource string) *string {
+	switch resource {
+	case "deployment":
+		return &m.Deployment
+	case "service":
+		return &m.Service
+	case "hpa":
+		return &m.HPA
+	case "configmap":
+		return &m.ConfigMap
+	}
+	return nil
+}
+
+// applyManifestEdits returns a copy of the manifests with the modifications (replacement
+// YAML per resource, in resource-name order) and then the edits applied. Every edited
+// resource must pass the YAML and schema checks and still hold an object of its kind.
+func applyManifestEdits(m *models.ManifestResult, modifications map[string]string, edits []models.ManifestEdit) (*models.ManifestResult, error) {
+	all := make([]models.ManifestEdit, 0, len(modifications)+len(edits))
+	resources := make([]string, 0, len(modifications))
+	for resource := range modifications {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		all = append(all, models.ManifestEdit{Resource: resource, Type: models.EditReplace, YAML: modifications[resource]})
+	}
+	all = append(all, edits...)
+
+	edited := *m
+	edited.Cached = false // edited manifests are no longer the cached AI response
+	for i, edit := range all {
+		if err := applyManifestEdit(&edited, edit); err != nil {
+			return nil, fmt.Errorf("edit %d (%s): %w", i+1, edit.Resource, err)
+		}
+	}
+	return &edited, nil
+}
+
+func applyManifestEdit(m *models.ManifestResult, edit models.ManifestEdit) error {
+	field := manifestField(m, edit.Resource)
+	if field == nil {
+		return fmt.Errorf("resource must be deployment, service, hpa or configmap")
+	}
+	editType := edit.Type
+	if editType == "" {
+		editType = models.EditReplace
+		if edit.Patch != "" {
+			editType = models.EditStrategicMerge
+		}
+	}
+
+	var content string
+	if editType == models.EditReplace {
+		if edit.YAML == "" {
+			if edit.Resource == "deployment" || edit.Resource == "service" {
+				return fmt.Errorf("the %s cannot be removed", edit.Resource)
+			}
+			*field = ""
+			return nil
+		}
+		content = edit.YAML
+	} else {
+		if *field == "" {
+			return fmt.Errorf("no %s manifest to patch", edit.Resource)
+		}
+		if edit.Patch == "" {
+			return fmt.Errorf("patch is required for a %s edit", editType)
+		}
+		patched, err := kubernetes.EditManifest(*field, editType, edit.Patch)
+		if err != nil {
+			return err
+		}
+		content = patched
+	}
+
+	kinds, err := kubernetes.ValidateManifest(content)
+	if err != nil {
+		return err
+	}
+	want := manifestResources[edit.Resource]
+	for _, kind := range kinds {
+		if kind == want {
+			*field = content
+			return nil
+		}
+	}
+	return fmt.Errorf("manifest has no %s", want)
+}
+
+// editDeployManifests applies edits to a deploy's manifests and stores the result as a
+// new manifest revision. Nothing changes when an edit fails.
+func (s *Server) editDeployManifests(ctx context.Context, deployID string, state *deployState, modifications map[string]string, edits []models.ManifestEdit) error {
+	s.mu.Lock()
+	edited, err := applyManifestEdits(state.Manifests, modifications, edits)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.setDeployManifests(state, edited)
+	s.mu.Unlock()
+
+	version := s.recordRevision(ctx, deployID, "single", models.RevisionEdit, "", 0, edited)
+
+	s.mu.Lock()
+	state.Response.ManifestVersion = revisionVersion(version, state.Response.ManifestVersion)
+	s.mu.Unlock()
+	s.saveDeployToDB(ctx, state)
+	return nil
+}
+
+// handleEditDeploy applies manual edits to the generated manifests of a deploy, as an
+// alternative to refining them through the AI.
+func (s *Server) handleEditDeploy(c *gin.Context) {
+	deployID := c.Param("deploy_id")
+
+	var req models.EditManifestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return
+	}
+	if len(req.Modifications) == 0 && len(req.Edits) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "modifications or edits are required"},
+		})
+		return
+	}
+
+	s.mu.RLock()
+	state, exists := s.deployStates[deployID]
+	var status string
+	if exists {
+		status = state.Status.Status
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "deployment not found"},
+		})
+		return
+	}
+	if status == "deploying" || status == models.RolloutAwaitingPromotion {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DEPLOY_IN_PROGRESS", Message: "cannot edit manifests while the deployment is in progress"},
+		})
+		return
+	}
+
+	if err := s.editDeployManifests(c.Request.Context(), deployID, state, req.Modifications, req.Edits); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_MODIFICATION", Message: err.Error()},
+		})
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c.JSON(http.StatusOK, state.Response)
+}
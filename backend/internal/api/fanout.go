@@ -0,0 +1,595 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// A multi-cluster deploy ("fan-out") is generated and approved once. Executing it runs
+// one ordinary deploy per target cluster, with the target's overrides applied to the
+// approved manifests. The per-cluster deploys have their own IDs, history entries and
+// ownership labels, so undeploy, redeploy and drift work per cluster; the parent keeps
+// a history entry that links them.
+
+// fanOutWave is one step of a multi-cluster deploy: the targets deployed together.
+type fanOutWave struct {
+	Number  int
+	Targets []int // indices into the request's targets
+}
+
+// validateFanOut checks the targets and strategy of a multi-cluster deploy request.
+func validateFanOut(targets []models.ClusterTarget, strategy string) error {
+	if strategy != "" && strategy != models.FanOutParallel && strategy != models.FanOutWaves {
+		return fmt.Errorf("strategy must be %q or %q", models.FanOutParallel, models.FanOutWaves)
+	}
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if seen[t.ClusterName] {
+			return fmt.Errorf("cluster %q is targeted more than once", t.ClusterName)
+		}
+		seen[t.ClusterName] = true
+		if t.Replicas < 0 || t.Wave < 0 {
+			return fmt.Errorf("cluster %q: replicas and wave must not be negative", t.ClusterName)
+		}
+	}
+	return nil
+}
+
+// fanOutWaves groups the targets into waves in execution order. The parallel strategy
+// deploys every target in one wave; with the waves strategy a target without a wave
+// gets its position in the list, so unnumbered targets roll out one at a time.
+func fanOutWaves(targets []models.ClusterTarget, strategy string) []fanOutWave {
+	byNumber := map[int]*fanOutWave{}
+	var numbers []int
+	for i, t := range targets {
+		n := 1
+		if strategy == models.FanOutWaves {
+			n = t.Wave
+			if n == 0 {
+				n = i + 1
+			}
+		}
+		w, ok := byNumber[n]
+		if !ok {
+			w = &fanOutWave{Number: n}
+			byNumber[n] = w
+			numbers = append(numbers, n)
+		}
+		w.Targets = append(w.Targets, i)
+	}
+	sort.Ints(numbers)
+	waves := make([]fanOutWave, 0, len(numbers))
+	for _, n := range numbers {
+		waves = append(waves, *byNumber[n])
+	}
+	return waves
+}
+
+// fanOutChildID is the deploy ID of the n-th target of a multi-cluster deploy.
+func fanOutChildID(parentID string, i int) string {
+	return fmt.Sprintf("%s-%d", parentID, i+1)
+}
+
+// fanOutStatus summarizes the outcome of the per-cluster deploys: every target
+// succeeded, none did, or some did ("partial").
+func fanOutStatus(succeeded, total int, success string) string {
+	switch succeeded {
+	case total:
+		return success
+	case 0:
+		return "failed"
+	default:
+		return "partial"
+	}
+}
+
+// runFanOut executes the waves in order; every target of a wave runs concurrently and
+// run reports whether its deploy succeeded. With the waves strategy a wave with a
+// failed target stops the rollout and skip is called for the targets of later waves.
+// step reports the progress of each "wave_<n>" step.
+func runFanOut(waves []fanOutWave, strategy string, clusters []string, run func(i int) bool, skip func(i int), step func(name, status, message string)) {
+	stopped := false
+	for _, w := range waves {
+		name := fmt.Sprintf("wave_%d", w.Number)
+		names := make([]string, 0, len(w.Targets))
+		for _, i := range w.Targets {
+			names = append(names, clusters[i])
+		}
+
+		if stopped {
+			for _, i := range w.Targets {
+				skip(i)
+			}
+			step(name, "skipped", "Skipped due to a failed earlier wave")
+			continue
+		}
+
+		step(name, "in_progress", "Deploying to "+strings.Join(names, ", "))
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var failed []string
+		for _, i := range w.Targets {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if !run(i) {
+					mu.Lock()
+					failed = append(failed, clusters[i])
+					mu.Unlock()
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if len(failed) > 0 {
+			sort.Strings(failed)
+			step(name, "failed", "Failed on "+strings.Join(failed, ", "))
+			stopped = strategy == models.FanOutWaves
+			continue
+		}
+		step(name, "completed", "Deployed to "+strings.Join(names, ", "))
+	}
+}
+
+// fanOutSteps returns one pending step per wave.
+func fanOutSteps(waves []fanOutWave, targets []models.ClusterTarget) []models.DeployStep {
+	steps := make([]models.DeployStep, 0, len(waves))
+	for _, w := range waves {
+		names := make([]string, 0, len(w.Targets))
+		for _, i := range w.Targets {
+			names = append(names, targets[i].ClusterName)
+		}
+		steps = append(steps, models.DeployStep{
+			Step:    fmt.Sprintf("wave_%d", w.Number),
+			Status:  "pending",
+			Message: strings.Join(names, ", "),
+		})
+	}
+	return steps
+}
+
+// targetOverrides returns the overrides of a target, with the namespace falling back to
+// the namespace of the request so every cluster deploys where the request asked.
+func targetOverrides(t models.ClusterTarget, namespace string) models.ManifestOverrides {
+	o := t.ManifestOverrides
+	if o.Namespace == "" {
+		o.Namespace = namespace
+	}
+	return o
+}
+
+// overrideManifestResult applies the overrides to each manifest of a single deploy.
+func overrideManifestResult(m *models.ManifestResult, o models.ManifestOverrides) (*models.ManifestResult, error) {
+	if m == nil {
+		return nil, nil
+	}
+	out := *m
+	for _, f := range []*string{&out.Deployment, &out.Service, &out.HPA, &out.ConfigMap} {
+		if *f == "" {
+			continue
+		}
+		overridden, err := kubernetes.ApplyOverrides(*f, o)
+		if err != nil {
+			return nil, err
+		}
+		*f = overridden
+	}
+	return &out, nil
+}
+
+// overrideStackManifests returns a copy of the stack manifests with the overrides
+// applied. A renamed Namespace object is re-keyed under its new name.
+func overrideStackManifests(manifests map[string]map[string]string, o models.ManifestOverrides) (map[string]map[string]string, error) {
+	out := make(map[string]map[string]string, len(manifests))
+	for kind, resources := range manifests {
+		out[kind] = make(map[string]string, len(resources))
+		for name, content := range resources {
+			overridden, err := kubernetes.ApplyOverrides(content, o)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", kind, name, err)
+			}
+			if kind == "Namespace" && o.Namespace != "" {
+				name = o.Namespace
+			}
+			out[kind][name] = overridden
+		}
+	}
+	return out, nil
+}
+
+// --- single deploys ---
+
+// startDeployFanOut creates the per-cluster deploys of an approved multi-cluster deploy
+// and executes them in the background. The parent status nests the status of each.
+func (s *Server) startDeployFanOut(ctx context.Context, deployID string, state *deployState, skipRollback bool) error {
+	targets := state.Request.Targets
+	strategy := state.Request.Strategy
+	if err := validateFanOut(targets, strategy); err != nil {
+		return err
+	}
+	waves := fanOutWaves(targets, strategy)
+	waveOf := map[int]int{}
+	for _, w := range waves {
+		for _, i := range w.Targets {
+			waveOf[i] = w.Number
+		}
+	}
+
+	// Apply every override before anything runs, so a bad override deploys nothing
+	now := time.Now()
+	children := make([]*deployState, len(targets))
+	gitTargets := make([]*models.GitDelivery, len(targets))
+	for i, t := range targets {
+		manifests, err := overrideManifestResult(state.Manifests, targetOverrides(t, state.Request.Namespace))
+		if err != nil {
+			return fmt.Errorf("cluster %q: %w", t.ClusterName, err)
+		}
+		req := *state.Request
+		req.ClusterName = t.ClusterName
+		req.Targets, req.Strategy = nil, ""
+		if t.Namespace != "" {
+			req.Namespace = t.Namespace
+		}
+
+		steps := directDeploySteps()
+		if gitTargets[i] = s.clusterDelivery(ctx, t.ClusterName); gitTargets[i] != nil {
+			steps = gitDeploySteps(*gitTargets[i])
+		}
+
+		// Each cluster deploy has its own response: its ID, overridden manifests and the
+		// cost under the pricing of its cluster
+		s.mu.RLock()
+		resp := *state.Response
+		s.mu.RUnlock()
+		resp.DeployID = fanOutChildID(deployID, i)
+		resp.Manifests = &models.Manifests{
+			Deployment: manifests.Deployment,
+			Service:    manifests.Service,
+			HPA:        manifests.HPA,
+			ConfigMap:  manifests.ConfigMap,
+		}
+		setRecommendations(&resp, manifests)
+		resp.EstimatedCost = s.estimateCost(t.ClusterName, manifestDocuments(manifests))
+
+		children[i] = &deployState{
+			Status: &models.DeployStatus{
+				DeployID:    resp.DeployID,
+				Status:      "pending",
+				StartedAt:   &now,
+				Steps:       steps,
+				ClusterName: t.ClusterName,
+				Wave:        waveOf[i],
+			},
+			Response:     &resp,
+			Request:      &req,
+			Manifests:    manifests,
+			SkipRollback: skipRollback,
+			ParentID:     deployID,
+		}
+	}
+
+	s.mu.Lock()
+	state.Status.Status = "deploying"
+	state.Status.Steps = fanOutSteps(waves, targets)
+	state.Status.Clusters = make(map[string]*models.DeployStatus, len(children))
+	state.SkipRollback = skipRollback
+	for _, child := range children {
+		s.deployStates[child.Status.DeployID] = child
+		state.Status.Clusters[child.Status.ClusterName] = child.Status
+	}
+	s.mu.Unlock()
+	s.saveDeployToDB(ctx, state)
+	for _, child := range children {
+		s.saveDeployToDB(ctx, child)
+	}
+
+	go s.executeDeployFanOut(deployID, state, children, gitTargets, waves)
+	return nil
+}
+
+// executeDeployFanOut runs the per-cluster deploys wave by wave and records the parent
+// history entry linking their results.
+func (s *Server) executeDeployFanOut(deployID string, state *deployState, children []*deployState, gitTargets []*models.GitDelivery, waves []fanOutWave) {
+	clusters := make([]string, len(children))
+	for i, child := range children {
+		clusters[i] = child.Status.ClusterName
+	}
+
+	run := func(i int) bool {
+		childID := children[i].Status.DeployID
+		s.mu.Lock()
+		children[i].Status.Status = "deploying"
+		s.mu.Unlock()
+		if gitTargets[i] != nil {
+			s.executeGitDeployAsync(childID, *gitTargets[i])
+		} else {
+			s.executeDeployAsync(childID)
+		}
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return children[i].Status.Status == "completed"
+	}
+	skip := func(i int) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		now := time.Now()
+		children[i].Status.Status = "skipped"
+		children[i].Status.CompletedAt = &now
+		for j := range children[i].Status.Steps {
+			children[i].Status.Steps[j].Status = "skipped"
+		}
+	}
+	runFanOut(waves, state.Request.Strategy, clusters, run, skip, s.deployStepUpdater(state))
+	for _, child := range children {
+		if child.Status.Status == "skipped" {
+			s.saveDeployToDB(context.Background(), child)
+		}
+	}
+
+	s.mu.Lock()
+	succeeded := 0
+	results := make([]models.TargetResult, len(children))
+	for i, child := range children {
+		results[i] = models.TargetResult{
+			ClusterTarget: state.Request.Targets[i],
+			DeployID:      child.Status.DeployID,
+			Status:        child.Status.Status,
+		}
+		if child.Status.Commit != nil {
+			results[i].CommitSHA = child.Status.Commit.SHA
+		}
+		if child.Status.Status == "completed" {
+			succeeded++
+		}
+	}
+	now := time.Now()
+	state.Status.Status = fanOutStatus(succeeded, len(children), "completed")
+	state.Status.CompletedAt = &now
+	s.mu.Unlock()
+	s.saveDeployToDB(context.Background(), state)
+
+	// The parent entry only links the per-cluster entries, which hold the manifests
+	history := deployHistoryRecord(deployID, state, now, succeeded > 0)
+	history.ManifestJSON = ""
+	if state.Status.Status == "partial" {
+		history.Status = "partial"
+	}
+	history.FanOut = &models.FanOut{Strategy: fanOutStrategy(state.Request.Strategy), Targets: results}
+	if err := s.data.SaveDeployment(context.Background(), history); err != nil {
+		slog.Error("failed to save deployment history", "error", err)
+	}
+	slog.Info("multi-cluster deployment finished", "deploy_id", deployID, "status", state.Status.Status, "clusters", len(children))
+}
+
+// fanOutStrategy returns the effective strategy of a request.
+func fanOutStrategy(strategy string) string {
+	if strategy == "" {
+		return models.FanOutParallel
+	}
+	return strategy
+}
+
+// deployStepUpdater returns a step reporter for the steps of a single deploy.
+func (s *Server) deployStepUpdater(state *deployState) func(name, status, message string) {
+	return func(name, status, message string) {
+		s.mu.Lock()
+		for i, step := range state.Status.Steps {
+			if step.Step == name {
+				state.Status.Steps[i].Status = status
+				state.Status.Steps[i].Message = message
+				if status == "completed" || status == "failed" || status == "skipped" {
+					now := time.Now()
+					state.Status.Steps[i].CompletedAt = &now
+				}
+				break
+			}
+		}
+		s.mu.Unlock()
+		s.saveDeployToDB(context.Background(), state)
+	}
+}
+
+// --- stack deploys ---
+
+// startStackFanOut creates the per-cluster stack deploys of an approved multi-cluster
+// stack deploy and executes them in the background.
+func (s *Server) startStackFanOut(ctx context.Context, deployID string, state *stackDeployState) error {
+	if state.Manifests == nil {
+		return fmt.Errorf("stack manifests have not been generated yet")
+	}
+	targets := state.Request.Targets
+	strategy := state.Request.Strategy
+	if err := validateFanOut(targets, strategy); err != nil {
+		return err
+	}
+	waves := fanOutWaves(targets, strategy)
+	waveOf := map[int]int{}
+	for _, w := range waves {
+		for _, i := range w.Targets {
+			waveOf[i] = w.Number
+		}
+	}
+
+	now := time.Now()
+	children := make([]*stackDeployState, len(targets))
+	for i, t := range targets {
+		manifests, err := overrideStackManifests(state.Manifests.Manifests, targetOverrides(t, state.Request.Namespace))
+		if err != nil {
+			return fmt.Errorf("cluster %q: %w", t.ClusterName, err)
+		}
+		req := *state.Request
+		req.ClusterName = t.ClusterName
+		req.Targets, req.Strategy = nil, ""
+		if t.Namespace != "" {
+			req.Namespace = t.Namespace
+		}
+
+		childID := fanOutChildID(deployID, i)
+		services := make(map[string]*models.ServiceDeployStatus, len(state.Status.DeployOrder))
+		for _, svcName := range state.Status.DeployOrder {
+			services[svcName] = &models.ServiceDeployStatus{
+				ServiceName: svcName,
+				Status:      "pending",
+				Steps:       buildServiceSteps(svcName, manifests),
+			}
+		}
+		topology := state.Manifests.Topology
+		children[i] = &stackDeployState{
+			Status: &models.StackDeployStatus{
+				DeployID:    childID,
+				Status:      "pending",
+				StackName:   state.Status.StackName,
+				StartedAt:   &now,
+				Services:    services,
+				DeployOrder: state.Status.DeployOrder,
+				ClusterName: t.ClusterName,
+				Wave:        waveOf[i],
+			},
+			Response: &models.StackDeployResponse{
+				DeployID:   childID,
+				Status:     "pending",
+				StackName:  state.Status.StackName,
+				Topology:   &topology,
+				Manifests:  models.StackManifests(manifests),
+				Reasoning:  state.Manifests.Reasoning,
+				Confidence: state.Manifests.Confidence,
+			},
+			Request: &req,
+			Manifests: &ai.StackManifestResult{
+				Topology:   topology,
+				Manifests:  manifests,
+				Reasoning:  state.Manifests.Reasoning,
+				Confidence: state.Manifests.Confidence,
+			},
+			ContainerInfos: state.ContainerInfos,
+			SkipRollback:   state.SkipRollback,
+			ParentID:       deployID,
+		}
+	}
+
+	s.mu.Lock()
+	state.Status.Status = "deploying"
+	state.Response.Status = "deploying"
+	state.Status.Clusters = make(map[string]*models.StackDeployStatus, len(children))
+	for _, child := range children {
+		s.stackDeployStates[child.Status.DeployID] = child
+		state.Status.Clusters[child.Status.ClusterName] = child.Status
+	}
+	s.mu.Unlock()
+
+	for _, child := range children {
+		s.saveStackDeployToDB(ctx, child)
+	}
+	s.updateStackDeployInDB(ctx, state)
+
+	go s.executeStackFanOut(deployID, state, children, waves)
+	return nil
+}
+
+// executeStackFanOut runs the per-cluster stack deploys wave by wave.
+func (s *Server) executeStackFanOut(deployID string, state *stackDeployState, children []*stackDeployState, waves []fanOutWave) {
+	ctx := context.Background()
+	clusters := make([]string, len(children))
+	for i, child := range children {
+		clusters[i] = child.Status.ClusterName
+	}
+
+	run := func(i int) bool {
+		child := children[i]
+		s.mu.Lock()
+		child.Status.Status = "deploying"
+		child.Response.Status = "deploying"
+		s.mu.Unlock()
+		s.updateStackDeployInDB(ctx, child)
+
+		s.executeStackDeployAsync(child.Status.DeployID)
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return child.Status.Status == "deployed"
+	}
+	skip := func(i int) {
+		child := children[i]
+		s.mu.Lock()
+		now := time.Now()
+		child.Status.Status = "skipped"
+		child.Response.Status = "skipped"
+		child.Status.CompletedAt = &now
+		s.mu.Unlock()
+		s.updateStackDeployInDB(ctx, child)
+	}
+	step := func(name, status, message string) {
+		slog.Info("multi-cluster stack wave", "deploy_id", deployID, "wave", name, "status", status, "message", message)
+		if status != "in_progress" {
+			s.updateStackDeployInDB(ctx, state)
+		}
+	}
+	runFanOut(waves, state.Request.Strategy, clusters, run, skip, step)
+
+	s.mu.Lock()
+	succeeded := 0
+	for _, child := range children {
+		if child.Status.Status == "deployed" {
+			succeeded++
+		}
+	}
+	now := time.Now()
+	state.Status.Status = fanOutStatus(succeeded, len(children), "deployed")
+	state.Response.Status = state.Status.Status
+	state.Status.CompletedAt = &now
+	s.mu.Unlock()
+
+	s.updateStackDeployInDB(ctx, state)
+	slog.Info("multi-cluster stack deployment finished", "deploy_id", deployID, "status", state.Status.Status, "clusters", len(children))
+}
+
+// stackFanOut links a multi-cluster stack deploy to its per-cluster deploys, or
+// returns nil for a stack deploy to one cluster.
+func stackFanOut(state *stackDeployState) *models.FanOut {
+	if state.Request == nil || len(state.Request.Targets) == 0 {
+		return nil
+	}
+	fanOut := &models.FanOut{Strategy: fanOutStrategy(state.Request.Strategy)}
+	for i, t := range state.Request.Targets {
+		result := models.TargetResult{ClusterTarget: t, Status: "pending"}
+		if child, ok := state.Status.Clusters[t.ClusterName]; ok {
+			result.DeployID = fanOutChildID(state.Status.DeployID, i)
+			result.Status = child.Status
+			if child.Commit != nil {
+				result.CommitSHA = child.Commit.SHA
+			}
+		}
+		fanOut.Targets = append(fanOut.Targets, result)
+	}
+	return fanOut
+}
+
+// stackFanOutStatuses loads the status of each per-cluster stack deploy of a stored
+// multi-cluster stack deploy.
+func (s *Server) stackFanOutStatuses(ctx context.Context, fanOut *models.FanOut) map[string]*models.StackDeployStatus {
+	clusters := map[string]*models.StackDeployStatus{}
+	for _, t := range fanOut.Targets {
+		if t.DeployID == "" {
+			continue
+		}
+		s.mu.RLock()
+		state, ok := s.stackDeployStates[t.DeployID]
+		s.mu.RUnlock()
+		if ok {
+			clusters[t.ClusterName] = state.Status
+			continue
+		}
+		if record, err := s.data.GetStackDeploy(ctx, t.DeployID); err == nil && record != nil {
+			clusters[t.ClusterName] = recordToStackStatus(record)
+		}
+	}
+	return clusters
+}
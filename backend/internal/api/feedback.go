@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/metrics"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+const (
+	// feedbackSampleTimeout bounds one usage sample of a single deploy.
+	feedbackSampleTimeout = 30 * time.Second
+	// throttleRatio is the share of a container's CFS periods that must be throttled
+	// within one sampling interval to count as a throttle event.
+	throttleRatio = 0.25
+)
+
+// usageTracker accumulates the samples of one deploy during its observation window.
+// It lives in memory: after a restart the counters continue from the stored usage,
+// while the percentiles only cover the samples taken since.
+type usageTracker struct {
+	usage    models.DeploymentUsage
+	cpu      []int64
+	memory   []int64
+	restarts map[string]int                   // pod/container → last restart count
+	cfs      map[string]models.ContainerUsage // pod/container → last CFS counters
+}
+
+// StartFeedback starts the post-deploy feedback job. Every interval it samples each
+// single deploy that is still inside its observation window and writes the measured
+// usage and health events to the deploy's history entry; at the end of the window
+// the entry is marked completed and no longer sampled.
+func (s *Server) StartFeedback(window, interval time.Duration) {
+	if window <= 0 {
+		window = time.Hour
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.feedbackCancel = cancel
+
+	s.feedbackWG.Add(1)
+	go func() {
+		defer s.feedbackWG.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.runFeedback(ctx, window)
+			}
+		}
+	}()
+
+	slog.Info("post-deploy feedback started", "window", window.String(), "interval", interval.String())
+}
+
+// StopFeedback stops the feedback job and waits for a running pass.
+func (s *Server) StopFeedback() {
+	if s.feedbackCancel != nil {
+		s.feedbackCancel()
+	}
+	s.feedbackWG.Wait()
+	slog.Info("post-deploy feedback stopped")
+}
+
+// runFeedback samples or completes every deploy whose feedback is not completed yet.
+func (s *Server) runFeedback(ctx context.Context, window time.Duration) {
+	history, err := s.data.GetDeployHistory(ctx, ownershipScanLimit)
+	if err != nil {
+		slog.Warn("feedback: failed to list deploy history", "error", err)
+		return
+	}
+
+	now := time.Now()
+	active := map[string]bool{}
+	for i := range history {
+		d := &history[i]
+		if d.Status != "deployed" || !d.Success || d.FanOut != nil || d.ManifestJSON == "" || d.TargetCluster == "" {
+			continue
+		}
+		if d.Usage != nil && d.Usage.Completed {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		end := d.DeployedAt.Add(window)
+		if now.Before(end) {
+			active[d.ID] = true
+			s.sampleDeploy(ctx, d)
+		} else {
+			s.completeFeedback(ctx, d)
+		}
+	}
+
+	// Deploys deleted or redeployed during their window
+	s.feedbackMu.Lock()
+	for id := range s.feedbackTrackers {
+		if !active[id] {
+			delete(s.feedbackTrackers, id)
+		}
+	}
+	s.feedbackMu.Unlock()
+}
+
+// sampleDeploy takes one usage sample of a deploy and writes the result so far.
+func (s *Server) sampleDeploy(ctx context.Context, d *models.DeploymentHistory) {
+	ctx, cancel := context.WithTimeout(ctx, feedbackSampleTimeout)
+	defer cancel()
+
+	var manifests models.ManifestResult
+	if err := json.Unmarshal([]byte(d.ManifestJSON), &manifests); err != nil {
+		return
+	}
+	selector := kubernetes.WorkloadSelector(manifests.Deployment)
+	if selector == "" {
+		selector = "app=" + d.ServiceName
+	}
+	sample, err := s.kubernetes.SampleUsage(ctx, d.TargetCluster, d.Namespace, selector)
+	if err != nil {
+		slog.Debug("feedback: failed to sample deploy", "deploy_id", d.ID, "cluster", d.TargetCluster, "error", err)
+		return
+	}
+
+	s.feedbackMu.Lock()
+	if s.feedbackTrackers == nil {
+		s.feedbackTrackers = map[string]*usageTracker{}
+	}
+	tracker, ok := s.feedbackTrackers[d.ID]
+	if !ok {
+		tracker = newUsageTracker(d.Usage)
+		s.feedbackTrackers[d.ID] = tracker
+	}
+	tracker.add(sample)
+	tracker.apply(d)
+	s.feedbackMu.Unlock()
+
+	if err := s.data.UpdateDeploymentUsage(ctx, d); err != nil {
+		slog.Warn("feedback: failed to save usage", "deploy_id", d.ID, "error", err)
+	}
+}
+
+// completeFeedback marks the feedback of a deploy whose window has ended as completed.
+// Deploys that were never sampled are left alone.
+func (s *Server) completeFeedback(ctx context.Context, d *models.DeploymentHistory) {
+	s.feedbackMu.Lock()
+	tracker := s.feedbackTrackers[d.ID]
+	delete(s.feedbackTrackers, d.ID)
+	if tracker != nil {
+		tracker.apply(d)
+	}
+	s.feedbackMu.Unlock()
+	if d.Usage == nil {
+		return
+	}
+
+	d.Usage.Completed = true
+	if err := s.data.UpdateDeploymentUsage(ctx, d); err != nil {
+		slog.Warn("feedback: failed to save usage", "deploy_id", d.ID, "error", err)
+		return
+	}
+	slog.Info("post-deploy feedback completed", "deploy_id", d.ID, "samples", d.Usage.Samples,
+		"actual_cpu", d.ActualCPU, "actual_memory", d.ActualMemory,
+		"oom_events", d.OOMEvents, "throttle_events", d.ThrottleEvents)
+}
+
+// newUsageTracker starts a tracker, continuing from the stored usage if any.
+func newUsageTracker(stored *models.DeploymentUsage) *usageTracker {
+	t := &usageTracker{restarts: map[string]int{}, cfs: map[string]models.ContainerUsage{}}
+	if stored != nil {
+		t.usage = *stored
+	}
+	return t
+}
+
+// add records one sample: per-pod CPU and memory usage, OOM-killed restarts since the
+// previous sample, and intervals in which a container was throttled in more than
+// throttleRatio of its CFS periods.
+func (t *usageTracker) add(sample *models.UsageSample) {
+	if t.usage.From.IsZero() {
+		t.usage.From = sample.Time
+	}
+	t.usage.To = sample.Time
+	if sample.ThrottleAvailable {
+		t.usage.ThrottleMeasured = true
+	}
+
+	for _, pod := range sample.Pods {
+		if pod.HasMetrics {
+			t.cpu = append(t.cpu, pod.CPUMillis)
+			t.memory = append(t.memory, pod.MemoryBytes)
+			t.usage.Samples++
+		}
+		for _, c := range pod.Containers {
+			key := pod.Name + "/" + c.Name
+			prev, seen := t.restarts[key]
+			if c.LastTermination == "OOMKilled" {
+				switch {
+				case seen && c.RestartCount > prev:
+					t.usage.OOMEvents += c.RestartCount - prev
+				case !seen && c.RestartCount > 0:
+					// Killed before the first sample; only the last termination is known
+					t.usage.OOMEvents++
+				}
+			}
+			t.restarts[key] = c.RestartCount
+
+			if c.CFSPeriods == 0 {
+				continue
+			}
+			if last, ok := t.cfs[key]; ok && c.CFSPeriods > last.CFSPeriods {
+				throttled := float64(c.ThrottledPeriods-last.ThrottledPeriods) / float64(c.CFSPeriods-last.CFSPeriods)
+				if throttled > throttleRatio {
+					t.usage.ThrottleEvents++
+				}
+			}
+			t.cfs[key] = c
+		}
+	}
+}
+
+// apply writes the tracked usage to a history entry: the usage with its percentiles,
+// the p95 values as ActualCPU and ActualMemory, and the event counts.
+func (t *usageTracker) apply(d *models.DeploymentHistory) {
+	usage := t.usage
+	if len(t.cpu) > 0 {
+		usage.CPU = metrics.Percentiles(t.cpu)
+		usage.Memory = metrics.Percentiles(t.memory)
+	}
+	d.Usage = &usage
+	if usage.Samples > 0 {
+		d.ActualCPU = fmt.Sprintf("%dm", usage.CPU.P95)
+		d.ActualMemory = fmt.Sprintf("%dMi", int64(math.Ceil(float64(usage.Memory.P95)/(1<<20))))
+	}
+	d.OOMEvents = usage.OOMEvents
+	d.ThrottleEvents = usage.ThrottleEvents
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+const (
+	// generationPollInterval is how often subscribers check for new events.
+	generationPollInterval = 250 * time.Millisecond
+	// generationRetention keeps the events of a finished generation for late subscribers.
+	generationRetention = 10 * time.Minute
+)
+
+// generationLog holds the progress events of a deploy's latest manifest generation.
+// Only the newest progress event is kept: each carries the full reasoning so far.
+type generationLog struct {
+	events []models.GenerationEvent
+	seq    int
+	done   bool
+}
+
+// generationRun records the events of one generation, including the policy repair
+// rounds that follow it, into the deploy's log.
+type generationRun struct {
+	s        *Server
+	deployID string
+	log      *generationLog
+}
+
+// beginGeneration starts a new event log for the deploy. Sequence numbers continue
+// across generations of the same deploy.
+func (s *Server) beginGeneration(deployID string) *generationRun {
+	s.generationMu.Lock()
+	defer s.generationMu.Unlock()
+	if s.generations == nil {
+		s.generations = make(map[string]*generationLog)
+	}
+	log := &generationLog{}
+	if prev, ok := s.generations[deployID]; ok {
+		log.seq = prev.seq
+	}
+	s.generations[deployID] = log
+	return &generationRun{s: s, deployID: deployID, log: log}
+}
+
+// report adds an event to the log. It is the ai.ProgressFunc of the run.
+func (g *generationRun) report(ev models.GenerationEvent) {
+	g.s.generationMu.Lock()
+	defer g.s.generationMu.Unlock()
+	if g.log.done {
+		return
+	}
+	g.log.seq++
+	ev.Seq = g.log.seq
+	ev.DeployID = g.deployID
+	if n := len(g.log.events); n > 0 && ev.Type == models.GenerationProgress && g.log.events[n-1].Type == models.GenerationProgress {
+		g.log.events[n-1] = ev
+	} else {
+		g.log.events = append(g.log.events, ev)
+	}
+}
+
+// context returns ctx with the run receiving the AI service's progress.
+func (g *generationRun) context(ctx context.Context) context.Context {
+	return ai.WithProgress(ctx, g.report)
+}
+
+// finish marks the generation done, which closes its subscribers, and keeps the log
+// for generationRetention.
+func (g *generationRun) finish() {
+	g.s.generationMu.Lock()
+	defer g.s.generationMu.Unlock()
+	if g.log.done {
+		return
+	}
+	g.log.done = true
+	time.AfterFunc(generationRetention, func() {
+		g.s.generationMu.Lock()
+		defer g.s.generationMu.Unlock()
+		if g.s.generations[g.deployID] == g.log {
+			delete(g.s.generations, g.deployID)
+		}
+	})
+}
+
+// generationEventsAfter returns the deploy's events with a sequence number above seq,
+// whether the generation has finished, and whether the deploy has a generation log.
+func (s *Server) generationEventsAfter(deployID string, seq int) ([]models.GenerationEvent, bool, bool) {
+	s.generationMu.Lock()
+	defer s.generationMu.Unlock()
+	log, ok := s.generations[deployID]
+	if !ok {
+		return nil, false, false
+	}
+	var events []models.GenerationEvent
+	for _, ev := range log.events {
+		if ev.Seq > seq {
+			events = append(events, ev)
+		}
+	}
+	return events, log.done, true
+}
+
+// acceptsEventStream reports whether the client asked for server-sent events.
+func acceptsEventStream(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// streamGeneration runs generate while relaying the deploy's generation events to the
+// client as server-sent events. The first event carries the deploy ID.
+func (s *Server) streamGeneration(c *gin.Context, deployID string, generate func()) {
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("deploy", gin.H{"deploy_id": deployID})
+	c.Writer.Flush()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		generate()
+	}()
+
+	seq := 0
+	relay := func() {
+		events, _, _ := s.generationEventsAfter(deployID, seq)
+		for _, ev := range events {
+			c.SSEvent("generation", ev)
+			seq = ev.Seq
+		}
+		if len(events) > 0 {
+			c.Writer.Flush()
+		}
+	}
+
+	ticker := time.NewTicker(generationPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			relay()
+			return
+		case <-ticker.C:
+			relay()
+		}
+	}
+}
+
+// handleGenerationWS streams the progress of a deploy's manifest generation: attempts,
+// tokens received, partial reasoning, retries and policy repair rounds. Events sent
+// before the client connected are replayed first, and the connection closes when the
+// generation is done.
+func (s *Server) handleGenerationWS(c *gin.Context) {
+	deployID := c.Param("deploy_id")
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		slog.Error("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	seq := 0
+	ticker := time.NewTicker(generationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			events, done, found := s.generationEventsAfter(deployID, seq)
+			if !found {
+				_ = conn.WriteJSON(gin.H{
+					"type":      "generation",
+					"deploy_id": deployID,
+					"status":    "not_found",
+				})
+				return
+			}
+			for _, ev := range events {
+				if err := conn.WriteJSON(gin.H{
+					"type":      "generation",
+					"deploy_id": deployID,
+					"data":      ev,
+				}); err != nil {
+					return
+				}
+				seq = ev.Seq
+			}
+			if done {
+				return
+			}
+		}
+	}
+}
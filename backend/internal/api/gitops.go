@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/gitops"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// gitCommitTimeout bounds the clone, commit and push of a git-mode deploy.
+const gitCommitTimeout = 2 * time.Minute
+
+// clusterDelivery returns the git delivery target of a cluster, or nil when manifests
+// are applied to the cluster directly. Clusters from the config file take precedence
+// over clusters registered through the API, which never carry merge request credentials.
+func (s *Server) clusterDelivery(ctx context.Context, cluster string) *models.GitDelivery {
+	for _, cl := range s.cfg.Clusters {
+		if cl.Name == cluster {
+			return gitTarget(cl.Name, cl.Delivery, cl.Git)
+		}
+	}
+	if s.data == nil {
+		return nil
+	}
+	registered, err := s.data.GetRegisteredClusters(ctx)
+	if err != nil {
+		slog.Warn("failed to load registered clusters for delivery mode", "cluster", cluster, "error", err)
+		return nil
+	}
+	for _, cl := range registered {
+		if cl.Name == cluster {
+			target := gitTarget(cl.Name, cl.Delivery, cl.Git)
+			if target != nil && target.MergeRequest != nil {
+				// Merge request tokens and API hosts come only from the config file
+				mr := *target.MergeRequest
+				mr.TokenEnv, mr.APIURL = "", ""
+				target.MergeRequest = &mr
+			}
+			return target
+		}
+	}
+	return nil
+}
+
+// gitTarget returns the defaulted git target of a cluster in git mode, or nil.
+func gitTarget(cluster, delivery string, git *models.GitDelivery) *models.GitDelivery {
+	if delivery != models.DeliveryGit || git == nil {
+		return nil
+	}
+	target := gitops.WithDefaults(*git, cluster)
+	return &target
+}
+
+// gitDeploySteps returns the steps of a single deploy to a git-mode cluster.
+func gitDeploySteps(target models.GitDelivery) []models.DeployStep {
+	steps := []models.DeployStep{
+		{Step: "push_image", Status: "pending"},
+		{Step: "commit_manifests", Status: "pending"},
+	}
+	if target.MergeRequest != nil {
+		steps = append(steps, models.DeployStep{Step: "open_merge_request", Status: "pending"})
+	}
+	return steps
+}
+
+// deployGitFiles lays out the manifests of a single deploy as repository files.
+func deployGitFiles(m *models.ManifestResult) map[string]string {
+	files := map[string]string{}
+	if m == nil {
+		return files
+	}
+	for name, content := range map[string]string{
+		"configmap.yaml":  m.ConfigMap,
+		"deployment.yaml": m.Deployment,
+		"service.yaml":    m.Service,
+		"hpa.yaml":        m.HPA,
+	} {
+		if content != "" {
+			files[name] = content
+		}
+	}
+	return files
+}
+
+// commitMessage describes a commit in one line; the message of every git-mode commit
+// is followed by a "Deploy-ID:" trailer.
+func commitMessage(what, cluster, namespace string) string {
+	return fmt.Sprintf("Deploy %s to %s/%s", what, cluster, namespace)
+}
+
+// deliverToGit commits the files and, when configured, opens a merge request for the
+// commit. step reports progress of "commit_manifests" and "open_merge_request".
+func (s *Server) deliverToGit(ctx context.Context, target models.GitDelivery, req gitops.CommitRequest, step func(name, status, message string)) (*models.GitCommit, error) {
+	step("commit_manifests", "in_progress", fmt.Sprintf("Committing manifests to %s (%s)...", target.Branch, target.Path))
+	commitCtx, cancel := context.WithTimeout(ctx, gitCommitTimeout)
+	commit, err := s.gitops.Commit(commitCtx, target, req)
+	cancel()
+	if err != nil {
+		step("commit_manifests", "failed", fmt.Sprintf("Failed: %v", err))
+		return nil, err
+	}
+	if commit.Unchanged {
+		step("commit_manifests", "completed", fmt.Sprintf("No changes; %s already at %s", commit.Branch, shortSHA(commit.SHA)))
+	} else {
+		step("commit_manifests", "completed", fmt.Sprintf("Committed %s to %s", shortSHA(commit.SHA), commit.Branch))
+	}
+
+	if target.MergeRequest == nil {
+		return commit, nil
+	}
+	if commit.Unchanged {
+		step("open_merge_request", "skipped", "Nothing to merge")
+		return commit, nil
+	}
+	step("open_merge_request", "in_progress", "Opening merge request...")
+	url, err := s.gitops.OpenMergeRequest(ctx, target, commit, req.Message, "Deploy-ID: "+req.DeployID)
+	if err != nil {
+		step("open_merge_request", "failed", fmt.Sprintf("Failed: %v", err))
+		return commit, err
+	}
+	commit.MergeRequestURL = url
+	step("open_merge_request", "completed", "Opened "+url)
+	return commit, nil
+}
+
+// shortSHA abbreviates a commit SHA for step messages.
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// executeGitDeployAsync delivers a single deploy to a git-mode cluster: the stamped
+// manifests are committed under "<path>/<namespace>/<name>/" and the GitOps controller
+// applies them. The deploy completes once the commit is pushed.
+func (s *Server) executeGitDeployAsync(deployID string, target models.GitDelivery) {
+	s.mu.RLock()
+	state, exists := s.deployStates[deployID]
+	s.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	updateStep := s.deployStepUpdater(state)
+
+	ctx := context.Background()
+	defer s.saveDeployToDB(ctx, state)
+	s.stampDeployManifests(ctx, deployID, state)
+
+	updateStep("push_image", "in_progress", "Checking image availability...")
+	updateStep("push_image", "completed", "Image ready")
+
+	ns := state.Request.Namespace
+	if ns == "" {
+		ns = "default"
+	}
+	name := state.Request.ContainerID
+	commit, err := s.deliverToGit(ctx, target, gitops.CommitRequest{
+		DeployID: deployID,
+		Dir:      ns + "/" + name,
+		Files:    deployGitFiles(state.Manifests),
+		Message:  commitMessage(name, state.Request.ClusterName, ns),
+	}, updateStep)
+	if err != nil {
+		slog.Error("git delivery failed", "deploy_id", deployID, "error", err)
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	state.Status.CompletedAt = &now
+	state.Status.Commit = commit
+	state.Status.Result = &models.DeployResult{
+		DeploymentName: name,
+		Namespace:      ns,
+		ServiceURL:     fmt.Sprintf("http://%s.%s.svc.cluster.local", name, ns),
+	}
+	if err != nil {
+		state.Status.Status = "failed"
+	} else {
+		state.Status.Status = "completed"
+	}
+	s.mu.Unlock()
+
+	// A commit that never reached the repository is not a deploy
+	if commit == nil {
+		return
+	}
+	history := deployHistoryRecord(deployID, state, now, err == nil)
+	history.CommitSHA = commit.SHA
+	if err := s.data.SaveDeployment(ctx, history); err != nil {
+		slog.Error("failed to save deployment history", "error", err)
+	}
+}
+
+// executeStackGitDeploy delivers a stack to a git-mode cluster as one commit holding
+// every stamped manifest under "<path>/<namespace>/<stack>/". Each apply step completes
+// with the commit, or fails when the service has no manifest for it.
+func (s *Server) executeStackGitDeploy(ctx context.Context, deployID string, state *stackDeployState, target models.GitDelivery,
+	manifests map[string]map[string]string, containerInfos []ai.ContainerInfo, updateStep func(svcName, stepName, status, message string)) {
+	type stepRef struct{ svc, step string }
+	var committed []stepRef
+	files := map[string]string{}
+	failed := false
+
+	for _, svcName := range state.Status.DeployOrder {
+		s.mu.RLock()
+		svc := state.Status.Services[svcName]
+		steps := make([]models.DeployStep, len(svc.Steps))
+		copy(steps, svc.Steps)
+		s.mu.RUnlock()
+
+		for _, step := range steps {
+			kind := stepToKind(step.Step)
+			resName, yamlContent := findManifestForStep(manifests, kind, svcName)
+			if yamlContent == "" {
+				updateStep(svcName, step.Step, "failed", fmt.Sprintf("No manifest found for %s", kind))
+				failed = true
+				continue
+			}
+
+			// Keep the stamped manifest so the stored record matches what was committed
+			yamlContent = stampOwnership(yamlContent, stackServiceOwnership(deployID, svcName, containerInfos))
+			s.mu.Lock()
+			manifests[kind][resName] = yamlContent
+			s.mu.Unlock()
+
+			files[stackGitFileName(kind, resName)] = yamlContent
+			committed = append(committed, stepRef{svcName, step.Step})
+			updateStep(svcName, step.Step, "in_progress", fmt.Sprintf("Committing %s...", kind))
+		}
+	}
+
+	var commit *models.GitCommit
+	message := "Skipped due to previous failure"
+	status := "skipped"
+	if !failed {
+		ns, clusterName := "default", ""
+		if state.Request != nil {
+			clusterName = state.Request.ClusterName
+			if state.Request.Namespace != "" {
+				ns = state.Request.Namespace
+			}
+		}
+		var err error
+		commit, err = s.deliverToGit(ctx, target, gitops.CommitRequest{
+			DeployID: deployID,
+			Dir:      ns + "/" + state.Status.StackName,
+			Files:    files,
+			Message:  commitMessage("stack "+state.Status.StackName, clusterName, ns),
+		}, func(name, stepStatus, stepMessage string) {
+			// The apply steps report the outcome of the whole delivery
+			if stepStatus == "completed" || stepStatus == "failed" {
+				status, message = stepStatus, stepMessage
+			}
+		})
+		if err != nil {
+			slog.Error("git delivery failed", "deploy_id", deployID, "error", err)
+			failed = true
+			status = "failed"
+		}
+	}
+	for _, ref := range committed {
+		updateStep(ref.svc, ref.step, status, message)
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	state.Status.Commit = commit
+	if failed {
+		state.Status.Status = "failed"
+		state.Response.Status = "failed"
+	} else {
+		state.Status.Status = "deployed"
+		state.Response.Status = "deployed"
+	}
+	state.Status.CompletedAt = &now
+	s.mu.Unlock()
+
+	s.updateStackDeployInDB(ctx, state)
+
+	slog.Info("stack deployment finished", "deploy_id", deployID, "status", state.Status.Status, "delivery", models.DeliveryGit)
+}
+
+// stackGitFileName names the repository file of one stack manifest.
+func stackGitFileName(kind, name string) string {
+	return strings.ToLower(name + "-" + kind + ".yaml")
+}
@@ -3,20 +3,24 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/config"
 	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
 )
 
 func (s *Server) handleGetClustersConfig(c *gin.Context) {
 	type clusterInfo struct {
-		Name           string `json:"name"`
-		Type           string `json:"type"`
-		KubeconfigPath string `json:"kubeconfig_path"`
-		Context        string `json:"context"`
-		Registry       string `json:"registry"`
+		Name           string              `json:"name"`
+		Type           string              `json:"type"`
+		KubeconfigPath string              `json:"kubeconfig_path"`
+		Context        string              `json:"context"`
+		Registry       string              `json:"registry"`
+		Delivery       string              `json:"delivery"`
+		Git            *models.GitDelivery `json:"git,omitempty"`
 	}
 
 	clusters := make([]clusterInfo, len(s.cfg.Clusters))
@@ -27,6 +31,8 @@ func (s *Server) handleGetClustersConfig(c *gin.Context) {
 			KubeconfigPath: cl.Kubeconfig,
 			Context:        cl.Context,
 			Registry:       cl.Registry,
+			Delivery:       cl.Delivery,
+			Git:            cl.Git,
 		}
 	}
 
@@ -62,6 +68,33 @@ func (s *Server) handleRegisterCluster(c *gin.Context) {
 	if req.Type == "" {
 		req.Type = "kubernetes"
 	}
+	if req.Delivery == "" {
+		req.Delivery = models.DeliveryDirect
+	}
+	switch {
+	case req.Delivery != models.DeliveryDirect && req.Delivery != models.DeliveryGit:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "delivery must be direct or git"},
+		})
+		return
+	case req.Delivery == models.DeliveryGit && (req.Git == nil || req.Git.URL == ""):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "git.url is required for git delivery"},
+		})
+		return
+	case req.Git != nil && strings.HasPrefix(req.Git.URL, "-"):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "git.url must not start with '-'"},
+		})
+		return
+	case req.Git != nil && req.Git.MergeRequest != nil && (req.Git.MergeRequest.TokenEnv != "" || req.Git.MergeRequest.APIURL != ""):
+		// The token is read from the server environment and sent to the API URL, so
+		// neither may be chosen by an API caller
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "git.merge_request.token_env and api_url can only be set in the config file"},
+		})
+		return
+	}
 
 	clusterCfg := config.ClusterConfig{
 		Name:       req.Name,
@@ -69,6 +102,8 @@ func (s *Server) handleRegisterCluster(c *gin.Context) {
 		Kubeconfig: req.Kubeconfig,
 		Context:    req.Context,
 		Registry:   req.Registry,
+		Delivery:   req.Delivery,
+		Git:        req.Git,
 	}
 
 	if err := s.kubernetes.AddCluster(c.Request.Context(), clusterCfg); err != nil {
@@ -86,6 +121,8 @@ func (s *Server) handleRegisterCluster(c *gin.Context) {
 			Kubeconfig: req.Kubeconfig,
 			Context:    req.Context,
 			Registry:   req.Registry,
+			Delivery:   req.Delivery,
+			Git:        req.Git,
 		})
 	}
 
@@ -136,6 +173,13 @@ func (s *Server) handleUpdateAIConfig(c *gin.Context) {
 		return
 	}
 
+	if req.Provider != "" && !ai.HasProvider(req.Provider) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: fmt.Sprintf("unsupported provider %q (available: %s)", req.Provider, strings.Join(ai.Providers(), ", "))},
+		})
+		return
+	}
+
 	s.ai.UpdateConfig(req.Provider, req.APIKey, req.Model)
 
 	// Persist to DB
@@ -173,6 +217,22 @@ func (s *Server) handleListAIModels(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"models": modelList})
 }
 
+func (s *Server) handleGetAICacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, s.ai.CacheStats(c.Request.Context()))
+}
+
+func (s *Server) handlePurgeAICache(c *gin.Context) {
+	purged, err := s.ai.PurgeCache(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "CACHE_ERROR", Message: fmt.Sprintf("failed to purge AI cache: %v", err)},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
 func (s *Server) handleHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, models.HealthResponse{
 		Status:    "healthy",
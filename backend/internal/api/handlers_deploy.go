@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -13,9 +14,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
 	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
 )
 
+// fewShotCandidates bounds the successful deploys the AI service ranks for few-shot
+// examples.
+const fewShotCandidates = 200
+
 func (s *Server) handleDeployDockerToK8s(c *gin.Context) {
 	var req models.DeployRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -25,6 +31,13 @@ func (s *Server) handleDeployDockerToK8s(c *gin.Context) {
 		return
 	}
 
+	if err := validateFanOut(req.Targets, req.Strategy); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return
+	}
+
 	if req.Namespace == "" {
 		req.Namespace = "default"
 	}
@@ -86,22 +99,52 @@ func (s *Server) handleDeployDockerToK8s(c *gin.Context) {
 		WorkingDir:  container.Config.WorkingDir,
 		CPUUsage:    cpuUsage,
 		MemoryUsage: memUsage,
+		Usage:       s.sampler.Profile(container.ID, container.Stats),
 	}
 
-	// 2. Search similar deployments
-	similar, _ := s.data.FindSimilar(ctx, imageName, "", 5)
+	// 2. Load past deploys; the AI service ranks them by similarity
+	similar, _ := s.data.FindSimilar(ctx, "", "", fewShotCandidates)
 
-	// 3. Generate manifest via AI
-	manifest, err := s.ai.GenerateManifest(ctx, containerInfo, similar)
+	// 3. Generate manifest via AI and repair its policy violations. With
+	// "Accept: text/event-stream" the progress is streamed to the client while the
+	// request waits.
+	deployID := uuid.New().String()
+	run := s.beginGeneration(deployID)
+	aiCtx := run.context(ai.WithDeploy(ctx, deployID, req.RequestedBy))
+	if req.NoCache {
+		aiCtx = ai.WithoutCache(aiCtx)
+	}
+	var manifest *models.ManifestResult
+	var violations []models.PolicyViolation
+	var repairRounds int
+	generate := func() {
+		defer run.finish()
+		if manifest, err = s.ai.GenerateManifest(aiCtx, containerInfo, similar); err == nil {
+			manifest, violations, repairRounds = s.repairDeployManifests(aiCtx, run, manifest)
+		}
+	}
+	streaming := acceptsEventStream(c)
+	reply := c.JSON
+	if streaming {
+		s.streamGeneration(c, deployID, generate)
+		reply = func(status int, body interface{}) {
+			if status == http.StatusOK {
+				c.SSEvent("result", body)
+			} else {
+				c.SSEvent("error", body)
+			}
+		}
+	} else {
+		generate()
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		reply(http.StatusInternalServerError, models.ErrorResponse{
 			Error: models.ErrorDetail{Code: "AI_ERROR", Message: fmt.Sprintf("failed to generate manifest: %v", err)},
 		})
 		return
 	}
 
 	// 4. Create deploy state
-	deployID := uuid.New().String()
 	now := time.Now()
 
 	resp := &models.DeployResponse{
@@ -110,27 +153,28 @@ func (s *Server) handleDeployDockerToK8s(c *gin.Context) {
 		AIAnalysis: &models.AIAnalysis{
 			ServiceType:        detectServiceType(containerInfo),
 			DetectedLanguage:   detectLanguage(containerInfo),
-			SimilarDeployments: len(similar),
-		},
-		Recommendations: &models.Recommendations{
-			CPURequest:    "100m",
-			CPULimit:      "500m",
-			MemoryRequest: "128Mi",
-			MemoryLimit:   "512Mi",
-			Replicas:      2,
-			EnableHPA:     req.Options.EnableHPA,
-			Reasoning:     manifest.Reasoning,
+			SimilarDeployments: len(manifest.Examples),
 		},
+		Recommendations: &models.Recommendations{Usage: containerInfo.Usage},
 		Manifests: &models.Manifests{
 			Deployment: manifest.Deployment,
 			Service:    manifest.Service,
 			HPA:        manifest.HPA,
 			ConfigMap:  manifest.ConfigMap,
 		},
+		ManifestVersion:    1,
+		Cached:             manifest.Cached,
+		PolicyViolations:   violations,
+		PolicyRepairRounds: repairRounds,
+		Quality:            kubernetes.ScoreManifests(manifestDocuments(manifest)),
+	}
+	setRecommendations(resp, manifest)
+	resp.EstimatedCost = s.estimateCost(costCluster(req.ClusterName, req.Targets), manifestDocuments(manifest))
+	if version := s.recordRevision(ctx, deployID, "single", generatedSource(manifest.Reasoning), "", 0, manifest); version > 0 {
+		resp.ManifestVersion = version
 	}
 
-	s.mu.Lock()
-	s.deployStates[deployID] = &deployState{
+	state := &deployState{
 		Status: &models.DeployStatus{
 			DeployID:  deployID,
 			Status:    "pending",
@@ -141,9 +185,12 @@ func (s *Server) handleDeployDockerToK8s(c *gin.Context) {
 		Request:   &req,
 		Manifests: manifest,
 	}
+	s.mu.Lock()
+	s.deployStates[deployID] = state
 	s.mu.Unlock()
+	s.saveDeployToDB(ctx, state)
 
-	c.JSON(http.StatusOK, resp)
+	reply(http.StatusOK, resp)
 }
 
 func (s *Server) handleExecuteDeploy(c *gin.Context) {
@@ -174,27 +221,69 @@ func (s *Server) handleExecuteDeploy(c *gin.Context) {
 		now := time.Now()
 		state.Status.CompletedAt = &now
 		s.mu.Unlock()
+		s.saveDeployToDB(c.Request.Context(), state)
 
-		c.JSON(http.StatusOK, state.Status)
+		c.JSON(http.StatusOK, s.deployStatusSnapshot(state))
 		return
 	}
 
+	if len(req.Modifications) > 0 || len(req.Edits) > 0 {
+		if err := s.editDeployManifests(c.Request.Context(), deployID, state, req.Modifications, req.Edits); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "INVALID_MODIFICATION", Message: err.Error()},
+			})
+			return
+		}
+	}
+
+	// Blue/green and canary deploys run beside the live Deployment until promoted
+	if st := rolloutStrategy(req.Strategy); st != nil {
+		if err := s.checkStrategy(c.Request.Context(), state, st); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "INVALID_STRATEGY", Message: err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, s.startStrategyDeploy(deployID, state, st, req.SkipRollback))
+		return
+	}
+
+	// Multi-cluster deploys run one deploy per target cluster
+	if len(state.Request.Targets) > 0 {
+		if err := s.startDeployFanOut(c.Request.Context(), deployID, state, req.SkipRollback); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "INVALID_TARGETS", Message: err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, s.deployStatusSnapshot(state))
+		return
+	}
+
+	// Clusters reconciled by a GitOps controller get their manifests committed instead
+	target := s.clusterDelivery(c.Request.Context(), state.Request.ClusterName)
+
 	// Initialize deployment steps
-	steps := []models.DeployStep{
-		{Step: "push_image", Status: "pending"},
-		{Step: "create_deployment", Status: "pending"},
-		{Step: "create_service", Status: "pending"},
+	steps := directDeploySteps()
+	if target != nil {
+		steps = gitDeploySteps(*target)
 	}
 
 	s.mu.Lock()
 	state.Status.Status = "deploying"
 	state.Status.Steps = steps
+	state.SkipRollback = req.SkipRollback
 	s.mu.Unlock()
+	s.saveDeployToDB(c.Request.Context(), state)
 
 	// Execute deployment asynchronously
-	go s.executeDeployAsync(deployID)
+	if target != nil {
+		go s.executeGitDeployAsync(deployID, *target)
+	} else {
+		go s.executeDeployAsync(deployID)
+	}
 
-	c.JSON(http.StatusOK, state.Status)
+	c.JSON(http.StatusOK, s.deployStatusSnapshot(state))
 }
 
 func (s *Server) handleRefineDeploy(c *gin.Context) {
@@ -221,26 +310,35 @@ func (s *Server) handleRefineDeploy(c *gin.Context) {
 		return
 	}
 
-	// Call AI to refine the manifest
-	refined, err := s.ai.RefineManifest(c.Request.Context(), state.Manifests, req.Feedback)
+	s.mu.RLock()
+	var requestedBy string
+	if state.Request != nil {
+		requestedBy = state.Request.RequestedBy
+	}
+	s.mu.RUnlock()
+
+	// Call AI to refine the manifest, then repair what the refinement broke
+	run := s.beginGeneration(deployID)
+	defer run.finish()
+	aiCtx := run.context(ai.WithDeploy(c.Request.Context(), deployID, requestedBy))
+	refined, err := s.ai.RefineManifest(aiCtx, state.Manifests, req.Feedback)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: models.ErrorDetail{Code: "AI_ERROR", Message: err.Error()},
 		})
 		return
 	}
+	refined, _, repairRounds := s.repairDeployManifests(aiCtx, run, refined)
+
+	version := s.recordRevision(c.Request.Context(), deployID, "single", models.RevisionRefine, req.Feedback, 0, refined)
 
 	// Update deploy state with refined manifest
 	s.mu.Lock()
-	state.Manifests = refined
-	state.Response.Manifests = &models.Manifests{
-		Deployment: refined.Deployment,
-		Service:    refined.Service,
-		HPA:        refined.HPA,
-		ConfigMap:  refined.ConfigMap,
-	}
-	state.Response.Recommendations.Reasoning = refined.Reasoning
+	s.setDeployManifests(state, refined)
+	state.Response.PolicyRepairRounds = repairRounds
+	state.Response.ManifestVersion = revisionVersion(version, state.Response.ManifestVersion)
 	s.mu.Unlock()
+	s.saveDeployToDB(c.Request.Context(), state)
 
 	c.JSON(http.StatusOK, state.Response)
 }
@@ -253,23 +351,25 @@ func (s *Server) executeDeployAsync(deployID string) {
 		return
 	}
 
-	updateStep := func(stepName, status, message string) {
-		s.mu.Lock()
-		defer s.mu.Unlock()
-		for i, step := range state.Status.Steps {
-			if step.Step == stepName {
-				state.Status.Steps[i].Status = status
-				state.Status.Steps[i].Message = message
-				if status == "completed" || status == "failed" {
-					now := time.Now()
-					state.Status.Steps[i].CompletedAt = &now
-				}
-				break
-			}
-		}
-	}
+	updateStep := s.deployStepUpdater(state)
 
 	ctx := context.Background()
+	defer s.saveDeployToDB(ctx, state)
+	s.stampDeployManifests(ctx, deployID, state)
+
+	// Prior live state of every object this execute touches, for rollback on failure
+	snapshots := &deploySnapshots{}
+	rollback := func() {
+		var steps []models.DeployStep
+		if state.SkipRollback {
+			steps = []models.DeployStep{skippedRollbackStep()}
+		} else {
+			steps = s.rollbackSnapshots(ctx, state.Request.ClusterName, snapshots.snaps)
+		}
+		s.mu.Lock()
+		state.Status.Steps = append(state.Status.Steps, steps...)
+		s.mu.Unlock()
+	}
 
 	// Step 1: Push image (skip if no registry configured or image is public)
 	updateStep("push_image", "in_progress", "Checking image availability...")
@@ -290,74 +390,188 @@ func (s *Server) executeDeployAsync(deployID string) {
 		ns = "default"
 	}
 
-	// Step 2: Create deployment
-	updateStep("create_deployment", "in_progress", "Applying Kubernetes deployment...")
-	if state.Manifests != nil && state.Manifests.Deployment != "" {
-		applyCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		err := s.kubernetes.ApplyManifest(applyCtx, clusterName, state.Manifests.Deployment)
-		cancel()
-		if err != nil {
-			slog.Error("failed to apply deployment", "deploy_id", deployID, "error", err)
-			updateStep("create_deployment", "failed", fmt.Sprintf("Failed: %v", err))
-			s.mu.Lock()
-			now := time.Now()
-			state.Status.Status = "failed"
-			state.Status.CompletedAt = &now
-			s.mu.Unlock()
-			return
+	// Manifests are applied in apply order: config and workloads, then the Service, then
+	// the optional HPA
+	var manifests models.ManifestResult
+	if state.Manifests != nil {
+		manifests = *state.Manifests
+	}
+	var workloadParts, serviceParts, optionalParts []manifestPart
+	for _, part := range applyOrder(manifests) {
+		switch part.kind {
+		case "Service":
+			serviceParts = append(serviceParts, part)
+		case "HorizontalPodAutoscaler":
+			optionalParts = append(optionalParts, part)
+		default:
+			workloadParts = append(workloadParts, part)
 		}
 	}
-	updateStep("create_deployment", "completed", "Deployment applied")
+	applyParts := func(stepName string, parts []manifestPart) ([]models.AppliedResource, error) {
+		var all []models.AppliedResource
+		for _, part := range parts {
+			if !state.SkipRollback {
+				if err := snapshots.capture(ctx, s, clusterName, part.yaml); err != nil {
+					return all, fmt.Errorf("%s: %w", part.kind, err)
+				}
+			}
+			applyCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			applied, err := s.kubernetes.ApplyManifest(applyCtx, clusterName, part.yaml)
+			cancel()
+			all = append(all, applied...)
+			if stepName != "" {
+				s.setStepResources(state, stepName, all)
+			}
+			if err != nil {
+				return all, fmt.Errorf("%s: %w", part.kind, err)
+			}
+		}
+		return all, nil
+	}
+	failStep := func(stepName string, err error) {
+		slog.Error("failed to apply manifests", "deploy_id", deployID, "step", stepName, "error", err)
+		updateStep(stepName, "failed", fmt.Sprintf("Failed: %v", err))
+		rollback()
+		s.mu.Lock()
+		now := time.Now()
+		state.Status.Status = "failed"
+		state.Status.CompletedAt = &now
+		s.mu.Unlock()
+	}
+
+	// Step 2: Create deployment, with the ConfigMap it reads applied first
+	updateStep("create_deployment", "in_progress", "Applying Kubernetes deployment...")
+	deploymentResources, err := applyParts("create_deployment", workloadParts)
+	if err != nil {
+		failStep("create_deployment", err)
+		return
+	}
+	deploymentMsg := "Deployment applied"
+	if len(deploymentResources) > 0 {
+		deploymentMsg = "Applied " + summarizeApplied(deploymentResources)
+	}
+	updateStep("create_deployment", "completed", deploymentMsg)
 
 	// Step 3: Create service
 	updateStep("create_service", "in_progress", "Applying Kubernetes service...")
-	if state.Manifests != nil && state.Manifests.Service != "" {
-		applyCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		err := s.kubernetes.ApplyManifest(applyCtx, clusterName, state.Manifests.Service)
-		cancel()
-		if err != nil {
-			slog.Error("failed to apply service", "deploy_id", deployID, "error", err)
-			updateStep("create_service", "failed", fmt.Sprintf("Failed: %v", err))
-			s.mu.Lock()
-			now := time.Now()
-			state.Status.Status = "failed"
-			state.Status.CompletedAt = &now
-			s.mu.Unlock()
-			return
-		}
+	serviceResources, err := applyParts("create_service", serviceParts)
+	if err != nil {
+		failStep("create_service", err)
+		return
+	}
+	serviceMsg := "Service applied"
+	if len(serviceResources) > 0 {
+		serviceMsg = "Applied " + summarizeApplied(serviceResources)
 	}
-	updateStep("create_service", "completed", "Service applied")
+	updateStep("create_service", "completed", serviceMsg)
 
 	// Apply optional resources (best-effort)
-	if state.Manifests != nil {
-		if state.Manifests.HPA != "" {
-			if err := s.kubernetes.ApplyManifest(ctx, clusterName, state.Manifests.HPA); err != nil {
-				slog.Warn("failed to apply HPA", "deploy_id", deployID, "error", err)
-			}
-		}
-		if state.Manifests.ConfigMap != "" {
-			if err := s.kubernetes.ApplyManifest(ctx, clusterName, state.Manifests.ConfigMap); err != nil {
-				slog.Warn("failed to apply ConfigMap", "deploy_id", deployID, "error", err)
+	if _, err := applyParts("", optionalParts); err != nil {
+		slog.Warn("failed to apply optional manifests", "deploy_id", deployID, "error", err)
+	}
+
+	// Step 4: Wait for the rollout to become ready
+	updateStep("wait_ready", "in_progress", "Waiting for pods to become ready...")
+	result := &models.DeployResult{
+		DeploymentName: state.Request.ContainerID,
+		Namespace:      ns,
+		PodsReady:      "0/0",
+		ServiceURL:     fmt.Sprintf("http://%s.%s.svc.cluster.local", state.Request.ContainerID, ns),
+	}
+	readyErr := s.waitForWorkloads(ctx, clusterName, deploymentResources, result)
+	for _, r := range serviceResources {
+		if r.Kind == "Service" && r.Status == "applied" {
+			if url, err := s.kubernetes.ServiceEndpoint(ctx, clusterName, r.Namespace, r.Name); err == nil {
+				result.ServiceURL = url
 			}
+			break
 		}
 	}
 
-	// Mark as completed
-	serviceName := state.Request.ContainerID
+	if readyErr != nil {
+		slog.Error("deployment did not become ready", "deploy_id", deployID, "error", readyErr)
+		updateStep("wait_ready", "failed", fmt.Sprintf("Failed: %v", readyErr))
+		rollback()
+	} else {
+		updateStep("wait_ready", "completed", fmt.Sprintf("%s pods ready", result.PodsReady))
+	}
+
 	s.mu.Lock()
 	now := time.Now()
-	state.Status.Status = "completed"
+	state.Status.Result = result
 	state.Status.CompletedAt = &now
-	state.Status.Result = &models.DeployResult{
-		DeploymentName: serviceName,
-		Namespace:      ns,
-		Replicas:       1,
-		PodsReady:      "pending",
-		ServiceURL:     fmt.Sprintf("http://%s.%s.svc.cluster.local", serviceName, ns),
+	if readyErr != nil {
+		state.Status.Status = "failed"
+	} else {
+		state.Status.Status = "completed"
 	}
 	s.mu.Unlock()
 
 	// Save to deployment history
+	history := deployHistoryRecord(deployID, state, now, readyErr == nil)
+	history.Replicas = result.Replicas
+	if err := s.data.SaveDeployment(ctx, history); err != nil {
+		slog.Error("failed to save deployment history", "error", err)
+	}
+}
+
+// manifestPart is one manifest of a single deploy and the kind it holds.
+type manifestPart struct {
+	kind, yaml string
+	rank       int // kubernetes.ApplyRank of yaml
+}
+
+// applyOrder lists the non-empty manifests of a single deploy in the order execute
+// applies them, ranked like the objects within one manifest: config before workloads,
+// workloads before Services, HPAs last. Preview lists them in the same order.
+func applyOrder(m models.ManifestResult) []manifestPart {
+	var parts []manifestPart
+	for _, part := range []manifestPart{
+		{kind: "ConfigMap", yaml: m.ConfigMap},
+		{kind: "Deployment", yaml: m.Deployment},
+		{kind: "Service", yaml: m.Service},
+		{kind: "HorizontalPodAutoscaler", yaml: m.HPA},
+	} {
+		if part.yaml != "" {
+			part.rank = kubernetes.ApplyRank(part.yaml)
+			parts = append(parts, part)
+		}
+	}
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].rank < parts[j].rank })
+	return parts
+}
+
+// directDeploySteps returns the steps of a single deploy applied to the cluster.
+func directDeploySteps() []models.DeployStep {
+	return []models.DeployStep{
+		{Step: "push_image", Status: "pending"},
+		{Step: "create_deployment", Status: "pending"},
+		{Step: "create_service", Status: "pending"},
+		{Step: "wait_ready", Status: "pending"},
+	}
+}
+
+// stampDeployManifests stamps every manifest of a deploy with the ownership labels that
+// undeploy and the orphan scan select on.
+func (s *Server) stampDeployManifests(ctx context.Context, deployID string, state *deployState) {
+	if state.Manifests == nil {
+		return
+	}
+	owner := s.deployOwnership(ctx, deployID, state.Request.ContainerID)
+	stamped := *state.Manifests
+	for _, m := range []*string{&stamped.Deployment, &stamped.Service, &stamped.HPA, &stamped.ConfigMap} {
+		if *m != "" {
+			*m = stampOwnership(*m, owner)
+		}
+	}
+	s.mu.Lock()
+	state.Manifests = &stamped
+	s.mu.Unlock()
+}
+
+// deployHistoryRecord builds the history entry of an executed deploy, including the
+// manifest JSON used for future redeploys.
+func deployHistoryRecord(deployID string, state *deployState, deployedAt time.Time, success bool) *models.DeploymentHistory {
 	history := &models.DeploymentHistory{
 		ID:            deployID,
 		ServiceName:   state.Request.ContainerID,
@@ -365,11 +579,13 @@ func (s *Server) executeDeployAsync(deployID string) {
 		ImageTag:      "",
 		TargetCluster: state.Request.ClusterName,
 		Namespace:     state.Request.Namespace,
-		DeployedAt:    now,
-		Success:       true,
+		DeployedAt:    deployedAt,
+		Success:       success,
 		AIGenerated:   true,
-		AIConfidence:  state.Manifests.Confidence,
-		Replicas:      2,
+		ParentID:      state.ParentID,
+	}
+	if !success {
+		history.Status = "failed"
 	}
 	if state.Response != nil && state.Response.Recommendations != nil {
 		history.CPURequest = state.Response.Recommendations.CPURequest
@@ -380,13 +596,50 @@ func (s *Server) executeDeployAsync(deployID string) {
 
 	// Save manifest JSON for future redeploy
 	if state.Manifests != nil {
+		history.AIConfidence = state.Manifests.Confidence
 		manifestJSON, _ := json.Marshal(state.Manifests)
 		history.ManifestJSON = string(manifestJSON)
 	}
+	return history
+}
 
-	if err := s.data.SaveDeployment(ctx, history); err != nil {
-		slog.Error("failed to save deployment history", "error", err)
+// isWorkload reports whether an applied object is a workload that rolls out pods.
+func isWorkload(r models.AppliedResource) bool {
+	return r.Status == "applied" && (r.Kind == "Deployment" || r.Kind == "StatefulSet" || r.Kind == "DaemonSet")
+}
+
+// waitForWorkloads waits for every applied Deployment, StatefulSet and DaemonSet to finish
+// rolling out, bounded by LimitsConfig.DeployTimeout. The observed replica counts of the
+// first workload are written to result, even on failure.
+func (s *Server) waitForWorkloads(ctx context.Context, clusterName string, applied []models.AppliedResource, result *models.DeployResult) error {
+	timeout := 30 * time.Minute
+	if s.cfg != nil && s.cfg.Limits.DeployTimeout > 0 {
+		timeout = time.Duration(s.cfg.Limits.DeployTimeout) * time.Minute
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	first := true
+	for _, r := range applied {
+		if !isWorkload(r) {
+			continue
+		}
+		status, err := s.kubernetes.WaitForRollout(waitCtx, clusterName, r.Namespace, r.Kind, r.Name)
+		if status != nil && first {
+			result.DeploymentName = r.Name
+			result.Namespace = r.Namespace
+			result.Replicas = status.Replicas
+			result.PodsReady = fmt.Sprintf("%d/%d", status.ReadyReplicas, status.Replicas)
+			first = false
+		}
+		if err != nil {
+			if status != nil {
+				result.Failures = status.Failures
+			}
+			return fmt.Errorf("%s %q: %w", r.Kind, r.Name, err)
+		}
 	}
+	return nil
 }
 
 func (s *Server) handleGetDeployStatus(c *gin.Context) {
@@ -396,14 +649,20 @@ func (s *Server) handleGetDeployStatus(c *gin.Context) {
 	state, exists := s.deployStates[deployID]
 	s.mu.RUnlock()
 
-	if !exists {
+	if exists {
+		c.JSON(http.StatusOK, s.deployStatusSnapshot(state))
+		return
+	}
+
+	// Fallback to DB (deploys finished or interrupted before a restart)
+	status, found := s.persistedDeployStatus(c.Request.Context(), deployID)
+	if !found {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "deployment not found"},
 		})
 		return
 	}
-
-	c.JSON(http.StatusOK, state.Status)
+	c.JSON(http.StatusOK, status)
 }
 
 func (s *Server) handleGetDeployHistory(c *gin.Context) {
@@ -459,6 +718,17 @@ func (s *Server) handleGetUnifiedHistory(c *gin.Context) {
 	})
 }
 
+// summarizeApplied formats per-object apply results for a step message, e.g. "Deployment/web, ConfigMap/web-config".
+func summarizeApplied(resources []models.AppliedResource) string {
+	parts := make([]string, 0, len(resources))
+	for _, r := range resources {
+		if r.Status == "applied" {
+			parts = append(parts, r.Kind+"/"+r.Name)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
 func detectServiceType(info ai.ContainerInfo) string {
 	image := strings.ToLower(info.Image)
 	switch {
@@ -510,6 +780,13 @@ func (s *Server) handleUndeployFromK8s(c *gin.Context) {
 		return
 	}
 
+	if deployment.FanOut != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "FANOUT_PARENT", Message: "multi-cluster deployment; undeploy the deployment of each cluster (fan_out.targets[].deploy_id)"},
+		})
+		return
+	}
+
 	if deployment.Status != "deployed" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error: models.ErrorDetail{Code: "INVALID_STATE", Message: "deployment is not in 'deployed' state"},
@@ -517,43 +794,35 @@ func (s *Server) handleUndeployFromK8s(c *gin.Context) {
 		return
 	}
 
-	// Best-effort delete all K8s resources (order: HPA → Ingress → ConfigMap → Service → Deployment)
+	// Best-effort delete every object labelled with this deploy ID, whatever its name
 	cluster := deployment.TargetCluster
 	ns := deployment.Namespace
 	svcName := deployment.ServiceName
 
-	// If stored manifests are available, parse and delete all resource kinds
+	var kinds []string
 	if deployment.ManifestJSON != "" {
-		var storedManifests map[string]string
+		var storedManifests models.ManifestResult
 		if err := json.Unmarshal([]byte(deployment.ManifestJSON), &storedManifests); err == nil {
-			// Phase 1: Delete known kinds in dependency-safe order
-			deleteOrder := []string{"HPA", "HorizontalPodAutoscaler", "Ingress", "HTTPRoute", "Gateway", "Service", "Deployment", "StatefulSet", "Secret", "ConfigMap", "PersistentVolumeClaim"}
-			deleted := map[string]bool{}
-			for _, kind := range deleteOrder {
-				if _, ok := storedManifests[kind]; ok {
-					deleted[kind] = true
-					if err := s.kubernetes.DeleteResource(ctx, cluster, kind, ns, svcName); err != nil {
-						slog.Warn("failed to delete resource", "kind", kind, "name", svcName, "error", err)
-					}
-				}
-			}
-			// Phase 2: Delete any remaining kinds not in the predefined order
-			for kind := range storedManifests {
-				if !deleted[kind] {
-					if err := s.kubernetes.DeleteResource(ctx, cluster, kind, ns, svcName); err != nil {
-						slog.Warn("failed to delete resource", "kind", kind, "name", svcName, "error", err)
-					}
-				}
-			}
+			kinds = manifestResultKinds(&storedManifests)
 		}
-	} else {
-		// Fallback: delete Deployment and Service by name
-		if err := s.kubernetes.DeleteResource(ctx, cluster, "Deployment", ns, svcName); err != nil {
-			slog.Warn("failed to delete k8s deployment (may already be gone)", "name", svcName, "error", err)
+	}
+	deleted, err := s.kubernetes.DeleteOwned(ctx, cluster, models.Ownership{DeployID: deployID}, kinds)
+	if err != nil {
+		slog.Warn("failed to delete labelled resources", "deploy_id", deployID, "error", err)
+	}
+	if deleted == nil {
+		deleted = []models.AppliedResource{}
+	}
+	if len(deleted) == 0 && !ownershipStamped(deployment.ManifestJSON) {
+		// Deployed before ownership labels existed: Deployment and Service by name, if confirmed
+		targets := []models.AppliedResource{
+			{Kind: "Service", Name: svcName, Namespace: ns},
+			{Kind: "Deployment", Name: svcName, Namespace: ns},
 		}
-		if err := s.kubernetes.DeleteResource(ctx, cluster, "Service", ns, svcName); err != nil {
-			slog.Warn("failed to delete k8s service (may already be gone)", "name", svcName, "error", err)
+		if !deleteByNameConfirmed(c, targets) {
+			return
 		}
+		deleted = s.deleteByName(ctx, cluster, targets)
 	}
 
 	now := time.Now()
@@ -564,10 +833,11 @@ func (s *Server) handleUndeployFromK8s(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{Success: true, Message: "Resources undeployed"})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Resources undeployed", "resources": deleted})
 }
 
-// handleRedeployToK8s re-deploys using stored manifests and creates a new history record.
+// handleRedeployToK8s re-deploys the stored manifests of an undeployed or failed deploy
+// under a new deploy ID, whose status is polled like any executed deploy.
 func (s *Server) handleRedeployToK8s(c *gin.Context) {
 	deployID := c.Param("deploy_id")
 	ctx := c.Request.Context()
@@ -580,6 +850,13 @@ func (s *Server) handleRedeployToK8s(c *gin.Context) {
 		return
 	}
 
+	if deployment.FanOut != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "FANOUT_PARENT", Message: "multi-cluster deployment; redeploy the deployment of each cluster (fan_out.targets[].deploy_id)"},
+		})
+		return
+	}
+
 	if deployment.Status == "deployed" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error: models.ErrorDetail{Code: "INVALID_STATE", Message: "deployment is already active; undeploy first"},
@@ -594,44 +871,71 @@ func (s *Server) handleRedeployToK8s(c *gin.Context) {
 		return
 	}
 
-	// Create a new deployment history record
-	newID := uuid.New().String()
-	now := time.Now()
-	newHistory := &models.DeploymentHistory{
-		ID:            newID,
-		ServiceName:   deployment.ServiceName,
-		ImageName:     deployment.ImageName,
-		ImageTag:      deployment.ImageTag,
-		ServiceType:   deployment.ServiceType,
-		Language:      deployment.Language,
-		CPURequest:    deployment.CPURequest,
-		CPULimit:      deployment.CPULimit,
-		MemoryRequest: deployment.MemoryRequest,
-		MemoryLimit:   deployment.MemoryLimit,
-		Replicas:      deployment.Replicas,
-		TargetCluster: deployment.TargetCluster,
-		Namespace:     deployment.Namespace,
-		DeployedAt:    now,
-		Success:       true,
-		Status:        "deployed",
-		ManifestJSON:  deployment.ManifestJSON,
-		AIGenerated:   deployment.AIGenerated,
-		AIConfidence:  deployment.AIConfidence,
-	}
-
-	if err := s.data.SaveDeployment(ctx, newHistory); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+	var manifests models.ManifestResult
+	if err := json.Unmarshal([]byte(deployment.ManifestJSON), &manifests); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "NO_MANIFEST", Message: "stored manifest is invalid: " + err.Error()},
 		})
 		return
 	}
 
-	slog.Info("redeployment created", "original_id", deployID, "new_id", newID)
+	// The redeploy runs like an executed deploy under a new ID: executeDeployAsync
+	// re-stamps the manifests with it, snapshots what it touches and rolls back on failure
+	newID := uuid.New().String()
+	now := time.Now()
+	state := &deployState{
+		Status: &models.DeployStatus{
+			DeployID:  newID,
+			Status:    "deploying",
+			StartedAt: &now,
+			Steps:     directDeploySteps(),
+		},
+		Response: &models.DeployResponse{
+			DeployID: newID,
+			Status:   "deploying",
+			Recommendations: &models.Recommendations{
+				CPURequest:    deployment.CPURequest,
+				CPULimit:      deployment.CPULimit,
+				MemoryRequest: deployment.MemoryRequest,
+				MemoryLimit:   deployment.MemoryLimit,
+				Replicas:      deployment.Replicas,
+			},
+			Manifests: &models.Manifests{
+				Deployment: manifests.Deployment,
+				Service:    manifests.Service,
+				HPA:        manifests.HPA,
+				ConfigMap:  manifests.ConfigMap,
+			},
+		},
+		Request: &models.DeployRequest{
+			ContainerID: deployment.ServiceName,
+			ClusterName: deployment.TargetCluster,
+			Namespace:   deployment.Namespace,
+		},
+		Manifests: &manifests,
+	}
+	target := s.clusterDelivery(ctx, deployment.TargetCluster)
+	if target != nil {
+		state.Status.Steps = gitDeploySteps(*target)
+	}
+
+	s.mu.Lock()
+	s.deployStates[newID] = state
+	s.mu.Unlock()
+	s.saveDeployToDB(ctx, state)
+
+	if target != nil {
+		go s.executeGitDeployAsync(newID, *target)
+	} else {
+		go s.executeDeployAsync(newID)
+	}
+
+	slog.Info("redeployment started", "original_id", deployID, "new_id", newID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
 		"deploy_id": newID,
-		"message":   "Redeployment created",
+		"message":   "Redeployment started",
 	})
 }
 
@@ -648,7 +952,8 @@ func (s *Server) handleDeleteDeployRecord(c *gin.Context) {
 		return
 	}
 
-	if deployment.Status == "deployed" {
+	// The parent entry of a multi-cluster deploy owns no resources itself
+	if deployment.Status == "deployed" && deployment.FanOut == nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error: models.ErrorDetail{Code: "INVALID_STATE", Message: "cannot delete record of active deployment; undeploy first"},
 		})
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/export"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// handleExportDeploy exports the manifests of a single deploy as a Helm chart or a
+// Kustomize base with per-cluster overlays.
+func (s *Server) handleExportDeploy(c *gin.Context) {
+	deployID := c.Param("deploy_id")
+
+	bundle := export.Bundle{}
+	s.mu.RLock()
+	state, exists := s.deployStates[deployID]
+	if exists && state.Response != nil && state.Response.Manifests != nil {
+		m := state.Response.Manifests
+		bundle.Manifests = nonEmpty(m.ConfigMap, m.Deployment, m.Service, m.HPA)
+		if state.Response.Recommendations != nil {
+			rec := *state.Response.Recommendations
+			bundle.Recommendations = &rec
+		}
+		if state.Request != nil {
+			bundle.Namespace = state.Request.Namespace
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(bundle.Manifests) == 0 {
+		deployment, err := s.data.GetDeployment(c.Request.Context(), deployID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "deployment not found"},
+			})
+			return
+		}
+		var stored models.ManifestResult
+		if deployment.ManifestJSON != "" {
+			if err := json.Unmarshal([]byte(deployment.ManifestJSON), &stored); err != nil {
+				c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+					Error: models.ErrorDetail{Code: "DATA_ERROR", Message: "invalid stored manifest: " + err.Error()},
+				})
+				return
+			}
+		}
+		bundle.Name = deployment.ServiceName
+		bundle.Namespace = deployment.Namespace
+		bundle.Manifests = nonEmpty(stored.ConfigMap, stored.Deployment, stored.Service, stored.HPA)
+		bundle.Recommendations = &models.Recommendations{
+			CPURequest:    deployment.CPURequest,
+			CPULimit:      deployment.CPULimit,
+			MemoryRequest: deployment.MemoryRequest,
+			MemoryLimit:   deployment.MemoryLimit,
+			Replicas:      deployment.Replicas,
+		}
+	}
+	if len(bundle.Manifests) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "NO_MANIFEST", Message: "no manifest available to export"},
+		})
+		return
+	}
+	s.writeExport(c, bundle)
+}
+
+// handleExportStack exports the manifests of a stack deploy as a Helm chart or a
+// Kustomize base with per-cluster overlays.
+func (s *Server) handleExportStack(c *gin.Context) {
+	deployID := c.Param("deploy_id")
+
+	var manifests map[string]map[string]string
+	bundle := export.Bundle{}
+	s.mu.RLock()
+	state, exists := s.stackDeployStates[deployID]
+	if exists && state.Response != nil {
+		manifests = state.Response.Manifests
+		bundle.Name = state.Response.StackName
+		if state.Request != nil {
+			bundle.Namespace = state.Request.Namespace
+		}
+		forEachStackManifest(manifests, func(kind, name, yamlContent string) {
+			bundle.Manifests = append(bundle.Manifests, yamlContent)
+		})
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		record, err := s.data.GetStackDeploy(c.Request.Context(), deployID)
+		if err != nil || record == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "stack deployment not found"},
+			})
+			return
+		}
+		if record.ManifestsJSON != "" {
+			if err := json.Unmarshal([]byte(record.ManifestsJSON), &manifests); err != nil {
+				c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+					Error: models.ErrorDetail{Code: "DATA_ERROR", Message: "invalid stored manifests: " + err.Error()},
+				})
+				return
+			}
+		}
+		bundle.Name = record.StackName
+		bundle.Namespace = record.Namespace
+		forEachStackManifest(manifests, func(kind, name, yamlContent string) {
+			bundle.Manifests = append(bundle.Manifests, yamlContent)
+		})
+	}
+	if len(bundle.Manifests) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "NO_MANIFEST", Message: "no manifests available to export"},
+		})
+		return
+	}
+	if bundle.Name == "" {
+		bundle.Name = "stack-" + deployID
+	}
+
+	s.writeExport(c, bundle)
+}
+
+// writeExport builds the requested format (?format=helm|kustomize), lints it and
+// responds with a .tgz archive, or with the files and lint result for ?output=json.
+func (s *Server) writeExport(c *gin.Context, bundle export.Bundle) {
+	// Exported manifests are no longer managed by the dashboard
+	for i, m := range bundle.Manifests {
+		if stripped, err := kubernetes.StripOwnership(m); err == nil {
+			bundle.Manifests[i] = stripped
+		}
+	}
+
+	format := c.DefaultQuery("format", "helm")
+	var files export.Files
+	var issues []export.Issue
+	var err error
+	var archiveName, root string
+	switch format {
+	case "helm":
+		files, err = export.Helm(bundle)
+		if err == nil {
+			root = exportRoot(files)
+			issues = export.LintHelm(files, root)
+			archiveName = fmt.Sprintf("%s-%s.tgz", root, export.ChartVersion)
+		}
+	case "kustomize":
+		clusters := []string{}
+		if list, listErr := s.kubernetes.ListClusters(c.Request.Context()); listErr == nil {
+			for _, cl := range list {
+				clusters = append(clusters, cl.Name)
+			}
+		} else {
+			slog.Warn("failed to list clusters for kustomize overlays", "error", listErr)
+		}
+		files, err = export.Kustomize(bundle, clusters)
+		if err == nil {
+			root = exportRoot(files)
+			issues = export.LintKustomize(files, root)
+			archiveName = root + "-kustomize.tgz"
+		}
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "format must be helm or kustomize"},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "EXPORT_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	if issues == nil {
+		issues = []export.Issue{}
+	}
+
+	if c.Query("output") == "json" {
+		c.JSON(http.StatusOK, gin.H{"format": format, "name": root, "files": files, "lint": issues})
+		return
+	}
+	if len(issues) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "EXPORT_LINT_FAILED", Message: fmt.Sprintf("%d lint issue(s) in exported %s", len(issues), format), Details: issues},
+		})
+		return
+	}
+
+	archive, err := files.TarGz()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "EXPORT_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archiveName))
+	c.Data(http.StatusOK, "application/gzip", archive)
+}
+
+// exportRoot returns the top-level directory shared by the exported files.
+func exportRoot(files export.Files) string {
+	for _, p := range files.Paths() {
+		if i := strings.Index(p, "/"); i > 0 {
+			return p[:i]
+		}
+	}
+	return ""
+}
+
+// nonEmpty returns the non-empty strings, in order.
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
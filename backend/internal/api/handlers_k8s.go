@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
 	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
 )
 
@@ -130,3 +131,152 @@ func (s *Server) handleRestartPod(c *gin.Context) {
 		Message: "Pod deleted for restart",
 	})
 }
+
+// rolloutTarget extracts the workload coordinates shared by all rollout endpoints.
+// It writes a 400 response and returns ok=false when the kind is not a rollout-capable workload.
+func rolloutTarget(c *gin.Context) (cluster, namespace, kind, name string, ok bool) {
+	kind, err := kubernetes.NormalizeWorkloadKind(c.Param("kind"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return "", "", "", "", false
+	}
+	return c.Param("cluster"), c.Param("ns"), kind, c.Param("name"), true
+}
+
+func (s *Server) handleRolloutStatus(c *gin.Context) {
+	cluster, namespace, kind, name, ok := rolloutTarget(c)
+	if !ok {
+		return
+	}
+
+	status, err := s.kubernetes.RolloutStatus(c.Request.Context(), cluster, namespace, kind, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "K8S_ERROR", Message: err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) handleRolloutHistory(c *gin.Context) {
+	cluster, namespace, kind, name, ok := rolloutTarget(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := s.kubernetes.RolloutHistory(c.Request.Context(), cluster, namespace, kind, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "K8S_ERROR", Message: err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"kind":      kind,
+		"name":      name,
+		"namespace": namespace,
+		"revisions": revisions,
+	})
+}
+
+func (s *Server) handleRolloutUndo(c *gin.Context) {
+	cluster, namespace, kind, name, ok := rolloutTarget(c)
+	if !ok {
+		return
+	}
+
+	// An empty body is allowed and rolls back to the previous revision.
+	var req models.RolloutUndoRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+			})
+			return
+		}
+	}
+	if req.Revision < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "revision must be >= 0"},
+		})
+		return
+	}
+
+	if err := s.kubernetes.UndoRollout(c.Request.Context(), cluster, namespace, kind, name, req.Revision); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "K8S_ERROR", Message: err.Error()},
+		})
+		return
+	}
+
+	message := kind + " rolled back to previous revision"
+	if req.Revision > 0 {
+		message = kind + " rolled back to revision " + strconv.FormatInt(req.Revision, 10)
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: message,
+	})
+}
+
+func (s *Server) handleRolloutPause(c *gin.Context) {
+	cluster, namespace, kind, name, ok := rolloutTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := s.kubernetes.PauseRollout(c.Request.Context(), cluster, namespace, kind, name); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "K8S_ERROR", Message: err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: kind + " rollout paused",
+	})
+}
+
+func (s *Server) handleRolloutResume(c *gin.Context) {
+	cluster, namespace, kind, name, ok := rolloutTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := s.kubernetes.ResumeRollout(c.Request.Context(), cluster, namespace, kind, name); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "K8S_ERROR", Message: err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: kind + " rollout resumed",
+	})
+}
+
+func (s *Server) handleRolloutRestart(c *gin.Context) {
+	cluster, namespace, kind, name, ok := rolloutTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := s.kubernetes.RestartRollout(c.Request.Context(), cluster, namespace, kind, name); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "K8S_ERROR", Message: err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: kind + " rolling restart triggered",
+	})
+}
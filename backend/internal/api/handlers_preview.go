@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// previewTimeout bounds the total time spent on dry-run requests for one preview.
+const previewTimeout = 60 * time.Second
+
+// handlePreviewDeploy runs a server-side dry-run of the generated manifests for a single
+// container deploy and reports which objects would be created, changed or left unchanged.
+func (s *Server) handlePreviewDeploy(c *gin.Context) {
+	deployID := c.Param("deploy_id")
+
+	var req models.PreviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+			})
+			return
+		}
+	}
+
+	s.mu.RLock()
+	state, exists := s.deployStates[deployID]
+	var clusterName, containerID string
+	var manifests models.ManifestResult
+	if exists {
+		if state.Request != nil {
+			clusterName = state.Request.ClusterName
+			containerID = state.Request.ContainerID
+		}
+		if state.Manifests != nil {
+			manifests = *state.Manifests
+		}
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "deployment not found"},
+		})
+		return
+	}
+	if req.ClusterName != "" {
+		clusterName = req.ClusterName
+	}
+	if clusterName == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "cluster_name is required"},
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), previewTimeout)
+	defer cancel()
+
+	// Diff what execute will apply, ownership labels included
+	owner := s.deployOwnership(ctx, deployID, containerID)
+
+	var parts []previewManifest
+	for _, part := range applyOrder(manifests) {
+		parts = append(parts, previewManifest{kind: part.kind, yaml: stampOwnership(part.yaml, owner)})
+	}
+	preview := models.DeployPreview{DeployID: deployID, ClusterName: clusterName, Resources: s.diffManifestSet(ctx, clusterName, parts)}
+	preview.Summary = summarizePreview(preview.Resources)
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// handlePreviewStackDeploy runs a server-side dry-run of every manifest in a stack deploy.
+func (s *Server) handlePreviewStackDeploy(c *gin.Context) {
+	deployID := c.Param("deploy_id")
+
+	var req models.PreviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+			})
+			return
+		}
+	}
+
+	s.mu.RLock()
+	state, exists := s.stackDeployStates[deployID]
+	var clusterName string
+	var containerInfos []ai.ContainerInfo
+	manifests := map[string]map[string]string{}
+	if exists {
+		if state.Request != nil {
+			clusterName = state.Request.ClusterName
+		}
+		containerInfos = state.ContainerInfos
+		if state.Manifests != nil {
+			for kind, resources := range state.Manifests.Manifests {
+				manifests[kind] = make(map[string]string, len(resources))
+				for name, yaml := range resources {
+					manifests[kind][name] = yaml
+				}
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "stack deployment not found"},
+		})
+		return
+	}
+	if req.ClusterName != "" {
+		clusterName = req.ClusterName
+	}
+	if clusterName == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "cluster_name is required"},
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), previewTimeout)
+	defer cancel()
+
+	var parts []previewManifest
+	for _, kind := range orderedManifestKinds(manifests) {
+		names := make([]string, 0, len(manifests[kind]))
+		for name := range manifests[kind] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			yamlContent := stampOwnership(manifests[kind][name], stackServiceOwnership(deployID, name, containerInfos))
+			parts = append(parts, previewManifest{service: name, kind: kind, yaml: yamlContent})
+		}
+	}
+	preview := models.DeployPreview{DeployID: deployID, ClusterName: clusterName, Resources: s.diffManifestSet(ctx, clusterName, parts)}
+	preview.Summary = summarizePreview(preview.Resources)
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// previewManifest is one manifest of a deploy preview and the service and kind it is
+// reported under.
+type previewManifest struct {
+	service, kind, yaml string
+}
+
+// diffManifestSet wraps kubernetes.DiffManifestSet so that manifest-level failures
+// (e.g. undecodable YAML) show up as an "error" entry instead of aborting the preview.
+// The manifests are diffed as one set, so objects in a Namespace the deploy creates
+// preview as created.
+func (s *Server) diffManifestSet(ctx context.Context, clusterName string, parts []previewManifest) []models.ResourceDiff {
+	yamls := make([]string, len(parts))
+	for i, part := range parts {
+		yamls[i] = part.yaml
+	}
+	diffs := []models.ResourceDiff{}
+	results, err := s.kubernetes.DiffManifestSet(ctx, clusterName, yamls)
+	for i, part := range parts {
+		if err != nil {
+			diffs = append(diffs, models.ResourceDiff{Service: part.service, Kind: part.kind, Action: "error", Error: err.Error()})
+			continue
+		}
+		for _, d := range results[i] {
+			d.Service = part.service
+			if d.Kind == "" {
+				d.Kind = part.kind
+			}
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+// orderedManifestKinds returns the kinds of a stack manifest map in apply order:
+// Namespace first, then applyKindOrder, then any remaining kinds alphabetically.
+func orderedManifestKinds(manifests map[string]map[string]string) []string {
+	kinds := []string{}
+	added := map[string]bool{}
+	for _, kind := range append([]string{"Namespace"}, applyKindOrder...) {
+		if _, ok := manifests[kind]; ok && !added[kind] {
+			kinds = append(kinds, kind)
+			added[kind] = true
+		}
+	}
+	rest := []string{}
+	for kind := range manifests {
+		if !added[kind] {
+			rest = append(rest, kind)
+		}
+	}
+	sort.Strings(rest)
+	return append(kinds, rest...)
+}
+
+func summarizePreview(diffs []models.ResourceDiff) models.PreviewSummary {
+	var summary models.PreviewSummary
+	for _, d := range diffs {
+		switch d.Action {
+		case "create":
+			summary.Create++
+		case "change":
+			summary.Change++
+		case "unchanged":
+			summary.Unchanged++
+		default:
+			summary.Error++
+		}
+	}
+	return summary
+}
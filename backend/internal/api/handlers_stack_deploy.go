@@ -7,12 +7,15 @@ import (
 	"log/slog"
 	"net/http"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
 	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
 )
 
@@ -38,6 +41,8 @@ type stackDeployState struct {
 	Request        *models.StackDeployRequest
 	Manifests      *ai.StackManifestResult
 	ContainerInfos []ai.ContainerInfo
+	SkipRollback   bool
+	ParentID       string // multi-cluster stack deploy this deploy runs one cluster of
 }
 
 // --- helpers ---
@@ -98,6 +103,11 @@ func (s *Server) stateToRecord(state *stackDeployState) *models.StackDeployRecor
 		}
 	}
 
+	commitSHA := ""
+	if state.Status.Commit != nil {
+		commitSHA = state.Status.Commit.SHA
+	}
+
 	return &models.StackDeployRecord{
 		DeployID:        state.Status.DeployID,
 		StackName:       state.Status.StackName,
@@ -117,6 +127,9 @@ func (s *Server) stateToRecord(state *stackDeployState) *models.StackDeployRecor
 		ServicesJSON:  servicesJSON,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
+		CommitSHA:     commitSHA,
+		ParentID:      state.ParentID,
+		FanOut:        stackFanOut(state),
 	}
 }
 
@@ -159,6 +172,9 @@ func recordToDetailResponse(record *models.StackDeployRecord) gin.H {
 			status.Services = svcs
 		}
 	}
+	if record.CommitSHA != "" {
+		status.Commit = &models.GitCommit{SHA: record.CommitSHA}
+	}
 
 	return gin.H{
 		"response":     resp,
@@ -190,22 +206,12 @@ func (s *Server) RestoreStackDeploy(record *models.StackDeployRecord) {
 		}
 	}
 
-	status := &models.StackDeployStatus{
-		DeployID:    record.DeployID,
-		Status:      record.Status,
-		StackName:   record.StackName,
-		StartedAt:   record.StartedAt,
-		CompletedAt: record.CompletedAt,
-		DeployOrder: record.DeployOrder,
-		Services:    map[string]*models.ServiceDeployStatus{},
-	}
-	if record.ServicesJSON != "" {
-		var svcs map[string]*models.ServiceDeployStatus
-		if err := json.Unmarshal([]byte(record.ServicesJSON), &svcs); err == nil {
-			status.Services = svcs
-		}
+	if revs, err := s.data.ListManifestRevisions(context.Background(), record.DeployID); err == nil && len(revs) > 0 {
+		resp.ManifestVersion = revs[len(revs)-1].Version
 	}
 
+	status := recordToStackStatus(record)
+
 	req := &models.StackDeployRequest{
 		ContainerIDs:   record.ContainerIDs,
 		ClusterName:    record.ClusterName,
@@ -214,16 +220,49 @@ func (s *Server) RestoreStackDeploy(record *models.StackDeployRecord) {
 		CreateNamespace: record.CreateNamespace,
 		Prompt:         record.Prompt,
 	}
+	if record.FanOut != nil {
+		req.Strategy = record.FanOut.Strategy
+		for _, t := range record.FanOut.Targets {
+			req.Targets = append(req.Targets, t.ClusterTarget)
+		}
+	}
 
 	s.mu.Lock()
 	s.stackDeployStates[record.DeployID] = &stackDeployState{
 		Status:   status,
 		Response: resp,
 		Request:  req,
+		ParentID: record.ParentID,
 	}
 	s.mu.Unlock()
 }
 
+// recordToStackStatus converts a DB record to the stack deploy status.
+func recordToStackStatus(record *models.StackDeployRecord) *models.StackDeployStatus {
+	status := &models.StackDeployStatus{
+		DeployID:    record.DeployID,
+		Status:      record.Status,
+		StackName:   record.StackName,
+		StartedAt:   record.StartedAt,
+		CompletedAt: record.CompletedAt,
+		DeployOrder: record.DeployOrder,
+		Services:    map[string]*models.ServiceDeployStatus{},
+	}
+	if record.ServicesJSON != "" {
+		var svcs map[string]*models.ServiceDeployStatus
+		if err := json.Unmarshal([]byte(record.ServicesJSON), &svcs); err == nil {
+			status.Services = svcs
+		}
+	}
+	if record.CommitSHA != "" {
+		status.Commit = &models.GitCommit{SHA: record.CommitSHA}
+	}
+	if record.ParentID != "" {
+		status.ClusterName = record.ClusterName
+	}
+	return status
+}
+
 // --- handlers ---
 
 func (s *Server) handleDeployStack(c *gin.Context) {
@@ -242,6 +281,13 @@ func (s *Server) handleDeployStack(c *gin.Context) {
 		return
 	}
 
+	if err := validateFanOut(req.Targets, req.Strategy); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return
+	}
+
 	if req.Namespace == "" {
 		req.Namespace = "default"
 	}
@@ -297,6 +343,7 @@ func (s *Server) handleDeployStack(c *gin.Context) {
 			Name:        container.Name,
 			Image:       imageName,
 			ImageTag:    imageTag,
+			ImageID:     container.ImageID,
 			EnvVars:     envVars,
 			Ports:       ports,
 			Volumes:     volumes,
@@ -304,6 +351,7 @@ func (s *Server) handleDeployStack(c *gin.Context) {
 			WorkingDir:  container.Config.WorkingDir,
 			CPUUsage:    cpuUsage,
 			MemoryUsage: memUsage,
+			Usage:       s.sampler.Profile(container.ID, container.Stats),
 		})
 	}
 
@@ -344,13 +392,17 @@ func (s *Server) handleDeployStack(c *gin.Context) {
 	s.saveStackDeployToDB(ctx, state)
 
 	// Launch AI manifest generation in background
-	go s.generateStackManifestAsync(deployID, req, containerInfos)
+	go s.generateStackManifestAsync(deployID, req, containerInfos, s.beginGeneration(deployID))
 
 	c.JSON(http.StatusOK, resp)
 }
 
-// generateStackManifestAsync runs AI manifest generation in a goroutine.
-func (s *Server) generateStackManifestAsync(deployID string, req models.StackDeployRequest, containerInfos []ai.ContainerInfo) {
+// generateStackManifestAsync runs AI manifest generation and the policy repair loop in
+// a goroutine. Its progress goes to run, started by the caller so subscribers never
+// miss the first events.
+func (s *Server) generateStackManifestAsync(deployID string, req models.StackDeployRequest, containerInfos []ai.ContainerInfo, run *generationRun) {
+	defer run.finish()
+
 	stackInfo := ai.StackContainerInfo{
 		StackName:  req.StackName,
 		Containers: containerInfos,
@@ -358,10 +410,13 @@ func (s *Server) generateStackManifestAsync(deployID string, req models.StackDep
 		UserPrompt: req.Prompt,
 	}
 
-	similar, _ := s.data.FindSimilar(context.Background(), "", "", 5)
+	similar, _ := s.data.FindSimilar(context.Background(), "", "", fewShotCandidates)
 
-	aiCtx, aiCancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	aiCtx, aiCancel := context.WithTimeout(run.context(ai.WithDeploy(context.Background(), deployID, req.RequestedBy)), 5*time.Minute)
 	defer aiCancel()
+	if req.NoCache {
+		aiCtx = ai.WithoutCache(aiCtx)
+	}
 
 	manifest, err := s.ai.GenerateStackManifest(aiCtx, stackInfo, similar)
 	if err != nil {
@@ -380,6 +435,7 @@ func (s *Server) generateStackManifestAsync(deployID string, req models.StackDep
 		}
 		return
 	}
+	manifest, violations, repairRounds := s.repairStackManifests(aiCtx, run, manifest)
 
 	s.mu.Lock()
 	state, exists := s.stackDeployStates[deployID]
@@ -394,6 +450,9 @@ func (s *Server) generateStackManifestAsync(deployID string, req models.StackDep
 	state.Response.Manifests = models.StackManifests(manifest.Manifests)
 	state.Response.Reasoning = manifest.Reasoning
 	state.Response.Confidence = manifest.Confidence
+	state.Response.Cached = manifest.Cached
+	state.Response.PolicyViolations = violations
+	state.Response.PolicyRepairRounds = repairRounds
 
 	// Inject Namespace manifest if createNamespace was requested at modal time
 	if req.CreateNamespace {
@@ -401,6 +460,8 @@ func (s *Server) generateStackManifestAsync(deployID string, req models.StackDep
 		state.Response.Topology = &manifest.Topology
 		state.Response.Manifests = models.StackManifests(manifest.Manifests)
 	}
+	state.Response.Quality = kubernetes.ScoreManifests(stackManifestDocuments(manifest))
+	state.Response.EstimatedCost = s.estimateCost(costCluster(req.ClusterName, req.Targets), stackManifestDocuments(manifest))
 
 	state.Status.Status = "pending"
 	state.Status.DeployOrder = manifest.Topology.DeployOrder
@@ -415,6 +476,11 @@ func (s *Server) generateStackManifestAsync(deployID string, req models.StackDep
 	state.Status.Services = svcStatuses
 	s.mu.Unlock()
 
+	version := s.recordRevision(context.Background(), deployID, "stack", generatedSource(manifest.Reasoning), "", 0, manifest)
+	s.mu.Lock()
+	state.Response.ManifestVersion = revisionVersion(version, state.Response.ManifestVersion)
+	s.mu.Unlock()
+
 	// Persist AI results to DB
 	s.updateStackDeployInDB(context.Background(), state)
 
@@ -466,7 +532,17 @@ func (s *Server) handleRefineStackDeploy(c *gin.Context) {
 		return
 	}
 
-	refined, err := s.ai.RefineStackManifest(c.Request.Context(), state.Manifests, req.Feedback)
+	s.mu.RLock()
+	var requestedBy string
+	if state.Request != nil {
+		requestedBy = state.Request.RequestedBy
+	}
+	s.mu.RUnlock()
+
+	run := s.beginGeneration(deployID)
+	defer run.finish()
+	aiCtx := run.context(ai.WithDeploy(c.Request.Context(), deployID, requestedBy))
+	refined, err := s.ai.RefineStackManifest(aiCtx, state.Manifests, req.Feedback)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		errMsg := err.Error()
@@ -479,29 +555,19 @@ func (s *Server) handleRefineStackDeploy(c *gin.Context) {
 		return
 	}
 
+	refined, _, repairRounds := s.repairStackManifests(aiCtx, run, refined)
+
 	// Re-inject Namespace manifest if original request had CreateNamespace
 	if state.Request != nil && state.Request.CreateNamespace {
 		injectNamespaceManifest(refined, state.Request.Namespace, state.Status.StackName)
 	}
 
+	version := s.recordRevision(c.Request.Context(), deployID, "stack", models.RevisionRefine, req.Feedback, 0, refined)
+
 	s.mu.Lock()
-	state.Manifests = refined
-	state.Response.Topology = &refined.Topology
-	state.Response.Manifests = models.StackManifests(refined.Manifests)
-	state.Response.Reasoning = refined.Reasoning
-	state.Response.Confidence = refined.Confidence
-
-	// Update deploy order in status
-	state.Status.DeployOrder = refined.Topology.DeployOrder
-	svcStatuses := make(map[string]*models.ServiceDeployStatus)
-	for _, svcName := range refined.Topology.DeployOrder {
-		svcStatuses[svcName] = &models.ServiceDeployStatus{
-			ServiceName: svcName,
-			Status:      "pending",
-			Steps:       []models.DeployStep{},
-		}
-	}
-	state.Status.Services = svcStatuses
+	s.setStackManifests(state, refined)
+	state.Response.PolicyRepairRounds = repairRounds
+	state.Response.ManifestVersion = revisionVersion(version, state.Response.ManifestVersion)
 	s.mu.Unlock()
 
 	// Persist refined manifests to DB
@@ -567,11 +633,13 @@ func (s *Server) handleRegenerateStackDeploy(c *gin.Context) {
 				Name:       container.Name,
 				Image:      imageName,
 				ImageTag:   imageTag,
+				ImageID:    container.ImageID,
 				EnvVars:    envVars,
 				Ports:      ports,
 				Volumes:    volumes,
 				Command:    container.Config.Cmd,
 				WorkingDir: container.Config.WorkingDir,
+				Usage:      s.sampler.Profile(container.ID, container.Stats),
 			})
 		}
 		if len(infos) > 0 {
@@ -597,13 +665,16 @@ func (s *Server) handleRegenerateStackDeploy(c *gin.Context) {
 	state.Response.Manifests = nil
 	state.Response.Reasoning = ""
 	state.Response.Confidence = 0
+	state.Response.Cached = false
+	state.Response.Quality = nil
+	state.Response.EstimatedCost = nil
 	state.Manifests = nil
 	s.mu.Unlock()
 
 	s.updateStackDeployInDB(c.Request.Context(), state)
 
 	// Re-launch AI generation with original request and container infos
-	go s.generateStackManifestAsync(deployID, *state.Request, state.ContainerInfos)
+	go s.generateStackManifestAsync(deployID, *state.Request, state.ContainerInfos, s.beginGeneration(deployID))
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "AI 매니페스트 재생성을 시작합니다"})
 }
@@ -720,9 +791,25 @@ func (s *Server) handleExecuteStackDeploy(c *gin.Context) {
 	}
 	if req.ClusterName != "" {
 		state.Request.ClusterName = req.ClusterName
+		if state.Manifests != nil && state.Response != nil {
+			state.Response.EstimatedCost = s.estimateCost(req.ClusterName, stackManifestDocuments(state.Manifests))
+		}
 	}
+	state.SkipRollback = req.SkipRollback
 	s.mu.Unlock()
 
+	// Multi-cluster stack deploys run one stack deploy per target cluster
+	if len(state.Request.Targets) > 0 {
+		if err := s.startStackFanOut(c.Request.Context(), deployID, state); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "INVALID_TARGETS", Message: err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, state.Status)
+		return
+	}
+
 	if state.Request.ClusterName == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "cluster_name is required — select a cluster when creating the stack deploy"},
@@ -850,8 +937,9 @@ func (s *Server) handleDeleteStackDeploy(c *gin.Context) {
 			})
 			return
 		}
-		// Cannot delete deployed (must undeploy first)
-		if status == "deployed" || status == "completed" {
+		// Cannot delete deployed (must undeploy first); a multi-cluster parent owns no resources
+		isFanOut := state.Request != nil && len(state.Request.Targets) > 0
+		if (status == "deployed" || status == "completed") && !isFanOut {
 			s.mu.Unlock()
 			c.JSON(http.StatusConflict, models.ErrorResponse{
 				Error: models.ErrorDetail{Code: "UNDEPLOY_REQUIRED", Message: "배포된 상태에서는 먼저 배포 중지를 해주세요"},
@@ -874,7 +962,7 @@ func (s *Server) handleDeleteStackDeploy(c *gin.Context) {
 			})
 			return
 		}
-		if record.Status == "deployed" || record.Status == "completed" {
+		if (record.Status == "deployed" || record.Status == "completed") && record.FanOut == nil {
 			c.JSON(http.StatusConflict, models.ErrorResponse{
 				Error: models.ErrorDetail{Code: "UNDEPLOY_REQUIRED", Message: "배포된 상태에서는 먼저 배포 중지를 해주세요"},
 			})
@@ -918,20 +1006,9 @@ func (s *Server) handleGetStackDeployStatus(c *gin.Context) {
 		return
 	}
 
-	status := &models.StackDeployStatus{
-		DeployID:    record.DeployID,
-		Status:      record.Status,
-		StackName:   record.StackName,
-		StartedAt:   record.StartedAt,
-		CompletedAt: record.CompletedAt,
-		DeployOrder: record.DeployOrder,
-		Services:    map[string]*models.ServiceDeployStatus{},
-	}
-	if record.ServicesJSON != "" {
-		var svcs map[string]*models.ServiceDeployStatus
-		if err := json.Unmarshal([]byte(record.ServicesJSON), &svcs); err == nil {
-			status.Services = svcs
-		}
+	status := recordToStackStatus(record)
+	if record.FanOut != nil {
+		status.Clusters = s.stackFanOutStatuses(c.Request.Context(), record.FanOut)
 	}
 	c.JSON(http.StatusOK, status)
 }
@@ -946,6 +1023,7 @@ func (s *Server) handleUndeployStack(c *gin.Context) {
 	var deployOrder []string
 	var manifests map[string]map[string]string
 	var currentStatus string
+	var isFanOut bool
 
 	s.mu.RLock()
 	state, inMemory := s.stackDeployStates[deployID]
@@ -956,6 +1034,7 @@ func (s *Server) handleUndeployStack(c *gin.Context) {
 		if state.Request != nil {
 			clusterName = state.Request.ClusterName
 			namespace = state.Request.Namespace
+			isFanOut = len(state.Request.Targets) > 0
 		}
 		deployOrder = state.Status.DeployOrder
 		if state.Manifests != nil {
@@ -973,11 +1052,19 @@ func (s *Server) handleUndeployStack(c *gin.Context) {
 		clusterName = record.ClusterName
 		namespace = record.Namespace
 		deployOrder = record.DeployOrder
+		isFanOut = record.FanOut != nil
 		if record.ManifestsJSON != "" {
 			json.Unmarshal([]byte(record.ManifestsJSON), &manifests)
 		}
 	}
 
+	if isFanOut {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "FANOUT_PARENT", Message: "멀티 클러스터 배포입니다. 클러스터별 배포(fan_out.targets[].deploy_id)를 각각 중지해주세요"},
+		})
+		return
+	}
+
 	if currentStatus != "deployed" && currentStatus != "completed" {
 		c.JSON(http.StatusConflict, models.ErrorResponse{
 			Error: models.ErrorDetail{Code: "INVALID_STATUS", Message: fmt.Sprintf("배포 중지는 deployed 상태에서만 가능합니다 (현재: %s)", currentStatus)},
@@ -985,68 +1072,27 @@ func (s *Server) handleUndeployStack(c *gin.Context) {
 		return
 	}
 
-	// Delete K8s resources for each service (best-effort, reverse deploy order).
-	// Within each service, delete in reverse dependency order:
-	// autoscaling/routing → networking → workloads → config/storage
-	// Known kinds are deleted first in safe order, then any unknown kinds from manifests.
-	deleteKindOrder := []string{
-		"HorizontalPodAutoscaler", "HPA",
-		"Ingress",
-		"HTTPRoute", "Gateway",
-		"Service",
-		"Deployment", "StatefulSet",
-		"Secret", "ConfigMap",
-		"PersistentVolumeClaim",
+	// Delete every object labelled with this stack ID (best-effort, reverse apply order)
+	var kinds []string
+	for kind := range manifests {
+		kinds = append(kinds, kind)
+	}
+	deleted, err := s.kubernetes.DeleteOwned(ctx, clusterName, models.Ownership{StackID: deployID}, kinds)
+	if err != nil {
+		slog.Warn("failed to delete labelled stack resources", "deploy_id", deployID, "error", err)
+	}
+	if deleted == nil {
+		deleted = []models.AppliedResource{}
 	}
-	for i := len(deployOrder) - 1; i >= 0; i-- {
-		svcName := deployOrder[i]
-		if svcName == "_namespace" {
-			continue // Namespace handled separately below
-		}
-		slog.Info("undeploying stack service", "deploy_id", deployID, "service", svcName)
-
-		if manifests != nil {
-			// Track which kinds have been processed
-			deletedKinds := map[string]bool{}
-
-			// Phase 1: Delete known kinds in safe order
-			for _, kind := range deleteKindOrder {
-				resources, ok := manifests[kind]
-				if !ok {
-					continue
-				}
-				deletedKinds[kind] = true
-				for resName := range resources {
-					if resName == svcName || strings.HasPrefix(resName, svcName+"-") || strings.HasSuffix(resName, "-"+svcName) {
-						if err := s.kubernetes.DeleteResource(ctx, clusterName, kind, namespace, resName); err != nil {
-							slog.Warn("failed to delete resource", "kind", kind, "name", resName, "error", err)
-						}
-					}
-				}
-			}
 
-			// Phase 2: Delete any remaining kinds not in the predefined order
-			for kind, resources := range manifests {
-				if kind == "Namespace" || deletedKinds[kind] {
-					continue
-				}
-				for resName := range resources {
-					if resName == svcName || strings.HasPrefix(resName, svcName+"-") || strings.HasSuffix(resName, "-"+svcName) {
-						slog.Info("deleting additional resource", "kind", kind, "name", resName)
-						if err := s.kubernetes.DeleteResource(ctx, clusterName, kind, namespace, resName); err != nil {
-							slog.Warn("failed to delete resource", "kind", kind, "name", resName, "error", err)
-						}
-					}
-				}
-			}
-		} else {
-			// Fallback: no manifests stored, try deleting Deployment and Service by service name
-			if err := s.kubernetes.DeleteResource(ctx, clusterName, "Deployment", namespace, svcName); err != nil {
-				slog.Warn("failed to delete deployment", "service", svcName, "error", err)
-			}
-			if err := s.kubernetes.DeleteResource(ctx, clusterName, "Service", namespace, svcName); err != nil {
-				slog.Warn("failed to delete service", "service", svcName, "error", err)
+	// Stacks deployed before ownership labels existed: each service's resources by name,
+	// if confirmed
+	if manifestsJSON, _ := json.Marshal(manifests); len(deleted) == 0 && !ownershipStamped(string(manifestsJSON)) {
+		if targets := stackTargetsByName(deployOrder, manifests, namespace); len(targets) > 0 {
+			if !deleteByNameConfirmed(c, targets) {
+				return
 			}
+			deleted = s.deleteByName(ctx, clusterName, targets)
 		}
 	}
 
@@ -1097,7 +1143,7 @@ func (s *Server) handleUndeployStack(c *gin.Context) {
 		s.mu.Unlock()
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "message": "스택 배포가 중지되었습니다"})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "스택 배포가 중지되었습니다", "resources": deleted})
 }
 
 // handleRedeployStack creates a new stack deployment from stored manifests.
@@ -1107,9 +1153,10 @@ func (s *Server) handleRedeployStack(c *gin.Context) {
 
 	// Only cluster_name is accepted — namespace is already baked into manifests
 	var req struct {
-		ClusterName string `json:"cluster_name"`
+		ClusterName  string `json:"cluster_name"`
+		SkipRollback bool   `json:"skip_rollback"`
 	}
-	_ = c.ShouldBindJSON(&req) // ignore errors — field optional
+	_ = c.ShouldBindJSON(&req) // ignore errors — fields optional
 
 	// Load original deployment (in-memory or DB)
 	var record *models.StackDeployRecord
@@ -1131,6 +1178,13 @@ func (s *Server) handleRedeployStack(c *gin.Context) {
 		}
 	}
 
+	if record.FanOut != nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "FANOUT_PARENT", Message: "멀티 클러스터 배포입니다. 클러스터별 배포(fan_out.targets[].deploy_id)를 각각 재배포해주세요"},
+		})
+		return
+	}
+
 	// Only allow redeploy from undeployed or failed
 	if record.Status != "undeployed" && record.Status != "failed" {
 		c.JSON(http.StatusConflict, models.ErrorResponse{
@@ -1163,25 +1217,48 @@ func (s *Server) handleRedeployStack(c *gin.Context) {
 		ns = "default"
 	}
 
-	now := time.Now()
-
 	// Parse stored manifests (namespace already set correctly)
 	var manifests models.StackManifests
 	json.Unmarshal([]byte(record.ManifestsJSON), &manifests)
+
+	// Reset the existing deployment state (reuse same deploy ID)
+	reusedState := stackStateFromRecord(deployID, record, manifests, clusterName, ns, req.SkipRollback)
+
+	s.mu.Lock()
+	s.stackDeployStates[deployID] = reusedState
+	s.mu.Unlock()
+
+	// Update DB
+	s.updateStackDeployInDB(ctx, reusedState)
+
+	// Execute deployment
+	go s.executeStackDeployAsync(deployID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"deploy_id":  deployID,
+		"status":     "deploying",
+		"stack_name": record.StackName,
+		"message":    "재배포가 시작되었습니다",
+	})
+}
+
+// stackStateFromRecord builds the execution state of a stored stack deploy that runs the
+// given manifests on a cluster, with every service pending.
+func stackStateFromRecord(deployID string, record *models.StackDeployRecord, manifests models.StackManifests, clusterName, ns string, skipRollback bool) *stackDeployState {
+	now := time.Now()
 	var topology models.StackTopology
 	json.Unmarshal([]byte(record.TopologyJSON), &topology)
 
-	// Reset the existing deployment state (reuse same deploy ID)
 	svcStatuses := make(map[string]*models.ServiceDeployStatus)
 	for _, svcName := range record.DeployOrder {
 		svcStatuses[svcName] = &models.ServiceDeployStatus{
 			ServiceName: svcName,
 			Status:      "pending",
-			Steps:       []models.DeployStep{},
+			Steps:       buildServiceSteps(svcName, map[string]map[string]string(manifests)),
 		}
 	}
 
-	reusedState := &stackDeployState{
+	return &stackDeployState{
 		Status: &models.StackDeployStatus{
 			DeployID:    deployID,
 			Status:      "deploying",
@@ -1212,31 +1289,98 @@ func (s *Server) handleRedeployStack(c *gin.Context) {
 			Reasoning:  record.Reasoning,
 			Confidence: record.Confidence,
 		},
+		SkipRollback: skipRollback,
 	}
+}
 
-	// Initialize steps for each service
-	for _, svcName := range record.DeployOrder {
-		if svc, ok := reusedState.Status.Services[svcName]; ok {
-			svc.Steps = buildServiceSteps(svcName, map[string]map[string]string(manifests))
+// rollbackStackDeploy reverts the services of a failed stack execute in reverse deploy order,
+// appending the rollback steps to each service. With SkipRollback set, only a skipped
+// rollback step is recorded on the failed service.
+func (s *Server) rollbackStackDeploy(ctx context.Context, state *stackDeployState, clusterName, failedService string, svcSnapshots map[string]*deploySnapshots) {
+	if state.SkipRollback {
+		s.mu.Lock()
+		if svc, ok := state.Status.Services[failedService]; ok {
+			svc.Steps = append(svc.Steps, skippedRollbackStep())
 		}
+		s.mu.Unlock()
+		return
 	}
 
-	s.mu.Lock()
-	s.stackDeployStates[deployID] = reusedState
-	s.mu.Unlock()
+	order := state.Status.DeployOrder
+	for i := len(order) - 1; i >= 0; i-- {
+		svcName := order[i]
+		snaps := svcSnapshots[svcName]
+		if snaps == nil || len(snaps.snaps) == 0 {
+			continue
+		}
 
-	// Update DB
-	s.updateStackDeployInDB(ctx, reusedState)
+		slog.Info("rolling back stack service", "deploy_id", state.Status.DeployID, "service", svcName)
+		steps := s.rollbackSnapshots(ctx, clusterName, snaps.snaps)
 
-	// Execute deployment
-	go s.executeStackDeployAsync(deployID)
+		s.mu.Lock()
+		if svc, ok := state.Status.Services[svcName]; ok {
+			svc.Steps = append(svc.Steps, steps...)
+			svc.Status = "rolled_back"
+			for _, step := range steps {
+				if step.Status == "failed" {
+					svc.Status = "failed"
+					break
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"deploy_id":  deployID,
-		"status":     "deploying",
-		"stack_name": record.StackName,
-		"message":    "재배포가 시작되었습니다",
-	})
+// stackTargetsByName lists the resources of an unlabelled stack by name: services in
+// reverse deploy order and, within a service, in reverse dependency order
+// (autoscaling/routing → networking → workloads → config/storage), then any other kinds
+// of the manifests. Without stored manifests, the Deployment and Service named after
+// each service are listed.
+func stackTargetsByName(deployOrder []string, manifests map[string]map[string]string, namespace string) []models.AppliedResource {
+	deleteKindOrder := []string{
+		"HorizontalPodAutoscaler", "HPA",
+		"Ingress",
+		"HTTPRoute", "Gateway",
+		"Service",
+		"Deployment", "StatefulSet",
+		"Secret", "ConfigMap",
+		"PersistentVolumeClaim",
+	}
+	var rest []string
+	for kind := range manifests {
+		if kind != "Namespace" && !slices.Contains(deleteKindOrder, kind) {
+			rest = append(rest, kind)
+		}
+	}
+	sort.Strings(rest)
+
+	var targets []models.AppliedResource
+	for i := len(deployOrder) - 1; i >= 0; i-- {
+		svcName := deployOrder[i]
+		if svcName == "_namespace" {
+			continue // Namespace handled separately
+		}
+		if manifests == nil {
+			targets = append(targets,
+				models.AppliedResource{Kind: "Deployment", Name: svcName, Namespace: namespace},
+				models.AppliedResource{Kind: "Service", Name: svcName, Namespace: namespace})
+			continue
+		}
+		for _, kind := range append(slices.Clone(deleteKindOrder), rest...) {
+			var names []string
+			for resName := range manifests[kind] {
+				if resName == svcName || strings.HasPrefix(resName, svcName+"-") || strings.HasSuffix(resName, "-"+svcName) {
+					names = append(names, resName)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				targets = append(targets, models.AppliedResource{Kind: kind, Name: name, Namespace: namespace})
+			}
+		}
+	}
+	return targets
 }
 
 // injectNamespaceManifest ensures a Namespace manifest and _namespace deploy order entry
@@ -1376,31 +1520,31 @@ func stepToKind(stepName string) string {
 	return label
 }
 
-// findManifestForStep finds the YAML manifest for a given kind and service name.
-func findManifestForStep(manifests map[string]map[string]string, kind, svcName string) string {
+// findManifestForStep finds the resource name and YAML manifest for a given kind and service name.
+func findManifestForStep(manifests map[string]map[string]string, kind, svcName string) (string, string) {
 	if manifests == nil {
-		return ""
+		return "", ""
 	}
 	// Special case: _namespace service matches all Namespace kind resources
 	if svcName == "_namespace" && kind == "Namespace" {
-		for _, yaml := range manifests["Namespace"] {
-			return yaml
+		for resName, yaml := range manifests["Namespace"] {
+			return resName, yaml
 		}
-		return ""
+		return "", ""
 	}
 	resources, ok := manifests[kind]
 	if !ok {
-		return ""
+		return "", ""
 	}
 	if yaml, ok := resources[svcName]; ok {
-		return yaml
+		return svcName, yaml
 	}
 	for resName, yaml := range resources {
 		if strings.HasPrefix(resName, svcName+"-") || strings.HasSuffix(resName, "-"+svcName) {
-			return yaml
+			return resName, yaml
 		}
 	}
-	return ""
+	return "", ""
 }
 
 func (s *Server) executeStackDeployAsync(deployID string) {
@@ -1444,6 +1588,18 @@ func (s *Server) executeStackDeployAsync(deployID string) {
 			}
 		}
 	}
+	setStepResources := func(svcName, stepName string, resources []models.AppliedResource) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if svc, ok := state.Status.Services[svcName]; ok {
+			for i, step := range svc.Steps {
+				if step.Step == stepName {
+					svc.Steps[i].Resources = resources
+					break
+				}
+			}
+		}
+	}
 
 	ctx := context.Background()
 
@@ -1456,9 +1612,22 @@ func (s *Server) executeStackDeployAsync(deployID string) {
 	if state.Manifests != nil {
 		manifests = state.Manifests.Manifests
 	}
+	containerInfos := state.ContainerInfos
 	s.mu.RUnlock()
 
+	// Clusters reconciled by a GitOps controller get the stack committed instead
+	if target := s.clusterDelivery(ctx, clusterName); target != nil {
+		s.executeStackGitDeploy(ctx, deployID, state, *target, manifests, containerInfos, updateStep)
+		return
+	}
+
 	deployFailed := false
+	failedService := ""
+
+	// Prior live state per service, for rollback on failure. The seen set is shared so an
+	// object touched by several services is restored to its state before the whole execute.
+	seen := map[string]bool{}
+	svcSnapshots := map[string]*deploySnapshots{}
 
 	for _, svcName := range state.Status.DeployOrder {
 		if deployFailed {
@@ -1483,6 +1652,7 @@ func (s *Server) executeStackDeployAsync(deployID string) {
 		s.mu.RUnlock()
 
 		serviceFailed := false
+		var svcApplied []models.AppliedResource
 		for _, step := range steps {
 			if serviceFailed {
 				updateStep(svcName, step.Step, "skipped", "Skipped due to previous failure")
@@ -1492,16 +1662,38 @@ func (s *Server) executeStackDeployAsync(deployID string) {
 			kind := stepToKind(step.Step)
 			updateStep(svcName, step.Step, "in_progress", fmt.Sprintf("Applying %s...", kind))
 
-			yamlContent := findManifestForStep(manifests, kind, svcName)
+			resName, yamlContent := findManifestForStep(manifests, kind, svcName)
 			if yamlContent == "" {
 				updateStep(svcName, step.Step, "failed", fmt.Sprintf("No manifest found for %s", kind))
 				serviceFailed = true
 				continue
 			}
 
+			// Keep the stamped manifest so the stored record matches what was applied
+			yamlContent = stampOwnership(yamlContent, stackServiceOwnership(deployID, svcName, containerInfos))
+			s.mu.Lock()
+			manifests[kind][resName] = yamlContent
+			s.mu.Unlock()
+
+			if svcSnapshots[svcName] == nil {
+				svcSnapshots[svcName] = &deploySnapshots{seen: seen}
+			}
+			if !state.SkipRollback {
+				if err := svcSnapshots[svcName].capture(ctx, s, clusterName, yamlContent); err != nil {
+					slog.Error("failed to snapshot manifest",
+						"deploy_id", deployID, "service", svcName,
+						"kind", kind, "error", err)
+					updateStep(svcName, step.Step, "failed", fmt.Sprintf("Failed: %v", err))
+					serviceFailed = true
+					continue
+				}
+			}
+
 			applyCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-			err := s.kubernetes.ApplyManifest(applyCtx, clusterName, yamlContent)
+			applied, err := s.kubernetes.ApplyManifest(applyCtx, clusterName, yamlContent)
 			cancel()
+			setStepResources(svcName, step.Step, applied)
+			svcApplied = append(svcApplied, applied...)
 
 			if err != nil {
 				slog.Error("failed to apply manifest",
@@ -1512,11 +1704,29 @@ func (s *Server) executeStackDeployAsync(deployID string) {
 				continue
 			}
 
-			updateStep(svcName, step.Step, "completed", fmt.Sprintf("%s applied", kind))
+			updateStep(svcName, step.Step, "completed", fmt.Sprintf("Applied %s", summarizeApplied(applied)))
+		}
+
+		// Wait for the workloads of the service before deploying the services after it, so
+		// that a rollout that never becomes ready is rolled back like a failed apply
+		if !serviceFailed && slices.ContainsFunc(svcApplied, isWorkload) {
+			s.mu.Lock()
+			svc.Steps = append(svc.Steps, models.DeployStep{Step: "wait_ready", Status: "pending"})
+			s.mu.Unlock()
+			updateStep(svcName, "wait_ready", "in_progress", "Waiting for pods to become ready...")
+			result := &models.DeployResult{}
+			if err := s.waitForWorkloads(ctx, clusterName, svcApplied, result); err != nil {
+				slog.Error("stack service did not become ready", "deploy_id", deployID, "service", svcName, "error", err)
+				updateStep(svcName, "wait_ready", "failed", fmt.Sprintf("Failed: %v", err))
+				serviceFailed = true
+			} else {
+				updateStep(svcName, "wait_ready", "completed", fmt.Sprintf("%s pods ready", result.PodsReady))
+			}
 		}
 
 		if serviceFailed {
 			deployFailed = true
+			failedService = svcName
 		}
 
 		// Update DB after each service
@@ -1524,6 +1734,10 @@ func (s *Server) executeStackDeployAsync(deployID string) {
 
 	}
 
+	if deployFailed {
+		s.rollbackStackDeploy(ctx, state, clusterName, failedService, svcSnapshots)
+	}
+
 	// Mark entire stack status
 	s.mu.Lock()
 	now := time.Now()
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// ownershipScanLimit bounds how many deploy records are compared against the clusters.
+const ownershipScanLimit = 1000
+
+// deployOwnership builds the ownership stamp for a single container deploy. Source
+// details are best-effort: the container may no longer exist locally.
+func (s *Server) deployOwnership(ctx context.Context, deployID, containerID string) models.Ownership {
+	owner := models.Ownership{DeployID: deployID, SourceContainer: containerID}
+	if s.docker == nil || containerID == "" {
+		return owner
+	}
+	if container, err := s.docker.GetContainer(ctx, containerID); err == nil {
+		owner.SourceContainer = container.Name
+		owner.SourceImage = container.Image
+		owner.SourceDigest = container.ImageID
+	}
+	return owner
+}
+
+// stackServiceOwnership builds the ownership stamp for one service of a stack deploy,
+// taking source details from the matching container, if any.
+func stackServiceOwnership(stackID, svcName string, infos []ai.ContainerInfo) models.Ownership {
+	owner := models.Ownership{StackID: stackID, Service: svcName}
+	for _, info := range infos {
+		if info.Name == svcName {
+			owner.SourceContainer = info.Name
+			owner.SourceImage = info.Image + ":" + info.ImageTag
+			owner.SourceDigest = info.ImageID
+			break
+		}
+	}
+	return owner
+}
+
+// stampOwnership labels every object in yamlContent with owner. If the manifest cannot
+// be decoded it is returned unchanged, so apply reports the decode error itself.
+func stampOwnership(yamlContent string, owner models.Ownership) string {
+	stamped, err := kubernetes.StampOwnership(yamlContent, owner)
+	if err != nil {
+		slog.Warn("failed to stamp ownership labels", "deploy_id", owner.DeployID, "stack_id", owner.StackID, "error", err)
+		return yamlContent
+	}
+	return stamped
+}
+
+// manifestResultKinds returns the kinds contained in a single deploy's manifests.
+func manifestResultKinds(m *models.ManifestResult) []string {
+	if m == nil {
+		return nil
+	}
+	var kinds []string
+	for _, yamlContent := range []string{m.ConfigMap, m.Deployment, m.Service, m.HPA} {
+		if yamlContent != "" {
+			kinds = append(kinds, kubernetes.ManifestKinds(yamlContent)...)
+		}
+	}
+	return kinds
+}
+
+// ownershipStamped reports whether stored manifests carry the ownership labels. Deploys
+// executed before the labels existed have none, so their objects can only be found by
+// name.
+func ownershipStamped(manifestsJSON string) bool {
+	return strings.Contains(manifestsJSON, kubernetes.LabelDeployID) || strings.Contains(manifestsJSON, kubernetes.LabelStackID)
+}
+
+// deleteByNameConfirmed reports whether the caller confirmed deleting unlabelled objects
+// by name with ?delete_by_name=true. Same-named objects need not belong to this
+// dashboard, so without it the request fails with 409 listing what would be deleted.
+func deleteByNameConfirmed(c *gin.Context, targets []models.AppliedResource) bool {
+	if c.Query("delete_by_name") == "true" {
+		return true
+	}
+	names := make([]string, 0, len(targets))
+	for _, t := range targets {
+		names = append(names, t.Kind+" "+t.Namespace+"/"+t.Name)
+	}
+	c.JSON(http.StatusConflict, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:    "UNLABELLED_RESOURCES",
+			Message: "deployed before ownership labels; retry with ?delete_by_name=true to delete by name: " + strings.Join(names, ", "),
+		},
+	})
+	return false
+}
+
+// deleteByName deletes unlabelled objects one by one, reporting each like DeleteOwned.
+func (s *Server) deleteByName(ctx context.Context, cluster string, targets []models.AppliedResource) []models.AppliedResource {
+	results := make([]models.AppliedResource, 0, len(targets))
+	for _, t := range targets {
+		t.Status = "deleted"
+		if err := s.kubernetes.DeleteResource(ctx, cluster, t.Kind, t.Namespace, t.Name); err != nil {
+			slog.Warn("failed to delete resource by name", "kind", t.Kind, "name", t.Name, "error", err)
+			t.Status = "failed"
+			t.Error = err.Error()
+		}
+		results = append(results, t)
+	}
+	return results
+}
+
+// handleScanOwnership compares the labelled objects on the clusters with the deploy
+// records. Objects whose record is deleted or missing are orphans; active records with
+// no labelled objects left on their cluster are missing, unless they were deployed
+// before the labels existed, which are reported as unlabelled.
+func (s *Server) handleScanOwnership(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	clusters := []string{}
+	if name := c.Query("cluster"); name != "" {
+		clusters = append(clusters, name)
+	} else {
+		list, err := s.kubernetes.ListClusters(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "K8S_ERROR", Message: err.Error()},
+			})
+			return
+		}
+		for _, cl := range list {
+			if cl.Status == "connected" {
+				clusters = append(clusters, cl.Name)
+			}
+		}
+	}
+
+	history, err := s.data.GetDeployHistory(ctx, ownershipScanLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	stacks, err := s.data.ListStackDeploys(ctx, ownershipScanLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+
+	deploys := make(map[string]models.DeploymentHistory, len(history))
+	for _, d := range history {
+		deploys[d.ID] = d
+	}
+	stackRecords := make(map[string]models.StackDeployRecord, len(stacks))
+	for _, st := range stacks {
+		stackRecords[st.DeployID] = st
+	}
+
+	// Deploys still executing have objects on the cluster but no final record yet
+	s.mu.RLock()
+	active := map[string]bool{}
+	for id, st := range s.deployStates {
+		if st.Status != nil && st.Status.Status == "deploying" {
+			active[id] = true
+		}
+	}
+	for id, st := range s.stackDeployStates {
+		if st.Status != nil && st.Status.Status == "deploying" {
+			active[id] = true
+		}
+	}
+	s.mu.RUnlock()
+
+	scan := models.OwnershipScan{
+		ScannedAt:  time.Now(),
+		Clusters:   clusters,
+		Orphans:    []models.OrphanResource{},
+		Missing:    []models.MissingResource{},
+		Unlabelled: []models.MissingResource{},
+	}
+
+	for _, cluster := range clusters {
+		owned, err := s.kubernetes.ListOwned(ctx, cluster, nil)
+		if err != nil {
+			scan.Errors = append(scan.Errors, cluster+": "+err.Error())
+			continue
+		}
+
+		present := map[string]bool{}
+		for _, obj := range owned {
+			present[obj.Owner.DeployID] = true
+			present[obj.Owner.StackID] = true
+
+			reason := ""
+			switch {
+			case obj.Owner.DeployID != "":
+				if active[obj.Owner.DeployID] {
+					continue
+				}
+				d, ok := deploys[obj.Owner.DeployID]
+				if !ok {
+					if rec, err := s.data.GetDeployment(ctx, obj.Owner.DeployID); err == nil && rec != nil {
+						d, ok = *rec, true
+					}
+				}
+				switch {
+				case !ok:
+					reason = "record_missing"
+				case d.Status == "deleted":
+					reason = "record_deleted"
+				}
+			case obj.Owner.StackID != "":
+				if active[obj.Owner.StackID] {
+					continue
+				}
+				st, ok := stackRecords[obj.Owner.StackID]
+				if !ok {
+					if rec, err := s.data.GetStackDeploy(ctx, obj.Owner.StackID); err == nil && rec != nil {
+						st, ok = *rec, true
+					}
+				}
+				switch {
+				case !ok:
+					reason = "record_missing"
+				case st.Status == "undeployed" || st.Status == "deleted":
+					reason = "record_deleted"
+				}
+			default:
+				reason = "record_missing"
+			}
+			if reason != "" {
+				scan.Orphans = append(scan.Orphans, models.OrphanResource{OwnedResource: obj, Reason: reason})
+			}
+		}
+
+		for _, st := range stacks {
+			if st.ClusterName != cluster || (st.Status != "deployed" && st.Status != "completed") || active[st.DeployID] {
+				continue
+			}
+			if present[st.DeployID] {
+				continue
+			}
+			missing := models.MissingResource{
+				Cluster: cluster, StackID: st.DeployID, ServiceName: st.StackName,
+				Namespace: st.Namespace, Status: st.Status,
+			}
+			if ownershipStamped(st.ManifestsJSON) {
+				scan.Missing = append(scan.Missing, missing)
+			} else {
+				scan.Unlabelled = append(scan.Unlabelled, missing)
+			}
+		}
+		for _, d := range history {
+			if d.TargetCluster != cluster || d.Status != "deployed" || active[d.ID] || present[d.ID] {
+				continue
+			}
+			if stackID := stackOfHistoryID(d.ID, stackRecords); stackID != "" {
+				continue // reported per stack above
+			}
+			missing := models.MissingResource{
+				Cluster: cluster, DeployID: d.ID, ServiceName: d.ServiceName,
+				Namespace: d.Namespace, Status: d.Status,
+			}
+			if ownershipStamped(d.ManifestJSON) {
+				scan.Missing = append(scan.Missing, missing)
+			} else {
+				scan.Unlabelled = append(scan.Unlabelled, missing)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, scan)
+}
+
+// stackOfHistoryID returns the stack deploy a "<stackID>_<service>" history row belongs to.
+func stackOfHistoryID(id string, stacks map[string]models.StackDeployRecord) string {
+	if i := strings.Index(id, "_"); i > 0 {
+		if _, ok := stacks[id[:i]]; ok {
+			return id[:i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// manifestDocuments returns the YAML documents of single-deploy manifests.
+func manifestDocuments(m *models.ManifestResult) []string {
+	return []string{m.Deployment, m.Service, m.HPA, m.ConfigMap}
+}
+
+// stackManifestDocuments returns the YAML documents of stack manifests by kind and name.
+func stackManifestDocuments(m *ai.StackManifestResult) []string {
+	kinds := make([]string, 0, len(m.Manifests))
+	for kind := range m.Manifests {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	var docs []string
+	for _, kind := range kinds {
+		names := make([]string, 0, len(m.Manifests[kind]))
+		for name := range m.Manifests[kind] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			docs = append(docs, m.Manifests[kind][name])
+		}
+	}
+	return docs
+}
+
+// checkPolicy returns the policy violations of manifest documents, or nil when the
+// policy check is off.
+func (s *Server) checkPolicy(documents []string) []models.PolicyViolation {
+	if !s.cfg.AI.Policy.Enabled {
+		return nil
+	}
+	return kubernetes.CheckPolicy(documents, s.cfg.AI.Policy.DisabledRules)
+}
+
+// policyFeedback is the refine feedback asking the model to fix violations.
+func policyFeedback(violations []models.PolicyViolation) string {
+	var b strings.Builder
+	b.WriteString("생성된 manifest가 다음 정책을 위반합니다. 모든 위반 사항을 수정하고 나머지 내용은 유지하세요.\n")
+	for _, v := range violations {
+		fmt.Fprintf(&b, "- [%s] %s: %s\n", v.Rule, v.Resource, v.Message)
+	}
+	return b.String()
+}
+
+// repairPolicy checks manifests and sends their violations back to the model through
+// refine until none remain, max_repair_rounds is reached or a refine fails. It returns
+// the violations left and the rounds used. Fallback templates are only checked: the
+// AI that would repair them is unavailable.
+func (s *Server) repairPolicy(ctx context.Context, run *generationRun, fallback bool, documents func() []string, refine func(ctx context.Context, feedback string) error) ([]models.PolicyViolation, int) {
+	violations := s.checkPolicy(documents())
+	if fallback {
+		return violations, 0
+	}
+	rounds := 0
+	for len(violations) > 0 && rounds < s.cfg.AI.Policy.MaxRepairRounds {
+		rounds++
+		run.report(models.GenerationEvent{
+			Type:    models.GenerationRepair,
+			Attempt: rounds,
+			Message: fmt.Sprintf("%d policy violations sent back to the model", len(violations)),
+		})
+		if err := refine(ctx, policyFeedback(violations)); err != nil {
+			slog.Warn("policy repair failed", "deploy_id", run.deployID, "round", rounds, "error", err)
+			break
+		}
+		violations = s.checkPolicy(documents())
+	}
+	if len(violations) > 0 {
+		slog.Info("manifests have policy violations", "deploy_id", run.deployID, "violations", len(violations), "repair_rounds", rounds)
+	}
+	return violations, rounds
+}
+
+// repairDeployManifests runs the policy repair loop on single-deploy manifests.
+func (s *Server) repairDeployManifests(ctx context.Context, run *generationRun, m *models.ManifestResult) (*models.ManifestResult, []models.PolicyViolation, int) {
+	violations, rounds := s.repairPolicy(ctx, run, generatedSource(m.Reasoning) == models.RevisionFallback,
+		func() []string { return manifestDocuments(m) },
+		func(ctx context.Context, feedback string) error {
+			refined, err := s.ai.RefineManifest(ctx, m, feedback)
+			if err != nil {
+				return err
+			}
+			m = refined
+			return nil
+		})
+	return m, violations, rounds
+}
+
+// repairStackManifests runs the policy repair loop on stack manifests.
+func (s *Server) repairStackManifests(ctx context.Context, run *generationRun, m *ai.StackManifestResult) (*ai.StackManifestResult, []models.PolicyViolation, int) {
+	violations, rounds := s.repairPolicy(ctx, run, generatedSource(m.Reasoning) == models.RevisionFallback,
+		func() []string { return stackManifestDocuments(m) },
+		func(ctx context.Context, feedback string) error {
+			refined, err := s.ai.RefineStackManifest(ctx, m, feedback)
+			if err != nil {
+				return err
+			}
+			m = refined
+			return nil
+		})
+	return m, violations, rounds
+}
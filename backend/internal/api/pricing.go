@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// PricingSetting is the settings key under which pricing changed through the API is
+// stored; it replaces the pricing of the config file on startup.
+const PricingSetting = "pricing.config"
+
+// SetPricing replaces the pricing used to estimate the cost of generated manifests.
+// Estimates already shown are recalculated on their next refine or edit.
+func (s *Server) SetPricing(pricing models.PricingConfig) {
+	s.pricingMu.Lock()
+	defer s.pricingMu.Unlock()
+	s.cfg.Pricing = pricing
+}
+
+// pricingFor returns the pricing profile of a cluster: the one mapped to the cluster,
+// then the one named after its configured type, then the default.
+func (s *Server) pricingFor(cluster string) (string, models.PricingProfile, bool) {
+	s.pricingMu.RLock()
+	defer s.pricingMu.RUnlock()
+	pricing := s.cfg.Pricing
+
+	candidates := []string{pricing.Clusters[cluster]}
+	for _, cl := range s.cfg.Clusters {
+		if cl.Name == cluster {
+			candidates = append(candidates, cl.Type)
+		}
+	}
+	candidates = append(candidates, pricing.Default)
+	for _, name := range candidates {
+		if profile, ok := pricing.Profiles[name]; ok && name != "" {
+			return name, profile, true
+		}
+	}
+	return "", models.PricingProfile{}, false
+}
+
+// estimateCost prices manifest documents with the pricing profile of a cluster, or
+// returns nil when no profile applies.
+func (s *Server) estimateCost(cluster string, documents []string) *models.EstimatedCost {
+	name, profile, ok := s.pricingFor(cluster)
+	if !ok {
+		return nil
+	}
+	cost := kubernetes.EstimateCost(documents, profile)
+	cost.Cluster, cost.Profile = cluster, name
+	return cost
+}
+
+// costCluster is the cluster whose pricing applies to a deploy: its target cluster, or
+// the first target of a multi-cluster deploy.
+func costCluster(cluster string, targets []models.ClusterTarget) string {
+	if cluster == "" && len(targets) > 0 {
+		return targets[0].ClusterName
+	}
+	return cluster
+}
+
+// validatePricing checks that rates are not negative and that the default and the
+// cluster mappings name existing profiles.
+func validatePricing(pricing models.PricingConfig) error {
+	for name, p := range pricing.Profiles {
+		if name == "" {
+			return fmt.Errorf("profile names must not be empty")
+		}
+		if p.VCPUHour < 0 || p.GiBHour < 0 || p.LoadBalancerHour < 0 || p.PVCGiBMonth < 0 {
+			return fmt.Errorf("profile %q: rates must not be negative", name)
+		}
+		if p.NodeOverhead < 0 || p.NodeOverhead > 1 {
+			return fmt.Errorf("profile %q: node_overhead must be between 0 and 1", name)
+		}
+	}
+	if _, ok := pricing.Profiles[pricing.Default]; pricing.Default != "" && !ok {
+		return fmt.Errorf("default profile %q is not defined", pricing.Default)
+	}
+	for cluster, name := range pricing.Clusters {
+		if _, ok := pricing.Profiles[name]; !ok {
+			return fmt.Errorf("cluster %q: profile %q is not defined", cluster, name)
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleGetPricing(c *gin.Context) {
+	s.pricingMu.RLock()
+	pricing := s.cfg.Pricing
+	s.pricingMu.RUnlock()
+	if pricing.Profiles == nil {
+		pricing.Profiles = map[string]models.PricingProfile{}
+	}
+	c.JSON(http.StatusOK, pricing)
+}
+
+func (s *Server) handleUpdatePricing(c *gin.Context) {
+	var req models.PricingConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return
+	}
+	if err := validatePricing(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_PRICING", Message: err.Error()},
+		})
+		return
+	}
+
+	s.SetPricing(req)
+
+	// Persist to DB
+	if s.data != nil {
+		encoded, _ := json.Marshal(req)
+		_ = s.data.SaveSetting(c.Request.Context(), PricingSetting, string(encoded))
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Pricing updated successfully",
+	})
+}
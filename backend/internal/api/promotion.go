@@ -0,0 +1,561 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/config"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// An environment promotion moves a deploy that runs in one environment of the pipeline
+// (config "environments") to the next one. Requesting a promotion only records it;
+// nothing is deployed until the promotion is approved, so every stage needs its own
+// approval. The promoted deploy reuses the stored manifests of the source deploy with
+// the patch of the target environment applied, and runs through the ordinary executors
+// under a new deploy ID — it can be undeployed, redeployed and promoted further like
+// any other deploy.
+
+// environmentList returns the configured promotion pipeline in promotion order.
+func (s *Server) environmentList() []config.EnvironmentConfig {
+	if s.cfg == nil {
+		return nil
+	}
+	return s.cfg.Environments
+}
+
+// environmentIndex returns the position of the named environment, or -1.
+func environmentIndex(envs []config.EnvironmentConfig, name string) int {
+	for i, env := range envs {
+		if env.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// environmentOverrides returns the patch of an environment, with the namespace falling
+// back to the namespace of the environment.
+func environmentOverrides(env config.EnvironmentConfig) models.ManifestOverrides {
+	o := env.Patch
+	if o.Namespace == "" {
+		o.Namespace = env.Namespace
+	}
+	return o
+}
+
+// artifactVersion identifies stored manifests by a digest of their content.
+func artifactVersion(manifestsJSON string) string {
+	sum := sha256.Sum256([]byte(manifestsJSON))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// sourceEnvironment returns the position of the environment a deploy runs in (-1 when it
+// runs in none) and the artifact version it carries. A promoted deploy is in the
+// environment it was promoted to and keeps the version of its promotion; any other
+// deploy is in the first environment of its cluster (and namespace, when the environment
+// sets one) and is versioned by its own manifests.
+func (s *Server) sourceEnvironment(ctx context.Context, deployID, cluster, namespace, manifestsJSON string) (int, string) {
+	envs := s.environmentList()
+	promoted, err := s.data.ListPromotions(ctx, models.PromotionFilter{DeployID: deployID, Limit: 1})
+	if err == nil && len(promoted) > 0 {
+		return environmentIndex(envs, promoted[0].ToEnv), promoted[0].ArtifactVersion
+	}
+	for i, env := range envs {
+		if env.Cluster == cluster && (env.Namespace == "" || env.Namespace == namespace) {
+			return i, artifactVersion(manifestsJSON)
+		}
+	}
+	return -1, artifactVersion(manifestsJSON)
+}
+
+// requestPromotion completes a promotion of a deployed source to the next environment
+// and records it as pending approval.
+func (s *Server) requestPromotion(c *gin.Context, p *models.Promotion, cluster, namespace, manifestsJSON string) {
+	ctx := c.Request.Context()
+	var req models.PromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return
+	}
+	// Approval is checked against the requester, so a promotion must name one
+	req.RequestedBy = strings.TrimSpace(req.RequestedBy)
+	if req.RequestedBy == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "requested_by is required"},
+		})
+		return
+	}
+
+	envs := s.environmentList()
+	if len(envs) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "NO_ENVIRONMENTS", Message: "no promotion environments are configured"},
+		})
+		return
+	}
+	from, version := s.sourceEnvironment(ctx, p.SourceID, cluster, namespace, manifestsJSON)
+	if from < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "UNKNOWN_ENVIRONMENT", Message: fmt.Sprintf("%s/%s is not part of a configured environment", cluster, namespace)},
+		})
+		return
+	}
+	if from == len(envs)-1 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "FINAL_ENVIRONMENT", Message: fmt.Sprintf("%q is the last environment of the pipeline", envs[from].Name)},
+		})
+		return
+	}
+
+	open, err := s.data.ListPromotions(ctx, models.PromotionFilter{SourceID: p.SourceID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	for _, existing := range open {
+		if existing.Status == models.PromotionPending || existing.Status == models.PromotionDeploying {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error: models.ErrorDetail{Code: "PROMOTION_IN_PROGRESS", Message: fmt.Sprintf("promotion %s of this deployment is %s", existing.ID, existing.Status)},
+			})
+			return
+		}
+	}
+
+	to := envs[from+1]
+	p.ID = uuid.New().String()
+	p.FromEnv = envs[from].Name
+	p.ToEnv = to.Name
+	p.ClusterName = to.Cluster
+	p.Namespace = environmentOverrides(to).Namespace
+	if p.Namespace == "" {
+		p.Namespace = namespace
+	}
+	p.ArtifactVersion = version
+	p.Status = models.PromotionPending
+	p.RequestedBy = req.RequestedBy
+	p.RequestComment = req.Comment
+	p.RequestedAt = time.Now()
+	if p.Images == nil {
+		p.Images = []string{}
+	}
+
+	if err := s.data.SavePromotion(ctx, p); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	slog.Info("promotion requested", "promotion_id", p.ID, "source_id", p.SourceID, "from", p.FromEnv, "to", p.ToEnv, "artifact_version", p.ArtifactVersion)
+	c.JSON(http.StatusCreated, p)
+}
+
+// handlePromoteDeploy requests the promotion of a deployed single deploy to the next
+// environment.
+func (s *Server) handlePromoteDeploy(c *gin.Context) {
+	deployment, ok := s.loadDeployedDeployment(c)
+	if !ok {
+		return
+	}
+	if deployment.FanOut != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "FANOUT_PARENT", Message: "multi-cluster deployment; promote the deployment of a cluster (fan_out.targets[].deploy_id)"},
+		})
+		return
+	}
+
+	var manifests models.ManifestResult
+	if err := json.Unmarshal([]byte(deployment.ManifestJSON), &manifests); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: "invalid stored manifest: " + err.Error()},
+		})
+		return
+	}
+	images, _ := kubernetes.ManifestImages(manifests.Deployment)
+
+	s.requestPromotion(c, &models.Promotion{
+		Kind:     models.PromotionDeploy,
+		SourceID: deployment.ID,
+		Name:     deployment.ServiceName,
+		Images:   images,
+	}, deployment.TargetCluster, deployment.Namespace, deployment.ManifestJSON)
+}
+
+// handlePromoteStack requests the promotion of a deployed stack to the next environment.
+func (s *Server) handlePromoteStack(c *gin.Context) {
+	record, ok := s.loadDeployedStack(c)
+	if !ok {
+		return
+	}
+	if record.FanOut != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "FANOUT_PARENT", Message: "multi-cluster deployment; promote the deployment of a cluster (fan_out.targets[].deploy_id)"},
+		})
+		return
+	}
+
+	var manifests map[string]map[string]string
+	if err := json.Unmarshal([]byte(record.ManifestsJSON), &manifests); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: "invalid stored manifests: " + err.Error()},
+		})
+		return
+	}
+
+	s.requestPromotion(c, &models.Promotion{
+		Kind:     models.PromotionStack,
+		SourceID: record.DeployID,
+		Name:     record.StackName,
+		Images:   stackImages(manifests),
+	}, record.ClusterName, record.Namespace, record.ManifestsJSON)
+}
+
+// stackImages returns the container images of every workload of a stack, sorted.
+func stackImages(manifests map[string]map[string]string) []string {
+	seen := map[string]bool{}
+	images := []string{}
+	for _, resources := range manifests {
+		for _, content := range resources {
+			found, _ := kubernetes.ManifestImages(content)
+			for _, image := range found {
+				if !seen[image] {
+					seen[image] = true
+					images = append(images, image)
+				}
+			}
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+// handleListEnvironments returns the promotion pipeline with the latest promotion
+// completed into each environment.
+func (s *Server) handleListEnvironments(c *gin.Context) {
+	ctx := c.Request.Context()
+	envs := s.environmentList()
+	result := make([]models.Environment, 0, len(envs))
+	for i, env := range envs {
+		item := models.Environment{
+			Name:      env.Name,
+			Order:     i + 1,
+			Cluster:   env.Cluster,
+			Namespace: env.Namespace,
+			Patch:     env.Patch,
+		}
+		current, err := s.data.ListPromotions(ctx, models.PromotionFilter{ToEnv: env.Name, Status: models.PromotionCompleted, Limit: 1})
+		if err == nil && len(current) > 0 {
+			item.Current = &current[0]
+		}
+		result = append(result, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"environments": result})
+}
+
+// handleListPromotions lists promotions, newest first, optionally filtered by status,
+// target environment or source deploy.
+func (s *Server) handleListPromotions(c *gin.Context) {
+	filter := models.PromotionFilter{
+		Status:   c.Query("status"),
+		ToEnv:    c.Query("env"),
+		SourceID: c.Query("source_id"),
+		Limit:    50,
+	}
+	if limit := c.Query("limit"); limit != "" {
+		fmt.Sscanf(limit, "%d", &filter.Limit)
+	}
+
+	promotions, err := s.data.ListPromotions(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"promotions": promotions, "total": len(promotions)})
+}
+
+// handleGetPromotion returns a single promotion.
+func (s *Server) handleGetPromotion(c *gin.Context) {
+	p, ok := s.loadPromotion(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// loadPromotion fetches the promotion of the request path.
+func (s *Server) loadPromotion(c *gin.Context) (*models.Promotion, bool) {
+	p, err := s.data.GetPromotion(c.Request.Context(), c.Param("promotion_id"))
+	if err != nil || p == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "PROMOTION_NOT_FOUND", Message: "promotion not found"},
+		})
+		return nil, false
+	}
+	return p, true
+}
+
+// loadPendingPromotion fetches the promotion of the request path and the decision on
+// it. The promotion must be pending, name its requester, and the approver must be
+// someone else.
+func (s *Server) loadPendingPromotion(c *gin.Context) (*models.Promotion, models.PromotionDecisionRequest, bool) {
+	var req models.PromotionDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return nil, req, false
+	}
+	p, ok := s.loadPromotion(c)
+	if !ok {
+		return nil, req, false
+	}
+	if p.Status != models.PromotionPending {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_STATUS", Message: fmt.Sprintf("promotion is not pending approval (current: %s)", p.Status)},
+		})
+		return nil, req, false
+	}
+	req.Approver = strings.TrimSpace(req.Approver)
+	if req.Approver == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: "approver is required"},
+		})
+		return nil, req, false
+	}
+	if strings.TrimSpace(p.RequestedBy) == "" {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "SELF_APPROVAL", Message: "a promotion without a requester cannot be checked against self-approval"},
+		})
+		return nil, req, false
+	}
+	if strings.EqualFold(strings.TrimSpace(p.RequestedBy), req.Approver) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "SELF_APPROVAL", Message: "a promotion must be decided by someone other than its requester"},
+		})
+		return nil, req, false
+	}
+	return p, req, true
+}
+
+// handleApprovePromotion approves a pending promotion and deploys it to the target
+// environment.
+func (s *Server) handleApprovePromotion(c *gin.Context) {
+	p, req, ok := s.loadPendingPromotion(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	envs := s.environmentList()
+	idx := environmentIndex(envs, p.ToEnv)
+	if idx < 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "UNKNOWN_ENVIRONMENT", Message: fmt.Sprintf("environment %q is no longer configured", p.ToEnv)},
+		})
+		return
+	}
+
+	now := time.Now()
+	p.DecidedBy = req.Approver
+	p.DecisionComment = req.Comment
+	p.DecidedAt = &now
+	p.DeployID = uuid.New().String()
+	p.Status = models.PromotionDeploying
+
+	if err := s.startPromotion(ctx, *p, envs[idx]); err != nil {
+		p.Status = models.PromotionFailed
+		p.Error = err.Error()
+		p.DeployID = ""
+		p.CompletedAt = &now
+		if saveErr := s.data.SavePromotion(ctx, p); saveErr != nil {
+			slog.Error("failed to save promotion", "promotion_id", p.ID, "error", saveErr)
+		}
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "PROMOTION_FAILED", Message: err.Error()},
+		})
+		return
+	}
+
+	slog.Info("promotion approved", "promotion_id", p.ID, "to", p.ToEnv, "deploy_id", p.DeployID, "approver", p.DecidedBy)
+	c.JSON(http.StatusOK, p)
+}
+
+// handleRejectPromotion rejects a pending promotion.
+func (s *Server) handleRejectPromotion(c *gin.Context) {
+	p, req, ok := s.loadPendingPromotion(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	p.Status = models.PromotionRejected
+	p.DecidedBy = req.Approver
+	p.DecisionComment = req.Comment
+	p.DecidedAt = &now
+	if err := s.data.SavePromotion(c.Request.Context(), p); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DATA_ERROR", Message: err.Error()},
+		})
+		return
+	}
+
+	slog.Info("promotion rejected", "promotion_id", p.ID, "to", p.ToEnv, "approver", p.DecidedBy)
+	c.JSON(http.StatusOK, p)
+}
+
+// startPromotion records an approved promotion as deploying and starts the deploy of the
+// source manifests, patched for env, under p.DeployID. The promotion is completed or
+// failed once the deploy finishes.
+func (s *Server) startPromotion(ctx context.Context, p models.Promotion, env config.EnvironmentConfig) error {
+	overrides := environmentOverrides(env)
+
+	var run func()
+	switch p.Kind {
+	case models.PromotionDeploy:
+		state, err := s.promotedDeployState(ctx, p, overrides)
+		if err != nil {
+			return err
+		}
+		target := s.clusterDelivery(ctx, p.ClusterName)
+		if target != nil {
+			state.Status.Steps = gitDeploySteps(*target)
+		}
+		s.mu.Lock()
+		s.deployStates[p.DeployID] = state
+		s.mu.Unlock()
+		s.saveDeployToDB(ctx, state)
+
+		run = func() {
+			if target != nil {
+				s.executeGitDeployAsync(p.DeployID, *target)
+			} else {
+				s.executeDeployAsync(p.DeployID)
+			}
+			s.mu.RLock()
+			succeeded := state.Status.Status == "completed"
+			s.mu.RUnlock()
+			s.finishPromotion(p, succeeded)
+		}
+
+	case models.PromotionStack:
+		state, err := s.promotedStackState(ctx, p, overrides)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.stackDeployStates[p.DeployID] = state
+		s.mu.Unlock()
+		s.saveStackDeployToDB(ctx, state)
+
+		run = func() {
+			s.executeStackDeployAsync(p.DeployID)
+			s.mu.RLock()
+			succeeded := state.Status.Status == "deployed"
+			s.mu.RUnlock()
+			s.finishPromotion(p, succeeded)
+		}
+
+	default:
+		return fmt.Errorf("unknown promotion kind %q", p.Kind)
+	}
+
+	if err := s.data.SavePromotion(ctx, &p); err != nil {
+		return fmt.Errorf("saving promotion: %w", err)
+	}
+	go run()
+	return nil
+}
+
+// promotedDeployState builds the state of a single deploy running the stored manifests
+// of the promoted deploy with the environment overrides applied.
+func (s *Server) promotedDeployState(ctx context.Context, p models.Promotion, overrides models.ManifestOverrides) (*deployState, error) {
+	source, err := s.data.GetDeployment(ctx, p.SourceID)
+	if err != nil {
+		return nil, fmt.Errorf("source deployment %s not found", p.SourceID)
+	}
+	var stored models.ManifestResult
+	if err := json.Unmarshal([]byte(source.ManifestJSON), &stored); err != nil {
+		return nil, fmt.Errorf("invalid stored manifest: %w", err)
+	}
+	manifests, err := overrideManifestResult(&stored, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("applying %s patch: %w", p.ToEnv, err)
+	}
+
+	now := time.Now()
+	return &deployState{
+		Status: &models.DeployStatus{
+			DeployID:  p.DeployID,
+			Status:    "deploying",
+			StartedAt: &now,
+			Steps:     directDeploySteps(),
+		},
+		Response: &models.DeployResponse{
+			DeployID: p.DeployID,
+			Status:   "deploying",
+			Recommendations: &models.Recommendations{
+				CPURequest:    source.CPURequest,
+				CPULimit:      source.CPULimit,
+				MemoryRequest: source.MemoryRequest,
+				MemoryLimit:   source.MemoryLimit,
+				Replicas:      source.Replicas,
+			},
+		},
+		Request: &models.DeployRequest{
+			ContainerID: source.ServiceName,
+			ClusterName: p.ClusterName,
+			Namespace:   p.Namespace,
+		},
+		Manifests: manifests,
+	}, nil
+}
+
+// promotedStackState builds the state of a stack deploy running the stored manifests of
+// the promoted stack with the environment overrides applied.
+func (s *Server) promotedStackState(ctx context.Context, p models.Promotion, overrides models.ManifestOverrides) (*stackDeployState, error) {
+	source, err := s.data.GetStackDeploy(ctx, p.SourceID)
+	if err != nil || source == nil {
+		return nil, fmt.Errorf("source stack deployment %s not found", p.SourceID)
+	}
+	var stored map[string]map[string]string
+	if err := json.Unmarshal([]byte(source.ManifestsJSON), &stored); err != nil {
+		return nil, fmt.Errorf("invalid stored manifests: %w", err)
+	}
+	manifests, err := overrideStackManifests(stored, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("applying %s patch: %w", p.ToEnv, err)
+	}
+	return stackStateFromRecord(p.DeployID, source, models.StackManifests(manifests), p.ClusterName, p.Namespace, false), nil
+}
+
+// finishPromotion records the outcome of the deploy of an approved promotion.
+func (s *Server) finishPromotion(p models.Promotion, succeeded bool) {
+	now := time.Now()
+	p.CompletedAt = &now
+	p.Status = models.PromotionCompleted
+	if !succeeded {
+		p.Status = models.PromotionFailed
+		p.Error = "deployment " + p.DeployID + " failed"
+	}
+	if err := s.data.SavePromotion(context.Background(), &p); err != nil {
+		slog.Error("failed to save promotion", "promotion_id", p.ID, "error", err)
+	}
+	slog.Info("promotion finished", "promotion_id", p.ID, "to", p.ToEnv, "status", p.Status, "artifact_version", p.ArtifactVersion)
+}
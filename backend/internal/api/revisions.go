@@ -0,0 +1,441 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/ai"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// Every generate, refine, edit and revert stores the resulting manifests as a new
+// revision of the deploy, so earlier versions can be compared and restored before
+// execute. Single deploys store models.ManifestResult, stacks ai.StackManifestResult.
+
+// generatedSource tells AI output from the fallback template, which the AI service
+// marks in its reasoning.
+func generatedSource(reasoning string) string {
+	if strings.HasPrefix(reasoning, "[Fallback]") {
+		return models.RevisionFallback
+	}
+	return models.RevisionGenerate
+}
+
+// recordRevision stores manifests as the next revision of a deploy, with their quality
+// report and the model that produced them, and returns its version, or 0 when it could
+// not be stored.
+func (s *Server) recordRevision(ctx context.Context, deployID, deployType, source, feedback string, revertedFrom int, manifests interface{}) int {
+	data, err := json.Marshal(manifests)
+	if err != nil {
+		slog.Error("failed to encode manifest revision", "deploy_id", deployID, "error", err)
+		return 0
+	}
+	rev := &models.ManifestRevision{
+		DeployID:     deployID,
+		Type:         deployType,
+		Source:       source,
+		Feedback:     feedback,
+		RevertedFrom: revertedFrom,
+		ManifestJSON: string(data),
+	}
+	switch m := manifests.(type) {
+	case *models.ManifestResult:
+		rev.Quality = kubernetes.ScoreManifests(manifestDocuments(m))
+		rev.Provider, rev.Model, rev.PromptVersion = m.Provider, m.Model, m.PromptVersion
+	case *ai.StackManifestResult:
+		rev.Quality = kubernetes.ScoreManifests(stackManifestDocuments(m))
+		rev.Provider, rev.Model, rev.PromptVersion = m.Provider, m.Model, m.PromptVersion
+	}
+	if err := s.data.SaveManifestRevision(ctx, rev); err != nil {
+		slog.Error("failed to save manifest revision", "deploy_id", deployID, "source", source, "error", err)
+		return 0
+	}
+	return rev.Version
+}
+
+// revisionVersion is the manifest version after a change: the stored revision, or the
+// next number when the revision could not be stored.
+func revisionVersion(recorded, current int) int {
+	if recorded > 0 {
+		return recorded
+	}
+	return current + 1
+}
+
+// setDeployManifests replaces the manifests of a single deploy, checks them against the
+// policy, scores them and updates the recommendations and the cost estimate. Callers
+// that ran the repair loop set the rounds afterwards. The caller holds s.mu.
+func (s *Server) setDeployManifests(state *deployState, m *models.ManifestResult) {
+	state.Manifests = m
+	state.Response.Manifests = &models.Manifests{
+		Deployment: m.Deployment,
+		Service:    m.Service,
+		HPA:        m.HPA,
+		ConfigMap:  m.ConfigMap,
+	}
+	state.Response.Cached = m.Cached
+	setRecommendations(state.Response, m)
+	state.Response.PolicyViolations = s.checkPolicy(manifestDocuments(m))
+	state.Response.PolicyRepairRounds = 0
+	state.Response.Quality = kubernetes.ScoreManifests(manifestDocuments(m))
+	if state.Request != nil {
+		state.Response.EstimatedCost = s.estimateCost(costCluster(state.Request.ClusterName, state.Request.Targets), manifestDocuments(m))
+	}
+}
+
+// setRecommendations reads the recommendations of a deploy from its manifests, so they
+// describe the resources that would be deployed. The sampled usage is kept.
+func setRecommendations(resp *models.DeployResponse, m *models.ManifestResult) {
+	rec := &models.Recommendations{}
+	if resp.Recommendations != nil {
+		rec.Usage = resp.Recommendations.Usage
+	}
+	sizing, replicas := kubernetes.WorkloadSizing(m.Deployment)
+	rec.CPURequest, rec.CPULimit = sizing.CPURequest, sizing.CPULimit
+	rec.MemoryRequest, rec.MemoryLimit = sizing.MemoryRequest, sizing.MemoryLimit
+	rec.Replicas = replicas
+	rec.EnableHPA = m.HPA != ""
+	rec.Reasoning = m.Reasoning
+	resp.Recommendations = rec
+}
+
+// setStackManifests replaces the manifests of a stack deploy, checks them against the
+// policy, scores and prices them and resets its services to pending in the new deploy
+// order. The caller holds s.mu.
+func (s *Server) setStackManifests(state *stackDeployState, m *ai.StackManifestResult) {
+	state.Manifests = m
+	state.Response.Topology = &m.Topology
+	state.Response.Manifests = models.StackManifests(m.Manifests)
+	state.Response.Reasoning = m.Reasoning
+	state.Response.Confidence = m.Confidence
+	state.Response.Cached = m.Cached
+	state.Response.PolicyViolations = s.checkPolicy(stackManifestDocuments(m))
+	state.Response.PolicyRepairRounds = 0
+	state.Response.Quality = kubernetes.ScoreManifests(stackManifestDocuments(m))
+	if state.Request != nil {
+		state.Response.EstimatedCost = s.estimateCost(costCluster(state.Request.ClusterName, state.Request.Targets), stackManifestDocuments(m))
+	}
+
+	state.Status.DeployOrder = m.Topology.DeployOrder
+	svcStatuses := make(map[string]*models.ServiceDeployStatus)
+	for _, svcName := range m.Topology.DeployOrder {
+		svcStatuses[svcName] = &models.ServiceDeployStatus{
+			ServiceName: svcName,
+			Status:      "pending",
+			Steps:       []models.DeployStep{},
+		}
+	}
+	state.Status.Services = svcStatuses
+}
+
+// revisionDocuments splits the manifests of a revision into YAML documents keyed by
+// resource ("deployment") for single deploys and "<Kind>/<name>" for stacks.
+func revisionDocuments(rev *models.ManifestRevision) (map[string]string, error) {
+	docs := map[string]string{}
+	if rev.Type == "stack" {
+		var m ai.StackManifestResult
+		if err := json.Unmarshal([]byte(rev.ManifestJSON), &m); err != nil {
+			return nil, err
+		}
+		for kind, resources := range m.Manifests {
+			for name, content := range resources {
+				if content != "" {
+					docs[kind+"/"+name] = content
+				}
+			}
+		}
+		return docs, nil
+	}
+
+	var m models.ManifestResult
+	if err := json.Unmarshal([]byte(rev.ManifestJSON), &m); err != nil {
+		return nil, err
+	}
+	for resource := range manifestResources {
+		if content := *manifestField(&m, resource); content != "" {
+			docs[resource] = content
+		}
+	}
+	return docs, nil
+}
+
+// diffRevisions compares two revisions document by document.
+func diffRevisions(from, to *models.ManifestRevision) ([]models.ResourceDiff, error) {
+	before, err := revisionDocuments(from)
+	if err != nil {
+		return nil, fmt.Errorf("revision %d: %w", from.Version, err)
+	}
+	after, err := revisionDocuments(to)
+	if err != nil {
+		return nil, fmt.Errorf("revision %d: %w", to.Version, err)
+	}
+
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	diffs := []models.ResourceDiff{}
+	for _, k := range keys {
+		docDiffs, err := kubernetes.DiffManifests(before[k], after[k])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		if to.Type == "stack" {
+			_, name, _ := strings.Cut(k, "/")
+			for i := range docDiffs {
+				docDiffs[i].Service = name
+			}
+		}
+		diffs = append(diffs, docDiffs...)
+	}
+	return diffs, nil
+}
+
+// --- endpoints ---
+
+func (s *Server) handleListDeployRevisions(c *gin.Context) { s.listRevisions(c, "single") }
+func (s *Server) handleListStackRevisions(c *gin.Context)  { s.listRevisions(c, "stack") }
+func (s *Server) handleGetDeployRevision(c *gin.Context)   { s.getRevision(c, "single") }
+func (s *Server) handleGetStackRevision(c *gin.Context)    { s.getRevision(c, "stack") }
+func (s *Server) handleDiffDeployRevisions(c *gin.Context) { s.compareRevisions(c, "single") }
+func (s *Server) handleDiffStackRevisions(c *gin.Context)  { s.compareRevisions(c, "stack") }
+
+// loadRevisions returns the revisions of a deploy of the type, oldest first. It writes
+// a 404 and returns nil when the deploy has none.
+func (s *Server) loadRevisions(c *gin.Context, deployType string) []models.ManifestRevision {
+	deployID := c.Param("deploy_id")
+	revs, err := s.data.ListManifestRevisions(c.Request.Context(), deployID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DB_ERROR", Message: err.Error()},
+		})
+		return nil
+	}
+	matching := revs[:0]
+	for _, rev := range revs {
+		if rev.Type == deployType {
+			matching = append(matching, rev)
+		}
+	}
+	if len(matching) == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "REVISION_NOT_FOUND", Message: fmt.Sprintf("no manifest revisions for deployment %s", deployID)},
+		})
+		return nil
+	}
+	return matching
+}
+
+// findRevision returns the revision of a version, writing a 400 or 404 when the
+// version is not a number or does not exist.
+func findRevision(c *gin.Context, revs []models.ManifestRevision, param string) *models.ManifestRevision {
+	version, err := strconv.Atoi(param)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REQUEST", Message: fmt.Sprintf("invalid revision version %q", param)},
+		})
+		return nil
+	}
+	for i := range revs {
+		if revs[i].Version == version {
+			return &revs[i]
+		}
+	}
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error: models.ErrorDetail{Code: "REVISION_NOT_FOUND", Message: fmt.Sprintf("revision %d not found", version)},
+	})
+	return nil
+}
+
+// listRevisions lists the revisions of a deploy without their manifests.
+func (s *Server) listRevisions(c *gin.Context, deployType string) {
+	revs := s.loadRevisions(c, deployType)
+	if revs == nil {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"deploy_id": c.Param("deploy_id"),
+		"current":   revs[len(revs)-1].Version,
+		"revisions": revs,
+		"total":     len(revs),
+	})
+}
+
+// getRevision returns one revision with its manifests.
+func (s *Server) getRevision(c *gin.Context, deployType string) {
+	revs := s.loadRevisions(c, deployType)
+	if revs == nil {
+		return
+	}
+	rev := findRevision(c, revs, c.Param("version"))
+	if rev == nil {
+		return
+	}
+	docs, err := revisionDocuments(rev)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REVISION", Message: err.Error()},
+		})
+		return
+	}
+	rev.Documents = docs
+	c.JSON(http.StatusOK, rev)
+}
+
+// compareRevisions compares ?from= with ?to=. to defaults to the current revision,
+// from to the one before to.
+func (s *Server) compareRevisions(c *gin.Context, deployType string) {
+	revs := s.loadRevisions(c, deployType)
+	if revs == nil {
+		return
+	}
+	to := &revs[len(revs)-1]
+	if v := c.Query("to"); v != "" {
+		if to = findRevision(c, revs, v); to == nil {
+			return
+		}
+	}
+	fromParam := c.Query("from")
+	if fromParam == "" {
+		fromParam = strconv.Itoa(to.Version - 1)
+	}
+	from := findRevision(c, revs, fromParam)
+	if from == nil {
+		return
+	}
+
+	resources, err := diffRevisions(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REVISION", Message: err.Error()},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.ManifestRevisionDiff{From: from.Version, To: to.Version, Resources: resources})
+}
+
+// handleRevertDeployRevision makes the manifests of an earlier revision current again,
+// as a new revision. Only a deploy that is not running or deployed can be reverted.
+func (s *Server) handleRevertDeployRevision(c *gin.Context) {
+	deployID := c.Param("deploy_id")
+
+	s.mu.RLock()
+	state, exists := s.deployStates[deployID]
+	var status string
+	if exists {
+		status = state.Status.Status
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "deployment not found"},
+		})
+		return
+	}
+	if status != "pending" && status != "failed" && status != "cancelled" {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_STATE", Message: fmt.Sprintf("cannot revert a deployment that is %s", status)},
+		})
+		return
+	}
+
+	revs := s.loadRevisions(c, "single")
+	if revs == nil {
+		return
+	}
+	rev := findRevision(c, revs, c.Param("version"))
+	if rev == nil {
+		return
+	}
+	var manifests models.ManifestResult
+	if err := json.Unmarshal([]byte(rev.ManifestJSON), &manifests); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REVISION", Message: err.Error()},
+		})
+		return
+	}
+
+	version := s.recordRevision(c.Request.Context(), deployID, "single", models.RevisionRevert, "", rev.Version, &manifests)
+
+	s.mu.Lock()
+	s.setDeployManifests(state, &manifests)
+	state.Response.ManifestVersion = revisionVersion(version, state.Response.ManifestVersion)
+	s.mu.Unlock()
+	s.saveDeployToDB(c.Request.Context(), state)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c.JSON(http.StatusOK, state.Response)
+}
+
+// handleRevertStackRevision makes the manifests of an earlier revision of a stack
+// current again, as a new revision.
+func (s *Server) handleRevertStackRevision(c *gin.Context) {
+	deployID := c.Param("deploy_id")
+
+	s.mu.RLock()
+	state, exists := s.stackDeployStates[deployID]
+	var status string
+	if exists {
+		status = state.Status.Status
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "stack deployment not found"},
+		})
+		return
+	}
+	if status != "pending" && status != "analyzing" {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_STATUS", Message: fmt.Sprintf("되돌리기는 pending 상태에서만 가능합니다 (현재: %s)", status)},
+		})
+		return
+	}
+
+	revs := s.loadRevisions(c, "stack")
+	if revs == nil {
+		return
+	}
+	rev := findRevision(c, revs, c.Param("version"))
+	if rev == nil {
+		return
+	}
+	var manifests ai.StackManifestResult
+	if err := json.Unmarshal([]byte(rev.ManifestJSON), &manifests); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_REVISION", Message: err.Error()},
+		})
+		return
+	}
+
+	version := s.recordRevision(c.Request.Context(), deployID, "stack", models.RevisionRevert, "", rev.Version, &manifests)
+
+	s.mu.Lock()
+	s.setStackManifests(state, &manifests)
+	state.Response.ManifestVersion = revisionVersion(version, state.Response.ManifestVersion)
+	s.mu.Unlock()
+
+	s.updateStackDeployInDB(c.Request.Context(), state)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c.JSON(http.StatusOK, state.Response)
+}
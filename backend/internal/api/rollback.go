@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// deploySnapshots accumulates the pre-deploy state of every object an execute touches.
+// Only the first snapshot of each object is kept, so a rollback restores the state from
+// before the execute started even when several manifests touch the same object.
+type deploySnapshots struct {
+	snaps []models.ResourceSnapshot
+	seen  map[string]bool
+}
+
+// capture snapshots the objects in yamlContent before they are applied. On error the
+// objects could not be restored by a rollback, so the manifest must not be applied.
+func (d *deploySnapshots) capture(ctx context.Context, s *Server, clusterName, yamlContent string) error {
+	snapCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	snaps, err := s.kubernetes.SnapshotManifest(snapCtx, clusterName, yamlContent)
+	if err != nil {
+		return fmt.Errorf("snapshotting live state for rollback: %w", err)
+	}
+	if d.seen == nil {
+		d.seen = make(map[string]bool)
+	}
+	for _, snap := range snaps {
+		key := snap.Kind + "/" + snap.Namespace + "/" + snap.Name
+		if d.seen[key] {
+			continue
+		}
+		d.seen[key] = true
+		d.snaps = append(d.snaps, snap)
+	}
+	return nil
+}
+
+// rollbackSnapshots reverts the captured objects in reverse apply order and returns one
+// "rollback:<Kind>/<name>" step per object. Objects that did not exist before are deleted.
+func (s *Server) rollbackSnapshots(ctx context.Context, clusterName string, snaps []models.ResourceSnapshot) []models.DeployStep {
+	steps := make([]models.DeployStep, 0, len(snaps))
+	for i := len(snaps) - 1; i >= 0; i-- {
+		snap := snaps[i]
+		step := models.DeployStep{Step: fmt.Sprintf("rollback:%s/%s", snap.Kind, snap.Name)}
+
+		restoreCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := s.kubernetes.RestoreSnapshot(restoreCtx, clusterName, snap)
+		cancel()
+
+		now := time.Now()
+		step.CompletedAt = &now
+		switch {
+		case err != nil:
+			slog.Error("rollback failed", "cluster", clusterName, "kind", snap.Kind, "name", snap.Name, "error", err)
+			step.Status = "failed"
+			step.Message = fmt.Sprintf("Failed: %v", err)
+		case snap.Existed:
+			step.Status = "completed"
+			step.Message = "Restored previous state"
+		default:
+			step.Status = "completed"
+			step.Message = "Deleted newly created object"
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// skippedRollbackStep reports that rollback was disabled for this execute.
+func skippedRollbackStep() models.DeployStep {
+	now := time.Now()
+	return models.DeployStep{
+		Step:        "rollback",
+		Status:      "skipped",
+		Message:     "Rollback skipped by request; failed state left on the cluster",
+		CompletedAt: &now,
+	}
+}
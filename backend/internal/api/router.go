@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"sync"
 
 	"github.com/gin-contrib/cors"
@@ -9,6 +10,7 @@ import (
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/config"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/data"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/docker"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/gitops"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/metrics"
 	"github.com/seyunpark/hybrid_cloud_dashboard/internal/registry"
@@ -17,10 +19,15 @@ import (
 
 // deployState holds in-memory state for an active deployment.
 type deployState struct {
-	Status    *models.DeployStatus
-	Response  *models.DeployResponse
-	Request   *models.DeployRequest
-	Manifests *models.ManifestResult
+	Status       *models.DeployStatus
+	Response     *models.DeployResponse
+	Request      *models.DeployRequest
+	Manifests    *models.ManifestResult
+	SkipRollback bool
+	ParentID     string // multi-cluster deploy this deploy runs one cluster of
+
+	Strategy      *models.DeployStrategy // blue/green or canary rollout; nil for rolling
+	rolloutSignal chan string            // promote/abort signal of a running rollout
 }
 
 // Server holds all dependencies for the HTTP server.
@@ -33,10 +40,27 @@ type Server struct {
 	data       data.Store
 	registry   registry.Service
 	metrics    *metrics.Collector
+	sampler    *metrics.Sampler
+	gitops     gitops.Service
 
 	deployStates      map[string]*deployState
 	stackDeployStates map[string]*stackDeployState
 	mu                sync.RWMutex
+
+	driftReports map[string]*models.DriftReport
+	driftMu      sync.RWMutex
+	driftCancel  context.CancelFunc
+	driftWG      sync.WaitGroup
+
+	feedbackTrackers map[string]*usageTracker
+	feedbackMu       sync.Mutex
+	feedbackCancel   context.CancelFunc
+	feedbackWG       sync.WaitGroup
+
+	generations  map[string]*generationLog
+	generationMu sync.Mutex
+
+	pricingMu sync.RWMutex // guards cfg.Pricing, which the API replaces
 }
 
 // NewServer creates and configures a new API server with all routes registered.
@@ -48,6 +72,7 @@ func NewServer(
 	dataStore data.Store,
 	registrySvc registry.Service,
 	metricsColl *metrics.Collector,
+	sampler *metrics.Sampler,
 ) *Server {
 	s := &Server{
 		cfg:          cfg,
@@ -57,6 +82,8 @@ func NewServer(
 		data:         dataStore,
 		registry:     registrySvc,
 		metrics:      metricsColl,
+		sampler:      sampler,
+		gitops:       gitops.NewService(),
 		deployStates:      make(map[string]*deployState),
 		stackDeployStates: make(map[string]*stackDeployState),
 	}
@@ -115,20 +142,43 @@ func (s *Server) setupRouter() {
 			k8sGroup.GET("/:cluster/services", s.handleListServices)
 			k8sGroup.POST("/:cluster/deployments/:ns/:name/scale", s.handleScaleDeployment)
 			k8sGroup.POST("/:cluster/pods/:ns/:name/restart", s.handleRestartPod)
+
+			// Rollout management (kind: deployment | statefulset | daemonset)
+			k8sGroup.GET("/:cluster/rollouts/:kind/:ns/:name/status", s.handleRolloutStatus)
+			k8sGroup.GET("/:cluster/rollouts/:kind/:ns/:name/history", s.handleRolloutHistory)
+			k8sGroup.POST("/:cluster/rollouts/:kind/:ns/:name/undo", s.handleRolloutUndo)
+			k8sGroup.POST("/:cluster/rollouts/:kind/:ns/:name/pause", s.handleRolloutPause)
+			k8sGroup.POST("/:cluster/rollouts/:kind/:ns/:name/resume", s.handleRolloutResume)
+			k8sGroup.POST("/:cluster/rollouts/:kind/:ns/:name/restart", s.handleRolloutRestart)
 		}
 
 		// Deploy
 		deployGroup := api.Group("/deploy")
 		{
 			deployGroup.POST("/docker-to-k8s", s.handleDeployDockerToK8s)
+			deployGroup.POST("/:deploy_id/preview", s.handlePreviewDeploy)
 			deployGroup.POST("/:deploy_id/execute", s.handleExecuteDeploy)
 			deployGroup.POST("/:deploy_id/refine", s.handleRefineDeploy)
+			deployGroup.POST("/:deploy_id/edit", s.handleEditDeploy)
+			deployGroup.GET("/:deploy_id/revisions", s.handleListDeployRevisions)
+			deployGroup.GET("/:deploy_id/revisions/diff", s.handleDiffDeployRevisions)
+			deployGroup.GET("/:deploy_id/revisions/:version", s.handleGetDeployRevision)
+			deployGroup.POST("/:deploy_id/revisions/:version/revert", s.handleRevertDeployRevision)
 			deployGroup.POST("/:deploy_id/undeploy", s.handleUndeployFromK8s)
 			deployGroup.POST("/:deploy_id/redeploy", s.handleRedeployToK8s)
 			deployGroup.DELETE("/:deploy_id", s.handleDeleteDeployRecord)
 			deployGroup.GET("/:deploy_id/status", s.handleGetDeployStatus)
 			deployGroup.GET("/history", s.handleGetDeployHistory)
 			deployGroup.GET("/unified-history", s.handleGetUnifiedHistory)
+			deployGroup.GET("/orphans", s.handleScanOwnership)
+			deployGroup.GET("/drift", s.handleListDriftReports)
+			deployGroup.GET("/:deploy_id/drift", s.handleCheckDeployDrift)
+			deployGroup.POST("/:deploy_id/drift/reapply", s.handleReapplyDeployDrift)
+			deployGroup.POST("/:deploy_id/drift/adopt", s.handleAdoptDeployDrift)
+			deployGroup.GET("/:deploy_id/export", s.handleExportDeploy)
+			deployGroup.POST("/:deploy_id/promote", s.handlePromoteDeploy)
+			deployGroup.POST("/:deploy_id/rollout/promote", s.handlePromoteRollout)
+			deployGroup.POST("/:deploy_id/rollout/abort", s.handleAbortRollout)
 
 			// Stack Deploy
 			deployGroup.GET("/stack", s.handleListActiveStackDeploys)
@@ -137,13 +187,37 @@ func (s *Server) setupRouter() {
 			deployGroup.POST("/stack", s.handleDeployStack)
 			deployGroup.POST("/stack/:deploy_id/refine", s.handleRefineStackDeploy)
 			deployGroup.POST("/stack/:deploy_id/regenerate", s.handleRegenerateStackDeploy)
+			deployGroup.GET("/stack/:deploy_id/revisions", s.handleListStackRevisions)
+			deployGroup.GET("/stack/:deploy_id/revisions/diff", s.handleDiffStackRevisions)
+			deployGroup.GET("/stack/:deploy_id/revisions/:version", s.handleGetStackRevision)
+			deployGroup.POST("/stack/:deploy_id/revisions/:version/revert", s.handleRevertStackRevision)
 			deployGroup.POST("/stack/:deploy_id/reopen", s.handleReopenStackDeploy)
+			deployGroup.POST("/stack/:deploy_id/preview", s.handlePreviewStackDeploy)
 			deployGroup.POST("/stack/:deploy_id/execute", s.handleExecuteStackDeploy)
 			deployGroup.POST("/stack/:deploy_id/undeploy", s.handleUndeployStack)
 			deployGroup.POST("/stack/:deploy_id/redeploy", s.handleRedeployStack)
 			deployGroup.DELETE("/stack/:deploy_id", s.handleDeleteStackDeploy)
+			deployGroup.GET("/stack/:deploy_id/drift", s.handleCheckStackDrift)
+			deployGroup.POST("/stack/:deploy_id/drift/reapply", s.handleReapplyStackDrift)
+			deployGroup.POST("/stack/:deploy_id/drift/adopt", s.handleAdoptStackDrift)
+			deployGroup.GET("/stack/:deploy_id/export", s.handleExportStack)
+			deployGroup.POST("/stack/:deploy_id/promote", s.handlePromoteStack)
 		}
 
+		// Environment promotion
+		promotionGroup := api.Group("/promotions")
+		{
+			promotionGroup.GET("", s.handleListPromotions)
+			promotionGroup.GET("/environments", s.handleListEnvironments)
+			promotionGroup.GET("/:promotion_id", s.handleGetPromotion)
+			promotionGroup.POST("/:promotion_id/approve", s.handleApprovePromotion)
+			promotionGroup.POST("/:promotion_id/reject", s.handleRejectPromotion)
+		}
+
+		// AI usage accounting
+		api.GET("/ai/usage", s.handleGetAIUsage)
+		api.GET("/ai/quality", s.handleGetAIQuality)
+
 		// Config
 		configGroup := api.Group("/config")
 		{
@@ -151,6 +225,10 @@ func (s *Server) setupRouter() {
 			configGroup.GET("/ai", s.handleGetAIConfig)
 			configGroup.PUT("/ai", s.handleUpdateAIConfig)
 			configGroup.GET("/ai/models", s.handleListAIModels)
+			configGroup.GET("/ai/cache", s.handleGetAICacheStats)
+			configGroup.DELETE("/ai/cache", s.handlePurgeAICache)
+			configGroup.GET("/pricing", s.handleGetPricing)
+			configGroup.PUT("/pricing", s.handleUpdatePricing)
 			configGroup.GET("/kubecontexts", s.handleListKubeContexts)
 			configGroup.POST("/clusters", s.handleRegisterCluster)
 			configGroup.DELETE("/clusters/:name", s.handleUnregisterCluster)
@@ -165,6 +243,8 @@ func (s *Server) setupRouter() {
 		ws.GET("/docker/:container_id/logs", s.handleDockerLogsWS)
 		ws.GET("/k8s/:cluster/:namespace/:pod/logs", s.handleK8sLogsWS)
 		ws.GET("/deploy/:deploy_id/status", s.handleDeployStatusWS)
+		ws.GET("/deploy/:deploy_id/generation", s.handleGenerationWS)
+		ws.GET("/deploy/drift", s.handleDriftWS)
 	}
 
 	s.router = r
@@ -0,0 +1,691 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/kubernetes"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// Blue/green and canary deploys run a second Deployment beside the live one instead of
+// applying the new template over it. Both stop at "await_promotion": promote finishes
+// the rollout, abort returns all traffic to the previous version. Until then the deploy
+// status is "awaiting_promotion" and the rollout progress is in DeployStatus.Rollout.
+//   - Blue/green deploys "<name>-blue" or "<name>-green" (whichever does not serve the
+//     Service), waits for readiness and switches the Service selector to it. Promote
+//     deletes the previous Deployment; abort switches the selector back.
+//   - Canary deploys "<name>-canary" behind the same Service and moves replicas from the
+//     stable Deployment to it step by step, checking restarts and readiness of the canary
+//     pods after each step. Promote applies the new template to the stable Deployment and
+//     deletes the canary; abort, or a failed health gate, deletes the canary and restores
+//     the stable replicas.
+
+var defaultCanarySteps = []int{10, 50}
+
+// canaryStepInterval is how long a canary step is observed when the request sets no
+// step_interval.
+var canaryStepInterval = 60 * time.Second
+
+// Signals of the promote and abort endpoints to a running rollout.
+const (
+	signalPromote = "promote"
+	signalAbort   = "abort"
+)
+
+// errRolloutAborted reports a rollout stopped by the abort endpoint.
+var errRolloutAborted = fmt.Errorf("aborted by request")
+
+// rolloutStrategy returns the strategy of an execute request, or nil for a plain
+// rolling apply.
+func rolloutStrategy(st *models.DeployStrategy) *models.DeployStrategy {
+	if st == nil || st.Type == "" || st.Type == models.StrategyRolling {
+		return nil
+	}
+	return st
+}
+
+// validateStrategy checks a blue/green or canary strategy.
+func validateStrategy(st *models.DeployStrategy) error {
+	switch st.Type {
+	case models.StrategyBlueGreen:
+		return nil
+	case models.StrategyCanary:
+		prev := 0
+		for _, w := range st.CanarySteps {
+			if w <= prev || w >= 100 {
+				return fmt.Errorf("canary_steps must increase strictly between 0 and 100")
+			}
+			prev = w
+		}
+		if st.StepInterval < 0 || st.MaxRestarts < 0 {
+			return fmt.Errorf("step_interval and max_restarts must not be negative")
+		}
+		return nil
+	default:
+		return fmt.Errorf("strategy type must be %q, %q or %q", models.StrategyRolling, models.StrategyBlueGreen, models.StrategyCanary)
+	}
+}
+
+// canarySteps returns the traffic shares of a canary strategy.
+func canarySteps(st *models.DeployStrategy) []int {
+	if len(st.CanarySteps) == 0 {
+		return defaultCanarySteps
+	}
+	return st.CanarySteps
+}
+
+// canaryReplicas splits the replicas of a Deployment for a canary traffic share: the
+// canary gets its share rounded up (at least one pod), the stable Deployment the rest.
+func canaryReplicas(total, weight int) (canary, stable int) {
+	canary = (total*weight + 99) / 100
+	if canary < 1 {
+		canary = 1
+	}
+	if canary > total {
+		canary = total
+	}
+	return canary, total - canary
+}
+
+// strategySteps returns the pending steps of a blue/green or canary deploy.
+func strategySteps(st *models.DeployStrategy) []models.DeployStep {
+	names := []string{"push_image"}
+	switch st.Type {
+	case models.StrategyBlueGreen:
+		names = append(names, "deploy_slot", "wait_ready", "switch_traffic", "await_promotion", "finalize")
+	case models.StrategyCanary:
+		names = append(names, "create_canary")
+		for _, w := range canarySteps(st) {
+			names = append(names, fmt.Sprintf("canary_%d", w))
+		}
+		names = append(names, "await_promotion", "promote")
+	}
+	steps := make([]models.DeployStep, 0, len(names))
+	for _, name := range names {
+		steps = append(steps, models.DeployStep{Step: name, Status: "pending"})
+	}
+	return steps
+}
+
+// checkStrategy reports why a deploy cannot use a blue/green or canary strategy.
+func (s *Server) checkStrategy(ctx context.Context, state *deployState, st *models.DeployStrategy) error {
+	if err := validateStrategy(st); err != nil {
+		return err
+	}
+	if len(state.Request.Targets) > 0 {
+		return fmt.Errorf("%s deploys run on a single cluster; remove targets", st.Type)
+	}
+	if s.clusterDelivery(ctx, state.Request.ClusterName) != nil {
+		return fmt.Errorf("cluster %q uses git delivery; %s rollouts need direct apply", state.Request.ClusterName, st.Type)
+	}
+	if state.Manifests == nil || kubernetes.ManifestObjectName(state.Manifests.Deployment, "Deployment") == "" {
+		return fmt.Errorf("%s deploys need a Deployment manifest", st.Type)
+	}
+	return nil
+}
+
+// startStrategyDeploy starts a blue/green or canary execute of an approved deploy and
+// returns the status it starts from.
+func (s *Server) startStrategyDeploy(deployID string, state *deployState, st *models.DeployStrategy, skipRollback bool) models.DeployStatus {
+	s.mu.Lock()
+	state.Status.Status = "deploying"
+	state.Status.Steps = strategySteps(st)
+	state.Status.Rollout = &models.RolloutProgress{Strategy: st.Type, Phase: models.RolloutProgressing}
+	state.Strategy = st
+	state.rolloutSignal = make(chan string, 1)
+	state.SkipRollback = skipRollback
+	started := *state.Status
+	started.Steps = append([]models.DeployStep(nil), state.Status.Steps...)
+	rollout := *state.Status.Rollout
+	started.Rollout = &rollout
+	s.mu.Unlock()
+	s.saveDeployToDB(context.Background(), state)
+
+	go s.executeStrategyDeployAsync(deployID)
+	return started
+}
+
+// executeStrategyDeployAsync runs a blue/green or canary deploy until it is promoted,
+// aborted or fails, then records the deploy history.
+func (s *Server) executeStrategyDeployAsync(deployID string) {
+	s.mu.RLock()
+	state, exists := s.deployStates[deployID]
+	s.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	ctx := context.Background()
+	defer s.saveDeployToDB(ctx, state)
+	step := s.deployStepUpdater(state)
+	s.stampDeployManifests(ctx, deployID, state)
+
+	step("push_image", "in_progress", "Checking image availability...")
+	step("push_image", "completed", "Image ready")
+
+	ns := state.Request.Namespace
+	if ns == "" {
+		ns = "default"
+	}
+	result := &models.DeployResult{
+		DeploymentName: state.Request.ContainerID,
+		Namespace:      ns,
+		PodsReady:      "0/0",
+		ServiceURL:     fmt.Sprintf("http://%s.%s.svc.cluster.local", state.Request.ContainerID, ns),
+	}
+
+	var live *models.ManifestResult
+	var err error
+	if state.Strategy.Type == models.StrategyBlueGreen {
+		live, err = s.runBlueGreen(ctx, state, ns, result, step)
+	} else {
+		live, err = s.runCanary(ctx, state, ns, result, step)
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	state.Status.Result = result
+	state.Status.CompletedAt = &now
+	switch {
+	case err == nil:
+		state.Status.Status = "completed"
+		state.Status.Rollout.Phase = models.RolloutPromoted
+		state.Manifests = live
+	case err == errRolloutAborted:
+		state.Status.Status = "aborted"
+		state.Status.Rollout.Phase = models.RolloutAborted
+	default:
+		state.Status.Status = "failed"
+		state.Status.Rollout.Phase = models.RolloutFailed
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		slog.Warn("rollout did not complete", "deploy_id", deployID, "strategy", state.Strategy.Type, "error", err)
+	}
+	history := deployHistoryRecord(deployID, state, now, err == nil)
+	history.Replicas = result.Replicas
+	if err := s.data.SaveDeployment(ctx, history); err != nil {
+		slog.Error("failed to save deployment history", "error", err)
+	}
+}
+
+// awaitPromotion marks the rollout as waiting and blocks until promote or abort.
+func (s *Server) awaitPromotion(state *deployState, step func(name, status, message string), message string) string {
+	s.mu.Lock()
+	state.Status.Status = models.RolloutAwaitingPromotion
+	state.Status.Rollout.Phase = models.RolloutAwaitingPromotion
+	s.mu.Unlock()
+	step("await_promotion", "in_progress", message)
+
+	signal := <-state.rolloutSignal
+
+	s.mu.Lock()
+	state.Status.Status = "deploying"
+	state.Status.Rollout.Phase = models.RolloutProgressing
+	s.mu.Unlock()
+	if signal == signalAbort {
+		step("await_promotion", "failed", "Aborted")
+	} else {
+		step("await_promotion", "completed", "Promoted")
+	}
+	return signal
+}
+
+// pendingSignal returns a promote or abort sent while the rollout was progressing.
+func pendingSignal(state *deployState) string {
+	select {
+	case signal := <-state.rolloutSignal:
+		return signal
+	default:
+		return ""
+	}
+}
+
+// deploymentExists reports whether a Deployment of the name runs in the namespace.
+func (s *Server) deploymentExists(ctx context.Context, cluster, ns, name string) bool {
+	deployments, err := s.kubernetes.ListDeployments(ctx, cluster, ns)
+	if err != nil {
+		return false
+	}
+	for _, d := range deployments {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForDeployment waits for the rollout of one Deployment.
+func (s *Server) waitForDeployment(ctx context.Context, cluster, ns, name string, result *models.DeployResult) error {
+	return s.waitForWorkloads(ctx, cluster, []models.AppliedResource{
+		{Kind: "Deployment", Name: name, Namespace: ns, Status: "applied"},
+	}, result)
+}
+
+// applyStep applies manifests as one step and reports the applied objects on it.
+func (s *Server) applyStep(ctx context.Context, state *deployState, name string, manifests ...string) error {
+	var all []models.AppliedResource
+	for _, m := range manifests {
+		if m == "" {
+			continue
+		}
+		applyCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		applied, err := s.kubernetes.ApplyManifest(applyCtx, state.Request.ClusterName, m)
+		cancel()
+		all = append(all, applied...)
+		if err != nil {
+			s.setStepResources(state, name, all)
+			return err
+		}
+	}
+	s.setStepResources(state, name, all)
+	return nil
+}
+
+func (s *Server) setStepResources(state *deployState, name string, resources []models.AppliedResource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, step := range state.Status.Steps {
+		if step.Step == name {
+			state.Status.Steps[i].Resources = resources
+			break
+		}
+	}
+}
+
+// skipRemaining marks every step that has not run as skipped.
+func (s *Server) skipRemaining(state *deployState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i, step := range state.Status.Steps {
+		if step.Status == "pending" || step.Status == "in_progress" {
+			state.Status.Steps[i].Status = "skipped"
+			state.Status.Steps[i].CompletedAt = &now
+		}
+	}
+}
+
+// --- blue/green ---
+
+// runBlueGreen deploys the inactive slot, switches the Service to it and waits for
+// promotion. It returns the manifests that are live once promoted.
+func (s *Server) runBlueGreen(ctx context.Context, state *deployState, ns string, result *models.DeployResult, step func(name, status, message string)) (*models.ManifestResult, error) {
+	cluster := state.Request.ClusterName
+	m := state.Manifests
+
+	// The Service tells which slot is live; a Service without a slot selector routes to
+	// the original Deployment
+	previous := ""
+	if serviceName := kubernetes.ManifestObjectName(m.Service, "Service"); serviceName != "" {
+		if services, err := s.kubernetes.ListServices(ctx, cluster, ns); err == nil {
+			for _, svc := range services {
+				if svc.Name == serviceName {
+					previous = svc.Selector[kubernetes.LabelSlot]
+				}
+			}
+		}
+	}
+	slot := "blue"
+	if previous == "blue" {
+		slot = "green"
+	}
+
+	variant, err := kubernetes.DeploymentVariant(m.Deployment, kubernetes.LabelSlot, slot, 0)
+	if err != nil {
+		return nil, s.failRollout(state, step, "deploy_slot", err)
+	}
+	live := *m
+	live.Deployment = variant.Manifest
+	if m.Service != "" {
+		if live.Service, err = kubernetes.SelectSlot(m.Service, kubernetes.LabelSlot, slot); err != nil {
+			return nil, s.failRollout(state, step, "deploy_slot", err)
+		}
+	}
+	if m.HPA != "" {
+		if live.HPA, err = kubernetes.RetargetScaler(m.HPA, variant.Original, variant.Name); err != nil {
+			return nil, s.failRollout(state, step, "deploy_slot", err)
+		}
+	}
+	previousName := variant.Original
+	if previous != "" {
+		previousName = variant.Original + "-" + previous
+	}
+
+	s.mu.Lock()
+	state.Status.Rollout.ActiveSlot = previous
+	state.Status.Rollout.PreviousSlot = previous
+	s.mu.Unlock()
+
+	// removeSlot deletes the new slot when the rollout does not go through
+	removeSlot := func() {
+		if state.SkipRollback {
+			return
+		}
+		if err := s.kubernetes.DeleteDeployment(ctx, cluster, ns, variant.Name); err != nil {
+			slog.Warn("failed to delete blue/green slot", "deployment", variant.Name, "error", err)
+		}
+	}
+
+	step("deploy_slot", "in_progress", fmt.Sprintf("Deploying %s slot (%s)...", slot, variant.Name))
+	if err := s.applyStep(ctx, state, "deploy_slot", m.ConfigMap, live.Deployment); err != nil {
+		removeSlot()
+		return nil, s.failRollout(state, step, "deploy_slot", err)
+	}
+	step("deploy_slot", "completed", fmt.Sprintf("Applied Deployment %s", variant.Name))
+
+	step("wait_ready", "in_progress", fmt.Sprintf("Waiting for %s to become ready...", variant.Name))
+	if err := s.waitForDeployment(ctx, cluster, ns, variant.Name, result); err != nil {
+		removeSlot()
+		return nil, s.failRollout(state, step, "wait_ready", err)
+	}
+	step("wait_ready", "completed", fmt.Sprintf("%s pods ready", result.PodsReady))
+
+	step("switch_traffic", "in_progress", fmt.Sprintf("Switching Service to %s...", slot))
+	if err := s.applyStep(ctx, state, "switch_traffic", live.Service, live.HPA); err != nil {
+		removeSlot()
+		return nil, s.failRollout(state, step, "switch_traffic", err)
+	}
+	s.mu.Lock()
+	state.Status.Rollout.ActiveSlot = slot
+	s.mu.Unlock()
+	step("switch_traffic", "completed", fmt.Sprintf("Service routes to %s", slot))
+	if url, err := s.kubernetes.ServiceEndpoint(ctx, cluster, ns, kubernetes.ManifestObjectName(m.Service, "Service")); err == nil && m.Service != "" {
+		result.ServiceURL = url
+	}
+
+	previousLabel := previous
+	if previousLabel == "" {
+		previousLabel = previousName
+	}
+	if s.awaitPromotion(state, step, fmt.Sprintf("Serving %s; %s kept for switch-back", slot, previousLabel)) == signalAbort {
+		// Switch back first so traffic never hits a deleted slot
+		back, hpa := m.Service, m.HPA
+		if previous != "" {
+			back, _ = kubernetes.SelectSlot(m.Service, kubernetes.LabelSlot, previous)
+			hpa, _ = kubernetes.RetargetScaler(m.HPA, variant.Original, previousName)
+		}
+		if err := s.applyStep(ctx, state, "finalize", back, hpa); err != nil {
+			step("finalize", "failed", fmt.Sprintf("Switch-back failed: %v", err))
+			return nil, err
+		}
+		removeSlot()
+		s.mu.Lock()
+		state.Status.Rollout.ActiveSlot = previous
+		s.mu.Unlock()
+		step("finalize", "completed", fmt.Sprintf("Switched back to %s; removed %s", previousLabel, variant.Name))
+		return nil, errRolloutAborted
+	}
+
+	step("finalize", "in_progress", fmt.Sprintf("Removing %s...", previousName))
+	message := fmt.Sprintf("Removed %s", previousName)
+	if !s.deploymentExists(ctx, cluster, ns, previousName) {
+		message = "No previous Deployment to remove"
+	} else if err := s.kubernetes.DeleteDeployment(ctx, cluster, ns, previousName); err != nil {
+		message = fmt.Sprintf("Promoted; failed to remove %s: %v", previousName, err)
+	}
+	step("finalize", "completed", message)
+	return &live, nil
+}
+
+// failRollout marks a step as failed and the remaining steps as skipped.
+func (s *Server) failRollout(state *deployState, step func(name, status, message string), name string, err error) error {
+	step(name, "failed", fmt.Sprintf("Failed: %v", err))
+	s.skipRemaining(state)
+	return err
+}
+
+// --- canary ---
+
+// runCanary moves traffic to a canary Deployment step by step and waits for promotion.
+// It returns the manifests that are live once promoted.
+func (s *Server) runCanary(ctx context.Context, state *deployState, ns string, result *models.DeployResult, step func(name, status, message string)) (*models.ManifestResult, error) {
+	cluster := state.Request.ClusterName
+	m := state.Manifests
+	st := state.Strategy
+	weights := canarySteps(st)
+	interval := canaryStepInterval
+	if st.StepInterval > 0 {
+		interval = time.Duration(st.StepInterval) * time.Second
+	}
+
+	variant, err := kubernetes.DeploymentVariant(m.Deployment, kubernetes.LabelTrack, "canary", 0)
+	if err != nil {
+		return nil, s.failRollout(state, step, "create_canary", err)
+	}
+	stableExists := s.deploymentExists(ctx, cluster, ns, variant.Original)
+
+	setWeight := func(weight int) error {
+		canary, stable := canaryReplicas(variant.Replicas, weight)
+		if err := s.kubernetes.ScaleDeployment(ctx, cluster, ns, variant.Name, canary); err != nil {
+			return fmt.Errorf("scaling canary: %w", err)
+		}
+		if stableExists {
+			if err := s.kubernetes.ScaleDeployment(ctx, cluster, ns, variant.Original, stable); err != nil {
+				return fmt.Errorf("scaling %s: %w", variant.Original, err)
+			}
+		}
+		s.mu.Lock()
+		state.Status.Rollout.CanaryWeight = weight
+		state.Status.Rollout.CanaryReplicas = canary
+		if stableExists {
+			state.Status.Rollout.StableReplicas = stable
+		}
+		s.mu.Unlock()
+		return nil
+	}
+	// restore deletes the canary and gives the stable Deployment its replicas back
+	restore := func() {
+		if state.SkipRollback {
+			return
+		}
+		if stableExists {
+			if err := s.kubernetes.ScaleDeployment(ctx, cluster, ns, variant.Original, variant.Replicas); err != nil {
+				slog.Warn("failed to restore stable replicas", "deployment", variant.Original, "error", err)
+			}
+		}
+		if err := s.kubernetes.DeleteDeployment(ctx, cluster, ns, variant.Name); err != nil {
+			slog.Warn("failed to delete canary", "deployment", variant.Name, "error", err)
+		}
+		s.mu.Lock()
+		state.Status.Rollout.CanaryWeight = 0
+		state.Status.Rollout.CanaryReplicas = 0
+		if stableExists {
+			state.Status.Rollout.StableReplicas = variant.Replicas
+		}
+		s.mu.Unlock()
+	}
+
+	first, _ := canaryReplicas(variant.Replicas, weights[0])
+	canaryManifest, err := kubernetes.DeploymentVariant(m.Deployment, kubernetes.LabelTrack, "canary", first)
+	if err != nil {
+		return nil, s.failRollout(state, step, "create_canary", err)
+	}
+	step("create_canary", "in_progress", fmt.Sprintf("Deploying %s...", variant.Name))
+	if err := s.applyStep(ctx, state, "create_canary", m.ConfigMap, canaryManifest.Manifest, m.Service); err != nil {
+		restore()
+		return nil, s.failRollout(state, step, "create_canary", err)
+	}
+	step("create_canary", "completed", fmt.Sprintf("Applied Deployment %s", variant.Name))
+
+	promoted := false
+	for _, weight := range weights {
+		name := fmt.Sprintf("canary_%d", weight)
+		if signal := pendingSignal(state); signal == signalAbort {
+			restore()
+			step(name, "failed", "Aborted")
+			s.skipRemaining(state)
+			return nil, errRolloutAborted
+		} else if signal == signalPromote {
+			promoted = true
+			break
+		}
+
+		step(name, "in_progress", fmt.Sprintf("Shifting %d%% of replicas to the canary...", weight))
+		if err := setWeight(weight); err != nil {
+			restore()
+			return nil, s.failRollout(state, step, name, err)
+		}
+		if err := s.waitForDeployment(ctx, cluster, ns, variant.Name, result); err != nil {
+			restore()
+			return nil, s.failRollout(state, step, name, fmt.Errorf("health gate: %w", err))
+		}
+
+		// Observe the step; promote or abort cut it short
+		select {
+		case signal := <-state.rolloutSignal:
+			if signal == signalAbort {
+				restore()
+				step(name, "failed", "Aborted")
+				s.skipRemaining(state)
+				return nil, errRolloutAborted
+			}
+			promoted = true
+		case <-time.After(interval):
+		}
+		if promoted {
+			step(name, "completed", fmt.Sprintf("%d%% — promoted early", weight))
+			break
+		}
+
+		if err := s.canaryHealthGate(ctx, cluster, ns, variant.Selector, st.MaxRestarts); err != nil {
+			restore()
+			return nil, s.failRollout(state, step, name, fmt.Errorf("health gate: %w", err))
+		}
+		canary, _ := canaryReplicas(variant.Replicas, weight)
+		step(name, "completed", fmt.Sprintf("%d%% (%d/%d replicas) healthy", weight, canary, variant.Replicas))
+	}
+
+	if promoted {
+		s.skipPendingCanarySteps(state)
+		step("await_promotion", "completed", "Promoted")
+	} else if s.awaitPromotion(state, step, fmt.Sprintf("Canary serving %d%%", weights[len(weights)-1])) == signalAbort {
+		restore()
+		step("promote", "skipped", "Aborted; canary removed")
+		return nil, errRolloutAborted
+	}
+
+	step("promote", "in_progress", fmt.Sprintf("Rolling out the new version to %s...", variant.Original))
+	if err := s.applyStep(ctx, state, "promote", m.Deployment, m.HPA); err != nil {
+		restore()
+		return nil, s.failRollout(state, step, "promote", err)
+	}
+	if err := s.waitForDeployment(ctx, cluster, ns, variant.Original, result); err != nil {
+		restore()
+		return nil, s.failRollout(state, step, "promote", err)
+	}
+	if err := s.kubernetes.DeleteDeployment(ctx, cluster, ns, variant.Name); err != nil {
+		slog.Warn("failed to delete canary", "deployment", variant.Name, "error", err)
+	}
+	s.mu.Lock()
+	state.Status.Rollout.CanaryWeight = 100
+	state.Status.Rollout.CanaryReplicas = 0
+	state.Status.Rollout.StableReplicas = variant.Replicas
+	s.mu.Unlock()
+	step("promote", "completed", fmt.Sprintf("%s updated; canary removed", variant.Original))
+	if url, err := s.kubernetes.ServiceEndpoint(ctx, cluster, ns, kubernetes.ManifestObjectName(m.Service, "Service")); err == nil && m.Service != "" {
+		result.ServiceURL = url
+	}
+	return m, nil
+}
+
+// skipPendingCanarySteps marks the canary steps a early promotion jumped over.
+func (s *Server) skipPendingCanarySteps(state *deployState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i, step := range state.Status.Steps {
+		if strings.HasPrefix(step.Step, "canary_") && step.Status == "pending" {
+			state.Status.Steps[i].Status = "skipped"
+			state.Status.Steps[i].CompletedAt = &now
+		}
+	}
+}
+
+// canaryHealthGate fails when a canary pod is not ready or the canary containers
+// restarted more often than allowed.
+func (s *Server) canaryHealthGate(ctx context.Context, cluster, ns, selector string, maxRestarts int) error {
+	pods, err := s.kubernetes.ListPods(ctx, cluster, ns, selector)
+	if err != nil {
+		return fmt.Errorf("listing canary pods: %w", err)
+	}
+	restarts := 0
+	for _, pod := range pods {
+		for _, c := range pod.Containers {
+			restarts += c.RestartCount
+			if !c.Ready {
+				return fmt.Errorf("container %s of pod %s is not ready", c.Name, pod.Name)
+			}
+		}
+	}
+	if restarts > maxRestarts {
+		return fmt.Errorf("canary containers restarted %d times (allowed %d)", restarts, maxRestarts)
+	}
+	return nil
+}
+
+// --- promote / abort endpoints ---
+
+// handlePromoteRollout finishes a blue/green or canary deploy: blue/green removes the
+// previous slot, canary moves the stable Deployment to the new version.
+func (s *Server) handlePromoteRollout(c *gin.Context) {
+	s.signalRollout(c, signalPromote)
+}
+
+// handleAbortRollout stops a blue/green or canary deploy and returns all traffic to the
+// previous version.
+func (s *Server) handleAbortRollout(c *gin.Context) {
+	s.signalRollout(c, signalAbort)
+}
+
+func (s *Server) signalRollout(c *gin.Context, signal string) {
+	deployID := c.Param("deploy_id")
+
+	s.mu.RLock()
+	state, exists := s.deployStates[deployID]
+	var phase string
+	if exists && state.Status.Rollout != nil {
+		phase = state.Status.Rollout.Phase
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "DEPLOY_NOT_FOUND", Message: "deployment not found"},
+		})
+		return
+	}
+	if phase == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "NO_ROLLOUT", Message: "deployment does not use a blue/green or canary strategy"},
+		})
+		return
+	}
+	if phase != models.RolloutProgressing && phase != models.RolloutAwaitingPromotion {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_STATE", Message: fmt.Sprintf("rollout is already %s", phase)},
+		})
+		return
+	}
+
+	select {
+	case state.rolloutSignal <- signal:
+	default:
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{Code: "INVALID_STATE", Message: "a promote or abort is already in progress"},
+		})
+		return
+	}
+
+	slog.Info("rollout signalled", "deploy_id", deployID, "signal", signal)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c.JSON(http.StatusAccepted, state.Status)
+}
@@ -297,6 +297,15 @@ func (s *Server) handleDeployStatusWS(c *gin.Context) {
 			s.mu.RUnlock()
 
 			if !exists {
+				// Deploys no longer in memory send their persisted status once
+				if status, found := s.persistedDeployStatus(ctx, deployID); found {
+					_ = conn.WriteJSON(gin.H{
+						"type":      "deploy_status",
+						"deploy_id": deployID,
+						"data":      status,
+					})
+					return
+				}
 				_ = conn.WriteJSON(gin.H{
 					"type":      "deploy_status",
 					"deploy_id": deployID,
@@ -305,12 +314,13 @@ func (s *Server) handleDeployStatusWS(c *gin.Context) {
 				return
 			}
 
-			currentJSON, _ := json.Marshal(state.Status)
+			status := s.deployStatusSnapshot(state)
+			currentJSON, _ := json.Marshal(status)
 			if string(currentJSON) != string(lastJSON) {
 				if err := conn.WriteJSON(gin.H{
 					"type":      "deploy_status",
 					"deploy_id": deployID,
-					"data":      state.Status,
+					"data":      status,
 				}); err != nil {
 					return
 				}
@@ -318,10 +328,66 @@ func (s *Server) handleDeployStatusWS(c *gin.Context) {
 			}
 
 			// If deployment is completed or failed, send final update and close
-			if state.Status.Status == "completed" || state.Status.Status == "failed" || state.Status.Status == "cancelled" {
+			if status.Status == "completed" || status.Status == "failed" || status.Status == "cancelled" || status.Status == "partial" || status.Status == "aborted" {
 				return
 			}
 		}
 	}
 }
 
+
+// handleDriftWS streams drift reports as the periodic check produces them. With
+// ?deploy_id= only that deploy's reports are sent.
+func (s *Server) handleDriftWS(c *gin.Context) {
+	deployID := c.Query("deploy_id")
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		slog.Error("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	sent := map[string]time.Time{}
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		s.driftMu.RLock()
+		var updates []interface{}
+		for id, report := range s.driftReports {
+			if (deployID == "" || id == deployID) && report.CheckedAt.After(sent[id]) {
+				updates = append(updates, report)
+				sent[id] = report.CheckedAt
+			}
+		}
+		s.driftMu.RUnlock()
+
+		for _, report := range updates {
+			if err := conn.WriteJSON(gin.H{
+				"type": "drift_report",
+				"data": report,
+			}); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
 )
 
 // Config is the root configuration structure loaded from config.yaml.
@@ -22,6 +24,12 @@ type Config struct {
 	Security  SecurityConfig  `yaml:"security"`
 	Features  FeaturesConfig  `yaml:"features"`
 	Limits    LimitsConfig    `yaml:"limits"`
+	Drift     DriftConfig     `yaml:"drift"`
+	Feedback  FeedbackConfig  `yaml:"feedback"`
+
+	Environments []EnvironmentConfig `yaml:"environments"` // promotion pipeline, in promotion order
+
+	Pricing models.PricingConfig `yaml:"pricing"` // cost estimates of generated manifests
 }
 
 type ServerConfig struct {
@@ -39,6 +47,49 @@ type AIConfig struct {
 	MaxTokens   int          `yaml:"max_tokens"`
 	FewShot     FewShotConfig `yaml:"few_shot"`
 	Cache       CacheConfig  `yaml:"cache"`
+
+	// Per-provider overrides keyed by provider name (openai, claude, gemini,
+	// azure-openai, openai-compatible, ollama, vllm, lmstudio)
+	Providers map[string]ProviderConfig `yaml:"providers"`
+
+	// USD prices per million tokens keyed by model name or model name prefix
+	Pricing map[string]ModelPrice `yaml:"pricing"`
+	Budget  BudgetConfig          `yaml:"budget"`
+
+	Policy PolicyConfig `yaml:"policy"`
+}
+
+// PolicyConfig checks generated manifests against the Kubernetes schemas and the
+// policy rules, and sends violations back to the model for up to MaxRepairRounds
+// refinements (0 = only report them).
+type PolicyConfig struct {
+	Enabled         bool     `yaml:"enabled"`
+	MaxRepairRounds int      `yaml:"max_repair_rounds"`
+	DisabledRules   []string `yaml:"disabled_rules"` // e.g. non_root, probes
+}
+
+type ModelPrice struct {
+	Input  float64 `yaml:"input"`  // USD per 1M prompt tokens
+	Output float64 `yaml:"output"` // USD per 1M completion tokens
+}
+
+// BudgetConfig caps AI spending. Once the month's recorded cost reaches MonthlyUSD,
+// manifests are generated from fallback templates until the next month (0 = no cap).
+type BudgetConfig struct {
+	MonthlyUSD float64 `yaml:"monthly_usd"`
+}
+
+// ProviderConfig overrides how the AI service reaches one LLM provider. Empty fields
+// keep the provider's defaults.
+type ProviderConfig struct {
+	BaseURL    string            `yaml:"base_url"`
+	Headers    map[string]string `yaml:"headers"`
+	Timeout    time.Duration     `yaml:"timeout"`
+	AuthScheme string            `yaml:"auth_scheme"` // bearer, api-key, x-api-key, query or none
+
+	// Azure OpenAI: the deployment (defaults to the model) and API version
+	Deployment string `yaml:"deployment"`
+	APIVersion string `yaml:"api_version"`
 }
 
 type FewShotConfig struct {
@@ -53,7 +104,17 @@ type CacheConfig struct {
 }
 
 type DockerConfig struct {
-	Local DockerLocalConfig `yaml:"local"`
+	Local    DockerLocalConfig    `yaml:"local"`
+	Sampling DockerSamplingConfig `yaml:"sampling"`
+}
+
+// DockerSamplingConfig controls the sampling of running containers' CPU and memory
+// usage, from which deploy requests and limits are derived.
+type DockerSamplingConfig struct {
+	Enabled  bool    `yaml:"enabled"`
+	Interval int     `yaml:"interval"` // seconds between samples
+	Window   int     `yaml:"window"`   // seconds of samples kept per container
+	Headroom float64 `yaml:"headroom"` // share added to p95 usage for requests, e.g. 0.2
 }
 
 type DockerLocalConfig struct {
@@ -66,6 +127,8 @@ type ClusterConfig struct {
 	Kubeconfig string `yaml:"kubeconfig"`
 	Context    string `yaml:"context"`
 	Registry   string `yaml:"registry"`
+	Delivery   string              `yaml:"delivery"` // "direct" (default) or "git"
+	Git        *models.GitDelivery `yaml:"git"`      // required when delivery is "git"
 }
 
 type RegistryConfig struct {
@@ -128,6 +191,30 @@ type LimitsConfig struct {
 	MaxLogLines          int `yaml:"max_log_lines"`
 }
 
+// DriftConfig controls the periodic comparison of stored manifests with live cluster state.
+type DriftConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	Interval int  `yaml:"interval"` // seconds between checks
+}
+
+// FeedbackConfig controls the post-deploy feedback job, which samples the usage and
+// health of each new deploy during an observation window and writes the result to
+// its deployment history entry.
+type FeedbackConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	Window   int  `yaml:"window"`   // seconds a deploy is observed after it was deployed
+	Interval int  `yaml:"interval"` // seconds between samples
+}
+
+// EnvironmentConfig is one stage of the promotion pipeline. Promotion moves a deploy
+// to the next environment in the list, with Patch applied to the stored manifests.
+type EnvironmentConfig struct {
+	Name      string                   `yaml:"name"`
+	Cluster   string                   `yaml:"cluster"`
+	Namespace string                   `yaml:"namespace"`
+	Patch     models.ManifestOverrides `yaml:"patch"`
+}
+
 // Load reads and parses the YAML configuration file at the given path.
 // If path is empty, it falls back to the CONFIG_PATH environment variable.
 func Load(path string) (*Config, error) {
@@ -203,6 +290,29 @@ func applyDefaults(cfg *Config) {
 	if cfg.Limits.MaxLogLines == 0 {
 		cfg.Limits.MaxLogLines = 10000
 	}
+	if cfg.Drift.Interval == 0 {
+		cfg.Drift.Interval = 300
+	}
+	if cfg.Docker.Sampling.Interval == 0 {
+		cfg.Docker.Sampling.Interval = 30
+	}
+	if cfg.Docker.Sampling.Window == 0 {
+		cfg.Docker.Sampling.Window = 3600
+	}
+	if cfg.Docker.Sampling.Headroom == 0 {
+		cfg.Docker.Sampling.Headroom = 0.2
+	}
+	if cfg.Feedback.Window == 0 {
+		cfg.Feedback.Window = 3600
+	}
+	if cfg.Feedback.Interval == 0 {
+		cfg.Feedback.Interval = 60
+	}
+	for i := range cfg.Clusters {
+		if cfg.Clusters[i].Delivery == "" {
+			cfg.Clusters[i].Delivery = models.DeliveryDirect
+		}
+	}
 }
 
 func applyEnvOverrides(cfg *Config) {
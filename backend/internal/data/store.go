@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -29,6 +30,9 @@ type Store interface {
 	// Deployment lifecycle
 	GetDeployment(ctx context.Context, id string) (*models.DeploymentHistory, error)
 	UpdateDeploymentStatus(ctx context.Context, id string, status string, deletedAt *time.Time) error
+	UpdateDeploymentManifest(ctx context.Context, id string, manifestJSON string) error
+	UpdateDriftStatus(ctx context.Context, id string, driftStatus string, checkedAt time.Time) error
+	UpdateDeploymentUsage(ctx context.Context, deployment *models.DeploymentHistory) error
 	DeleteDeploymentRecord(ctx context.Context, id string) error
 
 	// Settings persistence (key-value)
@@ -45,13 +49,41 @@ type Store interface {
 	// Unified deploy history (paginated)
 	ListUnifiedHistory(ctx context.Context, offset, limit int) ([]models.UnifiedDeployItem, int, error)
 
+	// Single deploy persistence
+	SaveSingleDeploy(ctx context.Context, record *models.DeployRecord) error
+	GetSingleDeploy(ctx context.Context, deployID string) (*models.DeployRecord, error)
+	ListSingleDeploys(ctx context.Context, limit int) ([]models.DeployRecord, error)
+
 	// Stack deploy persistence
 	SaveStackDeploy(ctx context.Context, record *models.StackDeployRecord) error
 	GetStackDeploy(ctx context.Context, deployID string) (*models.StackDeployRecord, error)
 	UpdateStackDeploy(ctx context.Context, record *models.StackDeployRecord) error
 	ListStackDeploys(ctx context.Context, limit int) ([]models.StackDeployRecord, error)
+	UpdateStackDriftStatus(ctx context.Context, deployID string, driftStatus string, checkedAt time.Time) error
 	DeleteStackDeploy(ctx context.Context, deployID string) error
 
+	// Environment promotions
+	SavePromotion(ctx context.Context, p *models.Promotion) error
+	GetPromotion(ctx context.Context, id string) (*models.Promotion, error)
+	ListPromotions(ctx context.Context, filter models.PromotionFilter) ([]models.Promotion, error)
+
+	// Manifest revisions
+	SaveManifestRevision(ctx context.Context, rev *models.ManifestRevision) error
+	GetManifestRevision(ctx context.Context, deployID string, version int) (*models.ManifestRevision, error)
+	ListManifestRevisions(ctx context.Context, deployID string) ([]models.ManifestRevision, error)
+
+	// AI response cache
+	SaveAICacheEntry(ctx context.Context, entry *models.AICacheEntry) error
+	GetAICacheEntry(ctx context.Context, key string) (*models.AICacheEntry, error)
+	CountAICacheEntries(ctx context.Context) (int, error)
+	PurgeAICache(ctx context.Context) (int64, error)
+
+	// AI usage accounting
+	SaveAIUsage(ctx context.Context, rec *models.AIUsageRecord) error
+	SumAIUsageCost(ctx context.Context, since time.Time) (float64, error)
+	SummarizeAIUsage(ctx context.Context, filter models.AIUsageFilter, groupBy string) ([]models.AIUsageSummary, error)
+	SummarizeManifestQuality(ctx context.Context, filter models.AIQualityFilter) ([]models.AIQualitySummary, error)
+
 	// Cleanup
 	CleanupOldRecords(ctx context.Context, retentionDays int) (int64, error)
 }
@@ -177,6 +209,92 @@ func (s *sqliteStore) migrate(db *sql.DB) error {
 	);
 	CREATE INDEX IF NOT EXISTS idx_stack_deploys_status ON stack_deploys(status);
 	CREATE INDEX IF NOT EXISTS idx_stack_deploys_created_at ON stack_deploys(created_at);
+
+	CREATE TABLE IF NOT EXISTS single_deploys (
+		deploy_id      TEXT PRIMARY KEY,
+		container_id   TEXT NOT NULL DEFAULT '',
+		cluster_name   TEXT NOT NULL DEFAULT '',
+		namespace      TEXT NOT NULL DEFAULT 'default',
+		status         TEXT NOT NULL DEFAULT 'pending',
+		started_at     DATETIME,
+		completed_at   DATETIME,
+		request_json   TEXT NOT NULL DEFAULT '',
+		response_json  TEXT NOT NULL DEFAULT '',
+		manifests_json TEXT NOT NULL DEFAULT '',
+		status_json    TEXT NOT NULL DEFAULT '',
+		parent_id      TEXT NOT NULL DEFAULT '',
+		created_at     DATETIME NOT NULL,
+		updated_at     DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_single_deploys_status ON single_deploys(status);
+	CREATE INDEX IF NOT EXISTS idx_single_deploys_created_at ON single_deploys(created_at);
+
+	CREATE TABLE IF NOT EXISTS promotions (
+		id               TEXT PRIMARY KEY,
+		kind             TEXT NOT NULL,
+		source_id        TEXT NOT NULL,
+		name             TEXT NOT NULL DEFAULT '',
+		from_env         TEXT NOT NULL DEFAULT '',
+		to_env           TEXT NOT NULL,
+		cluster_name     TEXT NOT NULL DEFAULT '',
+		namespace        TEXT NOT NULL DEFAULT '',
+		artifact_version TEXT NOT NULL DEFAULT '',
+		images_json      TEXT NOT NULL DEFAULT '[]',
+		status           TEXT NOT NULL,
+		deploy_id        TEXT NOT NULL DEFAULT '',
+		requested_by     TEXT NOT NULL DEFAULT '',
+		request_comment  TEXT NOT NULL DEFAULT '',
+		decided_by       TEXT NOT NULL DEFAULT '',
+		decision_comment TEXT NOT NULL DEFAULT '',
+		error            TEXT NOT NULL DEFAULT '',
+		requested_at     DATETIME NOT NULL,
+		decided_at       DATETIME,
+		completed_at     DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_promotions_source_id ON promotions(source_id);
+	CREATE INDEX IF NOT EXISTS idx_promotions_deploy_id ON promotions(deploy_id);
+
+	CREATE TABLE IF NOT EXISTS manifest_revisions (
+		deploy_id     TEXT NOT NULL,
+		version       INTEGER NOT NULL,
+		type          TEXT NOT NULL,
+		source        TEXT NOT NULL,
+		feedback      TEXT NOT NULL DEFAULT '',
+		reverted_from INTEGER NOT NULL DEFAULT 0,
+		manifest_json TEXT NOT NULL,
+		created_at    DATETIME NOT NULL,
+		PRIMARY KEY (deploy_id, version)
+	);
+
+	CREATE TABLE IF NOT EXISTS ai_cache (
+		cache_key   TEXT PRIMARY KEY,
+		kind        TEXT NOT NULL,
+		provider    TEXT NOT NULL,
+		model       TEXT NOT NULL DEFAULT '',
+		result_json TEXT NOT NULL,
+		created_at  DATETIME NOT NULL,
+		expires_at  DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_ai_cache_expires_at ON ai_cache(expires_at);
+
+	CREATE TABLE IF NOT EXISTS ai_usage (
+		id                INTEGER PRIMARY KEY AUTOINCREMENT,
+		operation         TEXT NOT NULL,
+		provider          TEXT NOT NULL,
+		model             TEXT NOT NULL DEFAULT '',
+		prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+		completion_tokens INTEGER NOT NULL DEFAULT 0,
+		cost_usd          REAL NOT NULL DEFAULT 0,
+		latency_ms        INTEGER NOT NULL DEFAULT 0,
+		retries           INTEGER NOT NULL DEFAULT 0,
+		outcome           TEXT NOT NULL,
+		error             TEXT NOT NULL DEFAULT '',
+		deploy_id         TEXT NOT NULL DEFAULT '',
+		user              TEXT NOT NULL DEFAULT '',
+		created_at        DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_ai_usage_created_at ON ai_usage(created_at);
+	CREATE INDEX IF NOT EXISTS idx_ai_usage_deploy_id ON ai_usage(deploy_id);
 	`
 	if _, err := db.Exec(query); err != nil {
 		return err
@@ -187,6 +305,12 @@ func (s *sqliteStore) migrate(db *sql.DB) error {
 		`ALTER TABLE deployment_history ADD COLUMN status TEXT NOT NULL DEFAULT 'deployed'`,
 		`ALTER TABLE deployment_history ADD COLUMN manifest_json TEXT NOT NULL DEFAULT ''`,
 		`ALTER TABLE deployment_history ADD COLUMN deleted_at DATETIME`,
+		`ALTER TABLE deployment_history ADD COLUMN drift_status TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE deployment_history ADD COLUMN drift_checked_at DATETIME`,
+		`ALTER TABLE deployment_history ADD COLUMN commit_sha TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE deployment_history ADD COLUMN parent_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE deployment_history ADD COLUMN fanout_json TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE deployment_history ADD COLUMN usage_json TEXT NOT NULL DEFAULT ''`,
 	}
 	for _, stmt := range alterStmts {
 		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
@@ -203,6 +327,11 @@ func (s *sqliteStore) migrate(db *sql.DB) error {
 	stackAlterStmts := []string{
 		`ALTER TABLE stack_deploys ADD COLUMN create_namespace INTEGER NOT NULL DEFAULT 0`,
 		`ALTER TABLE stack_deploys ADD COLUMN prompt TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE stack_deploys ADD COLUMN drift_status TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE stack_deploys ADD COLUMN drift_checked_at DATETIME`,
+		`ALTER TABLE stack_deploys ADD COLUMN commit_sha TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE stack_deploys ADD COLUMN parent_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE stack_deploys ADD COLUMN fanout_json TEXT NOT NULL DEFAULT ''`,
 	}
 	for _, stmt := range stackAlterStmts {
 		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
@@ -210,6 +339,33 @@ func (s *sqliteStore) migrate(db *sql.DB) error {
 		}
 	}
 
+	// Add delivery mode columns to registered_clusters (idempotent)
+	clusterAlterStmts := []string{
+		`ALTER TABLE registered_clusters ADD COLUMN delivery TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE registered_clusters ADD COLUMN git_json TEXT NOT NULL DEFAULT ''`,
+	}
+	for _, stmt := range clusterAlterStmts {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			// ignore duplicate column errors for idempotency
+		}
+	}
+
+	// Add quality and model columns to manifest_revisions (idempotent)
+	revisionAlterStmts := []string{
+		`ALTER TABLE manifest_revisions ADD COLUMN quality_score INTEGER`,
+		`ALTER TABLE manifest_revisions ADD COLUMN quality_version INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE manifest_revisions ADD COLUMN quality_json TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE manifest_revisions ADD COLUMN provider TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE manifest_revisions ADD COLUMN model TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE manifest_revisions ADD COLUMN prompt_version INTEGER NOT NULL DEFAULT 0`,
+	}
+	for _, stmt := range revisionAlterStmts {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			// ignore duplicate column errors for idempotency
+		}
+	}
+	db.Exec(`CREATE INDEX IF NOT EXISTS idx_manifest_revisions_created_at ON manifest_revisions(created_at)`)
+
 	// Normalize timestamps to RFC3339 for consistent cross-table sorting.
 	// Go's time.Time default format includes timezone name and monotonic clock
 	// (e.g. "2026-03-04 17:12:33 +0900 KST m=+249") which breaks text-based ORDER BY.
@@ -309,8 +465,9 @@ func (s *sqliteStore) SaveDeployment(ctx context.Context, deployment *models.Dep
 		replicas, actual_cpu, actual_memory,
 		target_cluster, namespace, deployed_at, success,
 		status, manifest_json, deleted_at,
-		oom_events, throttle_events, ai_generated, ai_confidence
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		oom_events, throttle_events, ai_generated, ai_confidence,
+		commit_sha, parent_id, fanout_json, usage_json
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	// Normalize timestamp to RFC3339 UTC for consistent sorting
@@ -324,6 +481,7 @@ func (s *sqliteStore) SaveDeployment(ctx context.Context, deployment *models.Dep
 		deployment.TargetCluster, deployment.Namespace, deployedAtStr, deployment.Success,
 		deployment.Status, deployment.ManifestJSON, deployment.DeletedAt,
 		deployment.OOMEvents, deployment.ThrottleEvents, deployment.AIGenerated, deployment.AIConfidence,
+		deployment.CommitSHA, deployment.ParentID, encodeFanOut(deployment.FanOut), encodeUsage(deployment.Usage),
 	)
 	return err
 }
@@ -341,7 +499,8 @@ func (s *sqliteStore) GetDeployHistory(ctx context.Context, limit int) ([]models
 		replicas, actual_cpu, actual_memory,
 		target_cluster, namespace, deployed_at, success,
 		status, manifest_json, deleted_at,
-		oom_events, throttle_events, ai_generated, ai_confidence
+		oom_events, throttle_events, ai_generated, ai_confidence,
+		drift_status, drift_checked_at, commit_sha, parent_id, fanout_json, usage_json
 		FROM deployment_history ORDER BY deployed_at DESC LIMIT ?`
 
 	rows, err := s.db.QueryContext(ctx, query, limit)
@@ -366,7 +525,8 @@ func (s *sqliteStore) FindSimilar(ctx context.Context, imageName, serviceType st
 		replicas, actual_cpu, actual_memory,
 		target_cluster, namespace, deployed_at, success,
 		status, manifest_json, deleted_at,
-		oom_events, throttle_events, ai_generated, ai_confidence
+		oom_events, throttle_events, ai_generated, ai_confidence,
+		drift_status, drift_checked_at, commit_sha, parent_id, fanout_json, usage_json
 		FROM deployment_history
 		WHERE success = 1
 		  AND (image_name LIKE ? OR service_type = ?)
@@ -442,12 +602,22 @@ func (s *sqliteStore) SaveRegisteredCluster(ctx context.Context, cluster *models
 	if s.db == nil {
 		return fmt.Errorf("database not initialized")
 	}
-	query := `INSERT INTO registered_clusters (name, type, kubeconfig, context, registry, created_at)
-		VALUES (?, ?, ?, ?, ?, ?)
+	gitJSON := ""
+	if cluster.Git != nil {
+		b, err := json.Marshal(cluster.Git)
+		if err != nil {
+			return fmt.Errorf("encoding git delivery: %w", err)
+		}
+		gitJSON = string(b)
+	}
+	query := `INSERT INTO registered_clusters (name, type, kubeconfig, context, registry, delivery, git_json, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(name) DO UPDATE SET type = excluded.type, kubeconfig = excluded.kubeconfig,
-		context = excluded.context, registry = excluded.registry`
+		context = excluded.context, registry = excluded.registry,
+		delivery = excluded.delivery, git_json = excluded.git_json`
 	_, err := s.db.ExecContext(ctx, query,
-		cluster.Name, cluster.Type, cluster.Kubeconfig, cluster.Context, cluster.Registry, time.Now().UTC())
+		cluster.Name, cluster.Type, cluster.Kubeconfig, cluster.Context, cluster.Registry,
+		cluster.Delivery, gitJSON, time.Now().UTC())
 	return err
 }
 
@@ -463,7 +633,7 @@ func (s *sqliteStore) GetRegisteredClusters(ctx context.Context) ([]models.Regis
 	if s.db == nil {
 		return nil, fmt.Errorf("database not initialized")
 	}
-	rows, err := s.db.QueryContext(ctx, "SELECT name, type, kubeconfig, context, registry, created_at FROM registered_clusters ORDER BY created_at")
+	rows, err := s.db.QueryContext(ctx, "SELECT name, type, kubeconfig, context, registry, delivery, git_json, created_at FROM registered_clusters ORDER BY created_at")
 	if err != nil {
 		return nil, err
 	}
@@ -472,10 +642,16 @@ func (s *sqliteStore) GetRegisteredClusters(ctx context.Context) ([]models.Regis
 	var result []models.RegisteredCluster
 	for rows.Next() {
 		var c models.RegisteredCluster
-		var createdAt string
-		if err := rows.Scan(&c.Name, &c.Type, &c.Kubeconfig, &c.Context, &c.Registry, &createdAt); err != nil {
+		var gitJSON, createdAt string
+		if err := rows.Scan(&c.Name, &c.Type, &c.Kubeconfig, &c.Context, &c.Registry, &c.Delivery, &gitJSON, &createdAt); err != nil {
 			return nil, err
 		}
+		if gitJSON != "" {
+			var git models.GitDelivery
+			if err := json.Unmarshal([]byte(gitJSON), &git); err == nil {
+				c.Git = &git
+			}
+		}
 		c.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 		result = append(result, c)
 	}
@@ -497,12 +673,14 @@ func (s *sqliteStore) GetDeployment(ctx context.Context, id string) (*models.Dep
 		replicas, actual_cpu, actual_memory,
 		target_cluster, namespace, deployed_at, success,
 		status, manifest_json, deleted_at,
-		oom_events, throttle_events, ai_generated, ai_confidence
+		oom_events, throttle_events, ai_generated, ai_confidence,
+		drift_status, drift_checked_at, commit_sha, parent_id, fanout_json, usage_json
 		FROM deployment_history WHERE id = ?`
 
 	var d models.DeploymentHistory
 	var deployedAt string
-	var deletedAt sql.NullString
+	var deletedAt, driftCheckedAt sql.NullString
+	var fanOutJSON, usageJSON string
 	err := s.db.QueryRowContext(ctx, query, id).Scan(
 		&d.ID, &d.ServiceName, &d.ImageName, &d.ImageTag, &d.ServiceType, &d.Language,
 		&d.CPURequest, &d.CPULimit, &d.MemoryRequest, &d.MemoryLimit,
@@ -510,6 +688,7 @@ func (s *sqliteStore) GetDeployment(ctx context.Context, id string) (*models.Dep
 		&d.TargetCluster, &d.Namespace, &deployedAt, &d.Success,
 		&d.Status, &d.ManifestJSON, &deletedAt,
 		&d.OOMEvents, &d.ThrottleEvents, &d.AIGenerated, &d.AIConfidence,
+		&d.DriftStatus, &driftCheckedAt, &d.CommitSHA, &d.ParentID, &fanOutJSON, &usageJSON,
 	)
 	if err != nil {
 		return nil, err
@@ -519,6 +698,12 @@ func (s *sqliteStore) GetDeployment(ctx context.Context, id string) (*models.Dep
 		t, _ := time.Parse(time.RFC3339, deletedAt.String)
 		d.DeletedAt = &t
 	}
+	if driftCheckedAt.Valid {
+		t, _ := time.Parse(time.RFC3339, driftCheckedAt.String)
+		d.DriftCheckedAt = &t
+	}
+	d.FanOut = decodeFanOut(fanOutJSON)
+	d.Usage = decodeUsage(usageJSON)
 	return &d, nil
 }
 
@@ -532,14 +717,138 @@ func (s *sqliteStore) UpdateDeploymentStatus(ctx context.Context, id string, sta
 	return err
 }
 
+func (s *sqliteStore) UpdateDeploymentManifest(ctx context.Context, id string, manifestJSON string) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE deployment_history SET manifest_json = ? WHERE id = ?`,
+		manifestJSON, id)
+	return err
+}
+
+func (s *sqliteStore) UpdateDriftStatus(ctx context.Context, id string, driftStatus string, checkedAt time.Time) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE deployment_history SET drift_status = ?, drift_checked_at = ? WHERE id = ?`,
+		driftStatus, checkedAt.UTC().Format(time.RFC3339Nano), id)
+	return err
+}
+
+// UpdateDeploymentUsage writes the measured usage and health events of a deploy:
+// ActualCPU, ActualMemory, OOMEvents, ThrottleEvents and Usage.
+func (s *sqliteStore) UpdateDeploymentUsage(ctx context.Context, deployment *models.DeploymentHistory) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE deployment_history SET actual_cpu = ?, actual_memory = ?, oom_events = ?, throttle_events = ?, usage_json = ?
+		WHERE id = ?`,
+		deployment.ActualCPU, deployment.ActualMemory, deployment.OOMEvents, deployment.ThrottleEvents,
+		encodeUsage(deployment.Usage), deployment.ID)
+	return err
+}
+
 func (s *sqliteStore) DeleteDeploymentRecord(ctx context.Context, id string) error {
 	if s.db == nil {
 		return fmt.Errorf("database not initialized")
 	}
 	_, err := s.db.ExecContext(ctx, `DELETE FROM deployment_history WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM single_deploys WHERE deploy_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM manifest_revisions WHERE deploy_id = ?`, id)
+	return err
+}
+
+// --- Single deploy persistence ---
+
+const singleDeployColumns = `deploy_id, container_id, cluster_name, namespace, status, started_at, completed_at,
+		request_json, response_json, manifests_json, status_json, parent_id, created_at, updated_at`
+
+// SaveSingleDeploy inserts a deploy or updates the existing one with the same ID.
+func (s *sqliteStore) SaveSingleDeploy(ctx context.Context, record *models.DeployRecord) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	nowStr := time.Now().UTC().Format(time.RFC3339Nano)
+	query := `INSERT INTO single_deploys (` + singleDeployColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(deploy_id) DO UPDATE SET status = excluded.status, completed_at = excluded.completed_at,
+		request_json = excluded.request_json, response_json = excluded.response_json,
+		manifests_json = excluded.manifests_json, status_json = excluded.status_json,
+		updated_at = excluded.updated_at`
+	_, err := s.db.ExecContext(ctx, query,
+		record.DeployID, record.ContainerID, record.ClusterName, record.Namespace, record.Status,
+		formatNullTime(record.StartedAt), formatNullTime(record.CompletedAt),
+		record.RequestJSON, record.ResponseJSON, record.ManifestsJSON, record.StatusJSON, record.ParentID,
+		nowStr, nowStr,
+	)
 	return err
 }
 
+func (s *sqliteStore) GetSingleDeploy(ctx context.Context, deployID string) (*models.DeployRecord, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+singleDeployColumns+` FROM single_deploys WHERE deploy_id = ?`, deployID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result, err := scanSingleDeploys(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &result[0], nil
+}
+
+// ListSingleDeploys returns the most recent single deploys, newest first.
+func (s *sqliteStore) ListSingleDeploys(ctx context.Context, limit int) ([]models.DeployRecord, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+singleDeployColumns+` FROM single_deploys ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSingleDeploys(rows)
+}
+
+func scanSingleDeploys(rows *sql.Rows) ([]models.DeployRecord, error) {
+	result := []models.DeployRecord{}
+	for rows.Next() {
+		var r models.DeployRecord
+		var startedAt, completedAt sql.NullString
+		var createdAt, updatedAt string
+		if err := rows.Scan(
+			&r.DeployID, &r.ContainerID, &r.ClusterName, &r.Namespace, &r.Status, &startedAt, &completedAt,
+			&r.RequestJSON, &r.ResponseJSON, &r.ManifestsJSON, &r.StatusJSON, &r.ParentID, &createdAt, &updatedAt,
+		); err != nil {
+			return nil, err
+		}
+		r.StartedAt = parseNullTime(startedAt)
+		r.CompletedAt = parseNullTime(completedAt)
+		r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		r.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
 // --- Stack deploy persistence ---
 
 func (s *sqliteStore) SaveStackDeploy(ctx context.Context, record *models.StackDeployRecord) error {
@@ -554,8 +863,9 @@ func (s *sqliteStore) SaveStackDeploy(ctx context.Context, record *models.StackD
 		create_namespace, prompt,
 		status, started_at, completed_at,
 		topology_json, manifests_json, reasoning, confidence,
-		deploy_order, services_json, created_at, updated_at
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		deploy_order, services_json, created_at, updated_at, commit_sha,
+		parent_id, fanout_json
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	nowStr := time.Now().UTC().Format(time.RFC3339Nano)
 	_, err := s.db.ExecContext(ctx, query,
@@ -563,7 +873,8 @@ func (s *sqliteStore) SaveStackDeploy(ctx context.Context, record *models.StackD
 		record.CreateNamespace, record.Prompt,
 		record.Status, record.StartedAt, record.CompletedAt,
 		record.TopologyJSON, record.ManifestsJSON, record.Reasoning, record.Confidence,
-		string(deployOrderJSON), record.ServicesJSON, nowStr, nowStr,
+		string(deployOrderJSON), record.ServicesJSON, nowStr, nowStr, record.CommitSHA,
+		record.ParentID, encodeFanOut(record.FanOut),
 	)
 	return err
 }
@@ -576,12 +887,14 @@ func (s *sqliteStore) GetStackDeploy(ctx context.Context, deployID string) (*mod
 		create_namespace, prompt,
 		status, started_at, completed_at,
 		topology_json, manifests_json, reasoning, confidence,
-		deploy_order, services_json, created_at, updated_at
+		deploy_order, services_json, created_at, updated_at,
+		drift_status, drift_checked_at, commit_sha, parent_id, fanout_json
 		FROM stack_deploys WHERE deploy_id = ?`
 
 	var r models.StackDeployRecord
 	var containerIDsJSON, deployOrderJSON string
-	var startedAt, completedAt, createdAt, updatedAt sql.NullString
+	var startedAt, completedAt, createdAt, updatedAt, driftCheckedAt sql.NullString
+	var fanOutJSON string
 
 	err := s.db.QueryRowContext(ctx, query, deployID).Scan(
 		&r.DeployID, &r.StackName, &r.ClusterName, &r.Namespace, &containerIDsJSON,
@@ -589,6 +902,7 @@ func (s *sqliteStore) GetStackDeploy(ctx context.Context, deployID string) (*mod
 		&r.Status, &startedAt, &completedAt,
 		&r.TopologyJSON, &r.ManifestsJSON, &r.Reasoning, &r.Confidence,
 		&deployOrderJSON, &r.ServicesJSON, &createdAt, &updatedAt,
+		&r.DriftStatus, &driftCheckedAt, &r.CommitSHA, &r.ParentID, &fanOutJSON,
 	)
 	if err != nil {
 		return nil, err
@@ -610,12 +924,17 @@ func (s *sqliteStore) GetStackDeploy(ctx context.Context, deployID string) (*mod
 	if updatedAt.Valid {
 		r.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt.String)
 	}
+	if driftCheckedAt.Valid {
+		t, _ := time.Parse(time.RFC3339, driftCheckedAt.String)
+		r.DriftCheckedAt = &t
+	}
 	if r.ContainerIDs == nil {
 		r.ContainerIDs = []string{}
 	}
 	if r.DeployOrder == nil {
 		r.DeployOrder = []string{}
 	}
+	r.FanOut = decodeFanOut(fanOutJSON)
 	return &r, nil
 }
 
@@ -628,13 +947,14 @@ func (s *sqliteStore) UpdateStackDeploy(ctx context.Context, record *models.Stac
 	query := `UPDATE stack_deploys SET
 		status = ?, completed_at = ?,
 		topology_json = ?, manifests_json = ?, reasoning = ?, confidence = ?,
-		deploy_order = ?, services_json = ?, updated_at = ?
+		deploy_order = ?, services_json = ?, updated_at = ?, commit_sha = ?, fanout_json = ?
 		WHERE deploy_id = ?`
 
 	_, err := s.db.ExecContext(ctx, query,
 		record.Status, record.CompletedAt,
 		record.TopologyJSON, record.ManifestsJSON, record.Reasoning, record.Confidence,
-		string(deployOrderJSON), record.ServicesJSON, time.Now().UTC().Format(time.RFC3339Nano),
+		string(deployOrderJSON), record.ServicesJSON, time.Now().UTC().Format(time.RFC3339Nano), record.CommitSHA,
+		encodeFanOut(record.FanOut),
 		record.DeployID,
 	)
 	return err
@@ -652,7 +972,8 @@ func (s *sqliteStore) ListStackDeploys(ctx context.Context, limit int) ([]models
 		create_namespace, prompt,
 		status, started_at, completed_at,
 		topology_json, manifests_json, reasoning, confidence,
-		deploy_order, services_json, created_at, updated_at
+		deploy_order, services_json, created_at, updated_at,
+		drift_status, drift_checked_at, commit_sha, parent_id, fanout_json
 		FROM stack_deploys ORDER BY created_at DESC LIMIT ?`
 
 	rows, err := s.db.QueryContext(ctx, query, limit)
@@ -665,7 +986,8 @@ func (s *sqliteStore) ListStackDeploys(ctx context.Context, limit int) ([]models
 	for rows.Next() {
 		var r models.StackDeployRecord
 		var containerIDsJSON, deployOrderJSON string
-		var startedAt, completedAt, createdAt, updatedAt sql.NullString
+		var startedAt, completedAt, createdAt, updatedAt, driftCheckedAt sql.NullString
+		var fanOutJSON string
 
 		if err := rows.Scan(
 			&r.DeployID, &r.StackName, &r.ClusterName, &r.Namespace, &containerIDsJSON,
@@ -673,6 +995,7 @@ func (s *sqliteStore) ListStackDeploys(ctx context.Context, limit int) ([]models
 			&r.Status, &startedAt, &completedAt,
 			&r.TopologyJSON, &r.ManifestsJSON, &r.Reasoning, &r.Confidence,
 			&deployOrderJSON, &r.ServicesJSON, &createdAt, &updatedAt,
+			&r.DriftStatus, &driftCheckedAt, &r.CommitSHA, &r.ParentID, &fanOutJSON,
 		); err != nil {
 			return nil, err
 		}
@@ -693,12 +1016,17 @@ func (s *sqliteStore) ListStackDeploys(ctx context.Context, limit int) ([]models
 		if updatedAt.Valid {
 			r.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt.String)
 		}
+		if driftCheckedAt.Valid {
+			t, _ := time.Parse(time.RFC3339, driftCheckedAt.String)
+			r.DriftCheckedAt = &t
+		}
 		if r.ContainerIDs == nil {
 			r.ContainerIDs = []string{}
 		}
 		if r.DeployOrder == nil {
 			r.DeployOrder = []string{}
 		}
+		r.FanOut = decodeFanOut(fanOutJSON)
 		results = append(results, r)
 	}
 	if results == nil {
@@ -707,11 +1035,25 @@ func (s *sqliteStore) ListStackDeploys(ctx context.Context, limit int) ([]models
 	return results, rows.Err()
 }
 
+func (s *sqliteStore) UpdateStackDriftStatus(ctx context.Context, deployID string, driftStatus string, checkedAt time.Time) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE stack_deploys SET drift_status = ?, drift_checked_at = ? WHERE deploy_id = ?`,
+		driftStatus, checkedAt.UTC().Format(time.RFC3339Nano), deployID)
+	return err
+}
+
 func (s *sqliteStore) DeleteStackDeploy(ctx context.Context, deployID string) error {
 	if s.db == nil {
 		return fmt.Errorf("database not initialized")
 	}
 	_, err := s.db.ExecContext(ctx, `DELETE FROM stack_deploys WHERE deploy_id = ?`, deployID)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM manifest_revisions WHERE deploy_id = ?`, deployID)
 	return err
 }
 
@@ -734,11 +1076,13 @@ func (s *sqliteStore) ListUnifiedHistory(ctx context.Context, offset, limit int)
 		SELECT 1 FROM stack_deploys sd
 		WHERE deployment_history.id LIKE sd.deploy_id || '_%'
 	)`
+	// Per-cluster deploys of a multi-cluster deploy are listed through their parent
+	stackFilter += ` AND parent_id = ''`
 
 	// Total count
 	var total int
 	countQuery := fmt.Sprintf(`SELECT
-		(SELECT COUNT(*) FROM stack_deploys) +
+		(SELECT COUNT(*) FROM stack_deploys WHERE parent_id = '') +
 		(SELECT COUNT(*) FROM deployment_history WHERE %s) AS total`, stackFilter)
 	if err := s.db.QueryRowContext(ctx, countQuery).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("counting unified history: %w", err)
@@ -746,10 +1090,10 @@ func (s *sqliteStore) ListUnifiedHistory(ctx context.Context, offset, limit int)
 
 	// Paginated union query
 	query := fmt.Sprintf(`
-	SELECT id, type, name, cluster, namespace, status,
+	SELECT id, type, name, cluster, namespace, status, drift_status,
 	       ai_generated, confidence, deployed_at,
 	       deploy_order_json,
-	       image_name, image_tag, replicas
+	       image_name, image_tag, replicas, fanout_json
 	FROM (
 		SELECT
 			deploy_id AS id,
@@ -758,14 +1102,17 @@ func (s *sqliteStore) ListUnifiedHistory(ctx context.Context, offset, limit int)
 			cluster_name AS cluster,
 			namespace,
 			status,
+			drift_status,
 			1 AS ai_generated,
 			confidence,
 			created_at AS deployed_at,
 			deploy_order AS deploy_order_json,
 			'' AS image_name,
 			'' AS image_tag,
-			0 AS replicas
+			0 AS replicas,
+			fanout_json
 		FROM stack_deploys
+		WHERE parent_id = ''
 		UNION ALL
 		SELECT
 			id,
@@ -774,13 +1121,15 @@ func (s *sqliteStore) ListUnifiedHistory(ctx context.Context, offset, limit int)
 			target_cluster AS cluster,
 			namespace,
 			status,
+			drift_status,
 			ai_generated,
 			ai_confidence AS confidence,
 			deployed_at,
 			'' AS deploy_order_json,
 			image_name,
 			image_tag,
-			replicas
+			replicas,
+			fanout_json
 		FROM deployment_history
 		WHERE %s
 	)
@@ -801,18 +1150,26 @@ func (s *sqliteStore) ListUnifiedHistory(ctx context.Context, offset, limit int)
 		var imageName, imageTag string
 		var replicas int
 		var aiGenerated bool
+		var fanOutJSON string
 
 		if err := rows.Scan(
-			&item.ID, &item.Type, &item.Name, &item.Cluster, &item.Namespace, &item.Status,
+			&item.ID, &item.Type, &item.Name, &item.Cluster, &item.Namespace, &item.Status, &item.DriftStatus,
 			&aiGenerated, &item.Confidence, &deployedAt,
 			&deployOrderJSON,
-			&imageName, &imageTag, &replicas,
+			&imageName, &imageTag, &replicas, &fanOutJSON,
 		); err != nil {
 			return nil, 0, fmt.Errorf("scanning unified row: %w", err)
 		}
 
 		item.AIGenerated = aiGenerated
 		item.DeployedAt, _ = time.Parse(time.RFC3339, deployedAt)
+		if item.FanOut = decodeFanOut(fanOutJSON); item.FanOut != nil && item.Cluster == "" {
+			clusters := make([]string, 0, len(item.FanOut.Targets))
+			for _, t := range item.FanOut.Targets {
+				clusters = append(clusters, t.ClusterName)
+			}
+			item.Cluster = strings.Join(clusters, ", ")
+		}
 
 		if item.Type == "stack" {
 			var deployOrder []string
@@ -855,6 +1212,433 @@ func (s *sqliteStore) ListUnifiedHistory(ctx context.Context, offset, limit int)
 
 // CleanupOldRecords deletes deployment history and stack deploy records
 // older than the given retention period.
+// --- Environment promotions ---
+
+const promotionColumns = `id, kind, source_id, name, from_env, to_env, cluster_name, namespace,
+		artifact_version, images_json, status, deploy_id,
+		requested_by, request_comment, decided_by, decision_comment, error,
+		requested_at, decided_at, completed_at`
+
+// SavePromotion inserts a promotion or updates the existing one with the same ID.
+func (s *sqliteStore) SavePromotion(ctx context.Context, p *models.Promotion) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	imagesJSON, _ := json.Marshal(p.Images)
+	query := `INSERT INTO promotions (` + promotionColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET status = excluded.status, deploy_id = excluded.deploy_id,
+		decided_by = excluded.decided_by, decision_comment = excluded.decision_comment,
+		error = excluded.error, decided_at = excluded.decided_at, completed_at = excluded.completed_at`
+	_, err := s.db.ExecContext(ctx, query,
+		p.ID, p.Kind, p.SourceID, p.Name, p.FromEnv, p.ToEnv, p.ClusterName, p.Namespace,
+		p.ArtifactVersion, string(imagesJSON), p.Status, p.DeployID,
+		p.RequestedBy, p.RequestComment, p.DecidedBy, p.DecisionComment, p.Error,
+		p.RequestedAt.UTC().Format(time.RFC3339Nano), formatNullTime(p.DecidedAt), formatNullTime(p.CompletedAt),
+	)
+	return err
+}
+
+func (s *sqliteStore) GetPromotion(ctx context.Context, id string) (*models.Promotion, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+promotionColumns+` FROM promotions WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result, err := scanPromotions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &result[0], nil
+}
+
+// ListPromotions returns the promotions matching the filter, newest first.
+func (s *sqliteStore) ListPromotions(ctx context.Context, filter models.PromotionFilter) ([]models.Promotion, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	var conds []string
+	var args []interface{}
+	for _, f := range []struct{ column, value string }{
+		{"status", filter.Status},
+		{"to_env", filter.ToEnv},
+		{"source_id", filter.SourceID},
+		{"deploy_id", filter.DeployID},
+	} {
+		if f.value != "" {
+			conds = append(conds, f.column+" = ?")
+			args = append(args, f.value)
+		}
+	}
+	query := `SELECT ` + promotionColumns + ` FROM promotions`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY requested_at DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPromotions(rows)
+}
+
+func scanPromotions(rows *sql.Rows) ([]models.Promotion, error) {
+	result := []models.Promotion{}
+	for rows.Next() {
+		var p models.Promotion
+		var imagesJSON, requestedAt string
+		var decidedAt, completedAt sql.NullString
+		if err := rows.Scan(
+			&p.ID, &p.Kind, &p.SourceID, &p.Name, &p.FromEnv, &p.ToEnv, &p.ClusterName, &p.Namespace,
+			&p.ArtifactVersion, &imagesJSON, &p.Status, &p.DeployID,
+			&p.RequestedBy, &p.RequestComment, &p.DecidedBy, &p.DecisionComment, &p.Error,
+			&requestedAt, &decidedAt, &completedAt,
+		); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(imagesJSON), &p.Images)
+		p.RequestedAt, _ = time.Parse(time.RFC3339, requestedAt)
+		p.DecidedAt = parseNullTime(decidedAt)
+		p.CompletedAt = parseNullTime(completedAt)
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// --- Manifest revisions ---
+
+const revisionColumns = `deploy_id, version, type, source, feedback, reverted_from, manifest_json, created_at,
+	quality_json, provider, model, prompt_version`
+
+// SaveManifestRevision stores a revision as the next version of its deploy and sets
+// rev.Version to it. The quality score is also stored on its own for aggregation.
+func (s *sqliteStore) SaveManifestRevision(ctx context.Context, rev *models.ManifestRevision) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if rev.CreatedAt.IsZero() {
+		rev.CreatedAt = time.Now()
+	}
+	var qualityJSON string
+	var qualityScore interface{}
+	var qualityVersion int
+	if rev.Quality != nil {
+		data, err := json.Marshal(rev.Quality)
+		if err != nil {
+			return fmt.Errorf("encoding quality report: %w", err)
+		}
+		qualityJSON, qualityScore, qualityVersion = string(data), rev.Quality.Score, rev.Quality.RulesVersion
+	}
+	query := `INSERT INTO manifest_revisions (` + revisionColumns + `, quality_score, quality_version)
+		SELECT ?, COALESCE(MAX(version), 0) + 1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? FROM manifest_revisions WHERE deploy_id = ?
+		RETURNING version`
+	return s.db.QueryRowContext(ctx, query,
+		rev.DeployID, rev.Type, rev.Source, rev.Feedback, rev.RevertedFrom, rev.ManifestJSON,
+		rev.CreatedAt.UTC().Format(time.RFC3339Nano),
+		qualityJSON, rev.Provider, rev.Model, rev.PromptVersion, qualityScore, qualityVersion,
+		rev.DeployID,
+	).Scan(&rev.Version)
+}
+
+func (s *sqliteStore) GetManifestRevision(ctx context.Context, deployID string, version int) (*models.ManifestRevision, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+revisionColumns+` FROM manifest_revisions WHERE deploy_id = ? AND version = ?`, deployID, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result, err := scanRevisions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &result[0], nil
+}
+
+// ListManifestRevisions returns the revisions of a deploy, oldest first.
+func (s *sqliteStore) ListManifestRevisions(ctx context.Context, deployID string) ([]models.ManifestRevision, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+revisionColumns+` FROM manifest_revisions WHERE deploy_id = ? ORDER BY version`, deployID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRevisions(rows)
+}
+
+func scanRevisions(rows *sql.Rows) ([]models.ManifestRevision, error) {
+	result := []models.ManifestRevision{}
+	for rows.Next() {
+		var rev models.ManifestRevision
+		var createdAt, qualityJSON string
+		if err := rows.Scan(
+			&rev.DeployID, &rev.Version, &rev.Type, &rev.Source, &rev.Feedback, &rev.RevertedFrom, &rev.ManifestJSON, &createdAt,
+			&qualityJSON, &rev.Provider, &rev.Model, &rev.PromptVersion,
+		); err != nil {
+			return nil, err
+		}
+		rev.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		if qualityJSON != "" {
+			var quality models.QualityReport
+			if err := json.Unmarshal([]byte(qualityJSON), &quality); err == nil {
+				rev.Quality = &quality
+			}
+		}
+		result = append(result, rev)
+	}
+	return result, rows.Err()
+}
+
+// SummarizeManifestQuality aggregates the quality scores of scored revisions the AI
+// produced (generate and refine) by provider, model, prompt version and rules version.
+func (s *sqliteStore) SummarizeManifestQuality(ctx context.Context, filter models.AIQualityFilter) ([]models.AIQualitySummary, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	where := []string{"model != ''", "quality_score IS NOT NULL"}
+	var args []interface{}
+	if filter.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, filter.Source)
+	} else {
+		where = append(where, "source IN (?, ?)")
+		args = append(args, models.RevisionGenerate, models.RevisionRefine)
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.From.UTC().Format(time.RFC3339Nano))
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.To.UTC().Format(time.RFC3339Nano))
+	}
+	query := `SELECT provider, model, prompt_version, quality_version, COUNT(*),
+		AVG(quality_score), MIN(quality_score), MAX(quality_score)
+		FROM manifest_revisions WHERE ` + strings.Join(where, " AND ") + `
+		GROUP BY provider, model, prompt_version, quality_version
+		ORDER BY provider, model, prompt_version, quality_version`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.AIQualitySummary{}
+	for rows.Next() {
+		var sum models.AIQualitySummary
+		if err := rows.Scan(&sum.Provider, &sum.Model, &sum.PromptVersion, &sum.RulesVersion, &sum.Revisions,
+			&sum.AvgScore, &sum.MinScore, &sum.MaxScore); err != nil {
+			return nil, err
+		}
+		sum.AvgScore = math.Round(sum.AvgScore*10) / 10
+		result = append(result, sum)
+	}
+	return result, rows.Err()
+}
+
+// --- AI response cache ---
+
+const aiCacheColumns = `cache_key, kind, provider, model, result_json, created_at, expires_at`
+
+// SaveAICacheEntry stores an entry, replacing any entry with the same key.
+func (s *sqliteStore) SaveAICacheEntry(ctx context.Context, entry *models.AICacheEntry) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	query := `INSERT INTO ai_cache (` + aiCacheColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(cache_key) DO UPDATE SET kind = excluded.kind, provider = excluded.provider,
+		model = excluded.model, result_json = excluded.result_json,
+		created_at = excluded.created_at, expires_at = excluded.expires_at`
+	_, err := s.db.ExecContext(ctx, query,
+		entry.Key, entry.Kind, entry.Provider, entry.Model, entry.ResultJSON,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano), entry.ExpiresAt.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+// GetAICacheEntry returns the unexpired entry with the given key, or sql.ErrNoRows.
+func (s *sqliteStore) GetAICacheEntry(ctx context.Context, key string) (*models.AICacheEntry, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	var e models.AICacheEntry
+	var createdAt, expiresAt string
+	err := s.db.QueryRowContext(ctx, `SELECT `+aiCacheColumns+` FROM ai_cache WHERE cache_key = ? AND expires_at > ?`,
+		key, time.Now().UTC().Format(time.RFC3339Nano),
+	).Scan(&e.Key, &e.Kind, &e.Provider, &e.Model, &e.ResultJSON, &createdAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	e.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	e.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	return &e, nil
+}
+
+// CountAICacheEntries returns the number of unexpired entries.
+func (s *sqliteStore) CountAICacheEntries(ctx context.Context) (int, error) {
+	if s.db == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM ai_cache WHERE expires_at > ?`,
+		time.Now().UTC().Format(time.RFC3339Nano)).Scan(&n)
+	return n, err
+}
+
+// PurgeAICache deletes every entry and returns how many were removed.
+func (s *sqliteStore) PurgeAICache(ctx context.Context) (int64, error) {
+	if s.db == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+	res, err := s.db.ExecContext(ctx, `DELETE FROM ai_cache`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// --- AI usage accounting ---
+
+// SaveAIUsage appends a usage record and sets rec.ID.
+func (s *sqliteStore) SaveAIUsage(ctx context.Context, rec *models.AIUsageRecord) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	res, err := s.db.ExecContext(ctx, `INSERT INTO ai_usage (
+		operation, provider, model, prompt_tokens, completion_tokens, cost_usd, latency_ms,
+		retries, outcome, error, deploy_id, user, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.Operation, rec.Provider, rec.Model, rec.PromptTokens, rec.CompletionTokens, rec.CostUSD, rec.LatencyMS,
+		rec.Retries, rec.Outcome, rec.Error, rec.DeployID, rec.User, rec.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	rec.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// SumAIUsageCost returns the cost of the requests recorded since the given time.
+func (s *sqliteStore) SumAIUsageCost(ctx context.Context, since time.Time) (float64, error) {
+	if s.db == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+	var total float64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(cost_usd), 0) FROM ai_usage WHERE created_at >= ?`,
+		since.UTC().Format(time.RFC3339Nano)).Scan(&total)
+	return total, err
+}
+
+// aiUsageGroups maps the groupings of SummarizeAIUsage to their key expression.
+var aiUsageGroups = map[string]string{
+	"day":   `substr(created_at, 1, 10)`,
+	"model": `provider || '/' || model`,
+	"user":  `user`,
+	"":      `''`, // one row for all records
+}
+
+// SummarizeAIUsage aggregates the matching usage records by day (UTC), model
+// ("provider/model") or user, ordered by key. An empty groupBy returns one total row.
+func (s *sqliteStore) SummarizeAIUsage(ctx context.Context, filter models.AIUsageFilter, groupBy string) ([]models.AIUsageSummary, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	keyExpr, ok := aiUsageGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported grouping: %s", groupBy)
+	}
+
+	var where []string
+	var args []interface{}
+	if !filter.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.From.UTC().Format(time.RFC3339Nano))
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.To.UTC().Format(time.RFC3339Nano))
+	}
+	if filter.DeployID != "" {
+		where = append(where, "deploy_id = ?")
+		args = append(args, filter.DeployID)
+	}
+	query := `SELECT ` + keyExpr + ` AS k, COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0),
+		COALESCE(SUM(cost_usd), 0), COALESCE(AVG(latency_ms), 0), COALESCE(MAX(latency_ms), 0),
+		COALESCE(SUM(retries), 0), COALESCE(SUM(CASE WHEN outcome != 'ok' THEN 1 ELSE 0 END), 0)
+		FROM ai_usage`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	if groupBy != "" {
+		query += ` GROUP BY k ORDER BY k`
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.AIUsageSummary{}
+	for rows.Next() {
+		var sum models.AIUsageSummary
+		var avgLatency float64
+		if err := rows.Scan(&sum.Key, &sum.Calls, &sum.PromptTokens, &sum.CompletionTokens, &sum.CostUSD,
+			&avgLatency, &sum.MaxLatencyMS, &sum.Retries, &sum.Failures); err != nil {
+			return nil, err
+		}
+		sum.AvgLatencyMS = int64(avgLatency)
+		result = append(result, sum)
+	}
+	return result, rows.Err()
+}
+
+// formatNullTime stores an optional timestamp as RFC3339 (NULL when unset).
+func formatNullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseNullTime is the inverse of formatNullTime.
+func parseNullTime(v sql.NullString) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, v.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
 func (s *sqliteStore) CleanupOldRecords(ctx context.Context, retentionDays int) (int64, error) {
 	if s.db == nil {
 		return 0, fmt.Errorf("database not initialized")
@@ -882,6 +1666,34 @@ func (s *sqliteStore) CleanupOldRecords(ctx context.Context, retentionDays int)
 	n, _ = res.RowsAffected()
 	totalDeleted += n
 
+	// Delete old single deploys that are no longer pending or running
+	res, err = s.db.ExecContext(ctx,
+		`DELETE FROM single_deploys WHERE created_at < ? AND status NOT IN ('pending', 'deploying', 'awaiting_promotion')`,
+		cutoff)
+	if err != nil {
+		return totalDeleted, fmt.Errorf("cleaning single_deploys: %w", err)
+	}
+	n, _ = res.RowsAffected()
+	totalDeleted += n
+
+	// Delete old manifest revisions
+	res, err = s.db.ExecContext(ctx,
+		`DELETE FROM manifest_revisions WHERE created_at < ?`, cutoff)
+	if err != nil {
+		return totalDeleted, fmt.Errorf("cleaning manifest_revisions: %w", err)
+	}
+	n, _ = res.RowsAffected()
+	totalDeleted += n
+
+	// Delete expired AI cache entries regardless of retention
+	res, err = s.db.ExecContext(ctx,
+		`DELETE FROM ai_cache WHERE expires_at <= ?`, time.Now().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return totalDeleted, fmt.Errorf("cleaning ai_cache: %w", err)
+	}
+	n, _ = res.RowsAffected()
+	totalDeleted += n
+
 	return totalDeleted, nil
 }
 
@@ -911,12 +1723,55 @@ func (s *sqliteStore) cleanupLoop(retentionDays int, interval time.Duration) {
 	}
 }
 
+// encodeFanOut serializes the per-cluster results of a multi-cluster deploy ("" when unset).
+func encodeFanOut(f *models.FanOut) string {
+	if f == nil {
+		return ""
+	}
+	data, _ := json.Marshal(f)
+	return string(data)
+}
+
+// decodeFanOut is the inverse of encodeFanOut.
+func decodeFanOut(s string) *models.FanOut {
+	if s == "" {
+		return nil
+	}
+	var f models.FanOut
+	if err := json.Unmarshal([]byte(s), &f); err != nil {
+		return nil
+	}
+	return &f
+}
+
+// encodeUsage serializes the measured usage of a deploy ("" when unset).
+func encodeUsage(u *models.DeploymentUsage) string {
+	if u == nil {
+		return ""
+	}
+	data, _ := json.Marshal(u)
+	return string(data)
+}
+
+// decodeUsage is the inverse of encodeUsage.
+func decodeUsage(s string) *models.DeploymentUsage {
+	if s == "" {
+		return nil
+	}
+	var u models.DeploymentUsage
+	if err := json.Unmarshal([]byte(s), &u); err != nil {
+		return nil
+	}
+	return &u
+}
+
 func scanDeployments(rows *sql.Rows) ([]models.DeploymentHistory, error) {
 	var results []models.DeploymentHistory
 	for rows.Next() {
 		var d models.DeploymentHistory
 		var deployedAt string
-		var deletedAt sql.NullString
+		var deletedAt, driftCheckedAt sql.NullString
+		var fanOutJSON, usageJSON string
 		err := rows.Scan(
 			&d.ID, &d.ServiceName, &d.ImageName, &d.ImageTag, &d.ServiceType, &d.Language,
 			&d.CPURequest, &d.CPULimit, &d.MemoryRequest, &d.MemoryLimit,
@@ -924,6 +1779,7 @@ func scanDeployments(rows *sql.Rows) ([]models.DeploymentHistory, error) {
 			&d.TargetCluster, &d.Namespace, &deployedAt, &d.Success,
 			&d.Status, &d.ManifestJSON, &deletedAt,
 			&d.OOMEvents, &d.ThrottleEvents, &d.AIGenerated, &d.AIConfidence,
+			&d.DriftStatus, &driftCheckedAt, &d.CommitSHA, &d.ParentID, &fanOutJSON, &usageJSON,
 		)
 		if err != nil {
 			return nil, err
@@ -933,6 +1789,12 @@ func scanDeployments(rows *sql.Rows) ([]models.DeploymentHistory, error) {
 			t, _ := time.Parse(time.RFC3339, deletedAt.String)
 			d.DeletedAt = &t
 		}
+		if driftCheckedAt.Valid {
+			t, _ := time.Parse(time.RFC3339, driftCheckedAt.String)
+			d.DriftCheckedAt = &t
+		}
+		d.FanOut = decodeFanOut(fanOutJSON)
+		d.Usage = decodeUsage(usageJSON)
 		results = append(results, d)
 	}
 	if results == nil {
@@ -2,8 +2,10 @@ package data
 
 import (
 	"context"
+	"database/sql"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -294,3 +296,507 @@ func TestClose_Idempotent(t *testing.T) {
 		t.Fatalf("second Close failed: %v", err)
 	}
 }
+
+func TestUpdateDriftStatus(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	if err := store.SaveDeployment(ctx, &models.DeploymentHistory{
+		ID: "drift-1", ServiceName: "web", ImageName: "nginx", DeployedAt: time.Now(), Success: true,
+		ManifestJSON: `{"deployment":"old"}`,
+	}); err != nil {
+		t.Fatalf("SaveDeployment failed: %v", err)
+	}
+
+	checkedAt := time.Now()
+	if err := store.UpdateDriftStatus(ctx, "drift-1", "drifted", checkedAt); err != nil {
+		t.Fatalf("UpdateDriftStatus failed: %v", err)
+	}
+	if err := store.UpdateDeploymentManifest(ctx, "drift-1", `{"deployment":"new"}`); err != nil {
+		t.Fatalf("UpdateDeploymentManifest failed: %v", err)
+	}
+
+	d, err := store.GetDeployment(ctx, "drift-1")
+	if err != nil {
+		t.Fatalf("GetDeployment failed: %v", err)
+	}
+	if d.DriftStatus != "drifted" {
+		t.Errorf("expected drift status 'drifted', got %q", d.DriftStatus)
+	}
+	if d.DriftCheckedAt == nil || !d.DriftCheckedAt.Equal(checkedAt) {
+		t.Errorf("expected drift checked at %v, got %v", checkedAt, d.DriftCheckedAt)
+	}
+	if d.ManifestJSON != `{"deployment":"new"}` {
+		t.Errorf("expected updated manifest, got %q", d.ManifestJSON)
+	}
+}
+
+func TestUpdateDeploymentUsage(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	deployedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second) // inside the retention of the cleanup loop
+	if err := store.SaveDeployment(ctx, &models.DeploymentHistory{
+		ID: "usage-1", ServiceName: "web", ImageName: "nginx", DeployedAt: deployedAt, Success: true,
+	}); err != nil {
+		t.Fatalf("SaveDeployment failed: %v", err)
+	}
+
+	usage := &models.DeploymentUsage{
+		Samples:   12,
+		CPU:       models.UsagePercentiles{P50: 40, P95: 90, Max: 120},
+		Memory:    models.UsagePercentiles{P50: 64 << 20, P95: 100 << 20, Max: 110 << 20},
+		OOMEvents: 1, ThrottleEvents: 2, ThrottleMeasured: true,
+		From: deployedAt, To: deployedAt.Add(time.Hour), Completed: true,
+	}
+	if err := store.UpdateDeploymentUsage(ctx, &models.DeploymentHistory{
+		ID: "usage-1", ActualCPU: "90m", ActualMemory: "100Mi", OOMEvents: 1, ThrottleEvents: 2, Usage: usage,
+	}); err != nil {
+		t.Fatalf("UpdateDeploymentUsage failed: %v", err)
+	}
+
+	d, err := store.GetDeployment(ctx, "usage-1")
+	if err != nil {
+		t.Fatalf("GetDeployment failed: %v", err)
+	}
+	if d.ActualCPU != "90m" || d.ActualMemory != "100Mi" || d.OOMEvents != 1 || d.ThrottleEvents != 2 {
+		t.Errorf("unexpected measured fields: %+v", d)
+	}
+	if !reflect.DeepEqual(d.Usage, usage) {
+		t.Errorf("expected usage %+v, got %+v", usage, d.Usage)
+	}
+
+	similar, err := store.FindSimilar(ctx, "nginx", "", 5)
+	if err != nil || len(similar) != 1 || similar[0].Usage == nil || similar[0].Usage.CPU.P95 != 90 {
+		t.Errorf("expected FindSimilar to return the usage, got %+v (%v)", similar, err)
+	}
+}
+
+func TestGitDeliveryFields(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	if err := store.SaveDeployment(ctx, &models.DeploymentHistory{
+		ID: "git-1", ServiceName: "web", ImageName: "nginx", DeployedAt: time.Now(), Success: true,
+		CommitSHA: "3f2a9c1d7e4b",
+	}); err != nil {
+		t.Fatalf("SaveDeployment failed: %v", err)
+	}
+	d, err := store.GetDeployment(ctx, "git-1")
+	if err != nil {
+		t.Fatalf("GetDeployment failed: %v", err)
+	}
+	if d.CommitSHA != "3f2a9c1d7e4b" {
+		t.Errorf("expected commit sha, got %q", d.CommitSHA)
+	}
+
+	if err := store.SaveRegisteredCluster(ctx, &models.RegisteredCluster{
+		Name: "prod", Type: "kubernetes", Context: "prod", Delivery: models.DeliveryGit,
+		Git: &models.GitDelivery{URL: "https://github.com/acme/manifests.git", Branch: "main"},
+	}); err != nil {
+		t.Fatalf("SaveRegisteredCluster failed: %v", err)
+	}
+	clusters, err := store.GetRegisteredClusters(ctx)
+	if err != nil {
+		t.Fatalf("GetRegisteredClusters failed: %v", err)
+	}
+	if len(clusters) != 1 || clusters[0].Delivery != models.DeliveryGit || clusters[0].Git == nil ||
+		clusters[0].Git.URL != "https://github.com/acme/manifests.git" {
+		t.Errorf("unexpected registered clusters %+v", clusters)
+	}
+}
+
+func TestFanOutFields(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now()
+	fanOut := &models.FanOut{Strategy: models.FanOutWaves, Targets: []models.TargetResult{
+		{ClusterTarget: models.ClusterTarget{ClusterName: "onprem", Wave: 1}, DeployID: "fan-1-1", Status: "completed"},
+		{ClusterTarget: models.ClusterTarget{ClusterName: "cloud", ManifestOverrides: models.ManifestOverrides{Replicas: 4}, Wave: 2}, DeployID: "fan-1-2", Status: "failed"},
+	}}
+	for _, d := range []*models.DeploymentHistory{
+		{ID: "fan-1", ServiceName: "web", DeployedAt: now, Success: true, Status: "partial", FanOut: fanOut},
+		{ID: "fan-1-1", ServiceName: "web", TargetCluster: "onprem", DeployedAt: now, Success: true, ParentID: "fan-1"},
+	} {
+		if err := store.SaveDeployment(ctx, d); err != nil {
+			t.Fatalf("SaveDeployment failed: %v", err)
+		}
+	}
+
+	parent, err := store.GetDeployment(ctx, "fan-1")
+	if err != nil {
+		t.Fatalf("GetDeployment failed: %v", err)
+	}
+	if parent.FanOut == nil || len(parent.FanOut.Targets) != 2 || parent.FanOut.Targets[1].Replicas != 4 ||
+		parent.FanOut.Targets[1].DeployID != "fan-1-2" {
+		t.Errorf("unexpected fan-out %+v", parent.FanOut)
+	}
+	child, err := store.GetDeployment(ctx, "fan-1-1")
+	if err != nil {
+		t.Fatalf("GetDeployment failed: %v", err)
+	}
+	if child.ParentID != "fan-1" || child.FanOut != nil {
+		t.Errorf("unexpected child %+v", child)
+	}
+
+	// The unified history lists the parent only, with the target clusters
+	items, total, err := store.ListUnifiedHistory(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("ListUnifiedHistory failed: %v", err)
+	}
+	if total != 1 || len(items) != 1 || items[0].ID != "fan-1" || items[0].Cluster != "onprem, cloud" || items[0].FanOut == nil {
+		t.Errorf("unexpected unified history (total %d): %+v", total, items)
+	}
+
+	if err := store.SaveStackDeploy(ctx, &models.StackDeployRecord{DeployID: "stack-1", StackName: "shop", Status: "deploying", FanOut: fanOut}); err != nil {
+		t.Fatalf("SaveStackDeploy failed: %v", err)
+	}
+	if err := store.SaveStackDeploy(ctx, &models.StackDeployRecord{DeployID: "stack-1-1", StackName: "shop", ClusterName: "onprem", Status: "deployed", ParentID: "stack-1"}); err != nil {
+		t.Fatalf("SaveStackDeploy failed: %v", err)
+	}
+	fanOut.Targets[1].Status = "deployed"
+	if err := store.UpdateStackDeploy(ctx, &models.StackDeployRecord{DeployID: "stack-1", Status: "deployed", FanOut: fanOut}); err != nil {
+		t.Fatalf("UpdateStackDeploy failed: %v", err)
+	}
+	stack, err := store.GetStackDeploy(ctx, "stack-1")
+	if err != nil {
+		t.Fatalf("GetStackDeploy failed: %v", err)
+	}
+	if stack.FanOut == nil || stack.FanOut.Targets[1].Status != "deployed" {
+		t.Errorf("unexpected stack fan-out %+v", stack.FanOut)
+	}
+	stackChild, err := store.GetStackDeploy(ctx, "stack-1-1")
+	if err != nil {
+		t.Fatalf("GetStackDeploy failed: %v", err)
+	}
+	if stackChild.ParentID != "stack-1" {
+		t.Errorf("expected parent id, got %q", stackChild.ParentID)
+	}
+	if _, total, _ := store.ListUnifiedHistory(ctx, 0, 10); total != 2 {
+		t.Errorf("expected the two parents in unified history, got %d", total)
+	}
+}
+
+func TestPromotions(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+	for i, p := range []*models.Promotion{
+		{ID: "p1", Kind: models.PromotionDeploy, SourceID: "d1", FromEnv: "dev", ToEnv: "staging", ArtifactVersion: "sha256:abc", Images: []string{"web:1.0"}, Status: models.PromotionPending, RequestedBy: "alice"},
+		{ID: "p2", Kind: models.PromotionStack, SourceID: "s1", FromEnv: "dev", ToEnv: "staging", ArtifactVersion: "sha256:def", Status: models.PromotionRejected},
+	} {
+		p.RequestedAt = base.Add(time.Duration(i) * time.Minute)
+		if err := store.SavePromotion(ctx, p); err != nil {
+			t.Fatalf("SavePromotion failed: %v", err)
+		}
+	}
+
+	// Approving updates the decision fields in place
+	decided := base.Add(10 * time.Minute)
+	p1, err := store.GetPromotion(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetPromotion failed: %v", err)
+	}
+	p1.Status, p1.DeployID, p1.DecidedBy, p1.DecidedAt = models.PromotionCompleted, "d2", "bob", &decided
+	if err := store.SavePromotion(ctx, p1); err != nil {
+		t.Fatalf("SavePromotion update failed: %v", err)
+	}
+
+	got, err := store.GetPromotion(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetPromotion failed: %v", err)
+	}
+	if got.Status != models.PromotionCompleted || got.DeployID != "d2" || got.DecidedBy != "bob" || got.RequestedBy != "alice" ||
+		got.DecidedAt == nil || !got.DecidedAt.Equal(decided.UTC().Truncate(0)) || got.CompletedAt != nil ||
+		len(got.Images) != 1 || got.Images[0] != "web:1.0" || got.ArtifactVersion != "sha256:abc" {
+		t.Errorf("unexpected promotion %+v", got)
+	}
+	if _, err := store.GetPromotion(ctx, "missing"); err == nil {
+		t.Error("expected an error for a missing promotion")
+	}
+
+	all, err := store.ListPromotions(ctx, models.PromotionFilter{})
+	if err != nil {
+		t.Fatalf("ListPromotions failed: %v", err)
+	}
+	if len(all) != 2 || all[0].ID != "p2" {
+		t.Errorf("expected newest first, got %+v", all)
+	}
+	byDeploy, _ := store.ListPromotions(ctx, models.PromotionFilter{DeployID: "d2", Status: models.PromotionCompleted})
+	if len(byDeploy) != 1 || byDeploy[0].ID != "p1" {
+		t.Errorf("expected p1 by deploy ID, got %+v", byDeploy)
+	}
+	limited, _ := store.ListPromotions(ctx, models.PromotionFilter{ToEnv: "staging", Limit: 1})
+	if len(limited) != 1 {
+		t.Errorf("expected limit to apply, got %d", len(limited))
+	}
+}
+
+func TestManifestRevisions(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, rev := range []*models.ManifestRevision{
+		{DeployID: "d1", Type: "single", Source: models.RevisionGenerate, ManifestJSON: `{"deployment":"v1"}`},
+		{DeployID: "d2", Type: "stack", Source: models.RevisionFallback, ManifestJSON: `{}`},
+		{DeployID: "d1", Type: "single", Source: models.RevisionRefine, Feedback: "more memory", ManifestJSON: `{"deployment":"v2"}`},
+		{DeployID: "d1", Type: "single", Source: models.RevisionRevert, RevertedFrom: 1, ManifestJSON: `{"deployment":"v1"}`},
+	} {
+		if err := store.SaveManifestRevision(ctx, rev); err != nil {
+			t.Fatalf("SaveManifestRevision failed: %v", err)
+		}
+	}
+
+	revs, err := store.ListManifestRevisions(ctx, "d1")
+	if err != nil {
+		t.Fatalf("ListManifestRevisions failed: %v", err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("expected 3 revisions, got %d", len(revs))
+	}
+	for i, rev := range revs {
+		if rev.Version != i+1 || rev.CreatedAt.IsZero() {
+			t.Errorf("revision %d: unexpected %+v", i, rev)
+		}
+	}
+	if revs[1].Feedback != "more memory" || revs[2].RevertedFrom != 1 || revs[2].ManifestJSON != `{"deployment":"v1"}` {
+		t.Errorf("unexpected revisions %+v", revs)
+	}
+
+	got, err := store.GetManifestRevision(ctx, "d2", 1)
+	if err != nil || got.Source != models.RevisionFallback || got.Type != "stack" {
+		t.Errorf("unexpected revision %+v (err %v)", got, err)
+	}
+	if _, err := store.GetManifestRevision(ctx, "d1", 4); err == nil {
+		t.Error("expected an error for a missing revision")
+	}
+
+	// Deleting the deploy record drops its revisions
+	if err := store.DeleteDeploymentRecord(ctx, "d1"); err != nil {
+		t.Fatalf("DeleteDeploymentRecord failed: %v", err)
+	}
+	if revs, _ := store.ListManifestRevisions(ctx, "d1"); len(revs) != 0 {
+		t.Errorf("expected revisions to be deleted, got %d", len(revs))
+	}
+}
+
+func TestManifestQuality(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	quality := func(score int) *models.QualityReport {
+		return &models.QualityReport{Score: score, RulesVersion: 1, Findings: []models.QualityFinding{
+			{Severity: models.SeverityWarning, Category: models.QualitySecurity, Resource: "Deployment/web", Rule: "image_tag"},
+		}}
+	}
+	for _, rev := range []*models.ManifestRevision{
+		{DeployID: "d1", Source: models.RevisionGenerate, Quality: quality(80), Provider: "openai", Model: "gpt-4o", PromptVersion: 1},
+		{DeployID: "d1", Source: models.RevisionRefine, Quality: quality(95), Provider: "openai", Model: "gpt-4o", PromptVersion: 1},
+		{DeployID: "d1", Source: models.RevisionEdit, Quality: quality(100), Provider: "openai", Model: "gpt-4o", PromptVersion: 1},
+		{DeployID: "d2", Source: models.RevisionGenerate, Quality: quality(70), Provider: "claude", Model: "claude-sonnet", PromptVersion: 1},
+		{DeployID: "d3", Source: models.RevisionGenerate, Quality: quality(60), Provider: "openai", Model: "gpt-4o", PromptVersion: 2},
+		{DeployID: "d4", Source: models.RevisionFallback, Quality: quality(50)},
+		{DeployID: "d5", Source: models.RevisionGenerate, Provider: "openai", Model: "gpt-4o", PromptVersion: 1},
+	} {
+		rev.Type = "single"
+		rev.ManifestJSON = `{}`
+		if err := store.SaveManifestRevision(ctx, rev); err != nil {
+			t.Fatalf("SaveManifestRevision failed: %v", err)
+		}
+	}
+
+	rev, err := store.GetManifestRevision(ctx, "d1", 1)
+	if err != nil || rev.Quality == nil || rev.Quality.Score != 80 || len(rev.Quality.Findings) != 1 || rev.Model != "gpt-4o" || rev.PromptVersion != 1 {
+		t.Fatalf("expected the quality report and model, got %+v (err %v)", rev, err)
+	}
+	if rev, _ := store.GetManifestRevision(ctx, "d5", 1); rev.Quality != nil {
+		t.Errorf("expected no report on an unscored revision, got %+v", rev.Quality)
+	}
+
+	// Edits, fallbacks and unscored revisions are left out
+	sums, err := store.SummarizeManifestQuality(ctx, models.AIQualityFilter{})
+	if err != nil {
+		t.Fatalf("SummarizeManifestQuality failed: %v", err)
+	}
+	want := []models.AIQualitySummary{
+		{Provider: "claude", Model: "claude-sonnet", PromptVersion: 1, RulesVersion: 1, Revisions: 1, AvgScore: 70, MinScore: 70, MaxScore: 70},
+		{Provider: "openai", Model: "gpt-4o", PromptVersion: 1, RulesVersion: 1, Revisions: 2, AvgScore: 87.5, MinScore: 80, MaxScore: 95},
+		{Provider: "openai", Model: "gpt-4o", PromptVersion: 2, RulesVersion: 1, Revisions: 1, AvgScore: 60, MinScore: 60, MaxScore: 60},
+	}
+	if !reflect.DeepEqual(sums, want) {
+		t.Errorf("expected %+v, got %+v", want, sums)
+	}
+
+	sums, _ = store.SummarizeManifestQuality(ctx, models.AIQualityFilter{Source: models.RevisionRefine})
+	if len(sums) != 1 || sums[0].AvgScore != 95 {
+		t.Errorf("expected only the refinement, got %+v", sums)
+	}
+	sums, _ = store.SummarizeManifestQuality(ctx, models.AIQualityFilter{To: time.Now().Add(-time.Hour)})
+	if len(sums) != 0 {
+		t.Errorf("expected nothing before the revisions, got %+v", sums)
+	}
+}
+
+func TestSingleDeploys(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	started := time.Now().Add(-time.Minute)
+	rec := &models.DeployRecord{
+		DeployID:     "d1",
+		ContainerID:  "web",
+		ClusterName:  "local",
+		Namespace:    "default",
+		Status:       "pending",
+		StartedAt:    &started,
+		RequestJSON:  `{"container_id":"web"}`,
+		ResponseJSON: `{"deploy_id":"d1"}`,
+	}
+	if err := store.SaveSingleDeploy(ctx, rec); err != nil {
+		t.Fatalf("SaveSingleDeploy failed: %v", err)
+	}
+
+	// Saving again updates the status and JSON columns in place
+	completed := time.Now()
+	rec.Status = "completed"
+	rec.CompletedAt = &completed
+	rec.StatusJSON = `{"deploy_id":"d1","status":"completed"}`
+	if err := store.SaveSingleDeploy(ctx, rec); err != nil {
+		t.Fatalf("SaveSingleDeploy (update) failed: %v", err)
+	}
+	if err := store.SaveSingleDeploy(ctx, &models.DeployRecord{DeployID: "d2", Status: "deploying"}); err != nil {
+		t.Fatalf("SaveSingleDeploy failed: %v", err)
+	}
+
+	got, err := store.GetSingleDeploy(ctx, "d1")
+	if err != nil {
+		t.Fatalf("GetSingleDeploy failed: %v", err)
+	}
+	if got.Status != "completed" || got.CompletedAt == nil || got.StartedAt == nil || got.ContainerID != "web" ||
+		got.StatusJSON != rec.StatusJSON || got.RequestJSON != rec.RequestJSON || got.CreatedAt.IsZero() {
+		t.Errorf("unexpected record %+v", got)
+	}
+	if _, err := store.GetSingleDeploy(ctx, "missing"); err == nil {
+		t.Error("expected an error for a missing deploy")
+	}
+
+	list, err := store.ListSingleDeploys(ctx, 10)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("expected 2 deploys, got %d (err %v)", len(list), err)
+	}
+
+	if err := store.DeleteDeploymentRecord(ctx, "d1"); err != nil {
+		t.Fatalf("DeleteDeploymentRecord failed: %v", err)
+	}
+	if _, err := store.GetSingleDeploy(ctx, "d1"); err == nil {
+		t.Error("expected the deploy to be deleted with its record")
+	}
+}
+
+func TestAICache(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now()
+	for _, e := range []*models.AICacheEntry{
+		{Key: "k1", Kind: "manifest", Provider: "openai", Model: "gpt-4o", ResultJSON: `{"deployment":"a"}`, ExpiresAt: now.Add(time.Hour)},
+		{Key: "k2", Kind: "stack", Provider: "openai", Model: "gpt-4o", ResultJSON: `{}`, ExpiresAt: now.Add(-time.Minute)},
+	} {
+		if err := store.SaveAICacheEntry(ctx, e); err != nil {
+			t.Fatalf("SaveAICacheEntry failed: %v", err)
+		}
+	}
+
+	got, err := store.GetAICacheEntry(ctx, "k1")
+	if err != nil {
+		t.Fatalf("GetAICacheEntry failed: %v", err)
+	}
+	if got.Kind != "manifest" || got.ResultJSON != `{"deployment":"a"}` || !got.ExpiresAt.After(now) {
+		t.Errorf("unexpected entry %+v", got)
+	}
+	if _, err := store.GetAICacheEntry(ctx, "k2"); err != sql.ErrNoRows {
+		t.Errorf("expected expired entry to be a miss, got %v", err)
+	}
+	if n, err := store.CountAICacheEntries(ctx); err != nil || n != 1 {
+		t.Errorf("expected 1 live entry, got %d (err %v)", n, err)
+	}
+
+	// Replacing a key keeps one row with the new result
+	if err := store.SaveAICacheEntry(ctx, &models.AICacheEntry{Key: "k1", Kind: "manifest", Provider: "openai", ResultJSON: `{"deployment":"b"}`, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("SaveAICacheEntry failed: %v", err)
+	}
+	if got, _ := store.GetAICacheEntry(ctx, "k1"); got == nil || got.ResultJSON != `{"deployment":"b"}` {
+		t.Errorf("expected replaced entry, got %+v", got)
+	}
+
+	// Cleanup drops expired entries, purge drops the rest
+	if _, err := store.CleanupOldRecords(ctx, 30); err != nil {
+		t.Fatalf("CleanupOldRecords failed: %v", err)
+	}
+	n, err := store.PurgeAICache(ctx)
+	if err != nil || n != 1 {
+		t.Errorf("expected purge to remove 1 entry, got %d (err %v)", n, err)
+	}
+}
+
+func TestAIUsage(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	day1 := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	for _, rec := range []*models.AIUsageRecord{
+		{Operation: "generate", Provider: "openai", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 500, CostUSD: 0.01, LatencyMS: 1200, Outcome: "ok", DeployID: "d1", User: "alice", CreatedAt: day1},
+		{Operation: "refine", Provider: "openai", Model: "gpt-4o", PromptTokens: 2000, CompletionTokens: 800, CostUSD: 0.02, LatencyMS: 3000, Retries: 1, Outcome: "ok", DeployID: "d1", User: "alice", CreatedAt: day2},
+		{Operation: "generate", Provider: "ollama", Model: "llama3.1", LatencyMS: 800, Retries: 2, Outcome: "fallback", Error: "connection refused", DeployID: "d2", User: "bob", CreatedAt: day2},
+	} {
+		if err := store.SaveAIUsage(ctx, rec); err != nil {
+			t.Fatalf("SaveAIUsage failed: %v", err)
+		}
+		if rec.ID == 0 {
+			t.Error("expected an ID to be assigned")
+		}
+	}
+
+	if cost, err := store.SumAIUsageCost(ctx, day2); err != nil || cost != 0.02 {
+		t.Errorf("expected 0.02 since day 2, got %v (err %v)", cost, err)
+	}
+
+	total, err := store.SummarizeAIUsage(ctx, models.AIUsageFilter{}, "")
+	if err != nil || len(total) != 1 {
+		t.Fatalf("expected one total row, got %+v (err %v)", total, err)
+	}
+	if got := total[0]; got.Calls != 3 || got.PromptTokens != 3000 || got.CompletionTokens != 1300 ||
+		got.MaxLatencyMS != 3000 || got.AvgLatencyMS != 1666 || got.Retries != 3 || got.Failures != 1 {
+		t.Errorf("unexpected total %+v", got)
+	}
+
+	byDay, _ := store.SummarizeAIUsage(ctx, models.AIUsageFilter{}, "day")
+	if len(byDay) != 2 || byDay[0].Key != "2026-03-01" || byDay[1].Key != "2026-03-02" || byDay[1].Calls != 2 {
+		t.Errorf("unexpected daily summary %+v", byDay)
+	}
+	byModel, _ := store.SummarizeAIUsage(ctx, models.AIUsageFilter{From: day2}, "model")
+	if len(byModel) != 2 || byModel[0].Key != "ollama/llama3.1" || byModel[1].Key != "openai/gpt-4o" || byModel[1].Calls != 1 {
+		t.Errorf("unexpected model summary %+v", byModel)
+	}
+	byUser, _ := store.SummarizeAIUsage(ctx, models.AIUsageFilter{DeployID: "d1"}, "user")
+	if len(byUser) != 1 || byUser[0].Key != "alice" || byUser[0].Calls != 2 {
+		t.Errorf("unexpected user summary %+v", byUser)
+	}
+
+	if _, err := store.SummarizeAIUsage(ctx, models.AIUsageFilter{}, "week"); err == nil {
+		t.Error("expected an error for an unsupported grouping")
+	}
+}
@@ -20,6 +20,7 @@ import (
 type Service interface {
 	ListContainers(ctx context.Context, all bool) ([]models.Container, error)
 	GetContainer(ctx context.Context, id string) (*models.ContainerDetail, error)
+	ContainerStats(ctx context.Context, id string) (*models.ContainerStats, error)
 	RestartContainer(ctx context.Context, id string) error
 	StopContainer(ctx context.Context, id string) error
 	DeleteContainer(ctx context.Context, id string, force bool) error
@@ -156,6 +157,7 @@ func (s *dockerService) GetContainer(ctx context.Context, id string) (*models.Co
 			WorkingDir:   workingDir,
 			ExposedPorts: exposedPorts,
 		},
+		ImageID: inspect.Image,
 		Mounts:  mounts,
 		Network: network,
 	}
@@ -168,6 +170,11 @@ func (s *dockerService) GetContainer(ctx context.Context, id string) (*models.Co
 	return detail, nil
 }
 
+// ContainerStats returns a one-shot CPU, memory and network sample of a container.
+func (s *dockerService) ContainerStats(ctx context.Context, id string) (*models.ContainerStats, error) {
+	return s.getContainerStats(ctx, id)
+}
+
 func (s *dockerService) getContainerStats(ctx context.Context, id string) (*models.ContainerStats, error) {
 	resp, err := s.client.ContainerStats(ctx, id, false)
 	if err != nil {
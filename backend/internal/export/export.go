@@ -0,0 +1,221 @@
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	sigyaml "sigs.k8s.io/yaml"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// Bundle is a set of generated manifests to export, with the metadata the chart and
+// kustomization need.
+type Bundle struct {
+	Name      string   // chart / kustomization name (coerced into a DNS label); defaults to the first workload
+	Namespace string   // namespace the manifests were generated for
+	Manifests []string // YAML documents (multi-document streams allowed)
+
+	// Recommendations, if set, override the values of the primary workload
+	// (the first Deployment or StatefulSet) in values.yaml.
+	Recommendations *models.Recommendations
+}
+
+// Files maps slash-separated paths to file contents.
+type Files map[string]string
+
+// Paths returns the file paths in sorted order.
+func (f Files) Paths() []string {
+	paths := make([]string, 0, len(f))
+	for p := range f {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// TarGz packs the files into a gzipped tar archive.
+func (f Files) TarGz() ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, p := range f.Paths() {
+		hdr := &tar.Header{Name: p, Mode: 0o644, Size: int64(len(f[p])), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, fmt.Errorf("writing %s header: %w", p, err)
+		}
+		if _, err := io.WriteString(tw, f[p]); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", p, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("closing tar: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("closing gzip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// object is one decoded manifest document.
+type object map[string]interface{}
+
+func (o object) kind() string { s, _ := o["kind"].(string); return s }
+
+func (o object) name() string {
+	meta, _ := o["metadata"].(map[string]interface{})
+	s, _ := meta["name"].(string)
+	return s
+}
+
+// clusterScopedKinds are never given a namespace.
+var clusterScopedKinds = map[string]bool{
+	"Namespace": true, "ClusterRole": true, "ClusterRoleBinding": true,
+	"PersistentVolume": true, "StorageClass": true, "CustomResourceDefinition": true,
+	"PriorityClass": true, "IngressClass": true,
+}
+
+// decodeObjects decodes every document of the bundle, flattening List kinds. Namespace
+// objects are dropped: the chart is installed with --create-namespace and overlays set
+// the namespace themselves.
+func decodeObjects(manifests []string) ([]object, error) {
+	var objs []object
+	for _, content := range manifests {
+		dec := yaml.NewDecoder(strings.NewReader(content))
+		for i := 0; ; i++ {
+			var doc map[string]interface{}
+			if err := dec.Decode(&doc); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, fmt.Errorf("decoding document %d: %w", i, err)
+			}
+			if len(doc) == 0 {
+				continue
+			}
+			if items, ok := doc["items"].([]interface{}); ok && strings.HasSuffix(object(doc).kind(), "List") {
+				for _, item := range items {
+					if m, ok := item.(map[string]interface{}); ok {
+						objs = append(objs, object(m))
+					}
+				}
+				continue
+			}
+			objs = append(objs, object(doc))
+		}
+	}
+
+	kept := objs[:0]
+	for _, obj := range objs {
+		if obj.kind() == "" || obj.name() == "" {
+			return nil, fmt.Errorf("document without kind or metadata.name")
+		}
+		if obj.kind() != "Namespace" {
+			kept = append(kept, obj)
+		}
+	}
+	return kept, nil
+}
+
+// podSpec returns the pod spec of a workload object, if any.
+func podSpec(obj object) map[string]interface{} {
+	path := []string{"spec", "template", "spec"}
+	if obj.kind() == "CronJob" {
+		path = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	}
+	var cur interface{} = map[string]interface{}(obj)
+	for _, key := range path {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[key]
+	}
+	spec, _ := cur.(map[string]interface{})
+	return spec
+}
+
+var nonKeyChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// valuesKey turns a resource name into a template-safe values key ("my-api" → "myApi").
+func valuesKey(name string) string {
+	parts := nonKeyChars.Split(name, -1)
+	var b strings.Builder
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString(strings.ToLower(p[:1]) + p[1:])
+		} else {
+			b.WriteString(strings.ToUpper(p[:1]) + p[1:])
+		}
+	}
+	key := b.String()
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		key = "r" + key
+	}
+	return key
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// chartName coerces s into a valid chart name (lowercase DNS label).
+func chartName(s string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(name) > 53 {
+		name = name[:53]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		name = "app"
+	}
+	return name
+}
+
+// bundleName names the export after the bundle, or its first workload if unnamed.
+func bundleName(b Bundle, objs []object) string {
+	if b.Name != "" {
+		return chartName(b.Name)
+	}
+	for _, obj := range objs {
+		if podSpec(obj) != nil {
+			return chartName(obj.name())
+		}
+	}
+	return chartName(objs[0].name())
+}
+
+// fileName builds a file name for an object: "<name>-<kind>.yaml".
+func fileName(obj object) string {
+	return chartName(obj.name()) + "-" + strings.ToLower(obj.kind()) + ".yaml"
+}
+
+// splitImage splits "repo/name:tag" into name and tag (or "@digest").
+func splitImage(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
+
+// marshalYAML encodes v the way kubectl would print it (sorted keys, JSON-compatible).
+func marshalYAML(v interface{}) (string, error) {
+	data, err := sigyaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,198 @@
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// ChartVersion is the version of every exported chart.
+const ChartVersion = "0.1.0"
+
+// placeholder is a template expression substituted after the object is encoded.
+type placeholder struct {
+	expr  string // template expression without braces
+	block bool   // rendered with toYaml under the key instead of inline
+}
+
+// templater collects placeholders while objects are rewritten into templates.
+type templater struct {
+	placeholders []placeholder
+}
+
+// token returns a scalar that survives YAML encoding unquoted and marks a placeholder.
+func (t *templater) token(p placeholder) string {
+	t.placeholders = append(t.placeholders, p)
+	return fmt.Sprintf("__HCD_TPL_%d__", len(t.placeholders)-1)
+}
+
+var tokenLine = regexp.MustCompile(`(?m)^(\s*(?:- )?)([^\s:]+): __HCD_TPL_(\d+)__$`)
+var tokenInline = regexp.MustCompile(`__HCD_TPL_(\d+)__`)
+
+// render replaces the placeholder tokens of an encoded object with template actions.
+func (t *templater) render(encoded string) string {
+	out := tokenLine.ReplaceAllStringFunc(encoded, func(line string) string {
+		m := tokenLine.FindStringSubmatch(line)
+		i, _ := strconv.Atoi(m[3])
+		p := t.placeholders[i]
+		if !p.block {
+			return line
+		}
+		indent := len(m[1]) + 2
+		return fmt.Sprintf("%s%s:\n{{- toYaml %s | nindent %d }}", m[1], m[2], p.expr, indent)
+	})
+	return tokenInline.ReplaceAllStringFunc(out, func(tok string) string {
+		i, _ := strconv.Atoi(tokenInline.FindStringSubmatch(tok)[1])
+		return "{{ " + t.placeholders[i].expr + " }}"
+	})
+}
+
+// Helm builds a chart from the bundle under "<name>/": Chart.yaml, values.yaml and one
+// template per object. Image, replicas and resources of every container and the
+// namespace of every object are parameters; values.yaml holds the generated values,
+// overridden for the primary workload by the bundle's recommendations.
+func Helm(b Bundle) (Files, error) {
+	objs, err := decodeObjects(b.Manifests)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("no objects to export")
+	}
+
+	name := bundleName(b, objs)
+	namespace := b.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	values := map[string]interface{}{"namespace": namespace}
+	files := Files{}
+	appVersion := ""
+	primary := true
+	usedKeys := map[string]bool{"namespace": true}
+
+	for _, obj := range objs {
+		t := &templater{}
+
+		if !clusterScopedKinds[obj.kind()] {
+			meta, _ := obj["metadata"].(map[string]interface{})
+			meta["namespace"] = t.token(placeholder{expr: ".Values.namespace | default .Release.Namespace | quote"})
+		}
+
+		if spec := podSpec(obj); spec != nil {
+			key := valuesKey(obj.name())
+			if usedKeys[key] {
+				key = valuesKey(obj.name() + "-" + obj.kind())
+			}
+			usedKeys[key] = true
+			wv := map[string]interface{}{}
+
+			workloadSpec, _ := obj["spec"].(map[string]interface{})
+			if replicas, ok := workloadSpec["replicas"]; ok && (obj.kind() == "Deployment" || obj.kind() == "StatefulSet") {
+				wv["replicas"] = replicas
+				workloadSpec["replicas"] = t.token(placeholder{expr: ".Values." + key + ".replicas"})
+			}
+
+			containers, _ := spec["containers"].([]interface{})
+			extra := map[string]interface{}{}
+			for i, item := range containers {
+				container, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				prefix := ".Values." + key
+				cv := wv
+				if i > 0 {
+					cname, _ := container["name"].(string)
+					ckey := valuesKey(cname)
+					cv = map[string]interface{}{}
+					extra[ckey] = cv
+					prefix += ".containers." + ckey
+				}
+				if image, ok := container["image"].(string); ok {
+					cv["image"] = image
+					container["image"] = t.token(placeholder{expr: prefix + ".image | quote"})
+					if appVersion == "" {
+						if _, tag := splitImage(image); tag != "" && !strings.HasPrefix(tag, "@") {
+							appVersion = tag
+						}
+					}
+				}
+				resources, _ := container["resources"].(map[string]interface{})
+				if resources == nil {
+					resources = map[string]interface{}{}
+				}
+				cv["resources"] = resources
+				container["resources"] = t.token(placeholder{expr: prefix + ".resources", block: true})
+			}
+			if len(extra) > 0 {
+				wv["containers"] = extra
+			}
+
+			if primary && (obj.kind() == "Deployment" || obj.kind() == "StatefulSet") {
+				applyRecommendations(wv, b.Recommendations)
+				primary = false
+			}
+			values[key] = wv
+		}
+
+		encoded, err := marshalYAML(map[string]interface{}(obj))
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s %q: %w", obj.kind(), obj.name(), err)
+		}
+		files[name+"/templates/"+fileName(obj)] = t.render(encoded)
+	}
+
+	if appVersion == "" {
+		appVersion = ChartVersion
+	}
+	files[name+"/Chart.yaml"] = fmt.Sprintf(`apiVersion: v2
+name: %s
+description: Exported from Hybrid Cloud Dashboard
+type: application
+version: %s
+appVersion: %q
+`, name, ChartVersion, appVersion)
+
+	encodedValues, err := marshalYAML(values)
+	if err != nil {
+		return nil, fmt.Errorf("encoding values: %w", err)
+	}
+	files[name+"/values.yaml"] = "# Default values for " + name + ".\n" + encodedValues
+	files[name+"/.helmignore"] = ".DS_Store\n.git/\n*.swp\n*.bak\n*.tmp\n"
+	return files, nil
+}
+
+// applyRecommendations overrides the workload values with the non-empty recommendations.
+func applyRecommendations(wv map[string]interface{}, rec *models.Recommendations) {
+	if rec == nil {
+		return
+	}
+	if rec.Replicas > 0 {
+		if _, ok := wv["replicas"]; ok {
+			wv["replicas"] = rec.Replicas
+		}
+	}
+	resources, _ := wv["resources"].(map[string]interface{})
+	if resources == nil {
+		return
+	}
+	set := func(section, resource, value string) {
+		if value == "" {
+			return
+		}
+		m, _ := resources[section].(map[string]interface{})
+		if m == nil {
+			m = map[string]interface{}{}
+			resources[section] = m
+		}
+		m[resource] = value
+	}
+	set("requests", "cpu", rec.CPURequest)
+	set("requests", "memory", rec.MemoryRequest)
+	set("limits", "cpu", rec.CPULimit)
+	set("limits", "memory", rec.MemoryLimit)
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+)
+
+const kustomizeAPIVersion = "kustomize.config.k8s.io/v1beta1"
+
+// Kustomize builds a kustomization from the bundle under "<name>/": a base with one
+// file per object and an overlay per cluster that sets the namespace and pins the
+// images and replica counts, the fields a cluster most often overrides.
+func Kustomize(b Bundle, clusters []string) (Files, error) {
+	objs, err := decodeObjects(b.Manifests)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("no objects to export")
+	}
+
+	name := bundleName(b, objs)
+	namespace := b.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	files := Files{}
+
+	var resources []string
+	images := map[string]map[string]interface{}{}
+	var replicas []map[string]interface{}
+	for _, obj := range objs {
+		file := fileName(obj)
+		encoded, err := marshalYAML(map[string]interface{}(obj))
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s %q: %w", obj.kind(), obj.name(), err)
+		}
+		files[name+"/base/"+file] = encoded
+		resources = append(resources, file)
+
+		if spec := podSpec(obj); spec != nil {
+			workloadSpec, _ := obj["spec"].(map[string]interface{})
+			if count, ok := workloadSpec["replicas"]; ok {
+				replicas = append(replicas, map[string]interface{}{"name": obj.name(), "count": count})
+			}
+			containers, _ := spec["containers"].([]interface{})
+			for _, item := range containers {
+				container, _ := item.(map[string]interface{})
+				image, _ := container["image"].(string)
+				if image == "" {
+					continue
+				}
+				imageName, tag := splitImage(image)
+				entry := map[string]interface{}{"name": imageName}
+				switch {
+				case len(tag) > 0 && tag[0] == '@':
+					entry["digest"] = tag[1:]
+				case tag != "":
+					entry["newTag"] = tag
+				}
+				images[imageName] = entry
+			}
+		}
+	}
+	sort.Strings(resources)
+
+	base, err := marshalYAML(map[string]interface{}{
+		"apiVersion": kustomizeAPIVersion,
+		"kind":       "Kustomization",
+		"resources":  resources,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding base kustomization: %w", err)
+	}
+	files[name+"/base/kustomization.yaml"] = base
+
+	imageNames := make([]string, 0, len(images))
+	for n := range images {
+		imageNames = append(imageNames, n)
+	}
+	sort.Strings(imageNames)
+	imageList := make([]interface{}, 0, len(imageNames))
+	for _, n := range imageNames {
+		imageList = append(imageList, images[n])
+	}
+
+	if len(clusters) == 0 {
+		clusters = []string{"default"}
+	}
+	for _, cluster := range clusters {
+		overlay := map[string]interface{}{
+			"apiVersion": kustomizeAPIVersion,
+			"kind":       "Kustomization",
+			"namespace":  namespace,
+			"resources":  []string{"../../base"},
+		}
+		if len(imageList) > 0 {
+			overlay["images"] = imageList
+		}
+		if len(replicas) > 0 {
+			overlay["replicas"] = replicas
+		}
+		encoded, err := marshalYAML(overlay)
+		if err != nil {
+			return nil, fmt.Errorf("encoding overlay %q: %w", cluster, err)
+		}
+		files[name+"/overlays/"+chartName(cluster)+"/kustomization.yaml"] = encoded
+	}
+	return files, nil
+}
@@ -0,0 +1,211 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+	sigyaml "sigs.k8s.io/yaml"
+)
+
+// Issue is one structural problem found by LintHelm or LintKustomize.
+type Issue struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+var (
+	chartNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	semverPattern    = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+)
+
+// LintHelm runs the structural checks of `helm lint` on the chart under root: Chart.yaml
+// fields, values.yaml syntax, and that every template renders with the default values
+// into documents with apiVersion, kind and metadata.name, without duplicates.
+func LintHelm(files Files, root string) []Issue {
+	var issues []Issue
+	add := func(p, format string, args ...interface{}) {
+		issues = append(issues, Issue{Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+
+	chartPath := root + "/Chart.yaml"
+	var chart struct {
+		APIVersion string `yaml:"apiVersion"`
+		Name       string `yaml:"name"`
+		Version    string `yaml:"version"`
+		Type       string `yaml:"type"`
+	}
+	if content, ok := files[chartPath]; !ok {
+		add(chartPath, "Chart.yaml file is missing")
+	} else if err := yaml.Unmarshal([]byte(content), &chart); err != nil {
+		add(chartPath, "unable to parse YAML: %v", err)
+	} else {
+		if chart.APIVersion != "v2" {
+			add(chartPath, "apiVersion must be v2, got %q", chart.APIVersion)
+		}
+		switch {
+		case chart.Name == "":
+			add(chartPath, "name is required")
+		case !chartNamePattern.MatchString(chart.Name):
+			add(chartPath, "name %q must be a lowercase DNS label", chart.Name)
+		case chart.Name != path.Base(root):
+			add(chartPath, "chart name %q must match the directory name %q", chart.Name, path.Base(root))
+		}
+		if !semverPattern.MatchString(chart.Version) {
+			add(chartPath, "version %q is not a valid SemVer 2 version", chart.Version)
+		}
+		if chart.Type != "" && chart.Type != "application" && chart.Type != "library" {
+			add(chartPath, "type %q must be application or library", chart.Type)
+		}
+	}
+
+	valuesPath := root + "/values.yaml"
+	values := map[string]interface{}{}
+	if content, ok := files[valuesPath]; ok {
+		if err := yaml.Unmarshal([]byte(content), &values); err != nil {
+			add(valuesPath, "unable to parse YAML: %v", err)
+		}
+	}
+
+	data := map[string]interface{}{
+		"Values":  values,
+		"Release": map[string]interface{}{"Name": path.Base(root), "Namespace": "default"},
+		"Chart":   map[string]interface{}{"Name": chart.Name, "Version": chart.Version},
+	}
+	seen := map[string]string{}
+	templates := 0
+	for _, p := range files.Paths() {
+		if !strings.HasPrefix(p, root+"/templates/") || !strings.HasSuffix(p, ".yaml") {
+			continue
+		}
+		templates++
+		tpl, err := template.New(path.Base(p)).Funcs(templateFuncs).Option("missingkey=error").Parse(files[p])
+		if err != nil {
+			add(p, "parse error: %v", err)
+			continue
+		}
+		var out bytes.Buffer
+		if err := tpl.Execute(&out, data); err != nil {
+			add(p, "render error: %v", err)
+			continue
+		}
+		issues = append(issues, lintDocuments(p, out.String(), seen)...)
+	}
+	if templates == 0 {
+		add(root+"/templates", "chart has no templates")
+	}
+	return issues
+}
+
+// LintKustomize checks every kustomization.yaml under root: it must parse as a
+// Kustomization, every resource must resolve to a file or a directory with its own
+// kustomization, and every resource file must hold valid objects.
+func LintKustomize(files Files, root string) []Issue {
+	var issues []Issue
+	add := func(p, format string, args ...interface{}) {
+		issues = append(issues, Issue{Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+
+	found := false
+	for _, p := range files.Paths() {
+		if !strings.HasPrefix(p, root+"/") || path.Base(p) != "kustomization.yaml" {
+			continue
+		}
+		found = true
+		var k struct {
+			APIVersion string   `yaml:"apiVersion"`
+			Kind       string   `yaml:"kind"`
+			Resources  []string `yaml:"resources"`
+		}
+		if err := yaml.Unmarshal([]byte(files[p]), &k); err != nil {
+			add(p, "unable to parse YAML: %v", err)
+			continue
+		}
+		if k.APIVersion != kustomizeAPIVersion || k.Kind != "Kustomization" {
+			add(p, "must be apiVersion %s, kind Kustomization", kustomizeAPIVersion)
+		}
+		if len(k.Resources) == 0 {
+			add(p, "kustomization has no resources")
+		}
+
+		dir := path.Dir(p)
+		seen := map[string]string{}
+		for _, r := range k.Resources {
+			target := path.Join(dir, r)
+			if content, ok := files[target]; ok {
+				issues = append(issues, lintDocuments(target, content, seen)...)
+				continue
+			}
+			if _, ok := files[target+"/kustomization.yaml"]; !ok {
+				add(p, "resource %q does not exist", r)
+			}
+		}
+	}
+	if !found {
+		add(root, "no kustomization.yaml found")
+	}
+	return issues
+}
+
+// lintDocuments checks that every document of a rendered file is an object with
+// apiVersion, kind and metadata.name, and that no kind/namespace/name repeats.
+func lintDocuments(p, content string, seen map[string]string) []Issue {
+	var issues []Issue
+	dec := yaml.NewDecoder(strings.NewReader(content))
+	for i := 0; ; i++ {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err != nil {
+			if err != io.EOF {
+				issues = append(issues, Issue{Path: p, Message: fmt.Sprintf("document %d: unable to parse YAML: %v", i, err)})
+			}
+			break
+		}
+		if len(doc) == 0 {
+			continue
+		}
+		apiVersion, _ := doc["apiVersion"].(string)
+		kind, _ := doc["kind"].(string)
+		meta, _ := doc["metadata"].(map[string]interface{})
+		name, _ := meta["name"].(string)
+		namespace, _ := meta["namespace"].(string)
+		if apiVersion == "" || kind == "" || name == "" {
+			issues = append(issues, Issue{Path: p, Message: fmt.Sprintf("document %d: apiVersion, kind and metadata.name are required", i)})
+			continue
+		}
+		id := kind + "/" + namespace + "/" + name
+		if other, dup := seen[id]; dup {
+			issues = append(issues, Issue{Path: p, Message: fmt.Sprintf("%s %q is also defined in %s", kind, name, other)})
+		}
+		seen[id] = p
+	}
+	return issues
+}
+
+// templateFuncs are the Sprig functions the exported templates use.
+var templateFuncs = template.FuncMap{
+	"default": func(def interface{}, v ...interface{}) interface{} {
+		if len(v) == 0 || v[0] == nil || v[0] == "" {
+			return def
+		}
+		return v[0]
+	},
+	"quote": func(v interface{}) string {
+		return fmt.Sprintf("%q", fmt.Sprint(v))
+	},
+	"toYaml": func(v interface{}) string {
+		data, err := sigyaml.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(string(data), "\n")
+	},
+	"nindent": func(n int, s string) string {
+		pad := strings.Repeat(" ", n)
+		return "\n" + pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+}
@@ -0,0 +1,272 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+const (
+	defaultBranch      = "main"
+	defaultAuthorName  = "Hybrid Cloud Dashboard"
+	defaultAuthorEmail = "hybrid-cloud-dashboard@localhost"
+
+	// BranchPrefix prefixes the deploy branches pushed for merge requests.
+	BranchPrefix = "hybrid-cloud-dashboard/"
+)
+
+// Service delivers manifests by committing them to a Git repository that a GitOps
+// controller (Argo CD, Flux) reconciles, instead of applying them to the cluster.
+type Service interface {
+	// Commit writes the files into the target repository and pushes the commit. With
+	// merge requests configured, the commit goes to a deploy branch instead of the
+	// target branch.
+	Commit(ctx context.Context, target models.GitDelivery, req CommitRequest) (*models.GitCommit, error)
+	// OpenMergeRequest opens a merge request for a commit pushed to a deploy branch
+	// and returns its URL.
+	OpenMergeRequest(ctx context.Context, target models.GitDelivery, commit *models.GitCommit, title, description string) (string, error)
+}
+
+// CommitRequest is one set of manifests to commit.
+type CommitRequest struct {
+	DeployID string            // recorded in the commit message as a "Deploy-ID:" trailer
+	Dir      string            // directory under the target path replaced by Files
+	Files    map[string]string // file contents keyed by slash-separated path relative to Dir
+	Message  string            // commit subject
+}
+
+type gitService struct{}
+
+// NewService creates a Git delivery service that drives the git CLI.
+func NewService() Service {
+	return &gitService{}
+}
+
+// WithDefaults fills in the branch and author defaults of a delivery target.
+func WithDefaults(target models.GitDelivery, cluster string) models.GitDelivery {
+	if target.Branch == "" {
+		target.Branch = defaultBranch
+	}
+	if target.Path == "" {
+		target.Path = "clusters/" + cluster
+	}
+	if target.AuthorName == "" {
+		target.AuthorName = defaultAuthorName
+	}
+	if target.AuthorEmail == "" {
+		target.AuthorEmail = defaultAuthorEmail
+	}
+	return target
+}
+
+// DeployBranch returns the branch a deploy is pushed to when merge requests are used.
+func DeployBranch(deployID string) string {
+	if len(deployID) > 8 {
+		deployID = deployID[:8]
+	}
+	return BranchPrefix + deployID
+}
+
+func (s *gitService) Commit(ctx context.Context, target models.GitDelivery, req CommitRequest) (*models.GitCommit, error) {
+	target = WithDefaults(target, "default")
+	if target.URL == "" {
+		return nil, fmt.Errorf("git delivery has no repository url")
+	}
+	if strings.HasPrefix(target.URL, "-") {
+		return nil, fmt.Errorf("invalid repository url %q", target.URL)
+	}
+	if len(req.Files) == 0 {
+		return nil, fmt.Errorf("no files to commit")
+	}
+	relDir, err := repoDir(target.Path, req.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.MkdirTemp("", "hcd-gitops-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating work directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	repo := filepath.Join(tmp, "repo")
+	g := &gitRunner{dir: repo, redact: target.URL, env: []string{
+		"GIT_AUTHOR_NAME=" + target.AuthorName, "GIT_AUTHOR_EMAIL=" + target.AuthorEmail,
+		"GIT_COMMITTER_NAME=" + target.AuthorName, "GIT_COMMITTER_EMAIL=" + target.AuthorEmail,
+	}}
+
+	if _, err := g.runIn(ctx, tmp, "clone", "--quiet", "--depth", "1", "--branch", target.Branch, "--", target.URL, repo); err != nil {
+		// The branch may not exist yet (e.g. an empty repository)
+		os.RemoveAll(repo)
+		if _, err := g.runIn(ctx, tmp, "clone", "--quiet", "--", target.URL, repo); err != nil {
+			return nil, err
+		}
+		if _, err := g.run(ctx, "checkout", "--quiet", "-B", target.Branch); err != nil {
+			return nil, err
+		}
+	}
+
+	branch := target.Branch
+	if target.MergeRequest != nil {
+		branch = DeployBranch(req.DeployID)
+		if _, err := g.run(ctx, "checkout", "--quiet", "-B", branch); err != nil {
+			return nil, err
+		}
+	}
+
+	// Replace the directory so manifests dropped from the deploy are pruned
+	absDir := filepath.Join(repo, filepath.FromSlash(relDir))
+	if err := os.RemoveAll(absDir); err != nil {
+		return nil, fmt.Errorf("clearing %s: %w", relDir, err)
+	}
+	names := make([]string, 0, len(req.Files))
+	for name := range req.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rel, err := repoDir(relDir, name)
+		if err != nil {
+			return nil, err
+		}
+		file := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return nil, fmt.Errorf("creating directory for %s: %w", rel, err)
+		}
+		if err := os.WriteFile(file, []byte(req.Files[name]), 0o644); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", rel, err)
+		}
+	}
+
+	if _, err := g.run(ctx, "add", "--all", "--", relDir); err != nil {
+		return nil, err
+	}
+	changes, err := g.run(ctx, "status", "--porcelain", "--", relDir)
+	if err != nil {
+		return nil, err
+	}
+
+	commit := &models.GitCommit{Repository: redactURL(target.URL), Branch: branch, Path: relDir}
+	if strings.TrimSpace(changes) == "" {
+		// Nothing to deliver: the repository already holds these manifests
+		commit.Branch = target.Branch
+		sha, err := g.run(ctx, "rev-parse", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		commit.SHA = strings.TrimSpace(sha)
+		commit.Unchanged = true
+		return commit, nil
+	}
+
+	message := req.Message
+	if message == "" {
+		message = "Deploy " + req.DeployID
+	}
+	if req.DeployID != "" {
+		message += "\n\nDeploy-ID: " + req.DeployID
+	}
+	if _, err := g.run(ctx, "commit", "--quiet", "--no-verify", "-m", message); err != nil {
+		return nil, err
+	}
+	sha, err := g.run(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	commit.SHA = strings.TrimSpace(sha)
+
+	push := []string{"push", "--quiet", "origin", "HEAD:refs/heads/" + branch}
+	if branch != target.Branch {
+		push = append(push, "--force") // deploy branches belong to the dashboard
+	}
+	if _, err := g.run(ctx, push...); err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
+
+func (s *gitService) OpenMergeRequest(ctx context.Context, target models.GitDelivery, commit *models.GitCommit, title, description string) (string, error) {
+	settings := target.MergeRequest
+	if settings == nil {
+		return "", fmt.Errorf("merge requests are not configured")
+	}
+	requester, ok := lookupMergeRequester(settings.Provider)
+	if !ok {
+		return "", fmt.Errorf("unknown merge request provider %q", settings.Provider)
+	}
+	targetBranch := settings.TargetBranch
+	if targetBranch == "" {
+		targetBranch = WithDefaults(target, "").Branch
+	}
+	token := ""
+	if settings.TokenEnv != "" {
+		token = os.Getenv(settings.TokenEnv)
+	}
+	return requester.OpenMergeRequest(ctx, MergeRequest{
+		Settings:     *settings,
+		SourceBranch: commit.Branch,
+		TargetBranch: targetBranch,
+		Title:        title,
+		Description:  description,
+		Token:        token,
+	})
+}
+
+// repoDir joins elem under base and rejects paths that escape it.
+func repoDir(base, elem string) (string, error) {
+	joined := path.Clean(path.Join(base, elem))
+	if joined == "." || joined == ".." || strings.HasPrefix(joined, "../") || path.IsAbs(joined) || joined == ".git" || strings.HasPrefix(joined, ".git/") {
+		return "", fmt.Errorf("invalid repository path %q", path.Join(base, elem))
+	}
+	return joined, nil
+}
+
+// redactURL hides the credentials of a repository URL.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = url.User("***")
+	return u.String()
+}
+
+// gitRunner runs git commands in a working copy.
+type gitRunner struct {
+	dir    string
+	redact string   // repository URL to hide in error output
+	env    []string // extra environment (commit identity)
+}
+
+func (g *gitRunner) run(ctx context.Context, args ...string) (string, error) {
+	return g.runIn(ctx, g.dir, args...)
+}
+
+func (g *gitRunner) runIn(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=true", "LC_ALL=C")
+	cmd.Env = append(cmd.Env, g.env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		if g.redact != "" {
+			msg = strings.ReplaceAll(msg, g.redact, redactURL(g.redact))
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,143 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// MergeRequest is a request to merge a deploy branch into the watched branch.
+type MergeRequest struct {
+	Settings     models.MergeRequestSettings
+	SourceBranch string
+	TargetBranch string
+	Title        string
+	Description  string
+	Token        string // API token read from Settings.TokenEnv
+}
+
+// MergeRequester opens merge requests (pull requests) on a Git hosting service.
+type MergeRequester interface {
+	// OpenMergeRequest opens the merge request and returns its web URL.
+	OpenMergeRequest(ctx context.Context, mr MergeRequest) (string, error)
+}
+
+var (
+	requestersMu sync.RWMutex
+	requesters   = map[string]MergeRequester{
+		"github": &githubRequester{client: &http.Client{Timeout: 30 * time.Second}},
+		"gitlab": &gitlabRequester{client: &http.Client{Timeout: 30 * time.Second}},
+	}
+)
+
+// RegisterMergeRequester makes a merge request provider available under name,
+// replacing any provider already registered with that name.
+func RegisterMergeRequester(name string, r MergeRequester) {
+	requestersMu.Lock()
+	defer requestersMu.Unlock()
+	requesters[name] = r
+}
+
+func lookupMergeRequester(name string) (MergeRequester, bool) {
+	requestersMu.RLock()
+	defer requestersMu.RUnlock()
+	r, ok := requesters[name]
+	return r, ok
+}
+
+// githubRequester opens pull requests through the GitHub REST API.
+type githubRequester struct {
+	client *http.Client
+}
+
+func (g *githubRequester) OpenMergeRequest(ctx context.Context, mr MergeRequest) (string, error) {
+	apiURL := strings.TrimSuffix(mr.Settings.APIURL, "/")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	body := map[string]string{
+		"title": mr.Title,
+		"head":  mr.SourceBranch,
+		"base":  mr.TargetBranch,
+		"body":  mr.Description,
+	}
+	headers := map[string]string{"Accept": "application/vnd.github+json"}
+	if mr.Token != "" {
+		headers["Authorization"] = "Bearer " + mr.Token
+	}
+	var resp struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := postJSON(ctx, g.client, apiURL+"/repos/"+mr.Settings.Project+"/pulls", headers, body, &resp); err != nil {
+		return "", fmt.Errorf("opening github pull request: %w", err)
+	}
+	return resp.HTMLURL, nil
+}
+
+// gitlabRequester opens merge requests through the GitLab REST API.
+type gitlabRequester struct {
+	client *http.Client
+}
+
+func (g *gitlabRequester) OpenMergeRequest(ctx context.Context, mr MergeRequest) (string, error) {
+	apiURL := strings.TrimSuffix(mr.Settings.APIURL, "/")
+	if apiURL == "" {
+		apiURL = "https://gitlab.com"
+	}
+	body := map[string]string{
+		"source_branch": mr.SourceBranch,
+		"target_branch": mr.TargetBranch,
+		"title":         mr.Title,
+		"description":   mr.Description,
+	}
+	headers := map[string]string{}
+	if mr.Token != "" {
+		headers["PRIVATE-TOKEN"] = mr.Token
+	}
+	var resp struct {
+		WebURL string `json:"web_url"`
+	}
+	endpoint := apiURL + "/api/v4/projects/" + url.PathEscape(mr.Settings.Project) + "/merge_requests"
+	if err := postJSON(ctx, g.client, endpoint, headers, body, &resp); err != nil {
+		return "", fmt.Errorf("opening gitlab merge request: %w", err)
+	}
+	return resp.WebURL, nil
+}
+
+// postJSON posts body as JSON and decodes a 2xx response into out.
+func postJSON(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
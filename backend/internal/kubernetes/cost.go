@@ -0,0 +1,171 @@
+package kubernetes
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// hoursPerMonth is the average number of hours in a month (365 × 24 / 12).
+const hoursPerMonth = 730
+
+// costLabels names the resources of a cost estimate in its breakdown.
+var costLabels = map[string]string{
+	models.CostCPU:          "CPU",
+	models.CostMemory:       "Memory",
+	models.CostLoadBalancer: "LoadBalancer",
+	models.CostStorage:      "Storage",
+	models.CostNodeOverhead: "Node overhead",
+}
+
+// costTotals are the priced quantities of a manifest set at one replica count.
+type costTotals struct {
+	vcpu, memoryGiB, loadBalancers, storageGiB float64
+}
+
+// EstimateCost estimates the monthly cost of the documents of a manifest set under a
+// pricing profile. Pods are priced by their requests (the limits where a request is
+// missing) times the replicas of their workload; the maximum uses maxReplicas for the
+// targets of HorizontalPodAutoscalers. DaemonSets count as one pod, since the number
+// of nodes is unknown, and Jobs are not priced. Services of type LoadBalancer,
+// PersistentVolumeClaims and the volume claim templates of StatefulSets add to the
+// cost. Invalid documents are skipped.
+func EstimateCost(documents []string, pricing models.PricingProfile) *models.EstimatedCost {
+	objs := decodeDocuments(documents, func(string, error) {})
+	workloads, services := podWorkloadsAndServices(objs)
+
+	autoscaled := map[string]*autoscaler{}
+	claimTemplates := map[string]float64{} // StatefulSet ref → GiB per pod
+	var base, peak costTotals
+	for _, obj := range objs {
+		switch obj.GroupVersionKind().GroupKind().String() {
+		case "HorizontalPodAutoscaler.autoscaling":
+			if hpa := toAutoscaler(obj); hpa != nil {
+				autoscaled[hpa.target] = hpa
+			}
+		case "StatefulSet.apps":
+			var sts appsv1.StatefulSet
+			if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &sts) == nil {
+				for _, t := range sts.Spec.VolumeClaimTemplates {
+					claimTemplates["StatefulSet/"+sts.Name] += gibibytes(t.Spec.Resources.Requests[corev1.ResourceStorage])
+				}
+			}
+		case "PersistentVolumeClaim":
+			var pvc corev1.PersistentVolumeClaim
+			if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pvc) == nil {
+				size := gibibytes(pvc.Spec.Resources.Requests[corev1.ResourceStorage])
+				base.storageGiB += size
+				peak.storageGiB += size
+			}
+		}
+	}
+
+	for _, w := range workloads {
+		replicas := w.replicas
+		switch {
+		case strings.HasPrefix(w.ref, "DaemonSet/"):
+			replicas = 1
+		case replicas == 0:
+			continue // Job, CronJob
+		}
+		maxReplicas := replicas
+		if hpa := autoscaled[w.ref]; hpa != nil {
+			replicas = max(replicas, hpa.minReplicas)
+			maxReplicas = max(replicas, hpa.maxReplicas)
+		}
+
+		var vcpu, memoryGiB float64
+		for _, c := range w.pod.Containers {
+			cpu := podResource(c, corev1.ResourceCPU)
+			vcpu += float64(cpu.MilliValue()) / 1000
+			memoryGiB += gibibytes(podResource(c, corev1.ResourceMemory))
+		}
+		for _, t := range []struct {
+			totals   *costTotals
+			replicas int32
+		}{{&base, replicas}, {&peak, maxReplicas}} {
+			n := float64(t.replicas)
+			t.totals.vcpu += vcpu * n
+			t.totals.memoryGiB += memoryGiB * n
+			t.totals.storageGiB += claimTemplates[w.ref] * n
+		}
+	}
+
+	for _, svc := range services {
+		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			base.loadBalancers++
+			peak.loadBalancers++
+		}
+	}
+
+	items := priceTotals(base, pricing)
+	peakItems := priceTotals(peak, pricing)
+	scales := peak != base
+	cost := &models.EstimatedCost{}
+	var parts []string
+	for i := range items {
+		item := items[i]
+		if item.Quantity == 0 && peakItems[i].Quantity == 0 {
+			continue
+		}
+		if scales {
+			item.MaxQuantity, item.MaxMonthlyUSD = peakItems[i].Quantity, peakItems[i].MonthlyUSD
+			cost.MaxMonthlyUSD += item.MaxMonthlyUSD
+		}
+		cost.MonthlyUSD += item.MonthlyUSD
+		cost.Items = append(cost.Items, item)
+		parts = append(parts, fmt.Sprintf("%s: $%.2f", costLabels[item.Resource], item.MonthlyUSD))
+	}
+	cost.MonthlyUSD = roundCents(cost.MonthlyUSD)
+	cost.MaxMonthlyUSD = roundCents(cost.MaxMonthlyUSD)
+	cost.Breakdown = strings.Join(parts, ", ")
+	if scales {
+		cost.Breakdown += fmt.Sprintf(" (up to $%.2f at HPA maxReplicas)", cost.MaxMonthlyUSD)
+	}
+	return cost
+}
+
+// priceTotals prices the totals, one item per resource in breakdown order.
+func priceTotals(t costTotals, p models.PricingProfile) []models.CostItem {
+	cpu := t.vcpu * p.VCPUHour * hoursPerMonth
+	memory := t.memoryGiB * p.GiBHour * hoursPerMonth
+	overhead := 0.0
+	if cpu+memory > 0 {
+		overhead = p.NodeOverhead
+	}
+	return []models.CostItem{
+		{Resource: models.CostCPU, Quantity: round(t.vcpu, 3), MonthlyUSD: roundCents(cpu)},
+		{Resource: models.CostMemory, Quantity: round(t.memoryGiB, 3), MonthlyUSD: roundCents(memory)},
+		{Resource: models.CostLoadBalancer, Quantity: t.loadBalancers, MonthlyUSD: roundCents(t.loadBalancers * p.LoadBalancerHour * hoursPerMonth)},
+		{Resource: models.CostStorage, Quantity: round(t.storageGiB, 3), MonthlyUSD: roundCents(t.storageGiB * p.PVCGiBMonth)},
+		{Resource: models.CostNodeOverhead, Quantity: overhead, MonthlyUSD: roundCents((cpu + memory) * overhead)},
+	}
+}
+
+// podResource returns the request of a container for a resource, or its limit when
+// the request is missing (Kubernetes then defaults the request to the limit).
+func podResource(c corev1.Container, name corev1.ResourceName) resource.Quantity {
+	if q, ok := c.Resources.Requests[name]; ok {
+		return q
+	}
+	return c.Resources.Limits[name]
+}
+
+func gibibytes(q resource.Quantity) float64 {
+	return float64(q.Value()) / (1 << 30)
+}
+
+func roundCents(usd float64) float64 {
+	return round(usd, 2)
+}
+
+func round(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,163 @@
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// costContainer requests half a vCPU and half a GiB.
+const costContainer = "image: app:1.0\nresources:\n  requests:\n    cpu: 500m\n    memory: 512Mi\n"
+
+func TestEstimateCost(t *testing.T) {
+	// 14.60 per vCPU, 7.30 per GiB and 14.60 per load balancer a month
+	pricing := models.PricingProfile{VCPUHour: 0.02, GiBHour: 0.01, LoadBalancerHour: 0.02, PVCGiBMonth: 0.1}
+	web := testWorkload{kind: "Deployment", name: "web", spec: "replicas: 2", container: costContainer}.String()
+
+	tests := []struct {
+		name     string
+		docs     []string
+		overhead float64
+		want     *models.EstimatedCost
+	}{
+		{
+			name: "requests times replicas",
+			docs: []string{web},
+			want: &models.EstimatedCost{
+				MonthlyUSD: 21.9,
+				Breakdown:  "CPU: $14.60, Memory: $7.30",
+				Items: []models.CostItem{
+					{Resource: models.CostCPU, Quantity: 1, MonthlyUSD: 14.6},
+					{Resource: models.CostMemory, Quantity: 1, MonthlyUSD: 7.3},
+				},
+			},
+		},
+		{
+			name: "limits where requests are missing",
+			docs: []string{testWorkload{kind: "Deployment", name: "web", container: "image: app:1.0\nresources:\n  limits:\n    cpu: 1\n    memory: 2Gi\n"}.String()},
+			want: &models.EstimatedCost{
+				MonthlyUSD: 29.2,
+				Breakdown:  "CPU: $14.60, Memory: $14.60",
+				Items: []models.CostItem{
+					{Resource: models.CostCPU, Quantity: 1, MonthlyUSD: 14.6},
+					{Resource: models.CostMemory, Quantity: 2, MonthlyUSD: 14.6},
+				},
+			},
+		},
+		{
+			name: "HPA min and max replicas",
+			docs: []string{testWorkload{kind: "Deployment", name: "web", spec: "replicas: 1", container: costContainer}.String(), autoscalerFor("web", 2, 6)},
+			want: &models.EstimatedCost{
+				MonthlyUSD:    21.9,
+				MaxMonthlyUSD: 65.7,
+				Breakdown:     "CPU: $14.60, Memory: $7.30 (up to $65.70 at HPA maxReplicas)",
+				Items: []models.CostItem{
+					{Resource: models.CostCPU, Quantity: 1, MaxQuantity: 3, MonthlyUSD: 14.6, MaxMonthlyUSD: 43.8},
+					{Resource: models.CostMemory, Quantity: 1, MaxQuantity: 3, MonthlyUSD: 7.3, MaxMonthlyUSD: 21.9},
+				},
+			},
+		},
+		{
+			name: "HPA range below the replicas",
+			docs: []string{web, autoscalerFor("web", 1, 2)},
+			want: &models.EstimatedCost{
+				MonthlyUSD: 21.9,
+				Breakdown:  "CPU: $14.60, Memory: $7.30",
+				Items: []models.CostItem{
+					{Resource: models.CostCPU, Quantity: 1, MonthlyUSD: 14.6},
+					{Resource: models.CostMemory, Quantity: 1, MonthlyUSD: 7.3},
+				},
+			},
+		},
+		{
+			name: "PersistentVolumeClaims",
+			docs: []string{"apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata:\n  name: data\nspec:\n  resources:\n    requests:\n      storage: 10Gi\n"},
+			want: &models.EstimatedCost{
+				MonthlyUSD: 1,
+				Breakdown:  "Storage: $1.00",
+				Items:      []models.CostItem{{Resource: models.CostStorage, Quantity: 10, MonthlyUSD: 1}},
+			},
+		},
+		{
+			name: "StatefulSet claim templates per replica",
+			docs: []string{testWorkload{
+				kind:      "StatefulSet",
+				name:      "db",
+				spec:      "replicas: 4\nvolumeClaimTemplates:\n- metadata:\n    name: data\n  spec:\n    resources:\n      requests:\n        storage: 5Gi\n",
+				container: "image: db:1.0\nresources:\n  requests:\n    cpu: 250m\n    memory: 256Mi\n",
+			}.String()},
+			want: &models.EstimatedCost{
+				MonthlyUSD: 23.9,
+				Breakdown:  "CPU: $14.60, Memory: $7.30, Storage: $2.00",
+				Items: []models.CostItem{
+					{Resource: models.CostCPU, Quantity: 1, MonthlyUSD: 14.6},
+					{Resource: models.CostMemory, Quantity: 1, MonthlyUSD: 7.3},
+					{Resource: models.CostStorage, Quantity: 20, MonthlyUSD: 2},
+				},
+			},
+		},
+		{
+			name: "DaemonSets as one pod, Jobs unpriced",
+			docs: []string{
+				testWorkload{kind: "DaemonSet", name: "agent", container: costContainer}.String(),
+				testWorkload{kind: "Job", name: "migrate", pod: "restartPolicy: Never", container: "image: app:1.0\nresources:\n  requests:\n    cpu: 8\n"}.String(),
+			},
+			want: &models.EstimatedCost{
+				MonthlyUSD: 10.95,
+				Breakdown:  "CPU: $7.30, Memory: $3.65",
+				Items: []models.CostItem{
+					{Resource: models.CostCPU, Quantity: 0.5, MonthlyUSD: 7.3},
+					{Resource: models.CostMemory, Quantity: 0.5, MonthlyUSD: 3.65},
+				},
+			},
+		},
+		{
+			name: "LoadBalancer Services",
+			docs: []string{
+				"apiVersion: v1\nkind: Service\nmetadata:\n  name: public\nspec:\n  type: LoadBalancer\n  ports:\n  - port: 80\n",
+				"apiVersion: v1\nkind: Service\nmetadata:\n  name: internal\nspec:\n  ports:\n  - port: 80\n",
+			},
+			want: &models.EstimatedCost{
+				MonthlyUSD: 14.6,
+				Breakdown:  "LoadBalancer: $14.60",
+				Items:      []models.CostItem{{Resource: models.CostLoadBalancer, Quantity: 1, MonthlyUSD: 14.6}},
+			},
+		},
+		{
+			name:     "node overhead on CPU and memory",
+			docs:     []string{web},
+			overhead: 0.1,
+			want: &models.EstimatedCost{
+				MonthlyUSD: 24.09,
+				Breakdown:  "CPU: $14.60, Memory: $7.30, Node overhead: $2.19",
+				Items: []models.CostItem{
+					{Resource: models.CostCPU, Quantity: 1, MonthlyUSD: 14.6},
+					{Resource: models.CostMemory, Quantity: 1, MonthlyUSD: 7.3},
+					{Resource: models.CostNodeOverhead, Quantity: 0.1, MonthlyUSD: 2.19},
+				},
+			},
+		},
+		{
+			name: "invalid and empty documents skipped",
+			docs: []string{"kind: Deployment\nmetadata:\n  name: broken\n", "", web},
+			want: &models.EstimatedCost{
+				MonthlyUSD: 21.9,
+				Breakdown:  "CPU: $14.60, Memory: $7.30",
+				Items: []models.CostItem{
+					{Resource: models.CostCPU, Quantity: 1, MonthlyUSD: 14.6},
+					{Resource: models.CostMemory, Quantity: 1, MonthlyUSD: 7.3},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := pricing
+			p.NodeOverhead = tt.overhead
+			if got := EstimateCost(tt.docs, p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// volatileMetadataFields are server-populated fields that always differ between
+// the live object and a dry-run result and therefore carry no useful diff signal.
+var volatileMetadataFields = []string{
+	"managedFields", "resourceVersion", "generation", "uid", "creationTimestamp", "selfLink",
+}
+
+// DiffManifest performs a server-side dry-run apply of the manifest and compares the
+// result against the live object. Nothing is persisted on the cluster.
+// Admission and schema errors are reported per object with Action "error"; only
+// decoding and connection problems are returned as an error.
+func (s *k8sService) DiffManifest(ctx context.Context, cluster string, yamlContent string) ([]models.ResourceDiff, error) {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if cc.dynClient == nil {
+		return nil, fmt.Errorf("cluster %q has no dynamic client", cluster)
+	}
+
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return nil, err
+	}
+
+	created := map[string]bool{}
+	diffs := make([]models.ResourceDiff, 0, len(objs))
+	for _, obj := range objs {
+		diffs = append(diffs, s.diffObject(ctx, cc, obj, created))
+	}
+	return diffs, nil
+}
+
+// DiffManifestSet diffs manifests that are applied together, in order, like
+// DiffManifest. The server cannot dry-run an object in a Namespace that does not exist
+// yet, so the namespaced objects of a Namespace an earlier manifest of the set creates
+// are reported as "create" without a dry run. A manifest that cannot be decoded gets a
+// single "error" entry; only connection problems are returned as an error.
+func (s *k8sService) DiffManifestSet(ctx context.Context, cluster string, manifests []string) ([][]models.ResourceDiff, error) {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if cc.dynClient == nil {
+		return nil, fmt.Errorf("cluster %q has no dynamic client", cluster)
+	}
+
+	created := map[string]bool{}
+	results := make([][]models.ResourceDiff, 0, len(manifests))
+	for _, yamlContent := range manifests {
+		objs, err := decodeManifests(yamlContent)
+		if err != nil {
+			results = append(results, []models.ResourceDiff{{Action: "error", Error: err.Error()}})
+			continue
+		}
+		diffs := make([]models.ResourceDiff, 0, len(objs))
+		for _, obj := range objs {
+			diffs = append(diffs, s.diffObject(ctx, cc, obj, created))
+		}
+		results = append(results, diffs)
+	}
+	return results, nil
+}
+
+// DiffManifests compares two manifests offline, matching objects by kind, namespace and
+// name. Objects only in after are "create", only in before "delete"; the changes of the
+// rest are computed on the decoded YAML, so formatting and key order do not count.
+func DiffManifests(before, after string) ([]models.ResourceDiff, error) {
+	var beforeObjs, afterObjs []*unstructured.Unstructured
+	var err error
+	if before != "" {
+		if beforeObjs, err = decodeManifests(before); err != nil {
+			return nil, fmt.Errorf("before: %w", err)
+		}
+	}
+	if after != "" {
+		if afterObjs, err = decodeManifests(after); err != nil {
+			return nil, fmt.Errorf("after: %w", err)
+		}
+	}
+
+	key := func(obj *unstructured.Unstructured) string {
+		return obj.GetKind() + "/" + obj.GetNamespace() + "/" + obj.GetName()
+	}
+	previous := make(map[string]*unstructured.Unstructured, len(beforeObjs))
+	for _, obj := range beforeObjs {
+		previous[key(obj)] = obj
+	}
+
+	diffs := make([]models.ResourceDiff, 0, len(afterObjs))
+	for _, obj := range afterObjs {
+		diff := models.ResourceDiff{Kind: obj.GetKind(), Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		if old, ok := previous[key(obj)]; !ok {
+			diff.Action = "create"
+		} else {
+			delete(previous, key(obj))
+			diff.Changes = diffValues("", old.Object, obj.Object)
+			diff.Action = "unchanged"
+			if len(diff.Changes) > 0 {
+				diff.Action = "change"
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	for _, obj := range beforeObjs {
+		if _, ok := previous[key(obj)]; ok {
+			diffs = append(diffs, models.ResourceDiff{Kind: obj.GetKind(), Name: obj.GetName(), Namespace: obj.GetNamespace(), Action: "delete"})
+		}
+	}
+	return diffs, nil
+}
+
+// diffObject computes the diff of a single object against the cluster. created holds
+// the Namespaces that objects diffed before this one create: objects in them are new
+// and cannot be dry-run. A Namespace that does not exist yet is added to it.
+func (s *k8sService) diffObject(ctx context.Context, cc *clusterClient, obj *unstructured.Unstructured, created map[string]bool) models.ResourceDiff {
+	diff := models.ResourceDiff{
+		Kind:      obj.GetKind(),
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+	}
+
+	dr, err := s.resourceFor(cc, obj)
+	if err != nil {
+		diff.Action = "error"
+		diff.Error = err.Error()
+		return diff
+	}
+	diff.Namespace = obj.GetNamespace()
+	if diff.Namespace != "" && created[diff.Namespace] {
+		diff.Action = "create"
+		return diff
+	}
+
+	live, err := dr.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		diff.Action = "error"
+		diff.Error = fmt.Sprintf("getting live %s %q: %v", obj.GetKind(), obj.GetName(), err)
+		return diff
+	}
+	if errors.IsNotFound(err) {
+		live = nil
+	}
+
+	dryRun, err := s.applyObject(ctx, dr, obj, true)
+	if err != nil {
+		diff.Action = "error"
+		diff.Error = err.Error()
+		return diff
+	}
+
+	if live == nil {
+		if obj.GetKind() == "Namespace" && obj.GetAPIVersion() == "v1" {
+			created[obj.GetName()] = true
+		}
+		diff.Action = "create"
+		return diff
+	}
+
+	diff.Changes = diffValues("", normalizeForDiff(live.Object), normalizeForDiff(dryRun.Object))
+	if len(diff.Changes) == 0 {
+		diff.Action = "unchanged"
+	} else {
+		diff.Action = "change"
+	}
+	return diff
+}
+
+// normalizeForDiff returns a copy of the object without status and server-populated metadata.
+func normalizeForDiff(obj map[string]interface{}) map[string]interface{} {
+	out := runtime.DeepCopyJSON(obj)
+	delete(out, "status")
+	if meta, ok := out["metadata"].(map[string]interface{}); ok {
+		for _, f := range volatileMetadataFields {
+			delete(meta, f)
+		}
+		if ann, ok := meta["annotations"].(map[string]interface{}); ok {
+			delete(ann, "deployment.kubernetes.io/revision")
+			if len(ann) == 0 {
+				delete(meta, "annotations")
+			}
+		}
+	}
+	return out
+}
+
+// diffValues walks two decoded JSON values and returns the leaf-level differences.
+// Paths use dot notation for map keys and [i] for list indexes.
+func diffValues(path string, before, after interface{}) []models.FieldChange {
+	bm, bIsMap := before.(map[string]interface{})
+	am, aIsMap := after.(map[string]interface{})
+	if bIsMap && aIsMap {
+		keys := make(map[string]bool, len(bm)+len(am))
+		for k := range bm {
+			keys[k] = true
+		}
+		for k := range am {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var changes []models.FieldChange
+		for _, k := range sorted {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			changes = append(changes, diffValues(childPath, bm[k], am[k])...)
+		}
+		return changes
+	}
+
+	bl, bIsList := before.([]interface{})
+	al, aIsList := after.([]interface{})
+	if bIsList && aIsList {
+		var changes []models.FieldChange
+		n := len(bl)
+		if len(al) > n {
+			n = len(al)
+		}
+		for i := 0; i < n; i++ {
+			var b, a interface{}
+			if i < len(bl) {
+				b = bl[i]
+			}
+			if i < len(al) {
+				a = al[i]
+			}
+			changes = append(changes, diffValues(path+"["+strconv.Itoa(i)+"]", b, a)...)
+		}
+		return changes
+	}
+
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	return []models.FieldChange{{Path: path, Before: before, After: after}}
+}
@@ -0,0 +1,312 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// newFakeService returns a service with a single cluster, "test", backed by a fake
+// clientset seeded with typed objects and a fake dynamic client seeded with objs.
+func newFakeService(typed []runtime.Object, objs ...runtime.Object) (*k8sService, *dynamicfake.FakeDynamicClient) {
+	listKinds := map[schema.GroupVersionResource]string{}
+	for kind, gvr := range knownGVR {
+		listKinds[gvr] = kind + "List"
+	}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objs...)
+	return &k8sService{clusters: map[string]*clusterClient{
+		"test": {client: k8sfake.NewClientset(typed...), dynClient: dyn},
+	}}, dyn
+}
+
+func configMap(name, value string) string {
+	return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n  namespace: default\ndata:\n  level: \"" + value + "\"\n"
+}
+
+func TestDiffManifests(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string
+		after   string
+		want    []models.ResourceDiff
+		wantErr string
+	}{
+		{
+			name:   "formatting and key order do not count",
+			before: configMap("app", "info"),
+			after:  "kind: ConfigMap\napiVersion: v1\ndata: {level: info}\nmetadata: {namespace: default, name: app}\n",
+			want:   []models.ResourceDiff{{Kind: "ConfigMap", Name: "app", Namespace: "default", Action: "unchanged"}},
+		},
+		{
+			name:   "changed value",
+			before: configMap("app", "info"),
+			after:  configMap("app", "debug"),
+			want: []models.ResourceDiff{{Kind: "ConfigMap", Name: "app", Namespace: "default", Action: "change", Changes: []models.FieldChange{
+				{Path: "data.level", Before: "info", After: "debug"},
+			}}},
+		},
+		{
+			name:   "created and deleted objects",
+			before: configMap("old", "info"),
+			after:  configMap("new", "info"),
+			want: []models.ResourceDiff{
+				{Kind: "ConfigMap", Name: "new", Namespace: "default", Action: "create"},
+				{Kind: "ConfigMap", Name: "old", Namespace: "default", Action: "delete"},
+			},
+		},
+		{
+			name:  "empty before",
+			after: configMap("app", "info"),
+			want:  []models.ResourceDiff{{Kind: "ConfigMap", Name: "app", Namespace: "default", Action: "create"}},
+		},
+		{
+			name:    "invalid after",
+			before:  configMap("app", "info"),
+			after:   "kind: [",
+			wantErr: "after:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffManifests(tt.before, tt.after)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DiffManifests: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after interface{}
+		want          []models.FieldChange
+	}{
+		{"equal", map[string]interface{}{"a": int64(1)}, map[string]interface{}{"a": int64(1)}, nil},
+		{
+			"nested keys in sorted order",
+			map[string]interface{}{"b": map[string]interface{}{"x": "1"}, "a": "1"},
+			map[string]interface{}{"b": map[string]interface{}{"x": "2"}, "a": "2"},
+			[]models.FieldChange{{Path: "a", Before: "1", After: "2"}, {Path: "b.x", Before: "1", After: "2"}},
+		},
+		{
+			"added and removed keys",
+			map[string]interface{}{"old": "1"},
+			map[string]interface{}{"new": "1"},
+			[]models.FieldChange{{Path: "new", After: "1"}, {Path: "old", Before: "1"}},
+		},
+		{
+			"list indexes",
+			map[string]interface{}{"ports": []interface{}{int64(80)}},
+			map[string]interface{}{"ports": []interface{}{int64(8080), int64(443)}},
+			[]models.FieldChange{{Path: "ports[0]", Before: int64(80), After: int64(8080)}, {Path: "ports[1]", After: int64(443)}},
+		},
+		{
+			"type change",
+			map[string]interface{}{"v": "1"},
+			map[string]interface{}{"v": map[string]interface{}{"x": "1"}},
+			[]models.FieldChange{{Path: "v", Before: "1", After: map[string]interface{}{"x": "1"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffValues("", tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeForDiff(t *testing.T) {
+	obj := map[string]interface{}{
+		"status": map[string]interface{}{"replicas": int64(1)},
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"resourceVersion": "42",
+			"uid":             "u1",
+			"managedFields":   []interface{}{},
+			"annotations":     map[string]interface{}{"deployment.kubernetes.io/revision": "3"},
+		},
+	}
+	want := map[string]interface{}{"metadata": map[string]interface{}{"name": "web"}}
+	if got := normalizeForDiff(obj); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, ok := obj["status"]; !ok {
+		t.Error("normalizeForDiff should not modify its input")
+	}
+}
+
+func TestDiffManifest(t *testing.T) {
+	live, err := decodeManifests(configMap("app", "info"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, dyn := newFakeService(nil, live[0])
+	// The fake client cannot server-side apply unstructured objects; answer the dry run
+	// the way the API server does, with the applied object and server-populated metadata.
+	dyn.PrependReactor("patch", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch := action.(clienttesting.PatchActionImpl)
+		if !reflect.DeepEqual(patch.PatchOptions.DryRun, []string{metav1.DryRunAll}) {
+			t.Errorf("expected a dry-run apply, got options %+v", patch.PatchOptions)
+		}
+		if patch.GetName() == "rejected" {
+			return true, nil, fmt.Errorf("admission webhook denied the request")
+		}
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal(patch.GetPatch(), &obj.Object); err != nil {
+			return true, nil, err
+		}
+		obj.SetResourceVersion("2")
+		obj.SetUID("u1")
+		return true, obj, nil
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		cluster  string
+		manifest string
+		want     []models.ResourceDiff
+		wantErr  string
+	}{
+		{
+			name:     "unchanged",
+			cluster:  "test",
+			manifest: configMap("app", "info"),
+			want:     []models.ResourceDiff{{Kind: "ConfigMap", Name: "app", Namespace: "default", Action: "unchanged"}},
+		},
+		{
+			name:     "changed",
+			cluster:  "test",
+			manifest: configMap("app", "debug"),
+			want: []models.ResourceDiff{{Kind: "ConfigMap", Name: "app", Namespace: "default", Action: "change", Changes: []models.FieldChange{
+				{Path: "data.level", Before: "info", After: "debug"},
+			}}},
+		},
+		{
+			name:     "new object",
+			cluster:  "test",
+			manifest: configMap("cache", "info"),
+			want:     []models.ResourceDiff{{Kind: "ConfigMap", Name: "cache", Namespace: "default", Action: "create"}},
+		},
+		{
+			name:     "rejected by admission",
+			cluster:  "test",
+			manifest: configMap("rejected", "info"),
+			want: []models.ResourceDiff{{Kind: "ConfigMap", Name: "rejected", Namespace: "default", Action: "error",
+				Error: `dry-run applying ConfigMap "rejected": admission webhook denied the request`}},
+		},
+		{
+			name:     "unknown kind without a REST mapper",
+			cluster:  "test",
+			manifest: "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n",
+			want:     []models.ResourceDiff{{Kind: "Widget", Name: "w", Action: "error", Error: `no REST mapper for kind "Widget"`}},
+		},
+		{
+			name:     "unknown cluster",
+			cluster:  "missing",
+			manifest: configMap("app", "info"),
+			wantErr:  `cluster "missing" not found`,
+		},
+		{
+			name:     "invalid manifest",
+			cluster:  "test",
+			manifest: "kind: [",
+			wantErr:  "decoding YAML document 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.DiffManifest(ctx, tt.cluster, tt.manifest)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DiffManifest: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffManifestSet_NewNamespace(t *testing.T) {
+	live, err := decodeManifests("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: default\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, dyn := newFakeService(nil, live[0])
+	var dryRuns []string
+	dyn.PrependReactor("patch", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch := action.(clienttesting.PatchActionImpl)
+		dryRuns = append(dryRuns, patch.GetResource().Resource+"/"+patch.GetNamespace()+"/"+patch.GetName())
+		if patch.GetNamespace() == "shop" {
+			return true, nil, fmt.Errorf(`namespaces "shop" not found`)
+		}
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal(patch.GetPatch(), &obj.Object); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	})
+	shop := "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: shop\n"
+	inShop := strings.Replace(configMap("app", "info"), "namespace: default", "namespace: shop", 1)
+
+	results, err := svc.DiffManifestSet(context.Background(), "test", []string{shop, inShop, configMap("app", "info"), "kind: ["})
+	if err != nil {
+		t.Fatalf("DiffManifestSet: %v", err)
+	}
+	var got []string
+	for _, diffs := range results {
+		for _, d := range diffs {
+			got = append(got, d.Kind+" "+d.Namespace+"/"+d.Name+" "+d.Action)
+		}
+	}
+	want := []string{"Namespace /shop create", "ConfigMap shop/app create", "ConfigMap default/app create", " / error"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if wantDryRuns := []string{"namespaces//shop", "configmaps/default/app"}; !reflect.DeepEqual(dryRuns, wantDryRuns) {
+		t.Errorf("dry runs %v, want %v: objects in a new Namespace cannot be dry-run", dryRuns, wantDryRuns)
+	}
+
+	// A Namespace and its objects in one manifest
+	dryRuns = nil
+	diffs, err := svc.DiffManifest(context.Background(), "test", shop+"---\n"+inShop)
+	if err != nil {
+		t.Fatalf("DiffManifest: %v", err)
+	}
+	if len(diffs) != 2 || diffs[1].Action != "create" || diffs[1].Error != "" {
+		t.Errorf("expected the ConfigMap in the new Namespace to be created, got %+v", diffs)
+	}
+	if !reflect.DeepEqual(dryRuns, []string{"namespaces//shop"}) {
+		t.Errorf("expected only the Namespace to be dry-run, got %v", dryRuns)
+	}
+}
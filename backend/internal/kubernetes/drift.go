@@ -0,0 +1,159 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	sigyaml "sigs.k8s.io/yaml"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// DetectDrift compares every object of a stored manifest with the live cluster. It uses
+// the same server-side dry-run as DiffManifest, so a change is reported exactly when
+// re-applying the manifest would change the live object: fields set by other managers
+// (and not by the manifest) are not drift, server defaulting is not drift.
+func (s *k8sService) DetectDrift(ctx context.Context, cluster string, yamlContent string) ([]models.ResourceDrift, error) {
+	diffs, err := s.DiffManifest(ctx, cluster, yamlContent)
+	if err != nil {
+		return nil, err
+	}
+
+	drifts := make([]models.ResourceDrift, 0, len(diffs))
+	for _, d := range diffs {
+		drift := models.ResourceDrift{Kind: d.Kind, Name: d.Name, Namespace: d.Namespace, Error: d.Error}
+		switch d.Action {
+		case "create":
+			drift.Status = "missing"
+		case "change":
+			drift.Status = "drifted"
+			// diffObject reports live → desired; drift reads stored → live
+			for _, c := range d.Changes {
+				drift.Changes = append(drift.Changes, models.FieldChange{Path: c.Path, Before: c.After, After: c.Before})
+			}
+		case "unchanged":
+			drift.Status = "in_sync"
+		default:
+			drift.Status = "error"
+		}
+		drifts = append(drifts, drift)
+	}
+	return drifts, nil
+}
+
+// AdoptLive rewrites a stored manifest from the live cluster state: every field the
+// manifest sets takes its live value, and objects deleted from the cluster are dropped.
+// Fields the manifest does not set are not copied, so the result stays as small as the
+// original and does not claim fields owned by other managers.
+func (s *k8sService) AdoptLive(ctx context.Context, cluster string, yamlContent string) (string, error) {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return "", err
+	}
+	if cc.dynClient == nil {
+		return "", fmt.Errorf("cluster %q has no dynamic client", cluster)
+	}
+
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return "", err
+	}
+
+	var docs []string
+	for _, obj := range objs {
+		dr, err := s.resourceFor(cc, obj)
+		if err != nil {
+			return "", err
+		}
+		live, err := dr.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("getting live %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+
+		adopted := projectLive(obj.Object, normalizeForDiff(live.Object))
+		data, err := sigyaml.Marshal(adopted)
+		if err != nil {
+			return "", fmt.Errorf("encoding %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		docs = append(docs, string(data))
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
+// projectLive returns the live value reshaped to the fields present in desired. Lists
+// of named items (containers, ports, env, ...) are matched by name; other lists are
+// projected element-wise when their lengths agree and taken from live otherwise.
+func projectLive(desired, live interface{}) interface{} {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		lm, ok := live.(map[string]interface{})
+		if !ok {
+			return runtime.DeepCopyJSONValue(live)
+		}
+		out := make(map[string]interface{}, len(d))
+		for k, dv := range d {
+			if lv, ok := lm[k]; ok {
+				out[k] = projectLive(dv, lv)
+			}
+		}
+		return out
+
+	case []interface{}:
+		ll, ok := live.([]interface{})
+		if !ok {
+			return runtime.DeepCopyJSONValue(live)
+		}
+		if byName, ok := namedItems(d); ok {
+			out := make([]interface{}, 0, len(ll))
+			for _, item := range ll {
+				m, _ := item.(map[string]interface{})
+				name, _ := m["name"].(string)
+				if dv, found := byName[name]; found {
+					out = append(out, projectLive(dv, item))
+				}
+			}
+			return out
+		}
+		if len(d) == len(ll) {
+			out := make([]interface{}, len(ll))
+			for i := range ll {
+				out[i] = projectLive(d[i], ll[i])
+			}
+			return out
+		}
+		return runtime.DeepCopyJSONValue(ll)
+
+	default:
+		if live == nil {
+			return nil
+		}
+		return runtime.DeepCopyJSONValue(live)
+	}
+}
+
+// namedItems indexes a list by its items' "name" field, if every item is a named map.
+func namedItems(list []interface{}) (map[string]interface{}, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	byName := make(map[string]interface{}, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok || name == "" {
+			return nil, false
+		}
+		byName[name] = item
+	}
+	return byName, true
+}
@@ -0,0 +1,116 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	sigyaml "sigs.k8s.io/yaml"
+)
+
+// EditManifest applies a patch edit to a single-object manifest and returns the result
+// as YAML. Replace edits are handled by the caller; the result of either still has to
+// pass ValidateManifest.
+//   - json: RFC 6902 JSON Patch operations
+//   - merge: RFC 7386 JSON Merge Patch
+//   - strategic: strategic merge patch of the object's built-in kind, merging lists such
+//     as containers and env by their merge keys
+func EditManifest(yamlContent, patchType, patch string) (string, error) {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return "", err
+	}
+	if len(objs) != 1 {
+		return "", fmt.Errorf("patches need a manifest with one object, found %d", len(objs))
+	}
+	original, err := objs[0].MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	patchJSON, err := sigyaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return "", fmt.Errorf("decoding patch: %w", err)
+	}
+
+	var patched []byte
+	switch patchType {
+	case models.EditJSONPatch:
+		ops, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return "", fmt.Errorf("decoding JSON patch: %w", err)
+		}
+		if patched, err = ops.Apply(original); err != nil {
+			return "", fmt.Errorf("applying JSON patch: %w", err)
+		}
+	case models.EditMergePatch:
+		if patched, err = jsonpatch.MergePatch(original, patchJSON); err != nil {
+			return "", fmt.Errorf("applying merge patch: %w", err)
+		}
+	case models.EditStrategicMerge:
+		typed, err := scheme.Scheme.New(objs[0].GroupVersionKind())
+		if err != nil {
+			return "", fmt.Errorf("strategic merge needs a built-in kind, %s is not: use a merge patch", objs[0].GetKind())
+		}
+		if patched, err = strategicpatch.StrategicMergePatch(original, patchJSON, typed); err != nil {
+			return "", fmt.Errorf("applying strategic merge patch: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("unknown patch type %q", patchType)
+	}
+
+	out, err := sigyaml.JSONToYAML(patched)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// ValidateManifest checks that every object of a manifest has apiVersion, kind and
+// metadata.name and, for built-in kinds, matches the Kubernetes schema: no unknown
+// fields and no values of the wrong type. It returns the objects' kinds.
+func ValidateManifest(yamlContent string) ([]string, error) {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("manifest has no objects")
+	}
+
+	kinds := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		ref := obj.GetKind() + "/" + obj.GetName()
+		switch {
+		case obj.GetAPIVersion() == "":
+			return nil, fmt.Errorf("%s: apiVersion is required", ref)
+		case obj.GetKind() == "":
+			return nil, fmt.Errorf("object %q: kind is required", obj.GetName())
+		case obj.GetName() == "":
+			return nil, fmt.Errorf("%s: metadata.name is required", obj.GetKind())
+		}
+		if err := validateSchema(obj); err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		kinds = append(kinds, obj.GetKind())
+	}
+	return kinds, nil
+}
+
+// validateSchema converts an object of a built-in kind to its typed form, which fails
+// on unknown fields and mistyped values. Custom resources are not checked.
+func validateSchema(obj *unstructured.Unstructured) error {
+	typed, err := scheme.Scheme.New(obj.GroupVersionKind())
+	if err != nil {
+		return nil
+	}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructuredWithValidation(obj.Object, typed, true)
+	if err != nil {
+		return fmt.Errorf("schema: %s", strings.TrimPrefix(err.Error(), "strict decoding error: "))
+	}
+	return nil
+}
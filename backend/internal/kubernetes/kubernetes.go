@@ -14,7 +14,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/dynamic"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/restmapper"
@@ -37,8 +36,31 @@ type Service interface {
 	DeleteService(ctx context.Context, cluster, namespace, name string) error
 
 	// Generic resource operations (dynamic client)
-	ApplyManifest(ctx context.Context, cluster string, yamlContent string) error
+	ApplyManifest(ctx context.Context, cluster string, yamlContent string) ([]models.AppliedResource, error)
 	DeleteResource(ctx context.Context, cluster, kind, namespace, name string) error
+	DiffManifest(ctx context.Context, cluster string, yamlContent string) ([]models.ResourceDiff, error)
+	DiffManifestSet(ctx context.Context, cluster string, manifests []string) ([][]models.ResourceDiff, error)
+	SnapshotManifest(ctx context.Context, cluster string, yamlContent string) ([]models.ResourceSnapshot, error)
+	RestoreSnapshot(ctx context.Context, cluster string, snap models.ResourceSnapshot) error
+	DetectDrift(ctx context.Context, cluster string, yamlContent string) ([]models.ResourceDrift, error)
+	AdoptLive(ctx context.Context, cluster string, yamlContent string) (string, error)
+
+	// Ownership (objects labelled by StampOwnership)
+	ListOwned(ctx context.Context, cluster string, kinds []string) ([]models.OwnedResource, error)
+	DeleteOwned(ctx context.Context, cluster string, owner models.Ownership, kinds []string) ([]models.AppliedResource, error)
+
+	// Rollout management (Deployment, StatefulSet, DaemonSet)
+	RolloutStatus(ctx context.Context, cluster, namespace, kind, name string) (*models.RolloutStatus, error)
+	RolloutHistory(ctx context.Context, cluster, namespace, kind, name string) ([]models.RolloutRevision, error)
+	UndoRollout(ctx context.Context, cluster, namespace, kind, name string, revision int64) error
+	PauseRollout(ctx context.Context, cluster, namespace, kind, name string) error
+	ResumeRollout(ctx context.Context, cluster, namespace, kind, name string) error
+	RestartRollout(ctx context.Context, cluster, namespace, kind, name string) error
+	WaitForRollout(ctx context.Context, cluster, namespace, kind, name string) (*models.RolloutStatus, error)
+	ServiceEndpoint(ctx context.Context, cluster, namespace, name string) (string, error)
+
+	// Usage sampling for the post-deploy feedback job
+	SampleUsage(ctx context.Context, cluster, namespace, labelSelector string) (*models.UsageSample, error)
 
 	// Cluster management
 	ListKubeContexts(kubeconfigPath string) ([]models.KubeContext, error)
@@ -489,63 +511,101 @@ var knownGVR = map[string]schema.GroupVersionResource{
 	"Secret":                   {Group: "", Version: "v1", Resource: "secrets"},
 	"Service":                  {Group: "", Version: "v1", Resource: "services"},
 	"PersistentVolumeClaim":    {Group: "", Version: "v1", Resource: "persistentvolumeclaims"},
+	"ServiceAccount":           {Group: "", Version: "v1", Resource: "serviceaccounts"},
 	"Deployment":               {Group: "apps", Version: "v1", Resource: "deployments"},
 	"StatefulSet":              {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	"DaemonSet":                {Group: "apps", Version: "v1", Resource: "daemonsets"},
+	"Job":                      {Group: "batch", Version: "v1", Resource: "jobs"},
+	"CronJob":                  {Group: "batch", Version: "v1", Resource: "cronjobs"},
+	"Role":                     {Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "roles"},
+	"RoleBinding":              {Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"},
 	"HorizontalPodAutoscaler": {Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"},
+	"PodDisruptionBudget":      {Group: "policy", Version: "v1", Resource: "poddisruptionbudgets"},
 	"Ingress":                  {Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"},
+	"NetworkPolicy":            {Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"},
 }
 
 // ApplyManifest applies a raw YAML manifest to the specified cluster using Server-Side Apply.
-func (s *k8sService) ApplyManifest(ctx context.Context, cluster string, yamlContent string) error {
+// The manifest may contain several "---" separated documents and List kinds; objects are
+// applied in dependency order (see kindApplyPriority) and a result is returned for each.
+// Applying stops at the first failure; the remaining objects are reported as "skipped".
+func (s *k8sService) ApplyManifest(ctx context.Context, cluster string, yamlContent string) ([]models.AppliedResource, error) {
 	cc, err := s.getClient(cluster)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if cc.dynClient == nil {
-		return fmt.Errorf("cluster %q has no dynamic client", cluster)
+		return nil, fmt.Errorf("cluster %q has no dynamic client", cluster)
 	}
 
-	// Decode YAML to unstructured object
-	obj := &unstructured.Unstructured{}
-	dec := yamlutil.NewYAMLOrJSONDecoder(strings.NewReader(yamlContent), 4096)
-	if err := dec.Decode(obj); err != nil {
-		return fmt.Errorf("decoding YAML: %w", err)
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return nil, err
 	}
 
-	gvk := obj.GroupVersionKind()
-	if gvk.Kind == "" {
-		return fmt.Errorf("manifest has no kind")
+	results := make([]models.AppliedResource, 0, len(objs))
+	var applyErr error
+	for _, obj := range objs {
+		res := models.AppliedResource{Kind: obj.GetKind(), Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		if applyErr != nil {
+			res.Status = "skipped"
+			results = append(results, res)
+			continue
+		}
+
+		dr, err := s.resourceFor(cc, obj)
+		if err == nil {
+			res.Namespace = obj.GetNamespace()
+			_, err = s.applyObject(ctx, dr, obj, false)
+		}
+		if err != nil {
+			res.Status = "failed"
+			res.Error = err.Error()
+			applyErr = err
+		} else {
+			res.Status = "applied"
+		}
+		results = append(results, res)
 	}
 
-	// Resolve GVR
-	gvr, namespaced, err := s.resolveGVR(cc, gvk)
+	return results, applyErr
+}
+
+// resourceFor resolves the dynamic resource interface for an object.
+// Namespaced objects without a namespace are defaulted to "default".
+func (s *k8sService) resourceFor(cc *clusterClient, obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvr, namespaced, err := s.resolveGVR(cc, obj.GroupVersionKind())
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Build resource interface
-	var dr dynamic.ResourceInterface
-	if namespaced {
-		ns := obj.GetNamespace()
-		if ns == "" {
-			ns = "default"
-		}
-		dr = cc.dynClient.Resource(gvr).Namespace(ns)
-	} else {
-		dr = cc.dynClient.Resource(gvr)
+	if !namespaced {
+		return cc.dynClient.Resource(gvr), nil
 	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace("default")
+	}
+	return cc.dynClient.Resource(gvr).Namespace(obj.GetNamespace()), nil
+}
 
-	// Server-Side Apply (idempotent — works for both create and update)
+// applyObject performs a forced Server-Side Apply (idempotent — works for both create and update).
+// With dryRun set, the request is fully validated and admitted by the API server but not persisted.
+func (s *k8sService) applyObject(ctx context.Context, dr dynamic.ResourceInterface, obj *unstructured.Unstructured, dryRun bool) (*unstructured.Unstructured, error) {
 	obj.SetManagedFields(nil)
-	_, err = dr.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{
+	opts := metav1.ApplyOptions{
 		FieldManager: "hybrid-cloud-dashboard",
 		Force:        true,
-	})
+	}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	result, err := dr.Apply(ctx, obj.GetName(), obj, opts)
 	if err != nil {
-		return fmt.Errorf("applying %s %q: %w", gvk.Kind, obj.GetName(), err)
+		if dryRun {
+			return nil, fmt.Errorf("dry-run applying %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		return nil, fmt.Errorf("applying %s %q: %w", obj.GetKind(), obj.GetName(), err)
 	}
-
-	return nil
+	return result, nil
 }
 
 // DeleteResource deletes any K8s resource by kind, namespace, and name.
@@ -558,21 +618,9 @@ func (s *k8sService) DeleteResource(ctx context.Context, cluster, kind, namespac
 		return fmt.Errorf("cluster %q has no dynamic client", cluster)
 	}
 
-	gvr, ok := knownGVR[kind]
-	if !ok {
-		// Also handle the "HPA" alias used in manifest maps
-		if kind == "HPA" {
-			gvr = knownGVR["HorizontalPodAutoscaler"]
-		} else if cc.mapper != nil {
-			gvk := schema.GroupKind{Kind: kind}
-			mappings, mapErr := cc.mapper.RESTMappings(gvk)
-			if mapErr != nil || len(mappings) == 0 {
-				return fmt.Errorf("no mapping found for kind %q: %v", kind, mapErr)
-			}
-			gvr = mappings[0].Resource
-		} else {
-			return fmt.Errorf("unknown kind %q and no REST mapper available", kind)
-		}
+	gvr, err := s.kindGVR(cc, kind)
+	if err != nil {
+		return err
 	}
 
 	var dr dynamic.ResourceInterface
@@ -0,0 +1,137 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// kindApplyPriority orders kinds so that dependencies exist before their dependents:
+// Namespace, CRDs, config/RBAC, storage, workloads, services, ingress. Kinds not listed
+// (HPA, PDB, custom resources, ...) are applied last.
+var kindApplyPriority = map[string]int{
+	"Namespace":                0,
+	"CustomResourceDefinition": 1,
+
+	"ServiceAccount":     2,
+	"Role":               2,
+	"ClusterRole":        2,
+	"RoleBinding":        2,
+	"ClusterRoleBinding": 2,
+	"ConfigMap":          2,
+	"Secret":             2,
+
+	"StorageClass":          3,
+	"PersistentVolume":      3,
+	"PersistentVolumeClaim": 3,
+
+	"Deployment":  4,
+	"StatefulSet": 4,
+	"DaemonSet":   4,
+	"ReplicaSet":  4,
+	"Job":         4,
+	"CronJob":     4,
+	"Pod":         4,
+
+	"Service": 5,
+
+	"Ingress":       6,
+	"IngressClass":  6,
+	"NetworkPolicy": 6,
+}
+
+const defaultApplyPriority = 7
+
+// decodeManifests decodes every document of a YAML (or JSON) stream, expanding
+// List kinds into their items, and returns the objects in dependency-safe apply order.
+func decodeManifests(yamlContent string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	dec := yamlutil.NewYAMLOrJSONDecoder(strings.NewReader(yamlContent), 4096)
+	for doc := 1; ; doc++ {
+		obj := &unstructured.Unstructured{}
+		if err := dec.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("decoding YAML document %d: %w", doc, err)
+		}
+		if len(obj.Object) == 0 {
+			continue // empty document, e.g. a trailing "---"
+		}
+
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, fmt.Errorf("expanding %s in document %d: %w", obj.GetKind(), doc, err)
+			}
+			for i := range list.Items {
+				item := &list.Items[i]
+				if item.GetKind() == "" {
+					return nil, fmt.Errorf("item %d of %s in document %d has no kind", i, obj.GetKind(), doc)
+				}
+				objs = append(objs, item)
+			}
+			continue
+		}
+
+		if obj.GetKind() == "" {
+			return nil, fmt.Errorf("manifest document %d has no kind", doc)
+		}
+		objs = append(objs, obj)
+	}
+
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("manifest contains no objects")
+	}
+
+	sortForApply(objs)
+	return objs, nil
+}
+
+// sortForApply stably sorts objects by kindApplyPriority, keeping the
+// original document order within the same priority.
+func sortForApply(objs []*unstructured.Unstructured) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		return applyPriority(objs[i].GetKind()) < applyPriority(objs[j].GetKind())
+	})
+}
+
+func applyPriority(kind string) int {
+	if p, ok := kindApplyPriority[kind]; ok {
+		return p
+	}
+	return defaultApplyPriority
+}
+
+// ApplyRank returns the apply priority of the first object ApplyManifest applies from a
+// manifest, so that manifests applied one by one follow the same order as the objects
+// within one. Manifests that fail to decode rank last.
+func ApplyRank(yamlContent string) int {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return defaultApplyPriority
+	}
+	return applyPriority(objs[0].GetKind())
+}
+
+// ManifestKinds returns the distinct kinds in a manifest, in apply order. Manifests that
+// fail to decode yield no kinds.
+func ManifestKinds(yamlContent string) []string {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return nil
+	}
+	var kinds []string
+	seen := map[string]bool{}
+	for _, obj := range objs {
+		if !seen[obj.GetKind()] {
+			seen[obj.GetKind()] = true
+			kinds = append(kinds, obj.GetKind())
+		}
+	}
+	return kinds
+}
@@ -0,0 +1,123 @@
+package kubernetes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeManifests(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []string // Kind/name in apply order
+		wantErr string
+	}{
+		{
+			name: "orders by kind priority",
+			yaml: "apiVersion: autoscaling/v2\nkind: HorizontalPodAutoscaler\nmetadata: {name: web}\n---\n" +
+				"apiVersion: v1\nkind: Service\nmetadata: {name: web}\n---\n" +
+				"apiVersion: apps/v1\nkind: Deployment\nmetadata: {name: web}\n---\n" +
+				"apiVersion: v1\nkind: ConfigMap\nmetadata: {name: web-config}\n---\n" +
+				"apiVersion: v1\nkind: Namespace\nmetadata: {name: shop}\n",
+			want: []string{"Namespace/shop", "ConfigMap/web-config", "Deployment/web", "Service/web", "HorizontalPodAutoscaler/web"},
+		},
+		{
+			name: "keeps document order within a priority",
+			yaml: "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: b}\n---\n" +
+				"apiVersion: v1\nkind: Secret\nmetadata: {name: a}\n---\n" +
+				"apiVersion: v1\nkind: ConfigMap\nmetadata: {name: a}\n",
+			want: []string{"ConfigMap/b", "Secret/a", "ConfigMap/a"},
+		},
+		{
+			name: "expands Lists",
+			yaml: "apiVersion: v1\nkind: List\nitems:\n" +
+				"- apiVersion: v1\n  kind: Service\n  metadata: {name: web}\n" +
+				"- apiVersion: apps/v1\n  kind: Deployment\n  metadata: {name: web}\n" +
+				"---\napiVersion: v1\nkind: ConfigMap\nmetadata: {name: web-config}\n",
+			want: []string{"ConfigMap/web-config", "Deployment/web", "Service/web"},
+		},
+		{
+			name: "sorts items of several Lists together",
+			yaml: "apiVersion: v1\nkind: ServiceList\nitems:\n- apiVersion: v1\n  kind: Service\n  metadata: {name: api}\n" +
+				"---\napiVersion: v1\nkind: List\nitems:\n- apiVersion: networking.k8s.io/v1\n  kind: Ingress\n  metadata: {name: api}\n" +
+				"- apiVersion: v1\n  kind: PersistentVolumeClaim\n  metadata: {name: data}\n",
+			want: []string{"PersistentVolumeClaim/data", "Service/api", "Ingress/api"},
+		},
+		{
+			name: "skips empty documents",
+			yaml: "---\napiVersion: v1\nkind: ConfigMap\nmetadata: {name: a}\n---\n",
+			want: []string{"ConfigMap/a"},
+		},
+		{
+			name:    "document without a kind",
+			yaml:    "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: a}\n---\napiVersion: v1\nmetadata: {name: b}\n",
+			wantErr: "manifest document 2 has no kind",
+		},
+		{
+			name:    "List item without a kind",
+			yaml:    "apiVersion: v1\nkind: List\nitems:\n- apiVersion: v1\n  metadata: {name: a}\n",
+			wantErr: "item 0 of List in document 1 has no kind",
+		},
+		{
+			name:    "no objects",
+			yaml:    "---\n",
+			wantErr: "manifest contains no objects",
+		},
+		{
+			name:    "invalid YAML",
+			yaml:    "kind: [Service\n",
+			wantErr: "decoding YAML document 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, err := decodeManifests(tt.yaml)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeManifests: %v", err)
+			}
+			var got []string
+			for _, obj := range objs {
+				got = append(got, obj.GetKind()+"/"+obj.GetName())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRankAndManifestKinds(t *testing.T) {
+	tests := []struct {
+		name      string
+		yaml      string
+		wantRank  int
+		wantKinds []string
+	}{
+		{"config", "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: a}\n", 2, []string{"ConfigMap"}},
+		{
+			"first object after sorting",
+			"apiVersion: v1\nkind: Service\nmetadata: {name: a}\n---\napiVersion: apps/v1\nkind: Deployment\nmetadata: {name: a}\n---\napiVersion: apps/v1\nkind: Deployment\nmetadata: {name: b}\n",
+			4, []string{"Deployment", "Service"},
+		},
+		{"unlisted kind", "apiVersion: policy/v1\nkind: PodDisruptionBudget\nmetadata: {name: a}\n", defaultApplyPriority, []string{"PodDisruptionBudget"}},
+		{"empty", "", defaultApplyPriority, nil},
+		{"invalid", "kind: [", defaultApplyPriority, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ApplyRank(tt.yaml); got != tt.wantRank {
+				t.Errorf("ApplyRank = %d, want %d", got, tt.wantRank)
+			}
+			if got := ManifestKinds(tt.yaml); !reflect.DeepEqual(got, tt.wantKinds) {
+				t.Errorf("ManifestKinds = %v, want %v", got, tt.wantKinds)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// clusterScopedKinds are the kinds a namespace override leaves alone.
+var clusterScopedKinds = map[string]bool{
+	"Namespace":                true,
+	"CustomResourceDefinition": true,
+	"ClusterRole":              true,
+	"ClusterRoleBinding":       true,
+	"StorageClass":             true,
+	"PersistentVolume":         true,
+	"IngressClass":             true,
+	"PriorityClass":            true,
+}
+
+// podTemplatePaths locate the pod spec of each workload kind.
+var podTemplatePaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// ApplyOverrides rewrites a manifest for one target of a multi-cluster deploy. Empty
+// overrides leave the manifest untouched; otherwise every document is re-encoded.
+//   - Namespace moves namespaced objects (and a Namespace object) to the namespace.
+//   - Replicas sets the replicas of Deployments and StatefulSets.
+//   - Registry replaces the registry of every container image.
+//   - IngressHost sets the host of every Ingress rule and TLS entry.
+func ApplyOverrides(yamlContent string, o models.ManifestOverrides) (string, error) {
+	if o == (models.ManifestOverrides{}) {
+		return yamlContent, nil
+	}
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return "", err
+	}
+
+	for _, obj := range objs {
+		if err := overrideObject(obj, o); err != nil {
+			return "", fmt.Errorf("overriding %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+	return encodeManifests(objs)
+}
+
+func overrideObject(obj *unstructured.Unstructured, o models.ManifestOverrides) error {
+	kind := obj.GetKind()
+
+	if o.Namespace != "" {
+		if kind == "Namespace" {
+			obj.SetName(o.Namespace)
+		} else if !clusterScopedKinds[kind] {
+			obj.SetNamespace(o.Namespace)
+		}
+	}
+
+	if o.Replicas > 0 && (kind == "Deployment" || kind == "StatefulSet") {
+		if err := unstructured.SetNestedField(obj.Object, int64(o.Replicas), "spec", "replicas"); err != nil {
+			return err
+		}
+	}
+
+	if o.Registry != "" {
+		if path, ok := podTemplatePaths[kind]; ok {
+			for _, field := range []string{"initContainers", "containers"} {
+				if err := rewriteImages(obj, append(append([]string{}, path...), field), o.Registry); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if o.IngressHost != "" && kind == "Ingress" {
+		if err := overrideIngressHost(obj, o.IngressHost); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteImages moves the image of every container at path to registry.
+func rewriteImages(obj *unstructured.Unstructured, path []string, registry string) error {
+	containers, found, err := unstructured.NestedSlice(obj.Object, path...)
+	if err != nil || !found {
+		return err
+	}
+	for i, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if image, ok := container["image"].(string); ok && image != "" {
+			container["image"] = WithRegistry(image, registry)
+			containers[i] = container
+		}
+	}
+	return unstructured.SetNestedSlice(obj.Object, containers, path...)
+}
+
+// ManifestImages returns the container images of every workload in a manifest, in
+// document order without duplicates.
+func ManifestImages(yamlContent string) ([]string, error) {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return nil, err
+	}
+	var images []string
+	seen := map[string]bool{}
+	for _, obj := range objs {
+		path, ok := podTemplatePaths[obj.GetKind()]
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"initContainers", "containers"} {
+			containers, _, _ := unstructured.NestedSlice(obj.Object, append(append([]string{}, path...), field)...)
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if image, ok := container["image"].(string); ok && image != "" && !seen[image] {
+					seen[image] = true
+					images = append(images, image)
+				}
+			}
+		}
+	}
+	return images, nil
+}
+
+// WithRegistry replaces the registry host of an image reference. References without
+// a registry host (Docker Hub) get the registry prepended.
+func WithRegistry(image, registry string) string {
+	registry = strings.TrimSuffix(registry, "/")
+	if first, rest, ok := strings.Cut(image, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		image = rest
+	}
+	return registry + "/" + image
+}
+
+func overrideIngressHost(obj *unstructured.Unstructured, host string) error {
+	rules, found, err := unstructured.NestedSlice(obj.Object, "spec", "rules")
+	if err != nil {
+		return err
+	}
+	if found {
+		for i, r := range rules {
+			if rule, ok := r.(map[string]interface{}); ok {
+				rule["host"] = host
+				rules[i] = rule
+			}
+		}
+		if err := unstructured.SetNestedSlice(obj.Object, rules, "spec", "rules"); err != nil {
+			return err
+		}
+	}
+
+	tls, found, err := unstructured.NestedSlice(obj.Object, "spec", "tls")
+	if err != nil || !found {
+		return err
+	}
+	for i, t := range tls {
+		if entry, ok := t.(map[string]interface{}); ok {
+			entry["hosts"] = []interface{}{host}
+			tls[i] = entry
+		}
+	}
+	return unstructured.SetNestedSlice(obj.Object, tls, "spec", "tls")
+}
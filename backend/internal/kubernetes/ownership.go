@@ -0,0 +1,313 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	sigyaml "sigs.k8s.io/yaml"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// Ownership labels and annotations stamped on every object the dashboard applies.
+const (
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	LabelDeployID  = "hybrid-cloud-dashboard/deploy-id"
+	LabelStackID   = "hybrid-cloud-dashboard/stack-id"
+	LabelService   = "hybrid-cloud-dashboard/service"
+
+	AnnotationSourceContainer = "hybrid-cloud-dashboard/source-container"
+	AnnotationSourceImage     = "hybrid-cloud-dashboard/source-image"
+	AnnotationSourceDigest    = "hybrid-cloud-dashboard/source-digest"
+
+	ManagedByValue = "hybrid-cloud-dashboard"
+)
+
+// defaultOwnedKinds are always scanned by ListOwned and DeleteOwned, in addition to any
+// kinds the caller passes. Kinds the cluster does not serve are skipped.
+var defaultOwnedKinds = []string{
+	"ConfigMap", "Secret", "ServiceAccount", "Role", "RoleBinding",
+	"PersistentVolumeClaim",
+	"Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob",
+	"Service",
+	"Ingress", "NetworkPolicy",
+	"HorizontalPodAutoscaler", "PodDisruptionBudget",
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// labelValue coerces s into a valid label value (63 chars, alphanumeric at both ends).
+func labelValue(s string) string {
+	v := invalidLabelChars.ReplaceAllString(s, "-")
+	if len(v) > 63 {
+		v = v[:63]
+	}
+	return strings.Trim(v, "-_.")
+}
+
+// StampOwnership adds the ownership labels and annotations to every object in the
+// manifest and re-encodes it as a multi-document YAML stream. Namespaces are left
+// unlabelled: they are often shared, and a label-based undeploy must never remove one.
+// Pod templates are not touched so re-stamping with a new deploy ID does not roll pods.
+func StampOwnership(yamlContent string, owner models.Ownership) (string, error) {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return "", err
+	}
+
+	docs := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		if obj.GetKind() != "Namespace" {
+			stampObject(obj, owner)
+		}
+		data, err := sigyaml.Marshal(obj.Object)
+		if err != nil {
+			return "", fmt.Errorf("encoding %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		docs = append(docs, string(data))
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
+// StripOwnership removes the ownership labels and annotations from every object in the
+// manifest, for manifests that leave the dashboard (exports). Pod templates are never
+// stamped, so only object metadata is touched.
+func StripOwnership(yamlContent string) (string, error) {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return "", err
+	}
+
+	docs := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		lbls := obj.GetLabels()
+		if lbls[LabelManagedBy] == ManagedByValue {
+			delete(lbls, LabelManagedBy)
+		}
+		for _, key := range []string{LabelDeployID, LabelStackID, LabelService} {
+			delete(lbls, key)
+		}
+		if len(lbls) == 0 {
+			lbls = nil
+		}
+		obj.SetLabels(lbls)
+
+		annotations := obj.GetAnnotations()
+		for _, key := range []string{AnnotationSourceContainer, AnnotationSourceImage, AnnotationSourceDigest} {
+			delete(annotations, key)
+		}
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		obj.SetAnnotations(annotations)
+
+		data, err := sigyaml.Marshal(obj.Object)
+		if err != nil {
+			return "", fmt.Errorf("encoding %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		docs = append(docs, string(data))
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
+func stampObject(obj *unstructured.Unstructured, owner models.Ownership) {
+	lbls := obj.GetLabels()
+	if lbls == nil {
+		lbls = map[string]string{}
+	}
+	lbls[LabelManagedBy] = ManagedByValue
+	if owner.DeployID != "" {
+		lbls[LabelDeployID] = labelValue(owner.DeployID)
+	}
+	if owner.StackID != "" {
+		lbls[LabelStackID] = labelValue(owner.StackID)
+	}
+	if owner.Service != "" {
+		lbls[LabelService] = labelValue(owner.Service)
+	}
+	obj.SetLabels(lbls)
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	for key, val := range map[string]string{
+		AnnotationSourceContainer: owner.SourceContainer,
+		AnnotationSourceImage:     owner.SourceImage,
+		AnnotationSourceDigest:    owner.SourceDigest,
+	} {
+		if val != "" {
+			annotations[key] = val
+		}
+	}
+	if len(annotations) > 0 {
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// ownershipOf reads the ownership labels and annotations back from an object.
+func ownershipOf(obj *unstructured.Unstructured) models.Ownership {
+	lbls := obj.GetLabels()
+	annotations := obj.GetAnnotations()
+	return models.Ownership{
+		DeployID:        lbls[LabelDeployID],
+		StackID:         lbls[LabelStackID],
+		Service:         lbls[LabelService],
+		SourceContainer: annotations[AnnotationSourceContainer],
+		SourceImage:     annotations[AnnotationSourceImage],
+		SourceDigest:    annotations[AnnotationSourceDigest],
+	}
+}
+
+// ownerSelector builds the label selector matching the objects of one deploy.
+func ownerSelector(owner models.Ownership) (string, error) {
+	set := labels.Set{LabelManagedBy: ManagedByValue}
+	switch {
+	case owner.DeployID != "":
+		set[LabelDeployID] = labelValue(owner.DeployID)
+	case owner.StackID != "":
+		set[LabelStackID] = labelValue(owner.StackID)
+		if owner.Service != "" {
+			set[LabelService] = labelValue(owner.Service)
+		}
+	default:
+		return "", fmt.Errorf("ownership needs a deploy ID or stack ID")
+	}
+	return labels.SelectorFromSet(set).String(), nil
+}
+
+// ListOwned lists every object labelled as managed by the dashboard, across all
+// namespaces, for defaultOwnedKinds plus the given kinds.
+func (s *k8sService) ListOwned(ctx context.Context, cluster string, kinds []string) ([]models.OwnedResource, error) {
+	selector := labels.SelectorFromSet(labels.Set{LabelManagedBy: ManagedByValue}).String()
+	objs, err := s.listLabelled(ctx, cluster, kinds, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make([]models.OwnedResource, 0, len(objs))
+	for _, obj := range objs {
+		owned = append(owned, models.OwnedResource{
+			Cluster:   cluster,
+			Kind:      obj.GetKind(),
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Owner:     ownershipOf(obj),
+			CreatedAt: obj.GetCreationTimestamp().Time,
+		})
+	}
+	return owned, nil
+}
+
+// DeleteOwned deletes every object labelled with the given deploy (or stack service)
+// ownership, in reverse apply order, and reports one entry per object. kinds should
+// list the kinds the deploy applied so that kinds outside defaultOwnedKinds are found.
+func (s *k8sService) DeleteOwned(ctx context.Context, cluster string, owner models.Ownership, kinds []string) ([]models.AppliedResource, error) {
+	selector, err := ownerSelector(owner)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := s.listLabelled(ctx, cluster, kinds, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dependents first: ingress → services → workloads → config
+	sort.SliceStable(objs, func(i, j int) bool {
+		return applyPriority(objs[i].GetKind()) > applyPriority(objs[j].GetKind())
+	})
+
+	results := make([]models.AppliedResource, 0, len(objs))
+	var firstErr error
+	for _, obj := range objs {
+		res := models.AppliedResource{Kind: obj.GetKind(), Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		dr, err := s.resourceFor(cc, obj)
+		if err == nil {
+			err = dr.Delete(ctx, obj.GetName(), metav1.DeleteOptions{})
+			if errors.IsNotFound(err) {
+				err = nil
+			}
+		}
+		if err != nil {
+			res.Status = "failed"
+			res.Error = err.Error()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("deleting %s %q: %w", obj.GetKind(), obj.GetName(), err)
+			}
+		} else {
+			res.Status = "deleted"
+		}
+		results = append(results, res)
+	}
+	return results, firstErr
+}
+
+// listLabelled lists objects matching selector in all namespaces. Kinds the cluster
+// cannot map are skipped; list failures of a known kind are returned.
+func (s *k8sService) listLabelled(ctx context.Context, cluster string, kinds []string, selector string) ([]*unstructured.Unstructured, error) {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if cc.dynClient == nil {
+		return nil, fmt.Errorf("cluster %q has no dynamic client", cluster)
+	}
+	kinds = append(append([]string{}, defaultOwnedKinds...), kinds...)
+
+	var objs []*unstructured.Unstructured
+	seen := map[schema.GroupVersionResource]bool{}
+	for _, kind := range kinds {
+		gvr, err := s.kindGVR(cc, kind)
+		if err != nil {
+			continue // kind not served by this cluster
+		}
+		if seen[gvr] {
+			continue
+		}
+		seen[gvr] = true
+
+		list, err := cc.dynClient.Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("listing %s: %w", kind, err)
+		}
+		for i := range list.Items {
+			objs = append(objs, &list.Items[i])
+		}
+	}
+	return objs, nil
+}
+
+// kindGVR resolves a bare kind (as stored in manifest maps) to its resource, using
+// knownGVR first and the REST mapper otherwise. "HPA" is accepted as an alias.
+func (s *k8sService) kindGVR(cc *clusterClient, kind string) (schema.GroupVersionResource, error) {
+	if kind == "HPA" {
+		kind = "HorizontalPodAutoscaler"
+	}
+	if gvr, ok := knownGVR[kind]; ok {
+		return gvr, nil
+	}
+	if cc.mapper == nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("unknown kind %q and no REST mapper available", kind)
+	}
+	mappings, err := cc.mapper.RESTMappings(schema.GroupKind{Kind: kind})
+	if err != nil || len(mappings) == 0 {
+		return schema.GroupVersionResource{}, fmt.Errorf("no mapping found for kind %q: %v", kind, err)
+	}
+	return mappings[0].Resource, nil
+}
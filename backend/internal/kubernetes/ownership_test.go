@@ -0,0 +1,183 @@
+package kubernetes
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// labelledObject returns an object carrying the ownership labels of owner.
+func labelledObject(apiVersion, kind, namespace, name string, owner *models.Ownership) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	if owner != nil {
+		stampObject(obj, *owner)
+	}
+	return obj
+}
+
+func TestStampOwnership(t *testing.T) {
+	manifest := "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: shop\n---\n" +
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  labels:\n    app: web\nspec:\n  template:\n    metadata:\n      labels:\n        app: web\n"
+
+	tests := []struct {
+		name            string
+		owner           models.Ownership
+		wantLabels      map[string]string
+		wantAnnotations map[string]string
+	}{
+		{
+			name:  "single deploy",
+			owner: models.Ownership{DeployID: "d1", SourceContainer: "web", SourceImage: "web:1.0"},
+			wantLabels: map[string]string{
+				"app": "web", LabelManagedBy: ManagedByValue, LabelDeployID: "d1",
+			},
+			wantAnnotations: map[string]string{AnnotationSourceContainer: "web", AnnotationSourceImage: "web:1.0"},
+		},
+		{
+			name:  "stack service",
+			owner: models.Ownership{StackID: "s1", Service: "web", SourceDigest: "sha256:abc"},
+			wantLabels: map[string]string{
+				"app": "web", LabelManagedBy: ManagedByValue, LabelStackID: "s1", LabelService: "web",
+			},
+			wantAnnotations: map[string]string{AnnotationSourceDigest: "sha256:abc"},
+		},
+		{
+			name:       "values coerced into label values",
+			owner:      models.Ownership{DeployID: "deploy/with spaces_" + strings.Repeat("x", 70)},
+			wantLabels: map[string]string{"app": "web", LabelManagedBy: ManagedByValue, LabelDeployID: "deploy-with-spaces_" + strings.Repeat("x", 44)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stamped, err := StampOwnership(manifest, tt.owner)
+			if err != nil {
+				t.Fatalf("StampOwnership: %v", err)
+			}
+			objs, err := decodeManifests(stamped)
+			if err != nil || len(objs) != 2 {
+				t.Fatalf("decoding the stamped manifest: %v (%d objects)", err, len(objs))
+			}
+			if ns := objs[0]; ns.GetLabels() != nil || ns.GetAnnotations() != nil {
+				t.Errorf("Namespace should be left unlabelled, got %v %v", ns.GetLabels(), ns.GetAnnotations())
+			}
+			deploy := objs[1]
+			if !reflect.DeepEqual(deploy.GetLabels(), tt.wantLabels) {
+				t.Errorf("labels %v, want %v", deploy.GetLabels(), tt.wantLabels)
+			}
+			if !reflect.DeepEqual(deploy.GetAnnotations(), tt.wantAnnotations) {
+				t.Errorf("annotations %v, want %v", deploy.GetAnnotations(), tt.wantAnnotations)
+			}
+			if got := ownershipOf(deploy); got.DeployID != tt.wantLabels[LabelDeployID] || got.StackID != tt.wantLabels[LabelStackID] {
+				t.Errorf("ownershipOf = %+v", got)
+			}
+			if labels, _, _ := unstructured.NestedStringMap(deploy.Object, "spec", "template", "metadata", "labels"); !reflect.DeepEqual(labels, map[string]string{"app": "web"}) {
+				t.Errorf("pod template labels should not change, got %v", labels)
+			}
+
+			// Stripping restores the original metadata
+			stripped, err := StripOwnership(stamped)
+			if err != nil {
+				t.Fatalf("StripOwnership: %v", err)
+			}
+			objs, _ = decodeManifests(stripped)
+			if !reflect.DeepEqual(objs[1].GetLabels(), map[string]string{"app": "web"}) || objs[1].GetAnnotations() != nil {
+				t.Errorf("stripped metadata %v %v", objs[1].GetLabels(), objs[1].GetAnnotations())
+			}
+		})
+	}
+}
+
+func TestOwnerSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		owner   models.Ownership
+		want    string
+		wantErr bool
+	}{
+		{"deploy", models.Ownership{DeployID: "d1", StackID: "ignored"}, "app.kubernetes.io/managed-by=hybrid-cloud-dashboard,hybrid-cloud-dashboard/deploy-id=d1", false},
+		{"stack", models.Ownership{StackID: "s1"}, "app.kubernetes.io/managed-by=hybrid-cloud-dashboard,hybrid-cloud-dashboard/stack-id=s1", false},
+		{"stack service", models.Ownership{StackID: "s1", Service: "db"}, "app.kubernetes.io/managed-by=hybrid-cloud-dashboard,hybrid-cloud-dashboard/service=db,hybrid-cloud-dashboard/stack-id=s1", false},
+		{"no owner", models.Ownership{Service: "db"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ownerSelector(tt.owner)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ownerSelector = %q, %v; want %q (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListAndDeleteOwned(t *testing.T) {
+	d1 := &models.Ownership{DeployID: "d1", SourceImage: "web:1.0"}
+	svc, dyn := newFakeService(nil,
+		labelledObject("v1", "ConfigMap", "default", "web-config", d1),
+		labelledObject("apps/v1", "Deployment", "default", "web", d1),
+		labelledObject("v1", "Service", "default", "web", d1),
+		labelledObject("apps/v1", "Deployment", "other", "api", &models.Ownership{DeployID: "d2"}),
+		labelledObject("apps/v1", "Deployment", "default", "unmanaged", nil),
+	)
+	ctx := context.Background()
+
+	owned, err := svc.ListOwned(ctx, "test", nil)
+	if err != nil {
+		t.Fatalf("ListOwned: %v", err)
+	}
+	var got []string
+	for _, o := range owned {
+		got = append(got, o.Kind+" "+o.Namespace+"/"+o.Name+" "+o.Owner.DeployID)
+		if o.Cluster != "test" {
+			t.Errorf("%s/%s has cluster %q", o.Kind, o.Name, o.Cluster)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"ConfigMap default/web-config d1", "Deployment default/web d1", "Deployment other/api d2", "Service default/web d1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListOwned = %v, want %v", got, want)
+	}
+
+	deleted, err := svc.DeleteOwned(ctx, "test", models.Ownership{DeployID: "d1"}, nil)
+	if err != nil {
+		t.Fatalf("DeleteOwned: %v", err)
+	}
+	got = nil
+	for _, r := range deleted {
+		got = append(got, r.Kind+"/"+r.Name+" "+r.Status)
+	}
+	want = []string{"Service/web deleted", "Deployment/web deleted", "ConfigMap/web-config deleted"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DeleteOwned = %v, want dependents first: %v", got, want)
+	}
+
+	deployments, err := dyn.Resource(knownGVR["Deployment"]).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("listing deployments: %v", err)
+	}
+	var left []string
+	for _, d := range deployments.Items {
+		left = append(left, d.GetName())
+	}
+	sort.Strings(left)
+	if !reflect.DeepEqual(left, []string{"api", "unmanaged"}) {
+		t.Errorf("expected the objects of other owners to stay, got %v", left)
+	}
+
+	if _, err := svc.DeleteOwned(ctx, "test", models.Ownership{}, nil); err == nil {
+		t.Error("expected an error for an ownership without IDs")
+	}
+	if _, err := svc.ListOwned(ctx, "missing", nil); err == nil {
+		t.Error("expected an error for an unknown cluster")
+	}
+}
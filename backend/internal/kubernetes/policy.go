@@ -0,0 +1,300 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// podWorkload is an object that runs pods from a template.
+type podWorkload struct {
+	ref         string
+	selector    *metav1.LabelSelector // nil for kinds without a selector (Job, CronJob)
+	labels      map[string]string     // pod template labels
+	pod         corev1.PodSpec
+	longRunning bool  // probes apply
+	replicas    int32 // 0 for kinds without replicas (DaemonSet, Job, CronJob)
+}
+
+// CheckPolicy checks the documents of a generated manifest set against the Kubernetes
+// schema and the policy rules, skipping the rules listed in disabled. Service ports are
+// matched against the workloads of all documents, so a stack is checked as a whole.
+// Empty documents are ignored.
+func CheckPolicy(documents []string, disabled []string) []models.PolicyViolation {
+	off := make(map[string]bool, len(disabled))
+	for _, rule := range disabled {
+		off[rule] = true
+	}
+
+	var violations []models.PolicyViolation
+	add := func(rule, ref, format string, args ...interface{}) {
+		if !off[rule] {
+			violations = append(violations, models.PolicyViolation{Rule: rule, Resource: ref, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	objs := decodeDocuments(documents, func(ref string, err error) {
+		add(models.PolicySchema, ref, "%v", err)
+	})
+	workloads, services := podWorkloadsAndServices(objs)
+
+	for _, w := range workloads {
+		checkWorkload(w, add)
+	}
+	for _, svc := range services {
+		checkServicePorts(svc, workloads, add)
+	}
+	return violations
+}
+
+// decodeDocuments decodes the objects of the valid documents and reports the others to
+// invalid. Empty documents are skipped.
+func decodeDocuments(documents []string, invalid func(ref string, err error)) []*unstructured.Unstructured {
+	var objs []*unstructured.Unstructured
+	for _, doc := range documents {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		if _, err := ValidateManifest(doc); err != nil {
+			invalid(manifestRef(doc), err)
+			continue
+		}
+		decoded, _ := decodeManifests(doc)
+		objs = append(objs, decoded...)
+	}
+	return objs
+}
+
+// podWorkloadsAndServices picks the workloads and core Services out of objs.
+func podWorkloadsAndServices(objs []*unstructured.Unstructured) ([]*podWorkload, []*corev1.Service) {
+	var workloads []*podWorkload
+	var services []*corev1.Service
+	for _, obj := range objs {
+		if obj.GetKind() == "Service" && obj.GetAPIVersion() == "v1" {
+			var svc corev1.Service
+			if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &svc) == nil {
+				services = append(services, &svc)
+			}
+			continue
+		}
+		if w := toPodWorkload(obj); w != nil {
+			workloads = append(workloads, w)
+		}
+	}
+	return workloads, services
+}
+
+// manifestRef names the first object of a document that may not decode.
+func manifestRef(doc string) string {
+	objs, err := decodeManifests(doc)
+	if err != nil || len(objs) == 0 {
+		return ""
+	}
+	return objs[0].GetKind() + "/" + objs[0].GetName()
+}
+
+// toPodWorkload returns the pod template of a workload, or nil for other objects.
+func toPodWorkload(obj *unstructured.Unstructured) *podWorkload {
+	ref := obj.GetKind() + "/" + obj.GetName()
+	convert := func(out interface{}) bool {
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, out) == nil
+	}
+	switch obj.GroupVersionKind().GroupKind().String() {
+	case "Deployment.apps":
+		var d appsv1.Deployment
+		if convert(&d) {
+			return &podWorkload{ref, d.Spec.Selector, d.Spec.Template.Labels, d.Spec.Template.Spec, true, replicaCount(d.Spec.Replicas)}
+		}
+	case "StatefulSet.apps":
+		var d appsv1.StatefulSet
+		if convert(&d) {
+			return &podWorkload{ref, d.Spec.Selector, d.Spec.Template.Labels, d.Spec.Template.Spec, true, replicaCount(d.Spec.Replicas)}
+		}
+	case "DaemonSet.apps":
+		var d appsv1.DaemonSet
+		if convert(&d) {
+			return &podWorkload{ref, d.Spec.Selector, d.Spec.Template.Labels, d.Spec.Template.Spec, true, 0}
+		}
+	case "Job.batch":
+		var j batchv1.Job
+		if convert(&j) {
+			return &podWorkload{ref, nil, j.Spec.Template.Labels, j.Spec.Template.Spec, false, 0}
+		}
+	case "CronJob.batch":
+		var j batchv1.CronJob
+		if convert(&j) {
+			t := j.Spec.JobTemplate.Spec.Template
+			return &podWorkload{ref, nil, t.Labels, t.Spec, false, 0}
+		}
+	}
+	return nil
+}
+
+// replicaCount is the replica count of a spec, which defaults to 1.
+func replicaCount(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// selectorProblem describes why the selector of a long-running workload does not
+// select its own pods, or returns "".
+func selectorProblem(w *podWorkload) string {
+	if !w.longRunning {
+		return ""
+	}
+	if w.selector == nil {
+		return "spec.selector is missing"
+	}
+	sel, err := metav1.LabelSelectorAsSelector(w.selector)
+	if err != nil {
+		return fmt.Sprintf("spec.selector is invalid: %v", err)
+	}
+	if sel.Empty() || !sel.Matches(labels.Set(w.labels)) {
+		return fmt.Sprintf("spec.selector %s does not match the pod template labels %s", sel, labels.Set(w.labels))
+	}
+	return ""
+}
+
+func checkWorkload(w *podWorkload, add func(rule, ref, format string, args ...interface{})) {
+	if problem := selectorProblem(w); problem != "" {
+		add(models.PolicySelector, w.ref, "%s", problem)
+	}
+
+	for _, c := range w.pod.Containers {
+		var missing []string
+		for _, r := range []struct {
+			path string
+			list corev1.ResourceList
+			name corev1.ResourceName
+		}{
+			{"requests.cpu", c.Resources.Requests, corev1.ResourceCPU},
+			{"requests.memory", c.Resources.Requests, corev1.ResourceMemory},
+			{"limits.cpu", c.Resources.Limits, corev1.ResourceCPU},
+			{"limits.memory", c.Resources.Limits, corev1.ResourceMemory},
+		} {
+			if _, ok := r.list[r.name]; !ok {
+				missing = append(missing, "resources."+r.path)
+			}
+		}
+		if len(missing) > 0 {
+			add(models.PolicyResources, w.ref, "container %s has no %s", c.Name, strings.Join(missing, ", "))
+		}
+
+		if w.longRunning {
+			missing = nil
+			if c.ReadinessProbe == nil {
+				missing = append(missing, "readinessProbe")
+			}
+			if c.LivenessProbe == nil {
+				missing = append(missing, "livenessProbe")
+			}
+			if len(missing) > 0 {
+				add(models.PolicyProbes, w.ref, "container %s has no %s", c.Name, strings.Join(missing, ", "))
+			}
+		}
+
+		if tag := imageTag(c.Image); tag == "" || tag == "latest" {
+			add(models.PolicyPinnedImage, w.ref, "container %s image %q is not pinned to a version tag or digest", c.Name, c.Image)
+		}
+
+		if !runsAsNonRoot(w.pod.SecurityContext, c.SecurityContext) {
+			add(models.PolicyNonRoot, w.ref, "container %s does not set runAsNonRoot or a non-root runAsUser (set runAsUser: 0 explicitly if the image must run as root)", c.Name)
+		}
+	}
+}
+
+// imageTag returns the tag of an image reference, "" when it has none, and "@" for a
+// digest reference.
+func imageTag(image string) string {
+	if strings.Contains(image, "@") {
+		return "@"
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+// runsAsNonRoot reports whether a container runs as non-root or deliberately as root:
+// an explicit runAsUser: 0 or runAsNonRoot: false is an accepted exception.
+func runsAsNonRoot(pod *corev1.PodSecurityContext, container *corev1.SecurityContext) bool {
+	var nonRoot *bool
+	var user *int64
+	if pod != nil {
+		nonRoot, user = pod.RunAsNonRoot, pod.RunAsUser
+	}
+	if container != nil {
+		if container.RunAsNonRoot != nil {
+			nonRoot = container.RunAsNonRoot
+		}
+		if container.RunAsUser != nil {
+			user = container.RunAsUser
+		}
+	}
+	return nonRoot != nil || user != nil
+}
+
+// checkServicePorts checks that every port of a Service with a selector targets a
+// port of a container the selector matches.
+func checkServicePorts(svc *corev1.Service, workloads []*podWorkload, add func(rule, ref, format string, args ...interface{})) {
+	if len(svc.Spec.Selector) == 0 {
+		return
+	}
+	ref := "Service/" + svc.Name
+	sel := labels.SelectorFromSet(svc.Spec.Selector)
+
+	var matched []*podWorkload
+	for _, w := range workloads {
+		if sel.Matches(labels.Set(w.labels)) {
+			matched = append(matched, w)
+		}
+	}
+	if len(matched) == 0 {
+		add(models.PolicyTargetPort, ref, "selector %s matches no workload in the manifests", sel)
+		return
+	}
+
+	for _, p := range svc.Spec.Ports {
+		target := p.TargetPort
+		if target.Type == intstr.Int && target.IntVal == 0 && target.StrVal == "" {
+			target = intstr.FromInt32(p.Port)
+		}
+		if !exposesPort(matched, target) {
+			var refs []string
+			for _, w := range matched {
+				refs = append(refs, w.ref)
+			}
+			sort.Strings(refs)
+			add(models.PolicyTargetPort, ref, "port %d targets %s, which no container of %s exposes", p.Port, target.String(), strings.Join(refs, ", "))
+		}
+	}
+}
+
+func exposesPort(workloads []*podWorkload, target intstr.IntOrString) bool {
+	for _, w := range workloads {
+		for _, c := range w.pod.Containers {
+			for _, cp := range c.Ports {
+				if target.Type == intstr.String && cp.Name == target.StrVal {
+					return true
+				}
+				if target.Type == intstr.Int && cp.ContainerPort == target.IntVal {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package kubernetes
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// compliantContainer passes every policy rule.
+const compliantContainer = `image: web:1.2
+ports:
+- name: http
+  containerPort: 8080
+resources:
+  requests: {cpu: 100m, memory: 128Mi}
+  limits: {cpu: 200m, memory: 256Mi}
+readinessProbe:
+  httpGet: {path: /, port: 8080}
+livenessProbe:
+  httpGet: {path: /, port: 8080}
+securityContext:
+  runAsNonRoot: true
+`
+
+// testWorkload describes a workload manifest whose pods run a single container.
+type testWorkload struct {
+	kind, name string
+	spec       string // workload spec fields besides the selector and template
+	pod        string // pod spec fields besides the containers
+	container  string // container fields besides the name
+}
+
+func (w testWorkload) String() string {
+	apiVersion, selector := "apps/v1", fmt.Sprintf("selector:\n  matchLabels:\n    app: %s\n", w.name)
+	if w.kind == "Job" {
+		apiVersion, selector = "batch/v1", ""
+	}
+	return fmt.Sprintf("apiVersion: %s\nkind: %s\nmetadata:\n  name: %s\nspec:\n%s%s  template:\n    metadata:\n      labels:\n        app: %s\n    spec:\n%s      containers:\n      - name: app\n%s",
+		apiVersion, w.kind, w.name, indent(w.spec, 2), indent(selector, 2), w.name, indent(w.pod, 6), indent(w.container, 8))
+}
+
+// indent prefixes every line of s with n spaces.
+func indent(s string, n int) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		if line != "" {
+			b.WriteString(strings.Repeat(" ", n) + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+func webService(targetPort string) string {
+	return "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\nspec:\n  selector:\n    app: web\n  ports:\n  - port: 80\n    targetPort: " + targetPort + "\n"
+}
+
+func TestCheckPolicy(t *testing.T) {
+	web := testWorkload{kind: "Deployment", name: "web", container: compliantContainer}.String()
+	withContainer := func(old, new string) string {
+		return testWorkload{kind: "Deployment", name: "web", container: strings.Replace(compliantContainer, old, new, 1)}.String()
+	}
+
+	tests := []struct {
+		name     string
+		docs     []string
+		disabled []string
+		want     []string // "rule Resource"
+	}{
+		{
+			name: "compliant",
+			docs: []string{web, webService("8080"), ""},
+		},
+		{
+			name: "named target port",
+			docs: []string{web, webService("http")},
+		},
+		{
+			name: "missing resources",
+			docs: []string{withContainer("  limits: {cpu: 200m, memory: 256Mi}\n", "")},
+			want: []string{"resources Deployment/web"},
+		},
+		{
+			name: "missing probes",
+			docs: []string{withContainer("livenessProbe:\n  httpGet: {path: /, port: 8080}\n", "")},
+			want: []string{"probes Deployment/web"},
+		},
+		{
+			name: "Jobs need no probes",
+			docs: []string{testWorkload{kind: "Job", name: "migrate", pod: "restartPolicy: Never", container: "image: migrate:1.0\nresources:\n  requests: {cpu: 100m, memory: 128Mi}\n  limits: {cpu: 100m, memory: 128Mi}\nsecurityContext:\n  runAsUser: 1000\n"}.String()},
+		},
+		{
+			name: "latest tag",
+			docs: []string{withContainer("web:1.2", "web:latest")},
+			want: []string{"no_latest_tag Deployment/web"},
+		},
+		{
+			name: "untagged image behind a registry port",
+			docs: []string{withContainer("web:1.2", "registry.local:5000/web")},
+			want: []string{"no_latest_tag Deployment/web"},
+		},
+		{
+			name: "digest",
+			docs: []string{withContainer("web:1.2", "web@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")},
+		},
+		{
+			name: "no user set",
+			docs: []string{withContainer("securityContext:\n  runAsNonRoot: true\n", "")},
+			want: []string{"non_root Deployment/web"},
+		},
+		{
+			name: "explicit root",
+			docs: []string{withContainer("runAsNonRoot: true", "runAsUser: 0")},
+		},
+		{
+			name: "selector does not match the pod labels",
+			docs: []string{strings.Replace(web, "app: web", "app: other", 1)},
+			want: []string{"selector_labels Deployment/web"},
+		},
+		{
+			name: "target port no container exposes",
+			docs: []string{web, webService("9090")},
+			want: []string{"service_target_port Service/web"},
+		},
+		{
+			name: "Service selecting no workload",
+			docs: []string{webService("8080")},
+			want: []string{"service_target_port Service/web"},
+		},
+		{
+			name: "ports matched across documents",
+			docs: []string{webService("8080"), web},
+		},
+		{
+			name: "schema error",
+			docs: []string{web, "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\nspec:\n  replica: 2\n"},
+			want: []string{"schema Deployment/api"},
+		},
+		{
+			name:     "disabled rules",
+			docs:     []string{withContainer("web:1.2", "web:latest"), webService("9090")},
+			disabled: []string{models.PolicyPinnedImage, models.PolicyTargetPort},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range CheckPolicy(tt.docs, tt.disabled) {
+				got = append(got, v.Rule+" "+v.Resource)
+				if v.Message == "" {
+					t.Errorf("violation %s has no message", v.Rule)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	tests := []struct {
+		image, want string
+	}{
+		{"nginx", ""},
+		{"nginx:1.25", "1.25"},
+		{"nginx:latest", "latest"},
+		{"localhost:5000/team/web", ""},
+		{"localhost:5000/team/web:2.0", "2.0"},
+		{"nginx@sha256:abc", "@"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := imageTag(tt.image); got != tt.want {
+				t.Errorf("imageTag(%q) = %q, want %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
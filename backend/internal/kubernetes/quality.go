@@ -0,0 +1,442 @@
+package kubernetes
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// QualityRulesVersion identifies the quality rules and their weights. Bump it when
+// either changes, so scores of different rule sets are not compared.
+const QualityRulesVersion = 1
+
+// qualityPenalty is what one finding of a severity takes off its category score.
+var qualityPenalty = map[string]int{
+	models.SeverityError:   25,
+	models.SeverityWarning: 10,
+	models.SeverityInfo:    3,
+}
+
+var severityRank = map[string]int{
+	models.SeverityError:   0,
+	models.SeverityWarning: 1,
+	models.SeverityInfo:    2,
+}
+
+var qualityCategories = []string{
+	models.QualitySecurity,
+	models.QualityReliability,
+	models.QualityResources,
+	models.QualityNetworking,
+}
+
+// sensitiveEnvName matches environment variables that usually hold credentials.
+var sensitiveEnvName = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|SECRET|TOKEN|API_?KEY|PRIVATE_?KEY|CREDENTIAL)`)
+
+// maxCPULimitRatio is the CPU limit to request ratio above which pods are likely
+// throttled or overcommit their node.
+const maxCPULimitRatio = 4
+
+// autoscaler is the part of a HorizontalPodAutoscaler the rules need.
+type autoscaler struct {
+	ref         string
+	target      string // Kind/name
+	minReplicas int32
+	maxReplicas int32
+	cpu         bool // scales on CPU utilization
+}
+
+type qualityLint struct {
+	findings []models.QualityFinding
+}
+
+func (l *qualityLint) add(severity, category, rule, ref, format string, args ...interface{}) {
+	l.findings = append(l.findings, models.QualityFinding{
+		Severity: severity,
+		Category: category,
+		Resource: ref,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// ScoreManifests lints the documents of a manifest set for security posture,
+// reliability, resource hygiene and networking correctness, and scores each category
+// from 100 down by the findings it has. The report only depends on the documents.
+// Services, autoscalers, PodDisruptionBudgets and Ingresses are matched against the
+// objects of all documents, so a stack is scored as a whole.
+func ScoreManifests(documents []string) *models.QualityReport {
+	l := &qualityLint{}
+	objs := decodeDocuments(documents, func(ref string, err error) {
+		l.add(models.SeverityError, models.QualityReliability, "schema", ref, "%v", err)
+	})
+	workloads, services := podWorkloadsAndServices(objs)
+
+	var hpas []*autoscaler
+	var pdbs []*policyv1.PodDisruptionBudget
+	var ingresses []*networkingv1.Ingress
+	for _, obj := range objs {
+		switch obj.GroupVersionKind().GroupKind().String() {
+		case "HorizontalPodAutoscaler.autoscaling":
+			if hpa := toAutoscaler(obj); hpa != nil {
+				hpas = append(hpas, hpa)
+			}
+		case "PodDisruptionBudget.policy":
+			var pdb policyv1.PodDisruptionBudget
+			if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pdb) == nil {
+				pdbs = append(pdbs, &pdb)
+			}
+		case "Ingress.networking.k8s.io":
+			var ing networkingv1.Ingress
+			if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &ing) == nil {
+				ingresses = append(ingresses, &ing)
+			}
+		}
+	}
+
+	for _, w := range workloads {
+		l.checkSecurity(w)
+		l.checkReliability(w, hpas, pdbs)
+		l.checkResources(w)
+	}
+	l.checkAutoscalers(hpas, workloads)
+	for _, svc := range services {
+		checkServicePorts(svc, workloads, func(rule, ref, format string, args ...interface{}) {
+			l.add(models.SeverityError, models.QualityNetworking, rule, ref, format, args...)
+		})
+	}
+	for _, ing := range ingresses {
+		l.checkIngress(ing, services)
+	}
+	return l.report()
+}
+
+// toAutoscaler reads an autoscaling/v1 or v2 HorizontalPodAutoscaler.
+func toAutoscaler(obj *unstructured.Unstructured) *autoscaler {
+	var hpa autoscalingv2.HorizontalPodAutoscaler
+	if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &hpa) != nil {
+		return nil
+	}
+	a := &autoscaler{
+		ref:         "HorizontalPodAutoscaler/" + hpa.Name,
+		target:      hpa.Spec.ScaleTargetRef.Kind + "/" + hpa.Spec.ScaleTargetRef.Name,
+		minReplicas: replicaCount(hpa.Spec.MinReplicas),
+		maxReplicas: hpa.Spec.MaxReplicas,
+	}
+	// autoscaling/v1 only scales on CPU, and v2 defaults to it without metrics
+	a.cpu = obj.GroupVersionKind().Version == "v1" || len(hpa.Spec.Metrics) == 0
+	for _, m := range hpa.Spec.Metrics {
+		if m.Type == autoscalingv2.ResourceMetricSourceType && m.Resource != nil && m.Resource.Name == corev1.ResourceCPU {
+			a.cpu = true
+		}
+	}
+	return a
+}
+
+func (l *qualityLint) checkSecurity(w *podWorkload) {
+	const cat = models.QualitySecurity
+	var host []string
+	if w.pod.HostNetwork {
+		host = append(host, "hostNetwork")
+	}
+	if w.pod.HostPID {
+		host = append(host, "hostPID")
+	}
+	if w.pod.HostIPC {
+		host = append(host, "hostIPC")
+	}
+	if len(host) > 0 {
+		l.add(models.SeverityError, cat, "host_namespaces", w.ref, "pods share the node's namespaces (%s)", strings.Join(host, ", "))
+	}
+
+	for _, c := range w.pod.Containers {
+		sc := c.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			l.add(models.SeverityError, cat, "privileged", w.ref, "container %s is privileged", c.Name)
+		}
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			l.add(models.SeverityWarning, cat, "privilege_escalation", w.ref, "container %s does not set allowPrivilegeEscalation: false", c.Name)
+		}
+		switch nonRoot, explicit := runsAsNonRootUser(w.pod.SecurityContext, c.SecurityContext); {
+		case nonRoot:
+		case explicit:
+			l.add(models.SeverityWarning, cat, "run_as_non_root", w.ref, "container %s runs as root", c.Name)
+		default:
+			l.add(models.SeverityWarning, cat, "run_as_non_root", w.ref, "container %s does not set runAsNonRoot or a non-root runAsUser", c.Name)
+		}
+		if sc.ReadOnlyRootFilesystem == nil || !*sc.ReadOnlyRootFilesystem {
+			l.add(models.SeverityInfo, cat, "read_only_root_fs", w.ref, "container %s has a writable root filesystem", c.Name)
+		}
+		if sc.Capabilities == nil || !dropsAllCapabilities(sc.Capabilities.Drop) {
+			l.add(models.SeverityInfo, cat, "drop_capabilities", w.ref, "container %s does not drop ALL capabilities", c.Name)
+		}
+		if sc.Capabilities != nil {
+			var added []string
+			for _, capability := range sc.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" {
+					added = append(added, string(capability))
+				}
+			}
+			if len(added) > 0 {
+				l.add(models.SeverityWarning, cat, "added_capabilities", w.ref, "container %s adds capabilities %s", c.Name, strings.Join(added, ", "))
+			}
+		}
+		if tag := imageTag(c.Image); tag == "" || tag == "latest" {
+			l.add(models.SeverityWarning, cat, "image_tag", w.ref, "container %s image %q is not pinned to a version tag or digest", c.Name, c.Image)
+		}
+		for _, env := range c.Env {
+			if env.Value != "" && sensitiveEnvName.MatchString(env.Name) {
+				l.add(models.SeverityWarning, cat, "plaintext_secret", w.ref, "container %s sets %s as a literal value instead of a secretKeyRef", c.Name, env.Name)
+			}
+		}
+	}
+}
+
+// runsAsNonRootUser reports whether a container is guaranteed to run as non-root, and
+// whether its user is set explicitly at all.
+func runsAsNonRootUser(pod *corev1.PodSecurityContext, container *corev1.SecurityContext) (bool, bool) {
+	var nonRoot *bool
+	var user *int64
+	if pod != nil {
+		nonRoot, user = pod.RunAsNonRoot, pod.RunAsUser
+	}
+	if container != nil {
+		if container.RunAsNonRoot != nil {
+			nonRoot = container.RunAsNonRoot
+		}
+		if container.RunAsUser != nil {
+			user = container.RunAsUser
+		}
+	}
+	if user != nil {
+		return *user > 0, true
+	}
+	return nonRoot != nil && *nonRoot, nonRoot != nil
+}
+
+func dropsAllCapabilities(drop []corev1.Capability) bool {
+	for _, capability := range drop {
+		if strings.EqualFold(string(capability), "ALL") {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *qualityLint) checkReliability(w *podWorkload, hpas []*autoscaler, pdbs []*policyv1.PodDisruptionBudget) {
+	const cat = models.QualityReliability
+	if problem := selectorProblem(w); problem != "" {
+		l.add(models.SeverityError, cat, "selector_labels", w.ref, "%s", problem)
+	}
+
+	if w.longRunning {
+		for _, c := range w.pod.Containers {
+			if c.ReadinessProbe == nil {
+				l.add(models.SeverityWarning, cat, "readiness_probe", w.ref, "container %s has no readinessProbe", c.Name)
+			}
+			if c.LivenessProbe == nil {
+				l.add(models.SeverityWarning, cat, "liveness_probe", w.ref, "container %s has no livenessProbe", c.Name)
+			}
+		}
+	}
+
+	if !strings.HasPrefix(w.ref, "Deployment/") && !strings.HasPrefix(w.ref, "StatefulSet/") {
+		return
+	}
+	replicas := w.replicas
+	for _, hpa := range hpas {
+		if hpa.target == w.ref {
+			replicas = hpa.minReplicas
+		}
+	}
+	if replicas < 2 {
+		l.add(models.SeverityWarning, cat, "single_replica", w.ref, "runs %d replica(s), so every restart or node drain is an outage", replicas)
+		return
+	}
+
+	covered := false
+	for _, pdb := range pdbs {
+		if sel, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector); err == nil && !sel.Empty() && sel.Matches(labels.Set(w.labels)) {
+			covered = true
+		}
+	}
+	if !covered {
+		l.add(models.SeverityInfo, cat, "pod_disruption_budget", w.ref, "no PodDisruptionBudget covers its %d replicas", replicas)
+	}
+	if len(w.pod.TopologySpreadConstraints) == 0 && (w.pod.Affinity == nil || w.pod.Affinity.PodAntiAffinity == nil) {
+		l.add(models.SeverityInfo, cat, "anti_affinity", w.ref, "its %d replicas may be scheduled on the same node (no podAntiAffinity or topologySpreadConstraints)", replicas)
+	}
+}
+
+func (l *qualityLint) checkResources(w *podWorkload) {
+	const cat = models.QualityResources
+	for _, c := range w.pod.Containers {
+		for _, r := range []struct {
+			rule     string
+			severity string
+			list     corev1.ResourceList
+			path     string
+		}{
+			{"resource_requests", models.SeverityWarning, c.Resources.Requests, "requests"},
+			{"resource_limits", models.SeverityWarning, c.Resources.Limits, "limits"},
+		} {
+			var missing []string
+			for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+				if _, ok := r.list[name]; !ok {
+					missing = append(missing, "resources."+r.path+"."+string(name))
+				}
+			}
+			if len(missing) > 0 {
+				l.add(r.severity, cat, r.rule, w.ref, "container %s has no %s", c.Name, strings.Join(missing, ", "))
+			}
+		}
+
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			request, hasRequest := c.Resources.Requests[name]
+			limit, hasLimit := c.Resources.Limits[name]
+			if hasRequest && hasLimit && limit.Cmp(request) < 0 {
+				l.add(models.SeverityError, cat, "limit_below_request", w.ref, "container %s %s limit %s is below its request %s", c.Name, name, limit.String(), request.String())
+			}
+		}
+		request, hasRequest := c.Resources.Requests[corev1.ResourceCPU]
+		limit, hasLimit := c.Resources.Limits[corev1.ResourceCPU]
+		if hasRequest && hasLimit && request.MilliValue() > 0 && limit.MilliValue() > maxCPULimitRatio*request.MilliValue() {
+			l.add(models.SeverityInfo, cat, "cpu_limit_ratio", w.ref, "container %s cpu limit %s is more than %dx its request %s", c.Name, limit.String(), maxCPULimitRatio, request.String())
+		}
+	}
+}
+
+func (l *qualityLint) checkAutoscalers(hpas []*autoscaler, workloads []*podWorkload) {
+	const cat = models.QualityResources
+	for _, hpa := range hpas {
+		if hpa.maxReplicas < hpa.minReplicas {
+			l.add(models.SeverityError, cat, "hpa_replica_range", hpa.ref, "maxReplicas %d is below minReplicas %d", hpa.maxReplicas, hpa.minReplicas)
+		}
+		var target *podWorkload
+		for _, w := range workloads {
+			if w.ref == hpa.target {
+				target = w
+			}
+		}
+		if target == nil {
+			l.add(models.SeverityError, cat, "hpa_target", hpa.ref, "scaleTargetRef %s is not in the manifests", hpa.target)
+			continue
+		}
+		if !hpa.cpu {
+			continue
+		}
+		for _, c := range target.pod.Containers {
+			if _, ok := c.Resources.Requests[corev1.ResourceCPU]; !ok {
+				l.add(models.SeverityWarning, cat, "hpa_cpu_requests", hpa.ref, "scales %s on CPU utilization, but container %s has no cpu request", hpa.target, c.Name)
+			}
+		}
+	}
+}
+
+func (l *qualityLint) checkIngress(ing *networkingv1.Ingress, services []*corev1.Service) {
+	ref := "Ingress/" + ing.Name
+	var backends []*networkingv1.IngressServiceBackend
+	if b := ing.Spec.DefaultBackend; b != nil && b.Service != nil {
+		backends = append(backends, b.Service)
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil {
+				backends = append(backends, path.Backend.Service)
+			}
+		}
+	}
+
+	for _, b := range backends {
+		var svc *corev1.Service
+		for _, s := range services {
+			if s.Name == b.Name {
+				svc = s
+			}
+		}
+		if svc == nil {
+			l.add(models.SeverityError, models.QualityNetworking, "ingress_backend", ref, "backend service %s is not in the manifests", b.Name)
+			continue
+		}
+		found := false
+		for _, p := range svc.Spec.Ports {
+			if (b.Port.Name != "" && p.Name == b.Port.Name) || (b.Port.Name == "" && p.Port == b.Port.Number) {
+				found = true
+			}
+		}
+		if !found {
+			port := b.Port.Name
+			if port == "" {
+				port = fmt.Sprint(b.Port.Number)
+			}
+			l.add(models.SeverityError, models.QualityNetworking, "ingress_backend", ref, "backend port %s is not a port of Service/%s", port, b.Name)
+		}
+	}
+}
+
+// report orders the findings by severity, resource and rule, and scores them.
+func (l *qualityLint) report() *models.QualityReport {
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		a, b := l.findings[i], l.findings[j]
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] < severityRank[b.Severity]
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Message < b.Message
+	})
+
+	report := &models.QualityReport{
+		RulesVersion: QualityRulesVersion,
+		Counts:       map[string]int{models.SeverityError: 0, models.SeverityWarning: 0, models.SeverityInfo: 0},
+		Findings:     l.findings,
+	}
+	if report.Findings == nil {
+		report.Findings = []models.QualityFinding{}
+	}
+
+	penalties := map[string]int{}
+	findings := map[string]int{}
+	for _, f := range l.findings {
+		report.Counts[f.Severity]++
+		penalties[f.Category] += qualityPenalty[f.Severity]
+		findings[f.Category]++
+	}
+	total := 0
+	for _, category := range qualityCategories {
+		score := 100 - penalties[category]
+		if score < 0 {
+			score = 0
+		}
+		total += score
+		report.Categories = append(report.Categories, models.QualityCategoryScore{
+			Category: category,
+			Score:    score,
+			Findings: findings[category],
+		})
+	}
+	report.Score = int(math.Round(float64(total) / float64(len(qualityCategories))))
+	return report
+}
@@ -0,0 +1,193 @@
+package kubernetes
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// hardenedContainer has no quality findings.
+const hardenedContainer = compliantContainer + `  allowPrivilegeEscalation: false
+  readOnlyRootFilesystem: true
+  capabilities:
+    drop: [ALL]
+`
+
+const spreadPods = `topologySpreadConstraints:
+- maxSkew: 1
+  topologyKey: kubernetes.io/hostname
+  whenUnsatisfiable: ScheduleAnyway
+  labelSelector:
+    matchLabels:
+      app: web
+`
+
+const webBudget = "apiVersion: policy/v1\nkind: PodDisruptionBudget\nmetadata:\n  name: web\nspec:\n  minAvailable: 1\n  selector:\n    matchLabels:\n      app: web\n"
+
+func autoscalerFor(target string, minReplicas, maxReplicas int) string {
+	return fmt.Sprintf("apiVersion: autoscaling/v2\nkind: HorizontalPodAutoscaler\nmetadata:\n  name: %s\nspec:\n"+
+		"  scaleTargetRef:\n    apiVersion: apps/v1\n    kind: Deployment\n    name: %s\n  minReplicas: %d\n  maxReplicas: %d\n",
+		target, target, minReplicas, maxReplicas)
+}
+
+func webIngress(service, port string) string {
+	return "apiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: web\nspec:\n  rules:\n  - http:\n      paths:\n      - path: /\n        pathType: Prefix\n" +
+		"        backend:\n          service:\n            name: " + service + "\n            port:\n              " + port + "\n"
+}
+
+func TestScoreManifests(t *testing.T) {
+	hardened := testWorkload{kind: "Deployment", name: "web", spec: "replicas: 2", pod: spreadPods, container: hardenedContainer}
+	web := hardened.String()
+	with := func(edit func(w *testWorkload)) string {
+		w := hardened
+		edit(&w)
+		return w.String()
+	}
+
+	tests := []struct {
+		name string
+		docs []string
+		want []string // "severity rule Resource", in report order
+	}{
+		{
+			name: "hardened",
+			docs: []string{web, webService("http"), webBudget, webIngress("web", "number: 80")},
+		},
+		{
+			name: "privileged pod on the host network",
+			docs: []string{with(func(w *testWorkload) {
+				w.pod += "hostNetwork: true\n"
+				w.container += "  privileged: true\n"
+			}), webBudget},
+			want: []string{"error host_namespaces Deployment/web", "error privileged Deployment/web"},
+		},
+		{
+			name: "defaults of an unhardened container",
+			docs: []string{with(func(w *testWorkload) { w.container = compliantContainer }), webBudget},
+			want: []string{
+				"warning privilege_escalation Deployment/web",
+				"info drop_capabilities Deployment/web",
+				"info read_only_root_fs Deployment/web",
+			},
+		},
+		{
+			name: "plaintext credentials and added capabilities",
+			docs: []string{with(func(w *testWorkload) {
+				w.container = strings.Replace(w.container, "drop: [ALL]", "drop: [ALL]\n    add: [NET_BIND_SERVICE, SYS_ADMIN]", 1) +
+					"env:\n- name: DB_PASSWORD\n  value: hunter2\n- name: LOG_LEVEL\n  value: debug\n"
+			}), webBudget},
+			want: []string{"warning added_capabilities Deployment/web", "warning plaintext_secret Deployment/web"},
+		},
+		{
+			name: "single replica",
+			docs: []string{with(func(w *testWorkload) { w.spec = "replicas: 1" })},
+			want: []string{"warning single_replica Deployment/web"},
+		},
+		{
+			name: "HPA minReplicas counts as the replicas",
+			docs: []string{with(func(w *testWorkload) { w.spec = "replicas: 1" }), autoscalerFor("web", 2, 4)},
+			want: []string{"info pod_disruption_budget Deployment/web"},
+		},
+		{
+			name: "replicas on one node",
+			docs: []string{with(func(w *testWorkload) { w.pod = "" }), webBudget},
+			want: []string{"info anti_affinity Deployment/web"},
+		},
+		{
+			name: "limit below request and a high CPU ratio",
+			docs: []string{with(func(w *testWorkload) {
+				w.container = strings.Replace(w.container, "limits: {cpu: 200m, memory: 256Mi}", "limits: {cpu: 1, memory: 64Mi}", 1)
+			}), webBudget},
+			want: []string{"error limit_below_request Deployment/web", "info cpu_limit_ratio Deployment/web"},
+		},
+		{
+			name: "HPA with an inverted range and a missing target",
+			docs: []string{web, webBudget, autoscalerFor("api", 3, 2)},
+			want: []string{"error hpa_replica_range HorizontalPodAutoscaler/api", "error hpa_target HorizontalPodAutoscaler/api"},
+		},
+		{
+			name: "CPU HPA without cpu requests",
+			docs: []string{with(func(w *testWorkload) {
+				w.container = strings.Replace(w.container, "requests: {cpu: 100m, memory: 128Mi}", "requests: {memory: 128Mi}", 1)
+			}), webBudget, autoscalerFor("web", 2, 4)},
+			want: []string{
+				"warning resource_requests Deployment/web",
+				"warning hpa_cpu_requests HorizontalPodAutoscaler/web",
+			},
+		},
+		{
+			name: "Ingress backends",
+			docs: []string{web, webBudget, webService("http"), webIngress("web", "number: 8080"), strings.Replace(webIngress("api", "name: http"), "name: web\n", "name: api\n", 1)},
+			want: []string{"error ingress_backend Ingress/api", "error ingress_backend Ingress/web"},
+		},
+		{
+			name: "Service target port",
+			docs: []string{web, webBudget, webService("9090")},
+			want: []string{"error service_target_port Service/web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := ScoreManifests(tt.docs)
+			var got []string
+			for _, f := range report.Findings {
+				got = append(got, f.Severity+" "+f.Rule+" "+f.Resource)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreManifests_Scores(t *testing.T) {
+	tests := []struct {
+		name       string
+		docs       []string
+		want       int
+		categories map[string]int
+		counts     map[string]int
+	}{
+		{
+			name:       "no findings",
+			docs:       nil,
+			want:       100,
+			categories: map[string]int{models.QualitySecurity: 100, models.QualityReliability: 100, models.QualityResources: 100, models.QualityNetworking: 100},
+			counts:     map[string]int{models.SeverityError: 0, models.SeverityWarning: 0, models.SeverityInfo: 0},
+		},
+		{
+			// security: three warnings and two infos; reliability: two probe warnings and
+			// single_replica; resources: requests and limits; networking: a Service that
+			// matches no workload
+			name:       "bare pod",
+			docs:       []string{"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  selector:\n    matchLabels:\n      app: web\n  template:\n    metadata:\n      labels:\n        app: web\n    spec:\n      containers:\n      - name: app\n        image: web\n", strings.Replace(webService("80"), "app: web", "app: api", 1)},
+			want:       72,
+			categories: map[string]int{models.QualitySecurity: 64, models.QualityReliability: 70, models.QualityResources: 80, models.QualityNetworking: 75},
+			counts:     map[string]int{models.SeverityError: 1, models.SeverityWarning: 8, models.SeverityInfo: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := ScoreManifests(tt.docs)
+			if report.Score != tt.want || report.RulesVersion != QualityRulesVersion {
+				t.Errorf("score %d (rules v%d), want %d (rules v%d)", report.Score, report.RulesVersion, tt.want, QualityRulesVersion)
+			}
+			categories := map[string]int{}
+			for _, c := range report.Categories {
+				categories[c.Category] = c.Score
+			}
+			if !reflect.DeepEqual(categories, tt.categories) {
+				t.Errorf("categories %v, want %v", categories, tt.categories)
+			}
+			if !reflect.DeepEqual(report.Counts, tt.counts) {
+				t.Errorf("counts %v, want %v", report.Counts, tt.counts)
+			}
+			if report.Findings == nil {
+				t.Error("findings should be an empty list, not nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// rolloutPollInterval is how often WaitForRollout re-checks the workload.
+const rolloutPollInterval = 2 * time.Second
+
+// Failure reasons reported in models.PodFailure.
+const (
+	FailureImagePull     = "ImagePullBackOff"
+	FailureCrashLoop     = "CrashLoopBackOff"
+	FailureOOMKilled     = "OOMKilled"
+	FailureUnschedulable = "Unschedulable"
+	FailureProbe         = "ProbeFailed"
+	FailureConfig        = "ContainerConfigError"
+)
+
+// WaitForRollout polls the workload until its rollout completes, a fatal pod failure is
+// detected, or ctx expires. The returned status carries the last observed replica counts
+// and pod diagnostics even when an error is returned.
+func (s *k8sService) WaitForRollout(ctx context.Context, cluster, namespace, kind, name string) (*models.RolloutStatus, error) {
+	ticker := time.NewTicker(rolloutPollInterval)
+	defer ticker.Stop()
+
+	var last *models.RolloutStatus
+	for {
+		status, err := s.RolloutStatus(ctx, cluster, namespace, kind, name)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			return last, err
+		case err == nil:
+			last = status
+			if status.Complete {
+				return status, nil
+			}
+			if status.Failed {
+				return status, fmt.Errorf("%s", status.Message)
+			}
+			for _, f := range status.Failures {
+				if f.Fatal {
+					status.Failed = true
+					return status, fmt.Errorf("pod %s: %s: %s", f.Pod, f.Reason, f.Message)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if last == nil {
+				return nil, fmt.Errorf("timed out waiting for %s %q to become ready", kind, name)
+			}
+			last.Failed = true
+			msg := last.Message
+			if len(last.Failures) > 0 {
+				f := last.Failures[0]
+				msg = fmt.Sprintf("pod %s: %s: %s", f.Pod, f.Reason, f.Message)
+			}
+			return last, fmt.Errorf("timed out waiting for %s %q to become ready: %s", kind, name, msg)
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServiceEndpoint returns the best reachable URL for a Service: the load balancer
+// address once assigned, otherwise the in-cluster DNS name.
+func (s *k8sService) ServiceEndpoint(ctx context.Context, cluster, namespace, name string) (string, error) {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return "", err
+	}
+
+	svc, err := cc.client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("getting service: %w", err)
+	}
+
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return svc.Spec.ExternalName, nil
+	}
+
+	port := ""
+	if len(svc.Spec.Ports) > 0 && svc.Spec.Ports[0].Port != 80 {
+		port = ":" + strconv.Itoa(int(svc.Spec.Ports[0].Port))
+	}
+
+	if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, ing := range svc.Status.LoadBalancer.Ingress {
+			if ing.Hostname != "" {
+				return "http://" + ing.Hostname + port, nil
+			}
+			if ing.IP != "" {
+				return "http://" + ing.IP + port, nil
+			}
+		}
+	}
+
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local%s", svc.Name, svc.Namespace, port), nil
+}
+
+// workloadSelector returns the pod label selector of a Deployment, StatefulSet or DaemonSet.
+func workloadSelector(ctx context.Context, cc *clusterClient, namespace, kind, name string) (string, error) {
+	var sel *metav1.LabelSelector
+	switch kind {
+	case "Deployment":
+		d, err := cc.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("getting deployment: %w", err)
+		}
+		sel = d.Spec.Selector
+	case "StatefulSet":
+		sts, err := cc.client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("getting statefulset: %w", err)
+		}
+		sel = sts.Spec.Selector
+	case "DaemonSet":
+		ds, err := cc.client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("getting daemonset: %w", err)
+		}
+		sel = ds.Spec.Selector
+	default:
+		return "", fmt.Errorf("unsupported workload kind %q", kind)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(sel)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s selector: %w", kind, err)
+	}
+	return selector.String(), nil
+}
+
+// currentRevisionLabel returns the pod label that marks the pods of the current revision
+// of a workload: the pod-template-hash of the newest ReplicaSet of a Deployment, or the
+// controller-revision-hash of the update revision of a StatefulSet or DaemonSet. It
+// returns an empty key when the revision is not known yet.
+func (s *k8sService) currentRevisionLabel(ctx context.Context, cc *clusterClient, namespace, kind, name string) (string, string) {
+	switch kind {
+	case "Deployment":
+		replicaSets, err := s.deploymentReplicaSets(ctx, cc, namespace, name)
+		if err != nil {
+			return "", ""
+		}
+		var newest *appsv1.ReplicaSet
+		var newestRev int64
+		for i := range replicaSets {
+			rev, err := strconv.ParseInt(replicaSets[i].Annotations[revisionAnnotation], 10, 64)
+			if err == nil && (newest == nil || rev > newestRev) {
+				newest, newestRev = &replicaSets[i], rev
+			}
+		}
+		if newest == nil || newest.Labels[appsv1.DefaultDeploymentUniqueLabelKey] == "" {
+			return "", ""
+		}
+		return appsv1.DefaultDeploymentUniqueLabelKey, newest.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	case "StatefulSet":
+		// StatefulSet pods carry the name of their ControllerRevision
+		sts, err := cc.client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil || sts.Status.UpdateRevision == "" {
+			return "", ""
+		}
+		return appsv1.ControllerRevisionHashLabelKey, sts.Status.UpdateRevision
+	case "DaemonSet":
+		// DaemonSet pods carry the hash label of their ControllerRevision
+		revisions, err := s.controllerRevisions(ctx, cc, namespace, kind, name)
+		if err != nil {
+			return "", ""
+		}
+		var newest *appsv1.ControllerRevision
+		for i := range revisions {
+			if newest == nil || revisions[i].Revision > newest.Revision {
+				newest = &revisions[i]
+			}
+		}
+		if newest == nil || newest.Labels[appsv1.ControllerRevisionHashLabelKey] == "" {
+			return "", ""
+		}
+		return appsv1.ControllerRevisionHashLabelKey, newest.Labels[appsv1.ControllerRevisionHashLabelKey]
+	}
+	return "", ""
+}
+
+// diagnosePods inspects the pods of the current revision of a workload and classifies
+// why they are not ready, using container states, scheduling conditions and probe
+// events. Pods of older revisions still match the workload selector during a rollout
+// and are left out.
+func (s *k8sService) diagnosePods(ctx context.Context, cc *clusterClient, namespace, kind, name string) []models.PodFailure {
+	selector, err := workloadSelector(ctx, cc, namespace, kind, name)
+	if err != nil {
+		return nil
+	}
+	if key, value := s.currentRevisionLabel(ctx, cc, namespace, kind, name); key != "" {
+		if selector != "" {
+			selector += ","
+		}
+		selector += key + "=" + value
+	}
+	pods, err := cc.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil
+	}
+
+	var failures []models.PodFailure
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue // terminating pod from a previous revision
+		}
+
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse && cond.Reason == corev1.PodReasonUnschedulable {
+				failures = append(failures, models.PodFailure{
+					Pod: pod.Name, Reason: FailureUnschedulable, Message: cond.Message,
+				})
+			}
+		}
+
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		probeSuspect := false
+		for _, cs := range statuses {
+			if f, ok := classifyContainer(pod.Name, cs); ok {
+				failures = append(failures, f)
+				continue
+			}
+			if cs.State.Running != nil && !cs.Ready {
+				probeSuspect = true
+			}
+		}
+
+		if probeSuspect {
+			if f, ok := s.probeFailure(ctx, cc, pod); ok {
+				failures = append(failures, f)
+			}
+		}
+	}
+	return failures
+}
+
+// classifyContainer maps a container status to a failure, if it is in a known bad state.
+func classifyContainer(podName string, cs corev1.ContainerStatus) (models.PodFailure, bool) {
+	f := models.PodFailure{Pod: podName, Container: cs.Name}
+
+	lastOOM := cs.LastTerminationState.Terminated != nil && cs.LastTerminationState.Terminated.Reason == "OOMKilled"
+
+	if w := cs.State.Waiting; w != nil {
+		switch w.Reason {
+		case "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+			f.Reason, f.Message, f.Fatal = FailureImagePull, fmt.Sprintf("%s: %s", w.Reason, w.Message), true
+			return f, true
+		case "ErrImagePull":
+			// First pull failure; kubelet retries before backing off
+			f.Reason, f.Message = FailureImagePull, fmt.Sprintf("%s: %s", w.Reason, w.Message)
+			return f, true
+		case "CrashLoopBackOff":
+			if lastOOM {
+				f.Reason, f.Message, f.Fatal = FailureOOMKilled, "container exceeded its memory limit and was restarted "+strconv.Itoa(int(cs.RestartCount))+" times", true
+				return f, true
+			}
+			msg := w.Message
+			if t := cs.LastTerminationState.Terminated; t != nil {
+				msg = fmt.Sprintf("last exit code %d (%s), restarted %d times", t.ExitCode, t.Reason, cs.RestartCount)
+			}
+			f.Reason, f.Message, f.Fatal = FailureCrashLoop, msg, true
+			return f, true
+		case "CreateContainerConfigError", "CreateContainerError", "RunContainerError":
+			f.Reason, f.Message, f.Fatal = FailureConfig, fmt.Sprintf("%s: %s", w.Reason, w.Message), true
+			return f, true
+		}
+	}
+
+	if t := cs.State.Terminated; t != nil && t.Reason == "OOMKilled" {
+		f.Reason, f.Message, f.Fatal = FailureOOMKilled, "container exceeded its memory limit", true
+		return f, true
+	}
+	if lastOOM && !cs.Ready {
+		f.Reason, f.Message = FailureOOMKilled, "container was OOM killed and is restarting"
+		return f, true
+	}
+	return f, false
+}
+
+// probeFailure returns the most recent "Unhealthy" event of a pod, which the kubelet
+// emits when a readiness, liveness or startup probe fails.
+func (s *k8sService) probeFailure(ctx context.Context, cc *clusterClient, pod corev1.Pod) (models.PodFailure, bool) {
+	events, err := cc.client.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.AndSelectors(
+			fields.OneTermEqualSelector("involvedObject.name", pod.Name),
+			fields.OneTermEqualSelector("reason", "Unhealthy"),
+		).String(),
+	})
+	if err != nil || len(events.Items) == 0 {
+		return models.PodFailure{}, false
+	}
+
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool { return eventTime(items[i]).After(eventTime(items[j])) })
+	return models.PodFailure{
+		Pod:     pod.Name,
+		Reason:  FailureProbe,
+		Message: items[0].Message,
+	}, true
+}
+
+func eventTime(e corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
@@ -0,0 +1,293 @@
+package kubernetes
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+func TestClassifyContainer(t *testing.T) {
+	oomKilled := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}
+	waiting := func(reason string) corev1.ContainerState {
+		return corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: "details"}}
+	}
+
+	tests := []struct {
+		name   string
+		status corev1.ContainerStatus
+		want   models.PodFailure
+		ok     bool
+	}{
+		{
+			name:   "image pull back-off",
+			status: corev1.ContainerStatus{Name: "app", State: waiting("ImagePullBackOff")},
+			want:   models.PodFailure{Pod: "p", Container: "app", Reason: FailureImagePull, Message: "ImagePullBackOff: details", Fatal: true},
+			ok:     true,
+		},
+		{
+			name:   "first pull failure is retried",
+			status: corev1.ContainerStatus{Name: "app", State: waiting("ErrImagePull")},
+			want:   models.PodFailure{Pod: "p", Container: "app", Reason: FailureImagePull, Message: "ErrImagePull: details"},
+			ok:     true,
+		},
+		{
+			name: "crash loop",
+			status: corev1.ContainerStatus{Name: "app", RestartCount: 4, State: waiting("CrashLoopBackOff"),
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}},
+			want: models.PodFailure{Pod: "p", Container: "app", Reason: FailureCrashLoop, Message: "last exit code 1 (Error), restarted 4 times", Fatal: true},
+			ok:   true,
+		},
+		{
+			name:   "crash loop from OOM kills",
+			status: corev1.ContainerStatus{Name: "app", RestartCount: 3, State: waiting("CrashLoopBackOff"), LastTerminationState: oomKilled},
+			want:   models.PodFailure{Pod: "p", Container: "app", Reason: FailureOOMKilled, Message: "container exceeded its memory limit and was restarted 3 times", Fatal: true},
+			ok:     true,
+		},
+		{
+			name:   "config error",
+			status: corev1.ContainerStatus{Name: "app", State: waiting("CreateContainerConfigError")},
+			want:   models.PodFailure{Pod: "p", Container: "app", Reason: FailureConfig, Message: "CreateContainerConfigError: details", Fatal: true},
+			ok:     true,
+		},
+		{
+			name:   "OOM killed",
+			status: corev1.ContainerStatus{Name: "app", State: oomKilled},
+			want:   models.PodFailure{Pod: "p", Container: "app", Reason: FailureOOMKilled, Message: "container exceeded its memory limit", Fatal: true},
+			ok:     true,
+		},
+		{
+			name:   "restarting after an OOM kill",
+			status: corev1.ContainerStatus{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}, LastTerminationState: oomKilled},
+			want:   models.PodFailure{Pod: "p", Container: "app", Reason: FailureOOMKilled, Message: "container was OOM killed and is restarting"},
+			ok:     true,
+		},
+		{
+			name:   "recovered from an OOM kill",
+			status: corev1.ContainerStatus{Name: "app", Ready: true, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}, LastTerminationState: oomKilled},
+			want:   models.PodFailure{Pod: "p", Container: "app"},
+		},
+		{
+			name:   "container creating",
+			status: corev1.ContainerStatus{Name: "app", State: waiting("ContainerCreating")},
+			want:   models.PodFailure{Pod: "p", Container: "app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := classifyContainer("p", tt.status)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v (%v), want %+v (%v)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestServiceEndpoint(t *testing.T) {
+	service := func(name string, spec corev1.ServiceSpec, lb ...corev1.LoadBalancerIngress) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+			Spec:       spec,
+			Status:     corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: lb}},
+		}
+	}
+	svc, _ := newFakeService([]runtime.Object{
+		service("web", corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}}),
+		service("api", corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 8080}}}),
+		service("lb-host", corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: []corev1.ServicePort{{Port: 80}}}, corev1.LoadBalancerIngress{Hostname: "web.example.com"}),
+		service("lb-ip", corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: []corev1.ServicePort{{Port: 443}}}, corev1.LoadBalancerIngress{IP: "203.0.113.7"}),
+		service("lb-pending", corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: []corev1.ServicePort{{Port: 80}}}),
+		service("external", corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "db.example.com"}),
+	})
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"web", "http://web.shop.svc.cluster.local", false},
+		{"api", "http://api.shop.svc.cluster.local:8080", false},
+		{"lb-host", "http://web.example.com", false},
+		{"lb-ip", "http://203.0.113.7:443", false},
+		{"lb-pending", "http://lb-pending.shop.svc.cluster.local", false},
+		{"external", "db.example.com", false},
+		{"missing", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.ServiceEndpoint(context.Background(), "test", "shop", tt.name)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("got %q, %v; want %q (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiagnosePods(t *testing.T) {
+	labels := map[string]string{"app": "web"}
+	pod := func(name string, status corev1.PodStatus) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}, Status: status}
+	}
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	terminating := pod("web-old", corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+		{Name: "app", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+	}})
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	svc, _ := newFakeService([]runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		},
+		pod("web-pending", corev1.PodStatus{Conditions: []corev1.PodCondition{{
+			Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable, Message: "0/3 nodes are available",
+		}}}),
+		pod("web-pull", corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{
+			{Name: "init", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "not found"}}},
+		}}),
+		pod("web-probe", corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app", State: running}}}),
+		pod("web-ready", corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app", Ready: true, State: running}}}),
+		terminating,
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", Labels: map[string]string{"app": "other"}},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}}}}}},
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "e1", Namespace: "default"}, Reason: "Unhealthy", Message: "Readiness probe failed: 503",
+			InvolvedObject: corev1.ObjectReference{Name: "web-probe"}, LastTimestamp: metav1.NewTime(time.Now().Add(-time.Minute))},
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "e2", Namespace: "default"}, Reason: "Unhealthy", Message: "Readiness probe failed: connection refused",
+			InvolvedObject: corev1.ObjectReference{Name: "web-probe"}, LastTimestamp: metav1.NewTime(time.Now())},
+	})
+	cc, err := svc.getClient("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, kind string
+		want       []models.PodFailure
+	}{
+		{
+			name: "web",
+			kind: "Deployment",
+			want: []models.PodFailure{
+				{Pod: "web-pending", Reason: FailureUnschedulable, Message: "0/3 nodes are available"},
+				{Pod: "web-probe", Reason: FailureProbe, Message: "Readiness probe failed: connection refused"},
+				{Pod: "web-pull", Container: "init", Reason: FailureImagePull, Message: "ImagePullBackOff: not found", Fatal: true},
+			},
+		},
+		{name: "missing", kind: "Deployment"},
+		{name: "web", kind: "ReplicaSet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind+"/"+tt.name, func(t *testing.T) {
+			got := svc.diagnosePods(context.Background(), cc, "default", tt.kind, tt.name)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiagnosePods_CurrentRevision(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	controller := true
+	owner := func(kind string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: "web", UID: "web-uid", Controller: &controller}}
+	}
+	meta := func(name string, labels map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}
+	}
+	// crashing reports a crash-looping pod of the given revision
+	crashing := func(name, key, revision string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: meta(name, map[string]string{"app": "web", key: revision}), Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "app", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+		}}}
+	}
+	replicaSet := func(name, revision, hash string) *appsv1.ReplicaSet {
+		rs := &appsv1.ReplicaSet{ObjectMeta: meta(name, map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: hash})}
+		rs.Annotations = map[string]string{revisionAnnotation: revision}
+		rs.OwnerReferences = owner("Deployment")
+		return rs
+	}
+	controllerRevision := func(name string, revision int64, hash string) *appsv1.ControllerRevision {
+		cr := &appsv1.ControllerRevision{ObjectMeta: meta(name, map[string]string{"app": "web", appsv1.ControllerRevisionHashLabelKey: hash}), Revision: revision}
+		cr.OwnerReferences = owner("DaemonSet")
+		return cr
+	}
+	withUID := func(m metav1.ObjectMeta) metav1.ObjectMeta {
+		m.UID = "web-uid"
+		return m
+	}
+
+	tests := []struct {
+		name, kind string
+		objects    []runtime.Object
+		want       []string // pods reported
+	}{
+		{
+			name: "newest ReplicaSet",
+			kind: "Deployment",
+			objects: []runtime.Object{
+				&appsv1.Deployment{ObjectMeta: withUID(meta("web", nil)), Spec: appsv1.DeploymentSpec{Selector: selector}},
+				replicaSet("web-old", "1", "old"),
+				replicaSet("web-new", "2", "new"),
+				crashing("web-old-a", appsv1.DefaultDeploymentUniqueLabelKey, "old"),
+				crashing("web-new-a", appsv1.DefaultDeploymentUniqueLabelKey, "new"),
+			},
+			want: []string{"web-new-a"},
+		},
+		{
+			name: "update revision",
+			kind: "StatefulSet",
+			objects: []runtime.Object{
+				&appsv1.StatefulSet{ObjectMeta: withUID(meta("web", nil)), Spec: appsv1.StatefulSetSpec{Selector: selector},
+					Status: appsv1.StatefulSetStatus{CurrentRevision: "web-1", UpdateRevision: "web-2"}},
+				crashing("web-0", appsv1.ControllerRevisionHashLabelKey, "web-1"),
+				crashing("web-1", appsv1.ControllerRevisionHashLabelKey, "web-2"),
+			},
+			want: []string{"web-1"},
+		},
+		{
+			name: "newest ControllerRevision",
+			kind: "DaemonSet",
+			objects: []runtime.Object{
+				&appsv1.DaemonSet{ObjectMeta: withUID(meta("web", nil)), Spec: appsv1.DaemonSetSpec{Selector: selector}},
+				controllerRevision("web-aaa", 1, "aaa"),
+				controllerRevision("web-bbb", 2, "bbb"),
+				crashing("web-old", appsv1.ControllerRevisionHashLabelKey, "aaa"),
+				crashing("web-new", appsv1.ControllerRevisionHashLabelKey, "bbb"),
+			},
+			want: []string{"web-new"},
+		},
+		{
+			name: "no recorded revision inspects every pod",
+			kind: "StatefulSet",
+			objects: []runtime.Object{
+				&appsv1.StatefulSet{ObjectMeta: withUID(meta("web", nil)), Spec: appsv1.StatefulSetSpec{Selector: selector}},
+				crashing("web-0", appsv1.ControllerRevisionHashLabelKey, "web-1"),
+			},
+			want: []string{"web-0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind+"/"+tt.name, func(t *testing.T) {
+			svc, _ := newFakeService(tt.objects)
+			cc, err := svc.getClient("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range svc.diagnosePods(context.Background(), cc, "default", tt.kind, "web") {
+				got = append(got, f.Pod)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got failures of %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
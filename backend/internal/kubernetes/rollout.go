@@ -0,0 +1,541 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+const (
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+// NormalizeWorkloadKind maps user-facing kind names ("deployments", "sts", "DaemonSet", ...)
+// to the canonical kind used by rollout operations.
+func NormalizeWorkloadKind(kind string) (string, error) {
+	switch strings.ToLower(kind) {
+	case "deployment", "deployments", "deploy":
+		return "Deployment", nil
+	case "statefulset", "statefulsets", "sts":
+		return "StatefulSet", nil
+	case "daemonset", "daemonsets", "ds":
+		return "DaemonSet", nil
+	default:
+		return "", fmt.Errorf("unsupported workload kind %q (expected deployment, statefulset or daemonset)", kind)
+	}
+}
+
+// RolloutStatus reports whether the latest rollout of a workload has finished.
+func (s *k8sService) RolloutStatus(ctx context.Context, cluster, namespace, kind, name string) (*models.RolloutStatus, error) {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	kind, err = NormalizeWorkloadKind(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.RolloutStatus{Kind: kind, Name: name, Namespace: namespace}
+
+	switch kind {
+	case "Deployment":
+		d, err := cc.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting deployment: %w", err)
+		}
+		deploymentRolloutStatus(d, status)
+	case "StatefulSet":
+		sts, err := cc.client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting statefulset: %w", err)
+		}
+		statefulSetRolloutStatus(sts, status)
+	case "DaemonSet":
+		ds, err := cc.client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting daemonset: %w", err)
+		}
+		daemonSetRolloutStatus(ds, status)
+	}
+
+	if status.Revision == 0 {
+		history, err := s.RolloutHistory(ctx, cluster, namespace, kind, name)
+		if err == nil && len(history) > 0 {
+			status.Revision = history[len(history)-1].Revision
+		}
+	}
+	if !status.Complete {
+		status.Failures = s.diagnosePods(ctx, cc, namespace, kind, name)
+	}
+	return status, nil
+}
+
+// deploymentRolloutStatus mirrors the checks performed by `kubectl rollout status deployment`.
+func deploymentRolloutStatus(d *appsv1.Deployment, status *models.RolloutStatus) {
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	status.Replicas = int(desired)
+	status.UpdatedReplicas = int(d.Status.UpdatedReplicas)
+	status.ReadyReplicas = int(d.Status.ReadyReplicas)
+	status.AvailableReplicas = int(d.Status.AvailableReplicas)
+	status.Paused = d.Spec.Paused
+	status.Revision, _ = strconv.ParseInt(d.Annotations[revisionAnnotation], 10, 64)
+
+	if d.Generation > d.Status.ObservedGeneration {
+		status.Message = "Waiting for deployment spec update to be observed"
+		return
+	}
+	for _, cond := range d.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			status.Failed = true
+			status.Message = fmt.Sprintf("deployment %q exceeded its progress deadline", d.Name)
+			return
+		}
+	}
+	switch {
+	case d.Status.UpdatedReplicas < desired:
+		status.Message = fmt.Sprintf("Waiting for rollout to finish: %d out of %d new replicas have been updated", d.Status.UpdatedReplicas, desired)
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("Waiting for rollout to finish: %d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("Waiting for rollout to finish: %d of %d updated replicas are available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas)
+	default:
+		status.Complete = true
+		status.Message = fmt.Sprintf("deployment %q successfully rolled out", d.Name)
+	}
+}
+
+// statefulSetRolloutStatus mirrors the checks performed by `kubectl rollout status statefulset`.
+func statefulSetRolloutStatus(sts *appsv1.StatefulSet, status *models.RolloutStatus) {
+	desired := int32(1)
+	if sts.Spec.Replicas != nil {
+		desired = *sts.Spec.Replicas
+	}
+	status.Replicas = int(desired)
+	status.UpdatedReplicas = int(sts.Status.UpdatedReplicas)
+	status.ReadyReplicas = int(sts.Status.ReadyReplicas)
+	status.AvailableReplicas = int(sts.Status.AvailableReplicas)
+
+	if sts.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		status.Complete = true
+		status.Message = fmt.Sprintf("statefulset %q uses the %s strategy; rollout status is only tracked for RollingUpdate", sts.Name, sts.Spec.UpdateStrategy.Type)
+		return
+	}
+	if sts.Status.ObservedGeneration == 0 || sts.Generation > sts.Status.ObservedGeneration {
+		status.Message = "Waiting for statefulset spec update to be observed"
+		return
+	}
+	if sts.Status.ReadyReplicas < desired {
+		status.Message = fmt.Sprintf("Waiting for %d pods to be ready", desired-sts.Status.ReadyReplicas)
+		return
+	}
+	if ru := sts.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil && *ru.Partition > 0 {
+		if sts.Status.UpdatedReplicas < desired-*ru.Partition {
+			status.Message = fmt.Sprintf("Waiting for partitioned roll out to finish: %d out of %d new pods have been updated",
+				sts.Status.UpdatedReplicas, desired-*ru.Partition)
+			return
+		}
+		status.Complete = true
+		status.Message = fmt.Sprintf("partitioned roll out complete: %d new pods have been updated", sts.Status.UpdatedReplicas)
+		return
+	}
+	if sts.Status.UpdateRevision != sts.Status.CurrentRevision {
+		status.Message = fmt.Sprintf("Waiting for rolling update to complete %d pods at revision %s", sts.Status.UpdatedReplicas, sts.Status.UpdateRevision)
+		return
+	}
+	status.Complete = true
+	status.Message = fmt.Sprintf("statefulset rolling update complete %d pods at revision %s", sts.Status.CurrentReplicas, sts.Status.CurrentRevision)
+}
+
+// daemonSetRolloutStatus mirrors the checks performed by `kubectl rollout status daemonset`.
+func daemonSetRolloutStatus(ds *appsv1.DaemonSet, status *models.RolloutStatus) {
+	status.Replicas = int(ds.Status.DesiredNumberScheduled)
+	status.UpdatedReplicas = int(ds.Status.UpdatedNumberScheduled)
+	status.ReadyReplicas = int(ds.Status.NumberReady)
+	status.AvailableReplicas = int(ds.Status.NumberAvailable)
+
+	if ds.Spec.UpdateStrategy.Type != appsv1.RollingUpdateDaemonSetStrategyType {
+		status.Complete = true
+		status.Message = fmt.Sprintf("daemonset %q uses the %s strategy; rollout status is only tracked for RollingUpdate", ds.Name, ds.Spec.UpdateStrategy.Type)
+		return
+	}
+	if ds.Generation > ds.Status.ObservedGeneration {
+		status.Message = "Waiting for daemon set spec update to be observed"
+		return
+	}
+	switch {
+	case ds.Status.UpdatedNumberScheduled < ds.Status.DesiredNumberScheduled:
+		status.Message = fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d out of %d new pods have been updated",
+			ds.Name, ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)
+	case ds.Status.NumberAvailable < ds.Status.DesiredNumberScheduled:
+		status.Message = fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d of %d updated pods are available",
+			ds.Name, ds.Status.NumberAvailable, ds.Status.DesiredNumberScheduled)
+	default:
+		status.Complete = true
+		status.Message = fmt.Sprintf("daemon set %q successfully rolled out", ds.Name)
+	}
+}
+
+// RolloutHistory lists the recorded revisions of a workload, oldest first.
+// Deployments are backed by their ReplicaSets; StatefulSets and DaemonSets by ControllerRevisions.
+func (s *k8sService) RolloutHistory(ctx context.Context, cluster, namespace, kind, name string) ([]models.RolloutRevision, error) {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	kind, err = NormalizeWorkloadKind(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.RolloutRevision
+	switch kind {
+	case "Deployment":
+		replicaSets, err := s.deploymentReplicaSets(ctx, cc, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, rs := range replicaSets {
+			rev, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+			if err != nil {
+				continue
+			}
+			result = append(result, models.RolloutRevision{
+				Revision:    rev,
+				ChangeCause: rs.Annotations[changeCauseAnnotation],
+				Images:      templateImages(rs.Spec.Template.Spec.Containers),
+				CreatedAt:   rs.CreationTimestamp.Time,
+			})
+		}
+	case "StatefulSet", "DaemonSet":
+		revisions, err := s.controllerRevisions(ctx, cc, namespace, kind, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, cr := range revisions {
+			result = append(result, models.RolloutRevision{
+				Revision:    cr.Revision,
+				ChangeCause: cr.Annotations[changeCauseAnnotation],
+				Images:      controllerRevisionImages(cr),
+				CreatedAt:   cr.CreationTimestamp.Time,
+			})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Revision < result[j].Revision })
+	if len(result) > 0 {
+		result[len(result)-1].Current = true
+	}
+	if result == nil {
+		result = []models.RolloutRevision{}
+	}
+	return result, nil
+}
+
+// UndoRollout rolls a workload back to the given revision. A revision of 0 means the previous one.
+func (s *k8sService) UndoRollout(ctx context.Context, cluster, namespace, kind, name string, revision int64) error {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return err
+	}
+	kind, err = NormalizeWorkloadKind(kind)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case "Deployment":
+		d, err := cc.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("getting deployment: %w", err)
+		}
+		if d.Spec.Paused {
+			return fmt.Errorf("cannot roll back a paused deployment; resume it first")
+		}
+		replicaSets, err := s.deploymentReplicaSets(ctx, cc, namespace, name)
+		if err != nil {
+			return err
+		}
+		revisions := make(map[int64]*appsv1.ReplicaSet, len(replicaSets))
+		nums := make([]int64, 0, len(replicaSets))
+		for i := range replicaSets {
+			rev, err := strconv.ParseInt(replicaSets[i].Annotations[revisionAnnotation], 10, 64)
+			if err != nil {
+				continue
+			}
+			revisions[rev] = &replicaSets[i]
+			nums = append(nums, rev)
+		}
+		target, err := pickRevision(nums, revision)
+		if err != nil {
+			return err
+		}
+		rs := revisions[target]
+
+		tmpl := rs.Spec.Template.DeepCopy()
+		delete(tmpl.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+		ops := []map[string]interface{}{
+			{"op": "replace", "path": "/spec/template", "value": tmpl},
+		}
+		if cause := rs.Annotations[changeCauseAnnotation]; cause != "" {
+			if d.Annotations == nil {
+				ops = append(ops, map[string]interface{}{
+					"op": "add", "path": "/metadata/annotations",
+					"value": map[string]string{changeCauseAnnotation: cause},
+				})
+			} else {
+				ops = append(ops, map[string]interface{}{
+					"op": "add", "path": "/metadata/annotations/" + escapeJSONPointer(changeCauseAnnotation),
+					"value": cause,
+				})
+			}
+		}
+		patch, err := json.Marshal(ops)
+		if err != nil {
+			return fmt.Errorf("building rollback patch: %w", err)
+		}
+		if _, err := cc.client.AppsV1().Deployments(namespace).Patch(ctx, name, types.JSONPatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("rolling back deployment to revision %d: %w", target, err)
+		}
+		return nil
+
+	default:
+		revisions, err := s.controllerRevisions(ctx, cc, namespace, kind, name)
+		if err != nil {
+			return err
+		}
+		byRev := make(map[int64]*appsv1.ControllerRevision, len(revisions))
+		nums := make([]int64, 0, len(revisions))
+		for i := range revisions {
+			byRev[revisions[i].Revision] = &revisions[i]
+			nums = append(nums, revisions[i].Revision)
+		}
+		target, err := pickRevision(nums, revision)
+		if err != nil {
+			return err
+		}
+		// ControllerRevision data is a strategic merge patch of the workload template.
+		patch := byRev[target].Data.Raw
+		if kind == "StatefulSet" {
+			_, err = cc.client.AppsV1().StatefulSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		} else {
+			_, err = cc.client.AppsV1().DaemonSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("rolling back %s to revision %d: %w", strings.ToLower(kind), target, err)
+		}
+		return nil
+	}
+}
+
+// PauseRollout marks a Deployment as paused so template changes do not trigger a rollout.
+func (s *k8sService) PauseRollout(ctx context.Context, cluster, namespace, kind, name string) error {
+	return s.setPaused(ctx, cluster, namespace, kind, name, true)
+}
+
+// ResumeRollout resumes a paused Deployment.
+func (s *k8sService) ResumeRollout(ctx context.Context, cluster, namespace, kind, name string) error {
+	return s.setPaused(ctx, cluster, namespace, kind, name, false)
+}
+
+func (s *k8sService) setPaused(ctx context.Context, cluster, namespace, kind, name string, paused bool) error {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return err
+	}
+	kind, err = NormalizeWorkloadKind(kind)
+	if err != nil {
+		return err
+	}
+	if kind != "Deployment" {
+		return fmt.Errorf("pause/resume is not supported for %s", kind)
+	}
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"paused":%t}}`, paused))
+	if _, err := cc.client.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("patching deployment paused=%t: %w", paused, err)
+	}
+	return nil
+}
+
+// RestartRollout triggers a rolling restart by stamping the pod template with
+// the kubectl.kubernetes.io/restartedAt annotation, the same way `kubectl rollout restart` does.
+func (s *k8sService) RestartRollout(ctx context.Context, cluster, namespace, kind, name string) error {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return err
+	}
+	kind, err = NormalizeWorkloadKind(kind)
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						restartedAtAnnotation: time.Now().Format(time.RFC3339),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("building restart patch: %w", err)
+	}
+
+	switch kind {
+	case "Deployment":
+		d, err := cc.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("getting deployment: %w", err)
+		}
+		if d.Spec.Paused {
+			return fmt.Errorf("cannot restart a paused deployment; resume it first")
+		}
+		_, err = cc.client.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("restarting deployment: %w", err)
+		}
+	case "StatefulSet":
+		if _, err := cc.client.AppsV1().StatefulSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("restarting statefulset: %w", err)
+		}
+	case "DaemonSet":
+		if _, err := cc.client.AppsV1().DaemonSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("restarting daemonset: %w", err)
+		}
+	}
+	return nil
+}
+
+// deploymentReplicaSets returns the ReplicaSets controlled by the named Deployment.
+func (s *k8sService) deploymentReplicaSets(ctx context.Context, cc *clusterClient, namespace, name string) ([]appsv1.ReplicaSet, error) {
+	d, err := cc.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("getting deployment: %w", err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing deployment selector: %w", err)
+	}
+	rsList, err := cc.client.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("listing replicasets: %w", err)
+	}
+
+	owned := make([]appsv1.ReplicaSet, 0, len(rsList.Items))
+	for _, rs := range rsList.Items {
+		if ref := metav1.GetControllerOf(&rs); ref != nil && ref.UID == d.UID {
+			owned = append(owned, rs)
+		}
+	}
+	return owned, nil
+}
+
+// controllerRevisions returns the ControllerRevisions owned by the named StatefulSet or DaemonSet.
+func (s *k8sService) controllerRevisions(ctx context.Context, cc *clusterClient, namespace, kind, name string) ([]appsv1.ControllerRevision, error) {
+	var uid types.UID
+	var labelSelector *metav1.LabelSelector
+	if kind == "StatefulSet" {
+		sts, err := cc.client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting statefulset: %w", err)
+		}
+		uid, labelSelector = sts.UID, sts.Spec.Selector
+	} else {
+		ds, err := cc.client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting daemonset: %w", err)
+		}
+		uid, labelSelector = ds.UID, ds.Spec.Selector
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s selector: %w", strings.ToLower(kind), err)
+	}
+	crList, err := cc.client.AppsV1().ControllerRevisions(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("listing controller revisions: %w", err)
+	}
+
+	owned := make([]appsv1.ControllerRevision, 0, len(crList.Items))
+	for _, cr := range crList.Items {
+		if ref := metav1.GetControllerOf(&cr); ref != nil && ref.UID == uid {
+			owned = append(owned, cr)
+		}
+	}
+	return owned, nil
+}
+
+// pickRevision resolves the requested revision against the available ones.
+// 0 selects the revision immediately before the latest.
+func pickRevision(available []int64, requested int64) (int64, error) {
+	if len(available) == 0 {
+		return 0, fmt.Errorf("no rollout history found")
+	}
+	sort.Slice(available, func(i, j int) bool { return available[i] < available[j] })
+	if requested == 0 {
+		if len(available) < 2 {
+			return 0, fmt.Errorf("no previous revision to roll back to")
+		}
+		return available[len(available)-2], nil
+	}
+	for _, rev := range available {
+		if rev == requested {
+			if rev == available[len(available)-1] {
+				return 0, fmt.Errorf("revision %d is already the current revision", rev)
+			}
+			return rev, nil
+		}
+	}
+	return 0, fmt.Errorf("revision %d not found", requested)
+}
+
+func templateImages(containers []corev1.Container) []string {
+	images := make([]string, 0, len(containers))
+	for _, c := range containers {
+		images = append(images, c.Image)
+	}
+	return images
+}
+
+// controllerRevisionImages extracts container images from the template patch stored in a ControllerRevision.
+func controllerRevisionImages(cr appsv1.ControllerRevision) []string {
+	var data struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					Containers []corev1.Container `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(cr.Data.Raw, &data); err != nil {
+		return []string{}
+	}
+	return templateImages(data.Spec.Template.Spec.Containers)
+}
+
+// escapeJSONPointer escapes a map key for use in a JSON Patch path (RFC 6901).
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,121 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// SnapshotManifest records the current live state (or absence) of every object in the
+// manifest so that a failed deploy can be reverted with RestoreSnapshot.
+func (s *k8sService) SnapshotManifest(ctx context.Context, cluster string, yamlContent string) ([]models.ResourceSnapshot, error) {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if cc.dynClient == nil {
+		return nil, fmt.Errorf("cluster %q has no dynamic client", cluster)
+	}
+
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return nil, err
+	}
+
+	snaps := make([]models.ResourceSnapshot, 0, len(objs))
+	for _, obj := range objs {
+		dr, err := s.resourceFor(cc, obj)
+		if err != nil {
+			return nil, err
+		}
+		snap := models.ResourceSnapshot{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Name:       obj.GetName(),
+			Namespace:  obj.GetNamespace(),
+		}
+
+		live, err := dr.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("getting live %s %q: %w", obj.GetKind(), obj.GetName(), err)
+			}
+			snaps = append(snaps, snap)
+			continue
+		}
+
+		data, err := json.Marshal(live.Object)
+		if err != nil {
+			return nil, fmt.Errorf("encoding live %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		snap.Existed = true
+		snap.Object = string(data)
+		snaps = append(snaps, snap)
+	}
+	return snaps, nil
+}
+
+// RestoreSnapshot reverts an object to its snapshotted state: objects that did not exist
+// are deleted, existing ones are replaced with their previous spec.
+func (s *k8sService) RestoreSnapshot(ctx context.Context, cluster string, snap models.ResourceSnapshot) error {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return err
+	}
+	if cc.dynClient == nil {
+		return fmt.Errorf("cluster %q has no dynamic client", cluster)
+	}
+
+	target := &unstructured.Unstructured{}
+	target.SetAPIVersion(snap.APIVersion)
+	target.SetKind(snap.Kind)
+	target.SetName(snap.Name)
+	target.SetNamespace(snap.Namespace)
+	dr, err := s.resourceFor(cc, target)
+	if err != nil {
+		return err
+	}
+
+	if !snap.Existed {
+		err := dr.Delete(ctx, snap.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("deleting %s %q: %w", snap.Kind, snap.Name, err)
+		}
+		return nil
+	}
+
+	prior := &unstructured.Unstructured{}
+	if err := json.Unmarshal([]byte(snap.Object), &prior.Object); err != nil {
+		return fmt.Errorf("decoding snapshot of %s %q: %w", snap.Kind, snap.Name, err)
+	}
+	prior.SetManagedFields(nil)
+	prior.SetGeneration(0)
+	unstructured.RemoveNestedField(prior.Object, "status")
+
+	current, err := dr.Get(ctx, snap.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		// Deleted in the meantime — recreate from the snapshot
+		prior.SetResourceVersion("")
+		prior.SetUID("")
+		if _, err := dr.Create(ctx, prior, metav1.CreateOptions{FieldManager: "hybrid-cloud-dashboard"}); err != nil {
+			return fmt.Errorf("recreating %s %q: %w", snap.Kind, snap.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting %s %q: %w", snap.Kind, snap.Name, err)
+	}
+
+	// Replace (PUT) rather than apply so that fields added by the failed deploy are dropped too
+	prior.SetResourceVersion(current.GetResourceVersion())
+	if _, err := dr.Update(ctx, prior, metav1.UpdateOptions{FieldManager: "hybrid-cloud-dashboard"}); err != nil {
+		return fmt.Errorf("restoring %s %q: %w", snap.Kind, snap.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigyaml "sigs.k8s.io/yaml"
+)
+
+// Labels that tell apart the Deployments of a blue/green or canary rollout. They are
+// added to the selector and pod template of the variant Deployment, so its pods never
+// belong to the original one.
+const (
+	LabelSlot  = "hybrid-cloud-dashboard/slot"  // blue/green: "blue" or "green"
+	LabelTrack = "hybrid-cloud-dashboard/track" // canary: "canary"
+)
+
+// Variant is a manifest rewritten to run a Deployment beside the original one.
+type Variant struct {
+	Manifest string // the rewritten manifest
+	Name     string // name of the variant Deployment ("<name>-<value>")
+	Original string // name of the original Deployment
+	Selector string // label selector of the variant's pods
+	Replicas int    // replicas of the original Deployment (1 when unset)
+}
+
+// DeploymentVariant rewrites the Deployment of a manifest into a variant named
+// "<name>-<value>" whose selector and pod template carry label=value. With replicas > 0
+// the variant runs that many replicas. Other objects are left untouched.
+func DeploymentVariant(yamlContent, label, value string, replicas int) (*Variant, error) {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Variant{}
+	for _, obj := range objs {
+		if obj.GetKind() != "Deployment" || v.Name != "" {
+			continue
+		}
+		v.Original = obj.GetName()
+		v.Name = obj.GetName() + "-" + value
+		v.Replicas = 1
+		// Decoded YAML holds numbers as float64, objects built in code as int64
+		replicasField, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "replicas")
+		switch n := replicasField.(type) {
+		case int64:
+			v.Replicas = int(n)
+		case float64:
+			v.Replicas = int(n)
+		}
+
+		matchLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector", "matchLabels")
+		if matchLabels == nil {
+			matchLabels = map[string]string{}
+		}
+		matchLabels[label] = value
+		podLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+		if podLabels == nil {
+			podLabels = map[string]string{}
+		}
+		podLabels[label] = value
+
+		obj.SetName(v.Name)
+		if err := unstructured.SetNestedStringMap(obj.Object, matchLabels, "spec", "selector", "matchLabels"); err != nil {
+			return nil, err
+		}
+		if err := unstructured.SetNestedStringMap(obj.Object, podLabels, "spec", "template", "metadata", "labels"); err != nil {
+			return nil, err
+		}
+		if replicas > 0 {
+			if err := unstructured.SetNestedField(obj.Object, int64(replicas), "spec", "replicas"); err != nil {
+				return nil, err
+			}
+		}
+		v.Selector = labelSelector(matchLabels)
+	}
+	if v.Name == "" {
+		return nil, fmt.Errorf("manifest has no Deployment")
+	}
+
+	v.Manifest, err = encodeManifests(objs)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// SelectSlot adds label=value to the selector of every Service in a manifest, so the
+// Services route to one slot of a blue/green rollout only.
+func SelectSlot(yamlContent, label, value string) (string, error) {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return "", err
+	}
+	for _, obj := range objs {
+		if obj.GetKind() != "Service" {
+			continue
+		}
+		selector, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector")
+		if selector == nil {
+			selector = map[string]string{}
+		}
+		selector[label] = value
+		if err := unstructured.SetNestedStringMap(obj.Object, selector, "spec", "selector"); err != nil {
+			return "", err
+		}
+	}
+	return encodeManifests(objs)
+}
+
+// RetargetScaler points every HorizontalPodAutoscaler of a manifest that scales the
+// Deployment from to the Deployment to.
+func RetargetScaler(yamlContent, from, to string) (string, error) {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return "", err
+	}
+	for _, obj := range objs {
+		if obj.GetKind() != "HorizontalPodAutoscaler" {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "scaleTargetRef", "kind")
+		name, _, _ := unstructured.NestedString(obj.Object, "spec", "scaleTargetRef", "name")
+		if kind == "Deployment" && name == from {
+			if err := unstructured.SetNestedField(obj.Object, to, "spec", "scaleTargetRef", "name"); err != nil {
+				return "", err
+			}
+		}
+	}
+	return encodeManifests(objs)
+}
+
+// ManifestObjectName returns the name of the first object of the kind in a manifest,
+// or "" when there is none.
+func ManifestObjectName(yamlContent, kind string) string {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return ""
+	}
+	for _, obj := range objs {
+		if obj.GetKind() == kind {
+			return obj.GetName()
+		}
+	}
+	return ""
+}
+
+func encodeManifests(objs []*unstructured.Unstructured) (string, error) {
+	docs := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		data, err := sigyaml.Marshal(obj.Object)
+		if err != nil {
+			return "", fmt.Errorf("encoding %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		docs = append(docs, string(data))
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
+// labelSelector formats labels as an equality-based selector with sorted keys.
+func labelSelector(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+labels[k])
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,256 @@
+package kubernetes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+// podMetricsGVR is the metrics-server resource with the current usage of each pod.
+var podMetricsGVR = schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+
+// cAdvisor counters of the CFS bandwidth control, read through the API server's
+// node proxy.
+const (
+	cfsPeriodsMetric   = "container_cpu_cfs_periods_total"
+	cfsThrottledMetric = "container_cpu_cfs_throttled_periods_total"
+)
+
+// SampleUsage observes the pods matching the label selector: their CPU and memory
+// usage from metrics-server, the restarts and last termination reason of their
+// containers, and the CFS throttling counters from the cAdvisor metrics of their
+// nodes. Missing metrics-server or cAdvisor access is reported on the sample, not as
+// an error.
+func (s *k8sService) SampleUsage(ctx context.Context, cluster, namespace, labelSelector string) (*models.UsageSample, error) {
+	cc, err := s.getClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if cc.client == nil {
+		return nil, fmt.Errorf("cluster %q is not connected", cluster)
+	}
+
+	pods, err := cc.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	sample := &models.UsageSample{Time: time.Now().UTC()}
+	index := make(map[string]int, len(pods.Items))
+	nodes := map[string]bool{}
+	for _, p := range pods.Items {
+		if p.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		pod := models.PodUsage{Name: p.Name}
+		for _, cs := range p.Status.ContainerStatuses {
+			c := models.ContainerUsage{Name: cs.Name, RestartCount: int(cs.RestartCount)}
+			if t := cs.LastTerminationState.Terminated; t != nil {
+				c.LastTermination = t.Reason
+			}
+			pod.Containers = append(pod.Containers, c)
+		}
+		index[p.Name] = len(sample.Pods)
+		sample.Pods = append(sample.Pods, pod)
+		if p.Spec.NodeName != "" {
+			nodes[p.Spec.NodeName] = true
+		}
+	}
+	if len(sample.Pods) == 0 {
+		return sample, nil
+	}
+
+	if cc.dynClient != nil {
+		list, err := cc.dynClient.Resource(podMetricsGVR).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+		if err == nil {
+			sample.MetricsAvailable = true
+			for _, item := range list.Items {
+				i, ok := index[item.GetName()]
+				if !ok {
+					continue
+				}
+				containers, _, _ := unstructured.NestedSlice(item.Object, "containers")
+				for _, raw := range containers {
+					c, _ := raw.(map[string]interface{})
+					usage, _ := c["usage"].(map[string]interface{})
+					if q, err := resource.ParseQuantity(fmt.Sprint(usage["cpu"])); err == nil {
+						sample.Pods[i].CPUMillis += q.MilliValue()
+					}
+					if q, err := resource.ParseQuantity(fmt.Sprint(usage["memory"])); err == nil {
+						sample.Pods[i].MemoryBytes += q.Value()
+					}
+				}
+				sample.Pods[i].HasMetrics = true
+			}
+		}
+	}
+
+	for node := range nodes {
+		raw, err := cc.client.CoreV1().RESTClient().Get().
+			AbsPath("/api/v1/nodes", node, "proxy/metrics/cadvisor").
+			Do(ctx).Raw()
+		if err != nil {
+			continue
+		}
+		sample.ThrottleAvailable = true
+		for key, counters := range parseCFSCounters(raw, namespace) {
+			i, ok := index[key.pod]
+			if !ok {
+				continue
+			}
+			for j := range sample.Pods[i].Containers {
+				if c := &sample.Pods[i].Containers[j]; c.Name == key.container {
+					c.CFSPeriods, c.ThrottledPeriods = counters.periods, counters.throttled
+				}
+			}
+		}
+	}
+	return sample, nil
+}
+
+// WorkloadSelector returns the pod selector (spec.selector.matchLabels) of the first
+// Deployment, StatefulSet or DaemonSet in a manifest as a label selector, or "" when
+// there is none.
+func WorkloadSelector(yamlContent string) string {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return ""
+	}
+	for _, obj := range objs {
+		switch obj.GetKind() {
+		case "Deployment", "StatefulSet", "DaemonSet":
+			matchLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector", "matchLabels")
+			if len(matchLabels) > 0 {
+				return labelSelector(matchLabels)
+			}
+		}
+	}
+	return ""
+}
+
+// WorkloadSizing returns the requests and limits of the first container and the
+// replica count of the first Deployment or StatefulSet in a manifest. Replicas is 1
+// when the manifest leaves it unset and 0 when there is no such workload.
+func WorkloadSizing(yamlContent string) (models.ResourceSizing, int) {
+	objs, err := decodeManifests(yamlContent)
+	if err != nil {
+		return models.ResourceSizing{}, 0
+	}
+	for _, obj := range objs {
+		if obj.GetKind() != "Deployment" && obj.GetKind() != "StatefulSet" {
+			continue
+		}
+		replicas := 1
+		// Decoded YAML holds numbers as float64, objects built in code as int64
+		replicasField, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "replicas")
+		switch n := replicasField.(type) {
+		case int64:
+			replicas = int(n)
+		case float64:
+			replicas = int(n)
+		}
+		var sizing models.ResourceSizing
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+		if len(containers) > 0 {
+			c, _ := containers[0].(map[string]interface{})
+			quantity := func(fields ...string) string {
+				v, _, _ := unstructured.NestedFieldNoCopy(c, fields...)
+				if v == nil {
+					return ""
+				}
+				return fmt.Sprint(v)
+			}
+			sizing = models.ResourceSizing{
+				CPURequest:    quantity("resources", "requests", "cpu"),
+				CPULimit:      quantity("resources", "limits", "cpu"),
+				MemoryRequest: quantity("resources", "requests", "memory"),
+				MemoryLimit:   quantity("resources", "limits", "memory"),
+			}
+		}
+		return sizing, replicas
+	}
+	return models.ResourceSizing{}, 0
+}
+
+type containerKey struct {
+	pod, container string
+}
+
+type cfsCounters struct {
+	periods, throttled int64
+}
+
+// parseCFSCounters reads the CFS period counters of the containers in the namespace
+// from cAdvisor's Prometheus text output.
+func parseCFSCounters(raw []byte, namespace string) map[containerKey]cfsCounters {
+	out := map[containerKey]cfsCounters{}
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, cfsPeriodsMetric+"{") && !strings.HasPrefix(line, cfsThrottledMetric+"{") {
+			continue
+		}
+		open, end := strings.IndexByte(line, '{'), strings.LastIndexByte(line, '}')
+		if end < open {
+			continue
+		}
+		labels := parseMetricLabels(line[open+1 : end])
+		if labels["namespace"] != namespace || labels["pod"] == "" || labels["container"] == "" {
+			continue
+		}
+		fields := strings.Fields(line[end+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		key := containerKey{pod: labels["pod"], container: labels["container"]}
+		c := out[key]
+		if line[:open] == cfsPeriodsMetric {
+			c.periods = int64(value)
+		} else {
+			c.throttled = int64(value)
+		}
+		out[key] = c
+	}
+	return out
+}
+
+// parseMetricLabels parses the label set of a Prometheus sample: name="value" pairs
+// separated by commas, with backslash escapes in the values.
+func parseMetricLabels(s string) map[string]string {
+	labels := map[string]string{}
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+			break
+		}
+		name := strings.TrimSpace(s[:eq])
+		var value strings.Builder
+		i := eq + 2
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			value.WriteByte(s[i])
+		}
+		labels[name] = value.String()
+		s = strings.TrimPrefix(s[min(i+1, len(s)):], ",")
+	}
+	return labels
+}
@@ -0,0 +1,215 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/config"
+	"github.com/seyunpark/hybrid_cloud_dashboard/internal/docker"
+	"github.com/seyunpark/hybrid_cloud_dashboard/pkg/models"
+)
+
+const (
+	// defaultHeadroom applies when Profile is called without a sampler.
+	defaultHeadroom = 0.2
+	// Floors of the derived requests, so idle containers still get schedulable values.
+	minCPURequestMillis = 10
+	minMemoryRequest    = 32 << 20
+)
+
+// usagePoint is one CPU and memory sample of a container.
+type usagePoint struct {
+	at          time.Time
+	cpuMillis   int64
+	memoryBytes int64
+}
+
+// Sampler periodically records the CPU and memory usage of every running Docker
+// container and keeps the samples of the last window, from which Profile derives
+// requests and limits.
+type Sampler struct {
+	interval time.Duration
+	window   time.Duration
+	headroom float64
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	docker docker.Service
+
+	mu     sync.RWMutex
+	series map[string][]usagePoint // container ID → samples, oldest first
+}
+
+// NewSampler creates a usage sampler with the given configuration.
+func NewSampler(cfg config.DockerSamplingConfig, dockerSvc docker.Service) *Sampler {
+	return &Sampler{
+		interval: time.Duration(cfg.Interval) * time.Second,
+		window:   time.Duration(cfg.Window) * time.Second,
+		headroom: cfg.Headroom,
+		docker:   dockerSvc,
+		series:   map[string][]usagePoint{},
+	}
+}
+
+// Start begins sampling in a background goroutine.
+func (s *Sampler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.sample(ctx)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sample(ctx)
+			}
+		}
+	}()
+
+	slog.Info("usage sampler started", "interval", s.interval.String(), "window", s.window.String())
+}
+
+// Stop shuts down the sampler and waits for a running pass.
+func (s *Sampler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	slog.Info("usage sampler stopped")
+}
+
+// sample records one point for every running container and drops the points of
+// containers that stopped and those older than the window.
+func (s *Sampler) sample(ctx context.Context) {
+	if s.docker == nil {
+		return
+	}
+	containers, err := s.docker.ListContainers(ctx, false)
+	if err != nil {
+		slog.Debug("sampler: failed to list containers", "error", err)
+		return
+	}
+
+	running := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		if ctx.Err() != nil {
+			return
+		}
+		running[c.ID] = true
+		stats, err := s.docker.ContainerStats(ctx, c.ID)
+		if err != nil {
+			slog.Debug("sampler: failed to read container stats", "container", c.ID, "error", err)
+			continue
+		}
+		s.record(c.ID, time.Now(), stats)
+	}
+
+	s.mu.Lock()
+	for id := range s.series {
+		if !running[id] {
+			delete(s.series, id)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// record adds a stats sample of a container taken at the given time.
+func (s *Sampler) record(containerID string, at time.Time, stats *models.ContainerStats) {
+	point := usagePoint{at: at, cpuMillis: cpuMillis(stats.CPUPercent), memoryBytes: stats.MemoryUsage}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	points := append(s.series[containerID], point)
+	cutoff := at.Add(-s.window)
+	i := sort.Search(len(points), func(i int) bool { return !points[i].at.Before(cutoff) })
+	s.series[containerID] = points[i:]
+}
+
+// Profile returns the usage profile of a container from its samples in the window.
+// Without samples the current stats, if any, count as the only sample; nil is
+// returned when there is neither.
+func (s *Sampler) Profile(containerID string, current *models.ContainerStats) *models.UsageProfile {
+	var points []usagePoint
+	headroom := defaultHeadroom
+	if s != nil {
+		s.mu.RLock()
+		points = append(points, s.series[containerID]...)
+		s.mu.RUnlock()
+		headroom = s.headroom
+	}
+	if len(points) == 0 {
+		if current == nil {
+			return nil
+		}
+		now := time.Now().UTC()
+		points = []usagePoint{{at: now, cpuMillis: cpuMillis(current.CPUPercent), memoryBytes: current.MemoryUsage}}
+	}
+
+	cpu := make([]int64, len(points))
+	memory := make([]int64, len(points))
+	for i, p := range points {
+		cpu[i], memory[i] = p.cpuMillis, p.memoryBytes
+	}
+	profile := &models.UsageProfile{
+		Samples:  len(points),
+		From:     points[0].at.UTC(),
+		To:       points[len(points)-1].at.UTC(),
+		CPU:      Percentiles(cpu),
+		Memory:   Percentiles(memory),
+		Headroom: headroom,
+	}
+	profile.Suggested = RightSize(profile.CPU, profile.Memory, headroom)
+	return profile
+}
+
+// RightSize derives requests and limits from sampled usage: requests are the p95 plus
+// headroom, CPU limits leave room for bursts (at least twice the request) and memory
+// limits stay above the observed maximum (at least 1.5 times the request). CPU is
+// rounded up to 10m and memory to whole MiB.
+func RightSize(cpu, memory models.UsagePercentiles, headroom float64) models.ResourceSizing {
+	cpuRequest := max(roundUp(float64(cpu.P95)*(1+headroom), 10), minCPURequestMillis)
+	cpuLimit := max(roundUp(float64(cpu.Max)*(1+headroom), 10), 2*cpuRequest)
+	memRequest := max(roundUp(float64(memory.P95)*(1+headroom), 1<<20), minMemoryRequest)
+	memLimit := max(roundUp(float64(memory.Max)*(1+headroom), 1<<20), roundUp(float64(memRequest)*1.5, 1<<20))
+	return models.ResourceSizing{
+		CPURequest:    fmt.Sprintf("%dm", cpuRequest),
+		CPULimit:      fmt.Sprintf("%dm", cpuLimit),
+		MemoryRequest: fmt.Sprintf("%dMi", memRequest>>20),
+		MemoryLimit:   fmt.Sprintf("%dMi", memLimit>>20),
+	}
+}
+
+// Percentiles returns the nearest-rank p50, p95 and maximum of the values.
+func Percentiles(values []int64) models.UsagePercentiles {
+	if len(values) == 0 {
+		return models.UsagePercentiles{}
+	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) int64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return sorted[max(i, 0)]
+	}
+	return models.UsagePercentiles{P50: rank(0.50), P95: rank(0.95), Max: sorted[len(sorted)-1]}
+}
+
+// cpuMillis converts Docker's CPU percentage (100% = one core) to millicores.
+func cpuMillis(percent float64) int64 {
+	return int64(math.Round(percent * 10))
+}
+
+func roundUp(v float64, unit int64) int64 {
+	return int64(math.Ceil(v/float64(unit))) * unit
+}
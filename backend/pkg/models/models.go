@@ -32,6 +32,7 @@ type ContainerStats struct {
 
 type ContainerDetail struct {
 	Container
+	ImageID string           `json:"image_id,omitempty"` // content digest of the local image
 	Config  ContainerConfig  `json:"config"`
 	Mounts  []Mount          `json:"mounts"`
 	Network NetworkInfo      `json:"network"`
@@ -137,13 +138,182 @@ type ServicePort struct {
 	Protocol   string `json:"protocol"`
 }
 
+// RolloutStatus reports the progress of the latest rollout of a Deployment, StatefulSet or DaemonSet.
+
+type RolloutStatus struct {
+	Kind              string       `json:"kind"`
+	Name              string       `json:"name"`
+	Namespace         string       `json:"namespace"`
+	Revision          int64        `json:"revision"`
+	Replicas          int          `json:"replicas"`
+	UpdatedReplicas   int          `json:"updated_replicas"`
+	ReadyReplicas     int          `json:"ready_replicas"`
+	AvailableReplicas int          `json:"available_replicas"`
+	Paused            bool         `json:"paused"`
+	Complete          bool         `json:"complete"`
+	Failed            bool         `json:"failed"`
+	Message           string       `json:"message"`
+	Failures          []PodFailure `json:"failures,omitempty"`
+}
+
+// PodFailure explains why a pod of a workload is not becoming ready.
+// Fatal failures (e.g. CrashLoopBackOff) are not expected to resolve on their own.
+type PodFailure struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container,omitempty"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
+	Fatal     bool   `json:"fatal"`
+}
+
+// RolloutRevision is one entry of a workload's rollout history.
+type RolloutRevision struct {
+	Revision    int64     `json:"revision"`
+	ChangeCause string    `json:"change_cause,omitempty"`
+	Images      []string  `json:"images"`
+	CreatedAt   time.Time `json:"created_at"`
+	Current     bool      `json:"current"`
+}
+
+type RolloutUndoRequest struct {
+	Revision int64 `json:"revision"`
+}
+
+// AppliedResource is the outcome of applying one object from a manifest.
+// Status is one of "applied", "failed" or "skipped" (not attempted after an earlier failure).
+type AppliedResource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ResourceSnapshot records the live state of an object before a deploy touched it,
+// so the deploy can be rolled back. Object holds the prior live object as JSON.
+type ResourceSnapshot struct {
+	APIVersion string `json:"api_version"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+	Existed    bool   `json:"existed"`
+	Object     string `json:"object,omitempty"`
+}
+
+// ResourceDrift compares one object of a stored manifest with its live state. Changes
+// list only fields the manifest sets; Before is the stored value, After the live one.
+type ResourceDrift struct {
+	Service   string        `json:"service,omitempty"`
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace,omitempty"`
+	Status    string        `json:"status"` // "in_sync", "drifted", "missing", "error"
+	Changes   []FieldChange `json:"changes,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// DriftReport is the latest drift check of one single or stack deploy.
+// Status is "drifted" when any object drifted or is missing, "error" when an object
+// could not be checked, and "in_sync" otherwise.
+type DriftReport struct {
+	DeployID  string          `json:"deploy_id"`
+	Type      string          `json:"type"` // "single" or "stack"
+	Cluster   string          `json:"cluster"`
+	Status    string          `json:"status"`
+	Resources []ResourceDrift `json:"resources"`
+	CheckedAt time.Time       `json:"checked_at"`
+}
+
+// UsageSample is one observation of the pods of a workload, taken by the post-deploy
+// feedback job. MetricsAvailable is false when metrics-server did not answer, and
+// ThrottleAvailable is false when the nodes' cAdvisor metrics could not be read.
+type UsageSample struct {
+	Time              time.Time  `json:"time"`
+	Pods              []PodUsage `json:"pods"`
+	MetricsAvailable  bool       `json:"metrics_available"`
+	ThrottleAvailable bool       `json:"throttle_available"`
+}
+
+// PodUsage is the usage and container state of one pod in a UsageSample. CPU and
+// memory are summed over the containers and set when HasMetrics is true.
+type PodUsage struct {
+	Name        string           `json:"name"`
+	CPUMillis   int64            `json:"cpu_millicores"`
+	MemoryBytes int64            `json:"memory_bytes"`
+	HasMetrics  bool             `json:"has_metrics"`
+	Containers  []ContainerUsage `json:"containers"`
+}
+
+// ContainerUsage is the state of one container of a pod. The CFS counters are
+// cumulative since the container started.
+type ContainerUsage struct {
+	Name             string `json:"name"`
+	RestartCount     int    `json:"restart_count"`
+	LastTermination  string `json:"last_termination,omitempty"` // reason, e.g. "OOMKilled"
+	CFSPeriods       int64  `json:"cfs_periods,omitempty"`
+	ThrottledPeriods int64  `json:"throttled_periods,omitempty"`
+}
+
+// Ownership identifies the dashboard deploy that applied an object. It is stamped on
+// every applied object as labels (IDs) and annotations (source container/image).
+// Single deploys set DeployID; stack deploys set StackID and Service.
+type Ownership struct {
+	DeployID        string `json:"deploy_id,omitempty"`
+	StackID         string `json:"stack_id,omitempty"`
+	Service         string `json:"service,omitempty"`
+	SourceContainer string `json:"source_container,omitempty"`
+	SourceImage     string `json:"source_image,omitempty"`
+	SourceDigest    string `json:"source_digest,omitempty"`
+}
+
+// OwnedResource is a cluster object carrying the dashboard ownership labels.
+type OwnedResource struct {
+	Cluster   string    `json:"cluster"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace,omitempty"`
+	Owner     Ownership `json:"owner"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrphanResource is a labelled object whose deploy record is deleted or missing.
+type OrphanResource struct {
+	OwnedResource
+	Reason string `json:"reason"` // "record_missing", "record_deleted"
+}
+
+// MissingResource is a deploy the database considers active but which has no
+// labelled objects on its cluster.
+type MissingResource struct {
+	Cluster     string `json:"cluster"`
+	DeployID    string `json:"deploy_id,omitempty"`
+	StackID     string `json:"stack_id,omitempty"`
+	ServiceName string `json:"service_name,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Status      string `json:"status"`
+}
+
+// OwnershipScan is the result of comparing labelled cluster objects with deploy records.
+type OwnershipScan struct {
+	ScannedAt  time.Time         `json:"scanned_at"`
+	Clusters   []string          `json:"clusters"`
+	Orphans    []OrphanResource  `json:"orphans"`
+	Missing    []MissingResource `json:"missing"`
+	Unlabelled []MissingResource `json:"unlabelled"` // deployed before ownership labels; cannot be matched
+	Errors     []string          `json:"errors,omitempty"`
+}
+
 // --- Deploy Models ---
 
 type DeployRequest struct {
-	ContainerID string        `json:"container_id" binding:"required"`
-	ClusterName string        `json:"cluster_name" binding:"required"`
-	Namespace   string        `json:"namespace"`
-	Options     DeployOptions `json:"options"`
+	ContainerID string          `json:"container_id" binding:"required"`
+	ClusterName string          `json:"cluster_name" binding:"required_without=Targets"`
+	Namespace   string          `json:"namespace"`
+	Options     DeployOptions   `json:"options"`
+	Targets     []ClusterTarget `json:"targets,omitempty" binding:"omitempty,dive"` // multi-cluster fan-out; replaces cluster_name
+	Strategy    string          `json:"strategy,omitempty"`                         // fan-out strategy: "parallel" (default) or "waves"
+	NoCache     bool            `json:"no_cache,omitempty"`                         // skip the AI response cache
+	RequestedBy string          `json:"requested_by,omitempty"`                     // attributed AI usage
 }
 
 type DeployOptions struct {
@@ -157,7 +327,13 @@ type DeployResponse struct {
 	AIAnalysis      *AIAnalysis      `json:"ai_analysis,omitempty"`
 	Recommendations *Recommendations `json:"recommendations,omitempty"`
 	Manifests       *Manifests       `json:"manifests,omitempty"`
+	ManifestVersion int              `json:"manifest_version,omitempty"` // 1 when generated, +1 per refine or edit
 	EstimatedCost   *EstimatedCost   `json:"estimated_cost,omitempty"`
+	Cached          bool             `json:"cached,omitempty"` // manifests came from the AI response cache
+
+	PolicyViolations   []PolicyViolation `json:"policy_violations,omitempty"`    // violations the manifests still have
+	PolicyRepairRounds int               `json:"policy_repair_rounds,omitempty"` // AI refinements spent fixing violations
+	Quality            *QualityReport    `json:"quality,omitempty"`              // lint score of the current manifests
 }
 
 type AIAnalysis struct {
@@ -166,6 +342,7 @@ type AIAnalysis struct {
 	SimilarDeployments int    `json:"similar_deployments"`
 }
 
+// Recommendations are the resources of the primary workload in the current manifests.
 type Recommendations struct {
 	CPURequest    string `json:"cpu_request"`
 	CPULimit      string `json:"cpu_limit"`
@@ -174,6 +351,29 @@ type Recommendations struct {
 	Replicas      int    `json:"replicas"`
 	EnableHPA     bool   `json:"enable_hpa"`
 	Reasoning     string `json:"reasoning"`
+
+	Usage *UsageProfile `json:"usage,omitempty"` // sampled usage of the source container
+}
+
+// UsageProfile is the CPU and memory usage of a Docker container sampled over a
+// window, with the requests and limits derived from it. CPU is in millicores (100% of
+// one core = 1000) and memory in bytes.
+type UsageProfile struct {
+	Samples   int              `json:"samples"`
+	From      time.Time        `json:"from"`
+	To        time.Time        `json:"to"`
+	CPU       UsagePercentiles `json:"cpu_millicores"`
+	Memory    UsagePercentiles `json:"memory_bytes"`
+	Headroom  float64          `json:"headroom"` // share added to p95 for the requests
+	Suggested ResourceSizing   `json:"suggested"`
+}
+
+// ResourceSizing is a set of container requests and limits.
+type ResourceSizing struct {
+	CPURequest    string `json:"cpu_request"`
+	CPULimit      string `json:"cpu_limit"`
+	MemoryRequest string `json:"memory_request"`
+	MemoryLimit   string `json:"memory_limit"`
 }
 
 type Manifests struct {
@@ -183,67 +383,310 @@ type Manifests struct {
 	ConfigMap  string `json:"configmap,omitempty"`
 }
 
+// EstimatedCost is the monthly cost of a deploy's manifests under the pricing profile
+// of its cluster. MaxMonthlyUSD is the cost with every HPA target at maxReplicas.
 type EstimatedCost struct {
-	MonthlyUSD float64 `json:"monthly_usd"`
-	Breakdown  string  `json:"breakdown"`
+	MonthlyUSD    float64    `json:"monthly_usd"`
+	MaxMonthlyUSD float64    `json:"max_monthly_usd,omitempty"`
+	Breakdown     string     `json:"breakdown"`
+	Cluster       string     `json:"cluster,omitempty"`
+	Profile       string     `json:"profile,omitempty"` // pricing profile that was applied
+	Items         []CostItem `json:"items,omitempty"`
+}
+
+// Resources priced in a cost estimate
+const (
+	CostCPU          = "cpu"           // requested vCPU × replicas
+	CostMemory       = "memory"        // requested GiB × replicas
+	CostLoadBalancer = "load_balancer" // Services of type LoadBalancer
+	CostStorage      = "storage"       // GiB of persistent volume claims
+	CostNodeOverhead = "node_overhead" // share of the CPU and memory cost
+)
+
+// CostItem is the monthly cost of one kind of resource. Quantity is in vCPU, GiB,
+// LoadBalancers, GiB of storage or, for the node overhead, the share applied.
+type CostItem struct {
+	Resource      string  `json:"resource"`
+	Quantity      float64 `json:"quantity"`
+	MaxQuantity   float64 `json:"max_quantity,omitempty"`
+	MonthlyUSD    float64 `json:"monthly_usd"`
+	MaxMonthlyUSD float64 `json:"max_monthly_usd,omitempty"`
+}
+
+// PricingConfig holds the price lists used to estimate the cost of generated manifests,
+// one profile per cluster type (e.g. eks, aks, on-prem). A cluster uses the profile
+// mapped to it in Clusters, then the profile named after its type, then Default.
+type PricingConfig struct {
+	Default  string                    `json:"default,omitempty" yaml:"default"`
+	Clusters map[string]string         `json:"clusters,omitempty" yaml:"clusters"` // cluster name → profile
+	Profiles map[string]PricingProfile `json:"profiles" yaml:"profiles"`
+}
+
+// PricingProfile is the price list of one cluster type in USD.
+type PricingProfile struct {
+	VCPUHour         float64 `json:"vcpu_hour" yaml:"vcpu_hour"`                   // per requested vCPU
+	GiBHour          float64 `json:"gib_hour" yaml:"gib_hour"`                     // per requested GiB of memory
+	LoadBalancerHour float64 `json:"load_balancer_hour" yaml:"load_balancer_hour"` // per Service of type LoadBalancer
+	PVCGiBMonth      float64 `json:"pvc_gib_month" yaml:"pvc_gib_month"`           // per GiB of persistent volume claims
+	// NodeOverhead is the share added to the CPU and memory cost for system daemons and
+	// capacity that nodes cannot allocate to pods, e.g. 0.1 (optional)
+	NodeOverhead float64 `json:"node_overhead,omitempty" yaml:"node_overhead"`
 }
 
 type ExecuteRequest struct {
 	Approved      bool              `json:"approved"`
+	Modifications map[string]string `json:"modifications,omitempty"` // resource ("deployment", "service", "hpa", "configmap") -> replacement YAML
+	Edits         []ManifestEdit    `json:"edits,omitempty"`         // applied after modifications
+	SkipRollback  bool              `json:"skip_rollback,omitempty"` // keep the failed state on the cluster for debugging
+	Strategy      *DeployStrategy   `json:"strategy,omitempty"`      // rollout strategy; default rolling (plain apply)
+}
+
+// Manifest edit types.
+const (
+	EditReplace        = "replace"   // YAML replaces the resource
+	EditJSONPatch      = "json"      // RFC 6902 JSON Patch
+	EditMergePatch     = "merge"     // RFC 7386 JSON Merge Patch
+	EditStrategicMerge = "strategic" // Kubernetes strategic merge patch
+)
+
+// Policy rules checked on generated manifests.
+const (
+	PolicySchema      = "schema"              // valid YAML matching the Kubernetes schema
+	PolicyResources   = "resources"           // CPU and memory requests and limits on every container
+	PolicyProbes      = "probes"              // readiness and liveness probes on long-running containers
+	PolicyPinnedImage = "no_latest_tag"       // images pinned to a tag other than latest, or a digest
+	PolicyNonRoot     = "non_root"            // containers run as non-root unless root is explicit
+	PolicySelector    = "selector_labels"     // workload selectors match their pod template labels
+	PolicyTargetPort  = "service_target_port" // Service target ports match a container port
+)
+
+// PolicyViolation is a generated object breaking one policy rule.
+type PolicyViolation struct {
+	Rule     string `json:"rule"`
+	Resource string `json:"resource"` // Kind/name
+	Message  string `json:"message"`
+}
+
+// Manifest quality categories.
+const (
+	QualitySecurity    = "security"
+	QualityReliability = "reliability"
+	QualityResources   = "resources"
+	QualityNetworking  = "networking"
+)
+
+// Severities of manifest quality findings, most severe first.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// QualityFinding is one lint finding on a manifest object.
+type QualityFinding struct {
+	Severity string `json:"severity"`
+	Category string `json:"category"`
+	Resource string `json:"resource"` // Kind/name
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// QualityCategoryScore is the score of one quality category.
+type QualityCategoryScore struct {
+	Category string `json:"category"`
+	Score    int    `json:"score"` // 0-100
+	Findings int    `json:"findings"`
+}
+
+// QualityReport is the deterministic lint report of a manifest set. Scores are only
+// comparable between reports of the same rules version.
+type QualityReport struct {
+	Score        int                    `json:"score"` // 0-100, mean of the category scores
+	RulesVersion int                    `json:"rules_version"`
+	Categories   []QualityCategoryScore `json:"categories"`
+	Counts       map[string]int         `json:"counts"`   // findings per severity
+	Findings     []QualityFinding       `json:"findings"` // by severity, then resource
+}
+
+// ManifestEdit is a manual change to one resource of generated manifests.
+type ManifestEdit struct {
+	Resource string `json:"resource"`        // "deployment", "service", "hpa" or "configmap"
+	Type     string `json:"type,omitempty"`  // default "replace" with yaml, "strategic" with patch
+	YAML     string `json:"yaml,omitempty"`  // replace: the new manifest; empty removes an hpa or configmap
+	Patch    string `json:"patch,omitempty"` // patch document, JSON or YAML
+}
+
+// EditManifestRequest edits the manifests of a deploy before it is executed.
+type EditManifestRequest struct {
 	Modifications map[string]string `json:"modifications,omitempty"`
+	Edits         []ManifestEdit    `json:"edits,omitempty"`
+}
+
+// Deploy strategies.
+const (
+	StrategyRolling   = "rolling"    // Server-Side Apply of the Deployment
+	StrategyBlueGreen = "blue_green" // parallel Deployment; the Service switches after readiness
+	StrategyCanary    = "canary"     // small Deployment behind the same Service, growing step by step
+)
+
+// DeployStrategy selects how an execute rolls out the Deployment.
+type DeployStrategy struct {
+	Type         string `json:"type"`
+	CanarySteps  []int  `json:"canary_steps,omitempty"`  // canary: traffic share (%) of each step, increasing; default [10, 50]
+	StepInterval int    `json:"step_interval,omitempty"` // canary: seconds each step is observed before its health gate; default 60
+	MaxRestarts  int    `json:"max_restarts,omitempty"`  // canary: container restarts a health gate tolerates
+}
+
+// Rollout phases of a blue/green or canary deploy.
+const (
+	RolloutProgressing       = "progressing"
+	RolloutAwaitingPromotion = "awaiting_promotion"
+	RolloutPromoted          = "promoted"
+	RolloutAborted           = "aborted"
+	RolloutFailed            = "failed"
+)
+
+// RolloutProgress is the state of a blue/green or canary deploy.
+type RolloutProgress struct {
+	Strategy       string `json:"strategy"`
+	Phase          string `json:"phase"`
+	ActiveSlot     string `json:"active_slot,omitempty"`     // blue/green: slot the Service routes to
+	PreviousSlot   string `json:"previous_slot,omitempty"`   // blue/green: slot kept for switch-back; "" is the original Deployment
+	CanaryWeight   int    `json:"canary_weight,omitempty"`   // canary: current traffic share (%)
+	CanaryReplicas int    `json:"canary_replicas,omitempty"` // canary: replicas of the canary Deployment
+	StableReplicas int    `json:"stable_replicas,omitempty"` // canary: replicas left on the stable Deployment
 }
 
 type DeployStatus struct {
-	DeployID    string       `json:"deploy_id"`
-	Status      string       `json:"status"`
-	StartedAt   *time.Time   `json:"started_at,omitempty"`
-	CompletedAt *time.Time   `json:"completed_at,omitempty"`
-	Steps       []DeployStep `json:"steps"`
-	Result      *DeployResult `json:"result,omitempty"`
+	DeployID    string           `json:"deploy_id"`
+	Status      string           `json:"status"`
+	StartedAt   *time.Time       `json:"started_at,omitempty"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Steps       []DeployStep     `json:"steps"`
+	Result      *DeployResult    `json:"result,omitempty"`
+	Commit      *GitCommit       `json:"commit,omitempty"`  // set when the target cluster uses git delivery
+	Rollout     *RolloutProgress `json:"rollout,omitempty"` // set for blue/green and canary deploys
+
+	// Multi-cluster fan-out: the parent nests the status of each cluster's deploy
+	ClusterName string                   `json:"cluster_name,omitempty"`
+	Wave        int                      `json:"wave,omitempty"`
+	Clusters    map[string]*DeployStatus `json:"clusters,omitempty"`
 }
 
 type DeployStep struct {
-	Step        string     `json:"step"`
-	Status      string     `json:"status"`
-	Message     string     `json:"message,omitempty"`
-	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Step        string            `json:"step"`
+	Status      string            `json:"status"`
+	Message     string            `json:"message,omitempty"`
+	Resources   []AppliedResource `json:"resources,omitempty"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
 }
 
 type DeployResult struct {
-	DeploymentName string `json:"deployment_name"`
-	Namespace      string `json:"namespace"`
-	Replicas       int    `json:"replicas"`
-	PodsReady      string `json:"pods_ready"`
-	ServiceURL     string `json:"service_url"`
+	DeploymentName string       `json:"deployment_name"`
+	Namespace      string       `json:"namespace"`
+	Replicas       int          `json:"replicas"`
+	PodsReady      string       `json:"pods_ready"`
+	ServiceURL     string       `json:"service_url"`
+	Failures       []PodFailure `json:"failures,omitempty"`
+}
+
+// --- Deploy Preview (server-side dry-run diff) ---
+
+type PreviewRequest struct {
+	ClusterName string `json:"cluster_name,omitempty"`
+}
+
+type DeployPreview struct {
+	DeployID    string         `json:"deploy_id"`
+	ClusterName string         `json:"cluster_name"`
+	Summary     PreviewSummary `json:"summary"`
+	Resources   []ResourceDiff `json:"resources"`
+}
+
+type PreviewSummary struct {
+	Create    int `json:"create"`
+	Change    int `json:"change"`
+	Unchanged int `json:"unchanged"`
+	Error     int `json:"error"`
+}
+
+// ResourceDiff describes what applying one object would do.
+// Action is one of "create", "change", "unchanged" or "error"; revision diffs also
+// report "delete" for an object the newer revision no longer has.
+type ResourceDiff struct {
+	Service   string        `json:"service,omitempty"`
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace,omitempty"`
+	Action    string        `json:"action"`
+	Changes   []FieldChange `json:"changes,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type FieldChange struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
 }
 
 // --- Deployment History (Data Layer) ---
 
 type DeploymentHistory struct {
-	ID            string    `json:"id"`
-	ServiceName   string    `json:"service_name"`
-	ImageName     string    `json:"image_name"`
-	ImageTag      string    `json:"image_tag"`
-	ServiceType   string    `json:"service_type"`
-	Language      string    `json:"language"`
-	CPURequest    string    `json:"cpu_request"`
-	CPULimit      string    `json:"cpu_limit"`
-	MemoryRequest string    `json:"memory_request"`
-	MemoryLimit   string    `json:"memory_limit"`
-	Replicas      int       `json:"replicas"`
-	ActualCPU     string    `json:"actual_cpu"`
-	ActualMemory  string    `json:"actual_memory"`
-	TargetCluster string    `json:"target_cluster"`
-	Namespace     string    `json:"namespace"`
-	DeployedAt    time.Time `json:"deployed_at"`
-	Success       bool      `json:"success"`
-	Status        string    `json:"status"`                    // "deployed", "deleted", "failed"
-	ManifestJSON  string    `json:"manifest_json,omitempty"`   // stored manifest for redeploy
-	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
-	OOMEvents     int       `json:"oom_events"`
-	ThrottleEvents int      `json:"throttle_events"`
-	AIGenerated   bool      `json:"ai_generated"`
-	AIConfidence  float64   `json:"ai_confidence"`
+	ID             string     `json:"id"`
+	ServiceName    string     `json:"service_name"`
+	ImageName      string     `json:"image_name"`
+	ImageTag       string     `json:"image_tag"`
+	ServiceType    string     `json:"service_type"`
+	Language       string     `json:"language"`
+	CPURequest     string     `json:"cpu_request"`
+	CPULimit       string     `json:"cpu_limit"`
+	MemoryRequest  string     `json:"memory_request"`
+	MemoryLimit    string     `json:"memory_limit"`
+	Replicas       int        `json:"replicas"`
+	ActualCPU      string     `json:"actual_cpu"`
+	ActualMemory   string     `json:"actual_memory"`
+	TargetCluster  string     `json:"target_cluster"`
+	Namespace      string     `json:"namespace"`
+	DeployedAt     time.Time  `json:"deployed_at"`
+	Success        bool       `json:"success"`
+	Status         string     `json:"status"`                  // "deployed", "deleted", "failed"
+	ManifestJSON   string     `json:"manifest_json,omitempty"` // stored manifest for redeploy
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	OOMEvents      int        `json:"oom_events"`
+	ThrottleEvents int        `json:"throttle_events"`
+	AIGenerated    bool       `json:"ai_generated"`
+	AIConfidence   float64    `json:"ai_confidence"`
+	DriftStatus    string     `json:"drift_status,omitempty"` // "in_sync", "drifted", "missing", "error"
+	DriftCheckedAt *time.Time `json:"drift_checked_at,omitempty"`
+	CommitSHA      string     `json:"commit_sha,omitempty"` // git delivery commit
+	ParentID       string     `json:"parent_id,omitempty"`  // multi-cluster deploy this cluster's deploy belongs to
+	FanOut         *FanOut    `json:"fan_out,omitempty"`    // set on the parent entry of a multi-cluster deploy
+
+	Usage *DeploymentUsage `json:"usage,omitempty"` // measured by the post-deploy feedback job
+}
+
+// UsagePercentiles summarizes the samples of one resource.
+type UsagePercentiles struct {
+	P50 int64 `json:"p50"`
+	P95 int64 `json:"p95"`
+	Max int64 `json:"max"`
+}
+
+// DeploymentUsage is what the post-deploy feedback job measured for a deploy during
+// its observation window. CPU is in millicores and memory in bytes, per pod; the p95
+// values are also written to ActualCPU and ActualMemory. ThrottleEvents counts the
+// sampling intervals in which a container was throttled in a large share of its CFS
+// periods; ThrottleMeasured is false when no node exposed cAdvisor metrics.
+type DeploymentUsage struct {
+	Samples          int              `json:"samples"`
+	CPU              UsagePercentiles `json:"cpu_millicores"`
+	Memory           UsagePercentiles `json:"memory_bytes"`
+	OOMEvents        int              `json:"oom_events"`
+	ThrottleEvents   int              `json:"throttle_events"`
+	ThrottleMeasured bool             `json:"throttle_measured"`
+	From             time.Time        `json:"from"`
+	To               time.Time        `json:"to"`
+	Completed        bool             `json:"completed"` // the observation window has ended
 }
 
 // --- AI Models ---
@@ -255,6 +698,14 @@ type ManifestResult struct {
 	HPA        string  `json:"hpa,omitempty"`
 	Reasoning  string  `json:"reasoning"`
 	Confidence float64 `json:"confidence"`
+	Cached     bool    `json:"cached,omitempty"` // served from the AI response cache
+
+	// The model and prompt templates that produced the manifests; empty for fallback templates
+	Provider      string `json:"provider,omitempty"`
+	Model         string `json:"model,omitempty"`
+	PromptVersion int    `json:"prompt_version,omitempty"`
+
+	Examples []string `json:"examples,omitempty"` // IDs of the past deploys given as few-shot examples
 }
 
 // --- Cluster Management Models ---
@@ -273,6 +724,8 @@ type RegisterClusterRequest struct {
 	Type       string `json:"type"`
 	Kubeconfig string `json:"kubeconfig"`
 	Registry   string `json:"registry"`
+	Delivery   string       `json:"delivery,omitempty"` // "direct" (default) or "git"
+	Git        *GitDelivery `json:"git,omitempty"`
 }
 
 type UpdateAIConfigRequest struct {
@@ -288,20 +741,356 @@ type RegisteredCluster struct {
 	Kubeconfig string    `json:"kubeconfig"`
 	Context    string    `json:"context"`
 	Registry   string    `json:"registry"`
+	Delivery   string       `json:"delivery,omitempty"`
+	Git        *GitDelivery `json:"git,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
 }
 
+// --- Multi-Cluster Fan-out Models ---
+
+// Fan-out strategies.
+const (
+	FanOutParallel = "parallel" // every cluster at once
+	FanOutWaves    = "waves"    // wave by wave; a failed wave stops the rollout
+)
+
+// ManifestOverrides are per-target changes applied to generated manifests.
+type ManifestOverrides struct {
+	Namespace   string `json:"namespace,omitempty" yaml:"namespace"`
+	Replicas    int    `json:"replicas,omitempty" yaml:"replicas"`         // Deployment / StatefulSet replicas
+	Registry    string `json:"registry,omitempty" yaml:"registry"`         // registry host (and path) replacing the one of every container image
+	IngressHost string `json:"ingress_host,omitempty" yaml:"ingress_host"` // host of every Ingress rule and TLS entry
+}
+
+// ClusterTarget is one cluster of a multi-cluster deploy with its overrides.
+type ClusterTarget struct {
+	ClusterName string `json:"cluster_name" binding:"required"`
+	ManifestOverrides
+	Wave int `json:"wave,omitempty"` // waves strategy: lower waves deploy first; unset, the position in targets (1-based)
+}
+
+// FanOut links a multi-cluster deploy to the deploys it ran on each cluster.
+type FanOut struct {
+	Strategy string         `json:"strategy"`
+	Targets  []TargetResult `json:"targets"`
+}
+
+// TargetResult is the outcome of a multi-cluster deploy on one cluster.
+type TargetResult struct {
+	ClusterTarget
+	DeployID  string `json:"deploy_id,omitempty"`
+	Status    string `json:"status"`
+	CommitSHA string `json:"commit_sha,omitempty"`
+}
+
+// --- GitOps Delivery Models ---
+
+// Cluster delivery modes.
+const (
+	DeliveryDirect = "direct" // Server-Side Apply to the cluster
+	DeliveryGit    = "git"    // commit to a repository reconciled by Argo CD / Flux
+)
+
+// GitDelivery configures where a git-mode cluster's manifests are committed.
+type GitDelivery struct {
+	URL          string                `json:"url" yaml:"url"`                     // clone URL (https, ssh or local path)
+	Branch       string                `json:"branch" yaml:"branch"`               // branch the GitOps controller watches; default "main"
+	Path         string                `json:"path" yaml:"path"`                   // directory inside the repo; default "clusters/<cluster>"
+	AuthorName   string                `json:"author_name,omitempty" yaml:"author_name"`
+	AuthorEmail  string                `json:"author_email,omitempty" yaml:"author_email"`
+	MergeRequest *MergeRequestSettings `json:"merge_request,omitempty" yaml:"merge_request"` // push to a deploy branch and open a merge request instead
+}
+
+// MergeRequestSettings selects the merge request provider for a git-mode cluster.
+type MergeRequestSettings struct {
+	Provider     string `json:"provider" yaml:"provider"`                   // "github", "gitlab" or a registered provider
+	APIURL       string `json:"api_url,omitempty" yaml:"api_url"`           // provider API base URL; defaults to the public service
+	Project      string `json:"project" yaml:"project"`                     // "owner/repo" or GitLab project path
+	TargetBranch string `json:"target_branch,omitempty" yaml:"target_branch"` // defaults to GitDelivery.Branch
+	TokenEnv     string `json:"token_env,omitempty" yaml:"token_env"`       // environment variable holding the API token
+}
+
+// GitCommit records the commit a git-mode deploy produced.
+type GitCommit struct {
+	Repository      string `json:"repository"`
+	Branch          string `json:"branch"`
+	SHA             string `json:"sha"`
+	Path            string `json:"path"`
+	MergeRequestURL string `json:"merge_request_url,omitempty"`
+	Unchanged       bool   `json:"unchanged,omitempty"` // the repository already held these manifests; nothing was pushed
+}
+
+// --- Environment Promotion Models ---
+
+// Promotion kinds: what was promoted.
+const (
+	PromotionDeploy = "deploy" // a single deploy (deployment_history)
+	PromotionStack  = "stack"  // a stack deploy (stack_deploys)
+)
+
+// Promotion statuses.
+const (
+	PromotionPending   = "pending_approval"
+	PromotionRejected  = "rejected"
+	PromotionDeploying = "deploying"
+	PromotionCompleted = "completed"
+	PromotionFailed    = "failed"
+)
+
+// Environment is one stage of the promotion pipeline (e.g. dev → staging → prod).
+type Environment struct {
+	Name      string            `json:"name"`
+	Order     int               `json:"order"` // position in the pipeline, from 1
+	Cluster   string            `json:"cluster"`
+	Namespace string            `json:"namespace"`
+	Patch     ManifestOverrides `json:"patch"`
+	Current   *Promotion        `json:"current,omitempty"` // latest completed promotion into the environment
+}
+
+// PromoteRequest asks to promote a deploy to the next environment.
+type PromoteRequest struct {
+	RequestedBy string `json:"requested_by"`
+	Comment     string `json:"comment"`
+}
+
+// PromotionDecisionRequest approves or rejects a pending promotion.
+type PromotionDecisionRequest struct {
+	Approver string `json:"approver" binding:"required"`
+	Comment  string `json:"comment"`
+}
+
+// Promotion records a deploy moved from one environment to the next and the artifact
+// version it carried.
+type Promotion struct {
+	ID              string     `json:"id"`
+	Kind            string     `json:"kind"`      // PromotionDeploy or PromotionStack
+	SourceID        string     `json:"source_id"` // deploy in FromEnv that was promoted
+	Name            string     `json:"name"`      // service or stack name
+	FromEnv         string     `json:"from_env"`
+	ToEnv           string     `json:"to_env"`
+	ClusterName     string     `json:"cluster_name"`
+	Namespace       string     `json:"namespace"`
+	ArtifactVersion string     `json:"artifact_version"` // digest of the manifests first promoted, carried across stages
+	Images          []string   `json:"images"`           // container images of the promoted manifests
+	Status          string     `json:"status"`
+	DeployID        string     `json:"deploy_id,omitempty"` // deploy created in ToEnv once approved
+	RequestedBy     string     `json:"requested_by,omitempty"`
+	RequestComment  string     `json:"request_comment,omitempty"`
+	DecidedBy       string     `json:"decided_by,omitempty"`
+	DecisionComment string     `json:"decision_comment,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	RequestedAt     time.Time  `json:"requested_at"`
+	DecidedAt       *time.Time `json:"decided_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
+
+// PromotionFilter narrows a promotion listing; empty fields match everything.
+type PromotionFilter struct {
+	Status   string
+	ToEnv    string
+	SourceID string
+	DeployID string
+	Limit    int
+}
+
+// --- Manifest Revision Models ---
+
+// Manifest revision sources.
+const (
+	RevisionGenerate = "generate" // AI generation (also regenerate)
+	RevisionFallback = "fallback" // template generated because the AI was unavailable
+	RevisionRefine   = "refine"   // AI refine; Feedback holds the request
+	RevisionEdit     = "edit"     // manual edit
+	RevisionRevert   = "revert"   // copy of the revision in RevertedFrom
+)
+
+// ManifestRevision is one version of the manifests of a single or stack deploy.
+type ManifestRevision struct {
+	DeployID     string            `json:"deploy_id"`
+	Type         string            `json:"type"` // "single" or "stack"
+	Version      int               `json:"version"`
+	Source       string            `json:"source"`
+	Feedback     string            `json:"feedback,omitempty"`
+	RevertedFrom int               `json:"reverted_from,omitempty"`
+	ManifestJSON string            `json:"-"`                   // models.ManifestResult or ai.StackManifestResult
+	Documents    map[string]string `json:"documents,omitempty"` // "deployment" or "<Kind>/<name>" -> YAML
+	CreatedAt    time.Time         `json:"created_at"`
+
+	Quality       *QualityReport `json:"quality,omitempty"`
+	Provider      string         `json:"provider,omitempty"` // model that produced the manifests, if any
+	Model         string         `json:"model,omitempty"`
+	PromptVersion int            `json:"prompt_version,omitempty"`
+}
+
+// ManifestRevisionDiff compares two revisions object by object. Stack diffs set the
+// service of each resource.
+type ManifestRevisionDiff struct {
+	From      int            `json:"from"`
+	To        int            `json:"to"`
+	Resources []ResourceDiff `json:"resources"`
+}
+
+// --- AI Response Cache Models ---
+
+// AICacheEntry is a generated manifest stored under the hash of the inputs that
+// produced it.
+type AICacheEntry struct {
+	Key        string    `json:"key"`
+	Kind       string    `json:"kind"` // "manifest" or "stack"
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model"`
+	ResultJSON string    `json:"-"` // models.ManifestResult or ai.StackManifestResult
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// AICacheStats reports the AI response cache since the server started. Entries
+// counts the unexpired entries in the store.
+type AICacheStats struct {
+	Enabled  bool    `json:"enabled"`
+	TTL      string  `json:"ttl"`
+	Entries  int     `json:"entries"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	Bypassed int64   `json:"bypassed"`
+	HitRate  float64 `json:"hit_rate"` // hits / (hits + misses)
+}
+
+// --- AI Usage Models ---
+
+// Outcomes of an AI call.
+const (
+	AIOutcomeOK         = "ok"
+	AIOutcomeFallback   = "fallback"    // the call failed and a fallback template was used
+	AIOutcomeParseError = "parse_error" // the reply could not be parsed
+	AIOutcomeError      = "error"       // the call failed and the error was returned (refine)
+)
+
+// AIUsageRecord is one AI request, including its retries.
+type AIUsageRecord struct {
+	ID               int64     `json:"id"`
+	Operation        string    `json:"operation"` // generate, refine, generate_stack, refine_stack
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+	LatencyMS        int64     `json:"latency_ms"`
+	Retries          int       `json:"retries"`
+	Outcome          string    `json:"outcome"`
+	Error            string    `json:"error,omitempty"`
+	DeployID         string    `json:"deploy_id,omitempty"`
+	User             string    `json:"user,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// AIUsageFilter selects usage records. Zero values match everything.
+type AIUsageFilter struct {
+	From     time.Time
+	To       time.Time
+	DeployID string
+}
+
+// AIUsageSummary aggregates usage records under one key (a day, model or user).
+type AIUsageSummary struct {
+	Key              string  `json:"key"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	AvgLatencyMS     int64   `json:"avg_latency_ms"`
+	MaxLatencyMS     int64   `json:"max_latency_ms"`
+	Retries          int     `json:"retries"`
+	Failures         int     `json:"failures"` // calls whose outcome is not ok
+}
+
+// AIBudgetStatus reports spending against the monthly budget.
+type AIBudgetStatus struct {
+	MonthlyUSD float64 `json:"monthly_usd"` // 0 when no budget is set
+	SpentUSD   float64 `json:"spent_usd"`   // this month
+	Exceeded   bool    `json:"exceeded"`
+}
+
+// AIUsageReport is the response of the AI usage endpoint.
+type AIUsageReport struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Total   AIUsageSummary   `json:"total"`
+	ByDay   []AIUsageSummary `json:"by_day"`
+	ByModel []AIUsageSummary `json:"by_model"`
+	ByUser  []AIUsageSummary `json:"by_user"`
+	Budget  AIBudgetStatus   `json:"budget"`
+}
+
+// AIQualityFilter selects the AI-produced revisions compared by the quality report.
+// Zero values match everything.
+type AIQualityFilter struct {
+	From   time.Time
+	To     time.Time
+	Source string // "generate" or "refine"
+}
+
+// AIQualitySummary aggregates the quality scores of the revisions one model produced
+// with one prompt version, scored by one rules version.
+type AIQualitySummary struct {
+	Provider      string  `json:"provider"`
+	Model         string  `json:"model"`
+	PromptVersion int     `json:"prompt_version"`
+	RulesVersion  int     `json:"rules_version"`
+	Revisions     int     `json:"revisions"`
+	AvgScore      float64 `json:"avg_score"`
+	MinScore      int     `json:"min_score"`
+	MaxScore      int     `json:"max_score"`
+}
+
+// AIQualityReport is the response of the AI quality endpoint.
+type AIQualityReport struct {
+	From    time.Time          `json:"from"`
+	To      time.Time          `json:"to"`
+	Source  string             `json:"source,omitempty"`
+	ByModel []AIQualitySummary `json:"by_model"`
+}
+
+// Generation event types
+const (
+	GenerationStarted   = "started"   // an attempt was sent to the provider
+	GenerationProgress  = "progress"  // reply text arrived
+	GenerationRetry     = "retry"     // an attempt failed and the next one is pending
+	GenerationFallback  = "fallback"  // a fallback template replaced the AI reply
+	GenerationCompleted = "completed" // the reply was parsed
+	GenerationFailed    = "failed"    // the request failed (refine)
+	GenerationRepair    = "repair"    // policy violations are sent back to the model
+)
+
+// GenerationEvent reports the progress of a manifest generation. Reasoning holds the
+// reasoning text received so far, not only the newest piece.
+type GenerationEvent struct {
+	Seq            int       `json:"seq"`
+	DeployID       string    `json:"deploy_id"`
+	Operation      string    `json:"operation"`
+	Type           string    `json:"type"`
+	Attempt        int       `json:"attempt,omitempty"`
+	TokensReceived int       `json:"tokens_received,omitempty"`
+	Reasoning      string    `json:"reasoning,omitempty"`
+	Message        string    `json:"message,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
 // --- Stack Deploy Models ---
 
 // StackDeployRequest represents a request to deploy multiple containers as a connected stack.
 type StackDeployRequest struct {
-	ContainerIDs    []string      `json:"container_ids" binding:"required,min=2"`
-	ClusterName     string        `json:"cluster_name"`
-	Namespace       string        `json:"namespace"`
-	StackName       string        `json:"stack_name"`
-	CreateNamespace bool          `json:"create_namespace"`
-	Prompt          string        `json:"prompt"`
-	Options         DeployOptions `json:"options"`
+	ContainerIDs    []string        `json:"container_ids" binding:"required,min=2"`
+	ClusterName     string          `json:"cluster_name"`
+	Namespace       string          `json:"namespace"`
+	StackName       string          `json:"stack_name"`
+	CreateNamespace bool            `json:"create_namespace"`
+	Prompt          string          `json:"prompt"`
+	Options         DeployOptions   `json:"options"`
+	Targets         []ClusterTarget `json:"targets,omitempty" binding:"omitempty,dive"` // multi-cluster fan-out; replaces cluster_name
+	Strategy        string          `json:"strategy,omitempty"`                         // fan-out strategy: "parallel" (default) or "waves"
+	NoCache         bool            `json:"no_cache,omitempty"`                         // skip the AI response cache
+	RequestedBy     string          `json:"requested_by,omitempty"`                     // attributed AI usage
 }
 
 // ServiceConnection represents a detected connection between services.
@@ -333,13 +1122,20 @@ type StackManifests map[string]map[string]string
 
 // StackDeployResponse is the response for a stack deployment request.
 type StackDeployResponse struct {
-	DeployID   string          `json:"deploy_id"`
-	Status     string          `json:"status"`
-	StackName  string          `json:"stack_name"`
-	Topology   *StackTopology  `json:"topology,omitempty"`
-	Manifests  StackManifests  `json:"manifests,omitempty"`
-	Reasoning  string          `json:"reasoning,omitempty"`
-	Confidence float64         `json:"confidence,omitempty"`
+	DeployID        string         `json:"deploy_id"`
+	Status          string         `json:"status"`
+	StackName       string         `json:"stack_name"`
+	Topology        *StackTopology `json:"topology,omitempty"`
+	Manifests       StackManifests `json:"manifests,omitempty"`
+	Reasoning       string         `json:"reasoning,omitempty"`
+	Confidence      float64        `json:"confidence,omitempty"`
+	ManifestVersion int            `json:"manifest_version,omitempty"` // latest manifest revision
+	Cached          bool           `json:"cached,omitempty"`           // manifests came from the AI response cache
+
+	PolicyViolations   []PolicyViolation `json:"policy_violations,omitempty"`    // violations the manifests still have
+	PolicyRepairRounds int               `json:"policy_repair_rounds,omitempty"` // AI refinements spent fixing violations
+	Quality            *QualityReport    `json:"quality,omitempty"`              // lint score of the current manifests
+	EstimatedCost      *EstimatedCost    `json:"estimated_cost,omitempty"`
 }
 
 // ServiceDeployStatus tracks individual service progress within a stack.
@@ -351,13 +1147,19 @@ type ServiceDeployStatus struct {
 
 // StackDeployStatus tracks per-service deployment progress.
 type StackDeployStatus struct {
-	DeployID    string                         `json:"deploy_id"`
-	Status      string                         `json:"status"`
-	StackName   string                         `json:"stack_name"`
-	StartedAt   *time.Time                     `json:"started_at,omitempty"`
-	CompletedAt *time.Time                     `json:"completed_at,omitempty"`
+	DeployID    string                          `json:"deploy_id"`
+	Status      string                          `json:"status"`
+	StackName   string                          `json:"stack_name"`
+	StartedAt   *time.Time                      `json:"started_at,omitempty"`
+	CompletedAt *time.Time                      `json:"completed_at,omitempty"`
 	Services    map[string]*ServiceDeployStatus `json:"services"`
-	DeployOrder []string                       `json:"deploy_order"`
+	DeployOrder []string                        `json:"deploy_order"`
+	Commit      *GitCommit                      `json:"commit,omitempty"` // set when the target cluster uses git delivery
+
+	// Multi-cluster fan-out: the parent nests the status of each cluster's stack deploy
+	ClusterName string                        `json:"cluster_name,omitempty"`
+	Wave        int                           `json:"wave,omitempty"`
+	Clusters    map[string]*StackDeployStatus `json:"clusters,omitempty"`
 }
 
 // StackExecuteRequest is the request for executing a stack deployment.
@@ -366,6 +1168,27 @@ type StackExecuteRequest struct {
 	ClusterName     string `json:"cluster_name"`
 	Namespace       string `json:"namespace"`
 	CreateNamespace bool   `json:"create_namespace"`
+	SkipRollback    bool   `json:"skip_rollback,omitempty"` // keep the failed state on the cluster for debugging
+}
+
+// DeployRecord is the DB-persisted representation of a single-container deployment.
+// The request, response, manifests and status are stored as JSON; the status columns
+// take precedence over StatusJSON.
+type DeployRecord struct {
+	DeployID      string     `json:"deploy_id"`
+	ContainerID   string     `json:"container_id"`
+	ClusterName   string     `json:"cluster_name"`
+	Namespace     string     `json:"namespace"`
+	Status        string     `json:"status"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	RequestJSON   string     `json:"request_json,omitempty"`
+	ResponseJSON  string     `json:"response_json,omitempty"`
+	ManifestsJSON string     `json:"manifests_json,omitempty"`
+	StatusJSON    string     `json:"status_json,omitempty"` // DeployStatus with steps and result
+	ParentID      string     `json:"parent_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 // StackDeployRecord is the DB-persisted representation of a stack deployment.
@@ -378,16 +1201,21 @@ type StackDeployRecord struct {
 	CreateNamespace bool       `json:"create_namespace"`
 	Prompt          string     `json:"prompt,omitempty"`
 	Status          string     `json:"status"`
-	StartedAt     *time.Time `json:"started_at,omitempty"`
-	CompletedAt   *time.Time `json:"completed_at,omitempty"`
-	TopologyJSON  string     `json:"topology_json,omitempty"`
-	ManifestsJSON string     `json:"manifests_json,omitempty"`
-	Reasoning     string     `json:"reasoning,omitempty"`
-	Confidence    float64    `json:"confidence"`
-	DeployOrder   []string   `json:"deploy_order"`
-	ServicesJSON  string     `json:"services_json,omitempty"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	TopologyJSON    string     `json:"topology_json,omitempty"`
+	ManifestsJSON   string     `json:"manifests_json,omitempty"`
+	Reasoning       string     `json:"reasoning,omitempty"`
+	Confidence      float64    `json:"confidence"`
+	DeployOrder     []string   `json:"deploy_order"`
+	ServicesJSON    string     `json:"services_json,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DriftStatus     string     `json:"drift_status,omitempty"`
+	DriftCheckedAt  *time.Time `json:"drift_checked_at,omitempty"`
+	CommitSHA       string     `json:"commit_sha,omitempty"`
+	ParentID        string     `json:"parent_id,omitempty"`
+	FanOut          *FanOut    `json:"fan_out,omitempty"`
 }
 
 // --- Unified Deploy History ---
@@ -402,11 +1230,13 @@ type UnifiedDeployItem struct {
 	Cluster      string    `json:"cluster"`
 	Namespace    string    `json:"namespace"`
 	Status       string    `json:"status"`
+	DriftStatus  string    `json:"drift_status,omitempty"`
 	AIGenerated  bool      `json:"ai_generated"`
 	Confidence   float64   `json:"confidence"`
 	DeployedAt   time.Time `json:"deployed_at"`
 	StackDetail  *StackDeployBrief  `json:"stack_detail,omitempty"`
 	SingleDetail *SingleDeployBrief `json:"single_detail,omitempty"`
+	FanOut       *FanOut            `json:"fan_out,omitempty"` // per-cluster results of a multi-cluster deploy
 }
 
 // StackDeployBrief contains summary info for a stack deploy in the unified list.